@@ -0,0 +1,115 @@
+package main
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"relational-db/internal/formatter"
+	"relational-db/internal/parser"
+)
+
+// runFmt implements `relational-db fmt [flags] path ...`, which formats SQL
+// files in place. Directories are searched for .sql files. It returns the
+// exit status: 1 if any file could not be formatted, 2 for bad usage.
+func runFmt(args []string) int {
+	flags := flag.NewFlagSet("fmt", flag.ContinueOnError)
+	lower := flags.Bool("lower", false, "write keywords in lower case")
+	indent := flags.Int("indent", 4, "spaces per indentation level")
+	tabs := flags.Bool("tabs", false, "indent with tabs instead of spaces")
+	width := flags.Int("width", 80, "line width to break lists and conditions at, 0 for no limit")
+	list := flags.Bool("l", false, "list files whose formatting differs instead of rewriting them")
+	flags.Usage = func() {
+		fmt.Fprintln(flags.Output(), "usage: relational-db fmt [flags] path ...")
+		flags.PrintDefaults()
+	}
+	if err := flags.Parse(args); err != nil {
+		return 2
+	}
+	if flags.NArg() == 0 {
+		flags.Usage()
+		return 2
+	}
+
+	opts := formatter.DefaultOptions()
+	if *lower {
+		opts.KeywordCase = formatter.LowerCase
+	}
+	opts.Indent = strings.Repeat(" ", *indent)
+	if *tabs {
+		opts.Indent = "\t"
+	}
+	opts.LineWidth = *width
+
+	status := 0
+	for _, root := range flags.Args() {
+		err := filepath.WalkDir(root, func(path string, entry fs.DirEntry, err error) error {
+			if err != nil {
+				return err
+			}
+			// Named files are formatted whatever their extension
+			if entry.IsDir() || (path != root && filepath.Ext(path) != ".sql") {
+				return nil
+			}
+			if err := formatFile(path, opts, *list); err != nil {
+				reportFmtError(path, err)
+				status = 1
+			}
+			return nil
+		})
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "%v\n", err)
+			status = 1
+		}
+	}
+	return status
+}
+
+// formatFile formats a SQL file, rewriting it only when its formatting
+// changes. With list set the file is named instead of rewritten. Files
+// with CRLF line endings keep them.
+func formatFile(path string, opts formatter.Options, list bool) error {
+	info, err := os.Stat(path)
+	if err != nil {
+		return err
+	}
+	source, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+
+	formatted, err := formatter.FormatScript(string(source), opts)
+	if err != nil {
+		return err
+	}
+	output := []byte(formatted)
+	if bytes.Contains(source, []byte("\r\n")) {
+		output = bytes.ReplaceAll(output, []byte("\n"), []byte("\r\n"))
+	}
+	if bytes.Equal(source, output) {
+		return nil
+	}
+
+	if list {
+		fmt.Println(path)
+		return nil
+	}
+	return os.WriteFile(path, output, info.Mode().Perm())
+}
+
+// reportFmtError prints why a file could not be formatted, giving each
+// syntax error its position in the file
+func reportFmtError(path string, err error) {
+	errs, ok := err.(parser.ParseErrors)
+	if !ok {
+		fmt.Fprintf(os.Stderr, "%s: %v\n", path, err)
+		return
+	}
+	for _, e := range errs {
+		fmt.Fprintf(os.Stderr, "%s:%d:%d: %s\n", path, e.Line, e.Column, e.Message)
+	}
+}
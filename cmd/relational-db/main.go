@@ -9,12 +9,20 @@ import (
 
 	"relational-db/internal/config"
 	"relational-db/internal/storage"
+	"relational-db/pkg/database"
 )
 
 // Global storage engine (in real implementation, use dependency injection)
 var globalStorage *storage.Engine
 
+// Global database, whose tables are loaded from the system catalog at startup
+var globalDatabase *database.DatabaseImpl
+
 func main() {
+	if len(os.Args) > 1 && os.Args[1] == "fmt" {
+		os.Exit(runFmt(os.Args[2:]))
+	}
+
 	fmt.Println("Relational Database - Starting...")
 
 	// Load configuration
@@ -42,6 +50,9 @@ func main() {
 	fmt.Println("\nShutting down database server...")
 
 	// Implement graceful shutdown
+	if globalDatabase != nil {
+		globalDatabase.Close()
+	}
 	if globalStorage != nil {
 		if err := globalStorage.Close(); err != nil {
 			fmt.Printf("Error closing storage engine: %v\n", err)
@@ -107,6 +118,14 @@ func initializeDatabase(cfg *config.Config) error {
 		return fmt.Errorf("failed to initialize storage engine: %w", err)
 	}
 
+	// Open the database before anything else allocates pages: a new
+	// database must hand the first page to its system catalog
+	db, err := database.NewDatabase(cfg, storageEngine)
+	if err != nil {
+		return err
+	}
+	globalDatabase = db
+
 	// Test the storage engine
 	if err := testStorageEngine(storageEngine); err != nil {
 		return fmt.Errorf("storage engine test failed: %w", err)
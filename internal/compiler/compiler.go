@@ -24,6 +24,7 @@ const (
 	QueryTypeInsert
 	QueryTypeUpdate
 	QueryTypeDelete
+	QueryTypeMerge
 
 	// DDL (Data Definition Language)
 	QueryTypeCreateTable
@@ -31,6 +32,9 @@ const (
 	QueryTypeCreateIndex
 	QueryTypeDropIndex
 	QueryTypeAlterTable
+	QueryTypeCreateView
+	QueryTypeDropView
+	QueryTypeRefreshMaterializedView
 
 	// TCL (Transaction Control Language)
 	QueryTypeBegin
@@ -50,6 +54,8 @@ func (qt QueryType) String() string {
 		return "UPDATE"
 	case QueryTypeDelete:
 		return "DELETE"
+	case QueryTypeMerge:
+		return "MERGE"
 	case QueryTypeCreateTable:
 		return "CREATE_TABLE"
 	case QueryTypeDropTable:
@@ -60,6 +66,12 @@ func (qt QueryType) String() string {
 		return "DROP_INDEX"
 	case QueryTypeAlterTable:
 		return "ALTER_TABLE"
+	case QueryTypeCreateView:
+		return "CREATE_VIEW"
+	case QueryTypeDropView:
+		return "DROP_VIEW"
+	case QueryTypeRefreshMaterializedView:
+		return "REFRESH_MATERIALIZED_VIEW"
 	case QueryTypeBegin:
 		return "BEGIN"
 	case QueryTypeCommit:
@@ -75,12 +87,12 @@ func (qt QueryType) String() string {
 
 // IsDML returns true if this is a DML query
 func (qt QueryType) IsDML() bool {
-	return qt >= QueryTypeSelect && qt <= QueryTypeDelete
+	return qt >= QueryTypeSelect && qt <= QueryTypeMerge
 }
 
 // IsDDL returns true if this is a DDL query
 func (qt QueryType) IsDDL() bool {
-	return qt >= QueryTypeCreateTable && qt <= QueryTypeAlterTable
+	return qt >= QueryTypeCreateTable && qt <= QueryTypeRefreshMaterializedView
 }
 
 // IsTCL returns true if this is a TCL query
@@ -102,6 +114,10 @@ type CompiledQuery struct {
 	// Type information for all expressions
 	TypeInfo *TypeInformation
 
+	// Number of values that must be bound to the statement's ? and $n
+	// parameters to execute it
+	ParameterCount int
+
 	// Validation status
 	Validated bool
 	Errors    []CompilationError
@@ -149,45 +165,37 @@ func (qc *QueryCompiler) Compile(ast parser.Statement) (*CompiledQuery, error) {
 
 	// Step 1: Identify query type
 	compiled.QueryType = qc.identifyQueryType(ast)
+	compiled.ParameterCount = parameterCount(ast)
 
 	// Step 2: Resolve names (tables, columns, aliases)
 	if err := qc.resolveNames(ast, compiled.ResolvedRefs); err != nil {
-		compiled.AddError(CompilationError{
-			Code:     ErrNameResolution,
-			Category: ErrorCategoryNameResolution,
-			Message:  err.Error(),
-		})
+		compiled.AddError(compilationErrorFor(ErrNameResolution, ErrorCategoryNameResolution, err))
 		return compiled, err
 	}
 
 	// Step 3: Check and infer types
 	if err := qc.checkTypes(ast, compiled.ResolvedRefs, compiled.TypeInfo); err != nil {
-		compiled.AddError(CompilationError{
-			Code:     ErrTypeChecking,
-			Category: ErrorCategoryTypeChecking,
-			Message:  err.Error(),
-		})
+		compiled.AddError(compilationErrorFor(ErrTypeChecking, ErrorCategoryTypeChecking, err))
 		return compiled, err
 	}
 
 	// Step 4: Validate constraints
 	if err := qc.validateConstraints(ast, compiled.ResolvedRefs); err != nil {
-		compiled.AddError(CompilationError{
-			Code:     ErrConstraintValidation,
-			Category: ErrorCategoryConstraintValidation,
-			Message:  err.Error(),
-		})
+		compiled.AddError(compilationErrorFor(ErrConstraintValidation, ErrorCategoryConstraintValidation, err))
 		return compiled, err
 	}
 
-	// Step 5: Validate aggregates (if SELECT)
-	if selectStmt, ok := ast.(*parser.SelectStatement); ok {
-		if err := qc.validateAggregates(selectStmt, compiled.ResolvedRefs); err != nil {
-			compiled.AddError(CompilationError{
-				Code:     ErrInvalidAggregate,
-				Category: ErrorCategorySemanticAnalysis,
-				Message:  err.Error(),
-			})
+	// Step 5: Validate aggregates (if SELECT, or the query of a view)
+	var query *parser.SelectStatement
+	switch stmt := ast.(type) {
+	case *parser.SelectStatement:
+		query = stmt
+	case *parser.CreateViewStatement:
+		query = stmt.Query
+	}
+	if query != nil {
+		if err := qc.validateAggregates(query, compiled.ResolvedRefs); err != nil {
+			compiled.AddError(compilationErrorFor(ErrInvalidAggregate, ErrorCategorySemanticAnalysis, err))
 			return compiled, err
 		}
 	}
@@ -197,6 +205,20 @@ func (qc *QueryCompiler) Compile(ast parser.Statement) (*CompiledQuery, error) {
 	return compiled, nil
 }
 
+// parameterCount returns the number of values a statement's parameters
+// take: the highest index of a ? or $n placeholder, since $n parameters may
+// repeat and appear in any order
+func parameterCount(ast parser.Statement) int {
+	count := 0
+	parser.Inspect(ast, func(node parser.Node) bool {
+		if param, ok := node.(*parser.Parameter); ok && param.Index > count {
+			count = param.Index
+		}
+		return true
+	})
+	return count
+}
+
 // identifyQueryType determines the type of SQL statement
 func (qc *QueryCompiler) identifyQueryType(ast parser.Statement) QueryType {
 	switch ast.(type) {
@@ -208,10 +230,32 @@ func (qc *QueryCompiler) identifyQueryType(ast parser.Statement) QueryType {
 		return QueryTypeUpdate
 	case *parser.DeleteStatement:
 		return QueryTypeDelete
+	case *parser.MergeStatement:
+		return QueryTypeMerge
 	case *parser.CreateTableStatement:
 		return QueryTypeCreateTable
 	case *parser.DropTableStatement:
 		return QueryTypeDropTable
+	case *parser.AlterTableStatement:
+		return QueryTypeAlterTable
+	case *parser.CreateIndexStatement:
+		return QueryTypeCreateIndex
+	case *parser.DropIndexStatement:
+		return QueryTypeDropIndex
+	case *parser.CreateViewStatement:
+		return QueryTypeCreateView
+	case *parser.DropViewStatement:
+		return QueryTypeDropView
+	case *parser.RefreshMaterializedViewStatement:
+		return QueryTypeRefreshMaterializedView
+	case *parser.BeginStatement:
+		return QueryTypeBegin
+	case *parser.CommitStatement:
+		return QueryTypeCommit
+	case *parser.RollbackStatement:
+		return QueryTypeRollback
+	case *parser.SavepointStatement, *parser.ReleaseSavepointStatement:
+		return QueryTypeSavepoint
 	default:
 		return QueryTypeUnknown
 	}
@@ -230,10 +274,27 @@ func (qc *QueryCompiler) resolveNames(ast parser.Statement, refs *ResolvedRefere
 		return resolver.ResolveUpdate(stmt)
 	case *parser.DeleteStatement:
 		return resolver.ResolveDelete(stmt)
+	case *parser.MergeStatement:
+		return resolver.ResolveMerge(stmt)
 	case *parser.CreateTableStatement:
 		return resolver.ResolveCreateTable(stmt)
 	case *parser.DropTableStatement:
 		return resolver.ResolveDropTable(stmt)
+	case *parser.AlterTableStatement:
+		return resolver.ResolveAlterTable(stmt)
+	case *parser.CreateIndexStatement:
+		return resolver.ResolveCreateIndex(stmt)
+	case *parser.DropIndexStatement:
+		return resolver.ResolveDropIndex(stmt)
+	case *parser.CreateViewStatement:
+		return resolver.ResolveCreateView(stmt)
+	case *parser.DropViewStatement:
+		return resolver.ResolveDropView(stmt)
+	case *parser.RefreshMaterializedViewStatement:
+		return resolver.ResolveRefreshMaterializedView(stmt)
+	case *parser.BeginStatement, *parser.CommitStatement, *parser.RollbackStatement,
+		*parser.SavepointStatement, *parser.ReleaseSavepointStatement:
+		return nil // Transaction control references no schema objects
 	default:
 		return fmt.Errorf("unsupported statement type for name resolution")
 	}
@@ -252,8 +313,14 @@ func (qc *QueryCompiler) checkTypes(ast parser.Statement, refs *ResolvedReferenc
 		return checker.CheckUpdate(stmt)
 	case *parser.DeleteStatement:
 		return checker.CheckDelete(stmt)
+	case *parser.MergeStatement:
+		return checker.CheckMerge(stmt)
 	case *parser.CreateTableStatement:
 		return checker.CheckCreateTable(stmt)
+	case *parser.AlterTableStatement:
+		return checker.CheckAlterTable(stmt)
+	case *parser.CreateViewStatement:
+		return checker.CheckCreateView(stmt)
 	default:
 		return nil // No type checking needed for other statements
 	}
@@ -266,10 +333,16 @@ func (qc *QueryCompiler) validateConstraints(ast parser.Statement, refs *Resolve
 	switch stmt := ast.(type) {
 	case *parser.CreateTableStatement:
 		return validator.ValidateCreateTable(stmt)
+	case *parser.AlterTableStatement:
+		return validator.ValidateAlterTable(stmt)
+	case *parser.CreateIndexStatement:
+		return validator.ValidateCreateIndex(stmt)
 	case *parser.InsertStatement:
 		return validator.ValidateInsert(stmt)
 	case *parser.UpdateStatement:
 		return validator.ValidateUpdate(stmt)
+	case *parser.MergeStatement:
+		return validator.ValidateMerge(stmt)
 	default:
 		return nil // No constraint validation needed
 	}
@@ -290,7 +363,7 @@ func (qc *QueryCompiler) CompileSQL(sql string) (*CompiledQuery, error) {
 
 	// Check for parse errors
 	if len(p.Errors()) > 0 {
-		return nil, fmt.Errorf("parse error: %v", p.Errors()[0])
+		return nil, p.ParseErrors()[0]
 	}
 
 	if ast == nil {
@@ -314,6 +387,12 @@ type CatalogManager interface {
 
 	// ListTables returns all table names
 	ListTables() ([]string, error)
+
+	// GetView retrieves view metadata by name
+	GetView(name string) (*ViewMetadata, error)
+
+	// ListViews returns all view names
+	ListViews() ([]string, error)
 }
 
 // ResolvedReferences stores all name resolution results
@@ -326,6 +405,39 @@ type ResolvedReferences struct {
 
 	// Alias mappings: alias → real table name
 	Aliases map[string]string
+
+	// Common table expressions: the table each one defines, and the table
+	// names in FROM that refer to one
+	CTEs    map[*parser.CommonTableExpression]*TableMetadata
+	CTERefs map[*parser.Identifier]*CTEReference
+
+	// Result columns of each query, including set operations and the
+	// queries they combine
+	QueryResults map[*parser.SelectStatement]*TableMetadata
+
+	// Views expanded in FROM, by name
+	Views map[string]*ViewMetadata
+
+	// Catalog tables and views the statement reads directly, not through
+	// a view it expands
+	Dependencies []string
+
+	// Columns of the view a CREATE VIEW statement defines
+	ViewColumns *TableMetadata
+
+	// Columns of the rows the RETURNING clause of an INSERT, UPDATE or
+	// DELETE produces
+	Returning *TableMetadata
+}
+
+// CTEReference records that a table name in FROM refers to a common table
+// expression
+type CTEReference struct {
+	CTE *parser.CommonTableExpression
+
+	// Set for the reference in a recursive CTE's own recursive term, which
+	// reads the rows produced by the previous iteration
+	SelfReference bool
 }
 
 // NewResolvedReferences creates a new ResolvedReferences
@@ -334,6 +446,11 @@ func NewResolvedReferences() *ResolvedReferences {
 		Tables:  make(map[string]*TableMetadata),
 		Columns: make(map[string]*ColumnMetadata),
 		Aliases: make(map[string]string),
+		CTEs:    make(map[*parser.CommonTableExpression]*TableMetadata),
+		CTERefs: make(map[*parser.Identifier]*CTEReference),
+
+		QueryResults: make(map[*parser.SelectStatement]*TableMetadata),
+		Views:        make(map[string]*ViewMetadata),
 	}
 }
 
@@ -381,6 +498,9 @@ type TypeInformation struct {
 
 	// Expression ID → null possibility
 	Nullability map[string]bool
+
+	// Bind parameter index (1-based) → type inferred from its context
+	ParameterTypes map[int]DataType
 }
 
 // NewTypeInformation creates a new TypeInformation
@@ -389,6 +509,7 @@ func NewTypeInformation() *TypeInformation {
 		ExpressionTypes: make(map[string]DataType),
 		Coercions:       make(map[string]TypeCoercion),
 		Nullability:     make(map[string]bool),
+		ParameterTypes:  make(map[int]DataType),
 	}
 }
 
@@ -412,6 +533,17 @@ func (ti *TypeInformation) AddCoercion(exprID string, from, to DataType, reason
 	}
 }
 
+// SetParameterType records the inferred type of a bind parameter
+func (ti *TypeInformation) SetParameterType(index int, dataType DataType) {
+	ti.ParameterTypes[index] = dataType
+}
+
+// GetParameterType retrieves the inferred type of a bind parameter
+func (ti *TypeInformation) GetParameterType(index int) (DataType, bool) {
+	dt, found := ti.ParameterTypes[index]
+	return dt, found
+}
+
 // TypeCoercion represents a type conversion
 type TypeCoercion struct {
 	FromType DataType
@@ -470,6 +602,33 @@ func (dt DataType) String() string {
 	}
 }
 
+// DataTypeFromName returns the data type a SQL type name such as VARCHAR or
+// BIGINT denotes, and false for names it does not know
+func DataTypeFromName(name string) (DataType, bool) {
+	switch strings.ToUpper(name) {
+	case "INTEGER", "INT", "BIGINT", "SMALLINT":
+		return DataTypeInteger, true
+	case "REAL", "FLOAT", "DOUBLE":
+		return DataTypeReal, true
+	case "NUMERIC", "DECIMAL":
+		return DataTypeNumeric, true
+	case "TEXT", "VARCHAR", "CHAR":
+		return DataTypeText, true
+	case "BLOB":
+		return DataTypeBlob, true
+	case "BOOLEAN", "BOOL":
+		return DataTypeBoolean, true
+	case "DATE":
+		return DataTypeDate, true
+	case "TIME":
+		return DataTypeTime, true
+	case "TIMESTAMP", "DATETIME":
+		return DataTypeTimestamp, true
+	default:
+		return DataTypeUnknown, false
+	}
+}
+
 // IsNumeric returns true if this is a numeric type
 func (dt DataType) IsNumeric() bool {
 	return dt == DataTypeInteger || dt == DataTypeReal || dt == DataTypeNumeric
@@ -522,6 +681,28 @@ func (dt DataType) CanCoerceTo(other DataType) bool {
 		return true
 	}
 
+	// Numeric can coerce to Real, which may round it
+	if dt == DataTypeNumeric && other == DataTypeReal {
+		return true
+	}
+
+	return false
+}
+
+// CanCastTo returns true if an explicit CAST converts this type to another:
+// any implicit coercion, conversions between numeric types, between
+// integers and booleans, and to or from text
+func (dt DataType) CanCastTo(other DataType) bool {
+	switch {
+	case dt == DataTypeUnknown || dt.CanCoerceTo(other):
+		return true
+	case dt.IsNumeric() && other.IsNumeric():
+		return true
+	case (dt == DataTypeInteger && other == DataTypeBoolean) || (dt == DataTypeBoolean && other == DataTypeInteger):
+		return true
+	case dt == DataTypeText || other == DataTypeText:
+		return true
+	}
 	return false
 }
 
@@ -535,8 +716,56 @@ type TableMetadata struct {
 	TotalSize int64
 	TableID   uint64
 	CreatedAt time.Time
+
+	// PrimaryKey lists the primary key columns in key order
+	PrimaryKey []string
+
+	// Clustered is true when rows are stored in the leaves of a
+	// primary-key B+tree, so scans return rows in primary key order
+	Clustered bool
+
+	// TTLColumn and TTL describe the row expiry policy: rows whose
+	// TTLColumn value is older than TTL are deleted in the background
+	TTLColumn string
+	TTL       time.Duration
+
+	// Partitioning is set on partitioned tables and describes how rows
+	// are spread across the child partitions
+	Partitioning *PartitionScheme
+
+	// Constraints lists the names of the table's named constraints
+	Constraints []string
+
+	// Indexes lists the names of the table's secondary indexes
+	Indexes []string
+
+	// UniqueKeys lists the columns of each unique secondary index
+	UniqueKeys [][]string
 }
 
+// ViewMetadata describes a view. A view's query is expanded in place of
+// every reference to it; a materialized view is also a table holding the
+// rows its query returned when it was last refreshed.
+type ViewMetadata struct {
+	Name         string
+	Query        string   // SQL of the defining query
+	Columns      []string // renames the query's columns, empty to keep them
+	Materialized bool
+
+	// DependsOn lists the tables and views the query reads directly
+	DependsOn []string
+}
+
+// Table options that control row expiry
+const (
+	TTLColumnOption = "ttl_column"
+	TTLOption       = "ttl"
+)
+
+// ExcludedTable is the name ON CONFLICT DO UPDATE gives the row an INSERT
+// could not store
+const ExcludedTable = "excluded"
+
 // NewTableMetadata creates a new TableMetadata
 func NewTableMetadata(name string) *TableMetadata {
 	return &TableMetadata{
@@ -556,7 +785,7 @@ func (tm *TableMetadata) AddColumn(col *ColumnMetadata) {
 
 // GetColumn retrieves a column by name
 func (tm *TableMetadata) GetColumn(name string) (*ColumnMetadata, error) {
-	col, found := tm.ColumnMap[strings.ToLower(name)]
+	col, found := tm.ColumnMap[name]
 	if !found {
 		return nil, fmt.Errorf("column %s not found in table %s", name, tm.Name)
 	}
@@ -565,10 +794,64 @@ func (tm *TableMetadata) GetColumn(name string) (*ColumnMetadata, error) {
 
 // HasColumn checks if a column exists
 func (tm *TableMetadata) HasColumn(name string) bool {
-	_, found := tm.ColumnMap[strings.ToLower(name)]
+	_, found := tm.ColumnMap[name]
 	return found
 }
 
+// IsUniqueKey reports whether columns, in any order, are the primary key,
+// the columns of a unique index or a single UNIQUE column, so that no two
+// rows can agree on all of them
+func (tm *TableMetadata) IsUniqueKey(columns []string) bool {
+	if len(tm.PrimaryKey) > 0 && SameColumnSet(tm.PrimaryKey, columns) {
+		return true
+	}
+	for _, key := range tm.UniqueKeys {
+		if SameColumnSet(key, columns) {
+			return true
+		}
+	}
+	if len(columns) == 1 {
+		if col, err := tm.GetColumn(columns[0]); err == nil {
+			return col.IsUnique || (col.IsPrimaryKey && len(tm.PrimaryKey) == 0)
+		}
+	}
+	return false
+}
+
+// SameColumnSet reports whether two column lists name the same columns,
+// ignoring order
+func SameColumnSet(a, b []string) bool {
+	contains := func(list []string, name string) bool {
+		for _, col := range list {
+			if col == name {
+				return true
+			}
+		}
+		return false
+	}
+
+	for _, col := range a {
+		if !contains(b, col) {
+			return false
+		}
+	}
+	for _, col := range b {
+		if !contains(a, col) {
+			return false
+		}
+	}
+	return true
+}
+
+// ClusterKey returns the columns rows are physically ordered by, or nil
+// if the table is not clustered
+func (tm *TableMetadata) ClusterKey() []string {
+	if !tm.Clustered {
+		return nil
+	}
+	return tm.PrimaryKey
+}
+
 // ColumnMetadata contains column schema information
 type ColumnMetadata struct {
 	Name         string
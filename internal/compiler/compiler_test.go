@@ -2,6 +2,9 @@ package compiler
 
 import (
 	"testing"
+
+	"relational-db/internal/lexer"
+	"relational-db/internal/parser"
 )
 
 func TestNewQueryCompiler(t *testing.T) {
@@ -350,3 +353,998 @@ func TestTypeCompatibility(t *testing.T) {
 		})
 	}
 }
+
+func TestPartitionScheme(t *testing.T) {
+	l := lexer.NewLexer(`CREATE TABLE metrics (id INTEGER, ts INTEGER) PARTITION BY RANGE (ts) (
+		PARTITION p0 VALUES LESS THAN (100),
+		PARTITION p1 VALUES LESS THAN (200),
+		PARTITION p2 VALUES LESS THAN MAXVALUE)`)
+	p := parser.NewParser(l)
+	stmt, ok := p.ParseStatement().(*parser.CreateTableStatement)
+	if !ok || stmt.PartitionBy == nil {
+		t.Fatalf("Expected partitioned CREATE TABLE, errors: %v", p.Errors())
+	}
+
+	scheme, err := PartitionSchemeFromSpec("metrics", stmt.PartitionBy)
+	if err != nil {
+		t.Fatalf("PartitionSchemeFromSpec failed: %v", err)
+	}
+
+	routes := map[int64]string{-5: "p0", 99: "p0", 100: "p1", 199: "p1", 1000: "p2"}
+	for value, want := range routes {
+		if got, err := scheme.Route(value); err != nil || got != want {
+			t.Errorf("Route(%d) = %s, %v; expected %s", value, got, err, want)
+		}
+	}
+
+	// ts >= 150 AND ts < 200 only touches p1
+	if got := scheme.Prune(int64(150), int64(200), true, false); len(got) != 1 || got[0] != "p1" {
+		t.Errorf("Expected [p1], got %v", got)
+	}
+	// ts < 100 only touches p0
+	if got := scheme.Prune(nil, int64(100), false, false); len(got) != 1 || got[0] != "p0" {
+		t.Errorf("Expected [p0], got %v", got)
+	}
+
+	list := &PartitionScheme{
+		Method: parser.PartitionByList,
+		Column: "region",
+		Partitions: []*PartitionBound{
+			{Name: "eu", Values: []interface{}{"de", "fr"}},
+			{Name: "us", Values: []interface{}{"us"}},
+		},
+	}
+	if got, _ := list.Route("fr"); got != "eu" {
+		t.Errorf("Expected fr in eu, got %s", got)
+	}
+	if _, err := list.Route("jp"); err == nil {
+		t.Error("Expected error routing a value no LIST partition accepts")
+	}
+
+	hash := &PartitionScheme{Method: parser.PartitionByHash, Column: "id", Partitions: []*PartitionBound{{Name: "h0"}, {Name: "h1"}, {Name: "h2"}}}
+	first, _ := hash.Route(int64(42))
+	if again, _ := hash.Route(42.0); again != first {
+		t.Errorf("Expected equal numbers to hash alike, got %s and %s", first, again)
+	}
+	if got := hash.Prune(int64(42), int64(42), true, true); len(got) != 1 || got[0] != first {
+		t.Errorf("Expected equality to prune to %s, got %v", first, got)
+	}
+
+	// Range bounds must increase
+	l = lexer.NewLexer(`CREATE TABLE bad (ts INTEGER) PARTITION BY RANGE (ts) (
+		PARTITION a VALUES LESS THAN (200), PARTITION b VALUES LESS THAN (100))`)
+	p = parser.NewParser(l)
+	bad := p.ParseStatement().(*parser.CreateTableStatement)
+	if _, err := PartitionSchemeFromSpec("bad", bad.PartitionBy); err == nil {
+		t.Error("Expected error for decreasing range bounds")
+	}
+}
+
+func TestParameterTypeInference(t *testing.T) {
+	catalog := NewMockCatalog()
+	users := NewTableMetadata("users")
+	users.AddColumn(&ColumnMetadata{Name: "id", TableName: "users", DataType: DataTypeInteger})
+	users.AddColumn(&ColumnMetadata{Name: "name", TableName: "users", DataType: DataTypeText})
+	users.AddColumn(&ColumnMetadata{Name: "score", TableName: "users", DataType: DataTypeReal})
+	catalog.AddTable(users)
+	qc := NewQueryCompiler(catalog)
+
+	tests := []struct {
+		sql   string
+		types map[int]DataType
+	}{
+		{"SELECT id FROM users WHERE id = ? AND name = ?", map[int]DataType{1: DataTypeInteger, 2: DataTypeText}},
+		{"INSERT INTO users (name, score) VALUES ($1, $2)", map[int]DataType{1: DataTypeText, 2: DataTypeReal}},
+		{"UPDATE users SET score = ? WHERE id = ?", map[int]DataType{1: DataTypeReal, 2: DataTypeInteger}},
+	}
+
+	for _, tt := range tests {
+		stmt, err := parser.ParseSQL(tt.sql)
+		if err != nil {
+			t.Fatalf("%s: parse failed: %v", tt.sql, err)
+		}
+		compiled, err := qc.Compile(stmt)
+		if err != nil {
+			t.Fatalf("%s: compile failed: %v", tt.sql, err)
+		}
+		for index, want := range tt.types {
+			if got, _ := compiled.TypeInfo.GetParameterType(index); got != want {
+				t.Errorf("%s: parameter %d: expected %s, got %s", tt.sql, index, want, got)
+			}
+		}
+		if compiled.ParameterCount != len(tt.types) {
+			t.Errorf("%s: expected %d parameters, got %d", tt.sql, len(tt.types), compiled.ParameterCount)
+		}
+	}
+
+	// Numbered parameters may repeat and appear out of order, also in
+	// subqueries
+	stmt, err := parser.ParseSQL("SELECT id FROM users WHERE id = $2 OR id IN (SELECT id FROM users WHERE name = $1) OR score = $2")
+	if err != nil {
+		t.Fatalf("parse failed: %v", err)
+	}
+	if compiled, err := qc.Compile(stmt); err != nil || compiled.ParameterCount != 2 {
+		t.Errorf("Expected 2 parameters, got %v (%v)", compiled.ParameterCount, err)
+	}
+
+	// One parameter cannot be both INTEGER and TEXT
+	stmt, _ = parser.ParseSQL("SELECT id FROM users WHERE id = $1 AND name = $1")
+	if _, err := qc.Compile(stmt); err == nil {
+		t.Error("Expected conflicting parameter types to fail")
+	}
+}
+
+func TestSubqueryNameResolution(t *testing.T) {
+	catalog := NewMockCatalog()
+	users := NewTableMetadata("users")
+	users.AddColumn(&ColumnMetadata{Name: "id", TableName: "users", DataType: DataTypeInteger})
+	users.AddColumn(&ColumnMetadata{Name: "name", TableName: "users", DataType: DataTypeText})
+	catalog.AddTable(users)
+	orders := NewTableMetadata("orders")
+	orders.AddColumn(&ColumnMetadata{Name: "id", TableName: "orders", DataType: DataTypeInteger})
+	orders.AddColumn(&ColumnMetadata{Name: "user_id", TableName: "orders", DataType: DataTypeInteger})
+	orders.AddColumn(&ColumnMetadata{Name: "total", TableName: "orders", DataType: DataTypeReal})
+	catalog.AddTable(orders)
+	qc := NewQueryCompiler(catalog)
+
+	valid := []string{
+		// Correlated reference to the outer query
+		"SELECT name FROM users WHERE EXISTS (SELECT id FROM orders WHERE orders.user_id = users.id)",
+		// Derived table columns are visible through its alias
+		"SELECT big.total FROM (SELECT user_id, total FROM orders WHERE total > 100) AS big",
+		"SELECT name FROM users WHERE id IN (SELECT user_id FROM orders)",
+	}
+	for _, sql := range valid {
+		stmt, err := parser.ParseSQL(sql)
+		if err != nil {
+			t.Fatalf("%s: parse failed: %v", sql, err)
+		}
+		if _, err := qc.Compile(stmt); err != nil {
+			t.Errorf("%s: compile failed: %v", sql, err)
+		}
+	}
+
+	// The derived table's column has the type of its source column
+	stmt, _ := parser.ParseSQL("SELECT t.total FROM (SELECT total FROM orders) AS t")
+	compiled, err := qc.Compile(stmt)
+	if err != nil {
+		t.Fatalf("compile failed: %v", err)
+	}
+	if table := compiled.ResolvedRefs.Tables["t"]; table == nil || table.Columns[0].DataType != DataTypeReal {
+		t.Errorf("Expected derived column t.total of type REAL, got %+v", table)
+	}
+
+	// A derived table cannot see sibling FROM items
+	stmt, _ = parser.ParseSQL("SELECT name FROM users JOIN (SELECT total FROM orders WHERE orders.user_id = users.id) AS t ON users.id = users.id")
+	if _, err := qc.Compile(stmt); err == nil {
+		t.Error("Expected derived table referencing a sibling table to fail")
+	}
+}
+
+func TestCTENameResolution(t *testing.T) {
+	catalog := NewMockCatalog()
+	categories := NewTableMetadata("categories")
+	categories.AddColumn(&ColumnMetadata{Name: "id", TableName: "categories", DataType: DataTypeInteger})
+	categories.AddColumn(&ColumnMetadata{Name: "parent_id", TableName: "categories", DataType: DataTypeInteger})
+	categories.AddColumn(&ColumnMetadata{Name: "name", TableName: "categories", DataType: DataTypeText})
+	catalog.AddTable(categories)
+	qc := NewQueryCompiler(catalog)
+
+	stmt, err := parser.ParseSQL(`WITH RECURSIVE tree (node, parent) AS (
+		SELECT id, parent_id FROM categories WHERE parent_id = 0
+		UNION ALL
+		SELECT c.id, c.parent_id FROM categories c JOIN tree t ON c.parent_id = t.node)
+		SELECT node FROM tree`)
+	if err != nil {
+		t.Fatalf("parse failed: %v", err)
+	}
+	compiled, err := qc.Compile(stmt)
+	if err != nil {
+		t.Fatalf("compile failed: %v", err)
+	}
+
+	cte := stmt.(*parser.SelectStatement).With.CTEs[0]
+	table := compiled.ResolvedRefs.CTEs[cte]
+	if table == nil || len(table.Columns) != 2 || table.Columns[0].Name != "node" || table.Columns[0].DataType != DataTypeInteger {
+		t.Fatalf("Expected CTE columns (node INTEGER, parent), got %+v", table)
+	}
+
+	self, outer := 0, 0
+	for _, ref := range compiled.ResolvedRefs.CTERefs {
+		if ref.CTE != cte {
+			continue
+		}
+		if ref.SelfReference {
+			self++
+		} else {
+			outer++
+		}
+	}
+	if self != 1 || outer != 1 {
+		t.Errorf("Expected one self reference and one outer reference, got %d and %d", self, outer)
+	}
+
+	invalid := []string{
+		// Column list longer than the select list
+		"WITH t (a, b) AS (SELECT id FROM categories) SELECT a FROM t",
+		// Self reference without RECURSIVE
+		"WITH t AS (SELECT id FROM t) SELECT id FROM t",
+		// Duplicate name
+		"WITH t AS (SELECT id FROM categories), t AS (SELECT id FROM categories) SELECT id FROM t",
+		// Recursive term with a different number of columns
+		"WITH RECURSIVE t AS (SELECT id FROM categories UNION ALL SELECT id, name FROM t) SELECT id FROM t",
+		// CTE names are not visible outside their query
+		"SELECT id FROM categories WHERE id IN (WITH t AS (SELECT id FROM categories) SELECT id FROM t) AND id IN (SELECT id FROM t)",
+	}
+	for _, sql := range invalid {
+		stmt, err := parser.ParseSQL(sql)
+		if err != nil {
+			t.Fatalf("%s: parse failed: %v", sql, err)
+		}
+		if _, err := qc.Compile(stmt); err == nil {
+			t.Errorf("%s: expected compile error", sql)
+		}
+	}
+
+	// A CTE shadows a catalog table of the same name in the query, while
+	// its own body still reads the table
+	stmt, _ = parser.ParseSQL("WITH categories AS (SELECT name FROM categories) SELECT name FROM categories")
+	if _, err := qc.Compile(stmt); err != nil {
+		t.Errorf("compile failed: %v", err)
+	}
+	stmt, _ = parser.ParseSQL("WITH categories AS (SELECT name FROM categories) SELECT id FROM categories")
+	if _, err := qc.Compile(stmt); err == nil {
+		t.Error("Expected column missing from the shadowing CTE to fail")
+	}
+}
+
+func TestSetOperationCompilation(t *testing.T) {
+	catalog := NewMockCatalog()
+	users := NewTableMetadata("users")
+	users.AddColumn(&ColumnMetadata{Name: "id", TableName: "users", DataType: DataTypeInteger})
+	users.AddColumn(&ColumnMetadata{Name: "name", TableName: "users", DataType: DataTypeText})
+	catalog.AddTable(users)
+	scores := NewTableMetadata("scores")
+	scores.AddColumn(&ColumnMetadata{Name: "user_id", TableName: "scores", DataType: DataTypeInteger})
+	scores.AddColumn(&ColumnMetadata{Name: "score", TableName: "scores", DataType: DataTypeReal})
+	catalog.AddTable(scores)
+	qc := NewQueryCompiler(catalog)
+
+	// Result columns are named after the left query and typed to fit both
+	stmt, err := parser.ParseSQL("SELECT id FROM users UNION SELECT score FROM scores ORDER BY id")
+	if err != nil {
+		t.Fatalf("parse failed: %v", err)
+	}
+	compiled, err := qc.Compile(stmt)
+	if err != nil {
+		t.Fatalf("compile failed: %v", err)
+	}
+	result := compiled.ResolvedRefs.QueryResults[stmt.(*parser.SelectStatement)]
+	if result == nil || len(result.Columns) != 1 || result.Columns[0].Name != "id" || result.Columns[0].DataType != DataTypeReal {
+		t.Fatalf("Expected result column id REAL, got %+v", result)
+	}
+
+	// A derived table over a set operation exposes its result columns
+	stmt, _ = parser.ParseSQL("SELECT d.id FROM (SELECT id FROM users EXCEPT SELECT user_id FROM scores) AS d")
+	if _, err := qc.Compile(stmt); err != nil {
+		t.Errorf("compile failed: %v", err)
+	}
+
+	invalid := []string{
+		// Different numbers of columns
+		"SELECT id, name FROM users UNION SELECT user_id FROM scores",
+		"SELECT id FROM users INTERSECT (SELECT user_id FROM scores UNION SELECT id, name FROM users)",
+		// Types that cannot be matched
+		"SELECT name FROM users UNION ALL SELECT score FROM scores",
+		// ORDER BY must name a result column or position
+		"SELECT id FROM users UNION SELECT user_id FROM scores ORDER BY user_id",
+		"SELECT id FROM users UNION SELECT user_id FROM scores ORDER BY 2",
+		// Each query has its own scope
+		"SELECT id FROM users UNION SELECT name FROM scores",
+	}
+	for _, sql := range invalid {
+		stmt, err := parser.ParseSQL(sql)
+		if err != nil {
+			t.Fatalf("%s: parse failed: %v", sql, err)
+		}
+		if _, err := qc.Compile(stmt); err == nil {
+			t.Errorf("%s: expected compile error", sql)
+		}
+	}
+
+	// A recursive CTE whose second term never reads it is a plain UNION
+	stmt, _ = parser.ParseSQL("WITH RECURSIVE ids AS (SELECT id FROM users UNION SELECT user_id FROM scores) SELECT id FROM ids")
+	if _, err := qc.Compile(stmt); err != nil {
+		t.Fatalf("compile failed: %v", err)
+	}
+	cte := stmt.(*parser.SelectStatement).With.CTEs[0]
+	if cte.RecursiveTerm != nil || cte.Query.SetOperation == nil {
+		t.Errorf("Expected a non-recursive UNION body, got %s", cte)
+	}
+}
+
+func TestConditionalExpressionTypes(t *testing.T) {
+	catalog := NewMockCatalog()
+	users := NewTableMetadata("users")
+	users.AddColumn(&ColumnMetadata{Name: "id", TableName: "users", DataType: DataTypeInteger})
+	users.AddColumn(&ColumnMetadata{Name: "name", TableName: "users", DataType: DataTypeText, Nullable: true})
+	users.AddColumn(&ColumnMetadata{Name: "score", TableName: "users", DataType: DataTypeReal, Nullable: true})
+	users.AddColumn(&ColumnMetadata{Name: "avatar", TableName: "users", DataType: DataTypeBlob, Nullable: true})
+	catalog.AddTable(users)
+	qc := NewQueryCompiler(catalog)
+
+	compile := func(sql string) (*CompiledQuery, error) {
+		stmt, err := parser.ParseSQL(sql)
+		if err != nil {
+			t.Fatalf("%s: parse failed: %v", sql, err)
+		}
+		return qc.Compile(stmt)
+	}
+
+	valid := []string{
+		"SELECT CASE WHEN id > 1 THEN id ELSE score END FROM users",
+		"SELECT CASE id WHEN 1 THEN 'one' WHEN 2 THEN NULL END FROM users",
+		"SELECT COALESCE(score, id, 0) FROM users WHERE name IS NOT NULL",
+		"SELECT NULLIF(name, '') FROM users WHERE CASE WHEN score IS NULL THEN FALSE ELSE TRUE END",
+		"SELECT CAST(name AS INTEGER), CAST(score AS VARCHAR(10)), CAST(avatar AS TEXT) FROM users",
+	}
+	for _, sql := range valid {
+		if _, err := compile(sql); err != nil {
+			t.Errorf("%s: compile failed: %v", sql, err)
+		}
+	}
+
+	// CAST records the conversion it needs and types a parameter operand
+	compiled, err := compile("SELECT CAST(score AS INTEGER) FROM users WHERE id = CAST(? AS INTEGER)")
+	if err != nil {
+		t.Fatalf("compile failed: %v", err)
+	}
+	if coercion, found := compiled.TypeInfo.Coercions["CAST(score AS INTEGER)"]; !found || coercion.ToType != DataTypeInteger {
+		t.Errorf("Expected a REAL to INTEGER coercion for the cast, got %+v", compiled.TypeInfo.Coercions)
+	}
+	if compiled.TypeInfo.ParameterTypes[1] != DataTypeInteger {
+		t.Errorf("Expected parameter 1 to be INTEGER, got %s", compiled.TypeInfo.ParameterTypes[1])
+	}
+
+	invalid := []string{
+		// Branches must share a type
+		"SELECT CASE WHEN id > 1 THEN id ELSE name END FROM users",
+		// WHEN values must be comparable to the operand
+		"SELECT CASE id WHEN 'one' THEN 1 END FROM users",
+		// Searched WHEN conditions must be boolean
+		"SELECT CASE WHEN id THEN 1 END FROM users",
+		"SELECT COALESCE(name, id) FROM users",
+		"SELECT COALESCE() FROM users",
+		"SELECT NULLIF(name) FROM users",
+		"SELECT NULLIF(name, id) FROM users",
+		"SELECT CAST(avatar AS INTEGER) FROM users",
+		"SELECT CAST(id AS GEOMETRY) FROM users",
+		"SELECT CASE WHEN missing IS NULL THEN 1 END FROM users",
+	}
+	for _, sql := range invalid {
+		if _, err := compile(sql); err == nil {
+			t.Errorf("%s: expected compile error", sql)
+		}
+	}
+}
+
+func TestPredicateTypes(t *testing.T) {
+	catalog := NewMockCatalog()
+	users := NewTableMetadata("users")
+	users.AddColumn(&ColumnMetadata{Name: "id", TableName: "users", DataType: DataTypeInteger})
+	users.AddColumn(&ColumnMetadata{Name: "name", TableName: "users", DataType: DataTypeText})
+	users.AddColumn(&ColumnMetadata{Name: "score", TableName: "users", DataType: DataTypeReal})
+	catalog.AddTable(users)
+	qc := NewQueryCompiler(catalog)
+
+	compile := func(sql string) (*CompiledQuery, error) {
+		stmt, err := parser.ParseSQL(sql)
+		if err != nil {
+			t.Fatalf("%s: parse failed: %v", sql, err)
+		}
+		return qc.Compile(stmt)
+	}
+
+	valid := []string{
+		"SELECT id FROM users WHERE score BETWEEN 1 AND id * 2",
+		"SELECT id FROM users WHERE id NOT IN (1, 2.5, NULL)",
+		"SELECT id FROM users WHERE name LIKE 'a%' AND name NOT LIKE '%!_%' ESCAPE '!'",
+	}
+	for _, sql := range valid {
+		if _, err := compile(sql); err != nil {
+			t.Errorf("%s: compile failed: %v", sql, err)
+		}
+	}
+
+	// Parameters take their type from the other side of the comparison
+	compiled, err := compile("SELECT id FROM users WHERE id BETWEEN ? AND ? AND name IN (?) AND ? IN (score, 1) AND name LIKE ?")
+	if err != nil {
+		t.Fatalf("compile failed: %v", err)
+	}
+	expected := map[int]DataType{1: DataTypeInteger, 2: DataTypeInteger, 3: DataTypeText, 4: DataTypeReal, 5: DataTypeText}
+	for index, dataType := range expected {
+		if got := compiled.TypeInfo.ParameterTypes[index]; got != dataType {
+			t.Errorf("Expected parameter %d to be %s, got %s", index, dataType, got)
+		}
+	}
+
+	invalid := []string{
+		"SELECT id FROM users WHERE id BETWEEN 'a' AND 5",
+		"SELECT id FROM users WHERE name NOT BETWEEN 1 AND 5",
+		"SELECT id FROM users WHERE id IN (1, 'two')",
+		"SELECT id FROM users WHERE id LIKE '1%'",
+		"SELECT id FROM users WHERE name LIKE 'a' ESCAPE '!!'",
+		"SELECT id FROM users WHERE missing IN (1)",
+	}
+	for _, sql := range invalid {
+		if _, err := compile(sql); err == nil {
+			t.Errorf("%s: expected compile error", sql)
+		}
+	}
+}
+
+func TestAlterTableValidation(t *testing.T) {
+	catalog := NewMockCatalog()
+	users := NewTableMetadata("users")
+	users.AddColumn(&ColumnMetadata{Name: "id", TableName: "users", DataType: DataTypeInteger, IsPrimaryKey: true})
+	users.AddColumn(&ColumnMetadata{Name: "name", TableName: "users", DataType: DataTypeText, Nullable: true})
+	users.PrimaryKey = []string{"id"}
+	users.Constraints = []string{"users_name_key"}
+	catalog.AddTable(users)
+	orders := NewTableMetadata("orders")
+	orders.AddColumn(&ColumnMetadata{Name: "id", TableName: "orders", DataType: DataTypeInteger})
+	catalog.AddTable(orders)
+	events := NewTableMetadata("events")
+	events.AddColumn(&ColumnMetadata{Name: "id", TableName: "events", DataType: DataTypeInteger, IsPrimaryKey: true})
+	events.AddColumn(&ColumnMetadata{Name: "created_at", TableName: "events", DataType: DataTypeTimestamp})
+	events.PrimaryKey = []string{"id"}
+	events.Clustered = true
+	catalog.AddTable(events)
+	qc := NewQueryCompiler(catalog)
+
+	compile := func(sql string) error {
+		stmt, err := parser.ParseSQL(sql)
+		if err != nil {
+			t.Fatalf("%s: parse failed: %v", sql, err)
+		}
+		_, err = qc.Compile(stmt)
+		return err
+	}
+
+	valid := []string{
+		"ALTER TABLE events SET (ttl_column = created_at, ttl = '7 days')",
+		"CREATE TABLE sessions (id INTEGER PRIMARY KEY, seen TIMESTAMP) WITH (clustered = true, ttl_column = seen, ttl = '1 hour')",
+		"CREATE TABLE sessions (id INTEGER PRIMARY KEY, seen TIMESTAMP) WITH (ttl_column = seen, ttl = '1 hour')",
+		"CREATE TABLE sessions (id INTEGER, seen TIMESTAMP) WITH (ttl_column = seen, ttl = '1 hour')",
+		"ALTER TABLE users ADD COLUMN email VARCHAR(100) DEFAULT 'none'",
+		"ALTER TABLE users ADD email TEXT, RENAME COLUMN email TO mail",
+		"ALTER TABLE users DROP COLUMN name",
+		"ALTER TABLE users RENAME name TO full_name",
+		"ALTER TABLE users RENAME TO people",
+		"ALTER TABLE users ADD CONSTRAINT users_order_fkey FOREIGN KEY (id) REFERENCES orders (id)",
+		"ALTER TABLE users ADD CHECK (id > 0 AND name <> '')",
+		"ALTER TABLE users DROP CONSTRAINT users_name_key",
+	}
+	for _, sql := range valid {
+		if err := compile(sql); err != nil {
+			t.Errorf("%s: compile failed: %v", sql, err)
+		}
+	}
+
+	invalid := []string{
+		"ALTER TABLE missing ADD COLUMN x INTEGER",
+		"ALTER TABLE users ADD COLUMN name TEXT",
+		"ALTER TABLE users ADD COLUMN x INTEGER PRIMARY KEY",
+		"ALTER TABLE users ADD COLUMN x INTEGER DEFAULT 'abc'",
+		"ALTER TABLE users ADD COLUMN x INTEGER DEFAULT id",
+		"ALTER TABLE users DROP COLUMN id",
+		"ALTER TABLE users DROP COLUMN missing",
+		"ALTER TABLE users DROP COLUMN name, RENAME COLUMN name TO n",
+		"ALTER TABLE users RENAME COLUMN id TO name",
+		"ALTER TABLE users RENAME TO orders",
+		"ALTER TABLE users ADD PRIMARY KEY (name)",
+		"ALTER TABLE users ADD FOREIGN KEY (id) REFERENCES missing (id)",
+		"ALTER TABLE users ADD CHECK (name)",
+		"ALTER TABLE users DROP CONSTRAINT missing",
+	}
+	for _, sql := range invalid {
+		if err := compile(sql); err == nil {
+			t.Errorf("%s: expected compile error", sql)
+		}
+	}
+}
+
+func TestIndexStatementValidation(t *testing.T) {
+	catalog := NewMockCatalog()
+	users := NewTableMetadata("users")
+	users.AddColumn(&ColumnMetadata{Name: "id", TableName: "users", DataType: DataTypeInteger})
+	users.AddColumn(&ColumnMetadata{Name: "email", TableName: "users", DataType: DataTypeText})
+	users.Indexes = []string{"idx_users_email"}
+	catalog.AddTable(users)
+	qc := NewQueryCompiler(catalog)
+
+	compile := func(sql string) (*CompiledQuery, error) {
+		stmt, err := parser.ParseSQL(sql)
+		if err != nil {
+			t.Fatalf("%s: parse failed: %v", sql, err)
+		}
+		return qc.Compile(stmt)
+	}
+
+	valid := map[string]QueryType{
+		"CREATE INDEX idx_users_id ON users (id)":                       QueryTypeCreateIndex,
+		"CREATE UNIQUE INDEX idx_pair ON users (id, email) USING btree": QueryTypeCreateIndex,
+		"CREATE INDEX IF NOT EXISTS idx_users_email ON users (email)":   QueryTypeCreateIndex,
+		"DROP INDEX idx_users_email":                                    QueryTypeDropIndex,
+		"DROP INDEX IF EXISTS missing":                                  QueryTypeDropIndex,
+	}
+	for sql, queryType := range valid {
+		compiled, err := compile(sql)
+		if err != nil {
+			t.Errorf("%s: compile failed: %v", sql, err)
+			continue
+		}
+		if compiled.QueryType != queryType {
+			t.Errorf("%s: expected %s, got %s", sql, queryType, compiled.QueryType)
+		}
+	}
+
+	invalid := []string{
+		"CREATE INDEX idx ON missing (id)",
+		"CREATE INDEX idx ON users (missing)",
+		"CREATE INDEX idx ON users (id, ID)",
+		"CREATE INDEX IDX_USERS_EMAIL ON users (id)",
+		"CREATE INDEX idx ON users (id) USING gist",
+		"DROP INDEX missing",
+	}
+	for _, sql := range invalid {
+		if _, err := compile(sql); err == nil {
+			t.Errorf("%s: expected compile error", sql)
+		}
+	}
+}
+
+func TestTransactionStatementTypes(t *testing.T) {
+	qc := NewQueryCompiler(NewMockCatalog())
+
+	tests := map[string]QueryType{
+		"BEGIN ISOLATION LEVEL SERIALIZABLE": QueryTypeBegin,
+		"START TRANSACTION":                  QueryTypeBegin,
+		"COMMIT":                             QueryTypeCommit,
+		"ROLLBACK":                           QueryTypeRollback,
+		"ROLLBACK TO SAVEPOINT sp":           QueryTypeRollback,
+		"SAVEPOINT sp":                       QueryTypeSavepoint,
+		"RELEASE SAVEPOINT sp":               QueryTypeSavepoint,
+	}
+	for sql, queryType := range tests {
+		stmt, err := parser.ParseSQL(sql)
+		if err != nil {
+			t.Fatalf("%s: parse failed: %v", sql, err)
+		}
+		compiled, err := qc.Compile(stmt)
+		if err != nil {
+			t.Errorf("%s: compile failed: %v", sql, err)
+			continue
+		}
+		if compiled.QueryType != queryType || !compiled.QueryType.IsTCL() {
+			t.Errorf("%s: expected %s, got %s", sql, queryType, compiled.QueryType)
+		}
+	}
+}
+
+func TestViewCompilation(t *testing.T) {
+	catalog := NewMockCatalog()
+	users := NewTableMetadata("users")
+	users.AddColumn(&ColumnMetadata{Name: "id", TableName: "users", DataType: DataTypeInteger})
+	users.AddColumn(&ColumnMetadata{Name: "name", TableName: "users", DataType: DataTypeText})
+	users.AddColumn(&ColumnMetadata{Name: "active", TableName: "users", DataType: DataTypeBoolean})
+	catalog.AddTable(users)
+	totals := NewTableMetadata("totals")
+	totals.AddColumn(&ColumnMetadata{Name: "id", TableName: "totals", DataType: DataTypeInteger})
+	catalog.AddTable(totals)
+
+	catalog.AddView(&ViewMetadata{Name: "active_users", Query: "SELECT id, name FROM users WHERE active = TRUE", DependsOn: []string{"users"}})
+	catalog.AddView(&ViewMetadata{Name: "named_users", Query: "SELECT id, name FROM users", Columns: []string{"uid", "uname"}, DependsOn: []string{"users"}})
+	catalog.AddView(&ViewMetadata{Name: "nested", Query: "SELECT uid FROM named_users", DependsOn: []string{"named_users"}})
+	catalog.AddView(&ViewMetadata{Name: "loop1", Query: "SELECT * FROM loop2", DependsOn: []string{"loop2"}})
+	catalog.AddView(&ViewMetadata{Name: "loop2", Query: "SELECT * FROM loop1", DependsOn: []string{"loop1"}})
+	catalog.AddView(&ViewMetadata{Name: "totals", Query: "SELECT id FROM users", Materialized: true, DependsOn: []string{"users"}})
+	qc := NewQueryCompiler(catalog)
+
+	compile := func(sql string) (parser.Statement, *CompiledQuery, error) {
+		stmt, err := parser.ParseSQL(sql)
+		if err != nil {
+			t.Fatalf("%s: parse failed: %v", sql, err)
+		}
+		compiled, err := qc.Compile(stmt)
+		return stmt, compiled, err
+	}
+
+	// A view is expanded into a derived table named after it
+	stmt, compiled, err := compile("SELECT name FROM active_users WHERE id > 1")
+	if err != nil {
+		t.Fatalf("compile failed: %v", err)
+	}
+	if _, ok := stmt.(*parser.SelectStatement).FromClause.Tables[0].(*parser.SubqueryExpression); !ok {
+		t.Errorf("Expected the view to be expanded, got %s", stmt)
+	}
+	if compiled.ResolvedRefs.Views["active_users"] == nil {
+		t.Error("Expected the view to be recorded")
+	}
+	if deps := compiled.ResolvedRefs.Dependencies; len(deps) != 1 || deps[0] != "active_users" {
+		t.Errorf("Expected the query to depend on active_users only, got %v", deps)
+	}
+
+	valid := []string{
+		"SELECT n.uname FROM named_users AS n WHERE n.uid = 1",
+		"SELECT uid FROM nested",
+		"SELECT u.name FROM users u JOIN active_users a ON u.id = a.id",
+		"SELECT id FROM totals",
+	}
+	for _, sql := range valid {
+		if _, _, err := compile(sql); err != nil {
+			t.Errorf("%s: compile failed: %v", sql, err)
+		}
+	}
+
+	_, compiled, err = compile("CREATE VIEW v (a, b) AS SELECT id, name FROM active_users")
+	if err != nil {
+		t.Fatalf("compile failed: %v", err)
+	}
+	if compiled.QueryType != QueryTypeCreateView {
+		t.Errorf("Expected CREATE_VIEW, got %s", compiled.QueryType)
+	}
+	columns := compiled.ResolvedRefs.ViewColumns
+	if columns == nil || len(columns.Columns) != 2 || columns.Columns[0].Name != "a" || columns.Columns[1].DataType != DataTypeText {
+		t.Errorf("Expected view columns (a INTEGER, b TEXT), got %+v", columns)
+	}
+	if deps := compiled.ResolvedRefs.Dependencies; len(deps) != 1 || deps[0] != "active_users" {
+		t.Errorf("Expected the view to depend on active_users only, got %v", deps)
+	}
+
+	ddl := map[string]QueryType{
+		"CREATE OR REPLACE VIEW active_users AS SELECT id FROM users":    QueryTypeCreateView,
+		"CREATE VIEW IF NOT EXISTS active_users AS SELECT id FROM users": QueryTypeCreateView,
+		"CREATE MATERIALIZED VIEW mv AS SELECT uid FROM nested":          QueryTypeCreateView,
+		"DROP VIEW active_users":                                         QueryTypeDropView,
+		"DROP VIEW IF EXISTS missing":                                    QueryTypeDropView,
+		"DROP MATERIALIZED VIEW totals":                                  QueryTypeDropView,
+		"REFRESH MATERIALIZED VIEW totals":                               QueryTypeRefreshMaterializedView,
+	}
+	for sql, queryType := range ddl {
+		_, compiled, err := compile(sql)
+		if err != nil {
+			t.Errorf("%s: compile failed: %v", sql, err)
+			continue
+		}
+		if compiled.QueryType != queryType {
+			t.Errorf("%s: expected %s, got %s", sql, queryType, compiled.QueryType)
+		}
+	}
+
+	invalid := []string{
+		// Renamed columns hide the original names
+		"SELECT name FROM named_users",
+		// Views that refer to each other
+		"SELECT * FROM loop1",
+		"CREATE OR REPLACE VIEW named_users AS SELECT uid AS a, uid AS b FROM nested",
+		// Name conflicts
+		"CREATE VIEW active_users AS SELECT id FROM users",
+		"CREATE VIEW users AS SELECT id FROM users",
+		"CREATE OR REPLACE VIEW totals AS SELECT id FROM users",
+		// Column list and select list differ
+		"CREATE VIEW v (a) AS SELECT id, name FROM users",
+		"CREATE VIEW v AS SELECT id, id FROM users",
+		// The kind of view must match
+		"DROP MATERIALIZED VIEW active_users",
+		"DROP VIEW totals",
+		"DROP TABLE totals",
+		"DROP VIEW missing",
+		"REFRESH MATERIALIZED VIEW active_users",
+	}
+	for _, sql := range invalid {
+		if _, _, err := compile(sql); err == nil {
+			t.Errorf("%s: expected compile error", sql)
+		}
+	}
+
+	// Dependents come before the views they read
+	dependents := DependentViews(catalog, "users")
+	position := make(map[string]int)
+	for idx, name := range dependents {
+		position[name] = idx
+	}
+	if len(dependents) != 4 || position["nested"] > position["named_users"] {
+		t.Errorf("Expected active_users, named_users, nested and totals with nested first, got %v", dependents)
+	}
+}
+
+func TestDataModificationCompilation(t *testing.T) {
+	catalog := NewMockCatalog()
+	users := NewTableMetadata("users")
+	users.AddColumn(&ColumnMetadata{Name: "id", TableName: "users", DataType: DataTypeInteger})
+	users.AddColumn(&ColumnMetadata{Name: "name", TableName: "users", DataType: DataTypeText})
+	catalog.AddTable(users)
+	archive := NewTableMetadata("archive")
+	archive.AddColumn(&ColumnMetadata{Name: "id", TableName: "archive", DataType: DataTypeInteger})
+	archive.AddColumn(&ColumnMetadata{Name: "name", TableName: "archive", DataType: DataTypeText})
+	catalog.AddTable(archive)
+	qc := NewQueryCompiler(catalog)
+
+	compile := func(sql string) (*CompiledQuery, error) {
+		stmt, err := parser.ParseSQL(sql)
+		if err != nil {
+			t.Fatalf("%s: parse failed: %v", sql, err)
+		}
+		return qc.Compile(stmt)
+	}
+
+	// RETURNING names and types its columns like a select list
+	compiled, err := compile("INSERT INTO users (name) VALUES ('a'), ('b') RETURNING id, name AS n, id + 1")
+	if err != nil {
+		t.Fatalf("compile failed: %v", err)
+	}
+	returning := compiled.ResolvedRefs.Returning
+	if returning == nil || len(returning.Columns) != 3 {
+		t.Fatalf("Expected 3 RETURNING columns, got %+v", returning)
+	}
+	if returning.Columns[1].Name != "n" || returning.Columns[1].DataType != DataTypeText || returning.Columns[2].DataType != DataTypeInteger {
+		t.Errorf("Expected RETURNING columns (id, n TEXT, column3 INTEGER), got %+v", returning.Columns)
+	}
+
+	compiled, err = compile("DELETE FROM users WHERE id = 1 RETURNING *")
+	if err != nil {
+		t.Fatalf("compile failed: %v", err)
+	}
+	if returning := compiled.ResolvedRefs.Returning; returning == nil || len(returning.Columns) != 2 {
+		t.Errorf("Expected * to return both columns of users, got %+v", returning)
+	}
+
+	valid := []string{
+		"INSERT INTO archive SELECT id, name FROM users WHERE id > 1",
+		"INSERT INTO archive (id) SELECT id FROM users RETURNING name",
+		"INSERT INTO users VALUES (1)",
+		"UPDATE users SET name = 'x' WHERE id = 1 RETURNING users.name",
+	}
+	for _, sql := range valid {
+		if _, err := compile(sql); err != nil {
+			t.Errorf("%s: compile failed: %v", sql, err)
+		}
+	}
+
+	invalid := []string{
+		// Row widths
+		"INSERT INTO users VALUES (1, 'a', 2)",
+		"INSERT INTO users (id, name) VALUES (1)",
+		"INSERT INTO users VALUES (1, 'a'), (2)",
+		"INSERT INTO archive SELECT id, name, id FROM users",
+		"INSERT INTO archive (id, name) SELECT id FROM users",
+		// The query does not see the target table
+		"INSERT INTO archive SELECT archive.id, name FROM users",
+		// Unknown RETURNING columns
+		"INSERT INTO users VALUES (1, 'a') RETURNING missing",
+		"DELETE FROM users RETURNING archive.id",
+		// AUTO_INCREMENT
+		"CREATE TABLE t (id TEXT AUTO_INCREMENT)",
+		"CREATE TABLE t (id INT AUTO_INCREMENT, n INT AUTO_INCREMENT)",
+	}
+	for _, sql := range invalid {
+		if _, err := compile(sql); err == nil {
+			t.Errorf("%s: expected compile error", sql)
+		}
+	}
+
+	if _, err := compile("CREATE TABLE t (id BIGINT PRIMARY KEY AUTO_INCREMENT)"); err != nil {
+		t.Errorf("compile failed: %v", err)
+	}
+}
+
+func TestUpsertAndMergeCompilation(t *testing.T) {
+	catalog := NewMockCatalog()
+	users := NewTableMetadata("users")
+	users.AddColumn(&ColumnMetadata{Name: "id", TableName: "users", DataType: DataTypeInteger})
+	users.AddColumn(&ColumnMetadata{Name: "email", TableName: "users", DataType: DataTypeText})
+	users.AddColumn(&ColumnMetadata{Name: "name", TableName: "users", DataType: DataTypeText})
+	users.PrimaryKey = []string{"id"}
+	users.UniqueKeys = [][]string{{"email"}}
+	catalog.AddTable(users)
+	staging := NewTableMetadata("staging")
+	staging.AddColumn(&ColumnMetadata{Name: "id", TableName: "staging", DataType: DataTypeInteger})
+	staging.AddColumn(&ColumnMetadata{Name: "name", TableName: "staging", DataType: DataTypeText})
+	catalog.AddTable(staging)
+	qc := NewQueryCompiler(catalog)
+
+	compile := func(sql string) (*CompiledQuery, error) {
+		stmt, err := parser.ParseSQL(sql)
+		if err != nil {
+			t.Fatalf("%s: parse failed: %v", sql, err)
+		}
+		return qc.Compile(stmt)
+	}
+
+	compiled, err := compile("MERGE INTO users u USING staging s ON u.id = s.id WHEN MATCHED THEN UPDATE SET name = s.name")
+	if err != nil {
+		t.Fatalf("compile failed: %v", err)
+	}
+	if compiled.QueryType != QueryTypeMerge || !compiled.QueryType.IsDML() {
+		t.Errorf("Expected a DML MERGE, got %s", compiled.QueryType)
+	}
+
+	valid := []string{
+		"INSERT INTO users VALUES (1, 'a', 'b') ON CONFLICT DO NOTHING",
+		"INSERT INTO users VALUES (1, 'a', 'b') ON CONFLICT (ID) DO NOTHING",
+		"INSERT INTO users VALUES (1, 'a', 'b') ON CONFLICT (email) DO UPDATE SET name = excluded.name WHERE users.name <> excluded.name",
+		"INSERT INTO users SELECT id, name, name FROM staging ON CONFLICT (id) DO UPDATE SET name = excluded.name RETURNING id",
+		"MERGE INTO users USING (SELECT id, name FROM staging) AS s ON users.id = s.id WHEN MATCHED AND s.name IS NULL THEN DELETE WHEN MATCHED THEN UPDATE SET name = s.name WHEN NOT MATCHED THEN INSERT (id, name) VALUES (s.id, s.name)",
+		"MERGE INTO users USING staging ON users.id = staging.id WHEN NOT MATCHED THEN INSERT VALUES (id, name)",
+	}
+	for _, sql := range valid {
+		if _, err := compile(sql); err != nil {
+			t.Errorf("%s: compile failed: %v", sql, err)
+		}
+	}
+
+	invalid := []string{
+		// Conflict columns must be a primary key or unique index
+		"INSERT INTO users VALUES (1, 'a', 'b') ON CONFLICT (name) DO NOTHING",
+		"INSERT INTO users VALUES (1, 'a', 'b') ON CONFLICT (id, email) DO NOTHING",
+		"INSERT INTO users VALUES (1, 'a', 'b') ON CONFLICT (missing) DO NOTHING",
+		"INSERT INTO users VALUES (1, 'a', 'b') ON CONFLICT DO UPDATE SET name = 'x'",
+		// DO UPDATE sees the stored row and excluded, and both have every column
+		"INSERT INTO users VALUES (1, 'a', 'b') ON CONFLICT (id) DO UPDATE SET name = name",
+		"INSERT INTO users VALUES (1, 'a', 'b') ON CONFLICT (id) DO UPDATE SET missing = 1",
+		"INSERT INTO users VALUES (1, 'a', 'b') ON CONFLICT (id) DO UPDATE SET name = staging.name",
+		// MERGE
+		"MERGE INTO missing USING staging ON missing.id = staging.id WHEN MATCHED THEN DELETE",
+		"MERGE INTO users USING staging ON users.id = staging.missing WHEN MATCHED THEN DELETE",
+		"MERGE INTO users USING staging ON users.id = staging.id WHEN NOT MATCHED THEN INSERT (id) VALUES (users.id)",
+		"MERGE INTO users USING staging ON users.id = staging.id WHEN NOT MATCHED THEN INSERT (id, name) VALUES (staging.id)",
+		"MERGE INTO users USING staging ON users.id = staging.id WHEN MATCHED THEN UPDATE SET missing = 1",
+		"MERGE INTO users USING staging ON users.id = staging.id WHEN MATCHED THEN DELETE WHEN MATCHED AND staging.id > 1 THEN DELETE",
+		"MERGE INTO users USING (SELECT id FROM staging) ON users.id = 1 WHEN MATCHED THEN DELETE",
+		"MERGE INTO staging USING staging ON staging.id = 1 WHEN MATCHED THEN DELETE",
+		"MERGE INTO users USING staging ON users.name WHEN MATCHED THEN DELETE",
+	}
+	for _, sql := range invalid {
+		if _, err := compile(sql); err == nil {
+			t.Errorf("%s: expected compile error", sql)
+		}
+	}
+}
+
+func TestJoinedUpdateAndDeleteCompilation(t *testing.T) {
+	catalog := NewMockCatalog()
+	users := NewTableMetadata("users")
+	users.AddColumn(&ColumnMetadata{Name: "id", TableName: "users", DataType: DataTypeInteger})
+	users.AddColumn(&ColumnMetadata{Name: "name", TableName: "users", DataType: DataTypeText})
+	users.AddColumn(&ColumnMetadata{Name: "manager", TableName: "users", DataType: DataTypeInteger})
+	catalog.AddTable(users)
+	staging := NewTableMetadata("staging")
+	staging.AddColumn(&ColumnMetadata{Name: "user_id", TableName: "staging", DataType: DataTypeInteger})
+	staging.AddColumn(&ColumnMetadata{Name: "name", TableName: "staging", DataType: DataTypeText})
+	catalog.AddTable(staging)
+	qc := NewQueryCompiler(catalog)
+
+	compile := func(sql string) (*CompiledQuery, error) {
+		stmt, err := parser.ParseSQL(sql)
+		if err != nil {
+			t.Fatalf("%s: parse failed: %v", sql, err)
+		}
+		return qc.Compile(stmt)
+	}
+
+	compiled, err := compile("UPDATE users SET name = s.name FROM staging s WHERE users.id = s.user_id")
+	if err != nil {
+		t.Fatalf("compile failed: %v", err)
+	}
+	if deps := compiled.ResolvedRefs.Dependencies; len(deps) != 1 || deps[0] != "staging" {
+		t.Errorf("Expected staging as the only table read, got %v", deps)
+	}
+
+	valid := []string{
+		"UPDATE users SET name = staging.name FROM staging WHERE id = user_id",
+		"UPDATE users SET manager = m.id FROM users AS m WHERE users.manager = m.manager RETURNING users.id",
+		"UPDATE users SET name = x.name FROM (SELECT user_id, name FROM staging) AS x JOIN users AS u ON u.id = x.user_id WHERE users.id = u.manager",
+		"DELETE FROM users USING staging WHERE users.id = staging.user_id",
+		"DELETE FROM users USING staging s, users AS m WHERE users.manager = m.id AND m.id = s.user_id RETURNING *",
+	}
+	for _, sql := range valid {
+		if _, err := compile(sql); err != nil {
+			t.Errorf("%s: compile failed: %v", sql, err)
+		}
+	}
+
+	invalid := []string{
+		// The target can only be joined to itself under an alias
+		"UPDATE users SET name = 'x' FROM users WHERE users.id = 1",
+		"DELETE FROM users USING staging, users WHERE users.id = 1",
+		// Columns of both tables must be told apart
+		"UPDATE users SET name = name FROM staging WHERE id = user_id",
+		"DELETE FROM users USING staging WHERE name = 'x'",
+		// Join conditions inside FROM do not see the target
+		"UPDATE users SET name = 'x' FROM staging JOIN staging AS t ON t.user_id = users.id",
+		"UPDATE users SET name = 'x' FROM missing WHERE users.id = 1",
+		"UPDATE users SET name = 'x' FROM staging WHERE staging.missing = 1",
+		"UPDATE users SET name = 'x' FROM staging JOIN staging AS t ON t.name WHERE users.id = 1",
+	}
+	for _, sql := range invalid {
+		if _, err := compile(sql); err == nil {
+			t.Errorf("%s: expected compile error", sql)
+		}
+	}
+}
+
+func TestCompilationErrorPositions(t *testing.T) {
+	catalog := NewMockCatalog()
+	users := NewTableMetadata("users")
+	users.AddColumn(&ColumnMetadata{Name: "id", TableName: "users", DataType: DataTypeInteger})
+	users.AddColumn(&ColumnMetadata{Name: "name", TableName: "users", DataType: DataTypeText})
+	catalog.AddTable(users)
+	qc := NewQueryCompiler(catalog)
+
+	tests := []struct {
+		sql          string
+		line, column int
+		part         string
+	}{
+		{"SELECT id, nme FROM users", 1, 12, "nme"},
+		{"SELECT id\nFROM userz", 2, 6, "userz"},
+		{"SELECT u.x FROM users u", 1, 8, "u.x"},
+		{"UPDATE users SET nam = 'x'", 1, 18, "nam"},
+		// Type errors point at the innermost expression that caused them
+		{"SELECT id FROM users\nWHERE id = 1 AND name + 1 = 2", 2, 18, "(name + 1)"},
+		{"SELECT id FROM users WHERE name", 1, 28, "name"},
+	}
+	for _, tt := range tests {
+		stmt, err := parser.ParseSQL(tt.sql)
+		if err != nil {
+			t.Fatalf("%s: parse failed: %v", tt.sql, err)
+		}
+		compiled, err := qc.Compile(stmt)
+		if err == nil || len(compiled.Errors) != 1 {
+			t.Errorf("%s: expected one compile error, got %v", tt.sql, err)
+			continue
+		}
+		if ce := compiled.Errors[0]; ce.Line != tt.line || ce.Column != tt.column || ce.QueryPart != tt.part {
+			t.Errorf("%s: expected error at %d:%d in %q, got %d:%d in %q", tt.sql, tt.line, tt.column, tt.part, ce.Line, ce.Column, ce.QueryPart)
+		}
+	}
+}
+
+func TestLiteralTypes(t *testing.T) {
+	qc := NewQueryCompiler(NewMockCatalog())
+	stmt, err := parser.ParseSQL("CREATE VIEW v AS SELECT 42, 99999999999999999999, 1.5, 1.5e10, 'text', E'line\\n', X'CAFE', TRUE, NULL")
+	if err != nil {
+		t.Fatalf("parse failed: %v", err)
+	}
+	compiled, err := qc.Compile(stmt)
+	if err != nil {
+		t.Fatalf("compile failed: %v", err)
+	}
+
+	expected := []DataType{
+		DataTypeInteger, DataTypeNumeric, DataTypeNumeric, DataTypeReal,
+		DataTypeText, DataTypeText, DataTypeBlob, DataTypeBoolean, DataTypeNull,
+	}
+	columns := compiled.ResolvedRefs.ViewColumns
+	if columns == nil || len(columns.Columns) != len(expected) {
+		t.Fatalf("Expected %d view columns, got %+v", len(expected), columns)
+	}
+	for i, want := range expected {
+		if got := columns.Columns[i].DataType; got != want {
+			t.Errorf("Column %d: expected %s, got %s", i+1, want, got)
+		}
+	}
+
+	// Exact decimals are stored in REAL columns, but not in INTEGER ones
+	ddl := "CREATE TABLE t (id INTEGER, price REAL DEFAULT 1.5, ratio REAL DEFAULT 2.5e-1, data BLOB DEFAULT X'00')"
+	if stmt, err = parser.ParseSQL(ddl); err != nil {
+		t.Fatalf("parse failed: %v", err)
+	}
+	if _, err := qc.Compile(stmt); err != nil {
+		t.Errorf("%s: compile failed: %v", ddl, err)
+	}
+	if !DataTypeNumeric.CanCoerceTo(DataTypeReal) || DataTypeNumeric.CanCoerceTo(DataTypeInteger) {
+		t.Errorf("Expected NUMERIC to coerce to REAL only")
+	}
+}
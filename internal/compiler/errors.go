@@ -1,6 +1,11 @@
 package compiler
 
-import "fmt"
+import (
+	"errors"
+	"fmt"
+
+	"relational-db/internal/parser"
+)
 
 // ErrorCode represents specific compilation error codes
 type ErrorCode int
@@ -72,6 +77,9 @@ type CompilationError struct {
 	Column    int
 	Position  int
 	QueryPart string
+
+	// Err is the error the compilation step failed with
+	Err error
 }
 
 // Error implements the error interface
@@ -82,6 +90,11 @@ func (ce *CompilationError) Error() string {
 	return fmt.Sprintf("[%s] %s", ce.Category, ce.Message)
 }
 
+// Unwrap returns the error the compilation step failed with
+func (ce *CompilationError) Unwrap() error {
+	return ce.Err
+}
+
 // WithHint adds a hint to the error
 func (ce *CompilationError) WithHint(hint string) *CompilationError {
 	ce.Hint = hint
@@ -96,3 +109,44 @@ func NewCompilationError(code ErrorCode, category ErrorCategory, message string)
 		Message:  message,
 	}
 }
+
+// nodeError is an error raised at a node of the statement being compiled.
+// The node gives the CompilationError its position in the source.
+type nodeError struct {
+	node parser.Node
+	err  error
+}
+
+func (e *nodeError) Error() string { return e.err.Error() }
+func (e *nodeError) Unwrap() error { return e.err }
+
+// errorAt ties err to node, the part of the statement it was raised at. An
+// error already tied to a node keeps it, so errors are located at the
+// innermost node they concern, and nodes without a source position are
+// skipped. A nil err stays nil.
+func errorAt(node parser.Node, err error) error {
+	if err == nil {
+		return nil
+	}
+	var at *nodeError
+	if errors.As(err, &at) || !parser.PositionOf(node).IsValid() {
+		return err
+	}
+	return &nodeError{node: node, err: err}
+}
+
+// compilationErrorFor returns the CompilationError describing an error of a
+// compilation step, located at the node the error was raised at, if known
+func compilationErrorFor(code ErrorCode, category ErrorCategory, err error) CompilationError {
+	ce := NewCompilationError(code, category, err.Error())
+	ce.Err = err
+	var at *nodeError
+	if errors.As(err, &at) {
+		pos := parser.PositionOf(at.node)
+		ce.Line = pos.Line
+		ce.Column = pos.Column
+		ce.Position = pos.Offset
+		ce.QueryPart = at.node.String()
+	}
+	return ce
+}
@@ -1,30 +1,29 @@
 package compiler
 
-import (
-	"fmt"
-	"strings"
-)
+import "fmt"
 
 // MockCatalog is a simple in-memory catalog for testing
 type MockCatalog struct {
 	tables map[string]*TableMetadata
+	views  map[string]*ViewMetadata
 }
 
 // NewMockCatalog creates a new mock catalog
 func NewMockCatalog() *MockCatalog {
 	return &MockCatalog{
 		tables: make(map[string]*TableMetadata),
+		views:  make(map[string]*ViewMetadata),
 	}
 }
 
 // AddTable adds a table to the mock catalog
 func (mc *MockCatalog) AddTable(table *TableMetadata) {
-	mc.tables[strings.ToLower(table.Name)] = table
+	mc.tables[table.Name] = table
 }
 
 // GetTable retrieves table metadata by name
 func (mc *MockCatalog) GetTable(name string) (*TableMetadata, error) {
-	table, found := mc.tables[strings.ToLower(name)]
+	table, found := mc.tables[name]
 	if !found {
 		return nil, fmt.Errorf("table not found: %s", name)
 	}
@@ -42,7 +41,7 @@ func (mc *MockCatalog) GetColumn(table, column string) (*ColumnMetadata, error)
 
 // TableExists checks if a table exists
 func (mc *MockCatalog) TableExists(name string) bool {
-	_, found := mc.tables[strings.ToLower(name)]
+	_, found := mc.tables[name]
 	return found
 }
 
@@ -54,3 +53,26 @@ func (mc *MockCatalog) ListTables() ([]string, error) {
 	}
 	return names, nil
 }
+
+// AddView adds a view to the mock catalog
+func (mc *MockCatalog) AddView(view *ViewMetadata) {
+	mc.views[view.Name] = view
+}
+
+// GetView retrieves view metadata by name
+func (mc *MockCatalog) GetView(name string) (*ViewMetadata, error) {
+	view, found := mc.views[name]
+	if !found {
+		return nil, fmt.Errorf("view not found: %s", name)
+	}
+	return view, nil
+}
+
+// ListViews returns all view names
+func (mc *MockCatalog) ListViews() ([]string, error) {
+	names := make([]string, 0, len(mc.views))
+	for name := range mc.views {
+		names = append(names, name)
+	}
+	return names, nil
+}
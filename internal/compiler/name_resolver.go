@@ -2,38 +2,106 @@ package compiler
 
 import (
 	"fmt"
+	"strconv"
+	"strings"
 
 	"relational-db/internal/parser"
 )
 
 // NameResolver resolves table and column names to schema metadata
 type NameResolver struct {
-	catalog CatalogManager
-	refs    *ResolvedReferences
-	scope   *Scope
+	catalog   CatalogManager
+	refs      *ResolvedReferences
+	scope     *Scope
+	expanding []string // views whose queries are being resolved, outermost first
+	inView    bool     // resolving the query of a view referenced in FROM
 }
 
 // Scope represents a naming scope (for nested subqueries)
 type Scope struct {
 	Tables  map[string]*TableMetadata
 	Aliases map[string]string
+	CTEs    map[string]*cteBinding
 	Parent  *Scope
 }
 
+// cteBinding is a common table expression visible under its name
+type cteBinding struct {
+	cte   *parser.CommonTableExpression
+	table *TableMetadata
+	self  bool // bound in its own recursive term
+}
+
+// newScope creates an empty scope nested in parent
+func newScope(parent *Scope) *Scope {
+	return &Scope{
+		Tables:  make(map[string]*TableMetadata),
+		Aliases: make(map[string]string),
+		CTEs:    make(map[string]*cteBinding),
+		Parent:  parent,
+	}
+}
+
+// lookupCTE finds the common table expression a table name refers to in
+// this scope or an enclosing one
+func (s *Scope) lookupCTE(name string) (*cteBinding, bool) {
+	for scope := s; scope != nil; scope = scope.Parent {
+		if binding, found := scope.CTEs[name]; found {
+			return binding, true
+		}
+	}
+	return nil, false
+}
+
+// lookupTable finds a table in this scope by name or alias
+func (s *Scope) lookupTable(name string) (*TableMetadata, bool) {
+	if table, found := s.Tables[name]; found {
+		return table, true
+	}
+	if realName, ok := s.Aliases[name]; ok {
+		table, found := s.Tables[realName]
+		return table, found
+	}
+	return nil, false
+}
+
 // NewNameResolver creates a new name resolver
 func NewNameResolver(catalog CatalogManager, refs *ResolvedReferences) *NameResolver {
 	return &NameResolver{
 		catalog: catalog,
 		refs:    refs,
-		scope: &Scope{
-			Tables:  make(map[string]*TableMetadata),
-			Aliases: make(map[string]string),
-		},
+		scope:   newScope(nil),
+	}
+}
+
+// withScope returns a resolver for a subquery that resolves names in scope
+// and records them in the same references
+func (nr *NameResolver) withScope(scope *Scope) *NameResolver {
+	return &NameResolver{
+		catalog:   nr.catalog,
+		refs:      nr.refs,
+		scope:     scope,
+		expanding: nr.expanding,
+		inView:    nr.inView,
 	}
 }
 
 // ResolveSelect resolves names in a SELECT statement
 func (nr *NameResolver) ResolveSelect(stmt *parser.SelectStatement) error {
+	// Common table expressions are defined in this scope, and the query's
+	// own tables in a scope nested in it, so derived tables see them too
+	if stmt.With != nil {
+		if err := nr.resolveWithClause(stmt.With); err != nil {
+			return err
+		}
+		nr = nr.withScope(newScope(nr.scope))
+	}
+
+	// A set operation has no clauses of its own besides ORDER BY and LIMIT
+	if stmt.SetOperation != nil {
+		return nr.resolveSetOperation(stmt)
+	}
+
 	// Step 1: Resolve FROM clause (tables)
 	if stmt.FromClause != nil {
 		if err := nr.resolveFromClause(stmt.FromClause); err != nil {
@@ -82,6 +150,13 @@ func (nr *NameResolver) ResolveSelect(stmt *parser.SelectStatement) error {
 		}
 	}
 
+	// Step 7: Record the columns the query returns
+	result, err := nr.derivedTable("", stmt, nil)
+	if err != nil {
+		return err
+	}
+	nr.refs.QueryResults[stmt] = result
+
 	return nil
 }
 
@@ -91,7 +166,7 @@ func (nr *NameResolver) ResolveInsert(stmt *parser.InsertStatement) error {
 	tableName := stmt.TableName.Value
 	table, err := nr.catalog.GetTable(tableName)
 	if err != nil {
-		return fmt.Errorf("table not found: %s", tableName)
+		return errorAt(stmt.TableName, fmt.Errorf("table not found: %s", tableName))
 	}
 
 	nr.refs.AddTable(tableName, table)
@@ -101,12 +176,50 @@ func (nr *NameResolver) ResolveInsert(stmt *parser.InsertStatement) error {
 	for _, col := range stmt.Columns {
 		colName := col.Value
 		if !table.HasColumn(colName) {
-			return fmt.Errorf("column %s not found in table %s", colName, tableName)
+			return errorAt(col, fmt.Errorf("column %s not found in table %s", colName, tableName))
+		}
+	}
+
+	// Without a column list the values fill the table's columns in order
+	// and may stop short of the last ones
+	targets := len(stmt.Columns)
+	if targets == 0 {
+		targets = len(table.Columns)
+	}
+	checkCount := func(count int) error {
+		if count > targets {
+			return fmt.Errorf("INSERT has more expressions than target columns")
+		}
+		if count < targets && len(stmt.Columns) > 0 {
+			return fmt.Errorf("INSERT has more target columns than expressions")
+		}
+		return nil
+	}
+
+	// The rows of INSERT ... SELECT come from a query that cannot see the
+	// table being inserted into
+	if stmt.Query != nil {
+		inner := nr.withScope(newScope(nil))
+		if err := inner.ResolveSelect(stmt.Query); err != nil {
+			return err
+		}
+		source, err := inner.derivedTable(tableName, stmt.Query, nil)
+		if err != nil {
+			return err
+		}
+		if err := checkCount(len(source.Columns)); err != nil {
+			return err
 		}
 	}
 
 	// Resolve value expressions
 	for _, valueList := range stmt.Values {
+		if len(valueList) != len(stmt.Values[0]) {
+			return fmt.Errorf("VALUES lists must all be the same length")
+		}
+		if err := checkCount(len(valueList)); err != nil {
+			return err
+		}
 		for _, expr := range valueList {
 			if err := nr.resolveExpression(expr); err != nil {
 				return err
@@ -114,6 +227,49 @@ func (nr *NameResolver) ResolveInsert(stmt *parser.InsertStatement) error {
 		}
 	}
 
+	if stmt.OnConflict != nil {
+		if err := nr.resolveOnConflict(tableName, table, stmt.OnConflict); err != nil {
+			return err
+		}
+	}
+
+	return nr.resolveReturning(stmt.TableName, stmt.Returning)
+}
+
+// resolveOnConflict resolves the ON CONFLICT clause of an INSERT into
+// table. DO UPDATE sees the row already stored under the table's name and
+// the row that was not inserted as excluded.
+func (nr *NameResolver) resolveOnConflict(tableName string, table *TableMetadata, clause *parser.OnConflictClause) error {
+	for _, col := range clause.Columns {
+		if !table.HasColumn(col.Value) {
+			return errorAt(col, fmt.Errorf("column %s not found in table %s", col.Value, tableName))
+		}
+	}
+	if !clause.DoUpdate {
+		return nil
+	}
+	if len(clause.Columns) == 0 {
+		return fmt.Errorf("ON CONFLICT DO UPDATE requires conflict columns")
+	}
+
+	inner := nr.withScope(newScope(nil))
+	inner.scope.Tables[tableName] = table
+	inner.scope.Tables[ExcludedTable] = table
+	nr.refs.AddTable(ExcludedTable, table)
+
+	for _, setClause := range clause.SetClauses {
+		if !table.HasColumn(setClause.Column.Value) {
+			return errorAt(setClause.Column, fmt.Errorf("column %s not found in table %s", setClause.Column.Value, tableName))
+		}
+		if err := inner.resolveExpression(setClause.Value); err != nil {
+			return err
+		}
+	}
+	if clause.WhereClause != nil && clause.WhereClause.Condition != nil {
+		if err := inner.resolveExpression(clause.WhereClause.Condition); err != nil {
+			return err
+		}
+	}
 	return nil
 }
 
@@ -123,17 +279,23 @@ func (nr *NameResolver) ResolveUpdate(stmt *parser.UpdateStatement) error {
 	tableName := stmt.TableName.Value
 	table, err := nr.catalog.GetTable(tableName)
 	if err != nil {
-		return fmt.Errorf("table not found: %s", tableName)
+		return errorAt(stmt.TableName, fmt.Errorf("table not found: %s", tableName))
 	}
 
 	nr.refs.AddTable(tableName, table)
 	nr.scope.Tables[tableName] = table
 
+	if stmt.From != nil {
+		if err := nr.resolveJoinedTables(tableName, stmt.From); err != nil {
+			return err
+		}
+	}
+
 	// Resolve SET clause columns and values
 	for _, setClause := range stmt.SetClauses {
 		colName := setClause.Column.Value
 		if !table.HasColumn(colName) {
-			return fmt.Errorf("column %s not found in table %s", colName, tableName)
+			return errorAt(setClause.Column, fmt.Errorf("column %s not found in table %s", colName, tableName))
 		}
 
 		if err := nr.resolveExpression(setClause.Value); err != nil {
@@ -148,7 +310,7 @@ func (nr *NameResolver) ResolveUpdate(stmt *parser.UpdateStatement) error {
 		}
 	}
 
-	return nil
+	return nr.resolveReturning(stmt.TableName, stmt.Returning)
 }
 
 // ResolveDelete resolves names in a DELETE statement
@@ -157,12 +319,18 @@ func (nr *NameResolver) ResolveDelete(stmt *parser.DeleteStatement) error {
 	tableName := stmt.TableName.Value
 	table, err := nr.catalog.GetTable(tableName)
 	if err != nil {
-		return fmt.Errorf("table not found: %s", tableName)
+		return errorAt(stmt.TableName, fmt.Errorf("table not found: %s", tableName))
 	}
 
 	nr.refs.AddTable(tableName, table)
 	nr.scope.Tables[tableName] = table
 
+	if stmt.Using != nil {
+		if err := nr.resolveJoinedTables(tableName, stmt.Using); err != nil {
+			return err
+		}
+	}
+
 	// Resolve WHERE clause
 	if stmt.WhereClause != nil && stmt.WhereClause.Condition != nil {
 		if err := nr.resolveExpression(stmt.WhereClause.Condition); err != nil {
@@ -170,6 +338,115 @@ func (nr *NameResolver) ResolveDelete(stmt *parser.DeleteStatement) error {
 		}
 	}
 
+	return nr.resolveReturning(stmt.TableName, stmt.Returning)
+}
+
+// resolveJoinedTables resolves the FROM list of an UPDATE or the USING list
+// of a DELETE. The list is resolved on its own, so its join conditions do
+// not see the target, and its tables are then made visible next to the
+// target for the rest of the statement.
+func (nr *NameResolver) resolveJoinedTables(targetName string, from *parser.FromClause) error {
+	sources := nr.withScope(newScope(nil))
+	if err := sources.resolveFromClause(from); err != nil {
+		return err
+	}
+	if _, found := sources.scope.lookupTable(targetName); found {
+		return fmt.Errorf("table name %s specified more than once", targetName)
+	}
+
+	for name, table := range sources.scope.Tables {
+		nr.scope.Tables[name] = table
+	}
+	for alias, name := range sources.scope.Aliases {
+		nr.scope.Aliases[alias] = name
+	}
+	return nil
+}
+
+// ResolveMerge resolves names in a MERGE statement. The join condition and
+// WHEN MATCHED clauses see both the target and the source; WHEN NOT MATCHED
+// clauses have no target row and only see the source.
+func (nr *NameResolver) ResolveMerge(stmt *parser.MergeStatement) error {
+	tableName := stmt.Target.Value
+	table, err := nr.catalog.GetTable(tableName)
+	if err != nil {
+		return errorAt(stmt.Target, fmt.Errorf("table not found: %s", tableName))
+	}
+
+	source := nr.withScope(newScope(nil))
+	if err := source.resolveFromItem(&stmt.Source); err != nil {
+		return err
+	}
+	if subquery, ok := stmt.Source.(*parser.SubqueryExpression); ok && subquery.Alias == nil {
+		return fmt.Errorf("subquery in MERGE USING must have an alias")
+	}
+
+	targetName := tableName
+	if stmt.Target.Alias != nil {
+		targetName = stmt.Target.Alias.Value
+		nr.refs.AddAlias(targetName, tableName)
+	}
+	if _, found := source.scope.lookupTable(targetName); found {
+		return fmt.Errorf("table name %s specified more than once", targetName)
+	}
+	nr.refs.AddTable(targetName, table)
+
+	both := newScope(nil)
+	for name, t := range source.scope.Tables {
+		both.Tables[name] = t
+	}
+	for alias, name := range source.scope.Aliases {
+		both.Aliases[alias] = name
+	}
+	both.Tables[targetName] = table
+	matched := nr.withScope(both)
+
+	if err := matched.resolveExpression(stmt.Condition); err != nil {
+		return err
+	}
+
+	for _, clause := range stmt.Clauses {
+		inner := matched
+		if !clause.Matched {
+			inner = source
+		}
+		if clause.Condition != nil {
+			if err := inner.resolveExpression(clause.Condition); err != nil {
+				return err
+			}
+		}
+
+		for _, setClause := range clause.SetClauses {
+			if !table.HasColumn(setClause.Column.Value) {
+				return errorAt(setClause.Column, fmt.Errorf("column %s not found in table %s", setClause.Column.Value, tableName))
+			}
+			if err := inner.resolveExpression(setClause.Value); err != nil {
+				return err
+			}
+		}
+
+		if clause.Action != parser.MergeInsert {
+			continue
+		}
+		for _, col := range clause.Columns {
+			if !table.HasColumn(col.Value) {
+				return errorAt(col, fmt.Errorf("column %s not found in table %s", col.Value, tableName))
+			}
+		}
+		switch {
+		case len(clause.Columns) == 0 && len(clause.Values) > len(table.Columns),
+			len(clause.Columns) > 0 && len(clause.Values) > len(clause.Columns):
+			return fmt.Errorf("MERGE INSERT has more expressions than target columns")
+		case len(clause.Values) < len(clause.Columns):
+			return fmt.Errorf("MERGE INSERT has more target columns than expressions")
+		}
+		for _, expr := range clause.Values {
+			if err := inner.resolveExpression(expr); err != nil {
+				return err
+			}
+		}
+	}
+
 	return nil
 }
 
@@ -178,7 +455,7 @@ func (nr *NameResolver) ResolveCreateTable(stmt *parser.CreateTableStatement) er
 	// Check if table already exists
 	tableName := stmt.TableName.Value
 	if nr.catalog.TableExists(tableName) {
-		return fmt.Errorf("table %s already exists", tableName)
+		return errorAt(stmt.TableName, fmt.Errorf("table %s already exists", tableName))
 	}
 
 	// For CREATE TABLE, validate column definitions and constraints
@@ -203,17 +480,161 @@ func (nr *NameResolver) ResolveDropTable(stmt *parser.DropTableStatement) error
 	// Check if table exists (unless IF EXISTS is used)
 	tableName := stmt.TableName.Value
 	if !stmt.IfExists && !nr.catalog.TableExists(tableName) {
-		return fmt.Errorf("table %s does not exist", tableName)
+		return errorAt(stmt.TableName, fmt.Errorf("table %s does not exist", tableName))
+	}
+
+	// A materialized view is stored as a table but is dropped as a view
+	if view, err := nr.catalog.GetView(tableName); err == nil && view.Materialized {
+		return fmt.Errorf("%s is a materialized view, use DROP MATERIALIZED VIEW", tableName)
+	}
+
+	return nil
+}
+
+// ResolveCreateView resolves the query of a CREATE VIEW statement and
+// records the columns of the view. The query is resolved again each time
+// the view is referenced, so only its current meaning is checked here.
+func (nr *NameResolver) ResolveCreateView(stmt *parser.CreateViewStatement) error {
+	viewName := stmt.ViewName.Value
+	if existing, err := nr.catalog.GetView(viewName); err == nil {
+		switch {
+		case stmt.IfNotExists:
+		case stmt.OrReplace && !existing.Materialized:
+		default:
+			return fmt.Errorf("view %s already exists", viewName)
+		}
+	} else if nr.catalog.TableExists(viewName) {
+		return fmt.Errorf("relation %s already exists", viewName)
+	}
+
+	inner := nr.withScope(newScope(nil))
+	inner.expanding = []string{viewName}
+	if err := inner.ResolveSelect(stmt.Query); err != nil {
+		return err
+	}
+
+	table, err := inner.derivedTable(viewName, stmt.Query, stmt.Columns)
+	if err != nil {
+		return err
+	}
+	for idx, col := range table.Columns {
+		for _, other := range table.Columns[:idx] {
+			if col.Name == other.Name {
+				return fmt.Errorf("column %s specified more than once in view %s", col.Name, viewName)
+			}
+		}
+	}
+	nr.refs.ViewColumns = table
+
+	return nil
+}
+
+// ResolveDropView resolves names in a DROP VIEW statement
+func (nr *NameResolver) ResolveDropView(stmt *parser.DropViewStatement) error {
+	viewName := stmt.ViewName.Value
+	view, err := nr.catalog.GetView(viewName)
+	if err != nil {
+		if stmt.IfExists {
+			return nil
+		}
+		return fmt.Errorf("view %s does not exist", viewName)
+	}
+
+	if view.Materialized != stmt.Materialized {
+		if view.Materialized {
+			return fmt.Errorf("%s is a materialized view, use DROP MATERIALIZED VIEW", viewName)
+		}
+		return fmt.Errorf("%s is not a materialized view", viewName)
+	}
+	nr.refs.Views[viewName] = view
+
+	return nil
+}
+
+// ResolveRefreshMaterializedView resolves the view a REFRESH MATERIALIZED
+// VIEW statement recomputes
+func (nr *NameResolver) ResolveRefreshMaterializedView(stmt *parser.RefreshMaterializedViewStatement) error {
+	viewName := stmt.ViewName.Value
+	view, err := nr.catalog.GetView(viewName)
+	if err != nil {
+		return fmt.Errorf("materialized view %s does not exist", viewName)
+	}
+	if !view.Materialized {
+		return fmt.Errorf("%s is not a materialized view", viewName)
+	}
+	nr.refs.Views[viewName] = view
+
+	return nil
+}
+
+// ResolveCreateIndex resolves the table and columns of a CREATE INDEX statement
+func (nr *NameResolver) ResolveCreateIndex(stmt *parser.CreateIndexStatement) error {
+	tableName := stmt.TableName.Value
+	table, err := nr.catalog.GetTable(tableName)
+	if err != nil {
+		return errorAt(stmt.TableName, fmt.Errorf("table not found: %s", tableName))
+	}
+
+	nr.refs.AddTable(tableName, table)
+	nr.scope.Tables[tableName] = table
+
+	for _, col := range stmt.Columns {
+		if err := nr.resolveColumnReference(col.Value, tableName); err != nil {
+			return errorAt(col, err)
+		}
+	}
+
+	return nil
+}
+
+// ResolveDropIndex resolves names in a DROP INDEX statement
+func (nr *NameResolver) ResolveDropIndex(stmt *parser.DropIndexStatement) error {
+	// Check if index exists (unless IF EXISTS is used)
+	indexName := stmt.IndexName.Value
+	if _, found := FindIndexTable(nr.catalog, indexName); !found && !stmt.IfExists {
+		return fmt.Errorf("index %s does not exist", indexName)
+	}
+
+	return nil
+}
+
+// ResolveAlterTable resolves names in an ALTER TABLE statement
+func (nr *NameResolver) ResolveAlterTable(stmt *parser.AlterTableStatement) error {
+	tableName := stmt.TableName.Value
+	table, err := nr.catalog.GetTable(tableName)
+	if err != nil {
+		return errorAt(stmt.TableName, fmt.Errorf("table not found: %s", tableName))
 	}
 
+	nr.refs.AddTable(tableName, table)
+	nr.scope.Tables[tableName] = table
+
+	// CHECK constraints are conditions on the table's rows; DEFAULT values
+	// are checked to be constant later
+	for _, action := range stmt.Actions {
+		switch action := action.(type) {
+		case *parser.AddConstraintAction:
+			if action.Constraint.Type == parser.Check {
+				if err := nr.resolveExpression(action.Constraint.Check); err != nil {
+					return err
+				}
+			}
+		case *parser.AddColumnAction:
+			for _, constraint := range action.Column.Constraints {
+				if constraint.References != nil && !nr.catalog.TableExists(constraint.References.Table.Value) {
+					return errorAt(constraint.References.Table, fmt.Errorf("table not found: %s", constraint.References.Table.Value))
+				}
+			}
+		}
+	}
 	return nil
 }
 
 // resolveFromClause resolves table references in FROM clause
 func (nr *NameResolver) resolveFromClause(from *parser.FromClause) error {
 	// Resolve tables in FROM clause
-	for _, tableExpr := range from.Tables {
-		if err := nr.resolveTableExpression(tableExpr); err != nil {
+	for idx := range from.Tables {
+		if err := nr.resolveFromItem(&from.Tables[idx]); err != nil {
 			return err
 		}
 	}
@@ -221,7 +642,7 @@ func (nr *NameResolver) resolveFromClause(from *parser.FromClause) error {
 	// TODO: Handle JOINs when fully implemented in parser
 	for _, join := range from.Joins {
 		if join.Table != nil {
-			if err := nr.resolveTableExpression(join.Table); err != nil {
+			if err := nr.resolveFromItem(&join.Table); err != nil {
 				return err
 			}
 		}
@@ -235,14 +656,116 @@ func (nr *NameResolver) resolveFromClause(from *parser.FromClause) error {
 	return nil
 }
 
+// resolveFromItem resolves a FROM item. A reference to a view is replaced
+// by the view's query, as if the query had been written as a derived table.
+func (nr *NameResolver) resolveFromItem(item *parser.Expression) error {
+	if ident, ok := (*item).(*parser.Identifier); ok {
+		if _, found := nr.scope.lookupCTE(ident.Value); !found {
+			subquery, err := nr.expandView(ident)
+			if err != nil {
+				return err
+			}
+			if subquery != nil {
+				*item = subquery
+				return nr.resolveView(subquery, ident.Value)
+			}
+		}
+	}
+	return nr.resolveTableExpression(*item)
+}
+
+// expandView returns the derived table a reference to a view stands for,
+// or nil when the name is not a view. Materialized views are read like
+// tables and are not expanded.
+func (nr *NameResolver) expandView(ref *parser.Identifier) (*parser.SubqueryExpression, error) {
+	view, err := nr.catalog.GetView(ref.Value)
+	if err != nil || view.Materialized {
+		return nil, nil
+	}
+	for _, name := range nr.expanding {
+		if name == ref.Value {
+			return nil, fmt.Errorf("view %s refers to itself", ref.Value)
+		}
+	}
+
+	stmt, err := parser.ParseSQL(view.Query)
+	if err != nil {
+		return nil, fmt.Errorf("view %s: %v", ref.Value, err)
+	}
+	query, ok := stmt.(*parser.SelectStatement)
+	if !ok {
+		return nil, fmt.Errorf("view %s is not defined by a query", ref.Value)
+	}
+
+	alias := ref.Alias
+	if alias == nil {
+		alias = &parser.Identifier{Value: ref.Value}
+	}
+	subquery := &parser.SubqueryExpression{Query: query, Alias: alias}
+	for _, col := range view.Columns {
+		subquery.Columns = append(subquery.Columns, &parser.Identifier{Value: col})
+	}
+
+	nr.refs.Views[ref.Value] = view
+	nr.addDependency(ref.Value)
+	return subquery, nil
+}
+
+// resolveView resolves the query of an expanded view. The query only sees
+// the catalog, not the query that references the view.
+func (nr *NameResolver) resolveView(subquery *parser.SubqueryExpression, viewName string) error {
+	inner := nr.withScope(newScope(nil))
+	inner.expanding = append(append([]string(nil), nr.expanding...), viewName)
+	inner.inView = true
+	if err := inner.ResolveSelect(subquery.Query); err != nil {
+		return err
+	}
+
+	name := subquery.Alias.Value
+	table, err := inner.derivedTable(name, subquery.Query, subquery.Columns)
+	if err != nil {
+		return fmt.Errorf("view %s: %v", viewName, err)
+	}
+
+	nr.refs.AddTable(name, table)
+	nr.scope.Tables[name] = table
+	return nil
+}
+
+// addDependency records a catalog table or view the statement reads. Names
+// read by the queries of expanded views are left out.
+func (nr *NameResolver) addDependency(name string) {
+	if nr.inView {
+		return
+	}
+	for _, dep := range nr.refs.Dependencies {
+		if dep == name {
+			return
+		}
+	}
+	nr.refs.Dependencies = append(nr.refs.Dependencies, name)
+}
+
 // resolveTableExpression resolves a table reference expression
 func (nr *NameResolver) resolveTableExpression(expr parser.Expression) error {
 	switch e := expr.(type) {
+	case *parser.SubqueryExpression:
+		return nr.resolveDerivedTable(e)
+
 	case *parser.Identifier:
 		tableName := e.Value
-		table, err := nr.catalog.GetTable(tableName)
-		if err != nil {
-			return fmt.Errorf("table not found: %s", tableName)
+		var table *TableMetadata
+		if binding, found := nr.scope.lookupCTE(tableName); found {
+			// Common table expressions shadow catalog tables
+			table = binding.table
+			nr.refs.CTERefs[e] = &CTEReference{CTE: binding.cte, SelfReference: binding.self}
+		} else {
+			var err error
+			table, err = nr.catalog.GetTable(tableName)
+			if err != nil {
+				return errorAt(e, fmt.Errorf("table not found: %s", tableName))
+			}
+			nr.addDependency(tableName)
 		}
 
 		// Use alias if provided, otherwise use table name
@@ -264,122 +787,469 @@ func (nr *NameResolver) resolveTableExpression(expr parser.Expression) error {
 	}
 }
 
-// resolveExpression resolves column references in an expression
-func (nr *NameResolver) resolveExpression(expr parser.Expression) error {
-	if expr == nil {
+// resolveDerivedTable resolves a subquery in FROM and exposes its select
+// list as the columns of a table named by the subquery's alias
+func (nr *NameResolver) resolveDerivedTable(subquery *parser.SubqueryExpression) error {
+	// A derived table sees the queries enclosing this one, but not the
+	// other FROM items next to it
+	inner := nr.withScope(newScope(nr.scope.Parent))
+	if err := inner.ResolveSelect(subquery.Query); err != nil {
+		return err
+	}
+
+	// Without an alias the columns cannot be referenced; the semantic
+	// analyzer reports the missing alias
+	if subquery.Alias == nil {
 		return nil
 	}
 
-	switch e := expr.(type) {
-	case *parser.Identifier:
-		// Simple column reference
-		return nr.resolveColumnReference(e.Value, "")
+	name := subquery.Alias.Value
+	table, err := inner.derivedTable(name, subquery.Query, subquery.Columns)
+	if err != nil {
+		return err
+	}
 
-	case *parser.ColumnReference:
-		// Qualified column reference (table.column)
-		tableName := ""
-		if e.Table != nil {
-			tableName = e.Table.Value
-		}
-		return nr.resolveColumnReference(e.Column.Value, tableName)
+	nr.refs.AddTable(name, table)
+	nr.scope.Tables[name] = table
+	return nil
+}
 
-	case *parser.BinaryExpression:
-		if err := nr.resolveExpression(e.Left); err != nil {
+// resolveSetOperation resolves the queries a set operation combines, each
+// in its own scope, checks that they produce the same number of columns and
+// records the columns of the result, which take their names from the left
+// query
+func (nr *NameResolver) resolveSetOperation(stmt *parser.SelectStatement) error {
+	op := stmt.SetOperation
+	var operands [2]*TableMetadata
+	for idx, query := range []*parser.SelectStatement{op.Left, op.Right} {
+		inner := nr.withScope(newScope(nr.scope))
+		if err := inner.ResolveSelect(query); err != nil {
+			return err
+		}
+		table, err := inner.derivedTable("", query, nil)
+		if err != nil {
 			return err
 		}
-		return nr.resolveExpression(e.Right)
+		nr.refs.QueryResults[query] = table
+		operands[idx] = table
+	}
+
+	left, right := operands[0], operands[1]
+	if len(left.Columns) != len(right.Columns) {
+		return fmt.Errorf("each %s query must have the same number of columns", op.Operator)
+	}
 
-	case *parser.UnaryExpression:
-		return nr.resolveExpression(e.Operand)
+	result := NewTableMetadata("")
+	for idx, col := range left.Columns {
+		addDerivedColumn(result, col.Name, col)
+		result.Columns[idx].Nullable = col.Nullable || right.Columns[idx].Nullable
+	}
+	nr.refs.QueryResults[stmt] = result
 
-	case *parser.FunctionCall:
-		for _, arg := range e.Arguments {
-			if err := nr.resolveExpression(arg); err != nil {
-				return err
+	// ORDER BY sorts the combined rows, so it can only refer to result
+	// columns, by name or by position
+	if stmt.OrderBy != nil {
+		for _, order := range stmt.OrderBy.Orders {
+			switch e := order.Expression.(type) {
+			case *parser.Identifier:
+				if !result.HasColumn(e.Value) {
+					return fmt.Errorf("ORDER BY term %s does not match any column in the result of %s", e.Value, op.Operator)
+				}
+			case *parser.Literal:
+				position, err := strconv.Atoi(fmt.Sprintf("%v", e.Value))
+				if err != nil || position < 1 || position > len(result.Columns) {
+					return fmt.Errorf("ORDER BY position %v is not in select list", e.Value)
+				}
+			default:
+				return fmt.Errorf("ORDER BY on a %s result must name a result column or position", op.Operator)
 			}
 		}
+	}
+	return nil
+}
 
-	case *parser.Literal:
-		// Literals don't need resolution
-		return nil
-
-	case *parser.Wildcard:
-		// Wildcard: validate table if qualified
-		if e.Table != nil {
-			tableName := e.Table.Value
-			if _, found := nr.scope.Tables[tableName]; !found {
-				// Try resolving alias
-				if realName, ok := nr.scope.Aliases[tableName]; ok {
-					if _, found := nr.scope.Tables[realName]; !found {
-						return fmt.Errorf("table not found: %s", tableName)
-					}
-				} else {
-					return fmt.Errorf("table not found: %s", tableName)
+// derivedTable builds the table named name whose columns are the select
+// list of query, which this resolver has resolved. names, when given,
+// rename the columns in order.
+func (nr *NameResolver) derivedTable(name string, query *parser.SelectStatement, names []*parser.Identifier) (*TableMetadata, error) {
+	var columnNames []string
+	var sources []*ColumnMetadata
+	if result, found := nr.refs.QueryResults[query]; found && query.SetOperation != nil {
+		for _, col := range result.Columns {
+			columnNames = append(columnNames, col.Name)
+			sources = append(sources, col)
+		}
+	}
+	for idx, expr := range selectColumns(query) {
+		if wildcard, ok := expr.(*parser.Wildcard); ok {
+			for _, source := range nr.wildcardTables(query, wildcard) {
+				for _, col := range source.Columns {
+					columnNames = append(columnNames, col.Name)
+					sources = append(sources, col)
 				}
 			}
+			continue
 		}
-		return nil
 
-	default:
-		// Other expression types don't need resolution
-		return nil
+		var source *ColumnMetadata
+		switch e := expr.(type) {
+		case *parser.Identifier:
+			source, _, _ = nr.findColumn(e.Value, "")
+		case *parser.ColumnReference:
+			tableName := ""
+			if e.Table != nil {
+				tableName = e.Table.Value
+			}
+			source, _, _ = nr.findColumn(e.Column.Value, tableName)
+		}
+		columnNames = append(columnNames, SelectColumnName(expr, idx))
+		sources = append(sources, source)
 	}
 
-	return nil
+	if len(names) > 0 {
+		if len(names) != len(columnNames) {
+			return nil, fmt.Errorf("%s has %d columns available but %d columns specified", name, len(columnNames), len(names))
+		}
+		for idx, ident := range names {
+			columnNames[idx] = ident.Value
+		}
+	}
+
+	table := NewTableMetadata(name)
+	for idx, columnName := range columnNames {
+		addDerivedColumn(table, columnName, sources[idx])
+	}
+	return table, nil
 }
 
-// resolveColumnReference resolves a column name to schema metadata
-func (nr *NameResolver) resolveColumnReference(columnName, tableName string) error {
-	// If table is specified (qualified reference)
-	if tableName != "" {
-		// Resolve table (could be alias)
-		table, found := nr.scope.Tables[tableName]
-		if !found {
-			// Try resolving alias
-			if realName, ok := nr.scope.Aliases[tableName]; ok {
-				table, found = nr.scope.Tables[realName]
-			}
+// resolveWithClause resolves the common table expressions of a WITH clause
+// and makes each visible to the ones after it and to the query. Under WITH
+// RECURSIVE a CTE's recursive term also sees the CTE itself.
+func (nr *NameResolver) resolveWithClause(with *parser.WithClause) error {
+	for _, cte := range with.CTEs {
+		name := cte.Name.Value
+		if _, found := nr.scope.CTEs[name]; found {
+			return fmt.Errorf("common table expression %s defined more than once", name)
 		}
 
-		if !found {
-			return fmt.Errorf("table not found: %s", tableName)
+		// The body sees earlier CTEs but not the query's tables
+		inner := nr.withScope(newScope(nr.scope))
+		if err := inner.ResolveSelect(cte.Query); err != nil {
+			return err
 		}
 
-		// Resolve column in table
-		col, err := table.GetColumn(columnName)
+		// The anchor term alone determines the CTE's columns
+		table, err := inner.derivedTable(name, cte.Query, cte.Columns)
 		if err != nil {
 			return err
 		}
 
-		qualifiedName := tableName + "." + columnName
-		nr.refs.AddColumn(qualifiedName, col)
+		if cte.RecursiveTerm != nil {
+			if !with.Recursive {
+				return fmt.Errorf("common table expression %s has a recursive term but WITH RECURSIVE is missing", name)
+			}
+			recursive := nr.withScope(newScope(nr.scope))
+			recursive.scope.CTEs[name] = &cteBinding{cte: cte, table: table, self: true}
+			if err := recursive.ResolveSelect(cte.RecursiveTerm); err != nil {
+				return err
+			}
+
+			// A term that never reads the CTE is not recursive: the body
+			// is an ordinary UNION, evaluated once
+			if !nr.refersToSelf(cte) {
+				cte.Query = &parser.SelectStatement{SetOperation: &parser.SetOperation{
+					Operator: parser.Union,
+					All:      cte.UnionAll,
+					Left:     cte.Query,
+					Right:    cte.RecursiveTerm,
+				}}
+				cte.RecursiveTerm, cte.UnionAll = nil, false
+
+				inner = nr.withScope(newScope(nr.scope))
+				if err := inner.ResolveSelect(cte.Query); err != nil {
+					return err
+				}
+				if table, err = inner.derivedTable(name, cte.Query, cte.Columns); err != nil {
+					return err
+				}
+				nr.refs.CTEs[cte] = table
+				nr.scope.CTEs[name] = &cteBinding{cte: cte, table: table}
+				continue
+			}
+
+			rows, err := recursive.derivedTable(name, cte.RecursiveTerm, nil)
+			if err != nil {
+				return err
+			}
+			if len(rows.Columns) != len(table.Columns) {
+				return fmt.Errorf("recursive term of %s has %d columns, but its anchor has %d",
+					name, len(rows.Columns), len(table.Columns))
+			}
+		}
+
+		nr.refs.CTEs[cte] = table
+		nr.scope.CTEs[name] = &cteBinding{cte: cte, table: table}
+	}
+	return nil
+}
+
+// resolveReturning resolves the RETURNING clause of a statement modifying
+// table, which is in scope, and records the columns of the rows it returns
+func (nr *NameResolver) resolveReturning(table *parser.Identifier, returning []parser.Expression) error {
+	if len(returning) == 0 {
+		return nil
+	}
+
+	for _, expr := range returning {
+		if err := nr.resolveExpression(expr); err != nil {
+			return err
+		}
+	}
+
+	result, err := nr.derivedTable(table.Value, returningQuery(table, returning), nil)
+	if err != nil {
+		return err
+	}
+	nr.refs.Returning = result
+	return nil
+}
+
+// returningQuery returns a query over table selecting a RETURNING list, so
+// the list names and types its columns the way a select list does
+func returningQuery(table *parser.Identifier, returning []parser.Expression) *parser.SelectStatement {
+	return &parser.SelectStatement{
+		SelectClause: &parser.SelectClause{Columns: returning},
+		FromClause:   &parser.FromClause{Tables: []parser.Expression{table}},
+	}
+}
+
+// selectColumns returns the select list of a query, which is empty for a
+// set operation
+func selectColumns(query *parser.SelectStatement) []parser.Expression {
+	if query.SelectClause == nil {
+		return nil
+	}
+	return query.SelectClause.Columns
+}
+
+// refersToSelf reports whether the recursive term of cte, which has been
+// resolved, reads the CTE itself
+func (nr *NameResolver) refersToSelf(cte *parser.CommonTableExpression) bool {
+	for _, ref := range nr.refs.CTERefs {
+		if ref.CTE == cte && ref.SelfReference {
+			return true
+		}
+	}
+	return false
+}
+
+// wildcardTables returns the tables a * or table.* in a select list expands
+// to, in FROM clause order
+func (nr *NameResolver) wildcardTables(stmt *parser.SelectStatement, wildcard *parser.Wildcard) []*TableMetadata {
+	if wildcard.Table != nil {
+		if table, found := nr.scope.lookupTable(wildcard.Table.Value); found {
+			return []*TableMetadata{table}
+		}
+		return nil
+	}
+	if stmt.FromClause == nil {
 		return nil
 	}
 
-	// Unqualified column name - search all tables in scope
-	var foundColumn *ColumnMetadata
-	var foundInTable string
-	matchCount := 0
+	items := append([]parser.Expression{}, stmt.FromClause.Tables...)
+	for _, join := range stmt.FromClause.Joins {
+		items = append(items, join.Table)
+	}
 
-	for tblName, table := range nr.scope.Tables {
-		if col, err := table.GetColumn(columnName); err == nil {
-			foundColumn = col
-			foundInTable = tblName
-			matchCount++
+	var tables []*TableMetadata
+	for _, item := range items {
+		if table, found := nr.scope.Tables[tableReferenceName(item)]; found {
+			tables = append(tables, table)
 		}
 	}
+	return tables
+}
 
-	if matchCount == 0 {
-		return fmt.Errorf("column not found: %s", columnName)
+// tableReferenceName returns the name a FROM item is referenced by
+func tableReferenceName(expr parser.Expression) string {
+	switch e := expr.(type) {
+	case *parser.Identifier:
+		if e.Alias != nil {
+			return e.Alias.Value
+		}
+		return e.Value
+	case *parser.SubqueryExpression:
+		if e.Alias != nil {
+			return e.Alias.Value
+		}
 	}
+	return ""
+}
 
-	if matchCount > 1 {
-		return fmt.Errorf("ambiguous column reference: %s", columnName)
+// SelectColumnName returns the output name of the idx-th select list entry:
+// its alias, the column it reads, or a generated name
+func SelectColumnName(expr parser.Expression, idx int) string {
+	switch e := expr.(type) {
+	case *parser.Identifier:
+		if e.Alias != nil {
+			return e.Alias.Value
+		}
+		return e.Value
+	case *parser.ColumnReference:
+		if e.Column.Alias != nil {
+			return e.Column.Alias.Value
+		}
+		return e.Column.Value
+	case *parser.SubqueryExpression:
+		if e.Alias != nil {
+			return e.Alias.Value
+		}
+	case *parser.FunctionCall:
+		return strings.ToLower(e.Name.Value)
 	}
+	return fmt.Sprintf("column%d", idx+1)
+}
 
-	// Add fully qualified name to refs
-	qualifiedName := foundInTable + "." + columnName
-	nr.refs.AddColumn(qualifiedName, foundColumn)
+// addDerivedColumn adds a column of a derived table, copying type and
+// nullability from the column it was selected from when there is one
+func addDerivedColumn(table *TableMetadata, name string, source *ColumnMetadata) {
+	column := NewColumnMetadata(name, DataTypeUnknown)
+	if source != nil {
+		column.DataType = source.DataType
+		column.Nullable = source.Nullable
+	}
+	column.TableName = table.Name
+	table.AddColumn(column)
+}
+
+// resolveSubquery resolves a subquery used in an expression. Its FROM items
+// shadow the enclosing query's tables, which stay visible for correlated
+// references.
+func (nr *NameResolver) resolveSubquery(query *parser.SelectStatement) error {
+	return nr.withScope(newScope(nr.scope)).ResolveSelect(query)
+}
+
+// resolveExpression resolves column references in an expression,
+// including those of window specifications and correlated subqueries
+func (nr *NameResolver) resolveExpression(expr parser.Expression) error {
+	if expr == nil {
+		return nil
+	}
+	return nr.resolveNode(expr)
+}
+
+// resolveNode resolves column references under a node of an expression
+func (nr *NameResolver) resolveNode(node parser.Node) error {
+	var err error
+	parser.Rewrite(node, func(c *parser.Cursor) bool {
+		if err != nil {
+			return false
+		}
+
+		switch e := c.Node().(type) {
+		case *parser.Identifier:
+			// A function's name is not a column, and neither is an alias,
+			// which is not visited
+			if call, ok := c.Parent().(*parser.FunctionCall); ok && call.Name == e {
+				return false
+			}
+			err = errorAt(e, nr.resolveColumnReference(e.Value, ""))
+			return false
+
+		case *parser.ColumnReference:
+			// Qualified column reference (table.column)
+			tableName := ""
+			if e.Table != nil {
+				tableName = e.Table.Value
+			}
+			err = errorAt(e, nr.resolveColumnReference(e.Column.Value, tableName))
+			return false
+
+		case *parser.Wildcard:
+			// Wildcard: validate table if qualified
+			if e.Table != nil {
+				if _, found := nr.scope.lookupTable(e.Table.Value); !found {
+					err = errorAt(e.Table, fmt.Errorf("table not found: %s", e.Table.Value))
+				}
+			}
+			return false
+
+		case *parser.SubqueryExpression:
+			err = nr.resolveSubquery(e.Query)
+			return false
+
+		case *parser.ExistsExpression:
+			err = nr.resolveSubquery(e.Query)
+			return false
+
+		case *parser.WindowSpec:
+			// The walk visits only the name of a named window; its parts,
+			// copied from the WINDOW clause, are resolved as written there
+			if e.Name != nil {
+				err = nr.resolveNode(&parser.WindowSpec{PartitionBy: e.PartitionBy, OrderBy: e.OrderBy, Frame: e.Frame})
+				return false
+			}
+		}
+		return true
+	}, nil)
+	return err
+}
+
+// resolveColumnReference resolves a column name to schema metadata
+func (nr *NameResolver) resolveColumnReference(columnName, tableName string) error {
+	col, qualifiedName, err := nr.findColumn(columnName, tableName)
+	if err != nil {
+		return err
+	}
 
+	// Add fully qualified name to refs
+	nr.refs.AddColumn(qualifiedName, col)
 	return nil
 }
+
+// findColumn looks a column up in the current scope and then in the scopes
+// of enclosing queries, so inner tables shadow outer ones. It returns the
+// column and its name qualified by the table it was found in.
+func (nr *NameResolver) findColumn(columnName, tableName string) (*ColumnMetadata, string, error) {
+	for scope := nr.scope; scope != nil; scope = scope.Parent {
+		// If table is specified (qualified reference)
+		if tableName != "" {
+			table, found := scope.lookupTable(tableName)
+			if !found {
+				continue
+			}
+
+			// Resolve column in table
+			col, err := table.GetColumn(columnName)
+			if err != nil {
+				return nil, "", err
+			}
+			return col, tableName + "." + columnName, nil
+		}
+
+		// Unqualified column name - search all tables in scope
+		var foundColumn *ColumnMetadata
+		var foundInTable string
+		matchCount := 0
+
+		for tblName, table := range scope.Tables {
+			if col, err := table.GetColumn(columnName); err == nil {
+				foundColumn = col
+				foundInTable = tblName
+				matchCount++
+			}
+		}
+
+		if matchCount > 1 {
+			return nil, "", fmt.Errorf("ambiguous column reference: %s", columnName)
+		}
+		if matchCount == 1 {
+			return foundColumn, foundInTable + "." + columnName, nil
+		}
+	}
+
+	if tableName != "" {
+		return nil, "", fmt.Errorf("table not found: %s", tableName)
+	}
+	return nil, "", fmt.Errorf("column not found: %s", columnName)
+}
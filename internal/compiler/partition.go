@@ -0,0 +1,294 @@
+package compiler
+
+import (
+	"fmt"
+	"hash/fnv"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+
+	"relational-db/internal/lexer"
+	"relational-db/internal/parser"
+)
+
+// PartitionScheme describes how a partitioned table spreads its rows
+// across child partitions
+type PartitionScheme struct {
+	Method     parser.PartitionMethod
+	Column     string
+	Partitions []*PartitionBound
+}
+
+// PartitionBound describes the rows stored in one partition
+type PartitionBound struct {
+	Name     string
+	LessThan interface{}   // RANGE: exclusive upper bound, nil for MAXVALUE
+	Values   []interface{} // LIST: values stored in this partition
+}
+
+// PartitionSchemeFromSpec builds and validates the partition scheme declared
+// by a PARTITION BY clause
+func PartitionSchemeFromSpec(tableName string, spec *parser.PartitionSpec) (*PartitionScheme, error) {
+	scheme := &PartitionScheme{
+		Method: spec.Method,
+		Column: spec.Column.Value,
+	}
+
+	// HASH ... PARTITIONS n names its partitions after the table
+	if len(spec.Partitions) == 0 {
+		if spec.Method != parser.PartitionByHash || spec.Count <= 0 {
+			return nil, fmt.Errorf("partitioned table %s must declare at least one partition", tableName)
+		}
+		for i := 0; i < spec.Count; i++ {
+			scheme.Partitions = append(scheme.Partitions, &PartitionBound{Name: fmt.Sprintf("%s_p%d", tableName, i)})
+		}
+		return scheme, nil
+	}
+
+	names := make(map[string]bool)
+	listValues := make(map[string]string)
+	for i, def := range spec.Partitions {
+		name := def.Name.Value
+		if names[name] {
+			return nil, fmt.Errorf("duplicate partition name %s", name)
+		}
+		names[name] = true
+
+		bound := &PartitionBound{Name: name}
+		switch spec.Method {
+		case parser.PartitionByRange:
+			if def.MaxValue {
+				if i != len(spec.Partitions)-1 {
+					return nil, fmt.Errorf("MAXVALUE partition %s must be the last partition", name)
+				}
+				break
+			}
+			value, err := partitionLiteral(def.LessThan)
+			if err != nil {
+				return nil, fmt.Errorf("partition %s: %w", name, err)
+			}
+			if i > 0 && comparePartitionValues(value, scheme.Partitions[i-1].LessThan) <= 0 {
+				return nil, fmt.Errorf("partition %s: range bounds must be strictly increasing", name)
+			}
+			bound.LessThan = value
+
+		case parser.PartitionByList:
+			for _, expr := range def.Values {
+				value, err := partitionLiteral(expr)
+				if err != nil {
+					return nil, fmt.Errorf("partition %s: %w", name, err)
+				}
+				key := fmt.Sprintf("%v", value)
+				if other, exists := listValues[key]; exists {
+					return nil, fmt.Errorf("value %v appears in partitions %s and %s", value, other, name)
+				}
+				listValues[key] = name
+				bound.Values = append(bound.Values, value)
+			}
+		}
+
+		scheme.Partitions = append(scheme.Partitions, bound)
+	}
+
+	return scheme, nil
+}
+
+// PartitionNames returns the names of all partitions in declaration order
+func (ps *PartitionScheme) PartitionNames() []string {
+	names := make([]string, len(ps.Partitions))
+	for i, partition := range ps.Partitions {
+		names[i] = partition.Name
+	}
+	return names
+}
+
+// Route returns the partition that stores rows with the given partition key
+func (ps *PartitionScheme) Route(value interface{}) (string, error) {
+	switch ps.Method {
+	case parser.PartitionByRange:
+		// NULL sorts below every bound and lands in the first partition
+		for _, partition := range ps.Partitions {
+			if partition.LessThan == nil || value == nil || comparePartitionValues(value, partition.LessThan) < 0 {
+				return partition.Name, nil
+			}
+		}
+
+	case parser.PartitionByList:
+		for _, partition := range ps.Partitions {
+			for _, v := range partition.Values {
+				if comparePartitionValues(value, v) == 0 {
+					return partition.Name, nil
+				}
+			}
+		}
+
+	case parser.PartitionByHash:
+		return ps.Partitions[partitionHash(value)%uint32(len(ps.Partitions))].Name, nil
+	}
+
+	return "", fmt.Errorf("no partition for %s = %v", ps.Column, value)
+}
+
+// Prune returns the partitions that may hold rows whose partition key lies
+// in the given range. A nil bound leaves that end of the range open.
+func (ps *PartitionScheme) Prune(lower, upper interface{}, lowerInclusive, upperInclusive bool) []string {
+	inRange := func(v interface{}) bool {
+		if lower != nil {
+			if cmp := comparePartitionValues(v, lower); cmp < 0 || (cmp == 0 && !lowerInclusive) {
+				return false
+			}
+		}
+		if upper != nil {
+			if cmp := comparePartitionValues(v, upper); cmp > 0 || (cmp == 0 && !upperInclusive) {
+				return false
+			}
+		}
+		return true
+	}
+
+	var names []string
+	switch ps.Method {
+	case parser.PartitionByRange:
+		// Partition i holds [bound(i-1), bound(i))
+		var start interface{}
+		for _, partition := range ps.Partitions {
+			overlaps := true
+			if upper != nil && start != nil {
+				if cmp := comparePartitionValues(upper, start); cmp < 0 || (cmp == 0 && !upperInclusive) {
+					overlaps = false
+				}
+			}
+			if lower != nil && partition.LessThan != nil && comparePartitionValues(lower, partition.LessThan) >= 0 {
+				overlaps = false
+			}
+			if overlaps {
+				names = append(names, partition.Name)
+			}
+			start = partition.LessThan
+		}
+
+	case parser.PartitionByList:
+		for _, partition := range ps.Partitions {
+			for _, v := range partition.Values {
+				if inRange(v) {
+					names = append(names, partition.Name)
+					break
+				}
+			}
+		}
+
+	case parser.PartitionByHash:
+		// Hashing destroys order: only an equality can be pruned
+		if lower != nil && upper != nil && lowerInclusive && upperInclusive && comparePartitionValues(lower, upper) == 0 {
+			name, _ := ps.Route(lower)
+			return []string{name}
+		}
+		return ps.PartitionNames()
+	}
+
+	return names
+}
+
+// partitionLiteral converts a partition bound expression to a value
+func partitionLiteral(expr parser.Expression) (interface{}, error) {
+	switch e := expr.(type) {
+	case *parser.Literal:
+		text := fmt.Sprintf("%v", e.Value)
+		if e.Type == lexer.NUMBER {
+			if i, err := strconv.ParseInt(text, 10, 64); err == nil {
+				return i, nil
+			}
+			if f, err := strconv.ParseFloat(text, 64); err == nil {
+				return f, nil
+			}
+		}
+		return text, nil
+	case *parser.UnaryExpression:
+		if e.Operator == parser.UnaryMinus {
+			value, err := partitionLiteral(e.Operand)
+			if err != nil {
+				return nil, err
+			}
+			switch v := value.(type) {
+			case int64:
+				return -v, nil
+			case float64:
+				return -v, nil
+			}
+		}
+	}
+	return nil, fmt.Errorf("partition bound must be a literal, got %v", expr)
+}
+
+// comparePartitionValues orders partition key values. Numbers compare
+// numerically across integer and float types; NULL sorts first.
+func comparePartitionValues(a, b interface{}) int {
+	if a == nil || b == nil {
+		switch {
+		case a == nil && b == nil:
+			return 0
+		case a == nil:
+			return -1
+		default:
+			return 1
+		}
+	}
+
+	if af, ok := partitionNumber(a); ok {
+		if bf, ok := partitionNumber(b); ok {
+			switch {
+			case af < bf:
+				return -1
+			case af > bf:
+				return 1
+			default:
+				return 0
+			}
+		}
+	}
+
+	if at, ok := a.(time.Time); ok {
+		if bt, ok := b.(time.Time); ok {
+			return at.Compare(bt)
+		}
+		// Timestamp keys against string bounds such as '2024-01-01'
+		a = at.Format(time.RFC3339)
+	}
+
+	return strings.Compare(fmt.Sprintf("%v", a), fmt.Sprintf("%v", b))
+}
+
+// partitionNumber converts numeric values to float64
+func partitionNumber(v interface{}) (float64, bool) {
+	switch n := v.(type) {
+	case int:
+		return float64(n), true
+	case int32:
+		return float64(n), true
+	case int64:
+		return float64(n), true
+	case uint32:
+		return float64(n), true
+	case uint64:
+		return float64(n), true
+	case float32:
+		return float64(n), true
+	case float64:
+		return n, true
+	default:
+		return 0, false
+	}
+}
+
+// partitionHash hashes a partition key so that equal numbers hash alike
+// regardless of their Go type
+func partitionHash(value interface{}) uint32 {
+	if f, ok := partitionNumber(value); ok && f == math.Trunc(f) {
+		value = int64(f)
+	}
+
+	h := fnv.New32a()
+	fmt.Fprintf(h, "%v", value)
+	return h.Sum32()
+}
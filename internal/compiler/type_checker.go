@@ -4,6 +4,7 @@ import (
 	"fmt"
 	"relational-db/internal/lexer"
 	"relational-db/internal/parser"
+	"strconv"
 	"strings"
 )
 
@@ -23,6 +24,35 @@ func NewTypeChecker(refs *ResolvedReferences, typeInfo *TypeInformation) *TypeCh
 
 // CheckSelect checks types in a SELECT statement
 func (tc *TypeChecker) CheckSelect(stmt *parser.SelectStatement) error {
+	// Type check common table expressions; a recursive term may depend on
+	// the column types its anchor gives the CTE
+	if stmt.With != nil {
+		for _, cte := range stmt.With.CTEs {
+			if err := tc.CheckSelect(cte.Query); err != nil {
+				return err
+			}
+			if table, found := tc.refs.CTEs[cte]; found {
+				if err := tc.inferDerivedColumns(table, cte.Query); err != nil {
+					return err
+				}
+			}
+			if cte.RecursiveTerm != nil {
+				if err := tc.CheckSelect(cte.RecursiveTerm); err != nil {
+					return err
+				}
+			}
+		}
+	}
+
+	if stmt.SetOperation != nil {
+		return tc.checkSetOperation(stmt)
+	}
+
+	// Type check derived tables in FROM
+	if err := tc.checkFromClause(stmt.FromClause); err != nil {
+		return err
+	}
+
 	// Type check all SELECT columns
 	if stmt.SelectClause != nil {
 		for _, col := range stmt.SelectClause.Columns {
@@ -41,7 +71,7 @@ func (tc *TypeChecker) CheckSelect(stmt *parser.SelectStatement) error {
 
 		// WHERE must be BOOLEAN
 		if whereType != DataTypeBoolean && whereType != DataTypeUnknown {
-			return fmt.Errorf("WHERE clause must be boolean, got %s", whereType)
+			return errorAt(stmt.WhereClause.Condition, fmt.Errorf("WHERE clause must be boolean, got %s", whereType))
 		}
 	}
 
@@ -54,7 +84,7 @@ func (tc *TypeChecker) CheckSelect(stmt *parser.SelectStatement) error {
 
 		// HAVING must be BOOLEAN
 		if havingType != DataTypeBoolean && havingType != DataTypeUnknown {
-			return fmt.Errorf("HAVING clause must be boolean, got %s", havingType)
+			return errorAt(stmt.Having.Condition, fmt.Errorf("HAVING clause must be boolean, got %s", havingType))
 		}
 	}
 
@@ -63,18 +93,61 @@ func (tc *TypeChecker) CheckSelect(stmt *parser.SelectStatement) error {
 
 // CheckInsert checks types in an INSERT statement
 func (tc *TypeChecker) CheckInsert(stmt *parser.InsertStatement) error {
-	// TODO: Implement INSERT type checking
-	// Check that value types match column types
-	return nil
+	// TODO: Check that value types match column types
+	columns := make([]*ColumnMetadata, len(stmt.Columns))
+	if table, found := tc.refs.Tables[stmt.TableName.Value]; found {
+		if len(stmt.Columns) == 0 {
+			columns = table.Columns
+		}
+		for i, col := range stmt.Columns {
+			columns[i], _ = table.GetColumn(col.Value)
+		}
+	}
+
+	for _, row := range stmt.Values {
+		for i, value := range row {
+			// Parameters take the type of the column they are inserted into
+			if i < len(columns) && columns[i] != nil {
+				if err := tc.bindParameter(value, columns[i].DataType); err != nil {
+					return err
+				}
+			}
+			if _, err := tc.inferExpressionType(value); err != nil {
+				return err
+			}
+		}
+	}
+
+	if stmt.Query != nil {
+		if err := tc.CheckSelect(stmt.Query); err != nil {
+			return err
+		}
+	}
+
+	if conflict := stmt.OnConflict; conflict != nil {
+		table := tc.refs.Tables[stmt.TableName.Value]
+		if err := tc.checkSetClauses(table, conflict.SetClauses); err != nil {
+			return err
+		}
+		if conflict.WhereClause != nil {
+			if err := tc.checkCondition("ON CONFLICT WHERE", conflict.WhereClause.Condition); err != nil {
+				return err
+			}
+		}
+	}
+
+	return tc.checkReturning(stmt.TableName, stmt.Returning)
 }
 
 // CheckUpdate checks types in an UPDATE statement
 func (tc *TypeChecker) CheckUpdate(stmt *parser.UpdateStatement) error {
+	if err := tc.checkFromClause(stmt.From); err != nil {
+		return err
+	}
+
 	// Type check SET clause values
-	for _, setClause := range stmt.SetClauses {
-		if _, err := tc.inferExpressionType(setClause.Value); err != nil {
-			return err
-		}
+	if err := tc.checkSetClauses(tc.refs.Tables[stmt.TableName.Value], stmt.SetClauses); err != nil {
+		return err
 	}
 
 	// Type check WHERE clause
@@ -86,15 +159,19 @@ func (tc *TypeChecker) CheckUpdate(stmt *parser.UpdateStatement) error {
 
 		// WHERE must be BOOLEAN
 		if whereType != DataTypeBoolean && whereType != DataTypeUnknown {
-			return fmt.Errorf("WHERE clause must be boolean, got %s", whereType)
+			return errorAt(stmt.WhereClause.Condition, fmt.Errorf("WHERE clause must be boolean, got %s", whereType))
 		}
 	}
 
-	return nil
+	return tc.checkReturning(stmt.TableName, stmt.Returning)
 }
 
 // CheckDelete checks types in a DELETE statement
 func (tc *TypeChecker) CheckDelete(stmt *parser.DeleteStatement) error {
+	if err := tc.checkFromClause(stmt.Using); err != nil {
+		return err
+	}
+
 	// Type check WHERE clause
 	if stmt.WhereClause != nil && stmt.WhereClause.Condition != nil {
 		whereType, err := tc.inferExpressionType(stmt.WhereClause.Condition)
@@ -104,10 +181,122 @@ func (tc *TypeChecker) CheckDelete(stmt *parser.DeleteStatement) error {
 
 		// WHERE must be BOOLEAN
 		if whereType != DataTypeBoolean && whereType != DataTypeUnknown {
-			return fmt.Errorf("WHERE clause must be boolean, got %s", whereType)
+			return errorAt(stmt.WhereClause.Condition, fmt.Errorf("WHERE clause must be boolean, got %s", whereType))
+		}
+	}
+
+	return tc.checkReturning(stmt.TableName, stmt.Returning)
+}
+
+// CheckMerge checks types in a MERGE statement. Values take the type of the
+// target column they are stored in.
+func (tc *TypeChecker) CheckMerge(stmt *parser.MergeStatement) error {
+	if subquery, ok := stmt.Source.(*parser.SubqueryExpression); ok {
+		if err := tc.checkDerivedTable(subquery); err != nil {
+			return err
+		}
+	}
+	if err := tc.checkCondition("MERGE ON", stmt.Condition); err != nil {
+		return err
+	}
+
+	targetName := stmt.Target.Value
+	if stmt.Target.Alias != nil {
+		targetName = stmt.Target.Alias.Value
+	}
+	table := tc.refs.Tables[targetName]
+
+	for _, clause := range stmt.Clauses {
+		if clause.Condition != nil {
+			if err := tc.checkCondition("WHEN", clause.Condition); err != nil {
+				return err
+			}
+		}
+		if err := tc.checkSetClauses(table, clause.SetClauses); err != nil {
+			return err
+		}
+
+		columns := make([]*ColumnMetadata, len(clause.Columns))
+		if table != nil {
+			if len(clause.Columns) == 0 {
+				columns = table.Columns
+			}
+			for i, col := range clause.Columns {
+				columns[i], _ = table.GetColumn(col.Value)
+			}
+		}
+		for i, value := range clause.Values {
+			if i < len(columns) && columns[i] != nil {
+				if err := tc.bindParameter(value, columns[i].DataType); err != nil {
+					return err
+				}
+			}
+			if _, err := tc.inferExpressionType(value); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+// checkSetClauses checks the values of SET clauses assigning columns of
+// table; parameters take the type of their column
+func (tc *TypeChecker) checkSetClauses(table *TableMetadata, setClauses []*parser.SetClause) error {
+	for _, setClause := range setClauses {
+		if table != nil {
+			if col, err := table.GetColumn(setClause.Column.Value); err == nil {
+				if err := tc.bindParameter(setClause.Value, col.DataType); err != nil {
+					return err
+				}
+			}
+		}
+		if _, err := tc.inferExpressionType(setClause.Value); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// checkFromClause type checks the derived tables and join conditions of a
+// FROM clause
+func (tc *TypeChecker) checkFromClause(from *parser.FromClause) error {
+	if from == nil {
+		return nil
+	}
+
+	items := append([]parser.Expression{}, from.Tables...)
+	for _, join := range from.Joins {
+		items = append(items, join.Table)
+	}
+	for _, item := range items {
+		if subquery, ok := item.(*parser.SubqueryExpression); ok {
+			if err := tc.checkDerivedTable(subquery); err != nil {
+				return err
+			}
 		}
 	}
 
+	for _, join := range from.Joins {
+		if join.Condition == nil {
+			continue
+		}
+		if err := tc.checkCondition("JOIN", join.Condition); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// checkCondition checks that a clause's condition is boolean
+func (tc *TypeChecker) checkCondition(clause string, condition parser.Expression) error {
+	conditionType, err := tc.inferExpressionType(condition)
+	if err != nil {
+		return err
+	}
+	if conditionType != DataTypeBoolean && conditionType != DataTypeUnknown {
+		return errorAt(condition, fmt.Errorf("%s condition must be boolean, got %s", clause, conditionType))
+	}
 	return nil
 }
 
@@ -132,8 +321,56 @@ func (tc *TypeChecker) CheckCreateTable(stmt *parser.CreateTableStatement) error
 	return nil
 }
 
-// inferExpressionType infers the type of an expression
+// CheckAlterTable checks that added columns get defaults of their type and
+// that CHECK constraints are conditions
+func (tc *TypeChecker) CheckAlterTable(stmt *parser.AlterTableStatement) error {
+	for _, action := range stmt.Actions {
+		switch action := action.(type) {
+		case *parser.AddColumnAction:
+			columnType, _ := DataTypeFromName(action.Column.DataType.Name)
+			for _, constraint := range action.Column.Constraints {
+				if constraint.Type != parser.Default || constraint.DefaultValue == nil {
+					continue
+				}
+				defaultType, err := tc.inferExpressionType(constraint.DefaultValue)
+				if err != nil {
+					return fmt.Errorf("invalid DEFAULT value: %v", err)
+				}
+				// Dates and timestamps are written as strings
+				temporal := defaultType == DataTypeText && (columnType == DataTypeDate || columnType == DataTypeTimestamp)
+				if columnType != DataTypeUnknown && defaultType != DataTypeUnknown && !temporal && !defaultType.CanCoerceTo(columnType) {
+					return fmt.Errorf("DEFAULT of type %s cannot be stored in column %s of type %s", defaultType, action.Column.Name.Value, columnType)
+				}
+			}
+
+		case *parser.AddConstraintAction:
+			if action.Constraint.Type != parser.Check {
+				continue
+			}
+			checkType, err := tc.inferExpressionType(action.Constraint.Check)
+			if err != nil {
+				return err
+			}
+			if checkType != DataTypeBoolean && checkType != DataTypeUnknown {
+				return fmt.Errorf("CHECK constraint must be boolean, got %s", checkType)
+			}
+		}
+	}
+	return nil
+}
+
+// inferExpressionType infers the type of an expression. An error is
+// located at the innermost expression that caused it.
 func (tc *TypeChecker) inferExpressionType(expr parser.Expression) (DataType, error) {
+	dataType, err := tc.inferType(expr)
+	if err != nil {
+		return DataTypeUnknown, errorAt(expr, err)
+	}
+	return dataType, nil
+}
+
+// inferType infers the type of an expression of any kind
+func (tc *TypeChecker) inferType(expr parser.Expression) (DataType, error) {
 	if expr == nil {
 		return DataTypeUnknown, nil
 	}
@@ -157,29 +394,214 @@ func (tc *TypeChecker) inferExpressionType(expr parser.Expression) (DataType, er
 	case *parser.FunctionCall:
 		return tc.inferFunctionType(e)
 
+	case *parser.IsNullExpression:
+		if _, err := tc.inferExpressionType(e.Expr); err != nil {
+			return DataTypeUnknown, err
+		}
+		return DataTypeBoolean, nil
+
+	case *parser.BetweenExpression:
+		return tc.inferBetweenType(e)
+
+	case *parser.InListExpression:
+		return tc.inferInListType(e)
+
+	case *parser.LikeExpression:
+		return tc.inferLikeType(e)
+
+	case *parser.CaseExpression:
+		return tc.inferCaseType(e)
+
+	case *parser.CastExpression:
+		return tc.inferCastType(e)
+
 	case *parser.Wildcard:
 		// Wildcard doesn't have a specific type
 		return DataTypeUnknown, nil
 
+	case *parser.SubqueryExpression:
+		return tc.inferSubqueryType(e.Query)
+
+	case *parser.ExistsExpression:
+		if err := tc.CheckSelect(e.Query); err != nil {
+			return DataTypeUnknown, err
+		}
+		return DataTypeBoolean, nil
+
+	case *parser.Parameter:
+		// Unknown until the surrounding expression pins it down
+		if dt, found := tc.typeInfo.GetParameterType(e.Index); found {
+			return dt, nil
+		}
+		return DataTypeUnknown, nil
+
 	default:
 		return DataTypeUnknown, nil
 	}
 }
 
+// CheckCreateView checks types in the query of a CREATE VIEW statement and
+// fills in the types of the view's columns
+func (tc *TypeChecker) CheckCreateView(stmt *parser.CreateViewStatement) error {
+	if err := tc.CheckSelect(stmt.Query); err != nil {
+		return err
+	}
+	if tc.refs.ViewColumns == nil {
+		return nil
+	}
+	return tc.inferDerivedColumns(tc.refs.ViewColumns, stmt.Query)
+}
+
+// checkReturning checks types in the RETURNING clause of a statement
+// modifying table and fills in the types of the columns it returns
+func (tc *TypeChecker) checkReturning(table *parser.Identifier, returning []parser.Expression) error {
+	for _, expr := range returning {
+		if _, err := tc.inferExpressionType(expr); err != nil {
+			return err
+		}
+	}
+	if tc.refs.Returning == nil {
+		return nil
+	}
+	return tc.inferDerivedColumns(tc.refs.Returning, returningQuery(table, returning))
+}
+
+// checkDerivedTable type checks a subquery in FROM and fills in the types
+// of derived table columns computed by expressions
+func (tc *TypeChecker) checkDerivedTable(subquery *parser.SubqueryExpression) error {
+	if err := tc.CheckSelect(subquery.Query); err != nil {
+		return err
+	}
+	if subquery.Alias == nil {
+		return nil
+	}
+
+	table, found := tc.refs.Tables[subquery.Alias.Value]
+	if !found {
+		return nil
+	}
+	return tc.inferDerivedColumns(table, subquery.Query)
+}
+
+// inferDerivedColumns fills in the types of a derived table's columns that
+// name resolution could not take from a source column, from the select
+// list of the query producing them
+func (tc *TypeChecker) inferDerivedColumns(table *TableMetadata, query *parser.SelectStatement) error {
+	// A set operation's column types were settled when it was checked
+	if query.SetOperation != nil {
+		if result, found := tc.refs.QueryResults[query]; found && result != table {
+			for idx, col := range result.Columns {
+				if idx < len(table.Columns) {
+					table.Columns[idx].DataType = col.DataType
+				}
+			}
+		}
+		return nil
+	}
+
+	columns := query.SelectClause.Columns
+	for _, expr := range columns {
+		// A wildcard shifts the positions of the columns after it
+		if _, ok := expr.(*parser.Wildcard); ok {
+			return nil
+		}
+	}
+
+	for idx, expr := range columns {
+		if idx >= len(table.Columns) || table.Columns[idx].DataType != DataTypeUnknown {
+			continue
+		}
+		dataType, err := tc.inferExpressionType(expr)
+		if err != nil {
+			return err
+		}
+		table.Columns[idx].DataType = dataType
+	}
+	return nil
+}
+
+// checkSetOperation type checks the queries a set operation combines and
+// gives each result column a type both of theirs can be converted to
+func (tc *TypeChecker) checkSetOperation(stmt *parser.SelectStatement) error {
+	op := stmt.SetOperation
+	for _, query := range []*parser.SelectStatement{op.Left, op.Right} {
+		if err := tc.CheckSelect(query); err != nil {
+			return err
+		}
+		if table, found := tc.refs.QueryResults[query]; found {
+			if err := tc.inferDerivedColumns(table, query); err != nil {
+				return err
+			}
+		}
+	}
+
+	result, found := tc.refs.QueryResults[stmt]
+	left, leftFound := tc.refs.QueryResults[op.Left]
+	right, rightFound := tc.refs.QueryResults[op.Right]
+	if !found || !leftFound || !rightFound {
+		return nil
+	}
+
+	for idx, col := range result.Columns {
+		leftType, rightType := left.Columns[idx].DataType, right.Columns[idx].DataType
+		dataType, ok := commonType(leftType, rightType)
+		if !ok {
+			return fmt.Errorf("%s types %s and %s cannot be matched", op.Operator, leftType, rightType)
+		}
+		col.DataType = dataType
+	}
+	return nil
+}
+
+// commonType returns the type values of two types are converted to when
+// they make up one column or result, as in a set operation or the branches
+// of a CASE, and false if there is none
+func commonType(left, right DataType) (DataType, bool) {
+	switch {
+	case left == DataTypeUnknown || left == DataTypeNull:
+		return right, true
+	case right == DataTypeUnknown || right == DataTypeNull:
+		return left, true
+	case right.CanCoerceTo(left):
+		return left, true
+	case left.CanCoerceTo(right):
+		return right, true
+	case left.IsNumeric() && right.IsNumeric():
+		return DataTypeNumeric, true
+	}
+	return DataTypeUnknown, false
+}
+
+// inferSubqueryType type checks a subquery and returns the type of the
+// value it produces: the type of its first select list entry
+func (tc *TypeChecker) inferSubqueryType(query *parser.SelectStatement) (DataType, error) {
+	if err := tc.CheckSelect(query); err != nil {
+		return DataTypeUnknown, err
+	}
+	if query.SetOperation != nil {
+		if result, found := tc.refs.QueryResults[query]; found && len(result.Columns) > 0 {
+			return result.Columns[0].DataType, nil
+		}
+		return DataTypeUnknown, nil
+	}
+	if query.SelectClause == nil || len(query.SelectClause.Columns) == 0 {
+		return DataTypeUnknown, nil
+	}
+	return tc.inferExpressionType(query.SelectClause.Columns[0])
+}
+
 // inferLiteralType infers the type of a literal
 func (tc *TypeChecker) inferLiteralType(lit *parser.Literal) (DataType, error) {
 	switch lit.Type {
 	case lexer.NUMBER:
 		// Check if it's an integer or real number based on value
-		switch lit.Value.(type) {
+		switch v := lit.Value.(type) {
 		case int, int64, int32:
 			return DataTypeInteger, nil
 		case float64, float32:
 			return DataTypeReal, nil
 		case string:
-			// Parse string to determine if integer or float
-			// For now, assume integer (more sophisticated parsing needed)
-			return DataTypeInteger, nil
+			return numberLiteralType(v), nil
 		default:
 			return DataTypeNumeric, nil
 		}
@@ -187,9 +609,15 @@ func (tc *TypeChecker) inferLiteralType(lit *parser.Literal) (DataType, error) {
 	case lexer.STRING:
 		return DataTypeText, nil
 
+	case lexer.HEX_STRING:
+		return DataTypeBlob, nil
+
 	case lexer.NULL:
 		return DataTypeNull, nil
 
+	case lexer.TRUE, lexer.FALSE:
+		return DataTypeBoolean, nil
+
 	default:
 		// Check if it's a boolean value by examining the value
 		if v, ok := lit.Value.(bool); ok {
@@ -208,6 +636,22 @@ func (tc *TypeChecker) inferLiteralType(lit *parser.Literal) (DataType, error) {
 	}
 }
 
+// numberLiteralType returns the type of a number written as text: 1.5e10
+// is an approximate REAL, and 1.5, like an integer too large for INTEGER,
+// is an exact NUMERIC
+func numberLiteralType(text string) DataType {
+	switch {
+	case strings.ContainsAny(text, "eE"):
+		return DataTypeReal
+	case strings.Contains(text, "."):
+		return DataTypeNumeric
+	}
+	if _, err := strconv.ParseInt(text, 10, 64); err != nil {
+		return DataTypeNumeric
+	}
+	return DataTypeInteger
+}
+
 // inferIdentifierType infers the type of an identifier (column reference)
 func (tc *TypeChecker) inferIdentifierType(ident *parser.Identifier) (DataType, error) {
 	// Try to find the column in resolved references
@@ -281,6 +725,19 @@ func (tc *TypeChecker) inferBinaryType(bin *parser.BinaryExpression) (DataType,
 		return DataTypeUnknown, err
 	}
 
+	// A parameter takes the type of the operand it is combined with
+	switch bin.Operator {
+	case parser.And, parser.Or:
+		leftType, rightType, err = tc.bindOperands(bin, DataTypeBoolean, DataTypeBoolean)
+	case parser.Like:
+		leftType, rightType, err = tc.bindOperands(bin, DataTypeText, DataTypeText)
+	default:
+		leftType, rightType, err = tc.bindOperands(bin, rightType, leftType)
+	}
+	if err != nil {
+		return DataTypeUnknown, err
+	}
+
 	// Check operator type
 	switch bin.Operator {
 	case parser.Plus, parser.Minus, parser.Multiply, parser.Divide, parser.Modulo:
@@ -317,6 +774,46 @@ func (tc *TypeChecker) inferBinaryType(bin *parser.BinaryExpression) (DataType,
 	}
 }
 
+// bindOperands binds parameter operands of a binary expression to the given
+// types and returns the resulting operand types
+func (tc *TypeChecker) bindOperands(bin *parser.BinaryExpression, leftType, rightType DataType) (DataType, DataType, error) {
+	if err := tc.bindParameter(bin.Left, leftType); err != nil {
+		return DataTypeUnknown, DataTypeUnknown, err
+	}
+	if err := tc.bindParameter(bin.Right, rightType); err != nil {
+		return DataTypeUnknown, DataTypeUnknown, err
+	}
+
+	left, err := tc.inferExpressionType(bin.Left)
+	if err != nil {
+		return DataTypeUnknown, DataTypeUnknown, err
+	}
+	right, err := tc.inferExpressionType(bin.Right)
+	if err != nil {
+		return DataTypeUnknown, DataTypeUnknown, err
+	}
+	return left, right, nil
+}
+
+// bindParameter records the type of a parameter from its context. A
+// parameter used in several places must get compatible types.
+func (tc *TypeChecker) bindParameter(expr parser.Expression, dataType DataType) error {
+	param, ok := expr.(*parser.Parameter)
+	if !ok || dataType == DataTypeUnknown || dataType == DataTypeNull {
+		return nil
+	}
+
+	existing, found := tc.typeInfo.GetParameterType(param.Index)
+	if !found || existing == DataTypeUnknown {
+		tc.typeInfo.SetParameterType(param.Index, dataType)
+		return nil
+	}
+	if !existing.IsComparable(dataType) {
+		return fmt.Errorf("parameter %d is used as both %s and %s", param.Index, existing, dataType)
+	}
+	return nil
+}
+
 // inferArithmeticType infers the result type of arithmetic operations
 func (tc *TypeChecker) inferArithmeticType(left, right DataType) (DataType, error) {
 	// INTEGER op INTEGER = INTEGER
@@ -372,9 +869,218 @@ func (tc *TypeChecker) inferUnaryType(unary *parser.UnaryExpression) (DataType,
 	}
 }
 
+// inferCaseType checks the WHEN branches of a CASE expression and infers
+// the type of its result, which every THEN and ELSE value must convert to
+func (tc *TypeChecker) inferCaseType(expr *parser.CaseExpression) (DataType, error) {
+	operandType, err := tc.inferExpressionType(expr.Operand)
+	if err != nil {
+		return DataTypeUnknown, err
+	}
+
+	resultType := DataTypeNull
+	results := make([]parser.Expression, 0, len(expr.Whens)+1)
+	for _, when := range expr.Whens {
+		if expr.Operand != nil {
+			// Simple CASE: each WHEN value is compared with the operand
+			if err := tc.bindParameter(when.Condition, operandType); err != nil {
+				return DataTypeUnknown, err
+			}
+			whenType, err := tc.inferExpressionType(when.Condition)
+			if err != nil {
+				return DataTypeUnknown, err
+			}
+			if !operandType.IsComparable(whenType) && operandType != DataTypeUnknown && whenType != DataTypeUnknown {
+				return DataTypeUnknown, fmt.Errorf("CASE operand of type %s cannot be compared with WHEN value of type %s", operandType, whenType)
+			}
+		} else {
+			if err := tc.bindParameter(when.Condition, DataTypeBoolean); err != nil {
+				return DataTypeUnknown, err
+			}
+			conditionType, err := tc.inferExpressionType(when.Condition)
+			if err != nil {
+				return DataTypeUnknown, err
+			}
+			if conditionType != DataTypeBoolean && conditionType != DataTypeUnknown && conditionType != DataTypeNull {
+				return DataTypeUnknown, fmt.Errorf("WHEN condition must be boolean, got %s", conditionType)
+			}
+		}
+		results = append(results, when.Result)
+	}
+	if expr.Else != nil {
+		results = append(results, expr.Else)
+	}
+
+	for _, result := range results {
+		branchType, err := tc.inferExpressionType(result)
+		if err != nil {
+			return DataTypeUnknown, err
+		}
+		combined, ok := commonType(resultType, branchType)
+		if !ok {
+			return DataTypeUnknown, fmt.Errorf("CASE types %s and %s cannot be matched", resultType, branchType)
+		}
+		resultType = combined
+	}
+	return resultType, nil
+}
+
+// inferBetweenType checks that both bounds of BETWEEN are comparable with
+// its operand
+func (tc *TypeChecker) inferBetweenType(expr *parser.BetweenExpression) (DataType, error) {
+	operandType, err := tc.inferComparedType(expr.Expr, expr.Lower, expr.Upper)
+	if err != nil {
+		return DataTypeUnknown, err
+	}
+	for _, bound := range []parser.Expression{expr.Lower, expr.Upper} {
+		if err := tc.checkComparedWith(operandType, bound, "BETWEEN"); err != nil {
+			return DataTypeUnknown, err
+		}
+	}
+	return DataTypeBoolean, nil
+}
+
+// inferInListType checks that every value of an IN list is comparable with
+// its operand
+func (tc *TypeChecker) inferInListType(expr *parser.InListExpression) (DataType, error) {
+	operandType, err := tc.inferComparedType(expr.Expr, expr.List...)
+	if err != nil {
+		return DataTypeUnknown, err
+	}
+	for _, value := range expr.List {
+		if err := tc.checkComparedWith(operandType, value, "IN"); err != nil {
+			return DataTypeUnknown, err
+		}
+	}
+	return DataTypeBoolean, nil
+}
+
+// inferComparedType returns the type of the operand of BETWEEN or IN. A
+// parameter operand takes the type of the first value it is compared with.
+func (tc *TypeChecker) inferComparedType(operand parser.Expression, values ...parser.Expression) (DataType, error) {
+	if _, ok := operand.(*parser.Parameter); ok {
+		for _, value := range values {
+			valueType, err := tc.inferExpressionType(value)
+			if err != nil {
+				return DataTypeUnknown, err
+			}
+			if err := tc.bindParameter(operand, valueType); err != nil {
+				return DataTypeUnknown, err
+			}
+		}
+	}
+	return tc.inferExpressionType(operand)
+}
+
+// checkComparedWith binds a parameter value to the operand's type and checks
+// that the value can be compared with the operand
+func (tc *TypeChecker) checkComparedWith(operandType DataType, value parser.Expression, op string) error {
+	if err := tc.bindParameter(value, operandType); err != nil {
+		return err
+	}
+	valueType, err := tc.inferExpressionType(value)
+	if err != nil {
+		return err
+	}
+	if !operandType.IsComparable(valueType) && operandType != DataTypeUnknown && valueType != DataTypeUnknown {
+		return fmt.Errorf("%s operand of type %s cannot be compared with %s", op, operandType, valueType)
+	}
+	return nil
+}
+
+// inferLikeType checks that LIKE matches text against a text pattern and a
+// single-character escape
+func (tc *TypeChecker) inferLikeType(expr *parser.LikeExpression) (DataType, error) {
+	for _, operand := range []parser.Expression{expr.Expr, expr.Pattern, expr.Escape} {
+		if operand == nil {
+			continue
+		}
+		if err := tc.bindParameter(operand, DataTypeText); err != nil {
+			return DataTypeUnknown, err
+		}
+		operandType, err := tc.inferExpressionType(operand)
+		if err != nil {
+			return DataTypeUnknown, err
+		}
+		if operandType != DataTypeText && operandType != DataTypeUnknown && operandType != DataTypeNull {
+			return DataTypeUnknown, fmt.Errorf("LIKE requires text operands, got %s", operandType)
+		}
+	}
+
+	if lit, ok := expr.Escape.(*parser.Literal); ok {
+		if escape, ok := lit.Value.(string); ok && len([]rune(escape)) != 1 {
+			return DataTypeUnknown, fmt.Errorf("LIKE escape must be a single character, got %q", escape)
+		}
+	}
+	return DataTypeBoolean, nil
+}
+
+// inferCastType checks that a CAST converts between compatible types and
+// returns the target type
+func (tc *TypeChecker) inferCastType(cast *parser.CastExpression) (DataType, error) {
+	targetType, ok := DataTypeFromName(cast.Type.Name)
+	if !ok {
+		return DataTypeUnknown, fmt.Errorf("unsupported data type in CAST: %s", cast.Type.Name)
+	}
+
+	if err := tc.bindParameter(cast.Expr, targetType); err != nil {
+		return DataTypeUnknown, err
+	}
+	sourceType, err := tc.inferExpressionType(cast.Expr)
+	if err != nil {
+		return DataTypeUnknown, err
+	}
+	if !sourceType.CanCastTo(targetType) {
+		return DataTypeUnknown, fmt.Errorf("cannot cast %s to %s", sourceType, targetType)
+	}
+
+	if sourceType != targetType && sourceType != DataTypeUnknown {
+		tc.typeInfo.AddCoercion(cast.String(), sourceType, targetType, "CAST")
+	}
+	return targetType, nil
+}
+
+// checkWindowSpec type checks the partitioning and ordering expressions of
+// an OVER clause. Frame offsets must be numeric.
+func (tc *TypeChecker) checkWindowSpec(spec *parser.WindowSpec) error {
+	for _, expr := range spec.PartitionBy {
+		if _, err := tc.inferExpressionType(expr); err != nil {
+			return err
+		}
+	}
+	if spec.OrderBy != nil {
+		for _, order := range spec.OrderBy.Orders {
+			if _, err := tc.inferExpressionType(order.Expression); err != nil {
+				return err
+			}
+		}
+	}
+	if spec.Frame == nil {
+		return nil
+	}
+	for _, bound := range []*parser.FrameBound{spec.Frame.Start, spec.Frame.End} {
+		if bound.Offset == nil {
+			continue
+		}
+		offsetType, err := tc.inferExpressionType(bound.Offset)
+		if err != nil {
+			return err
+		}
+		if !offsetType.IsNumeric() && offsetType != DataTypeUnknown && offsetType != DataTypeNull {
+			return fmt.Errorf("window frame offset must be numeric, got %s", offsetType)
+		}
+	}
+	return nil
+}
+
 // inferFunctionType infers the return type of a function call
 func (tc *TypeChecker) inferFunctionType(fn *parser.FunctionCall) (DataType, error) {
-	funcName := fn.Name.Value
+	funcName := strings.ToUpper(fn.Name.Value)
+
+	if fn.Over != nil {
+		if err := tc.checkWindowSpec(fn.Over); err != nil {
+			return DataTypeUnknown, err
+		}
+	}
 
 	// Built-in aggregate functions
 	switch funcName {
@@ -400,10 +1106,53 @@ func (tc *TypeChecker) inferFunctionType(fn *parser.FunctionCall) (DataType, err
 			return tc.inferExpressionType(fn.Arguments[0])
 		}
 		return DataTypeUnknown, nil
+	case "ROW_NUMBER", "RANK", "DENSE_RANK":
+		return DataTypeInteger, nil
+	case "LAG", "LEAD", "FIRST_VALUE", "LAST_VALUE":
+		// The value of the first argument on another row of the window
+		if len(fn.Arguments) > 0 {
+			return tc.inferExpressionType(fn.Arguments[0])
+		}
+		return DataTypeUnknown, nil
 	case "UPPER", "LOWER", "TRIM", "LTRIM", "RTRIM":
 		return DataTypeText, nil
 	case "LENGTH", "CHAR_LENGTH":
 		return DataTypeInteger, nil
+	case "COALESCE":
+		// The first non-NULL argument: all must convert to one type
+		if len(fn.Arguments) == 0 {
+			return DataTypeUnknown, fmt.Errorf("COALESCE requires at least one argument")
+		}
+		resultType := DataTypeNull
+		for _, arg := range fn.Arguments {
+			argType, err := tc.inferExpressionType(arg)
+			if err != nil {
+				return DataTypeUnknown, err
+			}
+			combined, ok := commonType(resultType, argType)
+			if !ok {
+				return DataTypeUnknown, fmt.Errorf("COALESCE types %s and %s cannot be matched", resultType, argType)
+			}
+			resultType = combined
+		}
+		return resultType, nil
+	case "NULLIF":
+		// NULL if both arguments are equal, otherwise the first
+		if len(fn.Arguments) != 2 {
+			return DataTypeUnknown, fmt.Errorf("NULLIF requires 2 arguments, got %d", len(fn.Arguments))
+		}
+		leftType, err := tc.inferExpressionType(fn.Arguments[0])
+		if err != nil {
+			return DataTypeUnknown, err
+		}
+		rightType, err := tc.inferExpressionType(fn.Arguments[1])
+		if err != nil {
+			return DataTypeUnknown, err
+		}
+		if !leftType.IsComparable(rightType) && leftType != DataTypeUnknown && rightType != DataTypeUnknown {
+			return DataTypeUnknown, fmt.Errorf("NULLIF arguments of types %s and %s cannot be compared", leftType, rightType)
+		}
+		return leftType, nil
 	default:
 		return DataTypeUnknown, fmt.Errorf("unknown function: %s", funcName)
 	}
@@ -1,6 +1,11 @@
 package compiler
 
 import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
 	"relational-db/internal/parser"
 )
 
@@ -24,6 +29,540 @@ func (cv *ConstraintValidator) ValidateCreateTable(stmt *parser.CreateTableState
 	// - Check only one PRIMARY KEY
 	// - Validate FOREIGN KEY references
 	// - Check DEFAULT value types
+
+	// A clustered table stores its rows in the primary key B+tree, so it
+	// cannot exist without a primary key
+	if option := stmt.Option("clustered"); option != nil && option.IsEnabled() {
+		if len(CreateTablePrimaryKey(stmt)) == 0 {
+			return errorAt(stmt.TableName, fmt.Errorf("clustered table %s requires a PRIMARY KEY", stmt.TableName.Value))
+		}
+	}
+
+	if err := validateAutoIncrement(stmt); err != nil {
+		return err
+	}
+
+	columns := make(map[string]bool, len(stmt.Columns))
+	for _, col := range stmt.Columns {
+		columns[col.Name.Value] = true
+	}
+
+	if stmt.PartitionBy != nil {
+		if err := cv.validatePartitioning(stmt, columns); err != nil {
+			return err
+		}
+	}
+
+	return validateTTLOptions(stmt.TableName.Value, stmt.Option(TTLColumnOption), stmt.Option(TTLOption), func(name string) bool {
+		return columns[name]
+	})
+}
+
+// validatePartitioning checks the PARTITION BY clause of CREATE TABLE: the
+// partition key must be a column and partition names must be free
+func (cv *ConstraintValidator) validatePartitioning(stmt *parser.CreateTableStatement, columns map[string]bool) error {
+	tableName := stmt.TableName.Value
+	if !columns[stmt.PartitionBy.Column.Value] {
+		return errorAt(stmt.PartitionBy.Column, fmt.Errorf("partition column %s does not exist in table %s", stmt.PartitionBy.Column.Value, tableName))
+	}
+
+	scheme, err := PartitionSchemeFromSpec(tableName, stmt.PartitionBy)
+	if err != nil {
+		return err
+	}
+
+	for _, name := range scheme.PartitionNames() {
+		if name == tableName || cv.catalog.TableExists(name) {
+			return fmt.Errorf("partition %s conflicts with an existing table", name)
+		}
+	}
+
+	return nil
+}
+
+// ValidateAlterTable validates the changes made by ALTER TABLE. Actions are
+// checked in order against the table as the earlier actions left it, so a
+// column added by one action may be renamed by the next.
+func (cv *ConstraintValidator) ValidateAlterTable(stmt *parser.AlterTableStatement) error {
+	tableName := stmt.TableName.Value
+	table, err := cv.catalog.GetTable(tableName)
+	if err != nil {
+		return fmt.Errorf("table not found: %s", tableName)
+	}
+
+	alter := newAlteredTable(table)
+	var ttlColumn, ttl *parser.TableOption
+	for _, action := range stmt.Actions {
+		switch action := action.(type) {
+		case *parser.SetTableOptionsAction:
+			for _, option := range action.Options {
+				switch strings.ToLower(option.Name.Value) {
+				case TTLColumnOption:
+					ttlColumn = option
+				case TTLOption:
+					ttl = option
+				case "clustered":
+					return fmt.Errorf("clustered cannot be changed on existing table %s", tableName)
+				}
+			}
+		case *parser.ResetTableOptionsAction:
+			for _, name := range action.Names {
+				switch strings.ToLower(name.Value) {
+				case TTLColumnOption, TTLOption:
+					// Resetting either TTL option removes the policy
+					alter.ttlColumn = ""
+				case "clustered":
+					return fmt.Errorf("clustered cannot be changed on existing table %s", tableName)
+				}
+			}
+		case *parser.AddColumnAction:
+			if err := alter.addColumn(action.Column); err != nil {
+				return err
+			}
+		case *parser.DropColumnAction:
+			if err := alter.dropColumn(action.Column.Value); err != nil {
+				return err
+			}
+		case *parser.RenameColumnAction:
+			if err := alter.renameColumn(action.Column.Value, action.NewName.Value); err != nil {
+				return err
+			}
+		case *parser.RenameTableAction:
+			newName := action.NewName.Value
+			if table.Partitioning != nil {
+				return fmt.Errorf("partitioned table %s cannot be renamed", tableName)
+			}
+			if newName != tableName && cv.catalog.TableExists(newName) {
+				return fmt.Errorf("table %s already exists", newName)
+			}
+		case *parser.AddConstraintAction:
+			if err := alter.addConstraint(action.Constraint, cv.catalog); err != nil {
+				return err
+			}
+		case *parser.DropConstraintAction:
+			if err := alter.dropConstraint(action.Name.Value); err != nil {
+				return err
+			}
+		}
+	}
+
+	// Only one half of the policy given: pair it with the current setting
+	if ttlColumn == nil && ttl != nil && alter.ttlColumn != "" {
+		ttlColumn = &parser.TableOption{Name: &parser.Identifier{Value: TTLColumnOption}, Value: &parser.Identifier{Value: alter.ttlColumn}}
+	}
+	if ttl == nil && ttlColumn != nil && table.TTL > 0 {
+		ttl = &parser.TableOption{Name: &parser.Identifier{Value: TTLOption}, Value: &parser.Literal{Value: table.TTL.String()}}
+	}
+
+	return validateTTLOptions(tableName, ttlColumn, ttl, func(name string) bool {
+		return alter.columns[name]
+	})
+}
+
+// Index methods accepted by CREATE INDEX ... USING
+const (
+	IndexMethodBTree = "btree"
+	IndexMethodHash  = "hash"
+)
+
+// ValidateCreateIndex validates a CREATE INDEX statement: the index name is
+// unique across tables, no column is listed twice and the method is known
+func (cv *ConstraintValidator) ValidateCreateIndex(stmt *parser.CreateIndexStatement) error {
+	indexName := stmt.IndexName.Value
+	if table, found := FindIndexTable(cv.catalog, indexName); found && !stmt.IfNotExists {
+		return fmt.Errorf("index %s already exists on table %s", indexName, table)
+	}
+
+	seen := make(map[string]bool, len(stmt.Columns))
+	for _, col := range stmt.Columns {
+		name := col.Value
+		if seen[name] {
+			return errorAt(col, fmt.Errorf("column %s appears more than once in index %s", col.Value, indexName))
+		}
+		seen[name] = true
+	}
+
+	if stmt.Using != nil {
+		switch strings.ToLower(stmt.Using.Value) {
+		case IndexMethodBTree, IndexMethodHash:
+		default:
+			return errorAt(stmt.Using, fmt.Errorf("unsupported index method %s", stmt.Using.Value))
+		}
+	}
+
+	return nil
+}
+
+// FindIndexTable returns the table an index belongs to. Index names are
+// unique across tables and compared case-insensitively.
+func FindIndexTable(catalog CatalogManager, indexName string) (string, bool) {
+	tables, err := catalog.ListTables()
+	if err != nil {
+		return "", false
+	}
+	for _, tableName := range tables {
+		table, err := catalog.GetTable(tableName)
+		if err != nil {
+			continue
+		}
+		for _, name := range table.Indexes {
+			if name == indexName {
+				return table.Name, true
+			}
+		}
+	}
+	return "", false
+}
+
+// DependentViews returns the views that read name directly or through
+// other views, each after the views that depend on it, so they can be
+// dropped in order
+func DependentViews(catalog CatalogManager, name string) []string {
+	views, err := catalog.ListViews()
+	if err != nil {
+		return nil
+	}
+
+	var dependents []string
+	seen := make(map[string]bool)
+	var visit func(name string)
+	visit = func(name string) {
+		for _, viewName := range views {
+			view, err := catalog.GetView(viewName)
+			if err != nil || seen[viewName] {
+				continue
+			}
+			for _, dep := range view.DependsOn {
+				if dep == name {
+					seen[viewName] = true
+					visit(viewName)
+					dependents = append(dependents, viewName)
+					break
+				}
+			}
+		}
+	}
+	visit(name)
+	return dependents
+}
+
+// alteredTable tracks the columns and constraints of a table while the
+// actions of an ALTER TABLE statement are validated
+type alteredTable struct {
+	name        string
+	columns     map[string]bool // lower-cased column names
+	count       int
+	primaryKey  map[string]bool
+	ttlColumn   string
+	partitionBy string
+	constraints map[string]bool // lower-cased constraint names
+}
+
+// newAlteredTable starts from the current definition of a table
+func newAlteredTable(table *TableMetadata) *alteredTable {
+	alter := &alteredTable{
+		name:        table.Name,
+		columns:     make(map[string]bool, len(table.Columns)),
+		count:       len(table.Columns),
+		primaryKey:  make(map[string]bool, len(table.PrimaryKey)),
+		ttlColumn:   table.TTLColumn,
+		constraints: make(map[string]bool, len(table.Constraints)),
+	}
+	for _, col := range table.Columns {
+		alter.columns[col.Name] = true
+	}
+	for _, col := range table.PrimaryKey {
+		alter.primaryKey[col] = true
+	}
+	if table.Partitioning != nil {
+		alter.partitionBy = table.Partitioning.Column
+	}
+	for _, name := range table.Constraints {
+		alter.constraints[name] = true
+	}
+	return alter
+}
+
+// addColumn checks ADD COLUMN. A new column holds NULL or its default in
+// existing rows, so it cannot become part of the primary key.
+func (a *alteredTable) addColumn(col *parser.ColumnDefinition) error {
+	name := col.Name.Value
+	if a.columns[name] {
+		return fmt.Errorf("column %s already exists in table %s", name, a.name)
+	}
+	if _, ok := DataTypeFromName(col.DataType.Name); !ok {
+		return fmt.Errorf("unsupported data type %s for column %s", col.DataType.Name, col.Name.Value)
+	}
+
+	for _, constraint := range col.Constraints {
+		switch constraint.Type {
+		case parser.PrimaryKey:
+			return fmt.Errorf("cannot add primary key column %s to existing table %s", col.Name.Value, a.name)
+		case parser.Default:
+			if !isConstant(constraint.DefaultValue) {
+				return fmt.Errorf("DEFAULT for column %s must be a constant", col.Name.Value)
+			}
+		}
+	}
+
+	a.columns[name] = true
+	a.count++
+	return nil
+}
+
+// dropColumn checks DROP COLUMN. Columns the table is organized or expired
+// by cannot be dropped.
+func (a *alteredTable) dropColumn(column string) error {
+	switch {
+	case !a.columns[column]:
+		return fmt.Errorf("column %s not found in table %s", column, a.name)
+	case a.count == 1:
+		return fmt.Errorf("cannot drop the only column of table %s", a.name)
+	case a.primaryKey[column]:
+		return fmt.Errorf("cannot drop primary key column %s of table %s", column, a.name)
+	case a.ttlColumn == column:
+		return fmt.Errorf("cannot drop column %s used as %s of table %s", column, TTLColumnOption, a.name)
+	case a.partitionBy == column:
+		return fmt.Errorf("cannot drop partition column %s of table %s", column, a.name)
+	}
+
+	delete(a.columns, column)
+	a.count--
+	return nil
+}
+
+// renameColumn checks RENAME COLUMN, carrying the column's roles over to
+// its new name
+func (a *alteredTable) renameColumn(column, newName string) error {
+	if !a.columns[column] {
+		return fmt.Errorf("column %s not found in table %s", column, a.name)
+	}
+	if a.columns[newName] && newName != column {
+		return fmt.Errorf("column %s already exists in table %s", newName, a.name)
+	}
+	if a.partitionBy == column {
+		return fmt.Errorf("cannot rename partition column %s of table %s", column, a.name)
+	}
+
+	delete(a.columns, column)
+	a.columns[newName] = true
+	if a.primaryKey[column] {
+		delete(a.primaryKey, column)
+		a.primaryKey[newName] = true
+	}
+	if a.ttlColumn == column {
+		a.ttlColumn = newName
+	}
+	return nil
+}
+
+// addConstraint checks ADD CONSTRAINT: its columns must exist, a table has
+// only one primary key and foreign keys must reference existing columns
+func (a *alteredTable) addConstraint(constraint *parser.TableConstraint, catalog CatalogManager) error {
+	if constraint.Name != nil {
+		name := constraint.Name.Value
+		if a.constraints[name] {
+			return fmt.Errorf("constraint %s already exists on table %s", constraint.Name.Value, a.name)
+		}
+		a.constraints[name] = true
+	}
+
+	for _, col := range constraint.Columns {
+		if !a.columns[col.Value] {
+			return fmt.Errorf("column %s not found in table %s", col.Value, a.name)
+		}
+	}
+
+	switch constraint.Type {
+	case parser.PrimaryKey:
+		if len(a.primaryKey) > 0 {
+			return fmt.Errorf("table %s already has a primary key", a.name)
+		}
+		for _, col := range constraint.Columns {
+			a.primaryKey[col.Value] = true
+		}
+
+	case parser.ForeignKey:
+		if constraint.References == nil {
+			return fmt.Errorf("foreign key on table %s has no REFERENCES clause", a.name)
+		}
+		refTable, err := catalog.GetTable(constraint.References.Table.Value)
+		if err != nil {
+			return errorAt(constraint.References.Table, fmt.Errorf("referenced table %s does not exist", constraint.References.Table.Value))
+		}
+		refColumns := constraint.References.Columns
+		if len(refColumns) == 0 {
+			for _, col := range refTable.PrimaryKey {
+				refColumns = append(refColumns, &parser.Identifier{Value: col})
+			}
+		}
+		if len(refColumns) != len(constraint.Columns) {
+			return fmt.Errorf("foreign key on table %s has %d columns but references %d", a.name, len(constraint.Columns), len(refColumns))
+		}
+		for _, col := range refColumns {
+			if !refTable.HasColumn(col.Value) {
+				return fmt.Errorf("column %s not found in table %s", col.Value, refTable.Name)
+			}
+		}
+	}
+	return nil
+}
+
+// dropConstraint checks DROP CONSTRAINT
+func (a *alteredTable) dropConstraint(name string) error {
+	if !a.constraints[name] {
+		return fmt.Errorf("constraint %s does not exist on table %s", name, a.name)
+	}
+	delete(a.constraints, name)
+	return nil
+}
+
+// isConstant reports whether an expression involves no columns, parameters
+// or subqueries
+func isConstant(expr parser.Expression) bool {
+	if expr == nil {
+		return false
+	}
+
+	constant := true
+	parser.Inspect(expr, func(node parser.Node) bool {
+		switch node.(type) {
+		case nil, *parser.Literal, *parser.UnaryExpression, *parser.BinaryExpression,
+			*parser.CastExpression, *parser.DataType:
+			return true
+		}
+		constant = false
+		return false
+	})
+	return constant
+}
+
+// validateTTLOptions checks a row expiry policy: ttl_column must name an
+// existing column and ttl must be a positive interval. The two options are
+// only meaningful together.
+func validateTTLOptions(tableName string, ttlColumn, ttl *parser.TableOption, hasColumn func(string) bool) error {
+	if ttlColumn == nil && ttl == nil {
+		return nil
+	}
+	if ttlColumn == nil {
+		return fmt.Errorf("table %s sets %s without %s", tableName, TTLOption, TTLColumnOption)
+	}
+	if ttl == nil {
+		return fmt.Errorf("table %s sets %s without %s", tableName, TTLColumnOption, TTLOption)
+	}
+
+	column := ttlColumn.Text()
+	if column == "" || !hasColumn(column) {
+		return fmt.Errorf("%s %q does not exist in table %s", TTLColumnOption, column, tableName)
+	}
+
+	duration, err := ParseInterval(ttl.Text())
+	if err != nil {
+		return fmt.Errorf("invalid %s for table %s: %v", TTLOption, tableName, err)
+	}
+	if duration <= 0 {
+		return fmt.Errorf("%s for table %s must be positive", TTLOption, tableName)
+	}
+
+	return nil
+}
+
+// ParseInterval parses a row expiry interval such as '30 days', '12 hours'
+// or a Go duration string like '90m'
+func ParseInterval(s string) (time.Duration, error) {
+	s = strings.TrimSpace(s)
+	if s == "" {
+		return 0, fmt.Errorf("empty interval")
+	}
+
+	fields := strings.Fields(s)
+	if len(fields) != 2 {
+		duration, err := time.ParseDuration(s)
+		if err != nil {
+			return 0, fmt.Errorf("cannot parse interval %q", s)
+		}
+		return duration, nil
+	}
+
+	count, err := strconv.ParseInt(fields[0], 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("cannot parse interval %q", s)
+	}
+
+	var unit time.Duration
+	switch strings.TrimSuffix(strings.ToLower(fields[1]), "s") {
+	case "second", "sec":
+		unit = time.Second
+	case "minute", "min":
+		unit = time.Minute
+	case "hour":
+		unit = time.Hour
+	case "day":
+		unit = 24 * time.Hour
+	case "week":
+		unit = 7 * 24 * time.Hour
+	default:
+		return 0, fmt.Errorf("unknown interval unit %q", fields[1])
+	}
+
+	return time.Duration(count) * unit, nil
+}
+
+// CreateTablePrimaryKey returns the primary key columns declared in a CREATE
+// TABLE statement, either as a table constraint or as a column constraint
+func CreateTablePrimaryKey(stmt *parser.CreateTableStatement) []string {
+	for _, constraint := range stmt.Constraints {
+		if constraint.Type == parser.PrimaryKey {
+			columns := make([]string, 0, len(constraint.Columns))
+			for _, col := range constraint.Columns {
+				columns = append(columns, col.Value)
+			}
+			return columns
+		}
+	}
+
+	for _, col := range stmt.Columns {
+		for _, constraint := range col.Constraints {
+			if constraint.Type == parser.PrimaryKey {
+				return []string{col.Name.Value}
+			}
+		}
+	}
+
+	return nil
+}
+
+// CreateTableAutoIncrement returns the AUTO_INCREMENT column declared in a
+// CREATE TABLE statement, or "" if there is none
+func CreateTableAutoIncrement(stmt *parser.CreateTableStatement) string {
+	for _, col := range stmt.Columns {
+		for _, constraint := range col.Constraints {
+			if constraint.Type == parser.AutoIncrement {
+				return col.Name.Value
+			}
+		}
+	}
+	return ""
+}
+
+// validateAutoIncrement checks that a table has at most one AUTO_INCREMENT
+// column and that the column holds integers
+func validateAutoIncrement(stmt *parser.CreateTableStatement) error {
+	found := ""
+	for _, col := range stmt.Columns {
+		for _, constraint := range col.Constraints {
+			if constraint.Type != parser.AutoIncrement {
+				continue
+			}
+			if found != "" {
+				return fmt.Errorf("table %s has more than one AUTO_INCREMENT column", stmt.TableName.Value)
+			}
+			if dataType, _ := DataTypeFromName(col.DataType.Name); dataType != DataTypeInteger {
+				return fmt.Errorf("AUTO_INCREMENT column %s must be an integer", col.Name.Value)
+			}
+			found = col.Name.Value
+		}
+	}
 	return nil
 }
 
@@ -33,6 +572,23 @@ func (cv *ConstraintValidator) ValidateInsert(stmt *parser.InsertStatement) erro
 	// - Check NOT NULL constraints
 	// - Validate PRIMARY KEY uniqueness
 	// - Check FOREIGN KEY references
+
+	// Conflicts are detected on the primary key or a unique index, so the
+	// conflict columns must be one of them
+	if stmt.OnConflict != nil && len(stmt.OnConflict.Columns) > 0 {
+		table, found := cv.refs.Tables[stmt.TableName.Value]
+		if !found {
+			return nil
+		}
+		columns := make([]string, len(stmt.OnConflict.Columns))
+		for i, col := range stmt.OnConflict.Columns {
+			columns[i] = col.Value
+		}
+		if !table.IsUniqueKey(columns) {
+			return fmt.Errorf("no primary key or unique index of table %s matches ON CONFLICT (%s)",
+				stmt.TableName.Value, strings.Join(columns, ", "))
+		}
+	}
 	return nil
 }
 
@@ -45,6 +601,26 @@ func (cv *ConstraintValidator) ValidateUpdate(stmt *parser.UpdateStatement) erro
 	return nil
 }
 
+// ValidateMerge checks that every WHEN clause of a MERGE can apply. A
+// clause without an AND condition applies to all the rows it matches, so
+// a later clause of the same kind would never be reached.
+func (cv *ConstraintValidator) ValidateMerge(stmt *parser.MergeStatement) error {
+	unconditional := make(map[bool]bool)
+	for _, clause := range stmt.Clauses {
+		if unconditional[clause.Matched] {
+			kind := "WHEN NOT MATCHED"
+			if clause.Matched {
+				kind = "WHEN MATCHED"
+			}
+			return fmt.Errorf("unreachable WHEN clause after an unconditional %s", kind)
+		}
+		if clause.Condition == nil {
+			unconditional[clause.Matched] = true
+		}
+	}
+	return nil
+}
+
 // AggregateValidator validates aggregate function usage
 type AggregateValidator struct {
 	refs *ResolvedReferences
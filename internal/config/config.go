@@ -25,6 +25,7 @@ type DatabaseConfig struct {
 	Name string
 	MaxTransactions int
 	QueryTimeout int // seconds
+	PlanCacheSize int // number of prepared plans kept per database
 }
 
 // StorageConfig holds storage engine configuration
@@ -47,6 +48,7 @@ func Default() *Config {
 			Name:            "relationaldb",
 			MaxTransactions: 1000,
 			QueryTimeout:    30,
+			PlanCacheSize:   256,
 		},
 		Storage: StorageConfig{
 			DataDirectory: "./data",
@@ -85,6 +87,11 @@ func LoadFromEnv() *Config {
 			cfg.Database.QueryTimeout = timeout
 		}
 	}
+	if cacheSizeStr := os.Getenv("DB_PLAN_CACHE_SIZE"); cacheSizeStr != "" {
+		if cacheSize, err := strconv.Atoi(cacheSizeStr); err == nil {
+			cfg.Database.PlanCacheSize = cacheSize
+		}
+	}
 	
 	// Storage configuration
 	if dataDir := os.Getenv("DB_DATA_DIRECTORY"); dataDir != "" {
@@ -136,12 +143,13 @@ func (c *Config) String() string {
     Name: %s
     Max Transactions: %d
     Query Timeout: %d seconds
+    Plan Cache Size: %d plans
   Storage:
     Data Directory: %s
     Page Size: %d bytes
     Buffer Size: %d pages
     Max File Size: %d bytes`,
 		c.Server.Host, c.Server.Port, c.Server.MaxConnections,
-		c.Database.Name, c.Database.MaxTransactions, c.Database.QueryTimeout,
+		c.Database.Name, c.Database.MaxTransactions, c.Database.QueryTimeout, c.Database.PlanCacheSize,
 		c.Storage.DataDirectory, c.Storage.PageSize, c.Storage.BufferSize, c.Storage.MaxFileSize)
 }
\ No newline at end of file
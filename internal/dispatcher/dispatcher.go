@@ -7,6 +7,7 @@ import (
 	"time"
 
 	"relational-db/internal/config"
+	"relational-db/internal/executor"
 	"relational-db/internal/lexer"
 	"relational-db/internal/parser"
 	"relational-db/internal/storage"
@@ -24,6 +25,14 @@ const (
 	QueryTypeDropTable
 	QueryTypeCreateIndex
 	QueryTypeDropIndex
+	QueryTypeBegin
+	QueryTypeCommit
+	QueryTypeRollback
+	QueryTypeSavepoint
+	QueryTypeCreateView
+	QueryTypeDropView
+	QueryTypeRefreshMaterializedView
+	QueryTypeMerge
 )
 
 func (qt QueryType) String() string {
@@ -44,6 +53,22 @@ func (qt QueryType) String() string {
 		return "CREATE_INDEX"
 	case QueryTypeDropIndex:
 		return "DROP_INDEX"
+	case QueryTypeBegin:
+		return "BEGIN"
+	case QueryTypeCommit:
+		return "COMMIT"
+	case QueryTypeRollback:
+		return "ROLLBACK"
+	case QueryTypeSavepoint:
+		return "SAVEPOINT"
+	case QueryTypeCreateView:
+		return "CREATE_VIEW"
+	case QueryTypeDropView:
+		return "DROP_VIEW"
+	case QueryTypeRefreshMaterializedView:
+		return "REFRESH_MATERIALIZED_VIEW"
+	case QueryTypeMerge:
+		return "MERGE"
 	default:
 		return "UNKNOWN"
 	}
@@ -155,6 +180,10 @@ type Dispatcher struct {
 	config          *config.Config
 	storageEngine   storage.StorageEngine
 	
+	// Transaction state, one session per connection
+	transactions    *executor.TransactionExecutor
+	sessions        map[string]*executor.Session
+	
 	// Query execution statistics
 	queriesExecuted int64
 	totalExecutionTime time.Duration
@@ -166,6 +195,8 @@ func NewDispatcher(cfg *config.Config, storageEngine storage.StorageEngine) *Dis
 	return &Dispatcher{
 		config:         cfg,
 		storageEngine:  storageEngine,
+		transactions:   executor.NewTransactionExecutor(nil, executor.NewLockManager()),
+		sessions:       make(map[string]*executor.Session),
 		queryTypeStats: make(map[QueryType]int64),
 	}
 }
@@ -223,6 +254,26 @@ func (d *Dispatcher) determineQueryType(stmt parser.Statement) QueryType {
 		return QueryTypeCreateTable
 	case *parser.DropTableStatement:
 		return QueryTypeDropTable
+	case *parser.CreateIndexStatement:
+		return QueryTypeCreateIndex
+	case *parser.DropIndexStatement:
+		return QueryTypeDropIndex
+	case *parser.BeginStatement:
+		return QueryTypeBegin
+	case *parser.CommitStatement:
+		return QueryTypeCommit
+	case *parser.RollbackStatement:
+		return QueryTypeRollback
+	case *parser.SavepointStatement, *parser.ReleaseSavepointStatement:
+		return QueryTypeSavepoint
+	case *parser.CreateViewStatement:
+		return QueryTypeCreateView
+	case *parser.DropViewStatement:
+		return QueryTypeDropView
+	case *parser.RefreshMaterializedViewStatement:
+		return QueryTypeRefreshMaterializedView
+	case *parser.MergeStatement:
+		return QueryTypeMerge
 	default:
 		return QueryType(-1) // Unknown
 	}
@@ -244,6 +295,16 @@ func (d *Dispatcher) createQueryPlan(ctx context.Context, stmt parser.Statement,
 		return d.planCreateTableQuery(ctx, stmt.(*parser.CreateTableStatement))
 	case QueryTypeDropTable:
 		return d.planDropTableQuery(ctx, stmt.(*parser.DropTableStatement))
+	case QueryTypeCreateIndex:
+		return d.planCreateIndexQuery(ctx, stmt.(*parser.CreateIndexStatement))
+	case QueryTypeDropIndex:
+		return d.planDropIndexQuery(ctx, stmt.(*parser.DropIndexStatement))
+	case QueryTypeBegin, QueryTypeCommit, QueryTypeRollback, QueryTypeSavepoint:
+		return d.planTransactionQuery(ctx, stmt, queryType)
+	case QueryTypeCreateView, QueryTypeDropView, QueryTypeRefreshMaterializedView:
+		return d.planViewQuery(ctx, stmt, queryType)
+	case QueryTypeMerge:
+		return d.planMergeQuery(ctx, stmt.(*parser.MergeStatement))
 	default:
 		return nil, fmt.Errorf("unsupported query type: %v", queryType)
 	}
@@ -326,15 +387,20 @@ func (d *Dispatcher) planUpdateQuery(ctx context.Context, stmt *parser.UpdateSta
 		Cost:      100.0,
 	}
 	
+	plan.Operations = append(plan.Operations, scanOp)
+	
+	// Joined tables are scanned and joined to the target
+	if stmt.From != nil {
+		plan.Operations = append(plan.Operations, d.joinedTableOperations(stmt.From)...)
+	}
+	
 	// Filter operation if WHERE clause exists
 	if stmt.WhereClause != nil {
 		filterOp := Operation{
 			Type: OpFilter,
 			Cost: 10.0,
 		}
-		plan.Operations = append(plan.Operations, scanOp, filterOp)
-	} else {
-		plan.Operations = append(plan.Operations, scanOp)
+		plan.Operations = append(plan.Operations, filterOp)
 	}
 	
 	// Update operation
@@ -364,15 +430,20 @@ func (d *Dispatcher) planDeleteQuery(ctx context.Context, stmt *parser.DeleteSta
 		Cost:      100.0,
 	}
 	
+	plan.Operations = append(plan.Operations, scanOp)
+	
+	// Joined tables are scanned and joined to the target
+	if stmt.Using != nil {
+		plan.Operations = append(plan.Operations, d.joinedTableOperations(stmt.Using)...)
+	}
+	
 	// Filter operation if WHERE clause exists
 	if stmt.WhereClause != nil {
 		filterOp := Operation{
 			Type: OpFilter,
 			Cost: 10.0,
 		}
-		plan.Operations = append(plan.Operations, scanOp, filterOp)
-	} else {
-		plan.Operations = append(plan.Operations, scanOp)
+		plan.Operations = append(plan.Operations, filterOp)
 	}
 	
 	// Delete operation
@@ -388,6 +459,39 @@ func (d *Dispatcher) planDeleteQuery(ctx context.Context, stmt *parser.DeleteSta
 	return plan, nil
 }
 
+// planMergeQuery creates an execution plan for MERGE queries
+func (d *Dispatcher) planMergeQuery(ctx context.Context, stmt *parser.MergeStatement) (*QueryPlan, error) {
+	plan := &QueryPlan{
+		QueryType: QueryTypeMerge,
+		AST:       stmt,
+	}
+	
+	// Both tables are scanned to match source rows to target rows
+	targetOp := Operation{
+		Type:      OpTableScan,
+		TableName: stmt.Target.Value,
+		Cost:      100.0,
+	}
+	sourceOp := Operation{
+		Type: OpTableScan,
+		Cost: 100.0,
+	}
+	if source, ok := stmt.Source.(*parser.Identifier); ok {
+		sourceOp.TableName = source.Value
+	}
+	
+	mergeOp := Operation{
+		Type:      OpUpdate,
+		TableName: stmt.Target.Value,
+		Cost:      30.0,
+	}
+	plan.Operations = append(plan.Operations, targetOp, sourceOp, mergeOp)
+	
+	plan.EstimatedCost = d.calculatePlanCost(plan.Operations)
+	
+	return plan, nil
+}
+
 // planCreateTableQuery creates an execution plan for CREATE TABLE queries
 func (d *Dispatcher) planCreateTableQuery(ctx context.Context, stmt *parser.CreateTableStatement) (*QueryPlan, error) {
 	plan := &QueryPlan{
@@ -428,6 +532,84 @@ func (d *Dispatcher) planDropTableQuery(ctx context.Context, stmt *parser.DropTa
 	return plan, nil
 }
 
+// planCreateIndexQuery creates an execution plan for CREATE INDEX queries
+func (d *Dispatcher) planCreateIndexQuery(ctx context.Context, stmt *parser.CreateIndexStatement) (*QueryPlan, error) {
+	plan := &QueryPlan{
+		QueryType: QueryTypeCreateIndex,
+		AST:       stmt,
+	}
+	
+	// Building the index scans the table's existing rows
+	op := Operation{
+		Type:      OpTableScan,
+		TableName: stmt.TableName.Value,
+		Cost:      100.0,
+	}
+	
+	plan.Operations = append(plan.Operations, op)
+	plan.EstimatedCost = 100.0
+	
+	return plan, nil
+}
+
+// planDropIndexQuery creates an execution plan for DROP INDEX queries
+func (d *Dispatcher) planDropIndexQuery(ctx context.Context, stmt *parser.DropIndexStatement) (*QueryPlan, error) {
+	plan := &QueryPlan{
+		QueryType: QueryTypeDropIndex,
+		AST:       stmt,
+	}
+	
+	// Simple operation for index dropping
+	op := Operation{
+		Type: OpDelete, // Reuse delete type for DDL operations
+		Cost: 10.0,
+	}
+	
+	plan.Operations = append(plan.Operations, op)
+	plan.EstimatedCost = 10.0
+	
+	return plan, nil
+}
+
+// planViewQuery creates an execution plan for view DDL. Creating a
+// materialized view or refreshing one runs the view's query.
+func (d *Dispatcher) planViewQuery(ctx context.Context, stmt parser.Statement, queryType QueryType) (*QueryPlan, error) {
+	plan := &QueryPlan{
+		QueryType: queryType,
+		AST:       stmt,
+	}
+	
+	op := Operation{
+		Type: OpDelete, // Reuse delete type for DDL operations
+		Cost: 10.0,
+	}
+	switch s := stmt.(type) {
+	case *parser.CreateViewStatement:
+		op.TableName = s.ViewName.Value
+		if s.Materialized && !s.WithNoData {
+			op.Type, op.Cost = OpInsert, 100.0
+		}
+	case *parser.DropViewStatement:
+		op.TableName = s.ViewName.Value
+	case *parser.RefreshMaterializedViewStatement:
+		op.Type, op.TableName, op.Cost = OpInsert, s.ViewName.Value, 100.0
+	}
+	
+	plan.Operations = append(plan.Operations, op)
+	plan.EstimatedCost = op.Cost
+	
+	return plan, nil
+}
+
+// planTransactionQuery creates an execution plan for transaction control
+// statements, which touch no tables
+func (d *Dispatcher) planTransactionQuery(ctx context.Context, stmt parser.Statement, queryType QueryType) (*QueryPlan, error) {
+	return &QueryPlan{
+		QueryType: queryType,
+		AST:       stmt,
+	}, nil
+}
+
 // executeQuery executes the query plan
 func (d *Dispatcher) executeQuery(ctx context.Context, plan *QueryPlan, queryCtx *QueryContext) (*QueryResult, error) {
 	switch plan.QueryType {
@@ -443,6 +625,16 @@ func (d *Dispatcher) executeQuery(ctx context.Context, plan *QueryPlan, queryCtx
 		return d.executeCreateTableQuery(ctx, plan)
 	case QueryTypeDropTable:
 		return d.executeDropTableQuery(ctx, plan)
+	case QueryTypeCreateIndex:
+		return d.executeCreateIndexQuery(ctx, plan)
+	case QueryTypeDropIndex:
+		return d.executeDropIndexQuery(ctx, plan)
+	case QueryTypeBegin, QueryTypeCommit, QueryTypeRollback, QueryTypeSavepoint:
+		return d.executeTransactionQuery(ctx, plan, queryCtx)
+	case QueryTypeCreateView, QueryTypeDropView, QueryTypeRefreshMaterializedView:
+		return d.executeViewQuery(ctx, plan)
+	case QueryTypeMerge:
+		return d.executeMergeQuery(ctx, plan)
 	default:
 		return nil, fmt.Errorf("unsupported query type for execution: %v", plan.QueryType)
 	}
@@ -515,19 +707,166 @@ func (d *Dispatcher) executeDropTableQuery(ctx context.Context, plan *QueryPlan)
 	}, nil
 }
 
+// executeCreateIndexQuery executes CREATE INDEX queries
+func (d *Dispatcher) executeCreateIndexQuery(ctx context.Context, plan *QueryPlan) (*QueryResult, error) {
+	// TODO: Implement actual CREATE INDEX execution with storage engine
+	return &QueryResult{
+		Columns:      []string{},
+		Rows:         [][]interface{}{},
+		RowsAffected: 0,
+		LastInsertID: 0,
+	}, nil
+}
+
+// executeDropIndexQuery executes DROP INDEX queries
+func (d *Dispatcher) executeDropIndexQuery(ctx context.Context, plan *QueryPlan) (*QueryResult, error) {
+	// TODO: Implement actual DROP INDEX execution with storage engine
+	return &QueryResult{
+		Columns:      []string{},
+		Rows:         [][]interface{}{},
+		RowsAffected: 0,
+		LastInsertID: 0,
+	}, nil
+}
+
+// executeViewQuery executes CREATE VIEW, DROP VIEW and REFRESH
+// MATERIALIZED VIEW queries
+func (d *Dispatcher) executeViewQuery(ctx context.Context, plan *QueryPlan) (*QueryResult, error) {
+	// TODO: Implement actual view DDL execution with storage engine
+	return &QueryResult{
+		Columns:      []string{},
+		Rows:         [][]interface{}{},
+		RowsAffected: 0,
+		LastInsertID: 0,
+	}, nil
+}
+
+// executeMergeQuery executes MERGE queries
+func (d *Dispatcher) executeMergeQuery(ctx context.Context, plan *QueryPlan) (*QueryResult, error) {
+	// TODO: Implement actual MERGE execution with storage engine
+	return &QueryResult{
+		Columns:      []string{},
+		Rows:         [][]interface{}{},
+		RowsAffected: 0,
+		LastInsertID: 0,
+	}, nil
+}
+
+// executeTransactionQuery runs a transaction control statement in the
+// session of the query's connection
+func (d *Dispatcher) executeTransactionQuery(ctx context.Context, plan *QueryPlan, queryCtx *QueryContext) (*QueryResult, error) {
+	if queryCtx == nil || queryCtx.ConnectionID == "" {
+		return nil, fmt.Errorf("%s requires a connection", plan.QueryType)
+	}
+	
+	session := d.session(queryCtx.ConnectionID)
+	if err := session.Execute(plan.AST); err != nil {
+		return nil, err
+	}
+	
+	return &QueryResult{
+		Columns:      []string{},
+		Rows:         [][]interface{}{},
+		RowsAffected: 0,
+		LastInsertID: 0,
+	}, nil
+}
+
+// session returns the session of a connection, creating it on first use
+func (d *Dispatcher) session(connectionID string) *executor.Session {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	
+	session, exists := d.sessions[connectionID]
+	if !exists {
+		session = d.transactions.NewSession(connectionID)
+		d.sessions[connectionID] = session
+	}
+	return session
+}
+
+// InTransaction reports whether a connection has an open transaction
+func (d *Dispatcher) InTransaction(connectionID string) bool {
+	d.mu.RLock()
+	session, exists := d.sessions[connectionID]
+	d.mu.RUnlock()
+	
+	return exists && session.InTransaction()
+}
+
+// CloseSession discards a connection's session, rolling back any transaction
+// it left open
+func (d *Dispatcher) CloseSession(connectionID string) error {
+	d.mu.Lock()
+	session, exists := d.sessions[connectionID]
+	delete(d.sessions, connectionID)
+	d.mu.Unlock()
+	
+	if !exists {
+		return nil
+	}
+	return session.Close()
+}
+
 // Helper functions
 
-// extractTableName extracts table name from expression
+// extractTableName returns the table a FROM item reads: the table itself,
+// or for a derived table the first table named in its FROM clauses
 func (d *Dispatcher) extractTableName(expr parser.Expression) string {
-	switch e := expr.(type) {
-	case *parser.Identifier:
-		return e.Value
-	case *parser.ColumnReference:
-		if e.Table != nil {
-			return e.Table.Value
+	if ref, ok := expr.(*parser.ColumnReference); ok && ref.Table != nil {
+		return ref.Table.Value
+	}
+	
+	name := ""
+	parser.Rewrite(expr, func(c *parser.Cursor) bool {
+		if name != "" {
+			return false
+		}
+		ident, ok := c.Node().(*parser.Identifier)
+		if !ok {
+			return true
 		}
+		
+		// Names under other nodes are columns, functions and aliases
+		switch parent := c.Parent().(type) {
+		case nil, *parser.FromClause:
+			name = ident.Value
+		case *parser.JoinClause:
+			if parent.Table == parser.Expression(ident) {
+				name = ident.Value
+			}
+		}
+		return false
+	}, nil)
+	
+	if name == "" {
+		return "unknown_table"
+	}
+	return name
+}
+
+// joinedTableOperations returns the scans of the tables an UPDATE ... FROM
+// or DELETE ... USING joins to its target, each followed by its join
+func (d *Dispatcher) joinedTableOperations(from *parser.FromClause) []Operation {
+	items := append([]parser.Expression{}, from.Tables...)
+	for _, join := range from.Joins {
+		items = append(items, join.Table)
+	}
+	
+	var operations []Operation
+	for _, item := range items {
+		operations = append(operations,
+			Operation{
+				Type:      OpTableScan,
+				TableName: d.extractTableName(item),
+				Cost:      100.0,
+			},
+			Operation{
+				Type: OpHashJoin,
+				Cost: 50.0,
+			})
 	}
-	return "unknown_table"
+	return operations
 }
 
 // calculatePlanCost calculates the total cost of an execution plan
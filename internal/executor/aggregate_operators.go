@@ -1,58 +1,225 @@
 package executor
 
 import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"relational-db/internal/optimizer"
 	"relational-db/internal/parser"
 )
 
-// HashAggregateOperator implements hash-based aggregation
-type HashAggregateOperator struct {
-	child        PhysicalOperator
+// groupAggregator computes the aggregates of groups of rows for the
+// aggregate operators. A group's output row is its first input row followed
+// by one column per aggregate call, named after the call, which is where the
+// evaluator reads an aggregate's value from.
+type groupAggregator struct {
 	groupByKeys  []parser.Expression
-	aggFunctions []parser.Expression
-	hashTable    map[interface{}]*AggregateState
+	aggFunctions []*parser.FunctionCall
 	evaluator    *ExpressionEvaluator
-	iterator     []interface{} // Keys for iteration
-	iterPos      int
-	closed       bool
+	schemas      map[*TupleSchema]*TupleSchema
+}
+
+// aggregateGroup is the state of one group
+type aggregateGroup struct {
+	keys       []interface{}
+	first      *Tuple // nil for the group of an empty input
+	aggregates []*windowAggregate
+	seen       []map[string]bool // values of DISTINCT aggregates already added
+}
+
+// newGroupAggregator creates an aggregator; repeated calls are computed once
+func newGroupAggregator(groupByKeys []parser.Expression, aggFunctions []*parser.FunctionCall) *groupAggregator {
+	var distinct []*parser.FunctionCall
+	seen := make(map[string]bool)
+	for _, fn := range aggFunctions {
+		if name := fn.String(); !seen[name] {
+			seen[name] = true
+			distinct = append(distinct, fn)
+		}
+	}
+
+	return &groupAggregator{
+		groupByKeys:  groupByKeys,
+		aggFunctions: distinct,
+		evaluator:    NewExpressionEvaluator(),
+	}
+}
+
+// bind prepares the aggregator for an execution
+func (a *groupAggregator) bind(ctx *ExecutionContext) {
+	a.evaluator.Bind(ctx)
+	a.schemas = make(map[*TupleSchema]*TupleSchema)
+}
+
+// keys evaluates the GROUP BY expressions for a row
+func (a *groupAggregator) keys(tuple *Tuple) ([]interface{}, error) {
+	keys := make([]interface{}, len(a.groupByKeys))
+	for i, expr := range a.groupByKeys {
+		value, err := a.evaluator.Evaluate(expr, tuple)
+		if err != nil {
+			return nil, err
+		}
+		keys[i] = value
+	}
+	return keys, nil
+}
+
+// newGroup starts a group whose first row is tuple
+func (a *groupAggregator) newGroup(keys []interface{}, tuple *Tuple) *aggregateGroup {
+	group := &aggregateGroup{
+		keys:       keys,
+		first:      tuple,
+		aggregates: make([]*windowAggregate, len(a.aggFunctions)),
+		seen:       make([]map[string]bool, len(a.aggFunctions)),
+	}
+	for i, fn := range a.aggFunctions {
+		group.aggregates[i] = &windowAggregate{name: strings.ToUpper(fn.Name.Value)}
+		if fn.Distinct {
+			group.seen[i] = make(map[string]bool)
+		}
+	}
+	return group
+}
+
+// add adds a row to the aggregates of a group
+func (a *groupAggregator) add(group *aggregateGroup, tuple *Tuple) error {
+	for i, fn := range a.aggFunctions {
+		if len(fn.Arguments) != 1 {
+			return fmt.Errorf("%s requires 1 argument, got %d", fn.Name.Value, len(fn.Arguments))
+		}
+
+		var value interface{} = int64(1) // COUNT(*) counts rows, never NULL
+		if _, ok := fn.Arguments[0].(*parser.Wildcard); !ok {
+			var err error
+			if value, err = a.evaluator.Evaluate(fn.Arguments[0], tuple); err != nil {
+				return err
+			}
+		}
+
+		if seen := group.seen[i]; seen != nil && value != nil {
+			key := rowKey([]interface{}{value})
+			if seen[key] {
+				continue
+			}
+			seen[key] = true
+		}
+		if err := group.aggregates[i].add(value); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// result returns the output row of a group
+func (a *groupAggregator) result(group *aggregateGroup) *Tuple {
+	var input *TupleSchema
+	var values []interface{}
+	if group.first != nil {
+		input = group.first.Schema
+		values = append(values, group.first.Values...)
+	}
+	for _, agg := range group.aggregates {
+		values = append(values, agg.result())
+	}
+
+	schema, ok := a.schemas[input]
+	if !ok {
+		schema = a.outputSchema(input)
+		a.schemas[input] = schema
+	}
+	return NewTuple(schema, values)
+}
+
+// outputSchema returns the input schema with a column per aggregate call
+func (a *groupAggregator) outputSchema(input *TupleSchema) *TupleSchema {
+	var columns []ColumnInfo
+	if input != nil {
+		columns = append(columns, input.Columns...)
+	}
+	for _, fn := range a.aggFunctions {
+		columns = append(columns, ColumnInfo{
+			Name:     fn.String(),
+			Type:     windowColumnType(fn, input),
+			Nullable: true,
+		})
+	}
+	return NewTupleSchema(columns)
+}
+
+// HashAggregateOperator groups its input by hashing the GROUP BY values and
+// returns one row per group, in the order the groups were first seen.
+// Without GROUP BY all rows form one group, which exists even for an empty
+// input.
+type HashAggregateOperator struct {
+	child      PhysicalOperator
+	aggregator *groupAggregator
+	groups     []*aggregateGroup
+	iterPos    int
+	closed     bool
 }
 
 // NewHashAggregateOperator creates a new hash aggregate operator
 func NewHashAggregateOperator(
 	child PhysicalOperator,
 	groupByKeys []parser.Expression,
-	aggFunctions []parser.Expression,
+	aggFunctions []*parser.FunctionCall,
 ) *HashAggregateOperator {
 	return &HashAggregateOperator{
-		child:        child,
-		groupByKeys:  groupByKeys,
-		aggFunctions: aggFunctions,
-		evaluator:    NewExpressionEvaluator(),
-		closed:       true,
+		child:      child,
+		aggregator: newGroupAggregator(groupByKeys, aggFunctions),
+		closed:     true,
 	}
 }
 
-// Open initializes the operator
+// Open consumes all input and computes the aggregates of every group
 func (op *HashAggregateOperator) Open(ctx *ExecutionContext) error {
 	if !op.closed {
 		return nil
 	}
 
+	op.aggregator.bind(ctx)
+
 	if err := op.child.Open(ctx); err != nil {
 		return err
 	}
 
-	// Initialize hash table
-	op.hashTable = make(map[interface{}]*AggregateState)
+	op.groups = nil
+	hashTable := make(map[string]*aggregateGroup)
+	for {
+		if ctx.IsTimedOut() {
+			op.child.Close()
+			return ErrExecutionTimeout
+		}
+		tuple, err := op.child.Next()
+		if err != nil {
+			op.child.Close()
+			return err
+		}
+		if tuple == nil {
+			break
+		}
 
-	// TODO: Build hash table by consuming all input
-	// For each input tuple:
-	//   - Compute group key
-	//   - Update aggregate state
+		keys, err := op.aggregator.keys(tuple)
+		if err != nil {
+			op.child.Close()
+			return err
+		}
+		key := rowKey(keys)
+		group, found := hashTable[key]
+		if !found {
+			group = op.aggregator.newGroup(keys, tuple)
+			hashTable[key] = group
+			op.groups = append(op.groups, group)
+		}
+		if err := op.aggregator.add(group, tuple); err != nil {
+			op.child.Close()
+			return err
+		}
+	}
 
-	// Initialize iterator
-	op.iterator = make([]interface{}, 0, len(op.hashTable))
-	for key := range op.hashTable {
-		op.iterator = append(op.iterator, key)
+	if len(op.groups) == 0 && len(op.aggregator.groupByKeys) == 0 {
+		op.groups = append(op.groups, op.aggregator.newGroup(nil, nil))
 	}
 	op.iterPos = 0
 
@@ -60,19 +227,19 @@ func (op *HashAggregateOperator) Open(ctx *ExecutionContext) error {
 	return nil
 }
 
-// Next returns the next aggregated tuple
+// Next returns the next group's row
 func (op *HashAggregateOperator) Next() (*Tuple, error) {
 	if op.closed {
 		return nil, ErrOperatorClosed
 	}
 
-	if op.iterPos >= len(op.iterator) {
+	if op.iterPos >= len(op.groups) {
 		return nil, nil // EOF
 	}
 
-	// TODO: Return next group's aggregated result
+	group := op.groups[op.iterPos]
 	op.iterPos++
-	return nil, nil
+	return op.aggregator.result(group), nil
 }
 
 // Close releases resources
@@ -82,8 +249,7 @@ func (op *HashAggregateOperator) Close() error {
 	}
 
 	err := op.child.Close()
-	op.hashTable = nil
-	op.iterator = nil
+	op.groups = nil
 	op.closed = true
 	return err
 }
@@ -98,31 +264,29 @@ func (op *HashAggregateOperator) EstimatedCost() float64 {
 	return op.child.EstimatedCost()
 }
 
-// SortAggregateOperator implements sort-based aggregation
+// SortAggregateOperator aggregates input that arrives sorted on the GROUP
+// BY values, so each group is a run of consecutive rows and is returned as
+// soon as the next group starts
 type SortAggregateOperator struct {
-	child        PhysicalOperator
-	groupByKeys  []parser.Expression
-	aggFunctions []parser.Expression
-	sortedInput  []*Tuple
-	evaluator    *ExpressionEvaluator
-	currentPos   int
-	currentGroup interface{}
-	currentAgg   *AggregateState
-	closed       bool
+	child      PhysicalOperator
+	aggregator *groupAggregator
+	ctx        *ExecutionContext
+	current    *aggregateGroup
+	emitted    bool // whether a row was returned, for the group of an empty input
+	done       bool
+	closed     bool
 }
 
 // NewSortAggregateOperator creates a new sort aggregate operator
 func NewSortAggregateOperator(
 	child PhysicalOperator,
 	groupByKeys []parser.Expression,
-	aggFunctions []parser.Expression,
+	aggFunctions []*parser.FunctionCall,
 ) *SortAggregateOperator {
 	return &SortAggregateOperator{
-		child:        child,
-		groupByKeys:  groupByKeys,
-		aggFunctions: aggFunctions,
-		evaluator:    NewExpressionEvaluator(),
-		closed:       true,
+		child:      child,
+		aggregator: newGroupAggregator(groupByKeys, aggFunctions),
+		closed:     true,
 	}
 }
 
@@ -132,26 +296,68 @@ func (op *SortAggregateOperator) Open(ctx *ExecutionContext) error {
 		return nil
 	}
 
+	op.aggregator.bind(ctx)
+
 	if err := op.child.Open(ctx); err != nil {
 		return err
 	}
 
-	// TODO: Sort input by group keys
-	op.sortedInput = make([]*Tuple, 0)
-	op.currentPos = 0
-
+	op.ctx = ctx
+	op.current = nil
+	op.emitted = false
+	op.done = false
 	op.closed = false
 	return nil
 }
 
-// Next returns the next aggregated tuple
+// Next reads the rows of the next group and returns its row
 func (op *SortAggregateOperator) Next() (*Tuple, error) {
 	if op.closed {
 		return nil, ErrOperatorClosed
 	}
 
-	// TODO: Process sorted groups
-	return nil, nil // EOF for now
+	for !op.done {
+		if op.ctx.IsTimedOut() {
+			return nil, ErrExecutionTimeout
+		}
+		tuple, err := op.child.Next()
+		if err != nil {
+			return nil, err
+		}
+		if tuple == nil {
+			op.done = true
+			break
+		}
+
+		keys, err := op.aggregator.keys(tuple)
+		if err != nil {
+			return nil, err
+		}
+
+		var finished *aggregateGroup
+		if op.current == nil || compareKeys(keys, op.current.keys) != 0 {
+			finished = op.current
+			op.current = op.aggregator.newGroup(keys, tuple)
+		}
+		if err := op.aggregator.add(op.current, tuple); err != nil {
+			return nil, err
+		}
+		if finished != nil {
+			op.emitted = true
+			return op.aggregator.result(finished), nil
+		}
+	}
+
+	if op.current == nil && !op.emitted && len(op.aggregator.groupByKeys) == 0 {
+		op.current = op.aggregator.newGroup(nil, nil)
+	}
+	if op.current == nil {
+		return nil, nil // EOF
+	}
+	group := op.current
+	op.current = nil
+	op.emitted = true
+	return op.aggregator.result(group), nil
 }
 
 // Close releases resources
@@ -161,7 +367,7 @@ func (op *SortAggregateOperator) Close() error {
 	}
 
 	err := op.child.Close()
-	op.sortedInput = nil
+	op.current = nil
 	op.closed = true
 	return err
 }
@@ -177,10 +383,11 @@ func (op *SortAggregateOperator) EstimatedCost() float64 {
 	return op.child.EstimatedCost() * 1.5
 }
 
-// SortOperator implements external merge sort
+// SortOperator sorts its input on the ORDER BY keys of a query. NULLs sort
+// first in ascending order; rows with equal keys keep their input order.
 type SortOperator struct {
 	child      PhysicalOperator
-	sortKeys   []parser.Expression
+	sortKeys   []optimizer.SortKey
 	sortedData []*Tuple
 	evaluator  *ExpressionEvaluator
 	currentPos int
@@ -188,7 +395,7 @@ type SortOperator struct {
 }
 
 // NewSortOperator creates a new sort operator
-func NewSortOperator(child PhysicalOperator, sortKeys []parser.Expression) *SortOperator {
+func NewSortOperator(child PhysicalOperator, sortKeys []optimizer.SortKey) *SortOperator {
 	return &SortOperator{
 		child:     child,
 		sortKeys:  sortKeys,
@@ -197,19 +404,65 @@ func NewSortOperator(child PhysicalOperator, sortKeys []parser.Expression) *Sort
 	}
 }
 
-// Open initializes the operator
+// Open reads and sorts all input rows
 func (op *SortOperator) Open(ctx *ExecutionContext) error {
 	if !op.closed {
 		return nil
 	}
 
+	op.evaluator.Bind(ctx)
+
 	if err := op.child.Open(ctx); err != nil {
 		return err
 	}
 
-	// TODO: Sort all input tuples
-	// For now, just collect input
-	op.sortedData = make([]*Tuple, 0)
+	type sortRow struct {
+		tuple *Tuple
+		keys  []interface{}
+	}
+	var rows []sortRow
+	for {
+		if ctx.IsTimedOut() {
+			op.child.Close()
+			return ErrExecutionTimeout
+		}
+		tuple, err := op.child.Next()
+		if err != nil {
+			op.child.Close()
+			return err
+		}
+		if tuple == nil {
+			break
+		}
+
+		row := sortRow{tuple: tuple, keys: make([]interface{}, len(op.sortKeys))}
+		for i, key := range op.sortKeys {
+			expr, _ := key.Expr.(parser.Expression)
+			if row.keys[i], err = op.evaluator.Evaluate(expr, tuple); err != nil {
+				op.child.Close()
+				return err
+			}
+		}
+		rows = append(rows, row)
+	}
+
+	sort.SliceStable(rows, func(a, b int) bool {
+		for i, key := range op.sortKeys {
+			cmp := compareValues(rows[a].keys[i], rows[b].keys[i])
+			if key.Descending {
+				cmp = -cmp
+			}
+			if cmp != 0 {
+				return cmp < 0
+			}
+		}
+		return false
+	})
+
+	op.sortedData = make([]*Tuple, len(rows))
+	for i, row := range rows {
+		op.sortedData[i] = row.tuple
+	}
 	op.currentPos = 0
 
 	op.closed = false
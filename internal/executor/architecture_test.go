@@ -1,7 +1,17 @@
 package executor
 
 import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
 	"testing"
+	"time"
+
+	"relational-db/internal/compiler"
+	"relational-db/internal/optimizer"
+	"relational-db/internal/parser"
+	"relational-db/internal/storage"
 )
 
 // TestResultBuilder tests the Result Set Builder component
@@ -424,3 +434,1842 @@ func TestIsolationLevels(t *testing.T) {
 
 	te.CommitTransaction(txn.ID)
 }
+
+func TestSessionTransactionControl(t *testing.T) {
+	te := NewTransactionExecutor(NewExecutor(nil, nil), NewLockManager())
+	session := te.NewSession("conn-1")
+
+	run := func(sql string) error {
+		stmt, err := parser.ParseSQL(sql)
+		if err != nil {
+			t.Fatalf("%s: parse failed: %v", sql, err)
+		}
+		return session.Execute(stmt)
+	}
+
+	// Transaction control outside a transaction block
+	for _, sql := range []string{"COMMIT", "ROLLBACK", "SAVEPOINT sp", "RELEASE SAVEPOINT sp", "ROLLBACK TO SAVEPOINT sp"} {
+		if err := run(sql); err == nil {
+			t.Errorf("%s: expected error outside a transaction", sql)
+		}
+	}
+
+	if err := run("BEGIN ISOLATION LEVEL SERIALIZABLE"); err != nil {
+		t.Fatalf("BEGIN failed: %v", err)
+	}
+	txn := session.Transaction()
+	if txn == nil || txn.IsolationLevel != Serializable {
+		t.Fatalf("expected a SERIALIZABLE transaction, got %+v", txn)
+	}
+	if err := run("BEGIN"); err == nil {
+		t.Error("expected error for nested BEGIN")
+	}
+
+	for _, sql := range []string{"SAVEPOINT a", "SAVEPOINT b", "SAVEPOINT c"} {
+		if err := run(sql); err != nil {
+			t.Fatalf("%s failed: %v", sql, err)
+		}
+	}
+
+	// Rolling back to b keeps b but destroys c
+	if err := run("ROLLBACK TO SAVEPOINT b"); err != nil {
+		t.Fatalf("ROLLBACK TO failed: %v", err)
+	}
+	if _, ok := txn.Savepoints["b"]; !ok {
+		t.Error("expected savepoint b to survive ROLLBACK TO")
+	}
+	if _, ok := txn.Savepoints["c"]; ok {
+		t.Error("expected savepoint c to be destroyed")
+	}
+
+	// Releasing a destroys a and b
+	if err := run("RELEASE a"); err != nil {
+		t.Fatalf("RELEASE failed: %v", err)
+	}
+	if len(txn.Savepoints) != 0 {
+		t.Errorf("expected no savepoints, got %d", len(txn.Savepoints))
+	}
+	if err := run("ROLLBACK TO b"); err == nil {
+		t.Error("expected error rolling back to a released savepoint")
+	}
+
+	if err := run("COMMIT"); err != nil {
+		t.Fatalf("COMMIT failed: %v", err)
+	}
+	if session.InTransaction() || len(te.ListActiveTransactions()) != 0 {
+		t.Error("expected the transaction to be closed after COMMIT")
+	}
+
+	// BEGIN without a level uses the executor default; Close rolls back
+	te.SetIsolationLevel(RepeatableRead)
+	if err := run("START TRANSACTION"); err != nil {
+		t.Fatalf("START TRANSACTION failed: %v", err)
+	}
+	if level := session.Transaction().IsolationLevel; level != RepeatableRead {
+		t.Errorf("expected REPEATABLE_READ, got %s", level)
+	}
+	if err := session.Close(); err != nil {
+		t.Fatalf("Close failed: %v", err)
+	}
+	if session.InTransaction() || len(te.ListActiveTransactions()) != 0 {
+		t.Error("expected Close to roll back the open transaction")
+	}
+}
+
+// memoryStorage is an in-memory storage engine for catalog tests
+type memoryStorage struct {
+	pages  map[storage.PageID][]byte
+	nextID storage.PageID
+}
+
+func newMemoryStorage() *memoryStorage {
+	return &memoryStorage{pages: make(map[storage.PageID][]byte), nextID: 1}
+}
+
+func (ms *memoryStorage) AllocatePage() (storage.PageID, error) {
+	id := ms.nextID
+	ms.nextID++
+	ms.pages[id] = make([]byte, 4096)
+	return id, nil
+}
+
+func (ms *memoryStorage) DeallocatePage(id storage.PageID) error {
+	delete(ms.pages, id)
+	return nil
+}
+
+func (ms *memoryStorage) ReadPage(id storage.PageID) (*storage.Page, error) {
+	data, exists := ms.pages[id]
+	if !exists {
+		return nil, storage.ErrInvalidPageID
+	}
+	return &storage.Page{ID: id, Data: append([]byte(nil), data...)}, nil
+}
+
+func (ms *memoryStorage) WritePage(page *storage.Page) error {
+	if _, exists := ms.pages[page.ID]; !exists {
+		return fmt.Errorf("page %d not allocated", page.ID)
+	}
+	ms.pages[page.ID] = append([]byte(nil), page.Data...)
+	return nil
+}
+
+func (ms *memoryStorage) Sync() error  { return nil }
+func (ms *memoryStorage) Close() error { return nil }
+func (ms *memoryStorage) Stats() storage.StorageStats {
+	return storage.StorageStats{TotalPages: uint64(len(ms.pages))}
+}
+
+// TestSystemCatalogPersistence tests that the catalog survives a restart
+func TestSystemCatalogPersistence(t *testing.T) {
+	engine := newMemoryStorage()
+
+	sm := NewSchemaManager()
+	catalog := NewSystemCatalog(engine, sm, NewCatalogManager(sm), 4096)
+	if err := catalog.Bootstrap(); err != nil {
+		t.Fatalf("failed to bootstrap catalog: %v", err)
+	}
+
+	usersSchema := &TableSchema{
+		TableName: "users",
+		Columns: []ColumnInfo{
+			{Name: "tenant", Type: TypeInt},
+			{Name: "id", Type: TypeBigInt},
+			{Name: "email", Type: TypeString, Nullable: true},
+		},
+		PrimaryKey: []string{"tenant", "id"},
+		Clustered:  true,
+	}
+	if err := catalog.CreateTable(usersSchema); err != nil {
+		t.Fatalf("failed to create table: %v", err)
+	}
+
+	if err := catalog.CreateIndex(&IndexCatalogEntry{
+		IndexName: "idx_users_email",
+		TableName: "users",
+		Columns:   []string{"email"},
+		IsUnique:  true,
+	}); err != nil {
+		t.Fatalf("failed to create index: %v", err)
+	}
+
+	if err := catalog.AddConstraint("users", &Constraint{
+		Name:    "uq_email",
+		Type:    UniqueConstraint,
+		Columns: []string{"email"},
+	}); err != nil {
+		t.Fatalf("failed to add constraint: %v", err)
+	}
+
+	if err := catalog.CreateTable(&TableSchema{TableName: "sys_evil", Columns: usersSchema.Columns}); err == nil {
+		t.Error("expected reserved system table prefix to be rejected")
+	}
+
+	// Restart: fresh managers over the same storage
+	sm2 := NewSchemaManager()
+	cm2 := NewCatalogManager(sm2)
+	reloaded := NewSystemCatalog(engine, sm2, cm2, 4096)
+	if err := reloaded.Bootstrap(); err != nil {
+		t.Fatalf("failed to reload catalog: %v", err)
+	}
+
+	schema, err := sm2.GetSchema("users")
+	if err != nil {
+		t.Fatalf("expected users schema after restart: %v", err)
+	}
+	if len(schema.Columns) != 3 || schema.Columns[2].Name != "email" || !schema.Columns[2].Nullable {
+		t.Errorf("unexpected columns after restart: %+v", schema.Columns)
+	}
+	if len(schema.PrimaryKey) != 2 || schema.PrimaryKey[0] != "tenant" || schema.PrimaryKey[1] != "id" {
+		t.Errorf("expected primary key [tenant id], got %v", schema.PrimaryKey)
+	}
+	if !schema.Clustered {
+		t.Error("expected clustered flag to survive restart")
+	}
+
+	if _, err := cm2.GetIndex("idx_users_email"); err != nil {
+		t.Errorf("expected index after restart: %v", err)
+	}
+	if constraints, _ := sm2.GetConstraints("users"); len(constraints) != 1 {
+		t.Errorf("expected 1 constraint after restart, got %d", len(constraints))
+	}
+
+	// DDL after restart keeps working and table IDs keep increasing
+	if err := reloaded.DropTable("users"); err != nil {
+		t.Fatalf("failed to drop table: %v", err)
+	}
+	if err := reloaded.CreateTable(&TableSchema{
+		TableName: "orders",
+		Columns:   []ColumnInfo{{Name: "id", Type: TypeBigInt}},
+	}); err != nil {
+		t.Fatalf("failed to create table after restart: %v", err)
+	}
+	if entry, _ := cm2.GetTable("orders"); entry == nil || entry.TableID != 2 {
+		t.Errorf("expected orders to get table ID 2, got %+v", entry)
+	}
+
+	// Old catalog pages are released; only the root, the current chain and
+	// the rows page of orders remain
+	if len(engine.pages) != 3 {
+		t.Errorf("expected 3 catalog pages in use, got %d", len(engine.pages))
+	}
+}
+
+// TestSystemCatalogKeepsForeignData tests that Bootstrap never overwrites
+// pages it cannot read as a catalog
+func TestSystemCatalogKeepsForeignData(t *testing.T) {
+	engine := newMemoryStorage()
+	pageID, _ := engine.AllocatePage()
+	data := []byte("user data")
+	engine.WritePage(&storage.Page{ID: pageID, Data: data})
+
+	sm := NewSchemaManager()
+	if err := NewSystemCatalog(engine, sm, NewCatalogManager(sm), 4096).Bootstrap(); err == nil {
+		t.Error("expected a root page without a catalog to be rejected")
+	}
+	if string(engine.pages[pageID]) != string(data) {
+		t.Error("expected the root page to be left as it was")
+	}
+
+	// An unreadable root page is an error, not a new database
+	delete(engine.pages, pageID)
+	engine.AllocatePage()
+	if err := NewSystemCatalog(engine, sm, NewCatalogManager(sm), 4096).Bootstrap(); !errors.Is(err, storage.ErrInvalidPageID) {
+		t.Errorf("expected the read error of the root page, got %v", err)
+	}
+	if len(engine.pages) != 1 {
+		t.Errorf("expected no pages to be written, got %d", len(engine.pages))
+	}
+}
+
+func TestPartitionedTable(t *testing.T) {
+	engine := newMemoryStorage()
+
+	sm := NewSchemaManager()
+	cm := NewCatalogManager(sm)
+	catalog := NewSystemCatalog(engine, sm, cm, 4096)
+	if err := catalog.Bootstrap(); err != nil {
+		t.Fatalf("failed to bootstrap catalog: %v", err)
+	}
+
+	schema := &TableSchema{
+		TableName: "readings",
+		Columns: []ColumnInfo{
+			{Name: "id", Type: TypeBigInt},
+			{Name: "region", Type: TypeString},
+		},
+		PrimaryKey: []string{"id"},
+		Clustered:  true,
+	}
+	scheme := &compiler.PartitionScheme{
+		Method: parser.PartitionByList,
+		Column: "region",
+		Partitions: []*compiler.PartitionBound{
+			{Name: "readings_eu", Values: []interface{}{"de", "fr"}},
+			{Name: "readings_us", Values: []interface{}{"us"}},
+		},
+	}
+	if err := catalog.CreatePartitionedTable(schema, scheme); err != nil {
+		t.Fatalf("failed to create partitioned table: %v", err)
+	}
+
+	partitions := cm.ListPartitions("readings")
+	if len(partitions) != 2 || partitions[0].ParentTable != "readings" {
+		t.Fatalf("expected 2 partitions of readings, got %+v", partitions)
+	}
+	if err := cm.DropTable("readings_eu"); err == nil {
+		t.Error("expected dropping a single partition to fail")
+	}
+
+	// INSERT routing stores each row in its partition's index
+	exec := NewExecutor(nil, nil)
+	for _, partition := range partitions {
+		partitionSchema, err := cm.PartitionSchema(partition.TableName)
+		if err != nil {
+			t.Fatalf("failed to get partition schema: %v", err)
+		}
+		index, err := NewClusteredIndex(partitionSchema)
+		if err != nil {
+			t.Fatalf("failed to create partition index: %v", err)
+		}
+		exec.RegisterClusteredIndex(index)
+	}
+
+	for i, region := range []string{"de", "us", "fr"} {
+		target, err := exec.InsertRow(cm, "readings", []interface{}{int64(i), region})
+		if err != nil {
+			t.Fatalf("insert failed: %v", err)
+		}
+		if want := map[string]string{"de": "readings_eu", "fr": "readings_eu", "us": "readings_us"}[region]; target != want {
+			t.Errorf("expected %s to be routed to %s, got %s", region, want, target)
+		}
+	}
+	if _, err := exec.InsertRow(cm, "readings", []interface{}{int64(9), "jp"}); err == nil {
+		t.Error("expected error for a value no partition accepts")
+	}
+
+	// Scanning the partitions appends their rows
+	op, err := exec.buildOperatorTree(&optimizer.PhysicalPlan{
+		Type:        optimizer.PhysicalPlanTypeClusteredIndexScan,
+		TableName:   "readings",
+		Partitioned: true,
+		Partitions:  []string{"readings_eu", "readings_us"},
+	})
+	if err != nil {
+		t.Fatalf("failed to build partition scan: %v", err)
+	}
+	ctx := NewExecutionContext(context.Background(), DefaultExecutorConfig())
+	if err := op.Open(ctx); err != nil {
+		t.Fatalf("open failed: %v", err)
+	}
+	rows := 0
+	for {
+		tuple, err := op.Next()
+		if err != nil {
+			t.Fatalf("next failed: %v", err)
+		}
+		if tuple == nil {
+			break
+		}
+		rows++
+	}
+	op.Close()
+	if rows != 3 {
+		t.Errorf("expected 3 rows across partitions, got %d", rows)
+	}
+
+	// Partitions survive a restart
+	sm2 := NewSchemaManager()
+	cm2 := NewCatalogManager(sm2)
+	if err := NewSystemCatalog(engine, sm2, cm2, 4096).Bootstrap(); err != nil {
+		t.Fatalf("failed to reload catalog: %v", err)
+	}
+	entry, err := cm2.GetTable("readings")
+	if err != nil || entry.Partitioning == nil {
+		t.Fatalf("expected partitioned readings after restart: %v", err)
+	}
+	if len(entry.Partitioning.Partitions) != 2 || len(entry.Partitioning.Partitions[0].Values) != 2 {
+		t.Errorf("unexpected partitions after restart: %+v", entry.Partitioning.Partitions)
+	}
+	if target, err := cm2.RouteRow("readings", []interface{}{int64(5), "us"}); err != nil || target != "readings_us" {
+		t.Errorf("expected us to route to readings_us after restart, got %s (%v)", target, err)
+	}
+	if child, err := cm2.GetTable("readings_us"); err != nil || child.TableID != partitions[1].TableID {
+		t.Errorf("expected partition table ID to survive restart, got %+v", child)
+	}
+}
+
+func TestCreateTableStatement(t *testing.T) {
+	engine := newMemoryStorage()
+
+	sm := NewSchemaManager()
+	cm := NewCatalogManager(sm)
+	catalog := NewSystemCatalog(engine, sm, cm, 4096)
+	if err := catalog.Bootstrap(); err != nil {
+		t.Fatalf("failed to bootstrap catalog: %v", err)
+	}
+
+	exec := NewExecutor(nil, nil)
+	planner := NewQueryPlanner(cm, 10)
+	create := func(sql string) error {
+		stmt, err := parser.ParseSQL(sql)
+		if err != nil {
+			t.Fatalf("failed to parse %q: %v", sql, err)
+		}
+		return exec.CreateTable(catalog, planner, stmt.(*parser.CreateTableStatement))
+	}
+
+	// A clustered table gets an index holding its rows, and its TTL policy
+	// is picked up by the expiry worker
+	if err := create("CREATE TABLE sessions (id INTEGER PRIMARY KEY, created_at TIMESTAMP) WITH (clustered = true, ttl_column = created_at, ttl = '1 day')"); err != nil {
+		t.Fatalf("create table failed: %v", err)
+	}
+	schema, err := sm.GetSchema("sessions")
+	if err != nil || !schema.Clustered || schema.TTL == nil || schema.TTL.Duration != 24*time.Hour {
+		t.Fatalf("expected clustered sessions with a one day TTL, got %+v (%v)", schema, err)
+	}
+	if err := create("CREATE TABLE sessions (id INTEGER PRIMARY KEY) WITH (clustered = true)"); err == nil {
+		t.Error("expected an existing table name to be rejected")
+	}
+	if err := create("CREATE TABLE broken (id INTEGER) WITH (clustered = true)"); err == nil {
+		t.Error("expected a clustered table without a primary key to be rejected")
+	}
+	if _, err := sm.GetSchema("broken"); err == nil {
+		t.Error("expected a rejected table to leave nothing behind")
+	}
+
+	now := time.Now()
+	for i, created := range []time.Time{now.Add(-48 * time.Hour), now, now.Add(-72 * time.Hour)} {
+		if _, err := exec.InsertRow(cm, "sessions", []interface{}{int64(i + 1), created}); err != nil {
+			t.Fatalf("insert failed: %v", err)
+		}
+	}
+	index, err := exec.GetClusteredIndex("sessions")
+	if err != nil || index.Len() != 3 {
+		t.Fatalf("expected 3 rows in the sessions index, got %v", err)
+	}
+
+	worker := NewTTLWorker(cm, NewTransactionExecutor(exec, NewLockManager()), time.Hour, 10)
+	if removed, err := worker.RunOnce(); err != nil || removed != 2 {
+		t.Errorf("expected 2 expired sessions, got %d (%v)", removed, err)
+	}
+	if index.Len() != 1 || index.Get([]interface{}{int64(2)}) == nil {
+		t.Error("expected only the live session to remain")
+	}
+
+	// Each partition of a clustered table gets an index of its own, and
+	// expiry covers every partition
+	if err := create("CREATE TABLE readings (id INTEGER PRIMARY KEY, region VARCHAR(2), taken_at TIMESTAMP) PARTITION BY LIST (region) (PARTITION readings_eu VALUES IN ('de', 'fr'), PARTITION readings_us VALUES IN ('us')) WITH (clustered = true, ttl_column = taken_at, ttl = '1 day')"); err != nil {
+		t.Fatalf("create partitioned table failed: %v", err)
+	}
+	if _, err := exec.GetClusteredIndex("readings"); err == nil {
+		t.Error("expected a partitioned table to keep its rows in its partitions")
+	}
+	for i, region := range []string{"fr", "us", "de"} {
+		taken := now.Add(-48 * time.Hour)
+		if region == "de" {
+			taken = now
+		}
+		if _, err := exec.InsertRow(cm, "readings", []interface{}{int64(i + 1), region, taken}); err != nil {
+			t.Fatalf("insert failed: %v", err)
+		}
+	}
+	if index, err := exec.GetClusteredIndex("readings_eu"); err != nil || index.Len() != 2 {
+		t.Errorf("expected 2 rows in readings_eu, got %v", err)
+	}
+	if removed, err := worker.RunOnce(); err != nil || removed != 2 {
+		t.Errorf("expected 2 expired readings, got %d (%v)", removed, err)
+	}
+	for partition, rows := range map[string]int{"readings_eu": 1, "readings_us": 0} {
+		if index, err := exec.GetClusteredIndex(partition); err != nil || index.Len() != rows {
+			t.Errorf("expected %d rows left in %s (%v)", rows, partition, err)
+		}
+	}
+
+	// Tables that are not clustered, with or without a primary key, expire
+	// rows too
+	for _, sql := range []string{
+		"CREATE TABLE logs (id INTEGER PRIMARY KEY, logged_at TIMESTAMP) WITH (ttl_column = logged_at, ttl = '1 day')",
+		"CREATE TABLE events (id INTEGER, logged_at TIMESTAMP) WITH (ttl_column = logged_at, ttl = '1 day')",
+	} {
+		if err := create(sql); err != nil {
+			t.Fatalf("create table failed: %v", err)
+		}
+	}
+	for _, table := range []string{"logs", "events"} {
+		for i, logged := range []time.Time{now, now.Add(-48 * time.Hour), now.Add(-48 * time.Hour)} {
+			if _, err := exec.InsertRow(cm, table, []interface{}{int64(i + 1), logged}); err != nil {
+				t.Fatalf("insert failed: %v", err)
+			}
+		}
+	}
+	if removed, err := worker.RunOnce(); err != nil || removed != 4 {
+		t.Errorf("expected 4 expired log rows, got %d (%v)", removed, err)
+	}
+	for _, table := range []string{"logs", "events"} {
+		if index, err := exec.GetClusteredIndex(table); err != nil || index.Len() != 1 {
+			t.Errorf("expected only the live row left in %s (%v)", table, err)
+		}
+	}
+
+	// Tables survive a restart
+	sm2 := NewSchemaManager()
+	if err := NewSystemCatalog(engine, sm2, NewCatalogManager(sm2), 4096).Bootstrap(); err != nil {
+		t.Fatalf("failed to reload catalog: %v", err)
+	}
+	if schema, err := sm2.GetSchema("sessions"); err != nil || schema.TTL == nil {
+		t.Errorf("expected sessions with its TTL after restart, got %+v (%v)", schema, err)
+	}
+}
+
+// TestQueryPlannerCache tests prepared plan caching and invalidation
+func TestQueryPlannerCache(t *testing.T) {
+	sm := NewSchemaManager()
+	cm := NewCatalogManager(sm)
+	users := &TableSchema{
+		TableName: "users",
+		Columns: []ColumnInfo{
+			{Name: "id", Type: TypeBigInt},
+			{Name: "name", Type: TypeString},
+		},
+		PrimaryKey: []string{"id"},
+	}
+	if err := sm.RegisterSchema(users); err != nil {
+		t.Fatalf("failed to register schema: %v", err)
+	}
+
+	planner := NewQueryPlanner(cm, 2)
+
+	first, err := planner.Prepare("SELECT id FROM users WHERE id = ?")
+	if err != nil {
+		t.Fatalf("prepare failed: %v", err)
+	}
+	if first.Plan == nil || first.ParameterCount != 1 {
+		t.Fatalf("expected an optimized plan with 1 parameter, got %+v", first)
+	}
+
+	// Whitespace, comments and keyword case do not matter
+	second, err := planner.Prepare("select id\n  from users -- by key\n where id = ?;")
+	if err != nil {
+		t.Fatalf("prepare failed: %v", err)
+	}
+	if second != first {
+		t.Error("expected the cached plan to be reused")
+	}
+	if stats := planner.Cache().Stats(); stats.Hits != 1 || stats.Misses != 1 {
+		t.Errorf("expected 1 hit and 1 miss, got %+v", stats)
+	}
+
+	// A schema change invalidates plans that reference the table
+	altered := *users
+	altered.Columns = append(altered.Columns, ColumnInfo{Name: "email", Type: TypeString})
+	if err := sm.UpdateSchema(&altered); err != nil {
+		t.Fatalf("failed to update schema: %v", err)
+	}
+	refreshed, err := planner.Refresh(first)
+	if err != nil {
+		t.Fatalf("refresh failed: %v", err)
+	}
+	if refreshed == first {
+		t.Error("expected a new plan after the schema changed")
+	}
+	if stats := planner.Cache().Stats(); stats.Invalidations != 1 {
+		t.Errorf("expected 1 invalidation, got %+v", stats)
+	}
+
+	// The least recently used plan is evicted once the cache is full
+	for _, sql := range []string{"SELECT name FROM users", "SELECT email FROM users"} {
+		if _, err := planner.Prepare(sql); err != nil {
+			t.Fatalf("prepare %q failed: %v", sql, err)
+		}
+	}
+	if stats := planner.Cache().Stats(); stats.Size != 2 || stats.Evictions != 1 {
+		t.Errorf("expected 2 cached plans and 1 eviction, got %+v", stats)
+	}
+
+	// Errors locate the failure in the SQL as written, not as normalized
+	_, err = planner.Prepare("select name\n  from   users -- by name\n where  missing = 1")
+	var compileErr *compiler.CompilationError
+	if !errors.As(err, &compileErr) || compileErr.Line != 3 || compileErr.Column != 9 {
+		t.Errorf("expected a compilation error at line 3, column 9, got %+v", compileErr)
+	}
+	for _, sql := range []string{"select name\n  from users where", "select name\n  from users where # = 1"} {
+		_, err := planner.Prepare(sql)
+		var parseErrs parser.ParseErrors
+		if !errors.As(err, &parseErrs) || parseErrs[0].Line != 2 {
+			t.Errorf("%q: expected a parse error on line 2, got %v", sql, err)
+		}
+	}
+}
+
+// TestAlterTable tests ALTER TABLE schema changes, row rewrites and plan invalidation
+func TestAlterTable(t *testing.T) {
+	engine := newMemoryStorage()
+
+	sm := NewSchemaManager()
+	cm := NewCatalogManager(sm)
+	catalog := NewSystemCatalog(engine, sm, cm, 4096)
+	if err := catalog.Bootstrap(); err != nil {
+		t.Fatalf("failed to bootstrap catalog: %v", err)
+	}
+
+	accounts := &TableSchema{
+		TableName: "accounts",
+		Columns: []ColumnInfo{
+			{Name: "id", Type: TypeBigInt},
+			{Name: "name", Type: TypeString, Nullable: true},
+		},
+		PrimaryKey: []string{"id"},
+		Clustered:  true,
+	}
+	if err := catalog.CreateTable(accounts); err != nil {
+		t.Fatalf("failed to create table: %v", err)
+	}
+
+	exec := NewExecutor(nil, nil)
+	index, err := NewClusteredIndex(accounts)
+	if err != nil {
+		t.Fatalf("failed to create index: %v", err)
+	}
+	exec.RegisterClusteredIndex(index)
+	for i, name := range []string{"ann", "bob", "ann"} {
+		if err := index.Insert([]interface{}{int64(i + 1), name}); err != nil {
+			t.Fatalf("insert failed: %v", err)
+		}
+	}
+
+	planner := NewQueryPlanner(cm, 10)
+	plan, err := planner.Prepare("SELECT name FROM accounts")
+	if err != nil {
+		t.Fatalf("prepare failed: %v", err)
+	}
+
+	alter := func(sql string) error {
+		stmt, err := parser.ParseSQL(sql)
+		if err != nil {
+			t.Fatalf("failed to parse %q: %v", sql, err)
+		}
+		alterStmt := stmt.(*parser.AlterTableStatement)
+		schema, err := sm.GetSchema(alterStmt.TableName.Value)
+		if err != nil {
+			return err
+		}
+		constraints, _ := sm.GetConstraints(alterStmt.TableName.Value)
+		change, err := AlterSchema(schema, constraints, alterStmt)
+		if err != nil {
+			return err
+		}
+		return exec.AlterTable(catalog, change)
+	}
+
+	rows := func(table string) [][]interface{} {
+		index, err := exec.GetClusteredIndex(table)
+		if err != nil {
+			t.Fatalf("expected clustered index for %s: %v", table, err)
+		}
+		var result [][]interface{}
+		iter := index.Scan(nil, nil)
+		for tuple := iter.Next(); tuple != nil; tuple = iter.Next() {
+			result = append(result, tuple.Values)
+		}
+		return result
+	}
+
+	// Added columns take their default in existing rows
+	if err := alter("ALTER TABLE accounts ADD COLUMN status VARCHAR(10) NOT NULL DEFAULT 'active', RENAME COLUMN name TO full_name"); err != nil {
+		t.Fatalf("alter failed: %v", err)
+	}
+	schema, _ := sm.GetSchema("accounts")
+	if len(schema.Columns) != 3 || schema.Columns[1].Name != "full_name" || schema.Columns[2].Nullable {
+		t.Errorf("unexpected columns after alter: %+v", schema.Columns)
+	}
+	if version, _ := sm.GetSchemaVersion("accounts"); version != 2 {
+		t.Errorf("expected schema version 2, got %d", version)
+	}
+	if got := rows("accounts"); len(got) != 3 || got[0][2] != "active" || got[0][1] != "ann" {
+		t.Errorf("unexpected rows after alter: %v", got)
+	}
+	if refreshed, err := planner.Refresh(plan); err == nil {
+		t.Errorf("expected the cached plan to be rebuilt and fail on the renamed column, got %+v", refreshed)
+	}
+
+	// A constraint existing rows violate leaves the table as it was
+	if err := alter("ALTER TABLE accounts ADD CONSTRAINT uq_name UNIQUE (full_name)"); err == nil {
+		t.Error("expected duplicate names to violate the new unique constraint")
+	}
+	if err := alter("ALTER TABLE accounts ADD CHECK (id > 1)"); err == nil {
+		t.Error("expected row 1 to violate the new check constraint")
+	}
+	if err := alter("ALTER TABLE accounts ADD COLUMN region TEXT NOT NULL"); err == nil {
+		t.Error("expected NOT NULL column without a default to fail on existing rows")
+	}
+	if version, _ := sm.GetSchemaVersion("accounts"); version != 2 {
+		t.Errorf("expected failed alters to keep schema version 2, got %d", version)
+	}
+
+	// Unnamed constraints are named after the table and columns
+	if err := alter("ALTER TABLE accounts ADD CHECK (id > 0), ADD UNIQUE (id, full_name)"); err != nil {
+		t.Fatalf("alter failed: %v", err)
+	}
+	constraints, _ := sm.GetConstraints("accounts")
+	if len(constraints) != 2 || constraints[0].Name != "accounts_check" || constraints[1].Name != "accounts_id_full_name_key" {
+		t.Errorf("unexpected constraints: %+v", constraints)
+	}
+	if err := alter("ALTER TABLE accounts DROP COLUMN full_name"); err == nil {
+		t.Error("expected dropping a column used by a constraint to fail")
+	}
+	if err := alter("ALTER TABLE accounts DROP CONSTRAINT accounts_id_full_name_key, DROP COLUMN full_name"); err != nil {
+		t.Fatalf("alter failed: %v", err)
+	}
+	if got := rows("accounts"); len(got) != 3 || len(got[0]) != 2 || got[0][1] != "active" {
+		t.Errorf("unexpected rows after drop column: %v", got)
+	}
+
+	// Renaming moves the catalog entry and the rows
+	if err := alter("ALTER TABLE accounts RENAME TO customers"); err != nil {
+		t.Fatalf("rename failed: %v", err)
+	}
+	if _, err := sm.GetSchema("accounts"); err == nil {
+		t.Error("expected accounts to be gone after rename")
+	}
+	if _, err := exec.GetClusteredIndex("accounts"); err == nil {
+		t.Error("expected the accounts index to be gone after rename")
+	}
+	if got := rows("customers"); len(got) != 3 {
+		t.Errorf("expected 3 rows in customers, got %d", len(got))
+	}
+
+	// The altered schema survives a restart
+	sm2 := NewSchemaManager()
+	if err := NewSystemCatalog(engine, sm2, NewCatalogManager(sm2), 4096).Bootstrap(); err != nil {
+		t.Fatalf("failed to reload catalog: %v", err)
+	}
+	reloaded, err := sm2.GetSchema("customers")
+	if err != nil {
+		t.Fatalf("expected customers after restart: %v", err)
+	}
+	if len(reloaded.Columns) != 2 || reloaded.Columns[1].Name != "status" || reloaded.Columns[1].TableName != "customers" {
+		t.Errorf("unexpected columns after restart: %+v", reloaded.Columns)
+	}
+	if constraints, _ := sm2.GetConstraints("customers"); len(constraints) != 1 || constraints[0].CheckExpression == "" {
+		t.Errorf("expected the check constraint after restart, got %+v", constraints)
+	}
+}
+
+// TestCreateIndex tests building secondary indexes from existing rows
+func TestCreateIndex(t *testing.T) {
+	engine := newMemoryStorage()
+
+	sm := NewSchemaManager()
+	cm := NewCatalogManager(sm)
+	catalog := NewSystemCatalog(engine, sm, cm, 4096)
+	if err := catalog.Bootstrap(); err != nil {
+		t.Fatalf("failed to bootstrap catalog: %v", err)
+	}
+
+	users := &TableSchema{
+		TableName: "users",
+		Columns: []ColumnInfo{
+			{Name: "id", Type: TypeBigInt},
+			{Name: "email", Type: TypeString, Nullable: true},
+			{Name: "city", Type: TypeString, Nullable: true},
+		},
+		PrimaryKey: []string{"id"},
+		Clustered:  true,
+	}
+	if err := catalog.CreateTable(users); err != nil {
+		t.Fatalf("failed to create table: %v", err)
+	}
+
+	exec := NewExecutor(nil, nil)
+	index, err := NewClusteredIndex(users)
+	if err != nil {
+		t.Fatalf("failed to create index: %v", err)
+	}
+	exec.RegisterClusteredIndex(index)
+	for _, row := range [][]interface{}{
+		{int64(1), "a@x", "paris"},
+		{int64(2), "b@x", "oslo"},
+		{int64(3), nil, "paris"},
+		{int64(4), nil, "rome"},
+	} {
+		if _, err := exec.InsertRow(cm, "users", row); err != nil {
+			t.Fatalf("insert failed: %v", err)
+		}
+	}
+
+	run := func(sql string) error {
+		stmt, err := parser.ParseSQL(sql)
+		if err != nil {
+			t.Fatalf("failed to parse %q: %v", sql, err)
+		}
+		switch stmt := stmt.(type) {
+		case *parser.CreateIndexStatement:
+			return exec.CreateIndex(catalog, stmt)
+		case *parser.DropIndexStatement:
+			return exec.DropIndex(catalog, stmt)
+		}
+		t.Fatalf("unexpected statement %T", stmt)
+		return nil
+	}
+
+	// Existing rows are indexed; NULLs do not conflict in a unique index
+	if err := run("CREATE UNIQUE INDEX idx_email ON users (email)"); err != nil {
+		t.Fatalf("create index failed: %v", err)
+	}
+	if err := run("CREATE INDEX idx_city ON users (city) USING hash"); err != nil {
+		t.Fatalf("create index failed: %v", err)
+	}
+	city, err := exec.GetSecondaryIndex("idx_city")
+	if err != nil {
+		t.Fatalf("expected idx_city: %v", err)
+	}
+	if got := city.Lookup([]interface{}{"paris"}); len(got) != 2 || got[0][0] != int64(1) || got[1][0] != int64(3) {
+		t.Errorf("expected rows 1 and 3 for paris, got %v", got)
+	}
+	if entry, _ := cm.GetIndex("idx_city"); entry == nil || entry.IndexType != HashIndex {
+		t.Errorf("expected a hash index entry, got %+v", entry)
+	}
+	if version, _ := sm.GetSchemaVersion("users"); version != 3 {
+		t.Errorf("expected each index to bump the schema version to 3, got %d", version)
+	}
+
+	if err := run("CREATE UNIQUE INDEX idx_city_unique ON users (city)"); err == nil {
+		t.Error("expected duplicate cities to fail a unique index")
+	}
+	if _, err := cm.GetIndex("idx_city_unique"); err == nil {
+		t.Error("expected the failed index to leave no catalog entry")
+	}
+	if err := run("CREATE INDEX idx_city ON users (email)"); err == nil {
+		t.Error("expected an existing index name to be rejected")
+	}
+	if err := run("CREATE INDEX IF NOT EXISTS idx_city ON users (email)"); err != nil {
+		t.Errorf("expected IF NOT EXISTS to ignore an existing index: %v", err)
+	}
+
+	// Inserts maintain the indexes and respect unique ones
+	if _, err := exec.InsertRow(cm, "users", []interface{}{int64(5), "a@x", "oslo"}); err == nil {
+		t.Error("expected a duplicate email to be rejected")
+	}
+	if index.Get([]interface{}{int64(5)}) != nil {
+		t.Error("expected the rejected row not to be stored")
+	}
+	if _, err := exec.InsertRow(cm, "users", []interface{}{int64(6), "c@x", "oslo"}); err != nil {
+		t.Fatalf("insert failed: %v", err)
+	}
+	if got := city.Lookup([]interface{}{"oslo"}); len(got) != 2 {
+		t.Errorf("expected 2 rows for oslo, got %v", got)
+	}
+
+	// Concurrent inserts of one email store exactly one row, fully indexed
+	email, err := exec.GetSecondaryIndex("idx_email")
+	if err != nil {
+		t.Fatalf("expected idx_email: %v", err)
+	}
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func(id int64) {
+			defer wg.Done()
+			exec.InsertRow(cm, "users", []interface{}{id, "d@x", "oslo"})
+		}(int64(10 + i))
+	}
+	wg.Wait()
+	if got := email.Lookup([]interface{}{"d@x"}); len(got) != 1 {
+		t.Fatalf("expected one row for d@x, got %v", got)
+	}
+	if index.Len() != email.Len() || index.Len() != city.Len() {
+		t.Errorf("expected every stored row indexed once, got %d rows, %d emails and %d cities",
+			index.Len(), email.Len(), city.Len())
+	}
+
+	// Indexed columns cannot be dropped; renaming one carries over to the index
+	stmt, _ := parser.ParseSQL("ALTER TABLE users RENAME COLUMN city TO town")
+	schema, _ := sm.GetSchema("users")
+	change, err := AlterSchema(schema, nil, stmt.(*parser.AlterTableStatement))
+	if err != nil {
+		t.Fatalf("alter failed: %v", err)
+	}
+	if err := exec.AlterTable(catalog, change); err != nil {
+		t.Fatalf("alter failed: %v", err)
+	}
+	if entry, _ := cm.GetIndex("idx_city"); entry.Columns[0] != "town" {
+		t.Errorf("expected idx_city over town, got %v", entry.Columns)
+	}
+	stmt, _ = parser.ParseSQL("ALTER TABLE users DROP COLUMN town")
+	schema, _ = sm.GetSchema("users")
+	if _, err := AlterSchema(schema, nil, stmt.(*parser.AlterTableStatement)); err == nil {
+		t.Error("expected dropping an indexed column to fail")
+	}
+
+	if err := run("DROP INDEX idx_email"); err != nil {
+		t.Fatalf("drop index failed: %v", err)
+	}
+	if _, err := exec.GetSecondaryIndex("idx_email"); err == nil {
+		t.Error("expected idx_email to be gone")
+	}
+	if err := run("DROP INDEX idx_email"); err == nil {
+		t.Error("expected dropping a missing index to fail")
+	}
+	if err := run("DROP INDEX IF EXISTS idx_email"); err != nil {
+		t.Errorf("expected IF EXISTS to ignore a missing index: %v", err)
+	}
+
+	// Indexes survive a restart
+	sm2 := NewSchemaManager()
+	if err := NewSystemCatalog(engine, sm2, NewCatalogManager(sm2), 4096).Bootstrap(); err != nil {
+		t.Fatalf("failed to reload catalog: %v", err)
+	}
+	reloaded, _ := sm2.GetSchema("users")
+	if len(reloaded.Indexes) != 1 || reloaded.Indexes[0].Name != "idx_city" || reloaded.Indexes[0].Columns[0] != "town" {
+		t.Errorf("unexpected indexes after restart: %+v", reloaded.Indexes)
+	}
+	table, _ := NewCompilerCatalog(NewCatalogManager(sm2)).GetTable("users")
+	if len(table.Indexes) != 1 || table.Indexes[0] != "idx_city" {
+		t.Errorf("expected the compiler to see idx_city, got %v", table.Indexes)
+	}
+}
+
+func TestViewsWithQuotedNames(t *testing.T) {
+	engine := newMemoryStorage()
+
+	sm := NewSchemaManager()
+	cm := NewCatalogManager(sm)
+	catalog := NewSystemCatalog(engine, sm, cm, 4096)
+	if err := catalog.Bootstrap(); err != nil {
+		t.Fatalf("failed to bootstrap catalog: %v", err)
+	}
+
+	exec := NewExecutor(nil, nil)
+	planner := NewQueryPlanner(cm, 10)
+	ctx := context.Background()
+	run := func(sql string) error {
+		stmt, err := parser.ParseSQL(sql)
+		if err != nil {
+			t.Fatalf("failed to parse %q: %v", sql, err)
+		}
+		switch stmt := stmt.(type) {
+		case *parser.CreateTableStatement:
+			return exec.CreateTable(catalog, planner, stmt)
+		case *parser.CreateViewStatement:
+			return exec.CreateView(ctx, catalog, planner, stmt)
+		case *parser.RefreshMaterializedViewStatement:
+			return exec.RefreshMaterializedView(ctx, catalog, planner, stmt)
+		}
+		t.Fatalf("unexpected statement %T", stmt)
+		return nil
+	}
+
+	// Names that are keywords or hold spaces keep their quotes when a view
+	// is planned from its printed definition
+	for _, sql := range []string{
+		`CREATE TABLE "order items" (id INTEGER PRIMARY KEY, "select" INTEGER) WITH (clustered = true)`,
+		`CREATE VIEW "big orders" AS SELECT "select" AS "from" FROM "order items" WHERE "select" > 1`,
+		`CREATE MATERIALIZED VIEW "order" AS SELECT * FROM "big orders"`,
+		`REFRESH MATERIALIZED VIEW "order"`,
+	} {
+		if err := run(sql); err != nil {
+			t.Fatalf("%s failed: %v", sql, err)
+		}
+	}
+	for _, sql := range []string{`SELECT "from" FROM "big orders"`, `SELECT * FROM "order"`} {
+		if _, err := planner.Prepare(sql); err != nil {
+			t.Errorf("%s failed: %v", sql, err)
+		}
+	}
+
+	// The definitions are read back after a restart
+	sm2 := NewSchemaManager()
+	cm2 := NewCatalogManager(sm2)
+	if err := NewSystemCatalog(engine, sm2, cm2, 4096).Bootstrap(); err != nil {
+		t.Fatalf("failed to reload catalog: %v", err)
+	}
+	if _, err := NewQueryPlanner(cm2, 10).Prepare(`SELECT "from" FROM "big orders"`); err != nil {
+		t.Errorf("expected the view to plan after restart: %v", err)
+	}
+}
+
+func TestIdentifierCase(t *testing.T) {
+	engine := newMemoryStorage()
+
+	sm := NewSchemaManager()
+	cm := NewCatalogManager(sm)
+	catalog := NewSystemCatalog(engine, sm, cm, 4096)
+	if err := catalog.Bootstrap(); err != nil {
+		t.Fatalf("failed to bootstrap catalog: %v", err)
+	}
+
+	exec := NewExecutor(nil, nil)
+	planner := NewQueryPlanner(cm, 10)
+	ctx := context.Background()
+	run := func(sql string) error {
+		stmt, err := parser.ParseSQL(sql)
+		if err != nil {
+			t.Fatalf("failed to parse %q: %v", sql, err)
+		}
+		if create, ok := stmt.(*parser.CreateTableStatement); ok {
+			return exec.CreateTable(catalog, planner, create)
+		}
+		plan, err := planner.Prepare(sql)
+		if err != nil {
+			return err
+		}
+		_, err = exec.ExecuteDML(ctx, planner, plan, nil)
+		return err
+	}
+	query := func(sql string) string {
+		t.Helper()
+		plan, err := planner.Prepare(sql)
+		if err != nil {
+			t.Fatalf("%s failed: %v", sql, err)
+		}
+		result, err := exec.Execute(ctx, plan.Plan)
+		if err != nil {
+			t.Fatalf("%s failed: %v", sql, err)
+		}
+		var rows [][]interface{}
+		for _, tuple := range result.Tuples {
+			rows = append(rows, tuple.Values)
+		}
+		return fmt.Sprint(rows)
+	}
+
+	// Unquoted names fold to lower case; quoted ones are kept as written
+	for _, sql := range []string{
+		`CREATE TABLE q ("Col" INTEGER PRIMARY KEY, col INTEGER)`,
+		`INSERT INTO q VALUES (1, 2), (2, 1)`,
+		`CREATE TABLE Mixed (Id INTEGER PRIMARY KEY)`,
+		`CREATE TABLE "Mixed" (id INTEGER PRIMARY KEY)`,
+		`INSERT INTO MIXED VALUES (1)`,
+		`INSERT INTO "Mixed" VALUES (2)`,
+	} {
+		if err := run(sql); err != nil {
+			t.Fatalf("%s failed: %v", sql, err)
+		}
+	}
+	for sql, want := range map[string]string{
+		`SELECT "Col" FROM q WHERE col = 2`: "[[1]]",
+		`SELECT "Col" FROM q WHERE COL = 2`: "[[1]]",
+		`SELECT col FROM q WHERE "Col" = 2`: "[[1]]",
+		`SELECT ID FROM Mixed`:              "[[1]]",
+		`SELECT id FROM mixed`:              "[[1]]",
+		`SELECT id FROM "mixed"`:            "[[1]]",
+		`SELECT id FROM "Mixed"`:            "[[2]]",
+	} {
+		if got := query(sql); got != want {
+			t.Errorf("%s: expected %s, got %s", sql, want, got)
+		}
+	}
+	if _, err := planner.Prepare(`SELECT "ID" FROM Mixed`); err == nil {
+		t.Error("expected a quoted name to match its case exactly")
+	}
+	if err := run(`CREATE TABLE MIXED (id INTEGER)`); err == nil {
+		t.Error("expected MIXED to name the existing table mixed")
+	}
+}
+
+func TestViews(t *testing.T) {
+	engine := newMemoryStorage()
+
+	sm := NewSchemaManager()
+	cm := NewCatalogManager(sm)
+	catalog := NewSystemCatalog(engine, sm, cm, 4096)
+	if err := catalog.Bootstrap(); err != nil {
+		t.Fatalf("failed to bootstrap catalog: %v", err)
+	}
+
+	users := &TableSchema{
+		TableName: "users",
+		Columns: []ColumnInfo{
+			{Name: "id", Type: TypeBigInt},
+			{Name: "name", Type: TypeString, Nullable: true},
+		},
+		PrimaryKey: []string{"id"},
+		Clustered:  true,
+	}
+	if err := catalog.CreateTable(users); err != nil {
+		t.Fatalf("failed to create table: %v", err)
+	}
+
+	exec := NewExecutor(nil, nil)
+	index, err := NewClusteredIndex(users)
+	if err != nil {
+		t.Fatalf("failed to create index: %v", err)
+	}
+	exec.RegisterClusteredIndex(index)
+	for _, row := range [][]interface{}{{int64(1), "ann"}, {int64(2), "bob"}, {int64(3), "cy"}} {
+		if _, err := exec.InsertRow(cm, "users", row); err != nil {
+			t.Fatalf("insert failed: %v", err)
+		}
+	}
+
+	planner := NewQueryPlanner(cm, 10)
+	ctx := context.Background()
+	run := func(sql string) error {
+		stmt, err := parser.ParseSQL(sql)
+		if err != nil {
+			t.Fatalf("failed to parse %q: %v", sql, err)
+		}
+		switch stmt := stmt.(type) {
+		case *parser.CreateViewStatement:
+			return exec.CreateView(ctx, catalog, planner, stmt)
+		case *parser.DropViewStatement:
+			return exec.DropView(catalog, planner, stmt)
+		case *parser.DropTableStatement:
+			return exec.DropTable(catalog, planner, stmt)
+		case *parser.RefreshMaterializedViewStatement:
+			return exec.RefreshMaterializedView(ctx, catalog, planner, stmt)
+		}
+		t.Fatalf("unexpected statement %T", stmt)
+		return nil
+	}
+	query := func(sql string) (int, error) {
+		plan, err := planner.Prepare(sql)
+		if err != nil {
+			return 0, err
+		}
+		result, err := exec.Execute(ctx, plan.Plan)
+		if err != nil {
+			return 0, err
+		}
+		return result.RowCount(), nil
+	}
+
+	// A view's query runs each time the view is read
+	if err := run("CREATE VIEW user_list AS SELECT * FROM users"); err != nil {
+		t.Fatalf("create view failed: %v", err)
+	}
+	if count, err := query("SELECT * FROM user_list"); err != nil || count != 3 {
+		t.Errorf("expected 3 rows from the view, got %d (%v)", count, err)
+	}
+	if err := run("CREATE VIEW user_list AS SELECT id FROM users"); err == nil {
+		t.Error("expected an existing view name to be rejected")
+	}
+	if err := run("CREATE VIEW users AS SELECT id FROM users"); err == nil {
+		t.Error("expected a table name to be rejected")
+	}
+
+	// A materialized view keeps the rows of its last refresh
+	if err := run("CREATE MATERIALIZED VIEW user_snapshot AS SELECT * FROM user_list"); err != nil {
+		t.Fatalf("create materialized view failed: %v", err)
+	}
+	if entry, _ := cm.GetView("user_snapshot"); entry == nil || len(entry.DependsOn) != 1 || entry.DependsOn[0] != "user_list" {
+		t.Errorf("expected user_snapshot to depend on user_list, got %+v", entry)
+	}
+	if schema, err := sm.GetSchema("user_snapshot"); err != nil || len(schema.Columns) != 2 || schema.Columns[0].Type != TypeBigInt {
+		t.Errorf("expected user_snapshot to be stored as a table (id, name), got %+v (%v)", schema, err)
+	}
+	if _, err := exec.InsertRow(cm, "users", []interface{}{int64(4), "dee"}); err != nil {
+		t.Fatalf("insert failed: %v", err)
+	}
+	if count, err := query("SELECT * FROM user_snapshot"); err != nil || count != 3 {
+		t.Errorf("expected the 3 rows of the first refresh, got %d (%v)", count, err)
+	}
+	if err := run("REFRESH MATERIALIZED VIEW user_snapshot"); err != nil {
+		t.Fatalf("refresh failed: %v", err)
+	}
+	if count, err := query("SELECT * FROM user_snapshot"); err != nil || count != 4 {
+		t.Errorf("expected 4 rows after refresh, got %d (%v)", count, err)
+	}
+
+	// WITH NO DATA leaves the view unreadable until it is refreshed
+	if err := run("CREATE MATERIALIZED VIEW pending AS SELECT * FROM users WITH NO DATA"); err != nil {
+		t.Fatalf("create materialized view failed: %v", err)
+	}
+	if _, err := query("SELECT * FROM pending"); !errors.Is(err, ErrViewNotPopulated) {
+		t.Errorf("expected ErrViewNotPopulated, got %v", err)
+	}
+	if err := run("REFRESH MATERIALIZED VIEW CONCURRENTLY pending"); !errors.Is(err, ErrViewNotPopulated) {
+		t.Errorf("expected a concurrent refresh of an empty view to fail, got %v", err)
+	}
+	if err := run("REFRESH MATERIALIZED VIEW pending"); err != nil {
+		t.Fatalf("refresh failed: %v", err)
+	}
+	if err := run("REFRESH MATERIALIZED VIEW CONCURRENTLY pending"); err != nil {
+		t.Fatalf("concurrent refresh failed: %v", err)
+	}
+	if count, err := query("SELECT * FROM pending"); err != nil || count != 4 {
+		t.Errorf("expected 4 rows after refresh, got %d (%v)", count, err)
+	}
+
+	// Views survive a restart; materialized rows must be recomputed
+	sm2 := NewSchemaManager()
+	cm2 := NewCatalogManager(sm2)
+	if err := NewSystemCatalog(engine, sm2, cm2, 4096).Bootstrap(); err != nil {
+		t.Fatalf("failed to reload catalog: %v", err)
+	}
+	if views := cm2.ListViews(); len(views) != 3 {
+		t.Errorf("expected 3 views after restart, got %v", views)
+	}
+	if view, err := NewCompilerCatalog(cm2).GetView("user_snapshot"); err != nil || !view.Materialized || view.Query != "SELECT * FROM user_list" {
+		t.Errorf("unexpected view after restart: %+v (%v)", view, err)
+	}
+	if _, err := sm2.GetSchema("pending"); err != nil {
+		t.Errorf("expected the table of a materialized view after restart: %v", err)
+	}
+
+	// Dependent views stop a drop unless it cascades
+	if err := run("DROP TABLE users"); err == nil {
+		t.Error("expected views depending on users to stop the drop")
+	}
+	if err := run("DROP VIEW user_list"); err == nil {
+		t.Error("expected user_snapshot to stop dropping user_list")
+	}
+	if err := run("DROP VIEW user_snapshot"); err == nil {
+		t.Error("expected DROP VIEW of a materialized view to fail")
+	}
+	if err := run("DROP TABLE pending"); err == nil {
+		t.Error("expected DROP TABLE of a materialized view to fail")
+	}
+	if err := run("DROP MATERIALIZED VIEW pending"); err != nil {
+		t.Fatalf("drop materialized view failed: %v", err)
+	}
+	if _, err := sm.GetSchema("pending"); err == nil {
+		t.Error("expected the table of the dropped view to be gone")
+	}
+	if err := run("DROP TABLE users CASCADE"); err != nil {
+		t.Fatalf("drop table cascade failed: %v", err)
+	}
+	if views := cm.ListViews(); len(views) != 0 {
+		t.Errorf("expected the dependent views to be dropped, got %v", views)
+	}
+	if _, err := sm.GetSchema("user_snapshot"); err == nil {
+		t.Error("expected the table of user_snapshot to be gone")
+	}
+
+	sm3 := NewSchemaManager()
+	cm3 := NewCatalogManager(sm3)
+	if err := NewSystemCatalog(engine, sm3, cm3, 4096).Bootstrap(); err != nil {
+		t.Fatalf("failed to reload catalog: %v", err)
+	}
+	if views := cm3.ListViews(); len(views) != 0 || len(sm3.ListSchemas()) != len(systemTableSchemas()) {
+		t.Errorf("expected only system tables after restart, got views %v and tables %v", views, sm3.ListSchemas())
+	}
+}
+
+func TestDataModification(t *testing.T) {
+	engine := newMemoryStorage()
+
+	sm := NewSchemaManager()
+	cm := NewCatalogManager(sm)
+	catalog := NewSystemCatalog(engine, sm, cm, 4096)
+	if err := catalog.Bootstrap(); err != nil {
+		t.Fatalf("failed to bootstrap catalog: %v", err)
+	}
+
+	exec := NewExecutor(nil, nil)
+	for _, schema := range []*TableSchema{
+		{
+			TableName: "users",
+			Columns: []ColumnInfo{
+				{Name: "id", Type: TypeBigInt},
+				{Name: "name", Type: TypeString, Nullable: true},
+			},
+			PrimaryKey:    []string{"id"},
+			Clustered:     true,
+			AutoIncrement: "id",
+		},
+		{
+			TableName: "archive",
+			Columns: []ColumnInfo{
+				{Name: "id", Type: TypeBigInt},
+				{Name: "name", Type: TypeString, Nullable: true},
+			},
+			PrimaryKey: []string{"id"},
+			Clustered:  true,
+		},
+	} {
+		if err := catalog.CreateTable(schema); err != nil {
+			t.Fatalf("failed to create table: %v", err)
+		}
+		index, err := NewClusteredIndex(schema)
+		if err != nil {
+			t.Fatalf("failed to create index: %v", err)
+		}
+		exec.RegisterClusteredIndex(index)
+	}
+
+	planner := NewQueryPlanner(cm, 10)
+	ctx := context.Background()
+	run := func(sql string, params ...interface{}) (*DMLResult, error) {
+		plan, err := planner.Prepare(sql)
+		if err != nil {
+			return nil, err
+		}
+		bound, err := plan.Bind(params)
+		if err != nil {
+			return nil, err
+		}
+		return exec.ExecuteDML(ctx, planner, plan, bound)
+	}
+	rows := func(table string) [][]interface{} {
+		index, _ := exec.GetClusteredIndex(table)
+		var rows [][]interface{}
+		iter := index.Scan(nil, nil)
+		for tuple := iter.Next(); tuple != nil; tuple = iter.Next() {
+			rows = append(rows, tuple.Values)
+		}
+		return rows
+	}
+
+	// Multi-row VALUES fill the AUTO_INCREMENT key and return it
+	result, err := run("INSERT INTO users (name) VALUES ('ann'), ('bob'), (?) RETURNING id, name AS who", "cy")
+	if err != nil {
+		t.Fatalf("insert failed: %v", err)
+	}
+	if result.RowsAffected != 3 || result.LastInsertID != 3 {
+		t.Errorf("expected 3 rows and last insert id 3, got %d and %d", result.RowsAffected, result.LastInsertID)
+	}
+	if cols := result.Returning.Schema.Columns; len(cols) != 2 || cols[0].Name != "id" || cols[1].Name != "who" {
+		t.Errorf("unexpected RETURNING columns %v", cols)
+	}
+	if got := result.Returning.Tuples[2].Values; len(got) != 2 || got[0] != int64(3) || got[1] != "cy" {
+		t.Errorf("expected RETURNING row (3, cy), got %v", got)
+	}
+
+	// An explicit key moves the counter past it
+	if result, err = run("INSERT INTO users VALUES (10, 'dee')"); err != nil {
+		t.Fatalf("insert failed: %v", err)
+	}
+	if result.LastInsertID != 0 || result.Returning != nil {
+		t.Errorf("expected no generated id and no RETURNING rows, got %d and %v", result.LastInsertID, result.Returning)
+	}
+	if result, err = run("INSERT INTO users (name) VALUES ('eve')"); err != nil || result.LastInsertID != 11 {
+		t.Errorf("expected last insert id 11, got %v (%v)", result, err)
+	}
+
+	// A failing row undoes the rows of the statement stored before it
+	if _, err := run("INSERT INTO users VALUES (20, 'fay'), (1, 'dup')"); err == nil {
+		t.Error("expected duplicate key to fail the insert")
+	}
+	if got := len(rows("users")); got != 5 {
+		t.Errorf("expected the failed insert to leave 5 rows, got %d", got)
+	}
+
+	// INSERT ... SELECT copies rows between tables
+	if result, err = run("INSERT INTO archive SELECT * FROM users WHERE id > 2 RETURNING *"); err != nil {
+		t.Fatalf("insert select failed: %v", err)
+	}
+	if result.RowsAffected != 3 || len(rows("archive")) != 3 || len(result.Returning.Tuples) != 3 {
+		t.Errorf("expected 3 archived rows, got %d", result.RowsAffected)
+	}
+
+	// UPDATE returns the new values
+	if result, err = run("UPDATE users SET name = 'bea' WHERE id = 2 RETURNING name"); err != nil {
+		t.Fatalf("update failed: %v", err)
+	}
+	if result.RowsAffected != 1 || result.Returning.Tuples[0].Values[0] != "bea" {
+		t.Errorf("expected one updated row named bea, got %d %v", result.RowsAffected, result.Returning.Tuples)
+	}
+	if _, err := run("UPDATE users SET id = 1 WHERE id = 2"); err == nil {
+		t.Error("expected duplicate key to fail the update")
+	}
+	if stored := rows("users"); stored[1][0] != int64(2) || stored[1][1] != "bea" {
+		t.Errorf("expected the failed update to leave row 2 as it was, got %v", stored[1])
+	}
+
+	// DELETE returns the removed rows
+	if result, err = run("DELETE FROM users WHERE id >= 10 RETURNING id"); err != nil {
+		t.Fatalf("delete failed: %v", err)
+	}
+	if result.RowsAffected != 2 || len(result.Returning.Tuples) != 2 || len(rows("users")) != 3 {
+		t.Errorf("expected 2 deleted rows, got %d", result.RowsAffected)
+	}
+
+	// Parameters must fit the types inferred from their context
+	if _, err := run("UPDATE users SET name = ? WHERE id = ?", 42, 1); !errors.Is(err, ErrTypeMismatch) {
+		t.Errorf("expected an integer bound to a text parameter to fail, got %v", err)
+	}
+	if _, err := run("DELETE FROM users WHERE id = ?", "1"); !errors.Is(err, ErrTypeMismatch) {
+		t.Errorf("expected a string bound to an integer parameter to fail, got %v", err)
+	}
+	if result, err = run("UPDATE users SET name = ? WHERE id = ?", nil, 2.0); err != nil || result.RowsAffected != 1 {
+		t.Errorf("expected NULL and a whole float to bind, got %v (%v)", result, err)
+	}
+
+	if _, err := run("INSERT INTO users (id, name) VALUES (1)"); err == nil {
+		t.Error("expected more target columns than expressions to fail")
+	}
+	if _, err := run("DELETE FROM users RETURNING missing"); err == nil {
+		t.Error("expected unknown RETURNING column to fail")
+	}
+}
+
+func TestNotNullAndDefault(t *testing.T) {
+	engine := newMemoryStorage()
+
+	sm := NewSchemaManager()
+	cm := NewCatalogManager(sm)
+	catalog := NewSystemCatalog(engine, sm, cm, 4096)
+	if err := catalog.Bootstrap(); err != nil {
+		t.Fatalf("failed to bootstrap catalog: %v", err)
+	}
+
+	exec := NewExecutor(nil, nil)
+	planner := NewQueryPlanner(cm, 10)
+	ctx := context.Background()
+	create, err := parser.ParseSQL("CREATE TABLE x (a INTEGER PRIMARY KEY, b INTEGER NOT NULL DEFAULT 3, c VARCHAR(10) DEFAULT 'none', d INTEGER NOT NULL)")
+	if err != nil {
+		t.Fatalf("failed to parse table: %v", err)
+	}
+	if err := exec.CreateTable(catalog, planner, create.(*parser.CreateTableStatement)); err != nil {
+		t.Fatalf("failed to create table: %v", err)
+	}
+
+	run := func(sql string) (*DMLResult, error) {
+		plan, err := planner.Prepare(sql)
+		if err != nil {
+			return nil, err
+		}
+		return exec.ExecuteDML(ctx, planner, plan, nil)
+	}
+	rows := func() string {
+		index, _ := exec.GetClusteredIndex("x")
+		var rows [][]interface{}
+		iter := index.Scan(nil, nil)
+		for tuple := iter.Next(); tuple != nil; tuple = iter.Next() {
+			rows = append(rows, tuple.Values)
+		}
+		return fmt.Sprint(rows)
+	}
+
+	// Columns the INSERT leaves out take their DEFAULT
+	if _, err := run("INSERT INTO x (a, d) VALUES (2, 0)"); err != nil {
+		t.Fatalf("insert failed: %v", err)
+	}
+	if _, err := run("INSERT INTO x (d, a, c) VALUES (0, 1, NULL)"); err != nil {
+		t.Fatalf("insert failed: %v", err)
+	}
+	if got := rows(); got != "[[1 3 <nil> 0] [2 3 none 0]]" {
+		t.Errorf("expected the defaults to be stored, got %s", got)
+	}
+
+	// NULL is rejected in a NOT NULL column, however it would get there
+	for _, sql := range []string{
+		"INSERT INTO x (a, b, d) VALUES (3, NULL, 0)",
+		"INSERT INTO x (a) VALUES (3)",
+		"INSERT INTO x VALUES (3, 1)",
+		"INSERT INTO x (a, d) VALUES (3, 0), (4, NULL)",
+		"UPDATE x SET b = NULL WHERE a = 2",
+		"INSERT INTO x (a, d) VALUES (1, 0) ON CONFLICT (a) DO UPDATE SET d = NULL",
+	} {
+		if _, err := run(sql); !errors.Is(err, ErrNullValue) {
+			t.Errorf("%s: expected ErrNullValue, got %v", sql, err)
+		}
+	}
+	if got := rows(); got != "[[1 3 <nil> 0] [2 3 none 0]]" {
+		t.Errorf("expected the rejected statements to change nothing, got %s", got)
+	}
+
+	// Defaults are read back with the catalog
+	sm2 := NewSchemaManager()
+	if err := NewSystemCatalog(engine, sm2, NewCatalogManager(sm2), 4096).Bootstrap(); err != nil {
+		t.Fatalf("failed to reload catalog: %v", err)
+	}
+	schema, err := sm2.GetSchema("x")
+	if err != nil {
+		t.Fatalf("expected x after reload: %v", err)
+	}
+	if schema.Columns[1].Default != int64(3) || schema.Columns[2].Default != "none" || schema.Columns[3].Default != nil {
+		t.Errorf("expected defaults 3, none and NULL after reload, got %v", schema.Columns)
+	}
+}
+
+func TestUpsertAndMerge(t *testing.T) {
+	engine := newMemoryStorage()
+
+	sm := NewSchemaManager()
+	cm := NewCatalogManager(sm)
+	catalog := NewSystemCatalog(engine, sm, cm, 4096)
+	if err := catalog.Bootstrap(); err != nil {
+		t.Fatalf("failed to bootstrap catalog: %v", err)
+	}
+
+	exec := NewExecutor(nil, nil)
+	for _, schema := range []*TableSchema{
+		{
+			TableName: "users",
+			Columns: []ColumnInfo{
+				{Name: "id", Type: TypeBigInt},
+				{Name: "email", Type: TypeString, Nullable: true},
+				{Name: "name", Type: TypeString, Nullable: true},
+			},
+			PrimaryKey: []string{"id"},
+			Clustered:  true,
+		},
+		{
+			TableName: "staging",
+			Columns: []ColumnInfo{
+				{Name: "id", Type: TypeBigInt},
+				{Name: "name", Type: TypeString, Nullable: true},
+			},
+			PrimaryKey: []string{"id"},
+			Clustered:  true,
+		},
+	} {
+		if err := catalog.CreateTable(schema); err != nil {
+			t.Fatalf("failed to create table: %v", err)
+		}
+		index, err := NewClusteredIndex(schema)
+		if err != nil {
+			t.Fatalf("failed to create index: %v", err)
+		}
+		exec.RegisterClusteredIndex(index)
+	}
+	stmt, err := parser.ParseSQL("CREATE UNIQUE INDEX idx_email ON users (email)")
+	if err != nil {
+		t.Fatalf("failed to parse index: %v", err)
+	}
+	if err := exec.CreateIndex(catalog, stmt.(*parser.CreateIndexStatement)); err != nil {
+		t.Fatalf("create index failed: %v", err)
+	}
+
+	planner := NewQueryPlanner(cm, 10)
+	ctx := context.Background()
+	run := func(sql string) (*DMLResult, error) {
+		plan, err := planner.Prepare(sql)
+		if err != nil {
+			return nil, err
+		}
+		return exec.ExecuteDML(ctx, planner, plan, nil)
+	}
+	rows := func(table string) [][]interface{} {
+		index, _ := exec.GetClusteredIndex(table)
+		var rows [][]interface{}
+		iter := index.Scan(nil, nil)
+		for tuple := iter.Next(); tuple != nil; tuple = iter.Next() {
+			rows = append(rows, tuple.Values)
+		}
+		return rows
+	}
+
+	if _, err := run("INSERT INTO users VALUES (1, 'a@x', 'ann'), (2, 'b@x', 'bob')"); err != nil {
+		t.Fatalf("insert failed: %v", err)
+	}
+
+	// DO NOTHING skips conflicting rows and stores the rest
+	result, err := run("INSERT INTO users VALUES (1, 'z@x', 'zed'), (3, 'c@x', 'cy') ON CONFLICT DO NOTHING")
+	if err != nil {
+		t.Fatalf("upsert failed: %v", err)
+	}
+	if result.RowsAffected != 1 || len(rows("users")) != 3 {
+		t.Errorf("expected one stored row, got %d", result.RowsAffected)
+	}
+
+	// DO UPDATE sees the proposed row as excluded; WHERE can skip the update
+	result, err = run("INSERT INTO users VALUES (1, 'a@x', 'amy'), (2, 'b@x', 'bob') ON CONFLICT (id) DO UPDATE SET name = excluded.name WHERE users.name <> excluded.name RETURNING name")
+	if err != nil {
+		t.Fatalf("upsert failed: %v", err)
+	}
+	if result.RowsAffected != 1 || result.Returning.Tuples[0].Values[0] != "amy" {
+		t.Errorf("expected only row 1 renamed to amy, got %d %v", result.RowsAffected, result.Returning.Tuples)
+	}
+
+	// A unique index arbitrates as well as the primary key
+	if _, err := run("INSERT INTO users VALUES (9, 'b@x', 'bea') ON CONFLICT (email) DO UPDATE SET name = excluded.name"); err != nil {
+		t.Fatalf("upsert failed: %v", err)
+	}
+	if stored := rows("users"); len(stored) != 3 || stored[1][2] != "bea" {
+		t.Errorf("expected row 2 renamed to bea, got %v", stored)
+	}
+
+	// Changing the same row twice fails and undoes the whole statement
+	if _, err := run("INSERT INTO users VALUES (4, 'd@x', 'dee'), (1, 'a@x', 'x'), (1, 'a@x', 'y') ON CONFLICT (id) DO UPDATE SET name = excluded.name"); err == nil {
+		t.Error("expected a second change of row 1 to fail")
+	}
+	if stored := rows("users"); len(stored) != 3 || stored[0][2] != "amy" {
+		t.Errorf("expected the failed upsert to leave the table as it was, got %v", stored)
+	}
+
+	// MERGE updates, deletes and inserts in one statement
+	if _, err := run("INSERT INTO staging VALUES (1, 'ann'), (2, NULL), (5, 'eve')"); err != nil {
+		t.Fatalf("insert failed: %v", err)
+	}
+	result, err = run("MERGE INTO users u USING staging s ON u.id = s.id WHEN MATCHED AND s.name IS NULL THEN DELETE WHEN MATCHED THEN UPDATE SET name = s.name WHEN NOT MATCHED THEN INSERT (id, name) VALUES (s.id, s.name)")
+	if err != nil {
+		t.Fatalf("merge failed: %v", err)
+	}
+	if result.RowsAffected != 3 {
+		t.Errorf("expected 3 merged rows, got %d", result.RowsAffected)
+	}
+	stored := rows("users")
+	if len(stored) != 3 || stored[0][2] != "ann" || stored[1][0] != int64(3) || stored[2][0] != int64(5) || stored[2][1] != nil {
+		t.Errorf("unexpected rows after merge: %v", stored)
+	}
+
+	// A locked target fails the statement instead of interleaving with it
+	locks := NewLockManager()
+	te := NewTransactionExecutor(exec, locks)
+	if err := locks.AcquireTableLock(999, "users", SharedLock); err != nil {
+		t.Fatalf("failed to lock users: %v", err)
+	}
+	plan, err := planner.Prepare("INSERT INTO users VALUES (6, 'f@x', 'fay') ON CONFLICT DO NOTHING")
+	if err != nil {
+		t.Fatalf("prepare failed: %v", err)
+	}
+	if _, err := te.ExecuteDML(ctx, planner, plan, nil); err == nil {
+		t.Error("expected the lock conflict to fail the insert")
+	}
+	locks.ReleaseAllLocks(999)
+	if result, err := te.ExecuteDML(ctx, planner, plan, nil); err != nil || result.RowsAffected != 1 {
+		t.Errorf("expected the insert to succeed once unlocked, got %v (%v)", result, err)
+	}
+}
+
+func TestJoinedUpdateAndDelete(t *testing.T) {
+	engine := newMemoryStorage()
+
+	sm := NewSchemaManager()
+	cm := NewCatalogManager(sm)
+	catalog := NewSystemCatalog(engine, sm, cm, 4096)
+	if err := catalog.Bootstrap(); err != nil {
+		t.Fatalf("failed to bootstrap catalog: %v", err)
+	}
+
+	exec := NewExecutor(nil, nil)
+	for _, schema := range []*TableSchema{
+		{
+			TableName: "users",
+			Columns: []ColumnInfo{
+				{Name: "id", Type: TypeBigInt},
+				{Name: "name", Type: TypeString, Nullable: true},
+				{Name: "manager", Type: TypeBigInt, Nullable: true},
+			},
+			PrimaryKey: []string{"id"},
+			Clustered:  true,
+		},
+		{
+			TableName: "staging",
+			Columns: []ColumnInfo{
+				{Name: "seq", Type: TypeBigInt},
+				{Name: "user_id", Type: TypeBigInt, Nullable: true},
+				{Name: "label", Type: TypeString, Nullable: true},
+			},
+			PrimaryKey: []string{"seq"},
+			Clustered:  true,
+		},
+	} {
+		if err := catalog.CreateTable(schema); err != nil {
+			t.Fatalf("failed to create table: %v", err)
+		}
+		index, err := NewClusteredIndex(schema)
+		if err != nil {
+			t.Fatalf("failed to create index: %v", err)
+		}
+		exec.RegisterClusteredIndex(index)
+	}
+
+	planner := NewQueryPlanner(cm, 10)
+	ctx := context.Background()
+	run := func(sql string, params ...interface{}) (*DMLResult, error) {
+		plan, err := planner.Prepare(sql)
+		if err != nil {
+			return nil, err
+		}
+		bound, err := plan.Bind(params)
+		if err != nil {
+			return nil, err
+		}
+		return exec.ExecuteDML(ctx, planner, plan, bound)
+	}
+	rows := func(table string) [][]interface{} {
+		index, _ := exec.GetClusteredIndex(table)
+		var rows [][]interface{}
+		iter := index.Scan(nil, nil)
+		for tuple := iter.Next(); tuple != nil; tuple = iter.Next() {
+			rows = append(rows, tuple.Values)
+		}
+		return rows
+	}
+
+	if _, err := run("INSERT INTO users VALUES (1, 'ann', NULL), (2, 'bob', 1), (3, 'cy', 1), (4, 'dee', 2)"); err != nil {
+		t.Fatalf("insert failed: %v", err)
+	}
+	if _, err := run("INSERT INTO staging VALUES (1, 2, 'b'), (2, 3, 'c'), (3, 3, 'c'), (4, NULL, 'x')"); err != nil {
+		t.Fatalf("insert failed: %v", err)
+	}
+
+	// An equality between the target and the FROM table is hashed on
+	plan, err := planner.Prepare("UPDATE users SET name = s.label FROM staging s WHERE users.id = s.user_id")
+	if err != nil {
+		t.Fatalf("prepare failed: %v", err)
+	}
+	if join := plan.Plan.Root.Children[0]; join.Type != optimizer.PhysicalPlanTypeHashJoin || len(join.JoinKeys) != 1 {
+		t.Errorf("expected a hash join on one key, got %s", plan.Plan.Root)
+	}
+
+	// Row 3 matches two staging rows that agree, and is updated once
+	result, err := run("UPDATE users SET name = s.label FROM staging s WHERE users.id = s.user_id RETURNING id, name, s.seq")
+	if err != nil {
+		t.Fatalf("update failed: %v", err)
+	}
+	if result.RowsAffected != 2 || len(result.Returning.Tuples) != 2 {
+		t.Fatalf("expected 2 updated rows, got %d", result.RowsAffected)
+	}
+	if got := result.Returning.Tuples[1].Values; got[0] != int64(3) || got[1] != "c" || got[2] != int64(2) {
+		t.Errorf("expected RETURNING row (3, c, 2), got %v", got)
+	}
+	if stored := rows("users"); stored[1][1] != "b" || stored[2][1] != "c" || stored[3][1] != "dee" {
+		t.Errorf("unexpected rows after update: %v", stored)
+	}
+
+	// Joined rows that disagree fail the statement and change nothing
+	if _, err := run("UPDATE users SET name = 'seq' || s.seq FROM staging s WHERE users.id = s.user_id"); err == nil {
+		t.Error("expected rows updating row 3 to different values to fail")
+	}
+	if stored := rows("users"); stored[2][1] != "c" {
+		t.Errorf("expected the failed update to leave row 3 as it was, got %v", stored[2])
+	}
+
+	// A self-join through an alias; the condition is not an equality, so
+	// the rows are compared by a nested loop
+	if result, err = run("UPDATE users SET manager = NULL FROM users AS m WHERE users.manager = m.id AND m.name = ?", "b"); err != nil {
+		t.Fatalf("update failed: %v", err)
+	}
+	if result.RowsAffected != 1 || rows("users")[3][2] != nil {
+		t.Errorf("expected only row 4 to lose its manager, got %d", result.RowsAffected)
+	}
+
+	// FROM may list several tables and derived tables
+	if result, err = run("UPDATE users SET manager = x.user_id FROM (SELECT * FROM staging) AS x, users AS u WHERE users.id = 4 AND x.user_id = u.id AND u.name = 'b'"); err != nil {
+		t.Fatalf("update failed: %v", err)
+	}
+	if result.RowsAffected != 1 || rows("users")[3][2] != int64(2) {
+		t.Errorf("expected row 4 to get manager 2, got %v", rows("users")[3])
+	}
+
+	// DELETE ... USING removes each matching row once
+	if result, err = run("DELETE FROM users USING staging WHERE users.id = staging.user_id RETURNING *"); err != nil {
+		t.Fatalf("delete failed: %v", err)
+	}
+	if result.RowsAffected != 2 || len(result.Returning.Tuples) != 2 || len(result.Returning.Tuples[0].Values) != 3 {
+		t.Errorf("expected 2 deleted rows of 3 columns, got %d %v", result.RowsAffected, result.Returning.Tuples)
+	}
+	if stored := rows("users"); len(stored) != 2 || stored[0][0] != int64(1) || stored[1][0] != int64(4) {
+		t.Errorf("unexpected rows after delete: %v", stored)
+	}
+
+	if _, err := run("DELETE FROM users USING (SELECT * FROM staging) WHERE users.id = 1"); err == nil {
+		t.Error("expected a derived table without an alias to fail")
+	}
+	if _, err := run("DELETE FROM users USING staging s WHERE users.id = s.user_id AND s.label IS NULL"); err != nil {
+		t.Errorf("expected a delete matching nothing to succeed: %v", err)
+	}
+}
+
+func TestSelectQueries(t *testing.T) {
+	engine := newMemoryStorage()
+
+	sm := NewSchemaManager()
+	cm := NewCatalogManager(sm)
+	catalog := NewSystemCatalog(engine, sm, cm, 4096)
+	if err := catalog.Bootstrap(); err != nil {
+		t.Fatalf("failed to bootstrap catalog: %v", err)
+	}
+
+	exec := NewExecutor(nil, nil)
+	for _, schema := range []*TableSchema{
+		{
+			TableName:  "t",
+			Columns:    []ColumnInfo{{Name: "id", Type: TypeBigInt}},
+			PrimaryKey: []string{"id"},
+			Clustered:  true,
+		},
+		{
+			TableName: "s",
+			Columns: []ColumnInfo{
+				{Name: "id", Type: TypeBigInt},
+				{Name: "v", Type: TypeBigInt, Nullable: true},
+			},
+			PrimaryKey: []string{"id"},
+			Clustered:  true,
+		},
+	} {
+		if err := catalog.CreateTable(schema); err != nil {
+			t.Fatalf("failed to create table: %v", err)
+		}
+		index, err := NewClusteredIndex(schema)
+		if err != nil {
+			t.Fatalf("failed to create index: %v", err)
+		}
+		exec.RegisterClusteredIndex(index)
+	}
+	for _, row := range [][]interface{}{{int64(1)}, {int64(2)}} {
+		if _, err := exec.InsertRow(cm, "t", row); err != nil {
+			t.Fatalf("insert failed: %v", err)
+		}
+	}
+	for _, row := range [][]interface{}{{int64(1), int64(10)}, {int64(2), int64(30)}, {int64(3), int64(20)}, {int64(4), nil}} {
+		if _, err := exec.InsertRow(cm, "s", row); err != nil {
+			t.Fatalf("insert failed: %v", err)
+		}
+	}
+
+	planner := NewQueryPlanner(cm, 10)
+	ctx := context.Background()
+	query := func(sql string) (*ResultSet, error) {
+		plan, err := planner.Prepare(sql)
+		if err != nil {
+			return nil, err
+		}
+		return exec.Execute(ctx, plan.Plan)
+	}
+	check := func(sql string, want string) {
+		t.Helper()
+		result, err := query(sql)
+		if err != nil {
+			t.Errorf("%s failed: %v", sql, err)
+			return
+		}
+		var rows [][]interface{}
+		for _, tuple := range result.Tuples {
+			rows = append(rows, tuple.Values)
+		}
+		if got := fmt.Sprint(rows); got != want {
+			t.Errorf("%s: expected %s, got %s", sql, want, got)
+		}
+	}
+
+	// Aggregates reduce their input to one row per group, or to a single
+	// row without GROUP BY
+	check("SELECT id, (SELECT MAX(v) FROM s) FROM t", "[[1 30] [2 30]]")
+	check("SELECT COUNT(*), COUNT(v), SUM(v), MIN(v) FROM s", "[[4 3 60 10]]")
+	check("SELECT COUNT(*), MAX(v) FROM s WHERE id > 10", "[[0 <nil>]]")
+	check("SELECT v > 15, COUNT(*) FROM s WHERE v IS NOT NULL GROUP BY v > 15 ORDER BY 1", "[[false 1] [true 2]]")
+	check("SELECT id FROM s GROUP BY id HAVING MAX(v) >= 20 ORDER BY id", "[[2] [3]]")
+
+	// A derived table has only the columns its query selects
+	result, err := query("SELECT * FROM (SELECT id FROM s) d")
+	if err != nil {
+		t.Fatalf("derived table query failed: %v", err)
+	}
+	if cols := result.Schema.Columns; len(cols) != 1 || cols[0].Name != "id" {
+		t.Errorf("expected the single column id, got %v", cols)
+	}
+	check("SELECT * FROM (SELECT id FROM s) d", "[[1] [2] [3] [4]]")
+	check("SELECT d.n FROM (SELECT id AS n FROM s WHERE v > 15) d ORDER BY d.n", "[[2] [3]]")
+
+	// Joins pair the rows of their inputs; a LEFT JOIN keeps unmatched left
+	// rows with NULLs for the right input's columns
+	check("SELECT t.id, s.v FROM t JOIN s ON s.id = t.id ORDER BY t.id", "[[1 10] [2 30]]")
+	check("SELECT s.id, t.id FROM s LEFT JOIN t ON t.id = s.id ORDER BY s.id", "[[1 1] [2 2] [3 <nil>] [4 <nil>]]")
+	check("SELECT a.id, b.id FROM s a JOIN s b ON b.v > a.v WHERE a.id = 3", "[[3 2]]")
+	check("SELECT COUNT(*) FROM t, s", "[[8]]")
+
+	// A query without FROM reads a single row
+	check("SELECT 1 + 1, 'x'", "[[2 x]]")
+	check("SELECT 1 WHERE 1 = 2", "[]")
+
+	// Recursive CTEs iterate their recursive term over the rows it added last
+	check("WITH RECURSIVE r AS (SELECT id, v FROM s WHERE id = 2 UNION ALL SELECT s.id, s.v FROM s JOIN r ON s.id = r.id + 1) SELECT id FROM r", "[[2] [3] [4]]")
+	check("WITH RECURSIVE r(n) AS (SELECT 1 UNION ALL SELECT n+1 FROM r WHERE n < 5) SELECT n FROM r", "[[1] [2] [3] [4] [5]]")
+	check("WITH RECURSIVE r(n) AS (SELECT 1 UNION ALL SELECT n+1 FROM r WHERE n < 3) SELECT r.n, t.id FROM r JOIN t ON t.id = r.n", "[[1 1] [2 2]]")
+
+	// Calls over a named window use the window of the WINDOW clause
+	check("SELECT id, SUM(v) OVER w, ROW_NUMBER() OVER w FROM s WINDOW w AS (ORDER BY id) ORDER BY id",
+		"[[1 10 1] [2 40 2] [3 60 3] [4 60 4]]")
+	check("SELECT id FROM s WHERE v IS NOT NULL WINDOW w AS (ORDER BY v DESC) ORDER BY RANK() OVER w", "[[2] [3] [1]]")
+	if _, err := planner.Prepare("SELECT SUM(v) OVER w FROM s WINDOW w AS (ORDER BY missing)"); err == nil {
+		t.Error("expected the columns of a named window to be resolved")
+	}
+}
@@ -1,11 +1,14 @@
 // Package executor - Catalog Manager component
-// Manages database catalog metadata (tables, indexes, statistics)
+// Manages database catalog metadata (tables, indexes, views, statistics)
 package executor
 
 import (
 	"fmt"
 	"sync"
 	"time"
+
+	"relational-db/internal/compiler"
+	"relational-db/internal/storage"
 )
 
 // CatalogManager manages the system catalog
@@ -17,6 +20,9 @@ type CatalogManager struct {
 	// Index catalog
 	indexes map[string]*IndexCatalogEntry
 
+	// View catalog
+	views map[string]*ViewCatalogEntry
+
 	// Statistics
 	statistics map[string]*TableStatistics
 
@@ -38,6 +44,15 @@ type TableCatalogEntry struct {
 	PageCount  uint64
 	DataSize   uint64 // bytes
 	IndexCount int
+
+	// Partitioning is set on partitioned tables; their rows live in the
+	// child partitions, which are catalog entries with ParentTable set
+	Partitioning *compiler.PartitionScheme
+	ParentTable  string
+
+	// RowsPage anchors the stored rows of a table or partition; 0 if its
+	// rows are kept in memory only
+	RowsPage storage.PageID
 }
 
 // IndexCatalogEntry represents an index in the catalog
@@ -54,6 +69,17 @@ type IndexCatalogEntry struct {
 	KeyCount  uint64
 }
 
+// ViewCatalogEntry represents a view in the catalog. The rows of a
+// materialized view are kept in a table of the same name.
+type ViewCatalogEntry struct {
+	ViewName     string
+	Definition   string   // SQL of the view's query
+	Columns      []string // names of the view's columns
+	Materialized bool
+	DependsOn    []string // tables and views the query reads
+	CreatedAt    time.Time
+}
+
 // TableStatistics contains statistics for query optimization
 type TableStatistics struct {
 	TableName    string
@@ -94,6 +120,7 @@ func NewCatalogManager(schemaManager *SchemaManager) *CatalogManager {
 	return &CatalogManager{
 		tables:        make(map[string]*TableCatalogEntry),
 		indexes:       make(map[string]*IndexCatalogEntry),
+		views:         make(map[string]*ViewCatalogEntry),
 		statistics:    make(map[string]*TableStatistics),
 		schemaManager: schemaManager,
 	}
@@ -156,10 +183,23 @@ func (cm *CatalogManager) DropTable(tableName string) error {
 	cm.mutex.Lock()
 	defer cm.mutex.Unlock()
 
-	if _, exists := cm.tables[tableName]; !exists {
+	entry, exists := cm.tables[tableName]
+	if !exists {
 		return fmt.Errorf("table %s not found", tableName)
 	}
 
+	if entry.ParentTable != "" {
+		return fmt.Errorf("%s is a partition of %s and cannot be dropped on its own", tableName, entry.ParentTable)
+	}
+
+	// Partitions go with their parent
+	if entry.Partitioning != nil {
+		for _, name := range entry.Partitioning.PartitionNames() {
+			delete(cm.tables, name)
+			delete(cm.statistics, name)
+		}
+	}
+
 	// Remove all indexes associated with the table
 	for indexName, indexEntry := range cm.indexes {
 		if indexEntry.TableName == tableName {
@@ -173,6 +213,49 @@ func (cm *CatalogManager) DropTable(tableName string) error {
 	return nil
 }
 
+// RenameTable moves a table's catalog entry, statistics and indexes to a
+// new name. Partitioned tables and partitions cannot be renamed.
+func (cm *CatalogManager) RenameTable(oldName, newName string) error {
+	cm.mutex.Lock()
+	defer cm.mutex.Unlock()
+
+	entry, exists := cm.tables[oldName]
+	if !exists {
+		return fmt.Errorf("table %s not found", oldName)
+	}
+
+	if _, exists := cm.tables[newName]; exists {
+		return fmt.Errorf("table %s already exists", newName)
+	}
+
+	if entry.ParentTable != "" {
+		return fmt.Errorf("%s is a partition of %s and cannot be renamed", oldName, entry.ParentTable)
+	}
+
+	if entry.Partitioning != nil {
+		return fmt.Errorf("partitioned table %s cannot be renamed", oldName)
+	}
+
+	entry.TableName = newName
+	entry.ModifiedAt = time.Now()
+	cm.tables[newName] = entry
+	delete(cm.tables, oldName)
+
+	if stats, exists := cm.statistics[oldName]; exists {
+		stats.TableName = newName
+		cm.statistics[newName] = stats
+		delete(cm.statistics, oldName)
+	}
+
+	for _, indexEntry := range cm.indexes {
+		if indexEntry.TableName == oldName {
+			indexEntry.TableName = newName
+		}
+	}
+
+	return nil
+}
+
 // ListTables returns all table names
 func (cm *CatalogManager) ListTables() []string {
 	cm.mutex.RLock()
@@ -186,6 +269,127 @@ func (cm *CatalogManager) ListTables() []string {
 	return tables
 }
 
+// CreatePartitions partitions an existing table, registering one child entry
+// per partition. The children share the parent's schema and are returned in
+// partition order.
+func (cm *CatalogManager) CreatePartitions(tableName string, scheme *compiler.PartitionScheme) ([]*TableCatalogEntry, error) {
+	cm.mutex.Lock()
+	defer cm.mutex.Unlock()
+
+	parent, exists := cm.tables[tableName]
+	if !exists {
+		return nil, fmt.Errorf("table %s not found", tableName)
+	}
+
+	if parent.Partitioning != nil {
+		return nil, fmt.Errorf("table %s is already partitioned", tableName)
+	}
+
+	if scheme == nil || len(scheme.Partitions) == 0 {
+		return nil, fmt.Errorf("partitioned table %s requires at least one partition", tableName)
+	}
+
+	for _, name := range scheme.PartitionNames() {
+		if _, exists := cm.tables[name]; exists {
+			return nil, fmt.Errorf("table %s already exists", name)
+		}
+	}
+
+	children := make([]*TableCatalogEntry, 0, len(scheme.Partitions))
+	for _, name := range scheme.PartitionNames() {
+		child := &TableCatalogEntry{
+			TableName:   name,
+			SchemaName:  parent.SchemaName,
+			Owner:       parent.Owner,
+			CreatedAt:   time.Now(),
+			ModifiedAt:  time.Now(),
+			ParentTable: tableName,
+		}
+		cm.tables[name] = child
+		cm.statistics[name] = &TableStatistics{
+			TableName:    name,
+			ColumnStats:  make(map[string]*ColumnStatistics),
+			LastAnalyzed: time.Now(),
+		}
+		children = append(children, child)
+	}
+
+	parent.Partitioning = scheme
+	parent.ModifiedAt = time.Now()
+
+	return children, nil
+}
+
+// ListPartitions returns the child partitions of a table in partition order,
+// or nil if the table is not partitioned
+func (cm *CatalogManager) ListPartitions(tableName string) []*TableCatalogEntry {
+	cm.mutex.RLock()
+	defer cm.mutex.RUnlock()
+
+	parent, exists := cm.tables[tableName]
+	if !exists || parent.Partitioning == nil {
+		return nil
+	}
+
+	partitions := make([]*TableCatalogEntry, 0, len(parent.Partitioning.Partitions))
+	for _, name := range parent.Partitioning.PartitionNames() {
+		if child, exists := cm.tables[name]; exists {
+			partitions = append(partitions, child)
+		}
+	}
+
+	return partitions
+}
+
+// RouteRow returns the table a row must be stored in: the matching
+// partition for a partitioned table, otherwise the table itself
+func (cm *CatalogManager) RouteRow(tableName string, values []interface{}) (string, error) {
+	cm.mutex.RLock()
+	entry, exists := cm.tables[tableName]
+	cm.mutex.RUnlock()
+
+	if !exists {
+		return "", fmt.Errorf("table %s not found in catalog", tableName)
+	}
+
+	if entry.Partitioning == nil {
+		return tableName, nil
+	}
+
+	schema, err := cm.schemaManager.GetSchema(tableName)
+	if err != nil {
+		return "", err
+	}
+
+	column := NewTupleSchema(schema.Columns).GetColumnIndex(entry.Partitioning.Column)
+	if column < 0 || column >= len(values) {
+		return "", fmt.Errorf("partition column %s missing from row", entry.Partitioning.Column)
+	}
+
+	return entry.Partitioning.Route(values[column])
+}
+
+// PartitionSchema returns the schema of a partition: a copy of its parent's
+// schema under the partition's name
+func (cm *CatalogManager) PartitionSchema(partitionName string) (*TableSchema, error) {
+	cm.mutex.RLock()
+	entry, exists := cm.tables[partitionName]
+	cm.mutex.RUnlock()
+
+	if !exists || entry.ParentTable == "" {
+		return nil, fmt.Errorf("%s is not a partition", partitionName)
+	}
+
+	parent, err := cm.schemaManager.GetSchema(entry.ParentTable)
+	if err != nil {
+		return nil, err
+	}
+
+	schema := *parent
+	schema.TableName = partitionName
+	return &schema, nil
+}
+
 // CreateIndex registers a new index in the catalog
 func (cm *CatalogManager) CreateIndex(entry *IndexCatalogEntry) error {
 	cm.mutex.Lock()
@@ -267,6 +471,76 @@ func (cm *CatalogManager) ListIndexes(tableName string) []*IndexCatalogEntry {
 	return indexes
 }
 
+// CreateView registers a new view in the catalog
+func (cm *CatalogManager) CreateView(entry *ViewCatalogEntry) error {
+	cm.mutex.Lock()
+	defer cm.mutex.Unlock()
+
+	if entry == nil {
+		return fmt.Errorf("view entry cannot be nil")
+	}
+
+	if entry.ViewName == "" {
+		return fmt.Errorf("view name cannot be empty")
+	}
+
+	if _, exists := cm.views[entry.ViewName]; exists {
+		return fmt.Errorf("view %s already exists", entry.ViewName)
+	}
+
+	// Only a materialized view has a table of its own name
+	if _, exists := cm.tables[entry.ViewName]; exists != entry.Materialized {
+		if exists {
+			return fmt.Errorf("table %s already exists", entry.ViewName)
+		}
+		return fmt.Errorf("table %s not found", entry.ViewName)
+	}
+
+	entry.CreatedAt = time.Now()
+	cm.views[entry.ViewName] = entry
+	return nil
+}
+
+// GetView retrieves view catalog entry
+func (cm *CatalogManager) GetView(viewName string) (*ViewCatalogEntry, error) {
+	cm.mutex.RLock()
+	defer cm.mutex.RUnlock()
+
+	entry, exists := cm.views[viewName]
+	if !exists {
+		return nil, fmt.Errorf("view %s not found in catalog", viewName)
+	}
+
+	return entry, nil
+}
+
+// DropView removes a view from the catalog. The table of a materialized
+// view is dropped separately.
+func (cm *CatalogManager) DropView(viewName string) error {
+	cm.mutex.Lock()
+	defer cm.mutex.Unlock()
+
+	if _, exists := cm.views[viewName]; !exists {
+		return fmt.Errorf("view %s not found", viewName)
+	}
+
+	delete(cm.views, viewName)
+	return nil
+}
+
+// ListViews returns all view names
+func (cm *CatalogManager) ListViews() []string {
+	cm.mutex.RLock()
+	defer cm.mutex.RUnlock()
+
+	views := make([]string, 0, len(cm.views))
+	for viewName := range cm.views {
+		views = append(views, viewName)
+	}
+
+	return views
+}
+
 // UpdateTableStatistics updates statistics for a table
 func (cm *CatalogManager) UpdateTableStatistics(stats *TableStatistics) error {
 	cm.mutex.Lock()
@@ -328,6 +602,54 @@ func (cm *CatalogManager) UpdateRowCount(tableName string, rowCount uint64) erro
 	return nil
 }
 
+// restoreTable reinstates a table entry with its statistics and indexes,
+// keeping their original timestamps. Used when loading or undoing catalog changes.
+func (cm *CatalogManager) restoreTable(entry *TableCatalogEntry, stats *TableStatistics, indexes []*IndexCatalogEntry) {
+	cm.mutex.Lock()
+	defer cm.mutex.Unlock()
+
+	if stats == nil {
+		stats = &TableStatistics{
+			TableName:    entry.TableName,
+			RowCount:     entry.RowCount,
+			PageCount:    entry.PageCount,
+			ColumnStats:  make(map[string]*ColumnStatistics),
+			LastAnalyzed: entry.ModifiedAt,
+		}
+	}
+
+	entry.IndexCount = len(indexes)
+	cm.tables[entry.TableName] = entry
+	cm.statistics[entry.TableName] = stats
+	for _, index := range indexes {
+		cm.indexes[index.IndexName] = index
+	}
+}
+
+// restorePartitions reinstates the child entries of a partitioned table
+func (cm *CatalogManager) restorePartitions(partitions []*TableCatalogEntry) {
+	cm.mutex.Lock()
+	defer cm.mutex.Unlock()
+
+	for _, partition := range partitions {
+		cm.tables[partition.TableName] = partition
+		cm.statistics[partition.TableName] = &TableStatistics{
+			TableName:    partition.TableName,
+			RowCount:     partition.RowCount,
+			ColumnStats:  make(map[string]*ColumnStatistics),
+			LastAnalyzed: partition.ModifiedAt,
+		}
+	}
+}
+
+// restoreView reinstates a view entry, keeping its original timestamp
+func (cm *CatalogManager) restoreView(entry *ViewCatalogEntry) {
+	cm.mutex.Lock()
+	defer cm.mutex.Unlock()
+
+	cm.views[entry.ViewName] = entry
+}
+
 // GetCatalogInfo returns overall catalog information
 func (cm *CatalogManager) GetCatalogInfo() map[string]interface{} {
 	cm.mutex.RLock()
@@ -336,6 +658,7 @@ func (cm *CatalogManager) GetCatalogInfo() map[string]interface{} {
 	return map[string]interface{}{
 		"total_tables":  len(cm.tables),
 		"total_indexes": len(cm.indexes),
+		"total_views":   len(cm.views),
 		"total_rows":    cm.getTotalRows(),
 		"total_pages":   cm.getTotalPages(),
 	}
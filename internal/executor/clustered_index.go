@@ -0,0 +1,472 @@
+// Package executor - Clustered index component
+// Stores the rows of a table in the leaves of a B+tree, keyed by the primary
+// key or, for a heap table, by the row's values
+package executor
+
+import (
+	"fmt"
+	"sync"
+)
+
+// defaultClusteredIndexOrder is the maximum number of keys per B+tree node
+const defaultClusteredIndexOrder = 64
+
+// ClusteredIndex is a B+tree keyed by a table's primary key whose leaves hold
+// the rows themselves. Leaves are linked left to right, so a range scan is a
+// single descent followed by a walk along the leaf chain and always returns
+// rows in primary key order.
+//
+// The rows of a heap table, one without a primary key, are held the same
+// way: a row's key is all of its values followed by a row number that
+// keeps equal rows apart, and a row is addressed by its values alone.
+// Architecture: Part of Execution Engine Layer
+type ClusteredIndex struct {
+	tableName  string
+	keyColumns []string
+	keyIndexes []int // positions of the key columns in the row
+	schema     *TupleSchema
+	order      int
+
+	heap    bool
+	nextRow int64 // row number of the next row of a heap table
+
+	root *clusteredNode
+	size int
+
+	mutex sync.RWMutex
+}
+
+// clusteredNode is a B+tree node. Internal nodes hold separator keys and
+// children; leaf nodes hold keys and rows and are chained through next.
+type clusteredNode struct {
+	leaf     bool
+	keys     [][]interface{}
+	children []*clusteredNode // internal nodes only
+	rows     []*Tuple         // leaf nodes only
+	next     *clusteredNode   // leaf nodes only
+}
+
+// KeyBound is one end of a primary key range. Key may be a prefix of the
+// primary key, in which case only the leading key columns are compared.
+type KeyBound struct {
+	Key       []interface{}
+	Inclusive bool
+}
+
+// NewClusteredIndex creates an empty clustered index for a table schema
+func NewClusteredIndex(schema *TableSchema) (*ClusteredIndex, error) {
+	if schema == nil {
+		return nil, fmt.Errorf("schema cannot be nil")
+	}
+
+	if len(schema.PrimaryKey) == 0 {
+		return nil, fmt.Errorf("clustered table %s requires a primary key", schema.TableName)
+	}
+
+	tupleSchema := NewTupleSchema(schema.Columns)
+	keyIndexes := make([]int, len(schema.PrimaryKey))
+	for i, col := range schema.PrimaryKey {
+		idx := tupleSchema.GetColumnIndex(col)
+		if idx < 0 {
+			return nil, fmt.Errorf("primary key column %s does not exist", col)
+		}
+		keyIndexes[i] = idx
+	}
+
+	return &ClusteredIndex{
+		tableName:  schema.TableName,
+		keyColumns: schema.PrimaryKey,
+		keyIndexes: keyIndexes,
+		schema:     tupleSchema,
+		order:      defaultClusteredIndexOrder,
+		root:       &clusteredNode{leaf: true},
+	}, nil
+}
+
+// NewHeapIndex creates an empty index for the rows of a table without a
+// primary key
+func NewHeapIndex(schema *TableSchema) (*ClusteredIndex, error) {
+	if schema == nil {
+		return nil, fmt.Errorf("schema cannot be nil")
+	}
+
+	tupleSchema := NewTupleSchema(schema.Columns)
+	keyIndexes := make([]int, len(schema.Columns))
+	for i := range keyIndexes {
+		keyIndexes[i] = i
+	}
+
+	return &ClusteredIndex{
+		tableName:  schema.TableName,
+		keyIndexes: keyIndexes,
+		schema:     tupleSchema,
+		order:      defaultClusteredIndexOrder,
+		heap:       true,
+		root:       &clusteredNode{leaf: true},
+	}, nil
+}
+
+// newRowIndex creates the index holding a table's rows: keyed by its
+// primary key, or a heap index when it has none
+func newRowIndex(schema *TableSchema) (*ClusteredIndex, error) {
+	if schema != nil && len(schema.PrimaryKey) == 0 {
+		return NewHeapIndex(schema)
+	}
+	return NewClusteredIndex(schema)
+}
+
+// TableName returns the name of the table stored in this index
+func (ci *ClusteredIndex) TableName() string {
+	return ci.tableName
+}
+
+// KeyColumns returns the primary key columns rows are ordered by, none for
+// a heap table
+func (ci *ClusteredIndex) KeyColumns() []string {
+	return ci.keyColumns
+}
+
+// Schema returns the schema of the stored rows
+func (ci *ClusteredIndex) Schema() *TupleSchema {
+	return ci.schema
+}
+
+// Len returns the number of rows in the index
+func (ci *ClusteredIndex) Len() int {
+	ci.mutex.RLock()
+	defer ci.mutex.RUnlock()
+	return ci.size
+}
+
+// Insert stores a row, keyed by its primary key columns
+func (ci *ClusteredIndex) Insert(values []interface{}) error {
+	if len(values) != len(ci.schema.Columns) {
+		return fmt.Errorf("expected %d values, got %d", len(ci.schema.Columns), len(values))
+	}
+
+	key := ci.extractKey(values)
+	for i, v := range key {
+		if v == nil && !ci.heap {
+			return fmt.Errorf("primary key column %s cannot be NULL", ci.keyColumns[i])
+		}
+	}
+
+	ci.mutex.Lock()
+	defer ci.mutex.Unlock()
+
+	if ci.heap {
+		key = append(key, ci.nextRow)
+		ci.nextRow++
+	}
+
+	row := NewTuple(ci.schema, values)
+	splitKey, right, err := ci.insert(ci.root, key, row)
+	if err != nil {
+		return err
+	}
+
+	// Root split: grow the tree by one level
+	if right != nil {
+		ci.root = &clusteredNode{
+			keys:     [][]interface{}{splitKey},
+			children: []*clusteredNode{ci.root, right},
+		}
+	}
+
+	ci.size++
+	return nil
+}
+
+// insert adds a row below node, returning the separator key and new right
+// sibling if node had to be split
+func (ci *ClusteredIndex) insert(node *clusteredNode, key []interface{}, row *Tuple) ([]interface{}, *clusteredNode, error) {
+	if node.leaf {
+		pos := searchKeys(node.keys, key)
+		if pos < len(node.keys) && compareKeys(node.keys[pos], key) == 0 {
+			return nil, nil, fmt.Errorf("duplicate primary key %v in table %s", key, ci.tableName)
+		}
+
+		node.keys = insertKey(node.keys, pos, key)
+		node.rows = append(node.rows, nil)
+		copy(node.rows[pos+1:], node.rows[pos:])
+		node.rows[pos] = row
+
+		if len(node.keys) <= ci.order {
+			return nil, nil, nil
+		}
+		return ci.splitLeaf(node)
+	}
+
+	child := childIndex(node.keys, key)
+	splitKey, right, err := ci.insert(node.children[child], key, row)
+	if err != nil || right == nil {
+		return nil, nil, err
+	}
+
+	node.keys = insertKey(node.keys, child, splitKey)
+	node.children = append(node.children, nil)
+	copy(node.children[child+2:], node.children[child+1:])
+	node.children[child+1] = right
+
+	if len(node.keys) <= ci.order {
+		return nil, nil, nil
+	}
+	return ci.splitInternal(node)
+}
+
+// splitLeaf moves the upper half of a leaf into a new right sibling
+func (ci *ClusteredIndex) splitLeaf(node *clusteredNode) ([]interface{}, *clusteredNode, error) {
+	mid := len(node.keys) / 2
+
+	right := &clusteredNode{
+		leaf: true,
+		keys: append([][]interface{}{}, node.keys[mid:]...),
+		rows: append([]*Tuple{}, node.rows[mid:]...),
+		next: node.next,
+	}
+
+	node.keys = node.keys[:mid]
+	node.rows = node.rows[:mid]
+	node.next = right
+
+	return right.keys[0], right, nil
+}
+
+// splitInternal moves the upper half of an internal node into a new right
+// sibling and pushes the middle key up
+func (ci *ClusteredIndex) splitInternal(node *clusteredNode) ([]interface{}, *clusteredNode, error) {
+	mid := len(node.keys) / 2
+	splitKey := node.keys[mid]
+
+	right := &clusteredNode{
+		keys:     append([][]interface{}{}, node.keys[mid+1:]...),
+		children: append([]*clusteredNode{}, node.children[mid+1:]...),
+	}
+
+	node.keys = node.keys[:mid]
+	node.children = node.children[:mid+1]
+
+	return splitKey, right, nil
+}
+
+// Get returns the row with the given primary key, or nil if it does not
+// exist. The key of a heap table row is its values.
+func (ci *ClusteredIndex) Get(key []interface{}) *Tuple {
+	ci.mutex.RLock()
+	defer ci.mutex.RUnlock()
+
+	leaf, pos := ci.seek(key)
+	if leaf != nil && compareKeys(leaf.keys[pos], key) == 0 {
+		return leaf.rows[pos]
+	}
+	return nil
+}
+
+// Delete removes the row with the given primary key; of equal heap table
+// rows, one is removed. Leaves are not merged on underflow; empty leaves
+// stay in the chain and are skipped by scans.
+func (ci *ClusteredIndex) Delete(key []interface{}) bool {
+	ci.mutex.Lock()
+	defer ci.mutex.Unlock()
+
+	leaf, pos := ci.seek(key)
+	if leaf == nil || compareKeys(leaf.keys[pos], key) != 0 {
+		return false
+	}
+
+	leaf.keys = append(leaf.keys[:pos], leaf.keys[pos+1:]...)
+	leaf.rows = append(leaf.rows[:pos], leaf.rows[pos+1:]...)
+	ci.size--
+	return true
+}
+
+// CollectKeys walks the leaf chain in key order and returns the keys of up
+// to limit rows accepted by match. A limit of 0 or less collects all.
+func (ci *ClusteredIndex) CollectKeys(match func(*Tuple) bool, limit int) [][]interface{} {
+	ci.mutex.RLock()
+	defer ci.mutex.RUnlock()
+
+	node := ci.root
+	for !node.leaf {
+		node = node.children[0]
+	}
+
+	var keys [][]interface{}
+	for ; node != nil; node = node.next {
+		for i, row := range node.rows {
+			if !match(row) {
+				continue
+			}
+			keys = append(keys, node.keys[i])
+			if limit > 0 && len(keys) >= limit {
+				return keys
+			}
+		}
+	}
+	return keys
+}
+
+// Scan returns an iterator over rows whose primary key lies between lower
+// and upper, in ascending key order. A nil bound leaves that end open. The
+// rows are those stored when Scan is called; later changes to the index do
+// not affect the iterator.
+func (ci *ClusteredIndex) Scan(lower, upper *KeyBound) *ClusteredIndexIterator {
+	ci.mutex.RLock()
+	defer ci.mutex.RUnlock()
+
+	if lower != nil && len(lower.Key) == 0 {
+		lower = nil
+	}
+	if upper != nil && len(upper.Key) == 0 {
+		upper = nil
+	}
+
+	var leaf *clusteredNode
+	pos := 0
+	if lower == nil {
+		leaf = ci.root
+		for !leaf.leaf {
+			leaf = leaf.children[0]
+		}
+	} else {
+		leaf, pos = ci.seek(lower.Key)
+	}
+
+	var rows []*Tuple
+	for ; leaf != nil; leaf, pos = leaf.next, 0 {
+		for ; pos < len(leaf.keys); pos++ {
+			key := leaf.keys[pos]
+			if lower != nil && !lower.admits(key) {
+				continue
+			}
+			if upper != nil && !upper.bounds(key) {
+				return &ClusteredIndexIterator{rows: rows}
+			}
+			rows = append(rows, leaf.rows[pos])
+		}
+	}
+	return &ClusteredIndexIterator{rows: rows}
+}
+
+// rowValues returns the values of the stored rows in key order
+func (ci *ClusteredIndex) rowValues() [][]interface{} {
+	var rows [][]interface{}
+	iter := ci.Scan(nil, nil)
+	for row := iter.Next(); row != nil; row = iter.Next() {
+		rows = append(rows, row.Values)
+	}
+	return rows
+}
+
+// seek returns the leaf and position of the first key at or above key, or a
+// nil leaf if there is none. Key may be a prefix of the stored keys.
+// Callers must hold the index mutex.
+func (ci *ClusteredIndex) seek(key []interface{}) (*clusteredNode, int) {
+	node := ci.root
+	for !node.leaf {
+		node = node.children[searchKeys(node.keys, key)]
+	}
+
+	pos := searchKeys(node.keys, key)
+	// The key may lie past the end of the leaf, and leaves emptied by
+	// deletes stay in the chain
+	for node != nil && pos >= len(node.keys) {
+		node, pos = node.next, 0
+	}
+	return node, pos
+}
+
+// extractKey returns the primary key values of a row; for a heap table, the
+// row's values
+func (ci *ClusteredIndex) extractKey(values []interface{}) []interface{} {
+	key := make([]interface{}, len(ci.keyIndexes))
+	for i, idx := range ci.keyIndexes {
+		key[i] = values[idx]
+	}
+	return key
+}
+
+// ClusteredIndexIterator returns the rows a scan of a clustered index found
+type ClusteredIndexIterator struct {
+	rows []*Tuple
+	pos  int
+}
+
+// Next returns the next row in key order, or nil when the range is exhausted
+func (it *ClusteredIndexIterator) Next() *Tuple {
+	if it.pos >= len(it.rows) {
+		return nil
+	}
+	row := it.rows[it.pos]
+	it.pos++
+	return row
+}
+
+// admits reports whether key is at or above this lower bound
+func (kb *KeyBound) admits(key []interface{}) bool {
+	cmp := compareKeys(key, kb.Key)
+	if kb.Inclusive {
+		return cmp >= 0
+	}
+	return cmp > 0
+}
+
+// bounds reports whether key is at or below this upper bound
+func (kb *KeyBound) bounds(key []interface{}) bool {
+	cmp := compareKeys(key, kb.Key)
+	if kb.Inclusive {
+		return cmp <= 0
+	}
+	return cmp < 0
+}
+
+// compareKeys compares two keys column by column. Only the common prefix is
+// compared, so a partial key matches every full key that starts with it.
+func compareKeys(a, b []interface{}) int {
+	n := len(a)
+	if len(b) < n {
+		n = len(b)
+	}
+	for i := 0; i < n; i++ {
+		if cmp := compareValues(a[i], b[i]); cmp != 0 {
+			return cmp
+		}
+	}
+	return 0
+}
+
+// searchKeys returns the first position whose key is >= key
+func searchKeys(keys [][]interface{}, key []interface{}) int {
+	lo, hi := 0, len(keys)
+	for lo < hi {
+		mid := (lo + hi) / 2
+		if compareKeys(keys[mid], key) < 0 {
+			lo = mid + 1
+		} else {
+			hi = mid
+		}
+	}
+	return lo
+}
+
+// childIndex returns the child of an internal node that key belongs in
+func childIndex(keys [][]interface{}, key []interface{}) int {
+	lo, hi := 0, len(keys)
+	for lo < hi {
+		mid := (lo + hi) / 2
+		if compareKeys(keys[mid], key) <= 0 {
+			lo = mid + 1
+		} else {
+			hi = mid
+		}
+	}
+	return lo
+}
+
+// insertKey inserts key at position pos
+func insertKey(keys [][]interface{}, pos int, key []interface{}) [][]interface{} {
+	keys = append(keys, nil)
+	copy(keys[pos+1:], keys[pos:])
+	keys[pos] = key
+	return keys
+}
@@ -0,0 +1,162 @@
+package executor
+
+import (
+	"fmt"
+
+	"relational-db/internal/compiler"
+)
+
+// CompilerCatalog exposes the executor's catalog to the query compiler,
+// semantic analyzer and optimizer, which read table metadata through the
+// compiler.CatalogManager interface
+type CompilerCatalog struct {
+	catalog *CatalogManager
+}
+
+// NewCompilerCatalog creates a compiler view of a catalog
+func NewCompilerCatalog(catalog *CatalogManager) *CompilerCatalog {
+	return &CompilerCatalog{catalog: catalog}
+}
+
+// GetTable builds table metadata from the table's current schema
+func (cc *CompilerCatalog) GetTable(name string) (*compiler.TableMetadata, error) {
+	schema, err := cc.catalog.schemaManager.GetSchema(name)
+	if err != nil {
+		return nil, fmt.Errorf("table not found: %s", name)
+	}
+
+	table := compiler.NewTableMetadata(schema.TableName)
+	table.PrimaryKey = schema.PrimaryKey
+	table.Clustered = schema.Clustered
+	if schema.TTL != nil {
+		table.TTLColumn = schema.TTL.Column
+		table.TTL = schema.TTL.Duration
+	}
+
+	primaryKey := make(map[string]bool, len(schema.PrimaryKey))
+	for _, col := range schema.PrimaryKey {
+		primaryKey[col] = true
+	}
+
+	for _, col := range schema.Columns {
+		column := compiler.NewColumnMetadata(col.Name, compilerDataType(col.Type))
+		column.TableName = schema.TableName
+		column.Nullable = col.Nullable
+		column.IsPrimaryKey = primaryKey[col.Name]
+		table.AddColumn(column)
+	}
+
+	constraints, _ := cc.catalog.schemaManager.GetConstraints(name)
+	for _, constraint := range constraints {
+		table.Constraints = append(table.Constraints, constraint.Name)
+	}
+	for _, fk := range schema.ForeignKeys {
+		if fk.Name != "" {
+			table.Constraints = append(table.Constraints, fk.Name)
+		}
+	}
+	for _, index := range schema.Indexes {
+		table.Indexes = append(table.Indexes, index.Name)
+		if index.IsUnique {
+			table.UniqueKeys = append(table.UniqueKeys, append([]string{}, index.Columns...))
+		}
+	}
+
+	if entry, err := cc.catalog.GetTable(name); err == nil {
+		table.TableID = entry.TableID
+		table.RowCount = int64(entry.RowCount)
+		table.TotalSize = int64(entry.DataSize)
+		table.CreatedAt = entry.CreatedAt
+		table.Partitioning = entry.Partitioning
+	}
+
+	return table, nil
+}
+
+// GetColumn retrieves column metadata
+func (cc *CompilerCatalog) GetColumn(table, column string) (*compiler.ColumnMetadata, error) {
+	t, err := cc.GetTable(table)
+	if err != nil {
+		return nil, err
+	}
+	return t.GetColumn(column)
+}
+
+// TableExists checks if a table exists
+func (cc *CompilerCatalog) TableExists(name string) bool {
+	_, err := cc.catalog.schemaManager.GetSchema(name)
+	return err == nil
+}
+
+// ListTables returns all table names
+func (cc *CompilerCatalog) ListTables() ([]string, error) {
+	return cc.catalog.schemaManager.ListSchemas(), nil
+}
+
+// GetView builds view metadata from the view's catalog entry
+func (cc *CompilerCatalog) GetView(name string) (*compiler.ViewMetadata, error) {
+	entry, err := cc.catalog.GetView(name)
+	if err != nil {
+		return nil, fmt.Errorf("view not found: %s", name)
+	}
+
+	return &compiler.ViewMetadata{
+		Name:         entry.ViewName,
+		Query:        entry.Definition,
+		Columns:      append([]string{}, entry.Columns...),
+		Materialized: entry.Materialized,
+		DependsOn:    append([]string{}, entry.DependsOn...),
+	}, nil
+}
+
+// ListViews returns all view names
+func (cc *CompilerCatalog) ListViews() ([]string, error) {
+	return cc.catalog.ListViews(), nil
+}
+
+// compilerDataType maps an executor column type to the compiler's type
+func compilerDataType(ct ColumnType) compiler.DataType {
+	switch ct {
+	case TypeInt, TypeBigInt:
+		return compiler.DataTypeInteger
+	case TypeFloat, TypeDouble:
+		return compiler.DataTypeReal
+	case TypeString:
+		return compiler.DataTypeText
+	case TypeBoolean:
+		return compiler.DataTypeBoolean
+	case TypeDate:
+		return compiler.DataTypeDate
+	case TypeTimestamp:
+		return compiler.DataTypeTimestamp
+	case TypeNull:
+		return compiler.DataTypeNull
+	case TypeBlob:
+		return compiler.DataTypeBlob
+	default:
+		return compiler.DataTypeUnknown
+	}
+}
+
+// columnTypeOf maps a compiler type to the executor column type that holds
+// its values. Types the compiler could not infer are stored untyped.
+func columnTypeOf(dt compiler.DataType) ColumnType {
+	switch dt {
+	case compiler.DataTypeInteger:
+		return TypeBigInt
+	case compiler.DataTypeReal, compiler.DataTypeNumeric:
+		return TypeDouble
+	case compiler.DataTypeText:
+		return TypeString
+	case compiler.DataTypeBoolean:
+		return TypeBoolean
+	case compiler.DataTypeDate:
+		return TypeDate
+	case compiler.DataTypeTime, compiler.DataTypeTimestamp:
+		return TypeTimestamp
+	case compiler.DataTypeBlob:
+		return TypeBlob
+	default:
+		return TypeNull
+	}
+}
@@ -16,6 +16,19 @@ type ExecutionContext struct {
 	startTime   time.Time
 	memoryUsed  int64
 	memoryLimit int64
+
+	// Values bound to the query's ? and $n parameters, in order
+	parameters []interface{}
+
+	// Runs the subqueries of the query's expressions
+	subqueries *subqueryRunner
+
+	// Materializes the query's common table expressions
+	ctes *cteRunner
+
+	// Rows of the enclosing queries while running a correlated subquery,
+	// innermost first
+	outer *outerRow
 }
 
 // NewExecutionContext creates a new execution context
@@ -38,6 +51,24 @@ func (ec *ExecutionContext) SetBufferPool(pool *storage.BufferPool) {
 	ec.bufferPool = pool
 }
 
+// SetParameters binds values to the query's parameters
+func (ec *ExecutionContext) SetParameters(params []interface{}) {
+	ec.parameters = params
+}
+
+// Parameters returns the values bound to the query's parameters
+func (ec *ExecutionContext) Parameters() []interface{} {
+	return ec.parameters
+}
+
+// withOuterRow returns a context for running a correlated subquery for one
+// row of the enclosing query
+func (ec *ExecutionContext) withOuterRow(row *Tuple) *ExecutionContext {
+	child := *ec
+	child.outer = &outerRow{tuple: row, next: ec.outer}
+	return &child
+}
+
 // GetStorage returns the storage engine
 func (ec *ExecutionContext) GetStorage() storage.StorageEngine {
 	return ec.storage
@@ -0,0 +1,51 @@
+package executor
+
+import (
+	"fmt"
+
+	"relational-db/internal/optimizer"
+	"relational-db/internal/parser"
+)
+
+// cteRunner materializes the common table expressions of one query
+// execution. A CTE's rows are computed on its first scan and shared by all
+// later ones.
+type cteRunner struct {
+	executor *Executor
+	plans    map[*parser.CommonTableExpression]*optimizer.CTEPlan
+	results  map[*parser.CommonTableExpression]*ResultSet
+
+	// Rows produced by the last iteration of each recursive CTE being
+	// computed, read by its recursive term
+	workTables map[*parser.CommonTableExpression]*ResultSet
+}
+
+// newCTERunner creates a runner for the CTE plans of a query
+func newCTERunner(executor *Executor, plans map[*parser.CommonTableExpression]*optimizer.CTEPlan) *cteRunner {
+	return &cteRunner{
+		executor:   executor,
+		plans:      plans,
+		results:    make(map[*parser.CommonTableExpression]*ResultSet),
+		workTables: make(map[*parser.CommonTableExpression]*ResultSet),
+	}
+}
+
+// materialize returns the rows of a CTE, computing them on first use
+func (cr *cteRunner) materialize(ctx *ExecutionContext, cte *parser.CommonTableExpression) (*ResultSet, error) {
+	if result, cached := cr.results[cte]; cached {
+		return result, nil
+	}
+
+	plan, found := cr.plans[cte]
+	if !found {
+		return nil, ErrCTENotPlanned
+	}
+
+	result, err := cr.executor.runPlan(ctx, plan.Root, 0)
+	if err != nil {
+		return nil, fmt.Errorf("common table expression %s: %w", cte.Name.Value, err)
+	}
+
+	cr.results[cte] = result
+	return result, nil
+}
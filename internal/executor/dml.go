@@ -0,0 +1,1060 @@
+package executor
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"time"
+
+	"relational-db/internal/compiler"
+	"relational-db/internal/parser"
+)
+
+// DMLResult is the outcome of an INSERT, UPDATE, DELETE or MERGE
+type DMLResult struct {
+	RowsAffected int64
+
+	// LastInsertID is the last value an INSERT generated for the table's
+	// AUTO_INCREMENT column, 0 if it generated none
+	LastInsertID int64
+
+	// Returning holds the rows of the RETURNING clause, nil without one
+	Returning *ResultSet
+}
+
+// ExecuteDML runs a prepared INSERT, UPDATE, DELETE or MERGE with values
+// bound to its parameters. Params should come from
+// BindParameters. A statement that fails part way leaves the table as it
+// was.
+func (e *Executor) ExecuteDML(ctx context.Context, planner *QueryPlanner, prepared *PreparedPlan, params []interface{}) (*DMLResult, error) {
+	if prepared.Plan == nil {
+		return nil, fmt.Errorf("statement is not a planned query")
+	}
+
+	execCtx := e.executionContext(ctx, prepared.Plan, params)
+	evaluator := NewExpressionEvaluator()
+	evaluator.Bind(execCtx)
+
+	catalog := planner.catalog.catalog
+	switch stmt := prepared.Statement.(type) {
+	case *parser.InsertStatement:
+		return e.insertRows(ctx, catalog, planner, prepared, stmt, evaluator, params)
+	case *parser.UpdateStatement:
+		return e.updateRows(ctx, catalog, prepared, stmt, evaluator, params)
+	case *parser.DeleteStatement:
+		return e.deleteRows(ctx, catalog, prepared, stmt, evaluator, params)
+	case *parser.MergeStatement:
+		return e.mergeRows(ctx, catalog, planner, prepared, stmt, evaluator, params)
+	default:
+		return nil, fmt.Errorf("%s is not an INSERT, UPDATE, DELETE or MERGE", prepared.Compiled.QueryType)
+	}
+}
+
+// ExecuteDML runs a prepared INSERT, UPDATE, DELETE or MERGE in a
+// transaction of its own. The table it modifies is locked exclusively and
+// the tables it reads are share-locked until it commits, so conflict
+// checks and the changes they decide on cannot interleave with another
+// statement's. The changed rows are stored when it commits.
+func (te *TransactionExecutor) ExecuteDML(ctx context.Context, planner *QueryPlanner, prepared *PreparedPlan, params []interface{}) (*DMLResult, error) {
+	txn, err := te.BeginTransaction(te.GetIsolationLevel())
+	if err != nil {
+		return nil, err
+	}
+
+	result, err := te.ExecuteDMLInTransaction(ctx, txn.ID, planner, prepared, params)
+	if err != nil {
+		te.RollbackTransaction(txn.ID)
+		return nil, err
+	}
+
+	if err := te.CommitTransaction(txn.ID); err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// ExecuteDMLInTransaction runs a prepared INSERT, UPDATE, DELETE or MERGE
+// in an open transaction. Its locks are held until the transaction ends,
+// and rolling the transaction back, or back to a savepoint taken before
+// the statement, restores the rows it changed. The rows are stored when
+// the transaction commits. A statement that fails leaves the table as it
+// was and the transaction open.
+func (te *TransactionExecutor) ExecuteDMLInTransaction(ctx context.Context, txnID uint64, planner *QueryPlanner, prepared *PreparedPlan, params []interface{}) (*DMLResult, error) {
+	target := modifiedTable(prepared.Statement)
+	if target == "" {
+		return nil, fmt.Errorf("%s is not an INSERT, UPDATE, DELETE or MERGE", prepared.Compiled.QueryType)
+	}
+
+	txn, err := te.GetTransaction(txnID)
+	if err != nil {
+		return nil, err
+	}
+	txn.mutex.Lock()
+	defer txn.mutex.Unlock()
+
+	if txn.State != TxnActive {
+		return nil, fmt.Errorf("transaction %d is not active", txnID)
+	}
+	if err := te.lockTables(txn, target, prepared); err != nil {
+		return nil, err
+	}
+
+	catalog := planner.catalog.catalog
+	exec := te.queryExecutor
+	before, err := exec.storedRows(catalog, target)
+	if err != nil {
+		return nil, err
+	}
+	result, err := exec.ExecuteDML(ctx, planner, prepared, params)
+	if err != nil {
+		return nil, err
+	}
+	after, err := exec.storedRows(catalog, target)
+	if err != nil {
+		return nil, err
+	}
+
+	// Rows whose key or values changed are put back as they were
+	var inserted, removed [][]interface{}
+	for key, values := range after {
+		if old, exists := before[key]; !exists || !reflect.DeepEqual(old, values) {
+			inserted = append(inserted, values)
+		}
+	}
+	for key, values := range before {
+		if now, exists := after[key]; !exists || !reflect.DeepEqual(now, values) {
+			removed = append(removed, values)
+		}
+	}
+
+	txn.Operations = append(txn.Operations, &TransactionOperation{
+		Type:      operationType(prepared.Statement),
+		TableName: target,
+		Timestamp: time.Now(),
+		undo: func() {
+			for _, values := range inserted {
+				exec.removeRow(catalog, target, values)
+			}
+			for _, values := range removed {
+				exec.InsertRow(catalog, target, values)
+			}
+		},
+		persist: func() error {
+			return exec.saveRows(catalog, target)
+		},
+	})
+	txn.RowsModified += uint64(result.RowsAffected)
+
+	return result, nil
+}
+
+// lockTables locks the table a statement modifies exclusively and the
+// tables it reads in share mode for a transaction
+func (te *TransactionExecutor) lockTables(txn *Transaction, target string, prepared *PreparedPlan) error {
+	if err := te.lockManager.AcquireTableLock(txn.ID, target, ExclusiveLock); err != nil {
+		return err
+	}
+	for _, table := range prepared.Compiled.ResolvedRefs.Dependencies {
+		if table == target {
+			continue
+		}
+		if err := te.lockManager.AcquireTableLock(txn.ID, table, SharedLock); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// operationType returns the kind of change a data-modifying statement makes
+func operationType(stmt parser.Statement) OperationType {
+	switch stmt.(type) {
+	case *parser.InsertStatement:
+		return InsertOp
+	case *parser.DeleteStatement:
+		return DeleteOp
+	default:
+		return UpdateOp
+	}
+}
+
+// modifiedTable returns the table a data-modifying statement changes, or
+// "" for other statements
+func modifiedTable(stmt parser.Statement) string {
+	switch s := stmt.(type) {
+	case *parser.InsertStatement:
+		return s.TableName.Value
+	case *parser.UpdateStatement:
+		return s.TableName.Value
+	case *parser.DeleteStatement:
+		return s.TableName.Value
+	case *parser.MergeStatement:
+		return s.Target.Value
+	default:
+		return ""
+	}
+}
+
+// insertRows stores the rows of an INSERT, from its VALUES lists or from
+// running its query. Columns left out are NULL, except an AUTO_INCREMENT
+// column, which takes the next value of the table's counter. With ON
+// CONFLICT a row that conflicts with a stored one is skipped, or changes
+// the stored row instead.
+func (e *Executor) insertRows(ctx context.Context, catalog *CatalogManager, planner *QueryPlanner, prepared *PreparedPlan, stmt *parser.InsertStatement, evaluator *ExpressionEvaluator, params []interface{}) (*DMLResult, error) {
+	tableName := stmt.TableName.Value
+	schema, err := catalog.schemaManager.GetSchema(tableName)
+	if err != nil {
+		return nil, err
+	}
+
+	// Without a column list values fill the table's columns in order
+	positions := make([]int, 0, len(schema.Columns))
+	for _, col := range stmt.Columns {
+		pos := columnPosition(schema, col.Value)
+		if pos < 0 {
+			return nil, fmt.Errorf("column %s not found in table %s", col.Value, tableName)
+		}
+		positions = append(positions, pos)
+	}
+	if len(stmt.Columns) == 0 {
+		for pos := range schema.Columns {
+			positions = append(positions, pos)
+		}
+	}
+
+	sources, err := e.insertSources(ctx, planner, stmt, evaluator, params)
+	if err != nil {
+		return nil, err
+	}
+
+	result := newDMLResult(prepared)
+	tupleSchema := NewTupleSchema(schema.Columns)
+	var inserted, replaced [][]interface{}
+	undo := func() {
+		for _, values := range inserted {
+			e.removeRow(catalog, tableName, values)
+		}
+		for _, values := range replaced {
+			e.InsertRow(catalog, tableName, values)
+		}
+	}
+
+	// Rows this statement stored, by primary key, which DO UPDATE may not
+	// change again
+	stored := make(map[string]bool)
+
+	for _, source := range sources {
+		if len(source) > len(positions) {
+			undo()
+			return nil, fmt.Errorf("INSERT has more expressions than target columns")
+		}
+		values := columnDefaults(schema)
+		for i, value := range source {
+			values[positions[i]] = value
+		}
+
+		generated, err := e.fillAutoIncrement(catalog, schema, values)
+		if err != nil {
+			undo()
+			return nil, err
+		}
+		if err := checkNotNull(schema, values); err != nil {
+			undo()
+			return nil, err
+		}
+
+		if conflict := stmt.OnConflict; conflict != nil {
+			existing, err := e.conflictingRow(catalog, schema, values, conflict.Columns)
+			if err != nil {
+				undo()
+				return nil, err
+			}
+			if existing != nil {
+				if !conflict.DoUpdate {
+					continue
+				}
+				if stored[primaryKeyOf(schema, existing.Values)] {
+					undo()
+					return nil, fmt.Errorf("ON CONFLICT DO UPDATE cannot change a row of %s a second time", tableName)
+				}
+
+				update, ok, err := conflictUpdate(evaluator, conflict, schema, existing.Values, values)
+				if err != nil {
+					undo()
+					return nil, err
+				}
+				if !ok {
+					continue
+				}
+				if err := checkNotNull(schema, update); err != nil {
+					undo()
+					return nil, err
+				}
+				e.removeRow(catalog, tableName, existing.Values)
+				replaced = append(replaced, existing.Values)
+				values, generated = update, 0
+			}
+		}
+
+		if _, err := e.InsertRow(catalog, tableName, values); err != nil {
+			undo()
+			return nil, err
+		}
+		inserted = append(inserted, values)
+		stored[primaryKeyOf(schema, values)] = true
+
+		result.RowsAffected++
+		if generated != 0 {
+			result.LastInsertID = generated
+		}
+		if err := result.addReturning(evaluator, stmt.Returning, NewTuple(tupleSchema, values)); err != nil {
+			undo()
+			return nil, err
+		}
+	}
+
+	return result, nil
+}
+
+// conflictingRow returns the stored row a new row of the table conflicts
+// with on its primary key or a unique index, or nil if there is none. With
+// arbiter columns only the key on those columns is checked. NULLs never
+// conflict.
+func (e *Executor) conflictingRow(catalog *CatalogManager, schema *TableSchema, values []interface{}, arbiter []*parser.Identifier) (*Tuple, error) {
+	columns := make([]string, len(arbiter))
+	for i, col := range arbiter {
+		columns[i] = col.Value
+	}
+	checked := func(key []string) bool {
+		return len(columns) == 0 || compiler.SameColumnSet(key, columns)
+	}
+
+	target, err := catalog.RouteRow(schema.TableName, values)
+	if err != nil {
+		return nil, err
+	}
+
+	e.clusteredMutex.RLock()
+	defer e.clusteredMutex.RUnlock()
+
+	index, exists := e.clusteredIndexes[target]
+	if !exists {
+		return nil, fmt.Errorf("table %s does not store rows", target)
+	}
+
+	// A heap table has no primary key to conflict on
+	if !index.heap && checked(index.KeyColumns()) {
+		key := index.extractKey(values)
+		if !hasNull(key) {
+			if row := index.Get(key); row != nil {
+				return row, nil
+			}
+		}
+	}
+
+	for _, si := range e.tableIndexes(schema.TableName) {
+		if !si.entry.IsUnique || !checked(si.entry.Columns) {
+			continue
+		}
+		key, err := si.ExtractKey(index.Schema(), values)
+		if err != nil {
+			return nil, err
+		}
+		if hasNull(key) {
+			continue
+		}
+		for _, rowKey := range si.Lookup(key) {
+			for _, rows := range e.tableRows(catalog, schema.TableName) {
+				if row := rows.Get(rowKey); row != nil {
+					return row, nil
+				}
+			}
+		}
+	}
+	return nil, nil
+}
+
+// conflictUpdate computes the new values ON CONFLICT DO UPDATE gives a
+// stored row, seeing the stored row under the table's name and the row
+// that was not inserted as excluded. It reports false when the clause's
+// WHERE condition does not hold, which leaves the row as it is.
+func conflictUpdate(evaluator *ExpressionEvaluator, conflict *parser.OnConflictClause, schema *TableSchema, existing, excluded []interface{}) ([]interface{}, bool, error) {
+	columns := make([]ColumnInfo, 0, 2*len(schema.Columns))
+	for _, table := range []string{schema.TableName, compiler.ExcludedTable} {
+		for _, col := range schema.Columns {
+			col.TableName = table
+			columns = append(columns, col)
+		}
+	}
+	row := NewTuple(NewTupleSchema(columns), append(append([]interface{}{}, existing...), excluded...))
+
+	if conflict.WhereClause != nil {
+		holds, err := conditionHolds(evaluator, conflict.WhereClause.Condition, row)
+		if err != nil || !holds {
+			return nil, false, err
+		}
+	}
+
+	values := append([]interface{}{}, existing...)
+	for _, set := range conflict.SetClauses {
+		pos := columnPosition(schema, set.Column.Value)
+		if pos < 0 {
+			return nil, false, fmt.Errorf("column %s not found in table %s", set.Column.Value, schema.TableName)
+		}
+		value, err := evaluator.Evaluate(set.Value, row)
+		if err != nil {
+			return nil, false, err
+		}
+		values[pos] = value
+	}
+	return values, true, nil
+}
+
+// insertSources returns the rows an INSERT stores, in the order of its
+// target columns
+func (e *Executor) insertSources(ctx context.Context, planner *QueryPlanner, stmt *parser.InsertStatement, evaluator *ExpressionEvaluator, params []interface{}) ([][]interface{}, error) {
+	if stmt.Query == nil {
+		empty := NewTuple(NewTupleSchema(nil), nil)
+		rows := make([][]interface{}, 0, len(stmt.Values))
+		for _, exprs := range stmt.Values {
+			values := make([]interface{}, len(exprs))
+			for i, expr := range exprs {
+				value, err := evaluator.Evaluate(expr, empty)
+				if err != nil {
+					return nil, err
+				}
+				values[i] = value
+			}
+			rows = append(rows, values)
+		}
+		return rows, nil
+	}
+
+	// The query is planned on its own; parameters keep their numbers since
+	// the query comes before anything else that can hold one
+	prepared, err := planner.Prepare(stmt.Query.String())
+	if err != nil {
+		return nil, err
+	}
+	result, err := e.ExecuteWithParameters(ctx, prepared.Plan, params[:min(len(params), prepared.ParameterCount)])
+	if err != nil {
+		return nil, err
+	}
+
+	rows := make([][]interface{}, 0, result.RowCount())
+	for _, tuple := range result.Tuples {
+		rows = append(rows, append([]interface{}{}, tuple.Values...))
+	}
+	return rows, nil
+}
+
+// updateRows changes the rows an UPDATE matches. The new values of every row
+// are computed from the old ones before any row changes. With FROM, a row
+// joined to several rows is changed once, and they must all give it the
+// same new values.
+func (e *Executor) updateRows(ctx context.Context, catalog *CatalogManager, prepared *PreparedPlan, stmt *parser.UpdateStatement, evaluator *ExpressionEvaluator, params []interface{}) (*DMLResult, error) {
+	tableName := stmt.TableName.Value
+	schema, err := catalog.schemaManager.GetSchema(tableName)
+	if err != nil {
+		return nil, err
+	}
+
+	positions := make([]int, len(stmt.SetClauses))
+	for i, set := range stmt.SetClauses {
+		positions[i] = columnPosition(schema, set.Column.Value)
+		if positions[i] < 0 {
+			return nil, fmt.Errorf("column %s not found in table %s", set.Column.Value, tableName)
+		}
+	}
+
+	targets, err := e.targetRows(ctx, catalog, prepared, tableName, stmt.From, stmt.WhereClause, evaluator, params)
+	if err != nil {
+		return nil, err
+	}
+
+	// RETURNING is evaluated before any row changes so an error leaves
+	// every row in place
+	result := newDMLResult(prepared)
+	updated := make([][]interface{}, len(targets))
+	for i, target := range targets {
+		var values []interface{}
+		for _, row := range target.matches {
+			next := append([]interface{}{}, target.row.Values...)
+			for j, set := range stmt.SetClauses {
+				value, err := evaluator.Evaluate(set.Value, row)
+				if err != nil {
+					return nil, err
+				}
+				next[positions[j]] = value
+			}
+			if values != nil && !reflect.DeepEqual(values, next) {
+				return nil, fmt.Errorf("rows joined to a row of %s update it to different values", tableName)
+			}
+			values = next
+		}
+		if err := checkNotNull(schema, values); err != nil {
+			return nil, err
+		}
+
+		// RETURNING sees the new values, joined to the first row that matched
+		first := target.matches[0]
+		row := NewTuple(first.Schema, append(append([]interface{}{}, values...), first.Values[len(values):]...))
+		if err := result.addJoinedReturning(evaluator, stmt.Returning, values, row); err != nil {
+			return nil, err
+		}
+		updated[i] = values
+	}
+
+	// Old rows go first so a row may take a key another one gives up
+	for _, target := range targets {
+		e.removeRow(catalog, tableName, target.row.Values)
+	}
+	for i, values := range updated {
+		if _, err := e.InsertRow(catalog, tableName, values); err != nil {
+			for _, stored := range updated[:i] {
+				e.removeRow(catalog, tableName, stored)
+			}
+			for _, target := range targets {
+				e.InsertRow(catalog, tableName, target.row.Values)
+			}
+			return nil, err
+		}
+	}
+
+	result.RowsAffected = int64(len(updated))
+	return result, nil
+}
+
+// deleteRows removes the rows a DELETE matches. RETURNING is evaluated for
+// every row before any is removed. With USING, a row joined to several
+// rows is removed once.
+func (e *Executor) deleteRows(ctx context.Context, catalog *CatalogManager, prepared *PreparedPlan, stmt *parser.DeleteStatement, evaluator *ExpressionEvaluator, params []interface{}) (*DMLResult, error) {
+	tableName := stmt.TableName.Value
+	targets, err := e.targetRows(ctx, catalog, prepared, tableName, stmt.Using, stmt.WhereClause, evaluator, params)
+	if err != nil {
+		return nil, err
+	}
+
+	result := newDMLResult(prepared)
+	for _, target := range targets {
+		if err := result.addJoinedReturning(evaluator, stmt.Returning, target.row.Values, target.matches[0]); err != nil {
+			return nil, err
+		}
+	}
+
+	for _, target := range targets {
+		e.removeRow(catalog, tableName, target.row.Values)
+		result.RowsAffected++
+	}
+	return result, nil
+}
+
+// targetRows returns the rows an UPDATE or DELETE changes: the rows of the
+// target that satisfy its WHERE condition, or with a FROM or USING clause
+// the target rows that join a row of its tables
+func (e *Executor) targetRows(ctx context.Context, catalog *CatalogManager, prepared *PreparedPlan, tableName string, from *parser.FromClause, where *parser.WhereClause, evaluator *ExpressionEvaluator, params []interface{}) ([]*joinedTarget, error) {
+	if from != nil {
+		return e.joinedTargets(ctx, catalog, prepared.Plan, tableName, from, where, evaluator, params)
+	}
+
+	matched, err := e.matchingRows(catalog, tableName, where, evaluator)
+	if err != nil {
+		return nil, err
+	}
+	targets := make([]*joinedTarget, len(matched))
+	for i, row := range matched {
+		targets[i] = &joinedTarget{row: row, matches: []*Tuple{row}}
+	}
+	return targets, nil
+}
+
+// mergeRows runs a MERGE. Every source row is matched against the target
+// rows as they were before the statement, and the first WHEN clause that
+// applies decides what happens to it. The changes are made once all rows
+// are matched, and a failure leaves the target as it was.
+func (e *Executor) mergeRows(ctx context.Context, catalog *CatalogManager, planner *QueryPlanner, prepared *PreparedPlan, stmt *parser.MergeStatement, evaluator *ExpressionEvaluator, params []interface{}) (*DMLResult, error) {
+	tableName := stmt.Target.Value
+	schema, err := catalog.schemaManager.GetSchema(tableName)
+	if err != nil {
+		return nil, err
+	}
+
+	targets, err := e.matchingRows(catalog, tableName, nil, evaluator)
+	if err != nil {
+		return nil, err
+	}
+	if stmt.Target.Alias != nil {
+		naming := &aliasedSchema{alias: stmt.Target.Alias.Value}
+		for i := range targets {
+			targets[i] = naming.apply(targets[i])
+		}
+	}
+
+	sources, err := e.mergeSource(ctx, catalog, planner, stmt.Source, evaluator, params)
+	if err != nil {
+		return nil, err
+	}
+
+	// Old values of the rows updated or deleted, by target row, and the
+	// rows stored in their place or inserted
+	removed := make(map[int][]interface{})
+	var order []int
+	var updated, added [][]interface{}
+
+	var joinedSchema *TupleSchema
+	for _, source := range sources {
+		matched := false
+		for i, target := range targets {
+			if joinedSchema == nil {
+				joinedSchema = NewTupleSchema(append(append([]ColumnInfo{}, target.Schema.Columns...), source.Schema.Columns...))
+			}
+			row := NewTuple(joinedSchema, append(append([]interface{}{}, target.Values...), source.Values...))
+
+			holds, err := conditionHolds(evaluator, stmt.Condition, row)
+			if err != nil {
+				return nil, err
+			}
+			if !holds {
+				continue
+			}
+			matched = true
+
+			clause, err := firstMergeClause(evaluator, stmt.Clauses, true, row)
+			if err != nil {
+				return nil, err
+			}
+			if clause == nil || clause.Action == parser.MergeDoNothing {
+				continue
+			}
+			if _, done := removed[i]; done {
+				return nil, fmt.Errorf("MERGE cannot change a row of %s a second time", tableName)
+			}
+			removed[i] = target.Values
+			order = append(order, i)
+
+			if clause.Action == parser.MergeUpdate {
+				values := append([]interface{}{}, target.Values...)
+				for _, set := range clause.SetClauses {
+					pos := columnPosition(schema, set.Column.Value)
+					if pos < 0 {
+						return nil, fmt.Errorf("column %s not found in table %s", set.Column.Value, tableName)
+					}
+					if values[pos], err = evaluator.Evaluate(set.Value, row); err != nil {
+						return nil, err
+					}
+				}
+				if err := checkNotNull(schema, values); err != nil {
+					return nil, err
+				}
+				updated = append(updated, values)
+			}
+		}
+		if matched {
+			continue
+		}
+
+		clause, err := firstMergeClause(evaluator, stmt.Clauses, false, source)
+		if err != nil {
+			return nil, err
+		}
+		if clause == nil || clause.Action != parser.MergeInsert {
+			continue
+		}
+		values := columnDefaults(schema)
+		for i, expr := range clause.Values {
+			pos := i
+			if len(clause.Columns) > 0 {
+				if pos = columnPosition(schema, clause.Columns[i].Value); pos < 0 {
+					return nil, fmt.Errorf("column %s not found in table %s", clause.Columns[i].Value, tableName)
+				}
+			}
+			if values[pos], err = evaluator.Evaluate(expr, source); err != nil {
+				return nil, err
+			}
+		}
+		added = append(added, values)
+	}
+
+	// Old rows go first so a row may take a key another one gives up
+	for _, i := range order {
+		e.removeRow(catalog, tableName, removed[i])
+	}
+	var stored [][]interface{}
+	undo := func() {
+		for _, values := range stored {
+			e.removeRow(catalog, tableName, values)
+		}
+		for _, i := range order {
+			e.InsertRow(catalog, tableName, removed[i])
+		}
+	}
+
+	result := newDMLResult(prepared)
+	for _, values := range updated {
+		if _, err := e.InsertRow(catalog, tableName, values); err != nil {
+			undo()
+			return nil, err
+		}
+		stored = append(stored, values)
+	}
+	for _, values := range added {
+		generated, err := e.fillAutoIncrement(catalog, schema, values)
+		if err != nil {
+			undo()
+			return nil, err
+		}
+		if err := checkNotNull(schema, values); err != nil {
+			undo()
+			return nil, err
+		}
+		if _, err := e.InsertRow(catalog, tableName, values); err != nil {
+			undo()
+			return nil, err
+		}
+		stored = append(stored, values)
+		if generated != 0 {
+			result.LastInsertID = generated
+		}
+	}
+
+	result.RowsAffected = int64(len(order) + len(added))
+	return result, nil
+}
+
+// mergeSource returns the rows of a MERGE's source, a table or a derived
+// table, with their columns qualified by the name the statement gives it
+func (e *Executor) mergeSource(ctx context.Context, catalog *CatalogManager, planner *QueryPlanner, source parser.Expression, evaluator *ExpressionEvaluator, params []interface{}) ([]*Tuple, error) {
+	var rows []*Tuple
+	naming := &aliasedSchema{}
+
+	switch s := source.(type) {
+	case *parser.Identifier:
+		var err error
+		if rows, err = e.matchingRows(catalog, s.Value, nil, evaluator); err != nil {
+			return nil, err
+		}
+		naming.alias = s.Value
+		if s.Alias != nil {
+			naming.alias = s.Alias.Value
+		}
+
+	case *parser.SubqueryExpression:
+		// The source comes before anything else in the statement that can
+		// hold a parameter, so its parameters keep their numbers
+		prepared, err := planner.Prepare(s.Query.String())
+		if err != nil {
+			return nil, err
+		}
+		result, err := e.ExecuteWithParameters(ctx, prepared.Plan, params[:min(len(params), prepared.ParameterCount)])
+		if err != nil {
+			return nil, err
+		}
+		rows = result.Tuples
+		naming.alias = s.Alias.Value
+		for _, col := range s.Columns {
+			naming.columns = append(naming.columns, col.Value)
+		}
+
+	default:
+		return nil, fmt.Errorf("unsupported MERGE source %s", source.String())
+	}
+
+	for i := range rows {
+		rows[i] = naming.apply(rows[i])
+	}
+	return rows, nil
+}
+
+// firstMergeClause returns the first WHEN [NOT] MATCHED clause whose AND
+// condition holds for row, or nil if none applies
+func firstMergeClause(evaluator *ExpressionEvaluator, clauses []*parser.MergeClause, matched bool, row *Tuple) (*parser.MergeClause, error) {
+	for _, clause := range clauses {
+		if clause.Matched != matched {
+			continue
+		}
+		if clause.Condition == nil {
+			return clause, nil
+		}
+		holds, err := conditionHolds(evaluator, clause.Condition, row)
+		if err != nil {
+			return nil, err
+		}
+		if holds {
+			return clause, nil
+		}
+	}
+	return nil, nil
+}
+
+// matchingRows returns the rows of a table, across its partitions, that a
+// WHERE clause holds for; all of them without one
+func (e *Executor) matchingRows(catalog *CatalogManager, tableName string, where *parser.WhereClause, evaluator *ExpressionEvaluator) ([]*Tuple, error) {
+	schema, err := catalog.schemaManager.GetSchema(tableName)
+	if err != nil {
+		return nil, err
+	}
+	tupleSchema := NewTupleSchema(schema.Columns)
+
+	e.clusteredMutex.RLock()
+	indexes := e.tableRows(catalog, tableName)
+	e.clusteredMutex.RUnlock()
+	if len(indexes) == 0 {
+		return nil, fmt.Errorf("table %s does not store rows", tableName)
+	}
+
+	var matched []*Tuple
+	for _, index := range indexes {
+		iter := index.Scan(nil, nil)
+		for tuple := iter.Next(); tuple != nil; tuple = iter.Next() {
+			row := NewTuple(tupleSchema, tuple.Values)
+			if where != nil && where.Condition != nil {
+				holds, err := conditionHolds(evaluator, where.Condition, row)
+				if err != nil {
+					return nil, err
+				}
+				if !holds {
+					continue
+				}
+			}
+			matched = append(matched, row)
+		}
+	}
+	return matched, nil
+}
+
+// conditionHolds evaluates a condition for a row; NULL does not hold
+func conditionHolds(evaluator *ExpressionEvaluator, condition parser.Expression, row *Tuple) (bool, error) {
+	value, err := evaluator.Evaluate(condition, row)
+	if err != nil {
+		return false, err
+	}
+	holds, err := truthValue(value)
+	if err != nil {
+		return false, err
+	}
+	return holds != nil && *holds, nil
+}
+
+// removeRow deletes a stored row of a table, with its entries in the
+// table's secondary indexes
+func (e *Executor) removeRow(catalog *CatalogManager, tableName string, values []interface{}) {
+	target, err := catalog.RouteRow(tableName, values)
+	if err != nil {
+		return
+	}
+
+	e.clusteredMutex.RLock()
+	defer e.clusteredMutex.RUnlock()
+
+	index, exists := e.clusteredIndexes[target]
+	if !exists {
+		return
+	}
+	e.deleteRow(index, tableName, index.extractKey(values))
+}
+
+// deleteRow deletes the row with the given key from the index holding the
+// rows of a table, with its entries in the table's secondary indexes, and
+// reports whether the row was stored. Callers must hold clusteredMutex.
+func (e *Executor) deleteRow(index *ClusteredIndex, tableName string, key []interface{}) bool {
+	lock := e.tableLock(tableName)
+	lock.Lock()
+	defer lock.Unlock()
+
+	row := index.Get(key)
+	if row == nil {
+		return false
+	}
+	rowKey := index.extractKey(row.Values)
+	for _, si := range e.tableIndexes(tableName) {
+		if key, err := si.ExtractKey(index.Schema(), row.Values); err == nil {
+			si.Delete(key, rowKey)
+		}
+	}
+	return index.Delete(key)
+}
+
+// storedRows returns the rows of a table by primary key. The rows of a heap
+// table are told apart by their values, and equal rows by their count.
+func (e *Executor) storedRows(catalog *CatalogManager, tableName string) (map[string][]interface{}, error) {
+	schema, err := catalog.schemaManager.GetSchema(tableName)
+	if err != nil {
+		return nil, err
+	}
+
+	e.clusteredMutex.RLock()
+	defer e.clusteredMutex.RUnlock()
+
+	rows := make(map[string][]interface{})
+	copies := make(map[string]int)
+	for _, index := range e.tableRows(catalog, tableName) {
+		iter := index.Scan(nil, nil)
+		for tuple := iter.Next(); tuple != nil; tuple = iter.Next() {
+			key := primaryKeyOf(schema, tuple.Values)
+			if index.heap {
+				key = fmt.Sprintf("%#v", tuple.Values)
+				copies[key]++
+				key = fmt.Sprintf("%s#%d", key, copies[key])
+			}
+			rows[key] = tuple.Values
+		}
+	}
+	return rows, nil
+}
+
+// fillAutoIncrement gives a row's AUTO_INCREMENT column the next value of
+// the table's counter when the INSERT left it NULL, and returns that value,
+// or 0 if none was generated. A value given explicitly moves the counter
+// past it. The counter starts after the largest value already stored.
+func (e *Executor) fillAutoIncrement(catalog *CatalogManager, schema *TableSchema, values []interface{}) (int64, error) {
+	if schema.AutoIncrement == "" {
+		return 0, nil
+	}
+	pos := columnPosition(schema, schema.AutoIncrement)
+	if pos < 0 {
+		return 0, fmt.Errorf("AUTO_INCREMENT column %s not found in table %s", schema.AutoIncrement, schema.TableName)
+	}
+
+	e.sequenceMutex.Lock()
+	defer e.sequenceMutex.Unlock()
+
+	last, seeded := e.sequences[schema.TableName]
+	if !seeded {
+		e.clusteredMutex.RLock()
+		for _, index := range e.tableRows(catalog, schema.TableName) {
+			iter := index.Scan(nil, nil)
+			for tuple := iter.Next(); tuple != nil; tuple = iter.Next() {
+				if n, ok := toInt64(tuple.Values[pos]); ok && n > last {
+					last = n
+				}
+			}
+		}
+		e.clusteredMutex.RUnlock()
+	}
+
+	if values[pos] != nil {
+		n, ok := toInt64(values[pos])
+		if !ok {
+			return 0, fmt.Errorf("%w: AUTO_INCREMENT column %s requires an integer, got %T",
+				ErrTypeMismatch, schema.AutoIncrement, values[pos])
+		}
+		if n > last {
+			last = n
+		}
+		e.sequences[schema.TableName] = last
+		return 0, nil
+	}
+
+	last++
+	values[pos] = last
+	e.sequences[schema.TableName] = last
+	return last, nil
+}
+
+// forgetSequence drops a table's AUTO_INCREMENT counter, which is seeded
+// again from the table's rows when next needed
+func (e *Executor) forgetSequence(tableName string) {
+	e.sequenceMutex.Lock()
+	defer e.sequenceMutex.Unlock()
+	delete(e.sequences, tableName)
+}
+
+// newDMLResult creates the result of a statement, with a result set for
+// the rows of its RETURNING clause when it has one
+func newDMLResult(prepared *PreparedPlan) *DMLResult {
+	result := &DMLResult{}
+	if returning := prepared.Compiled.ResolvedRefs.Returning; returning != nil {
+		columns := make([]ColumnInfo, 0, len(returning.Columns))
+		for _, col := range returning.Columns {
+			columns = append(columns, ColumnInfo{
+				Name:     col.Name,
+				Type:     columnTypeOf(col.DataType),
+				Nullable: true,
+			})
+		}
+		result.Returning = NewResultSetWithSchema(NewTupleSchema(columns))
+	}
+	return result
+}
+
+// addReturning evaluates a RETURNING clause for a row the statement
+// inserted, updated or deleted and adds the values to the result
+func (r *DMLResult) addReturning(evaluator *ExpressionEvaluator, returning []parser.Expression, row *Tuple) error {
+	return r.addJoinedReturning(evaluator, returning, row.Values, row)
+}
+
+// addJoinedReturning evaluates a RETURNING clause for a row of the target
+// with the given values. Expressions are evaluated on row, which may join
+// the columns of other tables to the target's; * stands for the target's
+// columns only.
+func (r *DMLResult) addJoinedReturning(evaluator *ExpressionEvaluator, returning []parser.Expression, target []interface{}, row *Tuple) error {
+	if r.Returning == nil {
+		return nil
+	}
+
+	var values []interface{}
+	for _, expr := range returning {
+		if _, ok := expr.(*parser.Wildcard); ok {
+			values = append(values, target...)
+			continue
+		}
+		value, err := evaluator.Evaluate(expr, row)
+		if err != nil {
+			return err
+		}
+		values = append(values, value)
+	}
+	r.Returning.AddTuple(NewTuple(r.Returning.Schema, values))
+	return nil
+}
+
+// primaryKeyOf returns a row's primary key values as a map key
+func primaryKeyOf(schema *TableSchema, values []interface{}) string {
+	key := make([]interface{}, len(schema.PrimaryKey))
+	for i, col := range schema.PrimaryKey {
+		if pos := columnPosition(schema, col); pos >= 0 {
+			key[i] = values[pos]
+		}
+	}
+	return fmt.Sprintf("%#v", key)
+}
+
+// hasNull reports whether a key has a NULL value
+func hasNull(key []interface{}) bool {
+	for _, v := range key {
+		if v == nil {
+			return true
+		}
+	}
+	return false
+}
+
+// columnDefaults returns a row of a table holding the DEFAULT of each column
+func columnDefaults(schema *TableSchema) []interface{} {
+	values := make([]interface{}, len(schema.Columns))
+	for i, col := range schema.Columns {
+		values[i] = col.Default
+	}
+	return values
+}
+
+// checkNotNull rejects a row that is NULL in a NOT NULL column of its table
+func checkNotNull(schema *TableSchema, values []interface{}) error {
+	for i, col := range schema.Columns {
+		if !col.Nullable && i < len(values) && values[i] == nil {
+			return fmt.Errorf("%w: column %s of table %s is NOT NULL", ErrNullValue, col.Name, schema.TableName)
+		}
+	}
+	return nil
+}
+
+// columnPosition returns the position of a column in a table's schema, or
+// -1 if the table has no such column. Names match case-insensitively, as
+// they do in the compiler.
+func columnPosition(schema *TableSchema, name string) int {
+	for i, col := range schema.Columns {
+		if col.Name == name {
+			return i
+		}
+	}
+	return -1
+}
@@ -0,0 +1,258 @@
+package executor
+
+import (
+	"context"
+	"fmt"
+
+	"relational-db/internal/optimizer"
+	"relational-db/internal/parser"
+)
+
+// joinedTarget is a row an UPDATE or DELETE changes and the rows its
+// expressions are evaluated on: the row itself, or with FROM or USING the
+// row joined to each row it matches
+type joinedTarget struct {
+	row     *Tuple
+	matches []*Tuple // the target row's values followed by a joined row's
+}
+
+// joinedTargets joins the rows of the target of an UPDATE or DELETE to the
+// rows of its FROM or USING tables under the statement's WHERE condition,
+// with the join algorithm the optimizer chose. Target rows that join no
+// row are left out; the others keep the order of the target.
+func (e *Executor) joinedTargets(ctx context.Context, catalog *CatalogManager, plan *optimizer.QueryPlan, tableName string, from *parser.FromClause, where *parser.WhereClause, evaluator *ExpressionEvaluator, params []interface{}) ([]*joinedTarget, error) {
+	if len(plan.Root.Children) != 1 || len(plan.Root.Children[0].Children) != 2 {
+		return nil, fmt.Errorf("no join plan for the tables joined to %s", tableName)
+	}
+	join := plan.Root.Children[0]
+
+	targets, err := e.fromItemRows(ctx, catalog, plan, join.Children[0], &parser.Identifier{Value: tableName}, evaluator, params)
+	if err != nil {
+		return nil, err
+	}
+	sources, err := e.fromClauseRows(ctx, catalog, plan, join.Children[1], from, evaluator, params)
+	if err != nil {
+		return nil, err
+	}
+
+	// A hash join only pairs rows whose key values are equal; the whole
+	// condition is still checked for each pair
+	var buckets map[string][]*Tuple
+	if join.Type == optimizer.PhysicalPlanTypeHashJoin {
+		buckets = make(map[string][]*Tuple)
+		for _, source := range sources.rows {
+			key, err := joinKeyOf(evaluator, join.JoinKeys, false, source)
+			if err != nil {
+				return nil, err
+			}
+			if key != "" {
+				buckets[key] = append(buckets[key], source)
+			}
+		}
+	}
+
+	var joined []*joinedTarget
+	schema := joinedSchema(targets.schema, sources.schema)
+	for _, target := range targets.rows {
+		candidates := sources.rows
+		if buckets != nil {
+			key, err := joinKeyOf(evaluator, join.JoinKeys, true, target)
+			if err != nil {
+				return nil, err
+			}
+			candidates = buckets[key]
+		}
+
+		var matches []*Tuple
+		for _, source := range candidates {
+			row := NewTuple(schema, append(append([]interface{}{}, target.Values...), source.Values...))
+			if where != nil && where.Condition != nil {
+				holds, err := conditionHolds(evaluator, where.Condition, row)
+				if err != nil {
+					return nil, err
+				}
+				if !holds {
+					continue
+				}
+			}
+			matches = append(matches, row)
+		}
+		if len(matches) > 0 {
+			joined = append(joined, &joinedTarget{row: target, matches: matches})
+		}
+	}
+	return joined, nil
+}
+
+// joinKeyOf returns the values of a row for one side of a hash join's keys
+// as a map key, or "" when one of them is NULL and the row can match none.
+// Numbers are compared as floats, as the = operator compares them.
+func joinKeyOf(evaluator *ExpressionEvaluator, keys []optimizer.JoinKey, left bool, row *Tuple) (string, error) {
+	values := make([]interface{}, len(keys))
+	for i, key := range keys {
+		expr := key.Right
+		if left {
+			expr = key.Left
+		}
+		value, err := evaluator.Evaluate(expr, row)
+		if err != nil {
+			return "", err
+		}
+		if value == nil {
+			return "", nil
+		}
+		if f, ok := toFloat64(value); ok {
+			value = f
+		}
+		values[i] = value
+	}
+	return fmt.Sprintf("%#v", values), nil
+}
+
+// relation is a list of rows and the schema they share
+type relation struct {
+	schema *TupleSchema
+	rows   []*Tuple
+}
+
+// fromClauseRows returns the rows of the tables of a FROM or USING clause
+// joined together. plan is the clause's part of the statement's plan, whose
+// scans are in the order of the clause's items.
+func (e *Executor) fromClauseRows(ctx context.Context, catalog *CatalogManager, plan *optimizer.QueryPlan, sources *optimizer.PhysicalPlan, from *parser.FromClause, evaluator *ExpressionEvaluator, params []interface{}) (*relation, error) {
+	scans := sourceScans(sources)
+	if len(scans) != len(from.Tables)+len(from.Joins) {
+		return nil, fmt.Errorf("plan does not match FROM clause %s", from.String())
+	}
+
+	var rows *relation
+	for i, table := range from.Tables {
+		items, err := e.fromItemRows(ctx, catalog, plan, scans[i], table, evaluator, params)
+		if err != nil {
+			return nil, err
+		}
+		if i == 0 {
+			rows = items
+			continue
+		}
+		if rows, err = joinRelations(evaluator, rows, items, nil, parser.InnerJoin); err != nil {
+			return nil, err
+		}
+	}
+
+	for i, join := range from.Joins {
+		items, err := e.fromItemRows(ctx, catalog, plan, scans[len(from.Tables)+i], join.Table, evaluator, params)
+		if err != nil {
+			return nil, err
+		}
+		if rows, err = joinRelations(evaluator, rows, items, join.Condition, join.JoinType); err != nil {
+			return nil, err
+		}
+	}
+	return rows, nil
+}
+
+// sourceScans returns the scans under the joins of a FROM clause's plan,
+// left to right
+func sourceScans(plan *optimizer.PhysicalPlan) []*optimizer.PhysicalPlan {
+	switch plan.Type {
+	case optimizer.PhysicalPlanTypeNestedLoopJoin, optimizer.PhysicalPlanTypeHashJoin, optimizer.PhysicalPlanTypeMergeJoin:
+		var scans []*optimizer.PhysicalPlan
+		for _, child := range plan.Children {
+			scans = append(scans, sourceScans(child)...)
+		}
+		return scans
+	default:
+		return []*optimizer.PhysicalPlan{plan}
+	}
+}
+
+// fromItemRows returns the rows of a FROM item, a table or a derived table,
+// with their columns qualified by the name the statement gives it. A
+// derived table's query runs from its plan.
+func (e *Executor) fromItemRows(ctx context.Context, catalog *CatalogManager, plan *optimizer.QueryPlan, scan *optimizer.PhysicalPlan, item parser.Expression, evaluator *ExpressionEvaluator, params []interface{}) (*relation, error) {
+	var schema *TupleSchema
+	var rows []*Tuple
+	naming := &aliasedSchema{}
+
+	switch s := item.(type) {
+	case *parser.Identifier:
+		table, err := catalog.schemaManager.GetSchema(s.Value)
+		if err != nil {
+			return nil, err
+		}
+		if rows, err = e.matchingRows(catalog, s.Value, nil, evaluator); err != nil {
+			return nil, err
+		}
+		schema = NewTupleSchema(table.Columns)
+		naming.alias = s.Value
+		if s.Alias != nil {
+			naming.alias = s.Alias.Value
+		}
+
+	case *parser.SubqueryExpression:
+		if scan.Type != optimizer.PhysicalPlanTypeSubqueryScan || len(scan.Children) != 1 {
+			return nil, fmt.Errorf("no plan for derived table %s", s.String())
+		}
+		result, err := e.ExecuteWithParameters(ctx, &optimizer.QueryPlan{
+			Root:       scan.Children[0],
+			Subqueries: plan.Subqueries,
+			CTEs:       plan.CTEs,
+		}, params)
+		if err != nil {
+			return nil, err
+		}
+		schema, rows = result.Schema, result.Tuples
+		naming.alias = s.Alias.Value
+		for _, col := range s.Columns {
+			naming.columns = append(naming.columns, col.Value)
+		}
+
+	default:
+		return nil, fmt.Errorf("unsupported FROM item %s", item.String())
+	}
+
+	named := &relation{schema: naming.apply(NewTuple(schema, nil)).Schema}
+	for _, row := range rows {
+		named.rows = append(named.rows, naming.apply(row))
+	}
+	return named, nil
+}
+
+// joinRelations joins two lists of rows by comparing every pair. A LEFT
+// join keeps the left rows that match none, with NULLs for the right
+// columns.
+func joinRelations(evaluator *ExpressionEvaluator, left, right *relation, cond parser.Expression, joinType parser.JoinType) (*relation, error) {
+	if joinType != parser.InnerJoin && joinType != parser.LeftJoin {
+		return nil, fmt.Errorf("%s is not supported here", joinType)
+	}
+
+	joined := &relation{schema: joinedSchema(left.schema, right.schema)}
+	for _, l := range left.rows {
+		matched := false
+		for _, r := range right.rows {
+			row := NewTuple(joined.schema, append(append([]interface{}{}, l.Values...), r.Values...))
+			if cond != nil {
+				holds, err := conditionHolds(evaluator, cond, row)
+				if err != nil {
+					return nil, err
+				}
+				if !holds {
+					continue
+				}
+			}
+			matched = true
+			joined.rows = append(joined.rows, row)
+		}
+		if !matched && joinType == parser.LeftJoin {
+			values := append(append([]interface{}{}, l.Values...), make([]interface{}, len(right.schema.Columns))...)
+			joined.rows = append(joined.rows, NewTuple(joined.schema, values))
+		}
+	}
+	return joined, nil
+}
+
+// joinedSchema returns the schema of rows made of a row of left followed by
+// a row of right
+func joinedSchema(left, right *TupleSchema) *TupleSchema {
+	return NewTupleSchema(append(append([]ColumnInfo{}, left.Columns...), right.Columns...))
+}
@@ -18,6 +18,15 @@ var (
 	ErrTypeMismatch          = errors.New("type mismatch")
 	ErrNullValue             = errors.New("unexpected null value")
 	ErrDivisionByZero        = errors.New("division by zero")
+	ErrMissingParameter      = errors.New("missing parameter value")
+	ErrSubqueryNotPlanned    = errors.New("subquery has no plan")
+	ErrSubqueryMultipleRows  = errors.New("subquery used as an expression returned more than one row")
+	ErrCTENotPlanned         = errors.New("common table expression has no plan")
+	ErrRecursionLimit        = errors.New("recursive query exceeded the maximum recursion depth")
+	ErrWindowNotComputed     = errors.New("window function was not computed")
+	ErrAggregateNotComputed  = errors.New("aggregate function was not computed")
+	ErrInvalidWindowOffset   = errors.New("window offset must be a non-negative integer")
+	ErrViewNotPopulated      = errors.New("materialized view has not been populated")
 )
 
 // ExecutionError represents an execution error with context
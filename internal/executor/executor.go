@@ -5,9 +5,12 @@ package executor
 import (
 	"context"
 	"fmt"
+	"sync"
 	"time"
 
+	"relational-db/internal/compiler"
 	"relational-db/internal/optimizer"
+	"relational-db/internal/parser"
 	"relational-db/internal/storage"
 )
 
@@ -17,6 +20,27 @@ type Executor struct {
 	bufferPool *storage.BufferPool
 	statistics *ExecutionStatistics
 	config     *ExecutorConfig
+
+	// Row storage: table or partition name -> B+tree holding its rows,
+	// keyed by primary key, or a heap index for a table without one
+	clusteredIndexes map[string]*ClusteredIndex
+
+	// Secondary indexes: index name -> index over a table's rows
+	secondaryIndexes map[string]*SecondaryIndex
+
+	// Materialized views: view name -> rows of its last refresh
+	materializedViews map[string]*materializedView
+
+	clusteredMutex sync.RWMutex // guards the index and materialized view maps
+
+	// Last AUTO_INCREMENT value per table, seeded from its stored rows
+	sequences     map[string]int64
+	sequenceMutex sync.Mutex
+
+	// Per-table locks serializing changes to a table's stored rows, so a
+	// row and its secondary index entries change together
+	tableLocks     map[string]*sync.Mutex
+	tableLockMutex sync.Mutex
 }
 
 // ExecutorConfig contains configuration for the executor
@@ -37,6 +61,13 @@ type ExecutorConfig struct {
 	EnablePipelining bool
 	EnableBatching   bool
 	BatchSize        int
+
+	// Iterations a recursive CTE may run before the query fails, which
+	// stops UNION ALL recursion over cyclic data. Zero means no limit.
+	MaxRecursionDepth int
+
+	// Size of the storage engine's pages, which hold the stored rows
+	PageSize int
 }
 
 // DefaultExecutorConfig returns default executor configuration
@@ -51,6 +82,9 @@ func DefaultExecutorConfig() *ExecutorConfig {
 		EnablePipelining: true,
 		EnableBatching:   true,
 		BatchSize:        1000,
+
+		MaxRecursionDepth: 1000,
+		PageSize:          4096,
 	}
 }
 
@@ -61,6 +95,12 @@ func NewExecutor(storageEngine storage.StorageEngine, bufferPool *storage.Buffer
 		bufferPool: bufferPool,
 		statistics: NewExecutionStatistics(),
 		config:     DefaultExecutorConfig(),
+
+		clusteredIndexes:  make(map[string]*ClusteredIndex),
+		secondaryIndexes:  make(map[string]*SecondaryIndex),
+		materializedViews: make(map[string]*materializedView),
+		sequences:         make(map[string]int64),
+		tableLocks:        make(map[string]*sync.Mutex),
 	}
 }
 
@@ -75,15 +115,448 @@ func NewExecutorWithConfig(
 		bufferPool: bufferPool,
 		statistics: NewExecutionStatistics(),
 		config:     config,
+
+		clusteredIndexes:  make(map[string]*ClusteredIndex),
+		secondaryIndexes:  make(map[string]*SecondaryIndex),
+		materializedViews: make(map[string]*materializedView),
+		sequences:         make(map[string]int64),
+		tableLocks:        make(map[string]*sync.Mutex),
 	}
 }
 
+// RegisterClusteredIndex makes a clustered table's index available to scans
+func (e *Executor) RegisterClusteredIndex(index *ClusteredIndex) {
+	e.clusteredMutex.Lock()
+	defer e.clusteredMutex.Unlock()
+	e.clusteredIndexes[index.TableName()] = index
+}
+
+// RegisterTables gives each table of a catalog loaded from storage an index
+// holding its rows, one per partition when it is partitioned, as CreateTable
+// does for a new table, and sets up the table's secondary indexes. The
+// rows are read from the table's rows page.
+func (e *Executor) RegisterTables(catalog *CatalogManager) error {
+	e.clusteredMutex.Lock()
+	defer e.clusteredMutex.Unlock()
+
+	tableNames := catalog.ListTables()
+	for _, tableName := range tableNames {
+		for _, entry := range catalog.ListIndexes(tableName) {
+			if _, exists := e.secondaryIndexes[entry.IndexName]; !exists && !entry.IsPrimary {
+				e.secondaryIndexes[entry.IndexName] = NewSecondaryIndex(entry)
+			}
+		}
+	}
+
+	for _, tableName := range tableNames {
+		if _, exists := e.clusteredIndexes[tableName]; exists || len(catalog.ListPartitions(tableName)) > 0 {
+			continue
+		}
+		// A materialized view keeps the rows of its last refresh in memory
+		if _, err := catalog.GetView(tableName); err == nil {
+			continue
+		}
+
+		schema, err := catalog.schemaManager.GetSchema(tableName)
+		if err != nil {
+			if schema, err = catalog.PartitionSchema(tableName); err != nil {
+				return err
+			}
+		}
+
+		index, err := newRowIndex(schema)
+		if err != nil {
+			return err
+		}
+		entry, err := catalog.GetTable(tableName)
+		if err != nil {
+			return err
+		}
+		if err := e.loadRows(entry, index); err != nil {
+			return err
+		}
+		e.clusteredIndexes[tableName] = index
+	}
+	return nil
+}
+
+// loadRows reads the stored rows of a table or partition into its index
+// and the secondary indexes of its table. Callers must hold clusteredMutex.
+func (e *Executor) loadRows(entry *TableCatalogEntry, index *ClusteredIndex) error {
+	if e.storage == nil || entry.RowsPage == 0 {
+		return nil
+	}
+
+	rows, err := readRows(e.storage, entry.RowsPage)
+	if err != nil {
+		return fmt.Errorf("failed to load rows of %s: %w", entry.TableName, err)
+	}
+
+	tableName := entry.TableName
+	if entry.ParentTable != "" {
+		tableName = entry.ParentTable
+	}
+	secondary := e.tableIndexes(tableName)
+	for _, values := range rows {
+		if err := index.Insert(values); err != nil {
+			return fmt.Errorf("failed to load rows of %s: %w", entry.TableName, err)
+		}
+		for _, si := range secondary {
+			key, err := si.ExtractKey(index.Schema(), values)
+			if err != nil {
+				return err
+			}
+			if err := si.Insert(key, index.extractKey(values)); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// saveRows writes the rows of a table, or of each of its partitions, to
+// their rows pages. Tables without a rows page keep their rows in memory
+// only.
+func (e *Executor) saveRows(catalog *CatalogManager, tableName string) error {
+	if e.storage == nil {
+		return nil
+	}
+
+	e.clusteredMutex.RLock()
+	defer e.clusteredMutex.RUnlock()
+
+	entries := catalog.ListPartitions(tableName)
+	if entry, err := catalog.GetTable(tableName); err == nil {
+		entries = append(entries, entry)
+	}
+	for _, entry := range entries {
+		index, exists := e.clusteredIndexes[entry.TableName]
+		if !exists || entry.RowsPage == 0 {
+			continue
+		}
+		if err := writeRows(e.storage, e.config.PageSize, entry.RowsPage, index.rowValues()); err != nil {
+			return fmt.Errorf("failed to save rows of %s: %w", entry.TableName, err)
+		}
+	}
+	return nil
+}
+
+// GetClusteredIndex returns the clustered index for a table
+func (e *Executor) GetClusteredIndex(tableName string) (*ClusteredIndex, error) {
+	e.clusteredMutex.RLock()
+	defer e.clusteredMutex.RUnlock()
+
+	index, exists := e.clusteredIndexes[tableName]
+	if !exists {
+		return nil, fmt.Errorf("table %s does not store rows", tableName)
+	}
+	return index, nil
+}
+
+// GetSecondaryIndex returns a secondary index by name
+func (e *Executor) GetSecondaryIndex(indexName string) (*SecondaryIndex, error) {
+	e.clusteredMutex.RLock()
+	defer e.clusteredMutex.RUnlock()
+
+	index, exists := e.secondaryIndexes[indexName]
+	if !exists {
+		return nil, fmt.Errorf("index %s not found", indexName)
+	}
+	return index, nil
+}
+
+// CreateTable validates a CREATE TABLE statement against the catalog and
+// creates the table with the partitions it declares. The table gets an
+// empty index to hold its rows, one per partition when it is partitioned.
+func (e *Executor) CreateTable(catalog *SystemCatalog, planner *QueryPlanner, stmt *parser.CreateTableStatement) error {
+	if _, err := planner.compiler.Compile(stmt); err != nil {
+		return err
+	}
+
+	schema, err := SchemaFromCreateTable(stmt)
+	if err != nil {
+		return err
+	}
+
+	var scheme *compiler.PartitionScheme
+	if stmt.PartitionBy != nil {
+		scheme, err = compiler.PartitionSchemeFromSpec(schema.TableName, stmt.PartitionBy)
+		if err != nil {
+			return err
+		}
+	}
+
+	names := []string{schema.TableName}
+	if scheme != nil {
+		names = scheme.PartitionNames()
+	}
+	indexes := make([]*ClusteredIndex, 0, len(names))
+	for _, name := range names {
+		rowsSchema := *schema
+		rowsSchema.TableName = name
+		index, err := newRowIndex(&rowsSchema)
+		if err != nil {
+			return err
+		}
+		indexes = append(indexes, index)
+	}
+
+	e.clusteredMutex.Lock()
+	defer e.clusteredMutex.Unlock()
+
+	if err := catalog.CreatePartitionedTable(schema, scheme); err != nil {
+		return err
+	}
+	for _, index := range indexes {
+		e.clusteredIndexes[index.TableName()] = index
+	}
+	return nil
+}
+
+// CreateIndex builds a secondary index from a table's existing rows and
+// registers it in the catalog. A unique index fails on duplicate rows and
+// leaves nothing behind.
+func (e *Executor) CreateIndex(catalog *SystemCatalog, stmt *parser.CreateIndexStatement) error {
+	entry, err := IndexEntryFromCreateIndex(stmt)
+	if err != nil {
+		return err
+	}
+
+	if _, err := catalog.catalogManager.GetIndex(entry.IndexName); err == nil {
+		if stmt.IfNotExists {
+			return nil
+		}
+		return fmt.Errorf("index %s already exists", entry.IndexName)
+	}
+
+	e.clusteredMutex.Lock()
+	defer e.clusteredMutex.Unlock()
+
+	index := NewSecondaryIndex(entry)
+	for _, rows := range e.tableRows(catalog.catalogManager, entry.TableName) {
+		iter := rows.Scan(nil, nil)
+		for tuple := iter.Next(); tuple != nil; tuple = iter.Next() {
+			key, err := index.ExtractKey(rows.Schema(), tuple.Values)
+			if err != nil {
+				return err
+			}
+			if err := index.Insert(key, rows.extractKey(tuple.Values)); err != nil {
+				return err
+			}
+		}
+	}
+
+	if err := catalog.CreateIndex(entry); err != nil {
+		return err
+	}
+	e.secondaryIndexes[entry.IndexName] = index
+	return nil
+}
+
+// DropIndex removes a secondary index and its catalog entry
+func (e *Executor) DropIndex(catalog *SystemCatalog, stmt *parser.DropIndexStatement) error {
+	indexName := stmt.IndexName.Value
+	entry, err := catalog.catalogManager.GetIndex(indexName)
+	if err != nil {
+		if stmt.IfExists {
+			return nil
+		}
+		return err
+	}
+	if entry.IsPrimary {
+		return fmt.Errorf("cannot drop primary key index %s", indexName)
+	}
+
+	e.clusteredMutex.Lock()
+	defer e.clusteredMutex.Unlock()
+
+	if err := catalog.DropIndex(indexName); err != nil {
+		return err
+	}
+	delete(e.secondaryIndexes, indexName)
+	return nil
+}
+
+// tableRows returns the clustered indexes holding a table's rows: the
+// table's own, or one per partition. Callers must hold clusteredMutex.
+func (e *Executor) tableRows(catalog *CatalogManager, tableName string) []*ClusteredIndex {
+	var indexes []*ClusteredIndex
+	if index, exists := e.clusteredIndexes[tableName]; exists {
+		indexes = append(indexes, index)
+	}
+	for _, partition := range catalog.ListPartitions(tableName) {
+		if index, exists := e.clusteredIndexes[partition.TableName]; exists {
+			indexes = append(indexes, index)
+		}
+	}
+	return indexes
+}
+
+// tableIndexes returns the secondary indexes of a table. Callers must hold
+// clusteredMutex.
+func (e *Executor) tableIndexes(tableName string) []*SecondaryIndex {
+	var indexes []*SecondaryIndex
+	for _, index := range e.secondaryIndexes {
+		if index.TableName() == tableName {
+			indexes = append(indexes, index)
+		}
+	}
+	return indexes
+}
+
+// AlterTable applies a schema change to a table. The table's rows are
+// rewritten into a new index under the altered schema and checked against
+// it, and stored under a new rows page, before the catalog commits the
+// change, so a failed ALTER TABLE leaves both the rows and the catalog as
+// they were.
+func (e *Executor) AlterTable(catalog *SystemCatalog, change *SchemaChange) error {
+	e.clusteredMutex.Lock()
+	defer e.clusteredMutex.Unlock()
+
+	rebuilt, err := e.rewriteClusteredIndex(change)
+	if err != nil {
+		return err
+	}
+
+	var previousRows storage.PageID
+	if entry, err := catalog.catalogManager.GetTable(change.Previous.TableName); err == nil && rebuilt != nil && e.storage != nil && entry.RowsPage != 0 {
+		previousRows = entry.RowsPage
+		if change.rowsPage, err = newRowsPage(e.storage, e.config.PageSize); err != nil {
+			return err
+		}
+		if err := writeRows(e.storage, e.config.PageSize, change.rowsPage, rebuilt.rowValues()); err != nil {
+			releaseRows(e.storage, change.rowsPage)
+			return err
+		}
+	}
+
+	if err := catalog.AlterTable(change); err != nil {
+		if change.rowsPage != 0 {
+			releaseRows(e.storage, change.rowsPage)
+		}
+		return err
+	}
+	if change.rowsPage != 0 {
+		releaseRows(e.storage, previousRows)
+	}
+
+	if rebuilt != nil {
+		delete(e.clusteredIndexes, change.Previous.TableName)
+		e.clusteredIndexes[rebuilt.TableName()] = rebuilt
+	}
+	e.forgetSequence(change.Previous.TableName)
+	return nil
+}
+
+// ExecuteDDL runs a schema statement against the system catalog, which
+// persists the change
+func (e *Executor) ExecuteDDL(ctx context.Context, catalog *SystemCatalog, planner *QueryPlanner, stmt parser.Statement) error {
+	switch stmt := stmt.(type) {
+	case *parser.CreateTableStatement:
+		return e.CreateTable(catalog, planner, stmt)
+	case *parser.DropTableStatement:
+		return e.DropTable(catalog, planner, stmt)
+	case *parser.AlterTableStatement:
+		if _, err := planner.compiler.Compile(stmt); err != nil {
+			return err
+		}
+		tableName := stmt.TableName.Value
+		schema, err := catalog.schemaManager.GetSchema(tableName)
+		if err != nil {
+			return err
+		}
+		constraints, _ := catalog.schemaManager.GetConstraints(tableName)
+		change, err := AlterSchema(schema, constraints, stmt)
+		if err != nil {
+			return err
+		}
+		return e.AlterTable(catalog, change)
+	case *parser.CreateIndexStatement:
+		return e.CreateIndex(catalog, stmt)
+	case *parser.DropIndexStatement:
+		return e.DropIndex(catalog, stmt)
+	case *parser.CreateViewStatement:
+		return e.CreateView(ctx, catalog, planner, stmt)
+	case *parser.DropViewStatement:
+		return e.DropView(catalog, planner, stmt)
+	case *parser.RefreshMaterializedViewStatement:
+		return e.RefreshMaterializedView(ctx, catalog, planner, stmt)
+	default:
+		return fmt.Errorf("unsupported schema statement %T", stmt)
+	}
+}
+
+// rewriteClusteredIndex builds an index holding the table's rows upgraded
+// to the altered schema. It returns nil for tables the executor holds no
+// rows of. Callers must hold clusteredMutex.
+func (e *Executor) rewriteClusteredIndex(change *SchemaChange) (*ClusteredIndex, error) {
+	index, exists := e.clusteredIndexes[change.Previous.TableName]
+	if !exists {
+		return nil, nil
+	}
+
+	rebuilt, err := newRowIndex(change.Schema)
+	if err != nil {
+		return nil, err
+	}
+
+	// Keys seen per UNIQUE constraint added by the change
+	seen := make([]map[string]bool, len(change.unique))
+	positions := make([][]int, len(change.unique))
+	for i, columns := range change.unique {
+		seen[i] = make(map[string]bool)
+		for _, col := range columns {
+			positions[i] = append(positions[i], rebuilt.Schema().GetColumnIndex(col))
+		}
+	}
+
+	evaluator := NewExpressionEvaluator()
+	iter := index.Scan(nil, nil)
+	for tuple := iter.Next(); tuple != nil; tuple = iter.Next() {
+		values, err := change.UpgradeRow(tuple.Values)
+		if err != nil {
+			return nil, err
+		}
+		if err := change.checkRow(evaluator, NewTuple(rebuilt.Schema(), values)); err != nil {
+			return nil, err
+		}
+
+		for i, columns := range positions {
+			key := make([]interface{}, len(columns))
+			hasNull := false
+			for j, pos := range columns {
+				key[j] = values[pos]
+				hasNull = hasNull || values[pos] == nil
+			}
+			// UNIQUE permits any number of rows with a NULL key column
+			if hasNull {
+				continue
+			}
+			encoded := fmt.Sprintf("%#v", key)
+			if seen[i][encoded] {
+				return nil, fmt.Errorf("duplicate key %v violates unique constraint on %v of table %s",
+					key, change.unique[i], change.Schema.TableName)
+			}
+			seen[i][encoded] = true
+		}
+
+		if err := rebuilt.Insert(values); err != nil {
+			return nil, err
+		}
+	}
+
+	return rebuilt, nil
+}
+
 // Execute executes a query plan and returns results
 func (e *Executor) Execute(ctx context.Context, plan *optimizer.QueryPlan) (*ResultSet, error) {
-	// Create execution context
-	execCtx := NewExecutionContext(ctx, e.config)
-	execCtx.SetStorage(e.storage)
-	execCtx.SetBufferPool(e.bufferPool)
+	return e.ExecuteWithParameters(ctx, plan, nil)
+}
+
+// ExecuteWithParameters executes a query plan with values bound to its ?
+// and $n parameters. Params should come from BindParameters.
+func (e *Executor) ExecuteWithParameters(ctx context.Context, plan *optimizer.QueryPlan, params []interface{}) (*ResultSet, error) {
+	execCtx := e.executionContext(ctx, plan, params)
 
 	// Build operator tree from physical plan
 	rootOperator, err := e.buildOperatorTree(plan.Root)
@@ -136,6 +609,18 @@ func (e *Executor) Execute(ctx context.Context, plan *optimizer.QueryPlan) (*Res
 	return resultSet, nil
 }
 
+// executionContext creates the context a plan executes in, with its
+// subqueries and CTEs ready to run
+func (e *Executor) executionContext(ctx context.Context, plan *optimizer.QueryPlan, params []interface{}) *ExecutionContext {
+	execCtx := NewExecutionContext(ctx, e.config)
+	execCtx.SetStorage(e.storage)
+	execCtx.SetBufferPool(e.bufferPool)
+	execCtx.SetParameters(params)
+	execCtx.subqueries = newSubqueryRunner(e, plan.Subqueries)
+	execCtx.ctes = newCTERunner(e, plan.CTEs)
+	return execCtx
+}
+
 // buildOperatorTree builds operator tree from physical plan
 func (e *Executor) buildOperatorTree(plan *optimizer.PhysicalPlan) (PhysicalOperator, error) {
 	if plan == nil {
@@ -154,8 +639,24 @@ func (e *Executor) buildOperatorTree(plan *optimizer.PhysicalPlan) (PhysicalOper
 
 	// Create operator based on plan type
 	switch plan.Type {
-	case optimizer.PhysicalPlanTypeSeqScan:
-		return NewSeqScanOperator(plan.TableName, nil), nil
+	case optimizer.PhysicalPlanTypeSeqScan, optimizer.PhysicalPlanTypeClusteredIndexScan:
+		var scan PhysicalOperator
+		var err error
+		if plan.Partitioned {
+			scan, err = e.buildPartitionScan(plan)
+		} else {
+			scan, err = e.buildTableScan(plan, plan.TableName)
+		}
+		if err != nil {
+			return nil, err
+		}
+		// Columns are qualified by the table's alias, or by its name, so
+		// references like t.id find them in joined rows
+		name := plan.TableName
+		if plan.Alias != "" {
+			name = plan.Alias
+		}
+		return NewSubqueryScanOperator(scan, name, nil), nil
 
 	case optimizer.PhysicalPlanTypeIndexScan:
 		return NewIndexScanOperator(plan.TableName, plan.IndexName, nil), nil
@@ -164,19 +665,22 @@ func (e *Executor) buildOperatorTree(plan *optimizer.PhysicalPlan) (PhysicalOper
 		if len(children) != 1 {
 			return nil, fmt.Errorf("filter operator requires exactly 1 child")
 		}
-		return NewFilterOperator(children[0], nil), nil
+		predicate, _ := plan.FilterExpr.(parser.Expression)
+		return NewFilterOperator(children[0], predicate), nil
 
 	case optimizer.PhysicalPlanTypeNestedLoopJoin:
 		if len(children) != 2 {
 			return nil, fmt.Errorf("nested loop join requires exactly 2 children")
 		}
-		return NewNestedLoopJoinOperator(children[0], children[1], nil, plan.JoinType), nil
+		condition, _ := plan.JoinCond.(parser.Expression)
+		return NewNestedLoopJoinOperator(children[0], children[1], condition, plan.JoinType), nil
 
 	case optimizer.PhysicalPlanTypeHashJoin:
 		if len(children) != 2 {
 			return nil, fmt.Errorf("hash join requires exactly 2 children")
 		}
-		return NewHashJoinOperator(children[0], children[1], nil, plan.JoinType), nil
+		condition, _ := plan.JoinCond.(parser.Expression)
+		return NewHashJoinOperator(children[0], children[1], condition, plan.JoinKeys, plan.JoinType), nil
 
 	case optimizer.PhysicalPlanTypeMergeJoin:
 		if len(children) != 2 {
@@ -188,38 +692,210 @@ func (e *Executor) buildOperatorTree(plan *optimizer.PhysicalPlan) (PhysicalOper
 		if len(children) != 1 {
 			return nil, fmt.Errorf("hash aggregate requires exactly 1 child")
 		}
-		return NewHashAggregateOperator(children[0], nil, nil), nil
+		return NewHashAggregateOperator(children[0], plan.GroupBy, plan.Aggregates), nil
 
 	case optimizer.PhysicalPlanTypeSortAggregate:
 		if len(children) != 1 {
 			return nil, fmt.Errorf("sort aggregate requires exactly 1 child")
 		}
-		return NewSortAggregateOperator(children[0], nil, nil), nil
+		return NewSortAggregateOperator(children[0], plan.GroupBy, plan.Aggregates), nil
 
 	case optimizer.PhysicalPlanTypeSort:
 		if len(children) != 1 {
 			return nil, fmt.Errorf("sort requires exactly 1 child")
 		}
-		return NewSortOperator(children[0], nil), nil
+		return NewSortOperator(children[0], plan.SortKeys), nil
+
+	case optimizer.PhysicalPlanTypeWindow:
+		if len(children) != 1 {
+			return nil, fmt.Errorf("window requires exactly 1 child")
+		}
+		return NewWindowOperator(children[0], plan.WindowFuncs), nil
 
 	case optimizer.PhysicalPlanTypeProject:
 		if len(children) != 1 {
 			return nil, fmt.Errorf("project requires exactly 1 child")
 		}
-		return NewProjectOperator(children[0], nil), nil
+		return NewProjectOperator(children[0], plan.Projections), nil
 
 	case optimizer.PhysicalPlanTypeLimit:
 		if len(children) != 1 {
 			return nil, fmt.Errorf("limit requires exactly 1 child")
 		}
-		// TODO: Extract limit and offset from plan
-		return NewLimitOperator(children[0], 0, 0), nil
+		if plan.Limit == nil {
+			return nil, fmt.Errorf("limit requires a LIMIT clause")
+		}
+		return NewLimitClauseOperator(children[0], plan.Limit), nil
+
+	case optimizer.PhysicalPlanTypeSubqueryScan:
+		if len(children) != 1 {
+			return nil, fmt.Errorf("subquery scan requires exactly 1 child")
+		}
+		return NewSubqueryScanOperator(children[0], plan.TableName, plan.Columns), nil
+
+	case optimizer.PhysicalPlanTypeCTEScan:
+		return NewCTEScanOperator(plan.CTE, plan.TableName, plan.Columns), nil
+
+	case optimizer.PhysicalPlanTypeWorkTableScan:
+		return NewWorkTableScanOperator(plan.CTE, plan.TableName, plan.Columns), nil
+
+	case optimizer.PhysicalPlanTypeRecursiveUnion:
+		if len(children) != 2 {
+			return nil, fmt.Errorf("recursive union requires exactly 2 children")
+		}
+		return NewRecursiveUnionOperator(children[0], children[1], plan.CTE, plan.UnionAll), nil
+
+	case optimizer.PhysicalPlanTypeAppend:
+		return NewAppendOperator(children), nil
+
+	case optimizer.PhysicalPlanTypeSingleRow:
+		return NewSingleRowOperator(), nil
+
+	case optimizer.PhysicalPlanTypeHashSetOp:
+		if len(children) != 2 {
+			return nil, fmt.Errorf("hash set operation requires exactly 2 children")
+		}
+		return NewHashSetOperator(children[0], children[1], plan.SetOperator, plan.UnionAll), nil
+
+	case optimizer.PhysicalPlanTypeSortSetOp:
+		if len(children) != 2 {
+			return nil, fmt.Errorf("sort set operation requires exactly 2 children")
+		}
+		return NewSortSetOperator(children[0], children[1], plan.SetOperator, plan.UnionAll), nil
 
 	default:
 		return nil, fmt.Errorf("unsupported physical plan type: %v", plan.Type)
 	}
 }
 
+// runPlan executes a plan apart from the main query, such as a subquery or
+// CTE, and returns up to limit of its rows (all rows if limit is 0)
+func (e *Executor) runPlan(ctx *ExecutionContext, plan *optimizer.PhysicalPlan, limit int) (*ResultSet, error) {
+	root, err := e.buildOperatorTree(plan)
+	if err != nil {
+		return nil, fmt.Errorf("failed to build operator tree: %w", err)
+	}
+	if err := root.Open(ctx); err != nil {
+		return nil, fmt.Errorf("failed to open operator: %w", err)
+	}
+	defer root.Close()
+
+	result := NewResultSet()
+	for limit == 0 || result.RowCount() < limit {
+		if ctx.IsTimedOut() {
+			return nil, ErrExecutionTimeout
+		}
+
+		tuple, err := root.Next()
+		if err != nil {
+			return nil, err
+		}
+		if tuple == nil {
+			break
+		}
+		result.AddTuple(tuple)
+	}
+	return result, nil
+}
+
+// buildTableScan creates the scan operator reading one table or partition
+func (e *Executor) buildTableScan(plan *optimizer.PhysicalPlan, tableName string) (PhysicalOperator, error) {
+	if view, found := e.materializedView(tableName); found {
+		return NewMaterializedViewScanOperator(tableName, view), nil
+	}
+
+	index, err := e.GetClusteredIndex(tableName)
+	if err != nil {
+		return nil, err
+	}
+
+	if plan.Type != optimizer.PhysicalPlanTypeClusteredIndexScan {
+		scan := NewSeqScanOperator(tableName, nil)
+		scan.rows = index
+		return scan, nil
+	}
+
+	lower, upper := keyBoundsFromRange(plan.KeyRange)
+	return NewClusteredIndexScanOperator(index, lower, upper), nil
+}
+
+// buildPartitionScan scans the partitions left after pruning one after another
+func (e *Executor) buildPartitionScan(plan *optimizer.PhysicalPlan) (PhysicalOperator, error) {
+	scans := make([]PhysicalOperator, 0, len(plan.Partitions))
+	for _, partition := range plan.Partitions {
+		scan, err := e.buildTableScan(plan, partition)
+		if err != nil {
+			return nil, err
+		}
+		scans = append(scans, scan)
+	}
+	return NewAppendOperator(scans), nil
+}
+
+// InsertRow stores a row of a table. Rows of a partitioned table are routed
+// to their partition; the table the row was stored in is returned.
+func (e *Executor) InsertRow(catalog *CatalogManager, tableName string, values []interface{}) (string, error) {
+	target, err := catalog.RouteRow(tableName, values)
+	if err != nil {
+		return "", err
+	}
+
+	e.clusteredMutex.RLock()
+	defer e.clusteredMutex.RUnlock()
+
+	index, exists := e.clusteredIndexes[target]
+	if !exists {
+		return "", fmt.Errorf("table %s does not store rows", target)
+	}
+
+	lock := e.tableLock(tableName)
+	lock.Lock()
+	defer lock.Unlock()
+
+	// Unique indexes are checked before the row is stored
+	secondary := e.tableIndexes(tableName)
+	keys := make([][]interface{}, len(secondary))
+	for i, si := range secondary {
+		key, err := si.ExtractKey(index.Schema(), values)
+		if err != nil {
+			return "", err
+		}
+		if err := si.Check(key); err != nil {
+			return "", err
+		}
+		keys[i] = key
+	}
+
+	if err := index.Insert(values); err != nil {
+		return "", err
+	}
+	rowKey := index.extractKey(values)
+	for i, si := range secondary {
+		if err := si.Insert(keys[i], rowKey); err != nil {
+			// Take back what was stored so the row is not left half indexed
+			for j := 0; j < i; j++ {
+				secondary[j].Delete(keys[j], rowKey)
+			}
+			index.Delete(rowKey)
+			return "", err
+		}
+	}
+	return target, nil
+}
+
+// tableLock returns the lock serializing changes to the rows of a table
+func (e *Executor) tableLock(tableName string) *sync.Mutex {
+	e.tableLockMutex.Lock()
+	defer e.tableLockMutex.Unlock()
+
+	lock, exists := e.tableLocks[tableName]
+	if !exists {
+		lock = &sync.Mutex{}
+		e.tableLocks[tableName] = lock
+	}
+	return lock
+}
+
 // ExecutionStatistics tracks execution metrics
 type ExecutionStatistics struct {
 	QueriesExecuted    int64
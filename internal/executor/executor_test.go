@@ -2,8 +2,15 @@ package executor
 
 import (
 	"context"
+	"errors"
+	"fmt"
+	"sort"
 	"testing"
 	"time"
+
+	"relational-db/internal/lexer"
+	"relational-db/internal/optimizer"
+	"relational-db/internal/parser"
 )
 
 // TestNewExecutor tests creating an executor
@@ -287,6 +294,34 @@ func TestFilterOperator(t *testing.T) {
 }
 
 // TestProjectOperator tests project operator
+func TestBindParameters(t *testing.T) {
+	bound, err := BindParameters(3, []interface{}{7, uint8(2), float32(1.5)})
+	if err != nil {
+		t.Fatalf("BindParameters failed: %v", err)
+	}
+	if bound[0] != int64(7) || bound[1] != int64(2) || bound[2] != float64(1.5) {
+		t.Errorf("Unexpected bound values: %#v", bound)
+	}
+
+	if _, err := BindParameters(2, []interface{}{1}); err == nil {
+		t.Error("Expected error for missing parameter")
+	}
+	if _, err := BindParameters(1, []interface{}{struct{}{}}); err == nil {
+		t.Error("Expected error for unsupported parameter type")
+	}
+
+	// Parameters evaluate to the values bound to them
+	evaluator := NewExpressionEvaluator()
+	evaluator.SetParameters(bound)
+	value, err := evaluator.Evaluate(&parser.Parameter{Index: 3, Numbered: true}, nil)
+	if err != nil || value != float64(1.5) {
+		t.Errorf("Expected 1.5, got %v (%v)", value, err)
+	}
+	if _, err := evaluator.Evaluate(&parser.Parameter{Index: 4}, nil); !errors.Is(err, ErrMissingParameter) {
+		t.Errorf("Expected ErrMissingParameter, got %v", err)
+	}
+}
+
 func TestProjectOperator(t *testing.T) {
 	scan := NewSeqScanOperator("users", nil)
 	project := NewProjectOperator(scan, nil)
@@ -294,6 +329,46 @@ func TestProjectOperator(t *testing.T) {
 	if project.OperatorType() != "Project" {
 		t.Errorf("Expected operator type 'Project', got %s", project.OperatorType())
 	}
+
+	schema := NewTupleSchema([]ColumnInfo{
+		{Name: "id", TableName: "users", Type: TypeBigInt},
+		{Name: "name", TableName: "users", Type: TypeString, Nullable: true},
+		{Name: "total", TableName: "orders", Type: TypeDouble},
+	})
+	input := &valuesOperator{rows: []*Tuple{NewTuple(schema, []interface{}{int64(1), "ann", 9.5})}}
+	project = NewProjectOperator(input, []parser.Expression{
+		&parser.Identifier{Value: "name", Alias: &parser.Identifier{Value: "who"}},
+		&parser.BinaryExpression{Left: &parser.Identifier{Value: "id"}, Operator: parser.Plus, Right: &parser.Literal{Value: "1", Type: lexer.NUMBER}},
+		&parser.Wildcard{Table: &parser.Identifier{Value: "orders"}},
+		&parser.Wildcard{},
+	})
+
+	ctx := NewExecutionContext(context.Background(), DefaultExecutorConfig())
+	if err := project.Open(ctx); err != nil {
+		t.Fatalf("Open failed: %v", err)
+	}
+	defer project.Close()
+
+	tuple, err := project.Next()
+	if err != nil || tuple == nil {
+		t.Fatalf("Expected a projected row, got %v (%v)", tuple, err)
+	}
+	if fmt.Sprint(tuple.Values) != "[ann 2 9.5 1 ann 9.5]" {
+		t.Errorf("Expected [ann 2 9.5 1 ann 9.5], got %v", tuple.Values)
+	}
+	var names []string
+	for _, col := range tuple.Schema.Columns {
+		names = append(names, col.Name)
+	}
+	if fmt.Sprint(names) != "[who column2 total id name total]" {
+		t.Errorf("Expected columns [who column2 total id name total], got %v", names)
+	}
+	if tuple.Schema.Columns[0].Type != TypeString {
+		t.Errorf("Expected a selected column to keep its type, got %s", tuple.Schema.Columns[0].Type)
+	}
+	if tuple, _ := project.Next(); tuple != nil {
+		t.Errorf("Expected EOF, got %v", tuple)
+	}
 }
 
 // TestLimitOperator tests limit operator
@@ -326,7 +401,7 @@ func TestJoinOperators(t *testing.T) {
 	}
 
 	// Test hash join
-	hj := NewHashJoinOperator(left, right, nil, 0)
+	hj := NewHashJoinOperator(left, right, nil, nil, 0)
 	if hj.OperatorType() != "HashJoin" {
 		t.Errorf("Expected 'HashJoin', got %s", hj.OperatorType())
 	}
@@ -382,3 +457,754 @@ func TestAggregateState(t *testing.T) {
 		t.Errorf("Expected COUNT=3, got %v", count)
 	}
 }
+
+// TestClusteredIndex tests ordered storage and range scans of a clustered table
+func TestClusteredIndex(t *testing.T) {
+	schema := &TableSchema{
+		TableName: "events",
+		Columns: []ColumnInfo{
+			{Name: "id", Type: TypeBigInt},
+			{Name: "payload", Type: TypeString, Nullable: true},
+		},
+		PrimaryKey: []string{"id"},
+		Clustered:  true,
+	}
+
+	index, err := NewClusteredIndex(schema)
+	if err != nil {
+		t.Fatalf("Failed to create clustered index: %v", err)
+	}
+	index.order = 4 // Force several levels of splits
+
+	// Insert keys out of order
+	for i := 0; i < 100; i++ {
+		id := int64((i * 37) % 100)
+		if err := index.Insert([]interface{}{id, "row"}); err != nil {
+			t.Fatalf("Insert %d failed: %v", id, err)
+		}
+	}
+
+	if index.Len() != 100 {
+		t.Errorf("Expected 100 rows, got %d", index.Len())
+	}
+
+	if err := index.Insert([]interface{}{int64(42), "dup"}); err == nil {
+		t.Error("Expected duplicate primary key error")
+	}
+
+	// Full scan returns rows in key order
+	it := index.Scan(nil, nil)
+	for want := int64(0); want < 100; want++ {
+		row := it.Next()
+		if row == nil {
+			t.Fatalf("Scan ended early at %d", want)
+		}
+		if row.Values[0] != want {
+			t.Fatalf("Expected key %d, got %v", want, row.Values[0])
+		}
+	}
+	if it.Next() != nil {
+		t.Error("Expected scan to be exhausted")
+	}
+
+	// Range scan (10, 20]
+	it = index.Scan(
+		&KeyBound{Key: []interface{}{int64(10)}, Inclusive: false},
+		&KeyBound{Key: []interface{}{int64(20)}, Inclusive: true},
+	)
+	var keys []int64
+	for row := it.Next(); row != nil; row = it.Next() {
+		keys = append(keys, row.Values[0].(int64))
+	}
+	if len(keys) != 10 || keys[0] != 11 || keys[9] != 20 {
+		t.Errorf("Expected keys 11..20, got %v", keys)
+	}
+
+	// Point lookup and delete
+	if row := index.Get([]interface{}{int64(57)}); row == nil {
+		t.Error("Expected to find key 57")
+	}
+	if !index.Delete([]interface{}{int64(57)}) {
+		t.Error("Expected delete of key 57 to succeed")
+	}
+	if row := index.Get([]interface{}{int64(57)}); row != nil {
+		t.Error("Expected key 57 to be gone")
+	}
+
+	// A scan reads the rows present when it started
+	it = index.Scan(nil, nil)
+	for i := int64(0); i < 100; i++ {
+		index.Delete([]interface{}{i})
+	}
+	index.Insert([]interface{}{int64(500), "late"})
+	count := 0
+	for row := it.Next(); row != nil; row = it.Next() {
+		if row.Values[0] == int64(500) {
+			t.Error("Expected a row inserted during the scan to be skipped")
+		}
+		count++
+	}
+	if count != 99 {
+		t.Errorf("Expected the scan to see 99 rows, got %d", count)
+	}
+}
+
+// TestHeapIndex tests row storage for tables without a primary key
+func TestHeapIndex(t *testing.T) {
+	schema := &TableSchema{
+		TableName: "notes",
+		Columns:   []ColumnInfo{{Name: "body", Type: TypeString, Nullable: true}},
+	}
+
+	index, err := newRowIndex(schema)
+	if err != nil {
+		t.Fatalf("Failed to create row index: %v", err)
+	}
+	if !index.heap {
+		t.Fatal("Expected a table without a primary key to be stored in a heap")
+	}
+	for _, body := range []interface{}{"x", "x", nil} {
+		if err := index.Insert([]interface{}{body}); err != nil {
+			t.Fatalf("Insert %v failed: %v", body, err)
+		}
+	}
+	if index.Len() != 3 {
+		t.Errorf("Expected 3 rows, got %d", index.Len())
+	}
+
+	// Equal rows are kept apart and deleted one at a time
+	rows := index.rowValues()
+	if len(rows) != 3 || rows[0][0] != nil || rows[1][0] != "x" || rows[2][0] != "x" {
+		t.Fatalf("Expected rows ordered by value, got %v", rows)
+	}
+	if !index.Delete([]interface{}{"x"}) {
+		t.Fatal("Expected delete of an x to succeed")
+	}
+	if rows := index.rowValues(); len(rows) != 2 || rows[1][0] != "x" {
+		t.Errorf("Expected one x to remain, got %v", rows)
+	}
+}
+
+// TestClusteredIndexScanOperator tests the clustered index scan operator
+func TestClusteredIndexScanOperator(t *testing.T) {
+	schema := &TableSchema{
+		TableName:  "events",
+		Columns:    []ColumnInfo{{Name: "id", Type: TypeBigInt}},
+		PrimaryKey: []string{"id"},
+		Clustered:  true,
+	}
+
+	index, err := NewClusteredIndex(schema)
+	if err != nil {
+		t.Fatalf("Failed to create clustered index: %v", err)
+	}
+	for _, id := range []int64{3, 1, 2} {
+		index.Insert([]interface{}{id})
+	}
+
+	scan := NewClusteredIndexScanOperator(index, nil, nil)
+	if scan.OperatorType() != "ClusteredIndexScan" {
+		t.Errorf("Expected operator type 'ClusteredIndexScan', got %s", scan.OperatorType())
+	}
+
+	ctx := NewExecutionContext(context.Background(), DefaultExecutorConfig())
+	if err := scan.Open(ctx); err != nil {
+		t.Fatalf("Open failed: %v", err)
+	}
+	defer scan.Close()
+
+	for want := int64(1); want <= 3; want++ {
+		tuple, err := scan.Next()
+		if err != nil || tuple == nil {
+			t.Fatalf("Expected tuple %d, got %v (err %v)", want, tuple, err)
+		}
+		if tuple.Values[0] != want {
+			t.Errorf("Expected key %d, got %v", want, tuple.Values[0])
+		}
+	}
+}
+
+func TestTTLWorker(t *testing.T) {
+	schema := &TableSchema{
+		TableName: "sessions",
+		Columns: []ColumnInfo{
+			{Name: "id", Type: TypeBigInt},
+			{Name: "created_at", Type: TypeTimestamp},
+		},
+		PrimaryKey: []string{"id"},
+		Clustered:  true,
+		TTL:        &TTLPolicy{Column: "created_at", Duration: 24 * time.Hour},
+	}
+
+	sm := NewSchemaManager()
+	if err := sm.RegisterSchema(schema); err != nil {
+		t.Fatalf("Failed to register schema: %v", err)
+	}
+
+	index, err := NewClusteredIndex(schema)
+	if err != nil {
+		t.Fatalf("Failed to create clustered index: %v", err)
+	}
+
+	now := time.Date(2024, 6, 1, 12, 0, 0, 0, time.UTC)
+	for i := int64(0); i < 10; i++ {
+		// Even rows are two days old, odd rows one hour old
+		created := now.Add(-time.Hour)
+		if i%2 == 0 {
+			created = now.Add(-48 * time.Hour)
+		}
+		index.Insert([]interface{}{i, created})
+	}
+
+	exec := NewExecutor(nil, nil)
+	exec.RegisterClusteredIndex(index)
+	txnExecutor := NewTransactionExecutor(exec, NewLockManager())
+
+	worker := NewTTLWorker(NewCatalogManager(sm), txnExecutor, time.Hour, 2)
+	worker.now = func() time.Time { return now }
+
+	removed, err := worker.RunOnce()
+	if err != nil {
+		t.Fatalf("RunOnce failed: %v", err)
+	}
+	if removed != 5 {
+		t.Errorf("Expected 5 expired rows, got %d", removed)
+	}
+	if index.Len() != 5 {
+		t.Errorf("Expected 5 remaining rows, got %d", index.Len())
+	}
+	if index.Get([]interface{}{int64(0)}) != nil {
+		t.Error("Expected expired row 0 to be deleted")
+	}
+	if index.Get([]interface{}{int64(1)}) == nil {
+		t.Error("Expected live row 1 to remain")
+	}
+
+	if stats := worker.Stats(); stats["sessions"] != 5 {
+		t.Errorf("Expected 5 expired rows in stats, got %d", stats["sessions"])
+	}
+
+	if len(txnExecutor.ListActiveTransactions()) != 0 {
+		t.Error("Expected all expiry transactions to be finished")
+	}
+}
+
+func TestSubqueryEvaluation(t *testing.T) {
+	schema := NewTupleSchema([]ColumnInfo{{Name: "user_id", TableName: "orders", Type: TypeInt}})
+	rows := func(values ...interface{}) *ResultSet {
+		result := NewResultSet()
+		for _, value := range values {
+			result.AddTuple(NewTuple(schema, []interface{}{value}))
+		}
+		return result
+	}
+
+	// Uncorrelated subqueries are served from the runner's cache
+	one := &parser.SelectStatement{}
+	many := &parser.SelectStatement{}
+	none := &parser.SelectStatement{}
+	withNull := &parser.SelectStatement{}
+	runner := newSubqueryRunner(nil, map[*parser.SelectStatement]*optimizer.SubqueryPlan{
+		one: {}, many: {}, none: {}, withNull: {},
+	})
+	runner.results[one] = rows(int64(7))
+	runner.results[many] = rows(int64(1), int64(2))
+	runner.results[none] = rows()
+	runner.results[withNull] = rows(int64(1), nil)
+
+	execCtx := NewExecutionContext(context.Background(), DefaultExecutorConfig())
+	execCtx.subqueries = runner
+	evaluator := NewExpressionEvaluator()
+	evaluator.Bind(execCtx)
+
+	if value, err := evaluator.Evaluate(&parser.SubqueryExpression{Query: one}, nil); err != nil || value != int64(7) {
+		t.Errorf("Expected scalar subquery to return 7, got %v (%v)", value, err)
+	}
+	if value, err := evaluator.Evaluate(&parser.SubqueryExpression{Query: none}, nil); err != nil || value != nil {
+		t.Errorf("Expected empty scalar subquery to return NULL, got %v (%v)", value, err)
+	}
+	if _, err := evaluator.Evaluate(&parser.SubqueryExpression{Query: many}, nil); !errors.Is(err, ErrSubqueryMultipleRows) {
+		t.Errorf("Expected ErrSubqueryMultipleRows, got %v", err)
+	}
+
+	if value, _ := evaluator.Evaluate(&parser.ExistsExpression{Query: many}, nil); value != true {
+		t.Errorf("Expected EXISTS to be true, got %v", value)
+	}
+	if value, _ := evaluator.Evaluate(&parser.ExistsExpression{Query: none}, nil); value != false {
+		t.Errorf("Expected EXISTS over no rows to be false, got %v", value)
+	}
+
+	in := func(value interface{}, query *parser.SelectStatement) interface{} {
+		result, err := evaluator.Evaluate(&parser.BinaryExpression{
+			Left:     &parser.Literal{Value: value},
+			Operator: parser.In,
+			Right:    &parser.SubqueryExpression{Query: query},
+		}, nil)
+		if err != nil {
+			t.Fatalf("IN subquery failed: %v", err)
+		}
+		return result
+	}
+	if got := in(int64(2), many); got != true {
+		t.Errorf("Expected 2 IN (1, 2) to be true, got %v", got)
+	}
+	if got := in(int64(3), many); got != false {
+		t.Errorf("Expected 3 IN (1, 2) to be false, got %v", got)
+	}
+	if got := in(int64(3), withNull); got != nil {
+		t.Errorf("Expected 3 IN (1, NULL) to be NULL, got %v", got)
+	}
+	if got := in(nil, none); got != false {
+		t.Errorf("Expected NULL IN () to be false, got %v", got)
+	}
+
+	// Correlated subqueries see the enclosing query's row
+	outer := NewTuple(NewTupleSchema([]ColumnInfo{{Name: "id", TableName: "users", Type: TypeInt}}), []interface{}{int64(42)})
+	evaluator.Bind(execCtx.withOuterRow(outer))
+	if value, err := evaluator.Evaluate(&parser.ColumnReference{Table: &parser.Identifier{Value: "users"}, Column: &parser.Identifier{Value: "id"}}, nil); err != nil || value != int64(42) {
+		t.Errorf("Expected outer column users.id = 42, got %v (%v)", value, err)
+	}
+}
+
+// valuesOperator returns fixed rows, for operator tests
+type valuesOperator struct {
+	rows []*Tuple
+	pos  int
+}
+
+func (op *valuesOperator) Open(ctx *ExecutionContext) error { op.pos = 0; return nil }
+func (op *valuesOperator) Close() error                     { return nil }
+func (op *valuesOperator) OperatorType() string             { return "Values" }
+func (op *valuesOperator) EstimatedCost() float64           { return 0 }
+func (op *valuesOperator) Next() (*Tuple, error) {
+	if op.pos >= len(op.rows) {
+		return nil, nil
+	}
+	op.pos++
+	return op.rows[op.pos-1], nil
+}
+
+// mapOperator replaces each child row by the rows fn returns for it
+type mapOperator struct {
+	child   PhysicalOperator
+	fn      func(*Tuple) []*Tuple
+	pending []*Tuple
+}
+
+func (op *mapOperator) Open(ctx *ExecutionContext) error { op.pending = nil; return op.child.Open(ctx) }
+func (op *mapOperator) Close() error                     { return op.child.Close() }
+func (op *mapOperator) OperatorType() string             { return "Map" }
+func (op *mapOperator) EstimatedCost() float64           { return 0 }
+func (op *mapOperator) Next() (*Tuple, error) {
+	for len(op.pending) == 0 {
+		tuple, err := op.child.Next()
+		if tuple == nil || err != nil {
+			return nil, err
+		}
+		op.pending = op.fn(tuple)
+	}
+	tuple := op.pending[0]
+	op.pending = op.pending[1:]
+	return tuple, nil
+}
+
+func TestRecursiveUnionOperator(t *testing.T) {
+	schema := NewTupleSchema([]ColumnInfo{{Name: "n", Type: TypeInt}})
+	row := func(n int64) *Tuple { return NewTuple(schema, []interface{}{n}) }
+	cte := &parser.CommonTableExpression{Name: &parser.Identifier{Value: "walk"}}
+
+	run := func(unionAll bool, step func(int64) []int64, maxDepth int) ([]int64, error) {
+		recursive := &mapOperator{
+			child: NewWorkTableScanOperator(cte, "walk", nil),
+			fn: func(tuple *Tuple) []*Tuple {
+				var rows []*Tuple
+				for _, n := range step(tuple.Values[0].(int64)) {
+					rows = append(rows, row(n))
+				}
+				return rows
+			},
+		}
+		op := NewRecursiveUnionOperator(&valuesOperator{rows: []*Tuple{row(1)}}, recursive, cte, unionAll)
+
+		config := DefaultExecutorConfig()
+		config.MaxRecursionDepth = maxDepth
+		ctx := NewExecutionContext(context.Background(), config)
+		ctx.ctes = newCTERunner(nil, nil)
+		if err := op.Open(ctx); err != nil {
+			return nil, err
+		}
+		defer op.Close()
+
+		var values []int64
+		for {
+			tuple, err := op.Next()
+			if err != nil {
+				return values, err
+			}
+			if tuple == nil {
+				return values, nil
+			}
+			values = append(values, tuple.Values[0].(int64))
+		}
+	}
+
+	// Counting stops when an iteration produces no rows
+	values, err := run(true, func(n int64) []int64 {
+		if n < 5 {
+			return []int64{n + 1}
+		}
+		return nil
+	}, 100)
+	if err != nil || len(values) != 5 || values[4] != 5 {
+		t.Errorf("Expected 1..5, got %v (%v)", values, err)
+	}
+
+	// UNION drops rows already seen, ending recursion over a cycle 1 -> 2 -> 3 -> 1
+	cycle := func(n int64) []int64 { return []int64{n%3 + 1} }
+	values, err = run(false, cycle, 100)
+	if err != nil || len(values) != 3 {
+		t.Errorf("Expected 1, 2, 3, got %v (%v)", values, err)
+	}
+
+	// UNION ALL over the same cycle hits the recursion limit
+	if _, err := run(true, cycle, 10); !errors.Is(err, ErrRecursionLimit) {
+		t.Errorf("Expected ErrRecursionLimit, got %v", err)
+	}
+}
+
+func TestSetOperators(t *testing.T) {
+	schema := NewTupleSchema([]ColumnInfo{{Name: "n", Type: TypeInt}})
+	values := func(ns ...int64) *valuesOperator {
+		rows := make([]*Tuple, len(ns))
+		for i, n := range ns {
+			rows[i] = NewTuple(schema, []interface{}{n})
+		}
+		return &valuesOperator{rows: rows}
+	}
+	collect := func(op PhysicalOperator) []int64 {
+		ctx := NewExecutionContext(context.Background(), DefaultExecutorConfig())
+		if err := op.Open(ctx); err != nil {
+			t.Fatalf("open failed: %v", err)
+		}
+		defer op.Close()
+
+		var result []int64
+		for {
+			tuple, err := op.Next()
+			if err != nil {
+				t.Fatalf("next failed: %v", err)
+			}
+			if tuple == nil {
+				sort.Slice(result, func(i, j int) bool { return result[i] < result[j] })
+				return result
+			}
+			result = append(result, tuple.Values[0].(int64))
+		}
+	}
+
+	// Left rows 1 1 2 3, right rows 1 3 3 4
+	tests := []struct {
+		operator parser.SetOperator
+		all      bool
+		expected string
+	}{
+		{parser.Union, false, "[1 2 3 4]"},
+		{parser.Union, true, "[1 1 1 2 3 3 3 4]"},
+		{parser.Intersect, false, "[1 3]"},
+		{parser.Intersect, true, "[1 3]"},
+		{parser.Except, false, "[2]"},
+		{parser.Except, true, "[1 2]"},
+	}
+	for _, tt := range tests {
+		hash := collect(NewHashSetOperator(values(1, 1, 2, 3), values(1, 3, 3, 4), tt.operator, tt.all))
+		if got := fmt.Sprint(hash); got != tt.expected {
+			t.Errorf("hash %s all=%v: expected %s, got %s", tt.operator, tt.all, tt.expected, got)
+		}
+		sorted := collect(NewSortSetOperator(values(3, 1, 2, 1), values(4, 3, 1, 3), tt.operator, tt.all))
+		if got := fmt.Sprint(sorted); got != tt.expected {
+			t.Errorf("sort %s all=%v: expected %s, got %s", tt.operator, tt.all, tt.expected, got)
+		}
+	}
+
+	// The sort-based operator returns rows in order without being sorted
+	op := NewSortSetOperator(values(3, 2), values(1), parser.Union, false)
+	ctx := NewExecutionContext(context.Background(), DefaultExecutorConfig())
+	op.Open(ctx)
+	var order []int64
+	for tuple, _ := op.Next(); tuple != nil; tuple, _ = op.Next() {
+		order = append(order, tuple.Values[0].(int64))
+	}
+	op.Close()
+	if fmt.Sprint(order) != "[1 2 3]" {
+		t.Errorf("Expected sorted output [1 2 3], got %v", order)
+	}
+
+	// Integers and floats with the same value are the same row
+	mixed := func(vs ...interface{}) *valuesOperator {
+		rows := make([]*Tuple, len(vs))
+		for i, v := range vs {
+			rows[i] = NewTuple(schema, []interface{}{v})
+		}
+		return &valuesOperator{rows: rows}
+	}
+	for _, tt := range []struct {
+		operator parser.SetOperator
+		expected int
+	}{
+		{parser.Union, 3},
+		{parser.Intersect, 1},
+		{parser.Except, 1},
+	} {
+		hash := NewHashSetOperator(mixed(int64(1), int64(2)), mixed(1.0, 3.5), tt.operator, false)
+		hash.Open(ctx)
+		count := 0
+		for tuple, _ := hash.Next(); tuple != nil; tuple, _ = hash.Next() {
+			count++
+		}
+		hash.Close()
+		if count != tt.expected {
+			t.Errorf("hash %s of 1, 2 and 1.0, 3.5: expected %d rows, got %d", tt.operator, tt.expected, count)
+		}
+	}
+}
+
+func TestConditionalExpressionEvaluation(t *testing.T) {
+	schema := NewTupleSchema([]ColumnInfo{
+		{Name: "a", TableName: "t", Type: TypeInt, Nullable: true},
+		{Name: "b", TableName: "t", Type: TypeInt},
+		{Name: "s", TableName: "t", Type: TypeString},
+	})
+	tuple := NewTuple(schema, []interface{}{nil, int64(2), "12"})
+	evaluator := NewExpressionEvaluator()
+
+	tests := []struct {
+		expr     string
+		expected interface{}
+	}{
+		// Three-valued logic
+		{"a = 1", nil},
+		{"a = 1 AND b = 3", false},
+		{"a = 1 AND b = 2", nil},
+		{"a = 1 OR b = 2", true},
+		{"a = 1 OR b = 3", nil},
+		{"NOT (a = 1)", nil},
+		{"NOT (b = 3)", true},
+		{"a IS NULL", true},
+		{"b IS NOT NULL", true},
+		{"(a = 1) IS NULL", true},
+		{"b * 3 + 1", int64(7)},
+		{"b / 4.0", 0.5},
+		{"a + 1", nil},
+
+		// CASE takes the first branch whose condition is true
+		{"CASE WHEN a = 1 THEN 'x' WHEN b = 2 THEN 'y' ELSE 'z' END", "y"},
+		{"CASE WHEN a = 1 THEN 'x' END", nil},
+		{"CASE b WHEN 1 THEN 'one' WHEN 2 THEN 'two' END", "two"},
+		{"CASE a WHEN NULL THEN 'null' ELSE 'other' END", "other"},
+		// Branches not taken are not evaluated
+		{"CASE WHEN b = 2 THEN 1 ELSE 1 / 0 END", int64(1)},
+
+		{"COALESCE(a, b, 1 / 0)", int64(2)},
+		{"COALESCE(a, NULL)", nil},
+		{"NULLIF(b, 2)", nil},
+		{"NULLIF(b, 3)", int64(2)},
+		{"NULLIF(a, 3)", nil},
+
+		{"CAST(s AS INTEGER) + b", int64(14)},
+		{"CAST(b AS REAL)", float64(2)},
+		{"CAST(b AS VARCHAR(5))", "2"},
+		{"CAST(b AS BOOLEAN)", true},
+		{"CAST(a AS INTEGER)", nil},
+	}
+
+	for _, tt := range tests {
+		stmt, err := parser.ParseSQL("SELECT " + tt.expr + " FROM t")
+		if err != nil {
+			t.Fatalf("%s: parse failed: %v", tt.expr, err)
+		}
+		expr := stmt.(*parser.SelectStatement).SelectClause.Columns[0]
+		value, err := evaluator.Evaluate(expr, tuple)
+		if err != nil {
+			t.Errorf("%s: unexpected error: %v", tt.expr, err)
+			continue
+		}
+		if value != tt.expected {
+			t.Errorf("%s: expected %v (%T), got %v (%T)", tt.expr, tt.expected, tt.expected, value, value)
+		}
+	}
+
+	failing := map[string]error{
+		"b / 0":                    ErrDivisionByZero,
+		"s + 1":                    ErrTypeMismatch,
+		"CAST(s + 'x' AS INTEGER)": ErrTypeMismatch,
+		"CAST('abc' AS INTEGER)":   ErrTypeMismatch,
+		"CASE WHEN b THEN 1 END":   ErrTypeMismatch,
+		"NOT b":                    ErrTypeMismatch,
+	}
+	for sql, expected := range failing {
+		stmt, _ := parser.ParseSQL("SELECT " + sql + " FROM t")
+		expr := stmt.(*parser.SelectStatement).SelectClause.Columns[0]
+		if _, err := evaluator.Evaluate(expr, tuple); !errors.Is(err, expected) {
+			t.Errorf("%s: expected %v, got %v", sql, expected, err)
+		}
+	}
+}
+
+func TestPredicateEvaluation(t *testing.T) {
+	schema := NewTupleSchema([]ColumnInfo{
+		{Name: "a", TableName: "t", Type: TypeInt, Nullable: true},
+		{Name: "b", TableName: "t", Type: TypeInt},
+		{Name: "s", TableName: "t", Type: TypeString},
+	})
+	tuple := NewTuple(schema, []interface{}{nil, int64(3), "50% off_now"})
+	evaluator := NewExpressionEvaluator()
+
+	tests := []struct {
+		expr     string
+		expected interface{}
+	}{
+		{"b BETWEEN 1 AND 3", true},
+		{"b BETWEEN 4 AND 9", false},
+		{"b NOT BETWEEN 4 AND 9", true},
+		{"a BETWEEN 1 AND 3", nil},
+		// One bound already rules the value out
+		{"b BETWEEN a AND 2", false},
+		{"b BETWEEN a AND 5", nil},
+
+		{"b IN (1, 2, 3)", true},
+		{"b IN (1, 2)", false},
+		{"b IN (1, NULL)", nil},
+		{"b IN (NULL, 3)", true},
+		{"b NOT IN (1, 2)", true},
+		{"b NOT IN (1, NULL)", nil},
+		{"a IN (1)", nil},
+
+		{"s LIKE '50%'", true},
+		{"s LIKE '%OFF%'", false},
+		{"s LIKE '5_!% off!_%' ESCAPE '!'", true},
+		{"s LIKE '50!%' ESCAPE '!'", false},
+		{"s LIKE '%_now'", true},
+		{"s NOT LIKE '%off%'", false},
+		{"s LIKE '%%%'", true},
+		{"a LIKE '%'", nil},
+		{"s LIKE 'x' ESCAPE NULL", nil},
+
+		// Literal forms
+		{"X'CAFE' = X'cafe'", true},
+		{"X'0A' < X'0B00'", true},
+		{"1.5e1 = 15", true},
+		{"'it''s' = E'it\\'s'", true},
+		{"'a\\n' = E'a\\n'", false},
+	}
+
+	for _, tt := range tests {
+		stmt, err := parser.ParseSQL("SELECT a FROM t WHERE " + tt.expr)
+		if err != nil {
+			t.Fatalf("%s: parse failed: %v", tt.expr, err)
+		}
+		value, err := evaluator.Evaluate(stmt.(*parser.SelectStatement).WhereClause.Condition, tuple)
+		if err != nil {
+			t.Errorf("%s: unexpected error: %v", tt.expr, err)
+			continue
+		}
+		if value != tt.expected {
+			t.Errorf("%s: expected %v, got %v", tt.expr, tt.expected, value)
+		}
+	}
+
+	failing := []string{
+		"b LIKE '3'",
+		"s LIKE 'x' ESCAPE '!!'",
+		"s LIKE 'x!' ESCAPE '!'",
+	}
+	for _, sql := range failing {
+		stmt, _ := parser.ParseSQL("SELECT a FROM t WHERE " + sql)
+		if _, err := evaluator.Evaluate(stmt.(*parser.SelectStatement).WhereClause.Condition, tuple); !errors.Is(err, ErrTypeMismatch) {
+			t.Errorf("%s: expected %v, got %v", sql, ErrTypeMismatch, err)
+		}
+	}
+}
+
+func TestWindowOperator(t *testing.T) {
+	schema := NewTupleSchema([]ColumnInfo{
+		{Name: "g", Type: TypeString},
+		{Name: "d", Type: TypeInt},
+		{Name: "x", Type: TypeInt, Nullable: true},
+	})
+	input := [][]interface{}{
+		{"a", int64(2), int64(20)},
+		{"b", int64(1), int64(5)},
+		{"a", int64(1), int64(10)},
+		{"a", int64(4), int64(40)},
+		{"b", int64(3), nil},
+		{"a", int64(2), int64(30)},
+	}
+
+	tests := []struct {
+		function string
+		expected string
+	}{
+		{"ROW_NUMBER() OVER (PARTITION BY g ORDER BY d)", "[2 1 1 4 2 3]"},
+		{"RANK() OVER (PARTITION BY g ORDER BY d)", "[2 1 1 4 2 2]"},
+		{"DENSE_RANK() OVER (PARTITION BY g ORDER BY d)", "[2 1 1 3 2 2]"},
+		{"LAG(x) OVER (PARTITION BY g ORDER BY d)", "[10 <nil> <nil> 30 5 20]"},
+		{"LEAD(x, 1, 0) OVER (PARTITION BY g ORDER BY d)", "[30 <nil> 20 0 0 40]"},
+		{"FIRST_VALUE(x) OVER (PARTITION BY g ORDER BY d DESC)", "[40 <nil> 40 40 <nil> 40]"},
+		{"SUM(x) OVER (PARTITION BY g ORDER BY d)", "[60 5 10 100 5 60]"},
+		{"AVG(x) OVER (PARTITION BY g)", "[25 5 25 25 5 25]"},
+		{"SUM(x) OVER (PARTITION BY g ORDER BY d ROWS BETWEEN 1 PRECEDING AND CURRENT ROW)", "[30 5 10 70 5 50]"},
+		{"COUNT(*) OVER (ORDER BY d RANGE BETWEEN 1 PRECEDING AND 1 FOLLOWING)", "[5 4 4 2 4 5]"},
+	}
+
+	for _, tt := range tests {
+		stmt, err := parser.ParseSQL("SELECT " + tt.function + " FROM t")
+		if err != nil {
+			t.Fatalf("%s: parse failed: %v", tt.function, err)
+		}
+		call := stmt.(*parser.SelectStatement).SelectClause.Columns[0].(*parser.FunctionCall)
+
+		rows := make([]*Tuple, len(input))
+		for i, values := range input {
+			rows[i] = NewTuple(schema, values)
+		}
+		op := NewWindowOperator(&valuesOperator{rows: rows}, []*parser.FunctionCall{call, call})
+
+		ctx := NewExecutionContext(context.Background(), DefaultExecutorConfig())
+		if err := op.Open(ctx); err != nil {
+			t.Fatalf("%s: open failed: %v", tt.function, err)
+		}
+
+		// Rows keep their input order and gain one column per distinct call
+		evaluator := NewExpressionEvaluator()
+		var result []interface{}
+		for {
+			tuple, err := op.Next()
+			if err != nil {
+				t.Fatalf("%s: next failed: %v", tt.function, err)
+			}
+			if tuple == nil {
+				break
+			}
+			if len(tuple.Values) != 4 {
+				t.Fatalf("%s: expected 4 columns, got %d", tt.function, len(tuple.Values))
+			}
+			value, err := evaluator.Evaluate(call, tuple)
+			if err != nil {
+				t.Fatalf("%s: evaluate failed: %v", tt.function, err)
+			}
+			result = append(result, value)
+		}
+		op.Close()
+
+		if got := fmt.Sprint(result); got != tt.expected {
+			t.Errorf("%s: expected %s, got %s", tt.function, tt.expected, got)
+		}
+	}
+
+	// Window functions cannot be evaluated without the operator
+	stmt, _ := parser.ParseSQL("SELECT ROW_NUMBER() OVER () FROM t")
+	call := stmt.(*parser.SelectStatement).SelectClause.Columns[0]
+	if _, err := NewExpressionEvaluator().Evaluate(call, NewTuple(schema, input[0])); !errors.Is(err, ErrWindowNotComputed) {
+		t.Errorf("Expected ErrWindowNotComputed, got %v", err)
+	}
+}
@@ -0,0 +1,502 @@
+package executor
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+
+	"relational-db/internal/compiler"
+	"relational-db/internal/parser"
+)
+
+// evaluateIsNull evaluates expr IS [NOT] NULL, which is never NULL itself
+func (ee *ExpressionEvaluator) evaluateIsNull(expr *parser.IsNullExpression, tuple *Tuple) (interface{}, error) {
+	value, err := ee.Evaluate(expr.Expr, tuple)
+	if err != nil {
+		return nil, err
+	}
+	return (value == nil) != expr.Not, nil
+}
+
+// evaluateCase evaluates a CASE expression. Only the branch taken is
+// evaluated. A WHEN value never matches a NULL operand, and a WHEN
+// condition that is NULL counts as false; with no match and no ELSE the
+// result is NULL.
+func (ee *ExpressionEvaluator) evaluateCase(expr *parser.CaseExpression, tuple *Tuple) (interface{}, error) {
+	var operand interface{}
+	if expr.Operand != nil {
+		var err error
+		if operand, err = ee.Evaluate(expr.Operand, tuple); err != nil {
+			return nil, err
+		}
+	}
+
+	for _, when := range expr.Whens {
+		value, err := ee.Evaluate(when.Condition, tuple)
+		if err != nil {
+			return nil, err
+		}
+
+		matched := false
+		if expr.Operand != nil {
+			matched = operand != nil && value != nil && compareValues(operand, value) == 0
+		} else {
+			truth, err := truthValue(value)
+			if err != nil {
+				return nil, err
+			}
+			matched = truth != nil && *truth
+		}
+		if matched {
+			return ee.Evaluate(when.Result, tuple)
+		}
+	}
+
+	return ee.Evaluate(expr.Else, tuple)
+}
+
+// evaluateCast evaluates CAST(expr AS type)
+func (ee *ExpressionEvaluator) evaluateCast(expr *parser.CastExpression, tuple *Tuple) (interface{}, error) {
+	value, err := ee.Evaluate(expr.Expr, tuple)
+	if err != nil {
+		return nil, err
+	}
+
+	dataType, ok := compiler.DataTypeFromName(expr.Type.Name)
+	if !ok {
+		return nil, fmt.Errorf("%w: unsupported data type %s", ErrTypeMismatch, expr.Type.Name)
+	}
+	return castValue(value, dataType)
+}
+
+// evaluateCoalesce returns the first of its arguments that is not NULL,
+// evaluating no further arguments
+func (ee *ExpressionEvaluator) evaluateCoalesce(args []parser.Expression, tuple *Tuple) (interface{}, error) {
+	for _, arg := range args {
+		value, err := ee.Evaluate(arg, tuple)
+		if err != nil || value != nil {
+			return value, err
+		}
+	}
+	return nil, nil
+}
+
+// evaluateNullIf returns NULL if its two arguments are equal, and the first
+// argument otherwise
+func (ee *ExpressionEvaluator) evaluateNullIf(args []parser.Expression, tuple *Tuple) (interface{}, error) {
+	if len(args) != 2 {
+		return nil, fmt.Errorf("NULLIF requires 2 arguments, got %d", len(args))
+	}
+	left, err := ee.Evaluate(args[0], tuple)
+	if err != nil {
+		return nil, err
+	}
+	right, err := ee.Evaluate(args[1], tuple)
+	if err != nil {
+		return nil, err
+	}
+
+	if left != nil && right != nil && compareValues(left, right) == 0 {
+		return nil, nil
+	}
+	return left, nil
+}
+
+// evaluateBetween evaluates expr [NOT] BETWEEN lower AND upper as
+// expr >= lower AND expr <= upper, so a NULL on either side may leave the
+// result unknown
+func (ee *ExpressionEvaluator) evaluateBetween(expr *parser.BetweenExpression, tuple *Tuple) (interface{}, error) {
+	values := make([]interface{}, 3)
+	for i, operand := range []parser.Expression{expr.Expr, expr.Lower, expr.Upper} {
+		value, err := ee.Evaluate(operand, tuple)
+		if err != nil {
+			return nil, err
+		}
+		values[i] = value
+	}
+
+	lower, err := ee.applyBinaryOperator(parser.GreaterEqual, values[0], values[1])
+	if err != nil {
+		return nil, err
+	}
+	upper, err := ee.applyBinaryOperator(parser.LessEqual, values[0], values[2])
+	if err != nil {
+		return nil, err
+	}
+	result, err := logicalAnd(lower, upper)
+	if err != nil || !expr.Not {
+		return result, err
+	}
+	return ee.applyUnaryOperator(parser.Not, result)
+}
+
+// evaluateInList evaluates expr [NOT] IN (value, ...). Without a match, a
+// NULL operand or list value makes the result NULL rather than false.
+func (ee *ExpressionEvaluator) evaluateInList(expr *parser.InListExpression, tuple *Tuple) (interface{}, error) {
+	operand, err := ee.Evaluate(expr.Expr, tuple)
+	if err != nil {
+		return nil, err
+	}
+
+	var result interface{} = false
+	for _, item := range expr.List {
+		value, err := ee.Evaluate(item, tuple)
+		if err != nil {
+			return nil, err
+		}
+		if operand == nil || value == nil {
+			result = nil
+			continue
+		}
+		if compareValues(operand, value) == 0 {
+			result = true
+			break
+		}
+	}
+
+	if !expr.Not {
+		return result, nil
+	}
+	return ee.applyUnaryOperator(parser.Not, result)
+}
+
+// evaluateLike evaluates expr [NOT] LIKE pattern [ESCAPE escape]
+func (ee *ExpressionEvaluator) evaluateLike(expr *parser.LikeExpression, tuple *Tuple) (interface{}, error) {
+	value, err := ee.Evaluate(expr.Expr, tuple)
+	if err != nil {
+		return nil, err
+	}
+	pattern, err := ee.Evaluate(expr.Pattern, tuple)
+	if err != nil {
+		return nil, err
+	}
+	var escape interface{}
+	if expr.Escape != nil {
+		if escape, err = ee.Evaluate(expr.Escape, tuple); err != nil {
+			return nil, err
+		}
+		if escape == nil {
+			return nil, nil
+		}
+	}
+
+	result, err := likeOperator(value, pattern, escape)
+	if err != nil || !expr.Not {
+		return result, err
+	}
+	return ee.applyUnaryOperator(parser.Not, result)
+}
+
+// likeOperator matches a string against a LIKE pattern, in which % matches
+// any run of characters and _ any single character. The escape character,
+// if given, makes the character after it match only itself.
+func likeOperator(value, pattern, escape interface{}) (interface{}, error) {
+	if value == nil || pattern == nil {
+		return nil, nil
+	}
+	text, ok := value.(string)
+	if !ok {
+		return nil, fmt.Errorf("%w: LIKE on %T", ErrTypeMismatch, value)
+	}
+	patternText, ok := pattern.(string)
+	if !ok {
+		return nil, fmt.Errorf("%w: LIKE pattern of type %T", ErrTypeMismatch, pattern)
+	}
+
+	escapeRune := rune(-1)
+	if escape != nil {
+		escapeText, ok := escape.(string)
+		if !ok || len([]rune(escapeText)) != 1 {
+			return nil, fmt.Errorf("%w: LIKE escape must be a single character", ErrTypeMismatch)
+		}
+		escapeRune = []rune(escapeText)[0]
+	}
+
+	// Compile the pattern to runes, marking wildcards; escaped characters
+	// are literals
+	const (
+		anyRun  = -2
+		anyRune = -3
+	)
+	var compiled []rune
+	source := []rune(patternText)
+	for i := 0; i < len(source); i++ {
+		switch {
+		case source[i] == escapeRune:
+			if i+1 == len(source) {
+				return nil, fmt.Errorf("%w: LIKE pattern ends with its escape character", ErrTypeMismatch)
+			}
+			i++
+			compiled = append(compiled, source[i])
+		case source[i] == '%':
+			compiled = append(compiled, anyRun)
+		case source[i] == '_':
+			compiled = append(compiled, anyRune)
+		default:
+			compiled = append(compiled, source[i])
+		}
+	}
+
+	// Match greedily, backtracking to the most recent % on a mismatch
+	runes := []rune(text)
+	t, p := 0, 0
+	star, mark := -1, 0
+	for t < len(runes) {
+		switch {
+		case p < len(compiled) && (compiled[p] == anyRune || compiled[p] == runes[t]):
+			t++
+			p++
+		case p < len(compiled) && compiled[p] == anyRun:
+			star, mark = p, t
+			p++
+		case star >= 0:
+			p = star + 1
+			mark++
+			t = mark
+		default:
+			return false, nil
+		}
+	}
+	for p < len(compiled) && compiled[p] == anyRun {
+		p++
+	}
+	return p == len(compiled), nil
+}
+
+// truthValue converts a condition's value to SQL's three truth values:
+// true, false, or nil for NULL (unknown)
+func truthValue(value interface{}) (*bool, error) {
+	if value == nil {
+		return nil, nil
+	}
+	b, ok := value.(bool)
+	if !ok {
+		return nil, fmt.Errorf("%w: expected boolean, got %T", ErrTypeMismatch, value)
+	}
+	return &b, nil
+}
+
+// logicalAnd is false if either side is false, NULL if either side is NULL
+// and true otherwise
+func logicalAnd(left, right interface{}) (interface{}, error) {
+	l, err := truthValue(left)
+	if err != nil {
+		return nil, err
+	}
+	r, err := truthValue(right)
+	if err != nil {
+		return nil, err
+	}
+
+	switch {
+	case (l != nil && !*l) || (r != nil && !*r):
+		return false, nil
+	case l == nil || r == nil:
+		return nil, nil
+	default:
+		return true, nil
+	}
+}
+
+// logicalOr is true if either side is true, NULL if either side is NULL and
+// false otherwise
+func logicalOr(left, right interface{}) (interface{}, error) {
+	l, err := truthValue(left)
+	if err != nil {
+		return nil, err
+	}
+	r, err := truthValue(right)
+	if err != nil {
+		return nil, err
+	}
+
+	switch {
+	case (l != nil && *l) || (r != nil && *r):
+		return true, nil
+	case l == nil || r == nil:
+		return nil, nil
+	default:
+		return false, nil
+	}
+}
+
+// compareOperator applies a comparison operator to two non-NULL values
+func compareOperator(op parser.BinaryOperator, left, right interface{}) (interface{}, error) {
+	cmp := compareValues(left, right)
+	switch op {
+	case parser.Equal:
+		return cmp == 0, nil
+	case parser.NotEqual:
+		return cmp != 0, nil
+	case parser.LessThan:
+		return cmp < 0, nil
+	case parser.GreaterThan:
+		return cmp > 0, nil
+	case parser.LessEqual:
+		return cmp <= 0, nil
+	case parser.GreaterEqual:
+		return cmp >= 0, nil
+	default:
+		return nil, ErrInvalidOperator
+	}
+}
+
+// arithmetic applies an arithmetic operator to two non-NULL numbers.
+// Integers stay integers; any other number makes the result a float.
+func arithmetic(op parser.BinaryOperator, left, right interface{}) (interface{}, error) {
+	if l, ok := toInt64(left); ok {
+		if r, ok := toInt64(right); ok {
+			switch op {
+			case parser.Plus:
+				return l + r, nil
+			case parser.Minus:
+				return l - r, nil
+			case parser.Multiply:
+				return l * r, nil
+			case parser.Divide, parser.Modulo:
+				if r == 0 {
+					return nil, ErrDivisionByZero
+				}
+				if op == parser.Divide {
+					return l / r, nil
+				}
+				return l % r, nil
+			}
+			return nil, ErrInvalidOperator
+		}
+	}
+
+	l, lok := toFloat64(left)
+	r, rok := toFloat64(right)
+	if !lok || !rok {
+		return nil, fmt.Errorf("%w: %s on %T and %T", ErrTypeMismatch, op, left, right)
+	}
+	switch op {
+	case parser.Plus:
+		return l + r, nil
+	case parser.Minus:
+		return l - r, nil
+	case parser.Multiply:
+		return l * r, nil
+	case parser.Divide, parser.Modulo:
+		if r == 0 {
+			return nil, ErrDivisionByZero
+		}
+		if op == parser.Divide {
+			return l / r, nil
+		}
+		return math.Mod(l, r), nil
+	}
+	return nil, ErrInvalidOperator
+}
+
+// toInt64 converts integer column values to int64
+func toInt64(v interface{}) (int64, bool) {
+	switch n := v.(type) {
+	case int:
+		return int64(n), true
+	case int32:
+		return int64(n), true
+	case int64:
+		return n, true
+	case uint32:
+		return int64(n), true
+	default:
+		return 0, false
+	}
+}
+
+// castValue converts a value to a data type for CAST. NULL stays NULL.
+func castValue(value interface{}, dataType compiler.DataType) (interface{}, error) {
+	if value == nil {
+		return nil, nil
+	}
+	invalid := fmt.Errorf("%w: cannot cast %v to %s", ErrTypeMismatch, value, dataType)
+
+	switch dataType {
+	case compiler.DataTypeInteger:
+		if n, ok := toInt64(value); ok {
+			return n, nil
+		}
+		switch v := value.(type) {
+		case float32, float64:
+			f, _ := toFloat64(v)
+			return int64(f), nil
+		case bool:
+			if v {
+				return int64(1), nil
+			}
+			return int64(0), nil
+		case string:
+			if n, err := strconv.ParseInt(strings.TrimSpace(v), 10, 64); err == nil {
+				return n, nil
+			}
+		}
+
+	case compiler.DataTypeReal, compiler.DataTypeNumeric:
+		if f, ok := toFloat64(value); ok {
+			return f, nil
+		}
+		switch v := value.(type) {
+		case bool:
+			if v {
+				return float64(1), nil
+			}
+			return float64(0), nil
+		case string:
+			if f, err := strconv.ParseFloat(strings.TrimSpace(v), 64); err == nil {
+				return f, nil
+			}
+		}
+
+	case compiler.DataTypeText:
+		switch v := value.(type) {
+		case []byte:
+			return string(v), nil
+		case time.Time:
+			return v.Format(time.RFC3339), nil
+		default:
+			return fmt.Sprintf("%v", v), nil
+		}
+
+	case compiler.DataTypeBoolean:
+		if n, ok := toInt64(value); ok {
+			return n != 0, nil
+		}
+		switch v := value.(type) {
+		case bool:
+			return v, nil
+		case string:
+			if b, err := strconv.ParseBool(strings.TrimSpace(v)); err == nil {
+				return b, nil
+			}
+		}
+
+	case compiler.DataTypeBlob:
+		switch v := value.(type) {
+		case []byte:
+			return v, nil
+		case string:
+			return []byte(v), nil
+		}
+
+	case compiler.DataTypeDate, compiler.DataTypeTimestamp:
+		switch v := value.(type) {
+		case time.Time:
+			if dataType == compiler.DataTypeDate {
+				return time.Date(v.Year(), v.Month(), v.Day(), 0, 0, 0, 0, v.Location()), nil
+			}
+			return v, nil
+		case string:
+			for _, layout := range []string{"2006-01-02", "2006-01-02 15:04:05", time.RFC3339} {
+				if t, err := time.Parse(layout, strings.TrimSpace(v)); err == nil {
+					return t, nil
+				}
+			}
+		}
+	}
+
+	return nil, invalid
+}
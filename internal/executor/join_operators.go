@@ -5,15 +5,202 @@ import (
 	"relational-db/internal/parser"
 )
 
-// NestedLoopJoinOperator implements nested loop join
+// joinState pairs each row of a join's left input with the rows of its
+// right input, which it holds in memory, and returns the pairs the join
+// condition holds for. Rows of the outer side of a LEFT, RIGHT or FULL join
+// that pair with no row are returned with NULLs for the other side.
+type joinState struct {
+	cond      parser.Expression
+	joinType  optimizer.JoinType
+	evaluator *ExpressionEvaluator
+	ctx       *ExecutionContext
+
+	right        []*Tuple
+	rightMatched []bool
+	buckets      map[string][]int // for hash joins: right rows by key
+
+	current    *Tuple // left row being joined
+	candidates []int  // right rows the current row may pair with
+	pos        int
+	matched    bool
+	leftDone   bool
+	unmatched  int // next right row to check once the left input is done
+
+	leftSchema  *TupleSchema
+	rightSchema *TupleSchema
+	schemas     map[[2]*TupleSchema]*TupleSchema
+}
+
+// open reads the right input, hashing it on the right side of keys if
+// there are any
+func (js *joinState) open(ctx *ExecutionContext, right PhysicalOperator, keys []optimizer.JoinKey) error {
+	js.evaluator.Bind(ctx)
+	js.ctx = ctx
+
+	rows, err := allRows(ctx, right)
+	if err != nil {
+		return err
+	}
+	js.right = rows
+	js.rightMatched = make([]bool, len(rows))
+	js.leftSchema = NewTupleSchema(nil)
+	js.rightSchema = NewTupleSchema(nil)
+	if len(rows) > 0 {
+		js.rightSchema = rows[0].Schema
+	}
+	js.schemas = make(map[[2]*TupleSchema]*TupleSchema)
+
+	js.buckets = nil
+	if len(keys) > 0 {
+		js.buckets = make(map[string][]int)
+		for i, row := range rows {
+			key, err := joinKeyOf(js.evaluator, keys, false, row)
+			if err != nil {
+				return err
+			}
+			if key != "" {
+				js.buckets[key] = append(js.buckets[key], i)
+			}
+		}
+	}
+
+	js.current = nil
+	js.leftDone = false
+	js.unmatched = 0
+	return nil
+}
+
+// next returns the next joined row, reading left rows as needed
+func (js *joinState) next(left PhysicalOperator, keys []optimizer.JoinKey) (*Tuple, error) {
+	for !js.leftDone {
+		if js.current == nil {
+			if js.ctx.IsTimedOut() {
+				return nil, ErrExecutionTimeout
+			}
+			tuple, err := left.Next()
+			if err != nil {
+				return nil, err
+			}
+			if tuple == nil {
+				js.leftDone = true
+				break
+			}
+			if err := js.start(tuple, keys); err != nil {
+				return nil, err
+			}
+		}
+
+		for js.pos < len(js.candidates) {
+			i := js.candidates[js.pos]
+			js.pos++
+			row := js.join(js.current, js.right[i])
+			if js.cond != nil {
+				holds, err := conditionHolds(js.evaluator, js.cond, row)
+				if err != nil {
+					return nil, err
+				}
+				if !holds {
+					continue
+				}
+			}
+			js.matched = true
+			js.rightMatched[i] = true
+			return row, nil
+		}
+
+		row := js.current
+		js.current = nil
+		if !js.matched && (js.joinType == optimizer.JoinTypeLeft || js.joinType == optimizer.JoinTypeFull) {
+			return js.join(row, nil), nil
+		}
+	}
+
+	if js.joinType == optimizer.JoinTypeRight || js.joinType == optimizer.JoinTypeFull {
+		for js.unmatched < len(js.right) {
+			i := js.unmatched
+			js.unmatched++
+			if !js.rightMatched[i] {
+				return js.join(nil, js.right[i]), nil
+			}
+		}
+	}
+	return nil, nil // EOF
+}
+
+// start makes tuple the current left row and finds the right rows it may
+// pair with: all of them, or for a hash join those with its key values
+func (js *joinState) start(tuple *Tuple, keys []optimizer.JoinKey) error {
+	js.current = tuple
+	js.leftSchema = tuple.Schema
+	js.pos = 0
+	js.matched = false
+
+	if js.buckets == nil {
+		if len(js.candidates) != len(js.right) {
+			js.candidates = make([]int, len(js.right))
+			for i := range js.candidates {
+				js.candidates[i] = i
+			}
+		}
+		return nil
+	}
+
+	key, err := joinKeyOf(js.evaluator, keys, true, tuple)
+	if err != nil {
+		return err
+	}
+	js.candidates = js.buckets[key]
+	return nil
+}
+
+// join returns a left row followed by a right row; a nil row is NULLs
+func (js *joinState) join(left, right *Tuple) *Tuple {
+	leftSchema, rightSchema := js.leftSchema, js.rightSchema
+	if left != nil {
+		leftSchema = left.Schema
+	}
+	if right != nil {
+		rightSchema = right.Schema
+	}
+
+	pair := [2]*TupleSchema{leftSchema, rightSchema}
+	schema, ok := js.schemas[pair]
+	if !ok {
+		schema = joinedSchema(leftSchema, rightSchema)
+		js.schemas[pair] = schema
+	}
+
+	values := make([]interface{}, 0, len(schema.Columns))
+	if left != nil {
+		values = append(values, left.Values...)
+	} else {
+		values = append(values, make([]interface{}, len(leftSchema.Columns))...)
+	}
+	if right != nil {
+		values = append(values, right.Values...)
+	} else {
+		values = append(values, make([]interface{}, len(rightSchema.Columns))...)
+	}
+	return NewTuple(schema, values)
+}
+
+// close releases the right rows
+func (js *joinState) close() {
+	js.right = nil
+	js.rightMatched = nil
+	js.buckets = nil
+	js.candidates = nil
+	js.current = nil
+}
+
+// NestedLoopJoinOperator joins by checking the join condition for every
+// pair of a left row and a right row. The right input is read once and kept
+// in memory.
 type NestedLoopJoinOperator struct {
-	leftChild   PhysicalOperator
-	rightChild  PhysicalOperator
-	joinCond    parser.Expression
-	joinType    optimizer.JoinType
-	evaluator   *ExpressionEvaluator
-	currentLeft *Tuple
-	closed      bool
+	leftChild  PhysicalOperator
+	rightChild PhysicalOperator
+	state      *joinState
+	closed     bool
 }
 
 // NewNestedLoopJoinOperator creates a new nested loop join operator
@@ -25,24 +212,26 @@ func NewNestedLoopJoinOperator(
 	return &NestedLoopJoinOperator{
 		leftChild:  left,
 		rightChild: right,
-		joinCond:   condition,
-		joinType:   joinType,
-		evaluator:  NewExpressionEvaluator(),
-		closed:     true,
+		state: &joinState{
+			cond:      condition,
+			joinType:  joinType,
+			evaluator: NewExpressionEvaluator(),
+		},
+		closed: true,
 	}
 }
 
-// Open initializes the operator
+// Open reads the right input and opens the left one
 func (op *NestedLoopJoinOperator) Open(ctx *ExecutionContext) error {
 	if !op.closed {
 		return nil
 	}
 
-	if err := op.leftChild.Open(ctx); err != nil {
+	if err := op.state.open(ctx, op.rightChild, nil); err != nil {
 		return err
 	}
 
-	if err := op.rightChild.Open(ctx); err != nil {
+	if err := op.leftChild.Open(ctx); err != nil {
 		return err
 	}
 
@@ -55,9 +244,7 @@ func (op *NestedLoopJoinOperator) Next() (*Tuple, error) {
 	if op.closed {
 		return nil, ErrOperatorClosed
 	}
-
-	// TODO: Implement nested loop join logic
-	return nil, nil // EOF for now
+	return op.state.next(op.leftChild, nil)
 }
 
 // Close releases resources
@@ -66,14 +253,10 @@ func (op *NestedLoopJoinOperator) Close() error {
 		return nil
 	}
 
-	err1 := op.leftChild.Close()
-	err2 := op.rightChild.Close()
+	err := op.leftChild.Close()
+	op.state.close()
 	op.closed = true
-
-	if err1 != nil {
-		return err1
-	}
-	return err2
+	return err
 }
 
 // OperatorType returns the operator type
@@ -86,14 +269,15 @@ func (op *NestedLoopJoinOperator) EstimatedCost() float64 {
 	return op.leftChild.EstimatedCost() * op.rightChild.EstimatedCost()
 }
 
-// HashJoinOperator implements hash join
+// HashJoinOperator joins by hashing the right input on its side of the
+// join keys and pairing each left row only with the right rows whose key
+// values equal its own. The whole join condition is still checked for each
+// pair.
 type HashJoinOperator struct {
 	buildChild PhysicalOperator
 	probeChild PhysicalOperator
-	joinCond   parser.Expression
-	joinType   optimizer.JoinType
-	hashTable  map[interface{}][]*Tuple
-	evaluator  *ExpressionEvaluator
+	joinKeys   []optimizer.JoinKey
+	state      *joinState
 	closed     bool
 }
 
@@ -101,25 +285,29 @@ type HashJoinOperator struct {
 func NewHashJoinOperator(
 	left, right PhysicalOperator,
 	condition parser.Expression,
+	joinKeys []optimizer.JoinKey,
 	joinType optimizer.JoinType,
 ) *HashJoinOperator {
 	return &HashJoinOperator{
 		buildChild: right, // Smaller relation for build phase
 		probeChild: left,
-		joinCond:   condition,
-		joinType:   joinType,
-		evaluator:  NewExpressionEvaluator(),
-		closed:     true,
+		joinKeys:   joinKeys,
+		state: &joinState{
+			cond:      condition,
+			joinType:  joinType,
+			evaluator: NewExpressionEvaluator(),
+		},
+		closed: true,
 	}
 }
 
-// Open initializes the operator
+// Open builds the hash table from the build side and opens the probe side
 func (op *HashJoinOperator) Open(ctx *ExecutionContext) error {
 	if !op.closed {
 		return nil
 	}
 
-	if err := op.buildChild.Open(ctx); err != nil {
+	if err := op.state.open(ctx, op.buildChild, op.joinKeys); err != nil {
 		return err
 	}
 
@@ -127,9 +315,6 @@ func (op *HashJoinOperator) Open(ctx *ExecutionContext) error {
 		return err
 	}
 
-	// TODO: Build hash table from build side
-	op.hashTable = make(map[interface{}][]*Tuple)
-
 	op.closed = false
 	return nil
 }
@@ -139,9 +324,7 @@ func (op *HashJoinOperator) Next() (*Tuple, error) {
 	if op.closed {
 		return nil, ErrOperatorClosed
 	}
-
-	// TODO: Implement hash join logic
-	return nil, nil // EOF for now
+	return op.state.next(op.probeChild, op.joinKeys)
 }
 
 // Close releases resources
@@ -150,15 +333,10 @@ func (op *HashJoinOperator) Close() error {
 		return nil
 	}
 
-	err1 := op.buildChild.Close()
-	err2 := op.probeChild.Close()
-	op.hashTable = nil
+	err := op.probeChild.Close()
+	op.state.close()
 	op.closed = true
-
-	if err1 != nil {
-		return err1
-	}
-	return err2
+	return err
 }
 
 // OperatorType returns the operator type
@@ -203,6 +381,8 @@ func (op *MergeJoinOperator) Open(ctx *ExecutionContext) error {
 		return nil
 	}
 
+	op.evaluator.Bind(ctx)
+
 	if err := op.leftChild.Open(ctx); err != nil {
 		return err
 	}
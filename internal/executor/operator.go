@@ -2,7 +2,15 @@
 package executor
 
 import (
+	"bytes"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"relational-db/internal/lexer"
 	"relational-db/internal/parser"
+	"relational-db/internal/semantic"
 )
 
 // PhysicalOperator is the interface all physical operators must implement
@@ -47,6 +55,29 @@ func (t *Tuple) GetColumn(name string) (interface{}, error) {
 	return t.Values[idx], nil
 }
 
+// lookupColumn finds a column by name, restricted to one table's columns
+// when table is set
+func (t *Tuple) lookupColumn(table, name string) (interface{}, bool) {
+	if t == nil || t.Schema == nil {
+		return nil, false
+	}
+
+	if table == "" {
+		idx := t.Schema.GetColumnIndex(name)
+		if idx < 0 || idx >= len(t.Values) {
+			return nil, false
+		}
+		return t.Values[idx], true
+	}
+
+	for idx, col := range t.Schema.Columns {
+		if col.Name == name && col.TableName == table && idx < len(t.Values) {
+			return t.Values[idx], true
+		}
+	}
+	return nil, false
+}
+
 // GetColumnByIndex returns value by index
 func (t *Tuple) GetColumnByIndex(idx int) (interface{}, error) {
 	if idx < 0 || idx >= len(t.Values) {
@@ -86,7 +117,8 @@ type ColumnInfo struct {
 	Name      string
 	Type      ColumnType
 	Nullable  bool
-	TableName string // Optional table qualifier
+	TableName string      // Optional table qualifier
+	Default   interface{} // Value INSERT stores when it gives none, nil for NULL
 }
 
 // ColumnType represents the data type of a column
@@ -102,6 +134,7 @@ const (
 	TypeDate
 	TypeTimestamp
 	TypeNull
+	TypeBlob
 )
 
 // String returns string representation of column type
@@ -125,6 +158,8 @@ func (ct ColumnType) String() string {
 		return "TIMESTAMP"
 	case TypeNull:
 		return "NULL"
+	case TypeBlob:
+		return "BLOB"
 	default:
 		return "UNKNOWN"
 	}
@@ -207,13 +242,32 @@ func (rs *ResultSet) GetTuple(idx int) (*Tuple, error) {
 }
 
 // ExpressionEvaluator evaluates expressions against tuples
-type ExpressionEvaluator struct{}
+type ExpressionEvaluator struct {
+	// Values bound to ? and $n parameters; $1 is parameters[0]
+	parameters []interface{}
+
+	// Execution the evaluator runs in, for subqueries and the rows of
+	// enclosing queries
+	ctx *ExecutionContext
+}
 
 // NewExpressionEvaluator creates a new expression evaluator
 func NewExpressionEvaluator() *ExpressionEvaluator {
 	return &ExpressionEvaluator{}
 }
 
+// SetParameters sets the values bound to the query's parameters
+func (ee *ExpressionEvaluator) SetParameters(params []interface{}) {
+	ee.parameters = params
+}
+
+// Bind prepares the evaluator for an execution: its parameter values,
+// subqueries and, in a correlated subquery, the rows of enclosing queries
+func (ee *ExpressionEvaluator) Bind(ctx *ExecutionContext) {
+	ee.parameters = ctx.Parameters()
+	ee.ctx = ctx
+}
+
 // Evaluate evaluates an expression against a tuple
 func (ee *ExpressionEvaluator) Evaluate(expr parser.Expression, tuple *Tuple) (interface{}, error) {
 	if expr == nil {
@@ -225,27 +279,100 @@ func (ee *ExpressionEvaluator) Evaluate(expr parser.Expression, tuple *Tuple) (i
 		return ee.evaluateLiteral(e)
 
 	case *parser.Identifier:
-		return tuple.GetColumn(e.Value)
+		return ee.evaluateColumn("", e.Value, tuple)
+
+	case *parser.ColumnReference:
+		table := ""
+		if e.Table != nil {
+			table = e.Table.Value
+		}
+		return ee.evaluateColumn(table, e.Column.Value, tuple)
 
 	case *parser.BinaryExpression:
+		if subquery, ok := e.Right.(*parser.SubqueryExpression); ok && e.Operator == parser.In {
+			return ee.evaluateInSubquery(e.Left, subquery, tuple)
+		}
 		return ee.evaluateBinary(e, tuple)
 
+	case *parser.SubqueryExpression:
+		return ee.evaluateScalarSubquery(e, tuple)
+
+	case *parser.ExistsExpression:
+		return ee.evaluateExists(e, tuple)
+
 	case *parser.UnaryExpression:
 		return ee.evaluateUnary(e, tuple)
 
 	case *parser.FunctionCall:
 		return ee.evaluateFunction(e, tuple)
 
+	case *parser.IsNullExpression:
+		return ee.evaluateIsNull(e, tuple)
+
+	case *parser.BetweenExpression:
+		return ee.evaluateBetween(e, tuple)
+
+	case *parser.InListExpression:
+		return ee.evaluateInList(e, tuple)
+
+	case *parser.LikeExpression:
+		return ee.evaluateLike(e, tuple)
+
+	case *parser.CaseExpression:
+		return ee.evaluateCase(e, tuple)
+
+	case *parser.CastExpression:
+		return ee.evaluateCast(e, tuple)
+
+	case *parser.Parameter:
+		return ee.evaluateParameter(e)
+
 	default:
 		return nil, ErrUnsupportedExpression
 	}
 }
 
+// evaluateColumn reads a column of the tuple. Columns not found there are
+// looked up in the rows of enclosing queries, innermost first, which is how
+// correlated subqueries see the row they are evaluated for.
+func (ee *ExpressionEvaluator) evaluateColumn(table, name string, tuple *Tuple) (interface{}, error) {
+	if value, found := tuple.lookupColumn(table, name); found {
+		return value, nil
+	}
+	if ee.ctx != nil {
+		for row := ee.ctx.outer; row != nil; row = row.next {
+			if value, found := row.tuple.lookupColumn(table, name); found {
+				return value, nil
+			}
+		}
+	}
+	return nil, ErrColumnNotFound
+}
+
 // evaluateLiteral evaluates a literal expression
 func (ee *ExpressionEvaluator) evaluateLiteral(lit *parser.Literal) (interface{}, error) {
+	// The parser keeps numbers as their source text
+	if text, ok := lit.Value.(string); ok && lit.Type == lexer.NUMBER {
+		if n, err := strconv.ParseInt(text, 10, 64); err == nil {
+			return n, nil
+		}
+		f, err := strconv.ParseFloat(text, 64)
+		if err != nil {
+			return nil, fmt.Errorf("%w: invalid number %s", ErrTypeMismatch, text)
+		}
+		return f, nil
+	}
 	return lit.Value, nil
 }
 
+// evaluateParameter returns the value bound to a parameter
+func (ee *ExpressionEvaluator) evaluateParameter(param *parser.Parameter) (interface{}, error) {
+	if param.Index < 1 || param.Index > len(ee.parameters) {
+		return nil, fmt.Errorf("%w: no value bound to parameter %d", ErrMissingParameter, param.Index)
+	}
+	return ee.parameters[param.Index-1], nil
+}
+
 // evaluateBinary evaluates a binary expression
 func (ee *ExpressionEvaluator) evaluateBinary(expr *parser.BinaryExpression, tuple *Tuple) (interface{}, error) {
 	left, err := ee.Evaluate(expr.Left, tuple)
@@ -275,18 +402,165 @@ func (ee *ExpressionEvaluator) evaluateUnary(expr *parser.UnaryExpression, tuple
 
 // evaluateFunction evaluates a function call
 func (ee *ExpressionEvaluator) evaluateFunction(expr *parser.FunctionCall, tuple *Tuple) (interface{}, error) {
-	// TODO: Implement function evaluation
+	if expr.Over != nil {
+		// Window functions are computed by the window operator below
+		if value, found := tuple.lookupColumn("", expr.String()); found {
+			return value, nil
+		}
+		return nil, fmt.Errorf("%w: %s", ErrWindowNotComputed, expr.String())
+	}
+	if semantic.IsAggregateCall(expr) {
+		// Aggregates are computed by the aggregate operator below
+		if value, found := tuple.lookupColumn("", expr.String()); found {
+			return value, nil
+		}
+		return nil, fmt.Errorf("%w: %s", ErrAggregateNotComputed, expr.String())
+	}
+
+	switch strings.ToUpper(expr.Name.Value) {
+	case "COALESCE":
+		return ee.evaluateCoalesce(expr.Arguments, tuple)
+	case "NULLIF":
+		return ee.evaluateNullIf(expr.Arguments, tuple)
+	}
+	// TODO: Implement the remaining functions
 	return nil, ErrNotImplemented
 }
 
-// applyBinaryOperator applies a binary operator
+// applyBinaryOperator applies a binary operator with SQL's three-valued
+// logic: AND and OR can still decide their result when an operand is NULL,
+// while every other operator yields NULL.
 func (ee *ExpressionEvaluator) applyBinaryOperator(op parser.BinaryOperator, left, right interface{}) (interface{}, error) {
-	// TODO: Implement binary operators (=, <, >, +, -, *, /, etc.)
-	return nil, ErrNotImplemented
+	switch op {
+	case parser.And:
+		return logicalAnd(left, right)
+	case parser.Or:
+		return logicalOr(left, right)
+	}
+
+	if left == nil || right == nil {
+		return nil, nil
+	}
+
+	switch op {
+	case parser.Equal, parser.NotEqual, parser.LessThan, parser.GreaterThan, parser.LessEqual, parser.GreaterEqual:
+		return compareOperator(op, left, right)
+	case parser.Plus, parser.Minus, parser.Multiply, parser.Divide, parser.Modulo:
+		return arithmetic(op, left, right)
+	case parser.Like:
+		return likeOperator(left, right, nil)
+	default:
+		// IN and BETWEEN have expression nodes of their own
+		return nil, ErrInvalidOperator
+	}
 }
 
 // applyUnaryOperator applies a unary operator
 func (ee *ExpressionEvaluator) applyUnaryOperator(op parser.UnaryOperator, operand interface{}) (interface{}, error) {
-	// TODO: Implement unary operators (NOT, -, etc.)
-	return nil, ErrNotImplemented
+	if operand == nil {
+		return nil, nil
+	}
+
+	switch op {
+	case parser.Not:
+		truth, err := truthValue(operand)
+		if err != nil {
+			return nil, err
+		}
+		return !*truth, nil
+	case parser.UnaryMinus:
+		if n, ok := toInt64(operand); ok {
+			return -n, nil
+		}
+		if f, ok := toFloat64(operand); ok {
+			return -f, nil
+		}
+		return nil, fmt.Errorf("%w: cannot negate %T", ErrTypeMismatch, operand)
+	case parser.UnaryPlus:
+		if _, ok := toFloat64(operand); ok {
+			return operand, nil
+		}
+		return nil, fmt.Errorf("%w: unary + on %T", ErrTypeMismatch, operand)
+	default:
+		return nil, ErrInvalidOperator
+	}
+}
+
+// compareValues orders two column values. NULL sorts before any other value,
+// numbers of different Go types compare numerically, and values of unrelated
+// types fall back to comparing their string forms.
+func compareValues(a, b interface{}) int {
+	if a == nil || b == nil {
+		switch {
+		case a == nil && b == nil:
+			return 0
+		case a == nil:
+			return -1
+		default:
+			return 1
+		}
+	}
+
+	if af, ok := toFloat64(a); ok {
+		if bf, ok := toFloat64(b); ok {
+			switch {
+			case af < bf:
+				return -1
+			case af > bf:
+				return 1
+			default:
+				return 0
+			}
+		}
+	}
+
+	switch av := a.(type) {
+	case string:
+		if bv, ok := b.(string); ok {
+			return strings.Compare(av, bv)
+		}
+	case bool:
+		if bv, ok := b.(bool); ok {
+			switch {
+			case av == bv:
+				return 0
+			case !av:
+				return -1
+			default:
+				return 1
+			}
+		}
+	case time.Time:
+		if bv, ok := b.(time.Time); ok {
+			return av.Compare(bv)
+		}
+	case []byte:
+		if bv, ok := b.([]byte); ok {
+			return bytes.Compare(av, bv)
+		}
+	}
+
+	return strings.Compare(fmt.Sprintf("%v", a), fmt.Sprintf("%v", b))
+}
+
+// toFloat64 converts numeric column values to float64 for comparison
+func toFloat64(v interface{}) (float64, bool) {
+	switch n := v.(type) {
+	case int:
+		return float64(n), true
+	case int32:
+		return float64(n), true
+	case int64:
+		return float64(n), true
+	case uint32:
+		return float64(n), true
+	case uint64:
+		return float64(n), true
+	case float32:
+		return float64(n), true
+	case float64:
+		return n, true
+	default:
+		return 0, false
+	}
 }
@@ -0,0 +1,157 @@
+package executor
+
+import (
+	"fmt"
+	"math"
+	"time"
+
+	"relational-db/internal/compiler"
+)
+
+// BindParameters checks that a statement expecting count parameters got
+// exactly that many values and converts them to the types the executor
+// works with: integers become int64, floats float64, and strings, byte
+// slices, booleans, times and nil are kept as they are.
+func BindParameters(count int, params []interface{}) ([]interface{}, error) {
+	if len(params) != count {
+		return nil, fmt.Errorf("statement expects %d parameters, got %d", count, len(params))
+	}
+
+	bound := make([]interface{}, len(params))
+	for i, param := range params {
+		value, err := bindValue(param)
+		if err != nil {
+			return nil, fmt.Errorf("parameter %d: %w", i+1, err)
+		}
+		bound[i] = value
+	}
+	return bound, nil
+}
+
+// Bind binds params to the plan's parameters. Each value must also fit the
+// type the compiler inferred for its parameter: integers bound to a REAL
+// parameter become float64, whole floats bound to an INTEGER parameter
+// become int64, and strings bound to a BLOB parameter become byte slices.
+// NULL fits every type.
+func (p *PreparedPlan) Bind(params []interface{}) ([]interface{}, error) {
+	bound, err := BindParameters(p.ParameterCount, params)
+	if err != nil {
+		return nil, err
+	}
+	if p.Compiled == nil || p.Compiled.TypeInfo == nil {
+		return bound, nil
+	}
+
+	for i, value := range bound {
+		dataType, found := p.Compiled.TypeInfo.GetParameterType(i + 1)
+		if !found {
+			continue
+		}
+		converted, err := bindAs(value, dataType)
+		if err != nil {
+			return nil, fmt.Errorf("parameter %d: %w", i+1, err)
+		}
+		bound[i] = converted
+	}
+	return bound, nil
+}
+
+// bindAs converts a bound value to the given type
+func bindAs(v interface{}, dataType compiler.DataType) (interface{}, error) {
+	if v == nil {
+		return nil, nil
+	}
+
+	switch dataType {
+	case compiler.DataTypeInteger:
+		switch n := v.(type) {
+		case int64:
+			return n, nil
+		case float64:
+			if n == math.Trunc(n) && n >= math.MinInt64 && n < math.MaxInt64 {
+				return int64(n), nil
+			}
+		}
+	case compiler.DataTypeReal:
+		switch n := v.(type) {
+		case int64:
+			return float64(n), nil
+		case float64:
+			return n, nil
+		}
+	case compiler.DataTypeNumeric:
+		switch v.(type) {
+		case int64, float64:
+			return v, nil
+		}
+	case compiler.DataTypeText:
+		if _, ok := v.(string); ok {
+			return v, nil
+		}
+	case compiler.DataTypeBlob:
+		switch b := v.(type) {
+		case []byte:
+			return b, nil
+		case string:
+			return []byte(b), nil
+		}
+	case compiler.DataTypeBoolean:
+		if _, ok := v.(bool); ok {
+			return v, nil
+		}
+	case compiler.DataTypeDate, compiler.DataTypeTime, compiler.DataTypeTimestamp:
+		// Times are also written as strings and as Unix seconds
+		switch v.(type) {
+		case time.Time, string, int64:
+			return v, nil
+		}
+	default:
+		return v, nil
+	}
+	return nil, fmt.Errorf("%w: cannot bind %T to %s", ErrTypeMismatch, v, dataType)
+}
+
+// bindValue converts a single parameter value
+func bindValue(v interface{}) (interface{}, error) {
+	switch n := v.(type) {
+	case nil, string, bool, time.Time:
+		return n, nil
+	case []byte:
+		// Copy so the caller may reuse its buffer
+		return append([]byte(nil), n...), nil
+	case int:
+		return int64(n), nil
+	case int8:
+		return int64(n), nil
+	case int16:
+		return int64(n), nil
+	case int32:
+		return int64(n), nil
+	case int64:
+		return n, nil
+	case uint:
+		return bindUnsigned(uint64(n))
+	case uint8:
+		return int64(n), nil
+	case uint16:
+		return int64(n), nil
+	case uint32:
+		return int64(n), nil
+	case uint64:
+		return bindUnsigned(n)
+	case float32:
+		return float64(n), nil
+	case float64:
+		return n, nil
+	default:
+		return nil, fmt.Errorf("%w: unsupported parameter type %T", ErrTypeMismatch, v)
+	}
+}
+
+// bindUnsigned converts an unsigned value that must fit in an int64
+func bindUnsigned(n uint64) (interface{}, error) {
+	if n > math.MaxInt64 {
+		return nil, fmt.Errorf("%w: value %d overflows INTEGER", ErrTypeMismatch, n)
+	}
+	return int64(n), nil
+}
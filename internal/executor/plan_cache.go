@@ -0,0 +1,264 @@
+package executor
+
+import (
+	"container/list"
+	"fmt"
+	"strings"
+	"sync"
+	"unicode"
+
+	"relational-db/internal/compiler"
+	"relational-db/internal/lexer"
+	"relational-db/internal/optimizer"
+	"relational-db/internal/parser"
+)
+
+// DefaultPlanCacheSize is the number of plans kept when no size is configured
+const DefaultPlanCacheSize = 256
+
+// PreparedPlan is a statement that has been parsed, compiled and, for DML,
+// optimized, ready to be executed any number of times with different
+// parameter values
+type PreparedPlan struct {
+	SQL            string // normalized SQL, the plan cache key
+	Statement      parser.Statement
+	ParameterCount int
+	Compiled       *compiler.CompiledQuery
+	Plan           *optimizer.QueryPlan // nil for statements that are not optimized
+
+	// Schemas of the referenced tables when the plan was built
+	tables map[string]tableVersion
+
+	// SQL the plan was built from, as written; positions in errors refer
+	// to it
+	source string
+}
+
+// tableVersion identifies one version of a table's schema. The schema
+// pointer tells a dropped and recreated table apart from the original.
+type tableVersion struct {
+	schema  *TableSchema
+	version int
+}
+
+// Tables returns the names of the tables the plan reads or writes
+func (p *PreparedPlan) Tables() []string {
+	tables := make([]string, 0, len(p.tables))
+	for name := range p.tables {
+		tables = append(tables, name)
+	}
+	return tables
+}
+
+// isCurrent reports whether none of the referenced tables changed since the
+// plan was built
+func (p *PreparedPlan) isCurrent(schemas *SchemaManager) bool {
+	for name, built := range p.tables {
+		if currentTableVersion(schemas, name) != built {
+			return false
+		}
+	}
+	return true
+}
+
+// currentTableVersion returns the current schema version of a table, or the
+// zero version if it does not exist
+func currentTableVersion(schemas *SchemaManager, name string) tableVersion {
+	schema, err := schemas.GetSchema(name)
+	if err != nil {
+		return tableVersion{}
+	}
+	version, err := schemas.GetSchemaVersion(name)
+	if err != nil {
+		return tableVersion{}
+	}
+	return tableVersion{schema: schema, version: version}
+}
+
+// PlanCache is an LRU cache of prepared plans keyed by normalized SQL.
+// Entries are dropped on lookup once the schema of a table they reference
+// has changed.
+type PlanCache struct {
+	capacity int
+	schemas  *SchemaManager
+
+	entries map[string]*list.Element
+	lru     *list.List // front is the most recently used plan
+
+	// Statistics
+	hits          uint64
+	misses        uint64
+	evictions     uint64
+	invalidations uint64
+
+	mutex sync.Mutex
+}
+
+// PlanCacheStats reports plan cache activity
+type PlanCacheStats struct {
+	Size          int
+	Capacity      int
+	Hits          uint64
+	Misses        uint64
+	Evictions     uint64
+	Invalidations uint64
+}
+
+// NewPlanCache creates a plan cache holding up to capacity plans
+func NewPlanCache(capacity int, schemas *SchemaManager) *PlanCache {
+	if capacity <= 0 {
+		capacity = DefaultPlanCacheSize
+	}
+	return &PlanCache{
+		capacity: capacity,
+		schemas:  schemas,
+		entries:  make(map[string]*list.Element),
+		lru:      list.New(),
+	}
+}
+
+// Get returns the cached plan for normalized SQL if it is still current
+func (pc *PlanCache) Get(sql string) (*PreparedPlan, bool) {
+	pc.mutex.Lock()
+	defer pc.mutex.Unlock()
+
+	elem, exists := pc.entries[sql]
+	if !exists {
+		pc.misses++
+		return nil, false
+	}
+
+	plan := elem.Value.(*PreparedPlan)
+	if !plan.isCurrent(pc.schemas) {
+		pc.lru.Remove(elem)
+		delete(pc.entries, sql)
+		pc.invalidations++
+		pc.misses++
+		return nil, false
+	}
+
+	pc.lru.MoveToFront(elem)
+	pc.hits++
+	return plan, true
+}
+
+// Put adds a plan, evicting the least recently used plan when full
+func (pc *PlanCache) Put(plan *PreparedPlan) {
+	pc.mutex.Lock()
+	defer pc.mutex.Unlock()
+
+	if elem, exists := pc.entries[plan.SQL]; exists {
+		elem.Value = plan
+		pc.lru.MoveToFront(elem)
+		return
+	}
+
+	pc.entries[plan.SQL] = pc.lru.PushFront(plan)
+	for pc.lru.Len() > pc.capacity {
+		oldest := pc.lru.Back()
+		pc.lru.Remove(oldest)
+		delete(pc.entries, oldest.Value.(*PreparedPlan).SQL)
+		pc.evictions++
+	}
+}
+
+// Invalidate drops every cached plan that references a table
+func (pc *PlanCache) Invalidate(tableName string) {
+	pc.mutex.Lock()
+	defer pc.mutex.Unlock()
+
+	for sql, elem := range pc.entries {
+		if _, references := elem.Value.(*PreparedPlan).tables[tableName]; references {
+			pc.lru.Remove(elem)
+			delete(pc.entries, sql)
+			pc.invalidations++
+		}
+	}
+}
+
+// Clear drops all cached plans
+func (pc *PlanCache) Clear() {
+	pc.mutex.Lock()
+	defer pc.mutex.Unlock()
+
+	pc.entries = make(map[string]*list.Element)
+	pc.lru.Init()
+}
+
+// Len returns the number of cached plans
+func (pc *PlanCache) Len() int {
+	pc.mutex.Lock()
+	defer pc.mutex.Unlock()
+	return pc.lru.Len()
+}
+
+// Stats returns plan cache statistics
+func (pc *PlanCache) Stats() PlanCacheStats {
+	pc.mutex.Lock()
+	defer pc.mutex.Unlock()
+
+	return PlanCacheStats{
+		Size:          pc.lru.Len(),
+		Capacity:      pc.capacity,
+		Hits:          pc.hits,
+		Misses:        pc.misses,
+		Evictions:     pc.evictions,
+		Invalidations: pc.invalidations,
+	}
+}
+
+// NormalizeSQL rewrites a statement into a canonical form so that queries
+// differing only in whitespace, comments or the case of keywords and
+// unquoted names share a cache entry: tokens are separated by single
+// spaces, keywords are upper-cased, unquoted names are lower-cased and a
+// trailing semicolon is dropped. Literal values are kept, so only
+// parameterized queries share plans across different values.
+func NormalizeSQL(sql string) (string, error) {
+	l := lexer.NewLexer(sql)
+	var tokens []string
+
+	for {
+		token := l.NextToken()
+		switch token.Type {
+		case lexer.EOF:
+			for len(tokens) > 0 && tokens[len(tokens)-1] == ";" {
+				tokens = tokens[:len(tokens)-1]
+			}
+			return strings.Join(tokens, " "), nil
+		case lexer.ILLEGAL:
+			return "", fmt.Errorf("illegal token '%s' at position %d (line %d, column %d)",
+				token.Value, token.Position, token.Line, token.Column)
+		case lexer.COMMENT:
+			continue
+		case lexer.STRING:
+			tokens = append(tokens, "'"+strings.ReplaceAll(token.Value, "'", "''")+"'")
+		case lexer.HEX_STRING:
+			tokens = append(tokens, "X'"+strings.ToUpper(token.Value)+"'")
+		case lexer.IDENTIFIER:
+			name := token.Value
+			if !token.Quoted {
+				name = strings.ToLower(name)
+			}
+			tokens = append(tokens, normalizeIdentifier(name))
+		case lexer.NUMBER, lexer.PARAMETER:
+			tokens = append(tokens, token.Value)
+		default:
+			tokens = append(tokens, strings.ToUpper(token.Value))
+		}
+	}
+}
+
+// normalizeIdentifier quotes identifiers that would otherwise read as a
+// keyword or as several tokens, or fold to another name
+func normalizeIdentifier(name string) string {
+	quoted := "`" + strings.ReplaceAll(name, "`", "``") + "`"
+	if _, keyword := lexer.Keywords[strings.ToUpper(name)]; keyword {
+		return quoted
+	}
+	for i, ch := range name {
+		if !(unicode.IsLetter(ch) || ch == '_' || (i > 0 && unicode.IsDigit(ch))) || unicode.IsUpper(ch) {
+			return quoted
+		}
+	}
+	return name
+}
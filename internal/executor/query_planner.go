@@ -0,0 +1,138 @@
+package executor
+
+import (
+	"fmt"
+
+	"relational-db/internal/compiler"
+	"relational-db/internal/optimizer"
+	"relational-db/internal/parser"
+	"relational-db/internal/semantic"
+)
+
+// QueryPlanner runs SQL through the lexer, parser, compiler, semantic
+// analyzer and optimizer, and caches the resulting plans so repeated
+// statements skip straight to execution
+type QueryPlanner struct {
+	catalog   *CompilerCatalog
+	schemas   *SchemaManager
+	compiler  *compiler.QueryCompiler
+	analyzer  *semantic.SemanticAnalyzer
+	optimizer *optimizer.Optimizer
+	cache     *PlanCache
+}
+
+// NewQueryPlanner creates a planner over a catalog with a plan cache of
+// cacheSize entries
+func NewQueryPlanner(catalog *CatalogManager, cacheSize int) *QueryPlanner {
+	compilerCatalog := NewCompilerCatalog(catalog)
+	return &QueryPlanner{
+		catalog:  compilerCatalog,
+		schemas:  catalog.schemaManager,
+		compiler: compiler.NewQueryCompiler(compilerCatalog),
+		analyzer: semantic.NewSemanticAnalyzer(compilerCatalog),
+		// TODO: Feed catalog statistics to the optimizer
+		optimizer: optimizer.NewOptimizer(compilerCatalog, nil),
+		cache:     NewPlanCache(cacheSize, catalog.schemaManager),
+	}
+}
+
+// Cache returns the planner's plan cache
+func (qp *QueryPlanner) Cache() *PlanCache {
+	return qp.cache
+}
+
+// Prepare returns the plan for a statement, from the cache when an
+// up-to-date plan exists
+func (qp *QueryPlanner) Prepare(sql string) (*PreparedPlan, error) {
+	normalized, err := NormalizeSQL(sql)
+	if err != nil {
+		// The parser reports the illegal token as a ParseError
+		if _, parseErr := parser.ParseSQL(sql); parseErr != nil {
+			return nil, fmt.Errorf("parsing failed: %w", parseErr)
+		}
+		return nil, fmt.Errorf("lexical analysis failed: %w", err)
+	}
+
+	if plan, found := qp.cache.Get(normalized); found {
+		return plan, nil
+	}
+
+	plan, err := qp.plan(sql, normalized)
+	if err != nil {
+		return nil, err
+	}
+
+	// Only DML plans are cached; DDL is cheap to plan and changes the
+	// schemas the cache is checked against
+	if plan.Plan != nil {
+		qp.cache.Put(plan)
+	}
+	return plan, nil
+}
+
+// Refresh returns plan if it is still current, or replans its statement
+// after a referenced table changed
+func (qp *QueryPlanner) Refresh(plan *PreparedPlan) (*PreparedPlan, error) {
+	if plan.isCurrent(qp.schemas) {
+		return plan, nil
+	}
+	return qp.Prepare(plan.source)
+}
+
+// plan builds a prepared plan for sql, cached under its normalized form.
+// The statement is parsed from sql as written, so the positions of parse,
+// compilation and semantic errors refer to the caller's text; the errors
+// are wrapped and stay reachable with errors.As.
+func (qp *QueryPlanner) plan(sql, normalized string) (*PreparedPlan, error) {
+	stmt, err := parser.ParseSQL(sql)
+	if err != nil {
+		return nil, fmt.Errorf("parsing failed: %w", err)
+	}
+
+	compiled, err := qp.compiler.Compile(stmt)
+	if err != nil {
+		// The CompilationError carries the position of the failure
+		if n := len(compiled.Errors); n > 0 {
+			err = &compiled.Errors[n-1]
+		}
+		return nil, fmt.Errorf("compilation failed: %w", err)
+	}
+
+	prepared := &PreparedPlan{
+		SQL:            normalized,
+		Statement:      stmt,
+		ParameterCount: compiled.ParameterCount,
+		Compiled:       compiled,
+		tables:         make(map[string]tableVersion),
+		source:         sql,
+	}
+
+	// Record the schema each referenced table had while planning
+	for _, table := range compiled.ResolvedRefs.Tables {
+		prepared.tables[table.Name] = currentTableVersion(qp.schemas, table.Name)
+	}
+	// Views have no schema of their own; a plan expanding one is dropped
+	// by PlanCache.Invalidate when the view changes
+	for name := range compiled.ResolvedRefs.Views {
+		prepared.tables[name] = currentTableVersion(qp.schemas, name)
+	}
+
+	if !compiled.QueryType.IsDML() {
+		return prepared, nil
+	}
+
+	info, err := qp.analyzer.Analyze(compiled)
+	if err != nil {
+		return nil, fmt.Errorf("semantic analysis failed: %w", err)
+	}
+	if info.HasErrors() {
+		return nil, fmt.Errorf("semantic analysis failed: %w", &info.Errors[0])
+	}
+
+	prepared.Plan, err = qp.optimizer.Optimize(info)
+	if err != nil {
+		return nil, fmt.Errorf("optimization failed: %w", err)
+	}
+
+	return prepared, nil
+}
@@ -0,0 +1,182 @@
+// Package executor - Row Store component
+// Persists the rows of each table in a page chain anchored at the table's rows page
+package executor
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"hash/crc32"
+
+	"relational-db/internal/storage"
+)
+
+// Rows page layout. A rows page that was never written is all zeros and
+// holds no rows.
+const (
+	rowsMagic          = "NDBROWS\x00"
+	rowsFormatVersion  = 1
+	rowsRootHeaderSize = 8 + 4 + 8 + 8 + 4 // magic, version, length, first page, checksum
+)
+
+// newRowsPage allocates the rows page of a new table and writes it empty
+func newRowsPage(engine storage.StorageEngine, pageSize int) (storage.PageID, error) {
+	pageID, err := engine.AllocatePage()
+	if err != nil {
+		return 0, fmt.Errorf("failed to allocate rows page: %w", err)
+	}
+
+	// The page may have been used before; it must not point at old pages
+	if err := writeRowsRoot(engine, pageSize, pageID, nil, nil); err != nil {
+		engine.DeallocatePage(pageID)
+		return 0, err
+	}
+	return pageID, nil
+}
+
+// writeRows replaces the rows stored under a rows page. The rows are written
+// copy-on-write to a new page chain, which a single write of the rows page
+// then commits; a crash before it leaves the previous rows in place.
+func writeRows(engine storage.StorageEngine, pageSize int, rowsPage storage.PageID, rows [][]interface{}) error {
+	_, previous, err := readRowsImage(engine, rowsPage)
+	if err != nil {
+		return err
+	}
+
+	image := encodeRows(rows)
+	chain, err := writePageChain(engine, pageSize, image)
+	if err != nil {
+		return fmt.Errorf("failed to write rows: %w", err)
+	}
+
+	if err := writeRowsRoot(engine, pageSize, rowsPage, image, chain); err != nil {
+		releasePages(engine, chain)
+		return err
+	}
+	if err := engine.Sync(); err != nil {
+		return fmt.Errorf("failed to sync rows page %d: %w", rowsPage, err)
+	}
+
+	// The old chain is unreachable now; freeing it is best effort
+	releasePages(engine, previous)
+	return nil
+}
+
+// readRows returns the rows stored under a rows page
+func readRows(engine storage.StorageEngine, rowsPage storage.PageID) ([][]interface{}, error) {
+	image, _, err := readRowsImage(engine, rowsPage)
+	if err != nil || image == nil {
+		return nil, err
+	}
+
+	rows, err := decodeRows(image)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode rows of page %d: %w", rowsPage, err)
+	}
+	return rows, nil
+}
+
+// releaseRows deallocates a rows page and the pages holding its rows
+func releaseRows(engine storage.StorageEngine, rowsPage storage.PageID) {
+	if _, chain, err := readRowsImage(engine, rowsPage); err == nil {
+		releasePages(engine, chain)
+	}
+	engine.DeallocatePage(rowsPage)
+}
+
+// writeRowsRoot points a rows page at the page chain holding image
+func writeRowsRoot(engine storage.StorageEngine, pageSize int, rowsPage storage.PageID, image []byte, chain []storage.PageID) error {
+	if pageSize < rowsRootHeaderSize {
+		return fmt.Errorf("page size %d too small for rows", pageSize)
+	}
+
+	root := make([]byte, pageSize)
+	copy(root[0:8], rowsMagic)
+	binary.LittleEndian.PutUint32(root[8:12], rowsFormatVersion)
+	binary.LittleEndian.PutUint64(root[12:20], uint64(len(image)))
+	if len(chain) > 0 {
+		binary.LittleEndian.PutUint64(root[20:28], uint64(chain[0]))
+	}
+	binary.LittleEndian.PutUint32(root[28:32], crc32.ChecksumIEEE(image))
+
+	if err := engine.WritePage(&storage.Page{ID: rowsPage, Data: root}); err != nil {
+		return fmt.Errorf("failed to write rows page %d: %w", rowsPage, err)
+	}
+	return nil
+}
+
+// readRowsImage returns the encoded rows stored under a rows page and the
+// pages holding them; a nil image if the page was never written
+func readRowsImage(engine storage.StorageEngine, rowsPage storage.PageID) ([]byte, []storage.PageID, error) {
+	root, err := engine.ReadPage(rowsPage)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to read rows page %d: %w", rowsPage, err)
+	}
+
+	if !bytes.HasPrefix(root.Data, []byte(rowsMagic)) {
+		if len(bytes.Trim(root.Data, "\x00")) == 0 {
+			return nil, nil, nil
+		}
+		return nil, nil, fmt.Errorf("page %d does not hold rows", rowsPage)
+	}
+	if len(root.Data) < rowsRootHeaderSize {
+		return nil, nil, fmt.Errorf("rows page %d is truncated", rowsPage)
+	}
+
+	version := binary.LittleEndian.Uint32(root.Data[8:12])
+	if version != rowsFormatVersion {
+		return nil, nil, fmt.Errorf("unsupported rows format version %d", version)
+	}
+
+	length := binary.LittleEndian.Uint64(root.Data[12:20])
+	first := storage.PageID(binary.LittleEndian.Uint64(root.Data[20:28]))
+	checksum := binary.LittleEndian.Uint32(root.Data[28:32])
+
+	image, chain, err := readPageChain(engine, first, length)
+	if err != nil {
+		return nil, nil, fmt.Errorf("rows of page %d: %w", rowsPage, err)
+	}
+	if crc32.ChecksumIEEE(image) != checksum {
+		return nil, nil, fmt.Errorf("rows checksum mismatch on page %d", rowsPage)
+	}
+	return image, chain, nil
+}
+
+// encodeRows serializes rows as a row count, then per row its width and
+// tagged values
+func encodeRows(rows [][]interface{}) []byte {
+	var buf bytes.Buffer
+	writeUvarint(&buf, uint64(len(rows)))
+	for _, row := range rows {
+		writeUvarint(&buf, uint64(len(row)))
+		for _, value := range row {
+			encodeCatalogValue(&buf, value)
+		}
+	}
+	return buf.Bytes()
+}
+
+// decodeRows parses rows written by encodeRows
+func decodeRows(image []byte) ([][]interface{}, error) {
+	r := bytes.NewReader(image)
+
+	count, err := binary.ReadUvarint(r)
+	if err != nil {
+		return nil, err
+	}
+	rows := make([][]interface{}, 0, count)
+	for i := uint64(0); i < count; i++ {
+		width, err := binary.ReadUvarint(r)
+		if err != nil {
+			return nil, err
+		}
+		row := make([]interface{}, width)
+		for j := range row {
+			if row[j], err = decodeCatalogValue(r); err != nil {
+				return nil, err
+			}
+		}
+		rows = append(rows, row)
+	}
+	return rows, nil
+}
@@ -1,6 +1,10 @@
 package executor
 
 import (
+	"fmt"
+
+	"relational-db/internal/compiler"
+	"relational-db/internal/optimizer"
 	"relational-db/internal/parser"
 )
 
@@ -9,6 +13,9 @@ type SeqScanOperator struct {
 	tableName  string
 	filter     parser.Expression
 	schema     *TupleSchema
+	rows       *ClusteredIndex // holds the table's rows; nothing is read if nil
+	iterator   *ClusteredIndexIterator
+	evaluator  *ExpressionEvaluator
 	closed     bool
 	tuplesRead int64
 }
@@ -18,6 +25,7 @@ func NewSeqScanOperator(tableName string, filter parser.Expression) *SeqScanOper
 	return &SeqScanOperator{
 		tableName: tableName,
 		filter:    filter,
+		evaluator: NewExpressionEvaluator(),
 		closed:    true,
 	}
 }
@@ -28,10 +36,11 @@ func (op *SeqScanOperator) Open(ctx *ExecutionContext) error {
 		return nil // Already open
 	}
 
-	// TODO: Initialize scan state
-	// - Get table schema from catalog
-	// - Initialize page iterator
-	// - Set up tuple reader
+	op.evaluator.Bind(ctx)
+	if op.rows != nil {
+		op.schema = op.rows.Schema()
+		op.iterator = op.rows.Scan(nil, nil)
+	}
 
 	op.closed = false
 	return nil
@@ -43,12 +52,27 @@ func (op *SeqScanOperator) Next() (*Tuple, error) {
 		return nil, ErrOperatorClosed
 	}
 
-	// TODO: Implement sequential scan logic
-	// 1. Read next tuple from storage
-	// 2. Apply filter if present
-	// 3. Return tuple or nil if EOF
+	for op.iterator != nil {
+		row := op.iterator.Next()
+		if row == nil {
+			break
+		}
+		op.tuplesRead++
 
-	return nil, nil // EOF for now
+		tuple := row.Clone()
+		if op.filter != nil {
+			holds, err := conditionHolds(op.evaluator, op.filter, tuple)
+			if err != nil {
+				return nil, err
+			}
+			if !holds {
+				continue
+			}
+		}
+		return tuple, nil
+	}
+
+	return nil, nil // EOF
 }
 
 // Close releases resources
@@ -57,7 +81,7 @@ func (op *SeqScanOperator) Close() error {
 		return nil
 	}
 
-	// TODO: Cleanup scan state
+	op.iterator = nil
 	op.closed = true
 	return nil
 }
@@ -143,6 +167,532 @@ func (op *IndexScanOperator) EstimatedCost() float64 {
 	return float64(op.tuplesRead) * 4.0 // Random I/O more expensive
 }
 
+// ClusteredIndexScanOperator reads a clustered table in primary key order
+type ClusteredIndexScanOperator struct {
+	index      *ClusteredIndex
+	lower      *KeyBound
+	upper      *KeyBound
+	iterator   *ClusteredIndexIterator
+	closed     bool
+	tuplesRead int64
+}
+
+// NewClusteredIndexScanOperator creates a scan over the rows of a clustered
+// table whose primary key lies between lower and upper (nil for open ends)
+func NewClusteredIndexScanOperator(index *ClusteredIndex, lower, upper *KeyBound) *ClusteredIndexScanOperator {
+	return &ClusteredIndexScanOperator{
+		index:  index,
+		lower:  lower,
+		upper:  upper,
+		closed: true,
+	}
+}
+
+// Open initializes the operator
+func (op *ClusteredIndexScanOperator) Open(ctx *ExecutionContext) error {
+	if !op.closed {
+		return nil
+	}
+
+	// The rows in range as they are now; later changes are not seen
+	op.iterator = op.index.Scan(op.lower, op.upper)
+	op.tuplesRead = 0
+
+	op.closed = false
+	return nil
+}
+
+// Next returns the next tuple in primary key order
+func (op *ClusteredIndexScanOperator) Next() (*Tuple, error) {
+	if op.closed {
+		return nil, ErrOperatorClosed
+	}
+
+	row := op.iterator.Next()
+	if row == nil {
+		return nil, nil // EOF
+	}
+
+	op.tuplesRead++
+	return row.Clone(), nil
+}
+
+// Close releases resources
+func (op *ClusteredIndexScanOperator) Close() error {
+	if op.closed {
+		return nil
+	}
+
+	op.iterator = nil
+	op.closed = true
+	return nil
+}
+
+// OperatorType returns the operator type
+func (op *ClusteredIndexScanOperator) OperatorType() string {
+	return "ClusteredIndexScan"
+}
+
+// EstimatedCost returns estimated cost
+func (op *ClusteredIndexScanOperator) EstimatedCost() float64 {
+	return float64(op.tuplesRead) * 1.0 // Leaf chain is read sequentially
+}
+
+// AppendOperator returns the rows of each child in turn. It scans the
+// partitions of a partitioned table and computes UNION ALL.
+type AppendOperator struct {
+	children []PhysicalOperator
+	current  int
+	ctx      *ExecutionContext
+	closed   bool
+}
+
+// NewAppendOperator creates an operator that concatenates its children
+func NewAppendOperator(children []PhysicalOperator) *AppendOperator {
+	return &AppendOperator{
+		children: children,
+		closed:   true,
+	}
+}
+
+// Open initializes the operator. Children are opened as they are reached.
+func (op *AppendOperator) Open(ctx *ExecutionContext) error {
+	if !op.closed {
+		return nil
+	}
+
+	op.ctx = ctx
+	op.current = 0
+	if len(op.children) > 0 {
+		if err := op.children[0].Open(ctx); err != nil {
+			return err
+		}
+	}
+
+	op.closed = false
+	return nil
+}
+
+// Next returns the next tuple from the current child
+func (op *AppendOperator) Next() (*Tuple, error) {
+	if op.closed {
+		return nil, ErrOperatorClosed
+	}
+
+	for op.current < len(op.children) {
+		tuple, err := op.children[op.current].Next()
+		if err != nil || tuple != nil {
+			return tuple, err
+		}
+
+		// Child exhausted: move on to the next one
+		if err := op.children[op.current].Close(); err != nil {
+			return nil, err
+		}
+		op.current++
+		if op.current < len(op.children) {
+			if err := op.children[op.current].Open(op.ctx); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	return nil, nil // EOF
+}
+
+// Close releases resources
+func (op *AppendOperator) Close() error {
+	if op.closed {
+		return nil
+	}
+
+	if op.current < len(op.children) {
+		op.children[op.current].Close()
+	}
+	op.closed = true
+	return nil
+}
+
+// OperatorType returns the operator type
+func (op *AppendOperator) OperatorType() string {
+	return "Append"
+}
+
+// EstimatedCost returns estimated cost
+func (op *AppendOperator) EstimatedCost() float64 {
+	cost := 0.0
+	for _, child := range op.children {
+		cost += child.EstimatedCost()
+	}
+	return cost
+}
+
+// SingleRowOperator returns one row without columns, which a query
+// without FROM computes its select list from
+type SingleRowOperator struct {
+	done   bool
+	closed bool
+}
+
+// NewSingleRowOperator creates a single row operator
+func NewSingleRowOperator() *SingleRowOperator {
+	return &SingleRowOperator{closed: true}
+}
+
+// Open initializes the operator
+func (op *SingleRowOperator) Open(ctx *ExecutionContext) error {
+	op.done = false
+	op.closed = false
+	return nil
+}
+
+// Next returns the row on the first call and EOF after it
+func (op *SingleRowOperator) Next() (*Tuple, error) {
+	if op.closed {
+		return nil, ErrOperatorClosed
+	}
+	if op.done {
+		return nil, nil // EOF
+	}
+	op.done = true
+	return NewTuple(NewTupleSchema(nil), nil), nil
+}
+
+// Close releases resources
+func (op *SingleRowOperator) Close() error {
+	op.closed = true
+	return nil
+}
+
+// OperatorType returns the operator type
+func (op *SingleRowOperator) OperatorType() string {
+	return "SingleRow"
+}
+
+// EstimatedCost returns estimated cost
+func (op *SingleRowOperator) EstimatedCost() float64 {
+	return 0
+}
+
+// SubqueryScanOperator reads the rows of a derived table, the subquery in
+// FROM (SELECT ...) AS alias, qualifying its columns with the alias
+type SubqueryScanOperator struct {
+	child  PhysicalOperator
+	schema *aliasedSchema
+	closed bool
+}
+
+// NewSubqueryScanOperator creates a scan over a derived table. Columns, when
+// given, rename the subquery's columns in order.
+func NewSubqueryScanOperator(child PhysicalOperator, alias string, columns []string) *SubqueryScanOperator {
+	return &SubqueryScanOperator{
+		child:  child,
+		schema: &aliasedSchema{alias: alias, columns: columns},
+		closed: true,
+	}
+}
+
+// Open initializes the operator
+func (op *SubqueryScanOperator) Open(ctx *ExecutionContext) error {
+	if !op.closed {
+		return nil
+	}
+
+	if err := op.child.Open(ctx); err != nil {
+		return err
+	}
+
+	op.closed = false
+	return nil
+}
+
+// Next returns the next row of the derived table
+func (op *SubqueryScanOperator) Next() (*Tuple, error) {
+	if op.closed {
+		return nil, ErrOperatorClosed
+	}
+
+	tuple, err := op.child.Next()
+	if err != nil || tuple == nil {
+		return tuple, err
+	}
+	return op.schema.apply(tuple), nil
+}
+
+// Close releases resources
+func (op *SubqueryScanOperator) Close() error {
+	if op.closed {
+		return nil
+	}
+
+	err := op.child.Close()
+	op.closed = true
+	return err
+}
+
+// OperatorType returns the operator type
+func (op *SubqueryScanOperator) OperatorType() string {
+	return "SubqueryScan"
+}
+
+// EstimatedCost returns estimated cost
+func (op *SubqueryScanOperator) EstimatedCost() float64 {
+	return op.child.EstimatedCost()
+}
+
+// CTEScanOperator reads the rows of a materialized common table expression,
+// computing them on the first scan of the query execution
+type CTEScanOperator struct {
+	cte    *parser.CommonTableExpression
+	schema *aliasedSchema
+	rows   *ResultSet
+	pos    int
+	closed bool
+}
+
+// NewCTEScanOperator creates a scan over a materialized CTE referenced as
+// alias. Columns are the names the CTE lists for its columns, if any.
+func NewCTEScanOperator(cte *parser.CommonTableExpression, alias string, columns []string) *CTEScanOperator {
+	return &CTEScanOperator{
+		cte:    cte,
+		schema: &aliasedSchema{alias: alias, columns: columns},
+		closed: true,
+	}
+}
+
+// Open initializes the operator
+func (op *CTEScanOperator) Open(ctx *ExecutionContext) error {
+	if !op.closed {
+		return nil
+	}
+	if ctx.ctes == nil {
+		return ErrCTENotPlanned
+	}
+
+	rows, err := ctx.ctes.materialize(ctx, op.cte)
+	if err != nil {
+		return err
+	}
+
+	op.rows = rows
+	op.pos = 0
+	op.closed = false
+	return nil
+}
+
+// Next returns the next row of the CTE
+func (op *CTEScanOperator) Next() (*Tuple, error) {
+	if op.closed {
+		return nil, ErrOperatorClosed
+	}
+	if op.pos >= op.rows.RowCount() {
+		return nil, nil
+	}
+
+	tuple := op.rows.Tuples[op.pos]
+	op.pos++
+	return op.schema.apply(tuple), nil
+}
+
+// Close releases resources
+func (op *CTEScanOperator) Close() error {
+	op.rows = nil
+	op.closed = true
+	return nil
+}
+
+// OperatorType returns the operator type
+func (op *CTEScanOperator) OperatorType() string {
+	return "CTEScan"
+}
+
+// EstimatedCost returns estimated cost
+func (op *CTEScanOperator) EstimatedCost() float64 {
+	return float64(op.pos) * 0.1 // Rows are already in memory
+}
+
+// WorkTableScanOperator reads, in the recursive term of a recursive CTE, the
+// rows the previous iteration produced
+type WorkTableScanOperator struct {
+	cte    *parser.CommonTableExpression
+	schema *aliasedSchema
+	rows   *ResultSet
+	pos    int
+	closed bool
+}
+
+// NewWorkTableScanOperator creates a scan over a recursive CTE's work table
+func NewWorkTableScanOperator(cte *parser.CommonTableExpression, alias string, columns []string) *WorkTableScanOperator {
+	return &WorkTableScanOperator{
+		cte:    cte,
+		schema: &aliasedSchema{alias: alias, columns: columns},
+		closed: true,
+	}
+}
+
+// Open initializes the operator. It is opened again for every iteration.
+func (op *WorkTableScanOperator) Open(ctx *ExecutionContext) error {
+	if !op.closed {
+		return nil
+	}
+
+	op.rows = NewResultSet()
+	if ctx.ctes != nil {
+		if rows, found := ctx.ctes.workTables[op.cte]; found {
+			op.rows = rows
+		}
+	}
+	op.pos = 0
+	op.closed = false
+	return nil
+}
+
+// Next returns the next row of the previous iteration
+func (op *WorkTableScanOperator) Next() (*Tuple, error) {
+	if op.closed {
+		return nil, ErrOperatorClosed
+	}
+	if op.pos >= op.rows.RowCount() {
+		return nil, nil
+	}
+
+	tuple := op.rows.Tuples[op.pos]
+	op.pos++
+	return op.schema.apply(tuple), nil
+}
+
+// Close releases resources
+func (op *WorkTableScanOperator) Close() error {
+	op.rows = nil
+	op.closed = true
+	return nil
+}
+
+// OperatorType returns the operator type
+func (op *WorkTableScanOperator) OperatorType() string {
+	return "WorkTableScan"
+}
+
+// EstimatedCost returns estimated cost
+func (op *WorkTableScanOperator) EstimatedCost() float64 {
+	return float64(op.pos) * 0.1 // Rows are already in memory
+}
+
+// MaterializedViewScanOperator reads the rows of a materialized view as of
+// its last refresh
+type MaterializedViewScanOperator struct {
+	viewName string
+	view     *materializedView
+	rows     *ResultSet
+	pos      int
+	closed   bool
+}
+
+// NewMaterializedViewScanOperator creates a scan over a materialized view
+func NewMaterializedViewScanOperator(viewName string, view *materializedView) *MaterializedViewScanOperator {
+	return &MaterializedViewScanOperator{
+		viewName: viewName,
+		view:     view,
+		closed:   true,
+	}
+}
+
+// Open initializes the operator. The rows are those of the refresh that
+// finished last; a refresh that is not concurrent is waited for.
+func (op *MaterializedViewScanOperator) Open(ctx *ExecutionContext) error {
+	if !op.closed {
+		return nil
+	}
+
+	rows, populated := op.view.snapshot()
+	if !populated {
+		return fmt.Errorf("%s: %w", op.viewName, ErrViewNotPopulated)
+	}
+
+	op.rows = rows
+	op.pos = 0
+	op.closed = false
+	return nil
+}
+
+// Next returns the next row of the view
+func (op *MaterializedViewScanOperator) Next() (*Tuple, error) {
+	if op.closed {
+		return nil, ErrOperatorClosed
+	}
+	if op.pos >= op.rows.RowCount() {
+		return nil, nil
+	}
+
+	tuple := op.rows.Tuples[op.pos]
+	op.pos++
+	return tuple, nil
+}
+
+// Close releases resources
+func (op *MaterializedViewScanOperator) Close() error {
+	op.rows = nil
+	op.closed = true
+	return nil
+}
+
+// OperatorType returns the operator type
+func (op *MaterializedViewScanOperator) OperatorType() string {
+	return "MaterializedViewScan"
+}
+
+// EstimatedCost returns estimated cost
+func (op *MaterializedViewScanOperator) EstimatedCost() float64 {
+	return float64(op.pos) * 0.1 // Rows are already in memory
+}
+
+// aliasedSchema gives rows read from a derived table or CTE the name the
+// query refers to it by and, when the CTE lists them, its column names
+type aliasedSchema struct {
+	alias   string
+	columns []string
+	schema  *TupleSchema
+	source  *TupleSchema // input schema the output schema was built from
+}
+
+// apply returns tuple with its columns qualified by the alias and renamed
+func (as *aliasedSchema) apply(tuple *Tuple) *Tuple {
+	if tuple.Schema != as.source || as.schema == nil {
+		columns := make([]ColumnInfo, 0)
+		if tuple.Schema != nil {
+			columns = make([]ColumnInfo, len(tuple.Schema.Columns))
+			copy(columns, tuple.Schema.Columns)
+		}
+		for i := range columns {
+			columns[i].TableName = as.alias
+			if i < len(as.columns) {
+				columns[i].Name = as.columns[i]
+			}
+		}
+		as.schema = NewTupleSchema(columns)
+		as.source = tuple.Schema
+	}
+
+	return NewTuple(as.schema, tuple.Values)
+}
+
+// keyBoundsFromRange converts an optimizer key range on the leading primary
+// key column into clustered index scan bounds
+func keyBoundsFromRange(keyRange *optimizer.KeyRange) (*KeyBound, *KeyBound) {
+	if keyRange == nil {
+		return nil, nil
+	}
+
+	var lower, upper *KeyBound
+	if keyRange.Lower != nil {
+		lower = &KeyBound{Key: []interface{}{keyRange.Lower}, Inclusive: keyRange.LowerInclusive}
+	}
+	if keyRange.Upper != nil {
+		upper = &KeyBound{Key: []interface{}{keyRange.Upper}, Inclusive: keyRange.UpperInclusive}
+	}
+	return lower, upper
+}
+
 // FilterOperator filters tuples based on predicate
 type FilterOperator struct {
 	child      PhysicalOperator
@@ -169,6 +719,8 @@ func (op *FilterOperator) Open(ctx *ExecutionContext) error {
 		return nil
 	}
 
+	op.evaluator.Bind(ctx)
+
 	if err := op.child.Open(ctx); err != nil {
 		return err
 	}
@@ -232,12 +784,14 @@ func (op *FilterOperator) EstimatedCost() float64 {
 	return op.child.EstimatedCost() + float64(op.tuplesRead)*0.01
 }
 
-// ProjectOperator projects specific columns
+// ProjectOperator computes the select list of a query for each input row.
+// A * stands for all input columns and t.* for those of table t.
 type ProjectOperator struct {
 	child          PhysicalOperator
 	projectionList []parser.Expression
 	evaluator      *ExpressionEvaluator
 	outputSchema   *TupleSchema
+	inputSchema    *TupleSchema // input schema the output schema was built from
 	closed         bool
 }
 
@@ -257,11 +811,13 @@ func (op *ProjectOperator) Open(ctx *ExecutionContext) error {
 		return nil
 	}
 
+	op.evaluator.Bind(ctx)
+
 	if err := op.child.Open(ctx); err != nil {
 		return err
 	}
 
-	// TODO: Build output schema from projection list
+	op.outputSchema, op.inputSchema = nil, nil
 	op.closed = false
 	return nil
 }
@@ -281,9 +837,76 @@ func (op *ProjectOperator) Next() (*Tuple, error) {
 		return nil, nil // EOF
 	}
 
-	// TODO: Evaluate projection expressions and build output tuple
-	// For now, just return input tuple
-	return tuple, nil
+	var columns []ColumnInfo
+	var values []interface{}
+	for idx, expr := range op.projectionList {
+		if wildcard, ok := expr.(*parser.Wildcard); ok {
+			cols, vals := expandWildcard(wildcard, tuple)
+			columns = append(columns, cols...)
+			values = append(values, vals...)
+			continue
+		}
+
+		value, err := op.evaluator.Evaluate(expr, tuple)
+		if err != nil {
+			return nil, err
+		}
+		values = append(values, value)
+		columns = append(columns, projectedColumn(expr, idx, tuple.Schema))
+	}
+
+	// Rows of one input schema share one output schema
+	if op.outputSchema == nil || tuple.Schema != op.inputSchema {
+		op.outputSchema = NewTupleSchema(columns)
+		op.inputSchema = tuple.Schema
+	}
+	return NewTuple(op.outputSchema, values), nil
+}
+
+// expandWildcard returns the columns and values of a row a * or t.* in a
+// select list stands for
+func expandWildcard(wildcard *parser.Wildcard, tuple *Tuple) ([]ColumnInfo, []interface{}) {
+	if tuple.Schema == nil {
+		return nil, nil
+	}
+
+	var columns []ColumnInfo
+	var values []interface{}
+	for idx, col := range tuple.Schema.Columns {
+		if idx >= len(tuple.Values) {
+			break
+		}
+		if wildcard.Table != nil && col.TableName != wildcard.Table.Value {
+			continue
+		}
+		columns = append(columns, col)
+		values = append(values, tuple.Values[idx])
+	}
+	return columns, values
+}
+
+// projectedColumn describes the output column of the idx-th select list
+// entry. A column read as is keeps its type; computed values are untyped.
+func projectedColumn(expr parser.Expression, idx int, input *TupleSchema) ColumnInfo {
+	column := ColumnInfo{
+		Name:     compiler.SelectColumnName(expr, idx),
+		Type:     TypeNull,
+		Nullable: true,
+	}
+
+	var source string
+	switch e := expr.(type) {
+	case *parser.Identifier:
+		source = e.Value
+	case *parser.ColumnReference:
+		source = e.Column.Value
+	}
+	if input != nil && source != "" {
+		if col, ok := input.GetColumn(source); ok {
+			column.Type, column.Nullable = col.Type, col.Nullable
+		}
+	}
+	return column
 }
 
 // Close releases resources
@@ -315,6 +938,12 @@ type LimitOperator struct {
 	count   int64
 	skipped int64
 	closed  bool
+
+	// LIMIT and OFFSET of a query, evaluated when the operator is opened
+	// since they may be parameters. A LIMIT clause's count bounds the rows
+	// even when it is 0.
+	clause  *parser.LimitClause
+	bounded bool
 }
 
 // NewLimitOperator creates a new limit operator
@@ -327,12 +956,36 @@ func NewLimitOperator(child PhysicalOperator, limit, offset int64) *LimitOperato
 	}
 }
 
+// NewLimitClauseOperator creates a limit operator returning the rows a
+// query's LIMIT and OFFSET select
+func NewLimitClauseOperator(child PhysicalOperator, clause *parser.LimitClause) *LimitOperator {
+	return &LimitOperator{
+		child:  child,
+		clause: clause,
+		closed: true,
+	}
+}
+
 // Open initializes the operator
 func (op *LimitOperator) Open(ctx *ExecutionContext) error {
 	if !op.closed {
 		return nil
 	}
 
+	if op.clause != nil {
+		evaluator := NewExpressionEvaluator()
+		evaluator.Bind(ctx)
+
+		var err error
+		op.bounded = op.clause.Count != nil
+		if op.limit, err = limitValue(evaluator, "LIMIT", op.clause.Count); err != nil {
+			return err
+		}
+		if op.offset, err = limitValue(evaluator, "OFFSET", op.clause.Offset); err != nil {
+			return err
+		}
+	}
+
 	if err := op.child.Open(ctx); err != nil {
 		return err
 	}
@@ -362,7 +1015,7 @@ func (op *LimitOperator) Next() (*Tuple, error) {
 	}
 
 	// Check limit
-	if op.limit > 0 && op.count >= op.limit {
+	if (op.limit > 0 || op.bounded) && op.count >= op.limit {
 		return nil, nil // Limit reached
 	}
 
@@ -378,6 +1031,22 @@ func (op *LimitOperator) Next() (*Tuple, error) {
 	return tuple, nil
 }
 
+// limitValue evaluates the row count of a LIMIT or OFFSET, 0 if absent
+func limitValue(evaluator *ExpressionEvaluator, clause string, expr parser.Expression) (int64, error) {
+	if expr == nil {
+		return 0, nil
+	}
+	value, err := evaluator.Evaluate(expr, nil)
+	if err != nil {
+		return 0, err
+	}
+	count, ok := toFloat64(value)
+	if !ok || count < 0 || count != float64(int64(count)) {
+		return 0, fmt.Errorf("%s must be a non-negative integer, got %v", clause, value)
+	}
+	return int64(count), nil
+}
+
 // Close releases resources
 func (op *LimitOperator) Close() error {
 	if op.closed {
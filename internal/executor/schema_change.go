@@ -0,0 +1,450 @@
+// Package executor - Schema change component
+// Applies ALTER TABLE statements to table schemas and upgrades the rows stored under them
+package executor
+
+import (
+	"fmt"
+	"strings"
+
+	"relational-db/internal/compiler"
+	"relational-db/internal/parser"
+	"relational-db/internal/storage"
+)
+
+// SchemaChange is the result of applying an ALTER TABLE statement to a table:
+// the schema and constraints before and after, and how a row stored under the
+// previous schema maps onto the new one.
+type SchemaChange struct {
+	Previous    *TableSchema
+	Schema      *TableSchema
+	Constraints []*Constraint
+
+	// sources[i] is the position in a previous row of new column i, or -1
+	// for an added column, which takes defaults[i]
+	sources  []int
+	defaults []interface{}
+
+	// CHECK and UNIQUE constraints added by the statement, which existing
+	// rows must satisfy
+	checks []parser.Expression
+	unique [][]string
+
+	// Page the rows rewritten to the new schema were stored under, 0 if
+	// they were not stored
+	rowsPage storage.PageID
+}
+
+// Renamed reports whether the statement renamed the table
+func (c *SchemaChange) Renamed() bool {
+	return c.Previous.TableName != c.Schema.TableName
+}
+
+// RewritesRows reports whether stored rows change shape, that is whether a
+// column was added or dropped
+func (c *SchemaChange) RewritesRows() bool {
+	if len(c.sources) != len(c.Previous.Columns) {
+		return true
+	}
+	for i, source := range c.sources {
+		if source != i {
+			return true
+		}
+	}
+	return false
+}
+
+// UpgradeRow converts a row stored under the previous schema to the new one.
+// Added columns take their default, or NULL when they have none.
+func (c *SchemaChange) UpgradeRow(values []interface{}) ([]interface{}, error) {
+	if len(values) != len(c.Previous.Columns) {
+		return nil, fmt.Errorf("expected %d values, got %d", len(c.Previous.Columns), len(values))
+	}
+
+	row := make([]interface{}, len(c.sources))
+	for i, source := range c.sources {
+		if source < 0 {
+			row[i] = c.defaults[i]
+		} else {
+			row[i] = values[source]
+		}
+		if row[i] == nil && !c.Schema.Columns[i].Nullable {
+			return nil, fmt.Errorf("%w: column %s of table %s cannot be NULL",
+				ErrNullValue, c.Schema.Columns[i].Name, c.Schema.TableName)
+		}
+	}
+	return row, nil
+}
+
+// checkRow verifies that an upgraded row satisfies the CHECK constraints
+// added by the statement. A CHECK that evaluates to NULL is satisfied.
+func (c *SchemaChange) checkRow(evaluator *ExpressionEvaluator, tuple *Tuple) error {
+	for _, check := range c.checks {
+		result, err := evaluator.Evaluate(check, tuple)
+		if err != nil {
+			return err
+		}
+		if result == false {
+			return fmt.Errorf("row violates check constraint %s on table %s", check.String(), c.Schema.TableName)
+		}
+	}
+	return nil
+}
+
+// AlterSchema applies the actions of an ALTER TABLE statement to a copy of
+// schema and its constraints. The originals are left untouched so the caller
+// can hand the change to SystemCatalog.AlterTable.
+func AlterSchema(schema *TableSchema, constraints []*Constraint, stmt *parser.AlterTableStatement) (*SchemaChange, error) {
+	if schema == nil || stmt == nil {
+		return nil, fmt.Errorf("schema and statement cannot be nil")
+	}
+
+	altered := *schema
+	altered.Columns = append([]ColumnInfo{}, schema.Columns...)
+	altered.PrimaryKey = append([]string{}, schema.PrimaryKey...)
+	altered.ForeignKeys = make([]*ForeignKey, 0, len(schema.ForeignKeys))
+	for _, fk := range schema.ForeignKeys {
+		copied := *fk
+		copied.Columns = append([]string{}, fk.Columns...)
+		copied.RefColumns = append([]string{}, fk.RefColumns...)
+		altered.ForeignKeys = append(altered.ForeignKeys, &copied)
+	}
+	altered.Indexes = make([]*IndexInfo, 0, len(schema.Indexes))
+	for _, index := range schema.Indexes {
+		copied := *index
+		copied.Columns = append([]string{}, index.Columns...)
+		altered.Indexes = append(altered.Indexes, &copied)
+	}
+	if schema.TTL != nil {
+		ttl := *schema.TTL
+		altered.TTL = &ttl
+	}
+
+	change := &SchemaChange{
+		Previous:    schema,
+		Schema:      &altered,
+		Constraints: make([]*Constraint, 0, len(constraints)),
+		sources:     make([]int, len(schema.Columns)),
+		defaults:    make([]interface{}, len(schema.Columns)),
+	}
+	for _, constraint := range constraints {
+		copied := *constraint
+		copied.Columns = append([]string{}, constraint.Columns...)
+		change.Constraints = append(change.Constraints, &copied)
+	}
+	for i := range change.sources {
+		change.sources[i] = i
+	}
+
+	for _, action := range stmt.Actions {
+		var err error
+		switch action := action.(type) {
+		case *parser.SetTableOptionsAction:
+			err = applyTableOptions(change.Schema, action.Options)
+		case *parser.ResetTableOptionsAction:
+			for _, name := range action.Names {
+				switch strings.ToLower(name.Value) {
+				case compiler.TTLColumnOption, compiler.TTLOption:
+					change.Schema.TTL = nil
+				default:
+					return nil, fmt.Errorf("cannot reset table option %s", name.Value)
+				}
+			}
+		case *parser.AddColumnAction:
+			err = change.addColumn(action.Column)
+		case *parser.DropColumnAction:
+			err = change.dropColumn(action.Column.Value)
+		case *parser.RenameColumnAction:
+			err = change.renameColumn(action.Column.Value, action.NewName.Value)
+		case *parser.RenameTableAction:
+			change.renameTable(action.NewName.Value)
+		case *parser.AddConstraintAction:
+			err = change.addConstraint(action.Constraint)
+		case *parser.DropConstraintAction:
+			err = change.dropConstraint(action.Name.Value)
+		default:
+			err = fmt.Errorf("unsupported ALTER TABLE action: %s", action.NodeType())
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return change, nil
+}
+
+// columnIndex returns the position of a column in the altered schema, or -1
+func (c *SchemaChange) columnIndex(name string) int {
+	for i, col := range c.Schema.Columns {
+		if col.Name == name {
+			return i
+		}
+	}
+	return -1
+}
+
+// addColumn appends a column. Its constant DEFAULT is evaluated once and
+// stored into every existing row.
+func (c *SchemaChange) addColumn(def *parser.ColumnDefinition) error {
+	name := def.Name.Value
+	if c.columnIndex(name) >= 0 {
+		return fmt.Errorf("column %s already exists in table %s", name, c.Schema.TableName)
+	}
+
+	colType, err := columnTypeFromDataType(def.DataType)
+	if err != nil {
+		return err
+	}
+
+	value, err := columnDefault(def)
+	if err != nil {
+		return err
+	}
+	column := ColumnInfo{Name: name, Type: colType, Nullable: true, TableName: c.Schema.TableName, Default: value}
+	for _, constraint := range def.Constraints {
+		switch constraint.Type {
+		case parser.NotNull:
+			column.Nullable = false
+		case parser.PrimaryKey:
+			return fmt.Errorf("cannot add primary key column %s to existing table %s", name, c.Schema.TableName)
+		}
+	}
+
+	c.Schema.Columns = append(c.Schema.Columns, column)
+	c.sources = append(c.sources, -1)
+	c.defaults = append(c.defaults, value)
+
+	// Inline UNIQUE and REFERENCES become table constraints on the new column
+	for _, constraint := range def.Constraints {
+		if constraint.Type != parser.UniqueKey && constraint.Type != parser.ForeignKey {
+			continue
+		}
+		tableConstraint := &parser.TableConstraint{
+			Type:       constraint.Type,
+			Name:       constraint.Name,
+			Columns:    []*parser.Identifier{def.Name},
+			References: constraint.References,
+		}
+		if err := c.addConstraint(tableConstraint); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// dropColumn removes a column from the schema and from stored rows. A
+// column still used by a key, index, constraint or row expiry cannot be
+// dropped.
+func (c *SchemaChange) dropColumn(name string) error {
+	tableName := c.Schema.TableName
+	idx := c.columnIndex(name)
+	switch {
+	case idx < 0:
+		return fmt.Errorf("column %s not found in table %s", name, tableName)
+	case len(c.Schema.Columns) == 1:
+		return fmt.Errorf("cannot drop the only column of table %s", tableName)
+	case containsColumn(c.Schema.PrimaryKey, name):
+		return fmt.Errorf("cannot drop primary key column %s of table %s", name, tableName)
+	case c.Schema.TTL != nil && c.Schema.TTL.Column == name:
+		return fmt.Errorf("cannot drop column %s used as %s of table %s", name, compiler.TTLColumnOption, tableName)
+	}
+	for _, fk := range c.Schema.ForeignKeys {
+		if containsColumn(fk.Columns, name) {
+			return fmt.Errorf("cannot drop column %s of table %s used by foreign key %s", name, tableName, fk.Name)
+		}
+	}
+	for _, index := range c.Schema.Indexes {
+		if containsColumn(index.Columns, name) {
+			return fmt.Errorf("cannot drop column %s of table %s used by index %s", name, tableName, index.Name)
+		}
+	}
+	for _, constraint := range c.Constraints {
+		if containsColumn(constraint.Columns, name) {
+			return fmt.Errorf("cannot drop column %s of table %s used by constraint %s", name, tableName, constraint.Name)
+		}
+	}
+
+	if c.Schema.AutoIncrement == name {
+		c.Schema.AutoIncrement = ""
+	}
+	c.Schema.Columns = append(c.Schema.Columns[:idx:idx], c.Schema.Columns[idx+1:]...)
+	c.sources = append(c.sources[:idx:idx], c.sources[idx+1:]...)
+	c.defaults = append(c.defaults[:idx:idx], c.defaults[idx+1:]...)
+	return nil
+}
+
+// renameColumn renames a column everywhere the schema refers to it. Stored
+// rows are positional and stay as they are.
+func (c *SchemaChange) renameColumn(name, newName string) error {
+	idx := c.columnIndex(name)
+	if idx < 0 {
+		return fmt.Errorf("column %s not found in table %s", name, c.Schema.TableName)
+	}
+	if newName != name && c.columnIndex(newName) >= 0 {
+		return fmt.Errorf("column %s already exists in table %s", newName, c.Schema.TableName)
+	}
+
+	c.Schema.Columns[idx].Name = newName
+	renameColumn(c.Schema.PrimaryKey, name, newName)
+	for _, fk := range c.Schema.ForeignKeys {
+		renameColumn(fk.Columns, name, newName)
+	}
+	for _, index := range c.Schema.Indexes {
+		renameColumn(index.Columns, name, newName)
+	}
+	for _, constraint := range c.Constraints {
+		renameColumn(constraint.Columns, name, newName)
+	}
+	if c.Schema.TTL != nil && c.Schema.TTL.Column == name {
+		c.Schema.TTL.Column = newName
+	}
+	if c.Schema.AutoIncrement == name {
+		c.Schema.AutoIncrement = newName
+	}
+	return nil
+}
+
+// renameTable gives the table a new name
+func (c *SchemaChange) renameTable(newName string) {
+	c.Schema.TableName = newName
+	for i := range c.Schema.Columns {
+		c.Schema.Columns[i].TableName = newName
+	}
+}
+
+// addConstraint adds a table constraint. Unnamed constraints are named
+// table_columns_suffix, as PostgreSQL does.
+func (c *SchemaChange) addConstraint(def *parser.TableConstraint) error {
+	tableName := c.Schema.TableName
+	columns := make([]string, len(def.Columns))
+	for i, col := range def.Columns {
+		if c.columnIndex(col.Value) < 0 {
+			return fmt.Errorf("column %s not found in table %s", col.Value, tableName)
+		}
+		columns[i] = col.Value
+	}
+
+	constraint := &Constraint{Columns: columns}
+	var suffix string
+	switch def.Type {
+	case parser.PrimaryKey:
+		if len(c.Schema.PrimaryKey) > 0 {
+			return fmt.Errorf("table %s already has a primary key", tableName)
+		}
+		constraint.Type, suffix = PrimaryKeyConstraint, "pkey"
+	case parser.UniqueKey:
+		constraint.Type, suffix = UniqueConstraint, "key"
+	case parser.ForeignKey:
+		if def.References == nil {
+			return fmt.Errorf("foreign key on table %s has no REFERENCES clause", tableName)
+		}
+		constraint.Type, suffix = ForeignKeyConstraint, "fkey"
+	case parser.Check:
+		constraint.Type, suffix = CheckConstraint, "check"
+		constraint.CheckExpression = def.Check.String()
+	default:
+		return fmt.Errorf("unsupported constraint type in ALTER TABLE on table %s", tableName)
+	}
+
+	if def.Name != nil {
+		constraint.Name = def.Name.Value
+		if c.hasConstraint(constraint.Name) {
+			return fmt.Errorf("constraint %s already exists on table %s", constraint.Name, tableName)
+		}
+	} else {
+		base := strings.Join(append(append([]string{tableName}, columns...), suffix), "_")
+		constraint.Name = base
+		for n := 1; c.hasConstraint(constraint.Name); n++ {
+			constraint.Name = fmt.Sprintf("%s%d", base, n)
+		}
+	}
+
+	switch def.Type {
+	case parser.PrimaryKey:
+		c.Schema.PrimaryKey = columns
+		c.unique = append(c.unique, columns)
+	case parser.UniqueKey:
+		c.unique = append(c.unique, columns)
+	case parser.ForeignKey:
+		refColumns := make([]string, len(def.References.Columns))
+		for i, col := range def.References.Columns {
+			refColumns[i] = col.Value
+		}
+		c.Schema.ForeignKeys = append(c.Schema.ForeignKeys, &ForeignKey{
+			Name:       constraint.Name,
+			Columns:    columns,
+			RefTable:   def.References.Table.Value,
+			RefColumns: refColumns,
+		})
+	case parser.Check:
+		c.checks = append(c.checks, def.Check)
+	}
+
+	c.Constraints = append(c.Constraints, constraint)
+	return nil
+}
+
+// dropConstraint removes a named constraint, along with the primary key or
+// foreign key it defines
+func (c *SchemaChange) dropConstraint(name string) error {
+	idx := c.findConstraint(name)
+	fk := c.findForeignKey(name)
+	if idx < 0 && fk < 0 {
+		return fmt.Errorf("constraint %s does not exist on table %s", name, c.Schema.TableName)
+	}
+
+	if idx >= 0 {
+		if c.Constraints[idx].Type == PrimaryKeyConstraint {
+			c.Schema.PrimaryKey = nil
+		}
+		c.Constraints = append(c.Constraints[:idx:idx], c.Constraints[idx+1:]...)
+	}
+	if fk >= 0 {
+		c.Schema.ForeignKeys = append(c.Schema.ForeignKeys[:fk:fk], c.Schema.ForeignKeys[fk+1:]...)
+	}
+	return nil
+}
+
+// hasConstraint reports whether a constraint or foreign key has a name
+func (c *SchemaChange) hasConstraint(name string) bool {
+	return c.findConstraint(name) >= 0 || c.findForeignKey(name) >= 0
+}
+
+// findConstraint returns the position of a constraint by name, or -1.
+// Constraint names are case-insensitive.
+func (c *SchemaChange) findConstraint(name string) int {
+	for i, constraint := range c.Constraints {
+		if constraint.Name == name {
+			return i
+		}
+	}
+	return -1
+}
+
+// findForeignKey returns the position of a foreign key by name, or -1
+func (c *SchemaChange) findForeignKey(name string) int {
+	for i, fk := range c.Schema.ForeignKeys {
+		if fk.Name == name {
+			return i
+		}
+	}
+	return -1
+}
+
+// containsColumn reports whether a column list names a column
+func containsColumn(columns []string, name string) bool {
+	for _, col := range columns {
+		if col == name {
+			return true
+		}
+	}
+	return false
+}
+
+// renameColumn renames a column in a column list in place
+func renameColumn(columns []string, name, newName string) {
+	for i, col := range columns {
+		if col == name {
+			columns[i] = newName
+		}
+	}
+}
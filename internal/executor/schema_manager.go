@@ -4,7 +4,12 @@ package executor
 
 import (
 	"fmt"
+	"strings"
 	"sync"
+	"time"
+
+	"relational-db/internal/compiler"
+	"relational-db/internal/parser"
 )
 
 // SchemaManager manages database schemas and metadata
@@ -30,6 +35,22 @@ type TableSchema struct {
 	ForeignKeys []*ForeignKey
 	Indexes     []*IndexInfo
 	Version     int
+
+	// Clustered tables store rows in the leaves of a primary-key B+tree
+	Clustered bool
+
+	// TTL is the row expiry policy, nil when rows never expire
+	TTL *TTLPolicy
+
+	// AutoIncrement is the column an INSERT fills from a counter when it
+	// gives no value for it, "" if the table has none
+	AutoIncrement string
+}
+
+// TTLPolicy expires rows whose Column value is older than Duration
+type TTLPolicy struct {
+	Column   string
+	Duration time.Duration
 }
 
 // ForeignKey represents a foreign key constraint
@@ -184,6 +205,33 @@ func (sm *SchemaManager) DropSchema(tableName string) error {
 	return nil
 }
 
+// RenameSchema moves a schema, its version and constraints to a new table
+// name. Plans cached under the old name no longer find the table.
+func (sm *SchemaManager) RenameSchema(oldName, newName string) error {
+	sm.mutex.Lock()
+	defer sm.mutex.Unlock()
+
+	schema, exists := sm.schemas[oldName]
+	if !exists {
+		return fmt.Errorf("schema for table %s does not exist", oldName)
+	}
+	if _, exists := sm.schemas[newName]; exists {
+		return fmt.Errorf("schema for table %s already exists", newName)
+	}
+
+	sm.schemas[newName] = schema
+	sm.versions[newName] = sm.versions[oldName]
+	if constraints, ok := sm.constraints[oldName]; ok {
+		sm.constraints[newName] = constraints
+	}
+
+	delete(sm.schemas, oldName)
+	delete(sm.versions, oldName)
+	delete(sm.constraints, oldName)
+
+	return nil
+}
+
 // ListSchemas returns all registered table names
 func (sm *SchemaManager) ListSchemas() []string {
 	sm.mutex.RLock()
@@ -239,6 +287,29 @@ func (sm *SchemaManager) GetConstraints(tableName string) ([]*Constraint, error)
 	return sm.constraints[tableName], nil
 }
 
+// restoreSchema reinstates a schema with a known version and constraints,
+// bypassing version bumps. Used when loading or undoing catalog changes.
+func (sm *SchemaManager) restoreSchema(schema *TableSchema, version int, constraints []*Constraint) {
+	sm.mutex.Lock()
+	defer sm.mutex.Unlock()
+
+	schema.Version = version
+	sm.schemas[schema.TableName] = schema
+	sm.versions[schema.TableName] = version
+	if len(constraints) > 0 {
+		sm.constraints[schema.TableName] = constraints
+	} else {
+		delete(sm.constraints, schema.TableName)
+	}
+}
+
+// setConstraints replaces the constraint list of a table
+func (sm *SchemaManager) setConstraints(tableName string, constraints []*Constraint) {
+	sm.mutex.Lock()
+	defer sm.mutex.Unlock()
+	sm.constraints[tableName] = constraints
+}
+
 // validateSchema validates a table schema
 func (sm *SchemaManager) validateSchema(schema *TableSchema) error {
 	if len(schema.Columns) == 0 {
@@ -266,6 +337,21 @@ func (sm *SchemaManager) validateSchema(schema *TableSchema) error {
 		}
 	}
 
+	// Clustered tables are organized by their primary key
+	if schema.Clustered && len(schema.PrimaryKey) == 0 {
+		return fmt.Errorf("clustered table %s requires a primary key", schema.TableName)
+	}
+
+	// Row expiry is driven by an existing column
+	if schema.TTL != nil {
+		if !columnNames[schema.TTL.Column] {
+			return fmt.Errorf("ttl column %s does not exist", schema.TTL.Column)
+		}
+		if schema.TTL.Duration <= 0 {
+			return fmt.Errorf("ttl for table %s must be positive", schema.TableName)
+		}
+	}
+
 	// Validate foreign keys
 	for _, fk := range schema.ForeignKeys {
 		for _, col := range fk.Columns {
@@ -312,3 +398,134 @@ func (sm *SchemaManager) GetColumnInfo(tableName, columnName string) (*ColumnInf
 
 	return nil, fmt.Errorf("column %s not found in table %s", columnName, tableName)
 }
+
+// SchemaFromCreateTable builds a table schema from a CREATE TABLE statement
+func SchemaFromCreateTable(stmt *parser.CreateTableStatement) (*TableSchema, error) {
+	if stmt == nil {
+		return nil, fmt.Errorf("statement cannot be nil")
+	}
+
+	schema := &TableSchema{
+		TableName:  stmt.TableName.Value,
+		Columns:    make([]ColumnInfo, 0, len(stmt.Columns)),
+		PrimaryKey: compiler.CreateTablePrimaryKey(stmt),
+		Version:    1,
+
+		AutoIncrement: compiler.CreateTableAutoIncrement(stmt),
+	}
+
+	for _, col := range stmt.Columns {
+		colType, err := columnTypeFromDataType(col.DataType)
+		if err != nil {
+			return nil, err
+		}
+
+		nullable := true
+		for _, constraint := range col.Constraints {
+			if constraint.Type == parser.NotNull || constraint.Type == parser.PrimaryKey {
+				nullable = false
+			}
+		}
+		value, err := columnDefault(col)
+		if err != nil {
+			return nil, err
+		}
+
+		schema.Columns = append(schema.Columns, ColumnInfo{
+			Name:      col.Name.Value,
+			Type:      colType,
+			Nullable:  nullable,
+			TableName: schema.TableName,
+			Default:   value,
+		})
+	}
+
+	if option := stmt.Option("clustered"); option != nil {
+		schema.Clustered = option.IsEnabled()
+	}
+
+	if err := applyTableOptions(schema, stmt.Options); err != nil {
+		return nil, err
+	}
+
+	return schema, nil
+}
+
+// columnDefault evaluates the constant DEFAULT of a column definition and
+// converts it to the column's type; nil if the column has none
+func columnDefault(def *parser.ColumnDefinition) (interface{}, error) {
+	for _, constraint := range def.Constraints {
+		if constraint.Type != parser.Default {
+			continue
+		}
+		value, err := NewExpressionEvaluator().Evaluate(constraint.DefaultValue, nil)
+		if err != nil {
+			return nil, fmt.Errorf("invalid DEFAULT for column %s: %w", def.Name.Value, err)
+		}
+		if dataType, ok := compiler.DataTypeFromName(def.DataType.Name); ok {
+			if value, err = castValue(value, dataType); err != nil {
+				return nil, fmt.Errorf("invalid DEFAULT for column %s: %w", def.Name.Value, err)
+			}
+		}
+		return value, nil
+	}
+	return nil, nil
+}
+
+// applyTableOptions applies the row expiry options of a WITH or SET clause.
+// ttl_column and ttl may be given separately when a policy already exists.
+func applyTableOptions(schema *TableSchema, options []*parser.TableOption) error {
+	var column string
+	var duration time.Duration
+	if schema.TTL != nil {
+		column, duration = schema.TTL.Column, schema.TTL.Duration
+	}
+
+	changed := false
+	for _, option := range options {
+		switch strings.ToLower(option.Name.Value) {
+		case compiler.TTLColumnOption:
+			column = option.Text()
+			changed = true
+		case compiler.TTLOption:
+			d, err := compiler.ParseInterval(option.Text())
+			if err != nil {
+				return fmt.Errorf("invalid ttl for table %s: %w", schema.TableName, err)
+			}
+			duration = d
+			changed = true
+		}
+	}
+
+	if !changed {
+		return nil
+	}
+	if column == "" || duration == 0 {
+		return fmt.Errorf("table %s requires both %s and %s", schema.TableName, compiler.TTLColumnOption, compiler.TTLOption)
+	}
+
+	schema.TTL = &TTLPolicy{Column: column, Duration: duration}
+	return nil
+}
+
+// columnTypeFromDataType maps a SQL data type to an executor column type
+func columnTypeFromDataType(dataType *parser.DataType) (ColumnType, error) {
+	switch strings.ToUpper(dataType.Name) {
+	case "INTEGER", "INT":
+		return TypeBigInt, nil
+	case "REAL", "FLOAT", "DOUBLE":
+		return TypeDouble, nil
+	case "TEXT", "VARCHAR", "CHAR":
+		return TypeString, nil
+	case "BOOLEAN", "BOOL":
+		return TypeBoolean, nil
+	case "BLOB":
+		return TypeBlob, nil
+	case "DATE":
+		return TypeDate, nil
+	case "TIMESTAMP", "DATETIME":
+		return TypeTimestamp, nil
+	default:
+		return TypeNull, fmt.Errorf("unsupported data type: %s", dataType.Name)
+	}
+}
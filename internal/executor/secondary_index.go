@@ -0,0 +1,181 @@
+// Package executor - Secondary index component
+// Maps the indexed column values of a table's rows to their primary keys
+package executor
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+
+	"relational-db/internal/compiler"
+	"relational-db/internal/parser"
+)
+
+// SecondaryIndex maps the values of a table's indexed columns to the primary
+// keys of the rows holding them. Entries are kept sorted by key and then by
+// primary key, so a lookup is a binary search followed by a short walk.
+// NULL keys are indexed but never conflict in a unique index.
+// Architecture: Part of Execution Engine Layer
+type SecondaryIndex struct {
+	entry   *IndexCatalogEntry // shared with the catalog, so renames carry over
+	entries []secondaryEntry
+
+	mutex sync.RWMutex
+}
+
+// secondaryEntry is one indexed row
+type secondaryEntry struct {
+	key    []interface{}
+	rowKey []interface{}
+}
+
+// NewSecondaryIndex creates an empty secondary index for a catalog entry
+func NewSecondaryIndex(entry *IndexCatalogEntry) *SecondaryIndex {
+	return &SecondaryIndex{entry: entry}
+}
+
+// IndexEntryFromCreateIndex builds an index catalog entry from a CREATE INDEX
+// statement. Indexes are B-trees unless USING asks for a hash index.
+func IndexEntryFromCreateIndex(stmt *parser.CreateIndexStatement) (*IndexCatalogEntry, error) {
+	if stmt == nil {
+		return nil, fmt.Errorf("statement cannot be nil")
+	}
+
+	entry := &IndexCatalogEntry{
+		IndexName: stmt.IndexName.Value,
+		TableName: stmt.TableName.Value,
+		Columns:   make([]string, len(stmt.Columns)),
+		IsUnique:  stmt.Unique,
+		IndexType: BTreeIndex,
+	}
+	for i, col := range stmt.Columns {
+		entry.Columns[i] = col.Value
+	}
+
+	if stmt.Using != nil {
+		switch strings.ToLower(stmt.Using.Value) {
+		case compiler.IndexMethodBTree:
+			entry.IndexType = BTreeIndex
+		case compiler.IndexMethodHash:
+			entry.IndexType = HashIndex
+		default:
+			return nil, fmt.Errorf("unsupported index method %s", stmt.Using.Value)
+		}
+	}
+
+	return entry, nil
+}
+
+// Name returns the index name
+func (si *SecondaryIndex) Name() string {
+	return si.entry.IndexName
+}
+
+// TableName returns the name of the indexed table
+func (si *SecondaryIndex) TableName() string {
+	return si.entry.TableName
+}
+
+// Len returns the number of indexed rows
+func (si *SecondaryIndex) Len() int {
+	si.mutex.RLock()
+	defer si.mutex.RUnlock()
+	return len(si.entries)
+}
+
+// ExtractKey returns the indexed column values of a row
+func (si *SecondaryIndex) ExtractKey(schema *TupleSchema, values []interface{}) ([]interface{}, error) {
+	key := make([]interface{}, len(si.entry.Columns))
+	for i, col := range si.entry.Columns {
+		idx := schema.GetColumnIndex(col)
+		if idx < 0 || idx >= len(values) {
+			return nil, fmt.Errorf("index column %s does not exist", col)
+		}
+		key[i] = values[idx]
+	}
+	return key, nil
+}
+
+// Check reports the unique violation inserting key would cause, if any
+func (si *SecondaryIndex) Check(key []interface{}) error {
+	si.mutex.RLock()
+	defer si.mutex.RUnlock()
+	return si.check(key)
+}
+
+// Insert adds the row with primary key rowKey under key
+func (si *SecondaryIndex) Insert(key, rowKey []interface{}) error {
+	si.mutex.Lock()
+	defer si.mutex.Unlock()
+
+	if err := si.check(key); err != nil {
+		return err
+	}
+
+	pos := si.search(key, rowKey)
+	si.entries = append(si.entries, secondaryEntry{})
+	copy(si.entries[pos+1:], si.entries[pos:])
+	si.entries[pos] = secondaryEntry{key: key, rowKey: rowKey}
+	return nil
+}
+
+// Delete removes the row with primary key rowKey from under key
+func (si *SecondaryIndex) Delete(key, rowKey []interface{}) bool {
+	si.mutex.Lock()
+	defer si.mutex.Unlock()
+
+	pos := si.search(key, rowKey)
+	if pos == len(si.entries) || compareKeys(si.entries[pos].key, key) != 0 ||
+		compareKeys(si.entries[pos].rowKey, rowKey) != 0 {
+		return false
+	}
+	si.entries = append(si.entries[:pos], si.entries[pos+1:]...)
+	return true
+}
+
+// Lookup returns the primary keys of the rows whose indexed columns equal
+// key, in primary key order. Key may be a prefix of the indexed columns.
+func (si *SecondaryIndex) Lookup(key []interface{}) [][]interface{} {
+	si.mutex.RLock()
+	defer si.mutex.RUnlock()
+
+	var rowKeys [][]interface{}
+	for pos := si.search(key, nil); pos < len(si.entries); pos++ {
+		if compareKeys(si.entries[pos].key, key) != 0 {
+			break
+		}
+		rowKeys = append(rowKeys, si.entries[pos].rowKey)
+	}
+	return rowKeys
+}
+
+// check returns an error if key already exists in a unique index. Callers
+// must hold the mutex.
+func (si *SecondaryIndex) check(key []interface{}) error {
+	if !si.entry.IsUnique {
+		return nil
+	}
+	for _, v := range key {
+		if v == nil {
+			return nil
+		}
+	}
+
+	pos := si.search(key, nil)
+	if pos < len(si.entries) && compareKeys(si.entries[pos].key, key) == 0 {
+		return fmt.Errorf("duplicate key %v violates unique index %s", key, si.entry.IndexName)
+	}
+	return nil
+}
+
+// search returns the first position whose entry is >= (key, rowKey). A nil
+// rowKey finds the first entry for key.
+func (si *SecondaryIndex) search(key, rowKey []interface{}) int {
+	return sort.Search(len(si.entries), func(i int) bool {
+		if cmp := compareKeys(si.entries[i].key, key); cmp != 0 {
+			return cmp > 0
+		}
+		return rowKey == nil || compareKeys(si.entries[i].rowKey, rowKey) >= 0
+	})
+}
@@ -0,0 +1,138 @@
+// Package executor - Session component
+// Tracks the transaction state of a single client connection
+package executor
+
+import (
+	"fmt"
+	"sync"
+
+	"relational-db/internal/parser"
+)
+
+// Session holds the open transaction of one client connection and runs its
+// transaction control statements against the TransactionExecutor. Statements
+// outside BEGIN ... COMMIT have no session transaction.
+// Architecture: Part of Execution Engine Layer, sits on top of Transaction Executor
+type Session struct {
+	ID string
+
+	transactions *TransactionExecutor
+	txn          *Transaction
+
+	mutex sync.Mutex
+}
+
+// NewSession creates a session for a connection
+func (te *TransactionExecutor) NewSession(id string) *Session {
+	return &Session{
+		ID:           id,
+		transactions: te,
+	}
+}
+
+// Transaction returns the session's open transaction, or nil outside one
+func (s *Session) Transaction() *Transaction {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	return s.txn
+}
+
+// InTransaction reports whether the session has an open transaction
+func (s *Session) InTransaction() bool {
+	return s.Transaction() != nil
+}
+
+// Execute runs a transaction control statement
+func (s *Session) Execute(stmt parser.Statement) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	switch stmt := stmt.(type) {
+	case *parser.BeginStatement:
+		return s.begin(stmt)
+	case *parser.CommitStatement:
+		return s.commit()
+	case *parser.RollbackStatement:
+		return s.rollback(stmt)
+	case *parser.SavepointStatement:
+		if s.txn == nil {
+			return fmt.Errorf("SAVEPOINT can only be used in transaction blocks")
+		}
+		return s.transactions.CreateSavepoint(s.txn.ID, stmt.Name.Value)
+	case *parser.ReleaseSavepointStatement:
+		if s.txn == nil {
+			return fmt.Errorf("RELEASE SAVEPOINT can only be used in transaction blocks")
+		}
+		return s.transactions.ReleaseSavepoint(s.txn.ID, stmt.Name.Value)
+	default:
+		return fmt.Errorf("unsupported transaction control statement %T", stmt)
+	}
+}
+
+// Close rolls back the open transaction, if any
+func (s *Session) Close() error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if s.txn == nil {
+		return nil
+	}
+	txnID := s.txn.ID
+	s.txn = nil
+	return s.transactions.RollbackTransaction(txnID)
+}
+
+// begin opens a transaction at the requested isolation level
+func (s *Session) begin(stmt *parser.BeginStatement) error {
+	if s.txn != nil {
+		return fmt.Errorf("there is already a transaction in progress")
+	}
+
+	level := s.transactions.GetIsolationLevel()
+	switch stmt.IsolationLevel {
+	case parser.IsolationReadUncommitted:
+		level = ReadUncommitted
+	case parser.IsolationReadCommitted:
+		level = ReadCommitted
+	case parser.IsolationRepeatableRead:
+		level = RepeatableRead
+	case parser.IsolationSerializable:
+		level = Serializable
+	}
+
+	txn, err := s.transactions.BeginTransaction(level)
+	if err != nil {
+		return err
+	}
+	s.txn = txn
+	return nil
+}
+
+// commit commits the open transaction
+func (s *Session) commit() error {
+	if s.txn == nil {
+		return fmt.Errorf("there is no transaction in progress")
+	}
+
+	txnID := s.txn.ID
+	s.txn = nil
+	return s.transactions.CommitTransaction(txnID)
+}
+
+// rollback aborts the open transaction, or rewinds it to a savepoint
+func (s *Session) rollback(stmt *parser.RollbackStatement) error {
+	if s.txn == nil {
+		if stmt.Savepoint != nil {
+			return fmt.Errorf("ROLLBACK TO SAVEPOINT can only be used in transaction blocks")
+		}
+		return fmt.Errorf("there is no transaction in progress")
+	}
+
+	if stmt.Savepoint != nil {
+		return s.transactions.RollbackToSavepoint(s.txn.ID, stmt.Savepoint.Value)
+	}
+
+	txnID := s.txn.ID
+	s.txn = nil
+	return s.transactions.RollbackTransaction(txnID)
+}
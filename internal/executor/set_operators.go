@@ -0,0 +1,507 @@
+package executor
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"relational-db/internal/parser"
+)
+
+// RecursiveUnionOperator computes a recursive CTE. It returns the rows of
+// the anchor term, then runs the recursive term over the rows the previous
+// iteration produced until an iteration produces none. With UNION rows
+// already returned are dropped, which also ends recursion over cyclic data;
+// with UNION ALL the executor's recursion depth limit stops runaway queries.
+type RecursiveUnionOperator struct {
+	anchor    PhysicalOperator
+	recursive PhysicalOperator
+	cte       *parser.CommonTableExpression
+	unionAll  bool
+
+	ctx       *ExecutionContext
+	current   PhysicalOperator // term being read, nil when done
+	iteration int
+	produced  *ResultSet      // rows of the current iteration
+	seen      map[string]bool // rows returned so far, for UNION
+	closed    bool
+}
+
+// NewRecursiveUnionOperator creates a recursive union of a CTE's anchor and
+// recursive terms
+func NewRecursiveUnionOperator(anchor, recursive PhysicalOperator, cte *parser.CommonTableExpression, unionAll bool) *RecursiveUnionOperator {
+	return &RecursiveUnionOperator{
+		anchor:    anchor,
+		recursive: recursive,
+		cte:       cte,
+		unionAll:  unionAll,
+		closed:    true,
+	}
+}
+
+// Open initializes the operator
+func (op *RecursiveUnionOperator) Open(ctx *ExecutionContext) error {
+	if !op.closed {
+		return nil
+	}
+	if ctx.ctes == nil {
+		return ErrCTENotPlanned
+	}
+
+	if err := op.anchor.Open(ctx); err != nil {
+		return err
+	}
+
+	op.ctx = ctx
+	op.current = op.anchor
+	op.iteration = 0
+	op.produced = NewResultSet()
+	op.seen = make(map[string]bool)
+	op.closed = false
+	return nil
+}
+
+// Next returns the next row of the CTE
+func (op *RecursiveUnionOperator) Next() (*Tuple, error) {
+	if op.closed {
+		return nil, ErrOperatorClosed
+	}
+
+	for op.current != nil {
+		if op.ctx.IsTimedOut() {
+			return nil, ErrExecutionTimeout
+		}
+
+		tuple, err := op.current.Next()
+		if err != nil {
+			return nil, err
+		}
+		if tuple != nil {
+			if !op.unionAll {
+				key := rowKey(tuple.Values)
+				if op.seen[key] {
+					continue
+				}
+				op.seen[key] = true
+			}
+			op.produced.AddTuple(tuple)
+			return tuple, nil
+		}
+
+		// The term is exhausted: run the recursive term over its rows
+		if err := op.current.Close(); err != nil {
+			return nil, err
+		}
+		op.current = nil
+		if op.produced.RowCount() == 0 {
+			return nil, nil
+		}
+
+		op.iteration++
+		if limit := op.ctx.config.MaxRecursionDepth; limit > 0 && op.iteration > limit {
+			return nil, fmt.Errorf("%w: %s ran more than %d iterations", ErrRecursionLimit, op.cte.Name.Value, limit)
+		}
+
+		op.ctx.ctes.workTables[op.cte] = op.produced
+		op.produced = NewResultSet()
+		if err := op.recursive.Open(op.ctx); err != nil {
+			return nil, err
+		}
+		op.current = op.recursive
+	}
+
+	return nil, nil
+}
+
+// Close releases resources
+func (op *RecursiveUnionOperator) Close() error {
+	if op.closed {
+		return nil
+	}
+
+	var err error
+	if op.current != nil {
+		err = op.current.Close()
+		op.current = nil
+	}
+	delete(op.ctx.ctes.workTables, op.cte)
+	op.produced = nil
+	op.seen = nil
+	op.closed = true
+	return err
+}
+
+// OperatorType returns the operator type
+func (op *RecursiveUnionOperator) OperatorType() string {
+	return "RecursiveUnion"
+}
+
+// EstimatedCost returns estimated cost
+func (op *RecursiveUnionOperator) EstimatedCost() float64 {
+	return op.anchor.EstimatedCost() + float64(op.iteration)*op.recursive.EstimatedCost()
+}
+
+// rowKey returns a key that is equal for rows with equal values, for
+// eliminating duplicate rows. Numbers are keyed by value, so 1 and 1.0
+// are the same row.
+func rowKey(values []interface{}) string {
+	var key strings.Builder
+	for _, value := range values {
+		if f, ok := toFloat64(value); ok {
+			value = f
+		}
+		fmt.Fprintf(&key, "%T:%v\x00", value, value)
+	}
+	return key.String()
+}
+
+// HashSetOperator computes UNION, INTERSECT or EXCEPT by hashing rows. For
+// INTERSECT and EXCEPT it first counts the rows of the right input, then
+// streams the left input against the counts. Without ALL each distinct row
+// is returned at most once; with ALL a row appearing m times on the left
+// and n times on the right is returned m+n, min(m, n) or max(m-n, 0) times.
+type HashSetOperator struct {
+	left     PhysicalOperator
+	right    PhysicalOperator
+	operator parser.SetOperator
+	all      bool
+
+	ctx      *ExecutionContext
+	current  PhysicalOperator // input being read, nil when done
+	counts   map[string]int   // rows of the right input, for INTERSECT and EXCEPT
+	returned map[string]bool  // rows returned so far, without ALL
+	closed   bool
+}
+
+// NewHashSetOperator creates a hash-based set operation over two inputs
+// with the same columns
+func NewHashSetOperator(left, right PhysicalOperator, operator parser.SetOperator, all bool) *HashSetOperator {
+	return &HashSetOperator{
+		left:     left,
+		right:    right,
+		operator: operator,
+		all:      all,
+		closed:   true,
+	}
+}
+
+// Open initializes the operator, counting the right input's rows unless
+// the operation is a UNION
+func (op *HashSetOperator) Open(ctx *ExecutionContext) error {
+	if !op.closed {
+		return nil
+	}
+
+	op.ctx = ctx
+	op.counts = make(map[string]int)
+	op.returned = make(map[string]bool)
+
+	if op.operator != parser.Union {
+		if err := op.right.Open(ctx); err != nil {
+			return err
+		}
+		for {
+			tuple, err := op.right.Next()
+			if err != nil {
+				op.right.Close()
+				return err
+			}
+			if tuple == nil {
+				break
+			}
+			op.counts[rowKey(tuple.Values)]++
+		}
+		if err := op.right.Close(); err != nil {
+			return err
+		}
+	}
+
+	if err := op.left.Open(ctx); err != nil {
+		return err
+	}
+	op.current = op.left
+	op.closed = false
+	return nil
+}
+
+// Next returns the next row of the result
+func (op *HashSetOperator) Next() (*Tuple, error) {
+	if op.closed {
+		return nil, ErrOperatorClosed
+	}
+
+	for op.current != nil {
+		if op.ctx.IsTimedOut() {
+			return nil, ErrExecutionTimeout
+		}
+
+		tuple, err := op.current.Next()
+		if err != nil {
+			return nil, err
+		}
+		if tuple == nil {
+			exhausted := op.current
+			op.current = nil
+			if err := exhausted.Close(); err != nil {
+				return nil, err
+			}
+
+			// A UNION goes on with the rows of its right input
+			if op.operator == parser.Union && exhausted == op.left {
+				if err := op.right.Open(op.ctx); err != nil {
+					return nil, err
+				}
+				op.current = op.right
+			}
+			continue
+		}
+
+		if op.keep(rowKey(tuple.Values)) {
+			return tuple, nil
+		}
+	}
+
+	return nil, nil
+}
+
+// keep decides whether a row read from an input is part of the result
+func (op *HashSetOperator) keep(key string) bool {
+	switch op.operator {
+	case parser.Intersect:
+		if op.counts[key] == 0 {
+			return false
+		}
+		if op.all {
+			op.counts[key]--
+			return true
+		}
+
+	case parser.Except:
+		if op.counts[key] > 0 {
+			if op.all {
+				op.counts[key]--
+			}
+			return false
+		}
+		if op.all {
+			return true
+		}
+
+	default:
+		if op.all {
+			return true
+		}
+	}
+
+	if op.returned[key] {
+		return false
+	}
+	op.returned[key] = true
+	return true
+}
+
+// Close releases resources
+func (op *HashSetOperator) Close() error {
+	if op.closed {
+		return nil
+	}
+
+	var err error
+	if op.current != nil {
+		err = op.current.Close()
+		op.current = nil
+	}
+	op.counts = nil
+	op.returned = nil
+	op.closed = true
+	return err
+}
+
+// OperatorType returns the operator type
+func (op *HashSetOperator) OperatorType() string {
+	return "HashSetOp"
+}
+
+// EstimatedCost returns estimated cost
+func (op *HashSetOperator) EstimatedCost() float64 {
+	return op.left.EstimatedCost() + op.right.EstimatedCost()
+}
+
+// SortSetOperator computes UNION, INTERSECT or EXCEPT by sorting both
+// inputs on all columns and merging them. Equal rows end up in runs, and
+// each run is returned as many times as the operation calls for, so the
+// result is ordered by all columns.
+type SortSetOperator struct {
+	left     PhysicalOperator
+	right    PhysicalOperator
+	operator parser.SetOperator
+	all      bool
+
+	ctx    *ExecutionContext
+	rows   []*Tuple // result rows, in order
+	pos    int
+	closed bool
+}
+
+// NewSortSetOperator creates a sort-based set operation over two inputs
+// with the same columns
+func NewSortSetOperator(left, right PhysicalOperator, operator parser.SetOperator, all bool) *SortSetOperator {
+	return &SortSetOperator{
+		left:     left,
+		right:    right,
+		operator: operator,
+		all:      all,
+		closed:   true,
+	}
+}
+
+// Open reads and sorts both inputs and merges them into the result
+func (op *SortSetOperator) Open(ctx *ExecutionContext) error {
+	if !op.closed {
+		return nil
+	}
+
+	left, err := sortedRows(ctx, op.left)
+	if err != nil {
+		return err
+	}
+	right, err := sortedRows(ctx, op.right)
+	if err != nil {
+		return err
+	}
+
+	op.ctx = ctx
+	op.rows = op.merge(left, right)
+	op.pos = 0
+	op.closed = false
+	return nil
+}
+
+// merge walks the runs of equal rows of both sorted inputs in order
+func (op *SortSetOperator) merge(left, right []*Tuple) []*Tuple {
+	var rows []*Tuple
+	i, j := 0, 0
+	for i < len(left) || j < len(right) {
+		// The run of the smallest row not yet merged, on either side
+		var row *Tuple
+		switch {
+		case j >= len(right):
+			row = left[i]
+		case i >= len(left):
+			row = right[j]
+		case compareKeys(left[i].Values, right[j].Values) <= 0:
+			row = left[i]
+		default:
+			row = right[j]
+		}
+
+		m := 0
+		for i < len(left) && compareKeys(left[i].Values, row.Values) == 0 {
+			i++
+			m++
+		}
+		n := 0
+		for j < len(right) && compareKeys(right[j].Values, row.Values) == 0 {
+			j++
+			n++
+		}
+
+		for k := op.copies(m, n); k > 0; k-- {
+			rows = append(rows, row)
+		}
+	}
+	return rows
+}
+
+// copies returns how many times a row appearing m times in the left input
+// and n times in the right input is part of the result
+func (op *SortSetOperator) copies(m, n int) int {
+	var count int
+	switch op.operator {
+	case parser.Intersect:
+		count = m
+		if n < m {
+			count = n
+		}
+	case parser.Except:
+		count = m - n
+		if !op.all && n > 0 {
+			count = 0
+		}
+	default:
+		count = m + n
+	}
+
+	if count < 0 {
+		return 0
+	}
+	if !op.all && count > 1 {
+		return 1
+	}
+	return count
+}
+
+// Next returns the next row of the result
+func (op *SortSetOperator) Next() (*Tuple, error) {
+	if op.closed {
+		return nil, ErrOperatorClosed
+	}
+	if op.pos >= len(op.rows) {
+		return nil, nil
+	}
+	op.pos++
+	return op.rows[op.pos-1], nil
+}
+
+// Close releases resources
+func (op *SortSetOperator) Close() error {
+	op.rows = nil
+	op.closed = true
+	return nil
+}
+
+// OperatorType returns the operator type
+func (op *SortSetOperator) OperatorType() string {
+	return "SortSetOp"
+}
+
+// EstimatedCost returns estimated cost
+func (op *SortSetOperator) EstimatedCost() float64 {
+	return op.left.EstimatedCost() + op.right.EstimatedCost()
+}
+
+// sortedRows reads all rows of an input and sorts them on all columns
+func sortedRows(ctx *ExecutionContext, input PhysicalOperator) ([]*Tuple, error) {
+	rows, err := allRows(ctx, input)
+	if err != nil {
+		return nil, err
+	}
+
+	sort.SliceStable(rows, func(a, b int) bool {
+		return compareKeys(rows[a].Values, rows[b].Values) < 0
+	})
+	return rows, nil
+}
+
+// allRows opens an input and reads all of its rows
+func allRows(ctx *ExecutionContext, input PhysicalOperator) ([]*Tuple, error) {
+	if err := input.Open(ctx); err != nil {
+		return nil, err
+	}
+	defer input.Close()
+
+	var rows []*Tuple
+	for {
+		if ctx.IsTimedOut() {
+			return nil, ErrExecutionTimeout
+		}
+		tuple, err := input.Next()
+		if err != nil {
+			return nil, err
+		}
+		if tuple == nil {
+			return rows, nil
+		}
+		rows = append(rows, tuple)
+	}
+}
@@ -0,0 +1,140 @@
+package executor
+
+import (
+	"fmt"
+
+	"relational-db/internal/optimizer"
+	"relational-db/internal/parser"
+)
+
+// outerRow is a row of an enclosing query visible to a correlated subquery
+type outerRow struct {
+	tuple *Tuple
+	next  *outerRow
+}
+
+// subqueryRunner runs the subqueries of one query execution. Uncorrelated
+// subqueries are run once and their results reused for every row.
+type subqueryRunner struct {
+	executor *Executor
+	plans    map[*parser.SelectStatement]*optimizer.SubqueryPlan
+	results  map[*parser.SelectStatement]*ResultSet
+}
+
+// newSubqueryRunner creates a runner for the subquery plans of a query
+func newSubqueryRunner(executor *Executor, plans map[*parser.SelectStatement]*optimizer.SubqueryPlan) *subqueryRunner {
+	return &subqueryRunner{
+		executor: executor,
+		plans:    plans,
+		results:  make(map[*parser.SelectStatement]*ResultSet),
+	}
+}
+
+// run returns up to limit rows of a subquery (all rows if limit is 0),
+// evaluated for the enclosing query's row outer
+func (sr *subqueryRunner) run(ctx *ExecutionContext, query *parser.SelectStatement, outer *Tuple, limit int) (*ResultSet, error) {
+	plan, found := sr.plans[query]
+	if !found {
+		return nil, ErrSubqueryNotPlanned
+	}
+
+	if !plan.Correlated {
+		if result, cached := sr.results[query]; cached {
+			return result, nil
+		}
+	} else {
+		ctx = ctx.withOuterRow(outer)
+	}
+
+	result, err := sr.executor.runPlan(ctx, plan.Root, limit)
+	if err != nil {
+		return nil, fmt.Errorf("subquery: %w", err)
+	}
+
+	if !plan.Correlated {
+		sr.results[query] = result
+	}
+	return result, nil
+}
+
+// runSubquery runs a subquery of the expression being evaluated for tuple
+func (ee *ExpressionEvaluator) runSubquery(query *parser.SelectStatement, tuple *Tuple, limit int) (*ResultSet, error) {
+	if ee.ctx == nil || ee.ctx.subqueries == nil {
+		return nil, ErrSubqueryNotPlanned
+	}
+	return ee.ctx.subqueries.run(ee.ctx, query, tuple, limit)
+}
+
+// evaluateScalarSubquery returns the single value of a scalar subquery, or
+// NULL when it returns no rows
+func (ee *ExpressionEvaluator) evaluateScalarSubquery(subquery *parser.SubqueryExpression, tuple *Tuple) (interface{}, error) {
+	// Two rows are enough to tell that there is more than one
+	result, err := ee.runSubquery(subquery.Query, tuple, 2)
+	if err != nil {
+		return nil, err
+	}
+
+	switch result.RowCount() {
+	case 0:
+		return nil, nil
+	case 1:
+		return firstValue(result.Tuples[0]), nil
+	default:
+		return nil, ErrSubqueryMultipleRows
+	}
+}
+
+// evaluateExists reports whether a subquery returns any row
+func (ee *ExpressionEvaluator) evaluateExists(exists *parser.ExistsExpression, tuple *Tuple) (interface{}, error) {
+	result, err := ee.runSubquery(exists.Query, tuple, 1)
+	if err != nil {
+		return nil, err
+	}
+	return result.RowCount() > 0, nil
+}
+
+// evaluateInSubquery evaluates expr IN (SELECT ...). Following SQL's three
+// valued logic the result is NULL, not false, when no row matches but the
+// value or one of the subquery's values is NULL.
+func (ee *ExpressionEvaluator) evaluateInSubquery(expr parser.Expression, subquery *parser.SubqueryExpression, tuple *Tuple) (interface{}, error) {
+	value, err := ee.Evaluate(expr, tuple)
+	if err != nil {
+		return nil, err
+	}
+
+	result, err := ee.runSubquery(subquery.Query, tuple, 0)
+	if err != nil {
+		return nil, err
+	}
+	if result.RowCount() == 0 {
+		return false, nil
+	}
+	if value == nil {
+		return nil, nil
+	}
+
+	sawNull := false
+	for _, row := range result.Tuples {
+		candidate := firstValue(row)
+		if candidate == nil {
+			sawNull = true
+			continue
+		}
+		if compareValues(value, candidate) == 0 {
+			return true, nil
+		}
+	}
+
+	if sawNull {
+		return nil, nil
+	}
+	return false, nil
+}
+
+// firstValue returns the first column of a row, or NULL for an empty row
+func firstValue(tuple *Tuple) interface{} {
+	if len(tuple.Values) == 0 {
+		return nil
+	}
+	return tuple.Values[0]
+}
@@ -0,0 +1,1516 @@
+// Package executor - System Catalog component
+// Persists the catalog (tables, columns, indexes, constraints, partitions, views) in system tables
+// stored in the storage engine so the schema survives a restart
+package executor
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"math"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"relational-db/internal/compiler"
+	"relational-db/internal/parser"
+	"relational-db/internal/storage"
+)
+
+// CatalogRootPageID is the well-known page holding the catalog root record.
+// It is the first page allocated in a new database.
+const CatalogRootPageID storage.PageID = 1
+
+// System table names. The sys_ prefix is reserved for the catalog.
+const (
+	SystemTablePrefix   = "sys_"
+	SysTablesTable      = "sys_tables"
+	SysColumnsTable     = "sys_columns"
+	SysIndexesTable     = "sys_indexes"
+	SysConstraintsTable = "sys_constraints"
+	SysForeignKeysTable = "sys_foreign_keys"
+	SysPartitionsTable  = "sys_partitions"
+	SysViewsTable       = "sys_views"
+)
+
+// Catalog page layout
+const (
+	catalogMagic           = "NDBCATLG"
+	catalogFormatVersion   = 1
+	catalogRootHeaderSize  = 8 + 4 + 8 + 8 + 8 + 4 // magic, version, generation, length, first page, checksum
+	catalogChainHeaderSize = 8                     // next page ID
+)
+
+// SystemCatalog keeps the SchemaManager and CatalogManager in sync with the
+// system tables persisted in the storage engine.
+//
+// Every DDL change is applied in memory, then the full catalog image is
+// written copy-on-write to a fresh page chain and committed by a single write
+// of the root page. A crash before the root write leaves the previous catalog
+// intact; if persisting fails the in-memory change is undone, so memory and
+// disk never disagree.
+// Architecture: Part of Execution Engine Layer, sits above Schema and Catalog Managers
+type SystemCatalog struct {
+	storage        storage.StorageEngine
+	schemaManager  *SchemaManager
+	catalogManager *CatalogManager
+	pageSize       int
+
+	generation  uint64
+	chain       []storage.PageID // pages holding the current catalog image
+	nextTableID uint64
+	nextIndexID uint64
+
+	mutex sync.Mutex // serializes DDL
+}
+
+// NewSystemCatalog creates a system catalog over a storage engine
+func NewSystemCatalog(engine storage.StorageEngine, schemaManager *SchemaManager, catalogManager *CatalogManager, pageSize int) *SystemCatalog {
+	return &SystemCatalog{
+		storage:        engine,
+		schemaManager:  schemaManager,
+		catalogManager: catalogManager,
+		pageSize:       pageSize,
+		nextTableID:    1,
+		nextIndexID:    1,
+	}
+}
+
+// IsSystemTable reports whether a table name belongs to the system catalog
+func IsSystemTable(tableName string) bool {
+	return strings.HasPrefix(strings.ToLower(tableName), SystemTablePrefix)
+}
+
+// systemTableSchemas returns the schemas of the system tables
+func systemTableSchemas() []*TableSchema {
+	return []*TableSchema{
+		{
+			TableName: SysTablesTable,
+			Columns: []ColumnInfo{
+				{Name: "table_name", Type: TypeString},
+				{Name: "table_id", Type: TypeBigInt},
+				{Name: "schema_name", Type: TypeString, Nullable: true},
+				{Name: "owner", Type: TypeString, Nullable: true},
+				{Name: "created_at", Type: TypeTimestamp},
+				{Name: "modified_at", Type: TypeTimestamp},
+				{Name: "row_count", Type: TypeBigInt},
+				{Name: "version", Type: TypeBigInt},
+				{Name: "clustered", Type: TypeBoolean},
+				{Name: "ttl_column", Type: TypeString, Nullable: true},
+				{Name: "ttl_seconds", Type: TypeBigInt}, // 0 if rows never expire
+				{Name: "auto_increment", Type: TypeString, Nullable: true},
+				{Name: "rows_page", Type: TypeBigInt}, // 0 if rows are kept in memory only
+			},
+			PrimaryKey: []string{"table_name"},
+		},
+		{
+			TableName: SysColumnsTable,
+			Columns: []ColumnInfo{
+				{Name: "table_name", Type: TypeString},
+				{Name: "column_name", Type: TypeString},
+				{Name: "position", Type: TypeBigInt},
+				{Name: "type", Type: TypeBigInt},
+				{Name: "nullable", Type: TypeBoolean},
+				{Name: "pk_position", Type: TypeBigInt},                   // 1-based, 0 if not in the primary key
+				{Name: "default_value", Type: TypeString, Nullable: true}, // keeps its own type tag
+			},
+			PrimaryKey: []string{"table_name", "column_name"},
+		},
+		{
+			TableName: SysIndexesTable,
+			Columns: []ColumnInfo{
+				{Name: "index_name", Type: TypeString},
+				{Name: "index_id", Type: TypeBigInt},
+				{Name: "table_name", Type: TypeString},
+				{Name: "columns", Type: TypeString},
+				{Name: "is_unique", Type: TypeBoolean},
+				{Name: "is_primary", Type: TypeBoolean},
+				{Name: "index_type", Type: TypeBigInt},
+				{Name: "created_at", Type: TypeTimestamp},
+			},
+			PrimaryKey: []string{"index_name"},
+		},
+		{
+			TableName: SysConstraintsTable,
+			Columns: []ColumnInfo{
+				{Name: "table_name", Type: TypeString},
+				{Name: "constraint_name", Type: TypeString, Nullable: true},
+				{Name: "type", Type: TypeBigInt},
+				{Name: "columns", Type: TypeString},
+				{Name: "check_expression", Type: TypeString, Nullable: true},
+			},
+		},
+		{
+			TableName: SysForeignKeysTable,
+			Columns: []ColumnInfo{
+				{Name: "table_name", Type: TypeString},
+				{Name: "name", Type: TypeString, Nullable: true},
+				{Name: "columns", Type: TypeString},
+				{Name: "ref_table", Type: TypeString},
+				{Name: "ref_columns", Type: TypeString},
+				{Name: "on_delete", Type: TypeBigInt},
+				{Name: "on_update", Type: TypeBigInt},
+			},
+		},
+		{
+			// One row per partition; LIST partitions have one row per value
+			TableName: SysPartitionsTable,
+			Columns: []ColumnInfo{
+				{Name: "table_name", Type: TypeString},
+				{Name: "partition_name", Type: TypeString},
+				{Name: "partition_id", Type: TypeBigInt},
+				{Name: "position", Type: TypeBigInt},
+				{Name: "method", Type: TypeBigInt},
+				{Name: "column_name", Type: TypeString},
+				{Name: "bound", Type: TypeString, Nullable: true}, // keeps its own type tag; RANGE upper bound or LIST value, NULL for MAXVALUE and HASH
+				{Name: "rows_page", Type: TypeBigInt},
+			},
+		},
+		{
+			TableName: SysViewsTable,
+			Columns: []ColumnInfo{
+				{Name: "view_name", Type: TypeString},
+				{Name: "definition", Type: TypeString},
+				{Name: "columns", Type: TypeString},
+				{Name: "materialized", Type: TypeBoolean},
+				{Name: "depends_on", Type: TypeString},
+				{Name: "created_at", Type: TypeTimestamp},
+			},
+			PrimaryKey: []string{"view_name"},
+		},
+	}
+}
+
+// Bootstrap loads the catalog from storage, or initializes an empty catalog
+// in a new database. It must be called before any DDL.
+func (sc *SystemCatalog) Bootstrap() error {
+	sc.mutex.Lock()
+	defer sc.mutex.Unlock()
+
+	// System tables are always present
+	for _, schema := range systemTableSchemas() {
+		if _, err := sc.schemaManager.GetSchema(schema.TableName); err == nil {
+			continue
+		}
+		if err := sc.schemaManager.RegisterSchema(schema); err != nil {
+			return fmt.Errorf("failed to register system table %s: %w", schema.TableName, err)
+		}
+	}
+
+	// Only a database without any pages is new. Anything else must already
+	// hold a catalog; it is never overwritten, since the pages may hold data.
+	if sc.storage.Stats().TotalPages == 0 {
+		return sc.initialize()
+	}
+
+	root, err := sc.storage.ReadPage(CatalogRootPageID)
+	if err != nil {
+		return fmt.Errorf("failed to read catalog root page: %w", err)
+	}
+
+	if !bytes.HasPrefix(root.Data, []byte(catalogMagic)) {
+		return fmt.Errorf("page %d of the database does not hold a system catalog", CatalogRootPageID)
+	}
+
+	image, err := sc.readImage(root)
+	if err != nil {
+		return fmt.Errorf("failed to read catalog: %w", err)
+	}
+
+	tables, err := decodeCatalogImage(image)
+	if err != nil {
+		return fmt.Errorf("failed to decode catalog: %w", err)
+	}
+
+	if err := sc.load(tables); err != nil {
+		return err
+	}
+	return sc.addRowsPages()
+}
+
+// addRowsPages gives the tables of a catalog written before rows were
+// stored a rows page each, and persists the catalog if any lacked one
+func (sc *SystemCatalog) addRowsPages() error {
+	tableNames := sc.catalogManager.ListTables()
+	sort.Strings(tableNames)
+
+	var added []*TableCatalogEntry
+	for _, tableName := range tableNames {
+		entry, err := sc.catalogManager.GetTable(tableName)
+		if err != nil || entry.RowsPage != 0 || !sc.holdsRows(entry) {
+			continue
+		}
+		if entry.RowsPage, err = newRowsPage(sc.storage, sc.pageSize); err != nil {
+			sc.releaseAddedRows(added)
+			return err
+		}
+		added = append(added, entry)
+	}
+	if len(added) == 0 {
+		return nil
+	}
+
+	if err := sc.persist(); err != nil {
+		sc.releaseAddedRows(added)
+		return fmt.Errorf("failed to persist catalog: %w", err)
+	}
+	return nil
+}
+
+// releaseAddedRows takes back the rows pages given to tables by a change
+// that failed
+func (sc *SystemCatalog) releaseAddedRows(entries []*TableCatalogEntry) {
+	for _, entry := range entries {
+		releaseRows(sc.storage, entry.RowsPage)
+		entry.RowsPage = 0
+	}
+}
+
+// holdsRows reports whether a table stores rows of its own: a partitioned
+// table's rows are in its partitions and a materialized view's in memory
+func (sc *SystemCatalog) holdsRows(entry *TableCatalogEntry) bool {
+	if entry.Partitioning != nil {
+		return false
+	}
+	_, err := sc.catalogManager.GetView(entry.TableName)
+	return err != nil
+}
+
+// initialize claims the root page of a new database and writes an empty catalog
+func (sc *SystemCatalog) initialize() error {
+	pageID, err := sc.storage.AllocatePage()
+	if err != nil {
+		return fmt.Errorf("failed to allocate catalog root page: %w", err)
+	}
+
+	if pageID != CatalogRootPageID {
+		return fmt.Errorf("catalog root page must be page %d of a new database, got page %d",
+			CatalogRootPageID, pageID)
+	}
+
+	return sc.persist()
+}
+
+// CreateTable registers a new table and persists the catalog
+func (sc *SystemCatalog) CreateTable(schema *TableSchema) error {
+	return sc.CreatePartitionedTable(schema, nil)
+}
+
+// CreatePartitionedTable registers a new table split into the partitions of
+// scheme and persists the catalog. A nil scheme creates a plain table.
+func (sc *SystemCatalog) CreatePartitionedTable(schema *TableSchema, scheme *compiler.PartitionScheme) error {
+	sc.mutex.Lock()
+	defer sc.mutex.Unlock()
+
+	if schema == nil {
+		return fmt.Errorf("schema cannot be nil")
+	}
+
+	if IsSystemTable(schema.TableName) {
+		return fmt.Errorf("table name %s uses the reserved prefix %s", schema.TableName, SystemTablePrefix)
+	}
+
+	if err := sc.schemaManager.RegisterSchema(schema); err != nil {
+		return err
+	}
+
+	entry := &TableCatalogEntry{
+		TableName: schema.TableName,
+		TableID:   sc.nextTableID,
+	}
+	if err := sc.catalogManager.CreateTable(entry); err != nil {
+		sc.schemaManager.DropSchema(schema.TableName)
+		return err
+	}
+	sc.nextTableID++
+
+	// The rows go in the table, or in its partitions
+	holders := []*TableCatalogEntry{entry}
+	if scheme != nil {
+		partitions, err := sc.catalogManager.CreatePartitions(schema.TableName, scheme)
+		if err != nil {
+			sc.catalogManager.DropTable(schema.TableName)
+			sc.schemaManager.DropSchema(schema.TableName)
+			return err
+		}
+		for _, partition := range partitions {
+			partition.TableID = sc.nextTableID
+			sc.nextTableID++
+		}
+		holders = partitions
+	}
+
+	var added []*TableCatalogEntry
+	for _, holder := range holders {
+		var err error
+		if holder.RowsPage, err = newRowsPage(sc.storage, sc.pageSize); err != nil {
+			sc.releaseAddedRows(added)
+			sc.catalogManager.DropTable(schema.TableName)
+			sc.schemaManager.DropSchema(schema.TableName)
+			return err
+		}
+		added = append(added, holder)
+	}
+
+	if err := sc.persist(); err != nil {
+		sc.releaseAddedRows(added)
+		sc.catalogManager.DropTable(schema.TableName)
+		sc.schemaManager.DropSchema(schema.TableName)
+		return fmt.Errorf("failed to persist catalog: %w", err)
+	}
+
+	return nil
+}
+
+// DropTable removes a table with its indexes and constraints and persists
+// the catalog. It fails if views depend on the table.
+func (sc *SystemCatalog) DropTable(tableName string) error {
+	return sc.dropTable(tableName, false)
+}
+
+// DropTableCascade removes a table and every view that depends on it, and
+// persists the catalog
+func (sc *SystemCatalog) DropTableCascade(tableName string) error {
+	return sc.dropTable(tableName, true)
+}
+
+// dropTable removes a table, and with cascade the views depending on it,
+// in a single catalog change
+func (sc *SystemCatalog) dropTable(tableName string, cascade bool) error {
+	sc.mutex.Lock()
+	defer sc.mutex.Unlock()
+
+	if IsSystemTable(tableName) {
+		return fmt.Errorf("cannot drop system table %s", tableName)
+	}
+
+	if _, err := sc.catalogManager.GetView(tableName); err == nil {
+		return fmt.Errorf("%s is a materialized view, use DROP MATERIALIZED VIEW", tableName)
+	}
+
+	undo, err := sc.dropDependents(tableName, cascade)
+	if err != nil {
+		return err
+	}
+
+	rowsPages := sc.rowsPages(tableName)
+	undoTable, err := sc.removeTable(tableName)
+	if err != nil {
+		undo()
+		return err
+	}
+
+	if err := sc.persist(); err != nil {
+		undoTable()
+		undo()
+		return fmt.Errorf("failed to persist catalog: %w", err)
+	}
+
+	// The rows are unreachable now; freeing them is best effort
+	for _, pageID := range rowsPages {
+		releaseRows(sc.storage, pageID)
+	}
+	return nil
+}
+
+// rowsPages returns the rows pages of a table and its partitions
+func (sc *SystemCatalog) rowsPages(tableName string) []storage.PageID {
+	var pages []storage.PageID
+	entries := sc.catalogManager.ListPartitions(tableName)
+	if entry, err := sc.catalogManager.GetTable(tableName); err == nil {
+		entries = append(entries, entry)
+	}
+	for _, entry := range entries {
+		if entry.RowsPage != 0 {
+			pages = append(pages, entry.RowsPage)
+		}
+	}
+	return pages
+}
+
+// removeTable removes a table from the Schema and Catalog Managers. It
+// returns a function that undoes the removal.
+func (sc *SystemCatalog) removeTable(tableName string) (func(), error) {
+	// Keep everything needed to undo the drop
+	schema, err := sc.schemaManager.GetSchema(tableName)
+	if err != nil {
+		return nil, err
+	}
+	version, _ := sc.schemaManager.GetSchemaVersion(tableName)
+	constraints, _ := sc.schemaManager.GetConstraints(tableName)
+	entry, err := sc.catalogManager.GetTable(tableName)
+	if err != nil {
+		return nil, err
+	}
+	stats, _ := sc.catalogManager.GetTableStatistics(tableName)
+	indexes := sc.catalogManager.ListIndexes(tableName)
+	partitions := sc.catalogManager.ListPartitions(tableName)
+
+	if err := sc.catalogManager.DropTable(tableName); err != nil {
+		return nil, err
+	}
+	sc.schemaManager.DropSchema(tableName)
+
+	return func() {
+		sc.schemaManager.restoreSchema(schema, version, constraints)
+		sc.catalogManager.restoreTable(entry, stats, indexes)
+		sc.catalogManager.restorePartitions(partitions)
+	}, nil
+}
+
+// AlterTable commits a schema change made by AlterSchema and persists the
+// catalog. Renaming a table moves its catalog entry; the schema version is
+// bumped so cached plans over the table are rebuilt.
+func (sc *SystemCatalog) AlterTable(change *SchemaChange) error {
+	sc.mutex.Lock()
+	defer sc.mutex.Unlock()
+
+	if change == nil {
+		return fmt.Errorf("schema change cannot be nil")
+	}
+
+	tableName, newName := change.Previous.TableName, change.Schema.TableName
+	if IsSystemTable(tableName) || IsSystemTable(newName) {
+		return fmt.Errorf("cannot alter system table %s", tableName)
+	}
+
+	previous, err := sc.schemaManager.GetSchema(tableName)
+	if err != nil {
+		return err
+	}
+	if previous != change.Previous {
+		return fmt.Errorf("table %s was changed concurrently", tableName)
+	}
+	version, _ := sc.schemaManager.GetSchemaVersion(tableName)
+	constraints, _ := sc.schemaManager.GetConstraints(tableName)
+
+	if change.Renamed() {
+		if err := sc.catalogManager.RenameTable(tableName, newName); err != nil {
+			return err
+		}
+		if err := sc.schemaManager.RenameSchema(tableName, newName); err != nil {
+			sc.catalogManager.RenameTable(newName, tableName)
+			return err
+		}
+	}
+
+	undo := func() {
+		if change.Renamed() {
+			sc.schemaManager.DropSchema(newName)
+			sc.catalogManager.RenameTable(newName, tableName)
+		}
+		sc.schemaManager.restoreSchema(previous, version, constraints)
+	}
+
+	if err := sc.schemaManager.UpdateSchema(change.Schema); err != nil {
+		undo()
+		return err
+	}
+	sc.schemaManager.setConstraints(newName, change.Constraints)
+
+	// Renamed columns carry over to the catalog's index entries
+	indexColumns := make(map[*IndexCatalogEntry][]string)
+	for _, index := range change.Schema.Indexes {
+		if entry, err := sc.catalogManager.GetIndex(index.Name); err == nil {
+			indexColumns[entry] = entry.Columns
+			entry.Columns = append([]string{}, index.Columns...)
+		}
+	}
+
+	// Rows rewritten to the altered schema were stored under a new rows page
+	entry, err := sc.catalogManager.GetTable(newName)
+	if err != nil {
+		undo()
+		return err
+	}
+	rowsPage := entry.RowsPage
+	if change.rowsPage != 0 {
+		entry.RowsPage = change.rowsPage
+	}
+
+	if err := sc.persist(); err != nil {
+		entry.RowsPage = rowsPage
+		for entry, columns := range indexColumns {
+			entry.Columns = columns
+		}
+		undo()
+		return fmt.Errorf("failed to persist catalog: %w", err)
+	}
+
+	return nil
+}
+
+// CreateIndex registers a new index and persists the catalog. The index is
+// added to the table's schema, whose version is bumped so cached plans over
+// the table are rebuilt.
+func (sc *SystemCatalog) CreateIndex(entry *IndexCatalogEntry) error {
+	sc.mutex.Lock()
+	defer sc.mutex.Unlock()
+
+	if entry == nil {
+		return fmt.Errorf("index entry cannot be nil")
+	}
+
+	if IsSystemTable(entry.TableName) {
+		return fmt.Errorf("cannot create index on system table %s", entry.TableName)
+	}
+
+	schema, err := sc.schemaManager.GetSchema(entry.TableName)
+	if err != nil {
+		return err
+	}
+
+	entry.IndexID = sc.nextIndexID
+	if err := sc.catalogManager.CreateIndex(entry); err != nil {
+		return err
+	}
+	sc.nextIndexID++
+
+	indexes := append(append([]*IndexInfo{}, schema.Indexes...), indexInfo(entry))
+	undo, err := sc.replaceIndexes(entry.TableName, indexes)
+	if err != nil {
+		sc.catalogManager.DropIndex(entry.IndexName)
+		return err
+	}
+
+	if err := sc.persist(); err != nil {
+		undo()
+		sc.catalogManager.DropIndex(entry.IndexName)
+		return fmt.Errorf("failed to persist catalog: %w", err)
+	}
+
+	return nil
+}
+
+// DropIndex removes an index and persists the catalog
+func (sc *SystemCatalog) DropIndex(indexName string) error {
+	sc.mutex.Lock()
+	defer sc.mutex.Unlock()
+
+	entry, err := sc.catalogManager.GetIndex(indexName)
+	if err != nil {
+		return err
+	}
+
+	if err := sc.catalogManager.DropIndex(indexName); err != nil {
+		return err
+	}
+
+	undo := func() {}
+	if schema, err := sc.schemaManager.GetSchema(entry.TableName); err == nil {
+		indexes := make([]*IndexInfo, 0, len(schema.Indexes))
+		for _, index := range schema.Indexes {
+			if index.Name != indexName {
+				indexes = append(indexes, index)
+			}
+		}
+		if undo, err = sc.replaceIndexes(entry.TableName, indexes); err != nil {
+			sc.catalogManager.CreateIndex(entry)
+			return err
+		}
+	}
+
+	if err := sc.persist(); err != nil {
+		undo()
+		sc.catalogManager.CreateIndex(entry)
+		return fmt.Errorf("failed to persist catalog: %w", err)
+	}
+
+	return nil
+}
+
+// replaceIndexes swaps the index list of a table's schema and bumps its
+// version. It returns a function that undoes the swap.
+func (sc *SystemCatalog) replaceIndexes(tableName string, indexes []*IndexInfo) (func(), error) {
+	schema, err := sc.schemaManager.GetSchema(tableName)
+	if err != nil {
+		return nil, err
+	}
+	version, _ := sc.schemaManager.GetSchemaVersion(tableName)
+	constraints, _ := sc.schemaManager.GetConstraints(tableName)
+
+	altered := *schema
+	altered.Indexes = indexes
+	if err := sc.schemaManager.UpdateSchema(&altered); err != nil {
+		return nil, err
+	}
+
+	return func() {
+		sc.schemaManager.restoreSchema(schema, version, constraints)
+	}, nil
+}
+
+// CreateView registers a view and persists the catalog. With replace an
+// existing view of the same name is replaced; its dependents are kept.
+func (sc *SystemCatalog) CreateView(entry *ViewCatalogEntry, replace bool) error {
+	sc.mutex.Lock()
+	defer sc.mutex.Unlock()
+
+	if entry == nil {
+		return fmt.Errorf("view entry cannot be nil")
+	}
+
+	if IsSystemTable(entry.ViewName) {
+		return fmt.Errorf("view name %s uses the reserved prefix %s", entry.ViewName, SystemTablePrefix)
+	}
+
+	previous, err := sc.catalogManager.GetView(entry.ViewName)
+	if err == nil {
+		if !replace || previous.Materialized {
+			return fmt.Errorf("view %s already exists", entry.ViewName)
+		}
+		sc.catalogManager.DropView(entry.ViewName)
+	}
+
+	if err := sc.catalogManager.CreateView(entry); err != nil {
+		if previous != nil {
+			sc.catalogManager.restoreView(previous)
+		}
+		return err
+	}
+
+	if err := sc.persist(); err != nil {
+		sc.catalogManager.DropView(entry.ViewName)
+		if previous != nil {
+			sc.catalogManager.restoreView(previous)
+		}
+		return fmt.Errorf("failed to persist catalog: %w", err)
+	}
+
+	return nil
+}
+
+// CreateMaterializedView registers a materialized view with the table
+// holding its rows and persists the catalog
+func (sc *SystemCatalog) CreateMaterializedView(schema *TableSchema, entry *ViewCatalogEntry) error {
+	sc.mutex.Lock()
+	defer sc.mutex.Unlock()
+
+	if schema == nil || entry == nil {
+		return fmt.Errorf("schema and view entry cannot be nil")
+	}
+
+	if schema.TableName != entry.ViewName {
+		return fmt.Errorf("materialized view %s must be stored in a table of the same name", entry.ViewName)
+	}
+
+	if IsSystemTable(entry.ViewName) {
+		return fmt.Errorf("view name %s uses the reserved prefix %s", entry.ViewName, SystemTablePrefix)
+	}
+
+	if _, err := sc.catalogManager.GetView(entry.ViewName); err == nil {
+		return fmt.Errorf("view %s already exists", entry.ViewName)
+	}
+
+	if err := sc.schemaManager.RegisterSchema(schema); err != nil {
+		return err
+	}
+
+	undo := func() {
+		sc.catalogManager.DropView(entry.ViewName)
+		sc.catalogManager.DropTable(schema.TableName)
+		sc.schemaManager.DropSchema(schema.TableName)
+	}
+
+	table := &TableCatalogEntry{
+		TableName: schema.TableName,
+		TableID:   sc.nextTableID,
+	}
+	if err := sc.catalogManager.CreateTable(table); err != nil {
+		sc.schemaManager.DropSchema(schema.TableName)
+		return err
+	}
+	sc.nextTableID++
+
+	entry.Materialized = true
+	if err := sc.catalogManager.CreateView(entry); err != nil {
+		undo()
+		return err
+	}
+
+	if err := sc.persist(); err != nil {
+		undo()
+		return fmt.Errorf("failed to persist catalog: %w", err)
+	}
+
+	return nil
+}
+
+// DropView removes a view, and the table of a materialized view, and
+// persists the catalog. Without cascade it fails if other views depend on
+// the view; with cascade they are dropped too.
+func (sc *SystemCatalog) DropView(viewName string, cascade bool) error {
+	sc.mutex.Lock()
+	defer sc.mutex.Unlock()
+
+	if _, err := sc.catalogManager.GetView(viewName); err != nil {
+		return err
+	}
+
+	undo, err := sc.dropDependents(viewName, cascade)
+	if err != nil {
+		return err
+	}
+
+	undoView, err := sc.removeView(viewName)
+	if err != nil {
+		undo()
+		return err
+	}
+
+	if err := sc.persist(); err != nil {
+		undoView()
+		undo()
+		return fmt.Errorf("failed to persist catalog: %w", err)
+	}
+
+	return nil
+}
+
+// DependentViews returns the views that depend on a table or view,
+// directly or through other views, each after the views that depend on it
+func (sc *SystemCatalog) DependentViews(name string) []string {
+	sc.mutex.Lock()
+	defer sc.mutex.Unlock()
+
+	return compiler.DependentViews(NewCompilerCatalog(sc.catalogManager), name)
+}
+
+// dropDependents removes the views that depend on a table or view. Without
+// cascade it fails if there are any. It returns a function that undoes the
+// removal.
+func (sc *SystemCatalog) dropDependents(name string, cascade bool) (func(), error) {
+	dependents := compiler.DependentViews(NewCompilerCatalog(sc.catalogManager), name)
+	if len(dependents) > 0 && !cascade {
+		return nil, fmt.Errorf("cannot drop %s because views depend on it: %s", name, strings.Join(dependents, ", "))
+	}
+
+	var undos []func()
+	undo := func() {
+		for i := len(undos) - 1; i >= 0; i-- {
+			undos[i]()
+		}
+	}
+	for _, viewName := range dependents {
+		undoView, err := sc.removeView(viewName)
+		if err != nil {
+			undo()
+			return nil, err
+		}
+		undos = append(undos, undoView)
+	}
+
+	return undo, nil
+}
+
+// removeView removes a view, and the table of a materialized view, from
+// the catalog. It returns a function that undoes the removal.
+func (sc *SystemCatalog) removeView(viewName string) (func(), error) {
+	entry, err := sc.catalogManager.GetView(viewName)
+	if err != nil {
+		return nil, err
+	}
+
+	undoTable := func() {}
+	if entry.Materialized {
+		if undoTable, err = sc.removeTable(viewName); err != nil {
+			return nil, err
+		}
+	}
+	sc.catalogManager.DropView(viewName)
+
+	return func() {
+		undoTable()
+		sc.catalogManager.restoreView(entry)
+	}, nil
+}
+
+// indexInfo describes a catalog index in a table schema
+func indexInfo(entry *IndexCatalogEntry) *IndexInfo {
+	return &IndexInfo{
+		Name:      entry.IndexName,
+		Columns:   append([]string{}, entry.Columns...),
+		IsUnique:  entry.IsUnique,
+		IndexType: entry.IndexType,
+	}
+}
+
+// AddConstraint adds a table constraint and persists the catalog
+func (sc *SystemCatalog) AddConstraint(tableName string, constraint *Constraint) error {
+	sc.mutex.Lock()
+	defer sc.mutex.Unlock()
+
+	previous, _ := sc.schemaManager.GetConstraints(tableName)
+	if err := sc.schemaManager.AddConstraint(tableName, constraint); err != nil {
+		return err
+	}
+
+	if err := sc.persist(); err != nil {
+		sc.schemaManager.setConstraints(tableName, previous)
+		return fmt.Errorf("failed to persist catalog: %w", err)
+	}
+
+	return nil
+}
+
+// Generation returns the number of catalog versions committed to storage
+func (sc *SystemCatalog) Generation() uint64 {
+	sc.mutex.Lock()
+	defer sc.mutex.Unlock()
+	return sc.generation
+}
+
+// ============================================================================
+// Snapshot and load
+// ============================================================================
+
+// snapshot renders the in-memory catalog as system table rows
+func (sc *SystemCatalog) snapshot() map[string][][]interface{} {
+	rows := make(map[string][][]interface{})
+
+	tableNames := sc.schemaManager.ListSchemas()
+	sort.Strings(tableNames)
+
+	for _, tableName := range tableNames {
+		if IsSystemTable(tableName) {
+			continue
+		}
+
+		schema, err := sc.schemaManager.GetSchema(tableName)
+		if err != nil {
+			continue
+		}
+		entry, err := sc.catalogManager.GetTable(tableName)
+		if err != nil {
+			continue
+		}
+
+		ttlColumn, ttlSeconds := "", int64(0)
+		if schema.TTL != nil {
+			ttlColumn, ttlSeconds = schema.TTL.Column, int64(schema.TTL.Duration/time.Second)
+		}
+
+		rows[SysTablesTable] = append(rows[SysTablesTable], []interface{}{
+			entry.TableName, int64(entry.TableID), entry.SchemaName, entry.Owner,
+			entry.CreatedAt, entry.ModifiedAt, int64(entry.RowCount),
+			int64(schema.Version), schema.Clustered, ttlColumn, ttlSeconds,
+			schema.AutoIncrement, int64(entry.RowsPage),
+		})
+
+		for position, col := range schema.Columns {
+			pkPosition := 0
+			for i, pkCol := range schema.PrimaryKey {
+				if pkCol == col.Name {
+					pkPosition = i + 1
+				}
+			}
+			rows[SysColumnsTable] = append(rows[SysColumnsTable], []interface{}{
+				tableName, col.Name, int64(position), int64(col.Type), col.Nullable, int64(pkPosition), col.Default,
+			})
+		}
+
+		constraints, _ := sc.schemaManager.GetConstraints(tableName)
+		for _, c := range constraints {
+			rows[SysConstraintsTable] = append(rows[SysConstraintsTable], []interface{}{
+				tableName, c.Name, int64(c.Type), strings.Join(c.Columns, ","), c.CheckExpression,
+			})
+		}
+
+		for _, fk := range schema.ForeignKeys {
+			rows[SysForeignKeysTable] = append(rows[SysForeignKeysTable], []interface{}{
+				tableName, fk.Name, strings.Join(fk.Columns, ","), fk.RefTable,
+				strings.Join(fk.RefColumns, ","), int64(fk.OnDelete), int64(fk.OnUpdate),
+			})
+		}
+
+		if entry.Partitioning != nil {
+			partitions := sc.catalogManager.ListPartitions(tableName)
+			for position, bound := range entry.Partitioning.Partitions {
+				var partitionID, rowsPage int64
+				if position < len(partitions) {
+					partitionID = int64(partitions[position].TableID)
+					rowsPage = int64(partitions[position].RowsPage)
+				}
+				row := func(value interface{}) []interface{} {
+					return []interface{}{
+						tableName, bound.Name, partitionID, int64(position),
+						int64(entry.Partitioning.Method), entry.Partitioning.Column, value,
+						rowsPage,
+					}
+				}
+				if len(bound.Values) == 0 {
+					rows[SysPartitionsTable] = append(rows[SysPartitionsTable], row(bound.LessThan))
+				}
+				for _, value := range bound.Values {
+					rows[SysPartitionsTable] = append(rows[SysPartitionsTable], row(value))
+				}
+			}
+		}
+
+		indexes := sc.catalogManager.ListIndexes(tableName)
+		sort.Slice(indexes, func(i, j int) bool { return indexes[i].IndexName < indexes[j].IndexName })
+		for _, idx := range indexes {
+			rows[SysIndexesTable] = append(rows[SysIndexesTable], []interface{}{
+				idx.IndexName, int64(idx.IndexID), idx.TableName, strings.Join(idx.Columns, ","),
+				idx.IsUnique, idx.IsPrimary, int64(idx.IndexType), idx.CreatedAt,
+			})
+		}
+	}
+
+	viewNames := sc.catalogManager.ListViews()
+	sort.Strings(viewNames)
+	for _, viewName := range viewNames {
+		view, err := sc.catalogManager.GetView(viewName)
+		if err != nil {
+			continue
+		}
+		rows[SysViewsTable] = append(rows[SysViewsTable], []interface{}{
+			view.ViewName, view.Definition, strings.Join(view.Columns, ","), view.Materialized,
+			strings.Join(view.DependsOn, ","), view.CreatedAt,
+		})
+	}
+
+	return rows
+}
+
+// load rebuilds the Schema and Catalog Managers from system table rows
+func (sc *SystemCatalog) load(tables map[string][][]interface{}) error {
+	schemas := make(map[string]*TableSchema)
+	entries := make(map[string]*TableCatalogEntry)
+	var order []string
+
+	for _, row := range tables[SysTablesTable] {
+		name := row[0].(string)
+		entries[name] = &TableCatalogEntry{
+			TableName:  name,
+			TableID:    uint64(row[1].(int64)),
+			SchemaName: row[2].(string),
+			Owner:      row[3].(string),
+			CreatedAt:  row[4].(time.Time),
+			ModifiedAt: row[5].(time.Time),
+			RowCount:   uint64(row[6].(int64)),
+		}
+		schemas[name] = &TableSchema{
+			TableName: name,
+			Version:   int(row[7].(int64)),
+			Clustered: row[8].(bool),
+		}
+		// Catalogs written before row expiry, AUTO_INCREMENT or stored rows
+		// existed lack their columns
+		if len(row) > 10 {
+			if seconds := row[10].(int64); seconds > 0 {
+				schemas[name].TTL = &TTLPolicy{Column: row[9].(string), Duration: time.Duration(seconds) * time.Second}
+			}
+		}
+		if len(row) > 11 {
+			schemas[name].AutoIncrement = row[11].(string)
+		}
+		if len(row) > 12 {
+			entries[name].RowsPage = storage.PageID(row[12].(int64))
+		}
+		order = append(order, name)
+
+		if id := uint64(row[1].(int64)); id >= sc.nextTableID {
+			sc.nextTableID = id + 1
+		}
+	}
+
+	// Columns are stored in position order; primary key order comes from pk_position
+	pkColumns := make(map[string]map[int]string)
+	for _, row := range tables[SysColumnsTable] {
+		schema, ok := schemas[row[0].(string)]
+		if !ok {
+			return fmt.Errorf("column %v refers to unknown table %s", row[1], row[0])
+		}
+		column := ColumnInfo{
+			Name:      row[1].(string),
+			Type:      ColumnType(row[3].(int64)),
+			Nullable:  row[4].(bool),
+			TableName: schema.TableName,
+		}
+		// Catalogs written before column defaults were stored lack them
+		if len(row) > 6 {
+			column.Default = row[6]
+		}
+		schema.Columns = append(schema.Columns, column)
+		if pk := int(row[5].(int64)); pk > 0 {
+			if pkColumns[schema.TableName] == nil {
+				pkColumns[schema.TableName] = make(map[int]string)
+			}
+			pkColumns[schema.TableName][pk] = row[1].(string)
+		}
+	}
+	for tableName, cols := range pkColumns {
+		for i := 1; i <= len(cols); i++ {
+			schemas[tableName].PrimaryKey = append(schemas[tableName].PrimaryKey, cols[i])
+		}
+	}
+
+	for _, row := range tables[SysForeignKeysTable] {
+		schema, ok := schemas[row[0].(string)]
+		if !ok {
+			return fmt.Errorf("foreign key refers to unknown table %s", row[0])
+		}
+		schema.ForeignKeys = append(schema.ForeignKeys, &ForeignKey{
+			Name:       row[1].(string),
+			Columns:    splitColumns(row[2].(string)),
+			RefTable:   row[3].(string),
+			RefColumns: splitColumns(row[4].(string)),
+			OnDelete:   ReferentialAction(row[5].(int64)),
+			OnUpdate:   ReferentialAction(row[6].(int64)),
+		})
+	}
+
+	constraints := make(map[string][]*Constraint)
+	for _, row := range tables[SysConstraintsTable] {
+		tableName := row[0].(string)
+		constraints[tableName] = append(constraints[tableName], &Constraint{
+			Name:            row[1].(string),
+			Type:            ConstraintType(row[2].(int64)),
+			Columns:         splitColumns(row[3].(string)),
+			CheckExpression: row[4].(string),
+		})
+	}
+
+	indexes := make(map[string][]*IndexCatalogEntry)
+	for _, row := range tables[SysIndexesTable] {
+		entry := &IndexCatalogEntry{
+			IndexName: row[0].(string),
+			IndexID:   uint64(row[1].(int64)),
+			TableName: row[2].(string),
+			Columns:   splitColumns(row[3].(string)),
+			IsUnique:  row[4].(bool),
+			IsPrimary: row[5].(bool),
+			IndexType: IndexType(row[6].(int64)),
+			CreatedAt: row[7].(time.Time),
+		}
+		indexes[entry.TableName] = append(indexes[entry.TableName], entry)
+
+		if entry.IndexID >= sc.nextIndexID {
+			sc.nextIndexID = entry.IndexID + 1
+		}
+	}
+
+	// Partition rows arrive in position order; LIST partitions span several rows
+	partitions := make(map[string][]*TableCatalogEntry)
+	for _, row := range tables[SysPartitionsTable] {
+		parent, ok := entries[row[0].(string)]
+		if !ok {
+			return fmt.Errorf("partition %s refers to unknown table %s", row[1], row[0])
+		}
+		if parent.Partitioning == nil {
+			parent.Partitioning = &compiler.PartitionScheme{
+				Method: parser.PartitionMethod(row[4].(int64)),
+				Column: row[5].(string),
+			}
+		}
+
+		scheme := parent.Partitioning
+		position := int(row[3].(int64))
+		if position == len(scheme.Partitions) {
+			scheme.Partitions = append(scheme.Partitions, &compiler.PartitionBound{Name: row[1].(string)})
+			partition := &TableCatalogEntry{
+				TableName:   row[1].(string),
+				TableID:     uint64(row[2].(int64)),
+				SchemaName:  parent.SchemaName,
+				Owner:       parent.Owner,
+				CreatedAt:   parent.CreatedAt,
+				ModifiedAt:  parent.ModifiedAt,
+				ParentTable: parent.TableName,
+			}
+			if len(row) > 7 {
+				partition.RowsPage = storage.PageID(row[7].(int64))
+			}
+			partitions[parent.TableName] = append(partitions[parent.TableName], partition)
+			if id := uint64(row[2].(int64)); id >= sc.nextTableID {
+				sc.nextTableID = id + 1
+			}
+		} else if position != len(scheme.Partitions)-1 {
+			return fmt.Errorf("partition %s of table %s is out of order", row[1], row[0])
+		}
+
+		bound := scheme.Partitions[position]
+		switch scheme.Method {
+		case parser.PartitionByRange:
+			bound.LessThan = row[6]
+		case parser.PartitionByList:
+			bound.Values = append(bound.Values, row[6])
+		}
+	}
+
+	for _, name := range order {
+		schema := schemas[name]
+		if err := sc.schemaManager.validateSchema(schema); err != nil {
+			return fmt.Errorf("invalid schema for table %s: %w", name, err)
+		}
+		for _, index := range indexes[name] {
+			schema.Indexes = append(schema.Indexes, indexInfo(index))
+		}
+		sc.schemaManager.restoreSchema(schema, schema.Version, constraints[name])
+		sc.catalogManager.restoreTable(entries[name], nil, indexes[name])
+		sc.catalogManager.restorePartitions(partitions[name])
+	}
+
+	// The table of a materialized view was loaded above
+	for _, row := range tables[SysViewsTable] {
+		view := &ViewCatalogEntry{
+			ViewName:     row[0].(string),
+			Definition:   row[1].(string),
+			Columns:      splitColumns(row[2].(string)),
+			Materialized: row[3].(bool),
+			DependsOn:    splitColumns(row[4].(string)),
+			CreatedAt:    row[5].(time.Time),
+		}
+		if _, ok := schemas[view.ViewName]; ok != view.Materialized {
+			return fmt.Errorf("view %s does not match the tables in the catalog", view.ViewName)
+		}
+		sc.catalogManager.restoreView(view)
+	}
+
+	return nil
+}
+
+// splitColumns splits a comma-separated column list
+func splitColumns(s string) []string {
+	if s == "" {
+		return nil
+	}
+	return strings.Split(s, ",")
+}
+
+// ============================================================================
+// Page chain I/O
+// ============================================================================
+
+// persist writes the current catalog image to a new page chain and commits
+// it by rewriting the root page
+func (sc *SystemCatalog) persist() error {
+	image := encodeCatalogImage(sc.snapshot())
+
+	if sc.pageSize <= catalogChainHeaderSize || sc.pageSize < catalogRootHeaderSize {
+		return fmt.Errorf("page size %d too small for catalog", sc.pageSize)
+	}
+
+	chain, err := writePageChain(sc.storage, sc.pageSize, image)
+	if err != nil {
+		return fmt.Errorf("failed to write catalog: %w", err)
+	}
+
+	// Commit point: the root page now points at the new chain
+	root := make([]byte, sc.pageSize)
+	copy(root[0:8], catalogMagic)
+	binary.LittleEndian.PutUint32(root[8:12], catalogFormatVersion)
+	binary.LittleEndian.PutUint64(root[12:20], sc.generation+1)
+	binary.LittleEndian.PutUint64(root[20:28], uint64(len(image)))
+	if len(chain) > 0 {
+		binary.LittleEndian.PutUint64(root[28:36], uint64(chain[0]))
+	}
+	binary.LittleEndian.PutUint32(root[36:40], crc32.ChecksumIEEE(image))
+
+	if err := sc.storage.WritePage(&storage.Page{ID: CatalogRootPageID, Data: root}); err != nil {
+		releasePages(sc.storage, chain)
+		return fmt.Errorf("failed to write catalog root: %w", err)
+	}
+
+	if err := sc.storage.Sync(); err != nil {
+		return fmt.Errorf("failed to sync catalog root: %w", err)
+	}
+
+	// The old chain is unreachable now; freeing it is best effort
+	releasePages(sc.storage, sc.chain)
+	sc.chain = chain
+	sc.generation++
+
+	return nil
+}
+
+// readImage follows the page chain referenced by the root page
+func (sc *SystemCatalog) readImage(root *storage.Page) ([]byte, error) {
+	if len(root.Data) < catalogRootHeaderSize {
+		return nil, fmt.Errorf("catalog root page is truncated")
+	}
+
+	version := binary.LittleEndian.Uint32(root.Data[8:12])
+	if version != catalogFormatVersion {
+		return nil, fmt.Errorf("unsupported catalog format version %d", version)
+	}
+
+	generation := binary.LittleEndian.Uint64(root.Data[12:20])
+	length := binary.LittleEndian.Uint64(root.Data[20:28])
+	first := storage.PageID(binary.LittleEndian.Uint64(root.Data[28:36]))
+	checksum := binary.LittleEndian.Uint32(root.Data[36:40])
+
+	image, chain, err := readPageChain(sc.storage, first, length)
+	if err != nil {
+		return nil, fmt.Errorf("catalog %w", err)
+	}
+	if crc32.ChecksumIEEE(image) != checksum {
+		return nil, fmt.Errorf("catalog checksum mismatch")
+	}
+
+	sc.generation = generation
+	sc.chain = chain
+	return image, nil
+}
+
+// writePageChain writes an image to newly allocated pages, each starting
+// with the ID of the next, and syncs them. It returns the pages in chain
+// order; if it fails, none stay allocated.
+func writePageChain(engine storage.StorageEngine, pageSize int, image []byte) ([]storage.PageID, error) {
+	payloadSize := pageSize - catalogChainHeaderSize
+	if payloadSize <= 0 {
+		return nil, fmt.Errorf("page size %d too small for a page chain", pageSize)
+	}
+
+	// Allocate the new chain up front
+	pageCount := (len(image) + payloadSize - 1) / payloadSize
+	chain := make([]storage.PageID, 0, pageCount)
+	for i := 0; i < pageCount; i++ {
+		pageID, err := engine.AllocatePage()
+		if err != nil {
+			releasePages(engine, chain)
+			return nil, fmt.Errorf("failed to allocate page: %w", err)
+		}
+		chain = append(chain, pageID)
+	}
+
+	// Write the chain
+	for i, pageID := range chain {
+		data := make([]byte, pageSize)
+		if i+1 < len(chain) {
+			binary.LittleEndian.PutUint64(data[0:8], uint64(chain[i+1]))
+		}
+		start := i * payloadSize
+		end := start + payloadSize
+		if end > len(image) {
+			end = len(image)
+		}
+		copy(data[catalogChainHeaderSize:], image[start:end])
+
+		if err := engine.WritePage(&storage.Page{ID: pageID, Data: data}); err != nil {
+			releasePages(engine, chain)
+			return nil, fmt.Errorf("failed to write page %d: %w", pageID, err)
+		}
+	}
+
+	if err := engine.Sync(); err != nil {
+		releasePages(engine, chain)
+		return nil, fmt.Errorf("failed to sync pages: %w", err)
+	}
+
+	return chain, nil
+}
+
+// readPageChain reads an image of length bytes from the page chain starting
+// at first, and returns it with the pages of the chain
+func readPageChain(engine storage.StorageEngine, first storage.PageID, length uint64) ([]byte, []storage.PageID, error) {
+	image := make([]byte, 0, length)
+	var chain []storage.PageID
+	for next := first; next != 0 && uint64(len(image)) < length; {
+		page, err := engine.ReadPage(next)
+		if err != nil {
+			return nil, nil, fmt.Errorf("page %d cannot be read: %w", next, err)
+		}
+		if len(page.Data) < catalogChainHeaderSize {
+			return nil, nil, fmt.Errorf("page %d is truncated", next)
+		}
+
+		chain = append(chain, next)
+		payload := page.Data[catalogChainHeaderSize:]
+		if remaining := length - uint64(len(image)); uint64(len(payload)) > remaining {
+			payload = payload[:remaining]
+		}
+		image = append(image, payload...)
+		next = storage.PageID(binary.LittleEndian.Uint64(page.Data[0:8]))
+	}
+
+	if uint64(len(image)) != length {
+		return nil, nil, fmt.Errorf("image is truncated: expected %d bytes, got %d", length, len(image))
+	}
+	return image, chain, nil
+}
+
+// releasePages deallocates pages that are no longer referenced
+func releasePages(engine storage.StorageEngine, pages []storage.PageID) {
+	for _, pageID := range pages {
+		engine.DeallocatePage(pageID)
+	}
+}
+
+// ============================================================================
+// Catalog image encoding
+// ============================================================================
+
+// Value type tags used in the catalog image
+const (
+	catalogValueNull byte = iota
+	catalogValueInt
+	catalogValueFloat
+	catalogValueString
+	catalogValueBool
+	catalogValueTime
+	catalogValueBytes
+)
+
+// encodeCatalogImage serializes system table rows as
+// table count, then per table: name, row count, rows of tagged values
+func encodeCatalogImage(tables map[string][][]interface{}) []byte {
+	names := make([]string, 0, len(tables))
+	for name := range tables {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var buf bytes.Buffer
+	writeUvarint(&buf, uint64(len(names)))
+	for _, name := range names {
+		writeString(&buf, name)
+		writeUvarint(&buf, uint64(len(tables[name])))
+		for _, row := range tables[name] {
+			writeUvarint(&buf, uint64(len(row)))
+			for _, value := range row {
+				encodeCatalogValue(&buf, value)
+			}
+		}
+	}
+	return buf.Bytes()
+}
+
+// decodeCatalogImage parses an image written by encodeCatalogImage
+func decodeCatalogImage(image []byte) (map[string][][]interface{}, error) {
+	r := bytes.NewReader(image)
+	tables := make(map[string][][]interface{})
+
+	tableCount, err := binary.ReadUvarint(r)
+	if err != nil {
+		return nil, err
+	}
+	for t := uint64(0); t < tableCount; t++ {
+		name, err := readString(r)
+		if err != nil {
+			return nil, err
+		}
+		rowCount, err := binary.ReadUvarint(r)
+		if err != nil {
+			return nil, err
+		}
+		rows := make([][]interface{}, 0, rowCount)
+		for i := uint64(0); i < rowCount; i++ {
+			width, err := binary.ReadUvarint(r)
+			if err != nil {
+				return nil, err
+			}
+			row := make([]interface{}, width)
+			for j := range row {
+				if row[j], err = decodeCatalogValue(r); err != nil {
+					return nil, err
+				}
+			}
+			rows = append(rows, row)
+		}
+		tables[name] = rows
+	}
+
+	return tables, nil
+}
+
+// encodeCatalogValue writes a tagged value
+func encodeCatalogValue(buf *bytes.Buffer, value interface{}) {
+	switch v := value.(type) {
+	case nil:
+		buf.WriteByte(catalogValueNull)
+	case int64:
+		buf.WriteByte(catalogValueInt)
+		writeVarint(buf, v)
+	case int:
+		buf.WriteByte(catalogValueInt)
+		writeVarint(buf, int64(v))
+	case float64:
+		buf.WriteByte(catalogValueFloat)
+		writeUvarint(buf, math.Float64bits(v))
+	case string:
+		buf.WriteByte(catalogValueString)
+		writeString(buf, v)
+	case bool:
+		buf.WriteByte(catalogValueBool)
+		if v {
+			buf.WriteByte(1)
+		} else {
+			buf.WriteByte(0)
+		}
+	case time.Time:
+		buf.WriteByte(catalogValueTime)
+		writeVarint(buf, v.UnixNano())
+	case []byte:
+		buf.WriteByte(catalogValueBytes)
+		writeString(buf, string(v))
+	default:
+		buf.WriteByte(catalogValueString)
+		writeString(buf, fmt.Sprintf("%v", v))
+	}
+}
+
+// decodeCatalogValue reads a tagged value
+func decodeCatalogValue(r *bytes.Reader) (interface{}, error) {
+	tag, err := r.ReadByte()
+	if err != nil {
+		return nil, err
+	}
+
+	switch tag {
+	case catalogValueNull:
+		return nil, nil
+	case catalogValueInt:
+		return binary.ReadVarint(r)
+	case catalogValueFloat:
+		bits, err := binary.ReadUvarint(r)
+		return math.Float64frombits(bits), err
+	case catalogValueString:
+		return readString(r)
+	case catalogValueBool:
+		b, err := r.ReadByte()
+		return b == 1, err
+	case catalogValueTime:
+		nanos, err := binary.ReadVarint(r)
+		return time.Unix(0, nanos), err
+	case catalogValueBytes:
+		s, err := readString(r)
+		return []byte(s), err
+	default:
+		return nil, fmt.Errorf("unknown catalog value tag %d", tag)
+	}
+}
+
+func writeUvarint(buf *bytes.Buffer, v uint64) {
+	var tmp [binary.MaxVarintLen64]byte
+	buf.Write(tmp[:binary.PutUvarint(tmp[:], v)])
+}
+
+func writeVarint(buf *bytes.Buffer, v int64) {
+	var tmp [binary.MaxVarintLen64]byte
+	buf.Write(tmp[:binary.PutVarint(tmp[:], v)])
+}
+
+func writeString(buf *bytes.Buffer, s string) {
+	writeUvarint(buf, uint64(len(s)))
+	buf.WriteString(s)
+}
+
+func readString(r *bytes.Reader) (string, error) {
+	n, err := binary.ReadUvarint(r)
+	if err != nil {
+		return "", err
+	}
+	if n > uint64(r.Len()) {
+		return "", fmt.Errorf("string length %d exceeds remaining image", n)
+	}
+	// An empty string may end the image, where Read would report EOF
+	b := make([]byte, n)
+	if _, err := io.ReadFull(r, b); err != nil {
+		return "", err
+	}
+	return string(b), nil
+}
@@ -48,7 +48,8 @@ type Transaction struct {
 	AcquiredLocks []*Lock
 
 	// Savepoints
-	Savepoints map[string]*Savepoint
+	Savepoints    map[string]*Savepoint
+	nextSavepoint int
 
 	// Statistics
 	RowsRead     uint64
@@ -86,6 +87,9 @@ type TransactionOperation struct {
 	Plan      *optimizer.QueryPlan
 	Timestamp time.Time
 	Result    *ResultSet
+
+	undo    func()       // Restores what the operation changed; nil if nothing
+	persist func() error // Stores the rows of the changed table; nil if nothing
 }
 
 // OperationType defines types of operations
@@ -104,6 +108,8 @@ type Savepoint struct {
 	TxnID     uint64
 	Timestamp time.Time
 	Position  int // Position in operation list
+
+	sequence int // Creation order within the transaction
 }
 
 // NewTransactionExecutor creates a new transaction executor
@@ -168,6 +174,24 @@ func (te *TransactionExecutor) CommitTransaction(txnID uint64) error {
 
 	// TODO: Flush WAL to disk
 
+	// The changed tables are stored before the transaction counts as
+	// committed; if that fails it is rolled back instead
+	operations := txn.Operations
+	if err := persistOperations(operations); err != nil {
+		txn.undoOperations(0)
+		persistOperations(operations) // best effort: store the restored rows
+		txn.State = TxnAborted
+		txn.EndTime = time.Now()
+
+		te.lockManager.ReleaseAllLocks(txnID)
+		te.mutex.Lock()
+		delete(te.activeTransactions, txnID)
+		te.mutex.Unlock()
+		txn.Cancel()
+
+		return fmt.Errorf("transaction %d rolled back: %w", txnID, err)
+	}
+
 	// Change state to committed
 	txn.State = TxnCommitted
 	txn.EndTime = time.Now()
@@ -208,7 +232,7 @@ func (te *TransactionExecutor) RollbackTransaction(txnID uint64) error {
 	// Change state to aborting
 	txn.State = TxnAborting
 
-	// TODO: Undo all operations using WAL
+	txn.undoOperations(0)
 
 	// Change state to aborted
 	txn.State = TxnAborted
@@ -301,7 +325,9 @@ func (te *TransactionExecutor) CreateSavepoint(txnID uint64, name string) error
 		TxnID:     txnID,
 		Timestamp: time.Now(),
 		Position:  len(txn.Operations),
+		sequence:  txn.nextSavepoint,
 	}
+	txn.nextSavepoint++
 
 	txn.Savepoints[name] = savepoint
 	return nil
@@ -320,17 +346,89 @@ func (te *TransactionExecutor) RollbackToSavepoint(txnID uint64, name string) er
 	txn.mutex.Lock()
 	defer txn.mutex.Unlock()
 
+	if txn.State != TxnActive {
+		return fmt.Errorf("transaction %d is not active", txnID)
+	}
+
 	savepoint, exists := txn.Savepoints[name]
 	if !exists {
 		return fmt.Errorf("savepoint %s not found in transaction %d", name, txnID)
 	}
 
-	// TODO: Undo operations after savepoint position
-	txn.Operations = txn.Operations[:savepoint.Position]
+	txn.undoOperations(savepoint.Position)
+
+	// The savepoint itself survives, but those created after it are gone
+	txn.dropSavepointsAfter(savepoint.sequence)
 
 	return nil
 }
 
+// ReleaseSavepoint destroys a savepoint and every savepoint created after it,
+// keeping the operations performed since
+func (te *TransactionExecutor) ReleaseSavepoint(txnID uint64, name string) error {
+	te.mutex.RLock()
+	txn, exists := te.activeTransactions[txnID]
+	te.mutex.RUnlock()
+
+	if !exists {
+		return fmt.Errorf("transaction %d not found", txnID)
+	}
+
+	txn.mutex.Lock()
+	defer txn.mutex.Unlock()
+
+	if txn.State != TxnActive {
+		return fmt.Errorf("transaction %d is not active", txnID)
+	}
+
+	savepoint, exists := txn.Savepoints[name]
+	if !exists {
+		return fmt.Errorf("savepoint %s not found in transaction %d", name, txnID)
+	}
+
+	delete(txn.Savepoints, name)
+	txn.dropSavepointsAfter(savepoint.sequence)
+
+	return nil
+}
+
+// undoOperations undoes the operations from position on, latest first, and
+// drops them. Callers must hold the transaction mutex.
+func (txn *Transaction) undoOperations(position int) {
+	for i := len(txn.Operations) - 1; i >= position; i-- {
+		if undo := txn.Operations[i].undo; undo != nil {
+			undo()
+		}
+	}
+	txn.Operations = txn.Operations[:position]
+}
+
+// persistOperations stores the rows of each table changed by operations,
+// once per table
+func persistOperations(operations []*TransactionOperation) error {
+	stored := make(map[string]bool)
+	for _, op := range operations {
+		if op.persist == nil || stored[op.TableName] {
+			continue
+		}
+		if err := op.persist(); err != nil {
+			return err
+		}
+		stored[op.TableName] = true
+	}
+	return nil
+}
+
+// dropSavepointsAfter removes the savepoints created after sequence. Callers
+// must hold the transaction mutex.
+func (txn *Transaction) dropSavepointsAfter(sequence int) {
+	for name, sp := range txn.Savepoints {
+		if sp.sequence > sequence {
+			delete(txn.Savepoints, name)
+		}
+	}
+}
+
 // GetTransaction retrieves transaction information
 func (te *TransactionExecutor) GetTransaction(txnID uint64) (*Transaction, error) {
 	te.mutex.RLock()
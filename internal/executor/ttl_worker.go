@@ -0,0 +1,255 @@
+// Package executor - TTL Worker component
+// Deletes rows that have outlived their table's expiry policy
+package executor
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+)
+
+// Default TTL worker settings
+const (
+	defaultTTLInterval  = time.Minute
+	defaultTTLBatchSize = 500
+)
+
+// TTLWorker periodically removes expired rows from tables with a TTL policy.
+// Each batch runs in its own short transaction under an exclusive table lock,
+// so expiry never blocks writers for longer than one batch.
+// Architecture: Part of Execution Engine Layer, runs alongside the Transaction Executor
+type TTLWorker struct {
+	catalog     *CatalogManager
+	txnExecutor *TransactionExecutor
+
+	interval  time.Duration
+	batchSize int
+	now       func() time.Time // replaceable in tests
+
+	// Rows removed per table since the worker was created
+	expired    map[string]uint64
+	statsMutex sync.RWMutex
+
+	stop    chan struct{}
+	done    chan struct{}
+	running bool
+	mutex   sync.Mutex
+}
+
+// NewTTLWorker creates a TTL worker over the tables of a catalog. A zero
+// interval or batch size selects the default.
+func NewTTLWorker(catalog *CatalogManager, txnExecutor *TransactionExecutor, interval time.Duration, batchSize int) *TTLWorker {
+	if interval <= 0 {
+		interval = defaultTTLInterval
+	}
+	if batchSize <= 0 {
+		batchSize = defaultTTLBatchSize
+	}
+
+	return &TTLWorker{
+		catalog:     catalog,
+		txnExecutor: txnExecutor,
+		interval:    interval,
+		batchSize:   batchSize,
+		now:         time.Now,
+		expired:     make(map[string]uint64),
+	}
+}
+
+// Start launches the background expiry loop
+func (w *TTLWorker) Start() {
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+
+	if w.running {
+		return
+	}
+
+	w.stop = make(chan struct{})
+	w.done = make(chan struct{})
+	w.running = true
+
+	go w.loop(w.stop, w.done)
+}
+
+// Stop halts the background loop and waits for the current pass to finish
+func (w *TTLWorker) Stop() {
+	w.mutex.Lock()
+	if !w.running {
+		w.mutex.Unlock()
+		return
+	}
+	w.running = false
+	close(w.stop)
+	done := w.done
+	w.mutex.Unlock()
+
+	<-done
+}
+
+// loop runs an expiry pass every interval until stopped
+func (w *TTLWorker) loop(stop <-chan struct{}, done chan<- struct{}) {
+	defer close(done)
+
+	ticker := time.NewTicker(w.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-stop:
+			return
+		case <-ticker.C:
+			// Errors are retried on the next tick
+			w.RunOnce()
+		}
+	}
+}
+
+// RunOnce performs one expiry pass over every table with a TTL policy and
+// returns the number of rows removed
+func (w *TTLWorker) RunOnce() (uint64, error) {
+	tableNames := w.catalog.schemaManager.ListSchemas()
+	sort.Strings(tableNames)
+
+	var total uint64
+	var firstErr error
+	for _, tableName := range tableNames {
+		schema, err := w.catalog.schemaManager.GetSchema(tableName)
+		if err != nil || schema.TTL == nil {
+			continue
+		}
+
+		removed, err := w.expireTable(schema)
+		total += removed
+		if err != nil && firstErr == nil {
+			firstErr = err
+		}
+	}
+
+	return total, firstErr
+}
+
+// expireTable deletes a table's expired rows in batches until none remain.
+// The rows of a partitioned table are expired one partition at a time.
+func (w *TTLWorker) expireTable(schema *TableSchema) (uint64, error) {
+	exec := w.txnExecutor.queryExecutor
+	exec.clusteredMutex.RLock()
+	indexes := exec.tableRows(w.catalog, schema.TableName)
+	exec.clusteredMutex.RUnlock()
+
+	if len(indexes) == 0 {
+		return 0, fmt.Errorf("table %s has a ttl but does not store rows", schema.TableName)
+	}
+	cutoff := w.now().Add(-schema.TTL.Duration)
+
+	var total uint64
+	for _, index := range indexes {
+		column := index.Schema().GetColumnIndex(schema.TTL.Column)
+		if column < 0 {
+			return total, fmt.Errorf("ttl column %s does not exist in table %s", schema.TTL.Column, schema.TableName)
+		}
+
+		for {
+			removed, err := w.expireBatch(schema.TableName, index, column, cutoff)
+			total += removed
+			if err != nil {
+				return total, err
+			}
+			if removed < uint64(w.batchSize) {
+				break
+			}
+		}
+	}
+	return total, nil
+}
+
+// expireBatch deletes up to batchSize expired rows in one transaction, which
+// stores the table's rows when it commits
+func (w *TTLWorker) expireBatch(tableName string, index *ClusteredIndex, column int, cutoff time.Time) (uint64, error) {
+	txn, err := w.txnExecutor.BeginTransaction(ReadCommitted)
+	if err != nil {
+		return 0, err
+	}
+
+	if err := w.txnExecutor.lockManager.AcquireTableLock(txn.ID, tableName, ExclusiveLock); err != nil {
+		w.txnExecutor.RollbackTransaction(txn.ID)
+		return 0, fmt.Errorf("ttl expiry on %s: %w", tableName, err)
+	}
+
+	keys := index.CollectKeys(func(row *Tuple) bool {
+		return isExpired(row.Values[column], cutoff)
+	}, w.batchSize)
+
+	// Rows go through the executor so the secondary indexes drop them too
+	exec := w.txnExecutor.queryExecutor
+	var expired [][]interface{}
+	exec.clusteredMutex.RLock()
+	for _, key := range keys {
+		if row := index.Get(key); row != nil && exec.deleteRow(index, tableName, key) {
+			expired = append(expired, row.Values)
+		}
+	}
+	exec.clusteredMutex.RUnlock()
+	removed := uint64(len(expired))
+
+	txn.mutex.Lock()
+	txn.Operations = append(txn.Operations, &TransactionOperation{
+		Type:      DeleteOp,
+		TableName: tableName,
+		Timestamp: time.Now(),
+		undo: func() {
+			for _, values := range expired {
+				exec.InsertRow(w.catalog, tableName, values)
+			}
+		},
+		persist: func() error {
+			return exec.saveRows(w.catalog, tableName)
+		},
+	})
+	txn.RowsModified += removed
+	txn.mutex.Unlock()
+
+	if err := w.txnExecutor.CommitTransaction(txn.ID); err != nil {
+		return 0, err
+	}
+
+	if removed > 0 {
+		w.statsMutex.Lock()
+		w.expired[tableName] += removed
+		w.statsMutex.Unlock()
+	}
+
+	return removed, nil
+}
+
+// Stats returns the number of rows removed per table
+func (w *TTLWorker) Stats() map[string]uint64 {
+	w.statsMutex.RLock()
+	defer w.statsMutex.RUnlock()
+
+	stats := make(map[string]uint64, len(w.expired))
+	for tableName, count := range w.expired {
+		stats[tableName] = count
+	}
+	return stats
+}
+
+// isExpired reports whether a TTL column value lies before cutoff.
+// Timestamps may be stored as time.Time, Unix seconds or RFC 3339 text;
+// NULL and unrecognized values never expire.
+func isExpired(value interface{}, cutoff time.Time) bool {
+	switch v := value.(type) {
+	case time.Time:
+		return v.Before(cutoff)
+	case int64:
+		return v < cutoff.Unix()
+	case int:
+		return int64(v) < cutoff.Unix()
+	case string:
+		t, err := time.Parse(time.RFC3339, v)
+		return err == nil && t.Before(cutoff)
+	default:
+		return false
+	}
+}
@@ -0,0 +1,270 @@
+package executor
+
+import (
+	"context"
+	"fmt"
+	"sync"
+
+	"relational-db/internal/parser"
+)
+
+// materializedView holds the rows of a materialized view as of its last
+// refresh. A refresh that is not concurrent holds the lock while it runs,
+// so scans wait for the new rows; a concurrent refresh only takes it to
+// swap them in, so scans keep reading the old rows meanwhile.
+type materializedView struct {
+	rows  *ResultSet // nil until the view is first populated
+	mutex sync.RWMutex
+}
+
+// snapshot returns the rows of the last refresh
+func (mv *materializedView) snapshot() (*ResultSet, bool) {
+	mv.mutex.RLock()
+	defer mv.mutex.RUnlock()
+	return mv.rows, mv.rows != nil
+}
+
+// materializedView returns the rows kept for a materialized view
+func (e *Executor) materializedView(name string) (*materializedView, bool) {
+	e.clusteredMutex.RLock()
+	defer e.clusteredMutex.RUnlock()
+
+	view, exists := e.materializedViews[name]
+	return view, exists
+}
+
+// RegisterMaterializedViews makes the materialized views of a catalog
+// loaded from storage known to scans. Their rows are not persisted, so each
+// must be refreshed before it is read.
+func (e *Executor) RegisterMaterializedViews(catalog *CatalogManager) {
+	e.clusteredMutex.Lock()
+	defer e.clusteredMutex.Unlock()
+
+	for _, name := range catalog.ListViews() {
+		if view, err := catalog.GetView(name); err == nil && view.Materialized {
+			if _, exists := e.materializedViews[name]; !exists {
+				e.materializedViews[name] = &materializedView{}
+			}
+		}
+	}
+}
+
+// CreateView compiles a view's query and registers the view in the
+// catalog, with the tables and views the query reads as its dependencies.
+// A materialized view is populated unless it is created WITH NO DATA.
+func (e *Executor) CreateView(ctx context.Context, catalog *SystemCatalog, planner *QueryPlanner, stmt *parser.CreateViewStatement) error {
+	viewName := stmt.ViewName.Value
+	if _, err := catalog.catalogManager.GetView(viewName); err == nil && stmt.IfNotExists {
+		return nil
+	}
+
+	prepared, err := planner.Prepare(stmt.String())
+	if err != nil {
+		return err
+	}
+	refs := prepared.Compiled.ResolvedRefs
+	if refs.ViewColumns == nil {
+		return fmt.Errorf("view %s has no columns", viewName)
+	}
+
+	entry := &ViewCatalogEntry{
+		ViewName:     viewName,
+		Definition:   stmt.Query.String(),
+		Materialized: stmt.Materialized,
+		DependsOn:    append([]string{}, refs.Dependencies...),
+	}
+	for _, col := range stmt.Columns {
+		entry.Columns = append(entry.Columns, col.Value)
+	}
+
+	if !stmt.Materialized {
+		if err := catalog.CreateView(entry, stmt.OrReplace); err != nil {
+			return err
+		}
+		planner.Cache().Invalidate(viewName)
+		return nil
+	}
+
+	// Any value can end up in a column whose type the compiler could not
+	// infer, so the columns are all nullable
+	schema := &TableSchema{TableName: viewName}
+	for _, col := range refs.ViewColumns.Columns {
+		schema.Columns = append(schema.Columns, ColumnInfo{
+			Name:      col.Name,
+			Type:      columnTypeOf(col.DataType),
+			Nullable:  true,
+			TableName: viewName,
+		})
+	}
+	if err := catalog.CreateMaterializedView(schema, entry); err != nil {
+		return err
+	}
+
+	e.clusteredMutex.Lock()
+	e.materializedViews[viewName] = &materializedView{}
+	e.clusteredMutex.Unlock()
+
+	if stmt.WithNoData {
+		return nil
+	}
+	return e.RefreshMaterializedView(ctx, catalog, planner, &parser.RefreshMaterializedViewStatement{ViewName: stmt.ViewName})
+}
+
+// DropView removes a view and, with CASCADE, the views that depend on it
+func (e *Executor) DropView(catalog *SystemCatalog, planner *QueryPlanner, stmt *parser.DropViewStatement) error {
+	viewName := stmt.ViewName.Value
+	entry, err := catalog.catalogManager.GetView(viewName)
+	if err != nil {
+		if stmt.IfExists {
+			return nil
+		}
+		return err
+	}
+	if entry.Materialized != stmt.Materialized {
+		if entry.Materialized {
+			return fmt.Errorf("%s is a materialized view, use DROP MATERIALIZED VIEW", viewName)
+		}
+		return fmt.Errorf("%s is not a materialized view", viewName)
+	}
+
+	dropped := append(catalog.DependentViews(viewName), viewName)
+	if err := catalog.DropView(viewName, stmt.Cascade); err != nil {
+		return err
+	}
+	e.forgetViews(planner, dropped)
+	return nil
+}
+
+// DropTable removes a table with its rows and indexes. With CASCADE the
+// views that depend on the table are dropped too; otherwise the table
+// cannot be dropped while views depend on it.
+func (e *Executor) DropTable(catalog *SystemCatalog, planner *QueryPlanner, stmt *parser.DropTableStatement) error {
+	tableName := stmt.TableName.Value
+	if _, err := catalog.schemaManager.GetSchema(tableName); err != nil {
+		if stmt.IfExists {
+			return nil
+		}
+		return err
+	}
+
+	dropped := catalog.DependentViews(tableName)
+	partitions := catalog.catalogManager.ListPartitions(tableName)
+	var err error
+	if stmt.Cascade {
+		err = catalog.DropTableCascade(tableName)
+	} else {
+		err = catalog.DropTable(tableName)
+	}
+	if err != nil {
+		return err
+	}
+
+	e.clusteredMutex.Lock()
+	delete(e.clusteredIndexes, tableName)
+	for _, partition := range partitions {
+		delete(e.clusteredIndexes, partition.TableName)
+	}
+	for name, index := range e.secondaryIndexes {
+		if index.TableName() == tableName {
+			delete(e.secondaryIndexes, name)
+		}
+	}
+	e.clusteredMutex.Unlock()
+
+	e.forgetSequence(tableName)
+	e.forgetViews(planner, dropped)
+	planner.Cache().Invalidate(tableName)
+	return nil
+}
+
+// forgetViews drops the rows kept for dropped views and the cached plans
+// that read them
+func (e *Executor) forgetViews(planner *QueryPlanner, views []string) {
+	e.clusteredMutex.Lock()
+	for _, name := range views {
+		delete(e.materializedViews, name)
+	}
+	e.clusteredMutex.Unlock()
+
+	for _, name := range views {
+		planner.Cache().Invalidate(name)
+	}
+}
+
+// RefreshMaterializedView recomputes the rows of a materialized view by
+// running its query. A concurrent refresh requires the view to have been
+// populated and lets scans read the old rows until the new ones are ready.
+func (e *Executor) RefreshMaterializedView(ctx context.Context, catalog *SystemCatalog, planner *QueryPlanner, stmt *parser.RefreshMaterializedViewStatement) error {
+	viewName := stmt.ViewName.Value
+	entry, err := catalog.catalogManager.GetView(viewName)
+	if err != nil {
+		return err
+	}
+	if !entry.Materialized {
+		return fmt.Errorf("%s is not a materialized view", viewName)
+	}
+
+	e.clusteredMutex.Lock()
+	view, exists := e.materializedViews[viewName]
+	if !exists {
+		view = &materializedView{}
+		e.materializedViews[viewName] = view
+	}
+	e.clusteredMutex.Unlock()
+
+	if stmt.Concurrently {
+		if _, populated := view.snapshot(); !populated {
+			return fmt.Errorf("cannot refresh materialized view %s concurrently: %w", viewName, ErrViewNotPopulated)
+		}
+		rows, err := e.computeView(ctx, catalog, planner, entry)
+		if err != nil {
+			return err
+		}
+		view.mutex.Lock()
+		view.rows = rows
+		view.mutex.Unlock()
+		return nil
+	}
+
+	view.mutex.Lock()
+	defer view.mutex.Unlock()
+
+	rows, err := e.computeView(ctx, catalog, planner, entry)
+	if err != nil {
+		return err
+	}
+	view.rows = rows
+	return nil
+}
+
+// computeView runs the query of a materialized view and returns its rows
+// under the schema of the view's table
+func (e *Executor) computeView(ctx context.Context, catalog *SystemCatalog, planner *QueryPlanner, entry *ViewCatalogEntry) (*ResultSet, error) {
+	schema, err := catalog.schemaManager.GetSchema(entry.ViewName)
+	if err != nil {
+		return nil, err
+	}
+
+	prepared, err := planner.Prepare(entry.Definition)
+	if err != nil {
+		return nil, fmt.Errorf("materialized view %s: %w", entry.ViewName, err)
+	}
+	if prepared.Plan == nil {
+		return nil, fmt.Errorf("materialized view %s is not defined by a query", entry.ViewName)
+	}
+
+	result, err := e.Execute(ctx, prepared.Plan)
+	if err != nil {
+		return nil, fmt.Errorf("materialized view %s: %w", entry.ViewName, err)
+	}
+
+	rows := NewResultSetWithSchema(NewTupleSchema(schema.Columns))
+	for _, tuple := range result.Tuples {
+		if len(tuple.Values) != len(schema.Columns) {
+			return nil, fmt.Errorf("materialized view %s has %d columns but its query returned %d",
+				entry.ViewName, len(schema.Columns), len(tuple.Values))
+		}
+		rows.Tuples = append(rows.Tuples, NewTuple(rows.Schema, tuple.Values))
+	}
+	return rows, nil
+}
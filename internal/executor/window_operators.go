@@ -0,0 +1,586 @@
+// Package executor - Window operator
+// Computes window functions over partitions of sorted rows
+package executor
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"relational-db/internal/optimizer"
+	"relational-db/internal/parser"
+)
+
+// WindowOperator computes window functions. It reads all of its input,
+// sorts it once per distinct window and appends one column per function,
+// named after the call, which is where the evaluator reads a window
+// function's value from. Rows are returned in the order they arrived.
+type WindowOperator struct {
+	child     PhysicalOperator
+	functions []*parser.FunctionCall
+	evaluator *ExpressionEvaluator
+
+	rows   []*Tuple
+	pos    int
+	closed bool
+}
+
+// windowRow is an input row with its partition and ORDER BY keys for one
+// window
+type windowRow struct {
+	index     int
+	tuple     *Tuple
+	partition []interface{}
+	order     []interface{}
+}
+
+// NewWindowOperator creates a window operator computing functions, which
+// must all have an OVER clause. Repeated calls are computed once.
+func NewWindowOperator(child PhysicalOperator, functions []*parser.FunctionCall) *WindowOperator {
+	var distinct []*parser.FunctionCall
+	seen := make(map[string]bool)
+	for _, fn := range functions {
+		if name := fn.String(); !seen[name] {
+			seen[name] = true
+			distinct = append(distinct, fn)
+		}
+	}
+
+	return &WindowOperator{
+		child:     child,
+		functions: distinct,
+		evaluator: NewExpressionEvaluator(),
+		closed:    true,
+	}
+}
+
+// Open reads the input and computes every window function for every row
+func (op *WindowOperator) Open(ctx *ExecutionContext) error {
+	if !op.closed {
+		return nil
+	}
+
+	op.evaluator.Bind(ctx)
+
+	input, err := op.readInput(ctx)
+	if err != nil {
+		return err
+	}
+
+	results := make([][]interface{}, len(input))
+	for i := range results {
+		results[i] = make([]interface{}, len(op.functions))
+	}
+
+	for _, spec := range optimizer.WindowSpecs(op.functions) {
+		partitions, err := op.partition(spec, input)
+		if err != nil {
+			return err
+		}
+		for f, fn := range op.functions {
+			if fn.Over.String() != spec.String() {
+				continue
+			}
+			for _, partition := range partitions {
+				if err := op.compute(fn, partition, f, results); err != nil {
+					return err
+				}
+			}
+		}
+	}
+
+	op.rows = make([]*Tuple, len(input))
+	schemas := make(map[*TupleSchema]*TupleSchema)
+	for i, tuple := range input {
+		schema, ok := schemas[tuple.Schema]
+		if !ok {
+			schema = op.outputSchema(tuple.Schema)
+			schemas[tuple.Schema] = schema
+		}
+		values := make([]interface{}, 0, len(tuple.Values)+len(op.functions))
+		values = append(values, tuple.Values...)
+		values = append(values, results[i]...)
+		op.rows[i] = NewTuple(schema, values)
+	}
+
+	op.pos = 0
+	op.closed = false
+	return nil
+}
+
+// readInput reads all rows of the child
+func (op *WindowOperator) readInput(ctx *ExecutionContext) ([]*Tuple, error) {
+	if err := op.child.Open(ctx); err != nil {
+		return nil, err
+	}
+	defer op.child.Close()
+
+	var rows []*Tuple
+	for {
+		if ctx.IsTimedOut() {
+			return nil, ErrExecutionTimeout
+		}
+		tuple, err := op.child.Next()
+		if err != nil {
+			return nil, err
+		}
+		if tuple == nil {
+			return rows, nil
+		}
+		rows = append(rows, tuple)
+	}
+}
+
+// partition sorts the input on a window's PARTITION BY and ORDER BY keys
+// and splits it into partitions
+func (op *WindowOperator) partition(spec *parser.WindowSpec, input []*Tuple) ([][]*windowRow, error) {
+	rows := make([]*windowRow, len(input))
+	for i, tuple := range input {
+		row := &windowRow{index: i, tuple: tuple}
+		for _, expr := range spec.PartitionBy {
+			value, err := op.evaluator.Evaluate(expr, tuple)
+			if err != nil {
+				return nil, err
+			}
+			row.partition = append(row.partition, value)
+		}
+		if spec.OrderBy != nil {
+			for _, order := range spec.OrderBy.Orders {
+				value, err := op.evaluator.Evaluate(order.Expression, tuple)
+				if err != nil {
+					return nil, err
+				}
+				row.order = append(row.order, value)
+			}
+		}
+		rows[i] = row
+	}
+
+	sort.SliceStable(rows, func(a, b int) bool {
+		if cmp := compareKeys(rows[a].partition, rows[b].partition); cmp != 0 {
+			return cmp < 0
+		}
+		return compareOrder(spec, rows[a].order, rows[b].order) < 0
+	})
+
+	var partitions [][]*windowRow
+	start := 0
+	for i := 1; i <= len(rows); i++ {
+		if i == len(rows) || compareKeys(rows[i].partition, rows[start].partition) != 0 {
+			partitions = append(partitions, rows[start:i])
+			start = i
+		}
+	}
+	return partitions, nil
+}
+
+// compute computes function f for the rows of one partition
+func (op *WindowOperator) compute(fn *parser.FunctionCall, rows []*windowRow, f int, results [][]interface{}) error {
+	name := strings.ToUpper(fn.Name.Value)
+	switch name {
+	case "ROW_NUMBER":
+		for i, row := range rows {
+			results[row.index][f] = int64(i + 1)
+		}
+
+	case "RANK", "DENSE_RANK":
+		rank, dense := int64(0), int64(0)
+		for i, row := range rows {
+			if i == 0 || compareKeys(row.order, rows[i-1].order) != 0 {
+				rank = int64(i + 1)
+				dense++
+			}
+			if name == "RANK" {
+				results[row.index][f] = rank
+			} else {
+				results[row.index][f] = dense
+			}
+		}
+
+	case "LAG", "LEAD":
+		for i, row := range rows {
+			value, err := op.offsetValue(fn, rows, i, name == "LAG")
+			if err != nil {
+				return err
+			}
+			results[row.index][f] = value
+		}
+
+	case "FIRST_VALUE", "LAST_VALUE", "COUNT", "SUM", "AVG", "MIN", "MAX":
+		return op.computeFramed(fn, name, rows, f, results)
+
+	default:
+		return fmt.Errorf("%w: window function %s", ErrNotImplemented, name)
+	}
+	return nil
+}
+
+// offsetValue returns LAG or LEAD of row i: its first argument evaluated
+// offset rows before or after it, or the default when there is no such row
+func (op *WindowOperator) offsetValue(fn *parser.FunctionCall, rows []*windowRow, i int, lag bool) (interface{}, error) {
+	if len(fn.Arguments) == 0 {
+		return nil, fmt.Errorf("%s requires an argument", fn.Name.Value)
+	}
+
+	offset := int64(1)
+	if len(fn.Arguments) > 1 {
+		var err error
+		if offset, err = op.offset(fn.Arguments[1], rows[i].tuple); err != nil {
+			return nil, err
+		}
+	}
+	if lag {
+		offset = -offset
+	}
+
+	target := int64(i) + offset
+	if target < 0 || target >= int64(len(rows)) {
+		if len(fn.Arguments) > 2 {
+			return op.evaluator.Evaluate(fn.Arguments[2], rows[i].tuple)
+		}
+		return nil, nil
+	}
+	return op.evaluator.Evaluate(fn.Arguments[0], rows[target].tuple)
+}
+
+// computeFramed computes FIRST_VALUE, LAST_VALUE or an aggregate over each
+// row's window frame
+func (op *WindowOperator) computeFramed(fn *parser.FunctionCall, name string, rows []*windowRow, f int, results [][]interface{}) error {
+	if len(fn.Arguments) != 1 {
+		return fmt.Errorf("%s requires 1 argument, got %d", name, len(fn.Arguments))
+	}
+
+	values := make([]interface{}, len(rows))
+	for i, row := range rows {
+		if _, ok := fn.Arguments[0].(*parser.Wildcard); ok {
+			values[i] = int64(1) // COUNT(*) counts rows, never NULL
+			continue
+		}
+		value, err := op.evaluator.Evaluate(fn.Arguments[0], row.tuple)
+		if err != nil {
+			return err
+		}
+		values[i] = value
+	}
+
+	starts := make([]int, len(rows))
+	ends := make([]int, len(rows))
+	incremental := true
+	for i := range rows {
+		start, end, err := op.frame(fn.Over, rows, i)
+		if err != nil {
+			return err
+		}
+		starts[i], ends[i] = start, end
+		incremental = incremental && start == 0
+	}
+
+	// Frames that all start at the first row grow with the row, so running
+	// aggregates only add the rows each frame gains
+	var running *windowAggregate
+	covered := -1
+	for i, row := range rows {
+		start, end := starts[i], ends[i]
+
+		switch name {
+		case "FIRST_VALUE":
+			if start <= end {
+				results[row.index][f] = values[start]
+			}
+			continue
+		case "LAST_VALUE":
+			if start <= end {
+				results[row.index][f] = values[end]
+			}
+			continue
+		}
+
+		if !incremental || running == nil {
+			running = &windowAggregate{name: name}
+			covered = start - 1
+		}
+		for j := covered + 1; j <= end; j++ {
+			if err := running.add(values[j]); err != nil {
+				return err
+			}
+		}
+		if end > covered {
+			covered = end
+		}
+		results[row.index][f] = running.result()
+	}
+	return nil
+}
+
+// frame returns the first and last position of row i's window frame in its
+// partition. The frame is empty when start > end.
+func (op *WindowOperator) frame(spec *parser.WindowSpec, rows []*windowRow, i int) (int, int, error) {
+	frame := spec.Frame
+	if frame == nil {
+		// Without ORDER BY the frame is the whole partition, with it the
+		// rows up to the current row's last peer
+		if spec.OrderBy == nil {
+			return 0, len(rows) - 1, nil
+		}
+		frame = &parser.WindowFrame{
+			Unit:  parser.FrameRange,
+			Start: &parser.FrameBound{Type: parser.UnboundedPreceding},
+			End:   &parser.FrameBound{Type: parser.CurrentRow},
+		}
+	}
+
+	start, err := op.bound(spec, frame.Unit, frame.Start, rows, i, true)
+	if err != nil {
+		return 0, 0, err
+	}
+	end, err := op.bound(spec, frame.Unit, frame.End, rows, i, false)
+	if err != nil {
+		return 0, 0, err
+	}
+
+	if start < 0 {
+		start = 0
+	}
+	if end > len(rows)-1 {
+		end = len(rows) - 1
+	}
+	return start, end, nil
+}
+
+// bound returns the position a frame bound puts the start or end of row i's
+// frame at. Positions may fall outside the partition.
+func (op *WindowOperator) bound(spec *parser.WindowSpec, unit parser.FrameUnit, bound *parser.FrameBound, rows []*windowRow, i int, isStart bool) (int, error) {
+	switch bound.Type {
+	case parser.UnboundedPreceding:
+		return 0, nil
+	case parser.UnboundedFollowing:
+		return len(rows) - 1, nil
+	case parser.CurrentRow:
+		if unit == parser.FrameRows {
+			return i, nil
+		}
+		// A RANGE frame includes the current row's peers
+		j := i
+		if isStart {
+			for j > 0 && compareKeys(rows[j-1].order, rows[i].order) == 0 {
+				j--
+			}
+		} else {
+			for j < len(rows)-1 && compareKeys(rows[j+1].order, rows[i].order) == 0 {
+				j++
+			}
+		}
+		return j, nil
+	}
+
+	if unit == parser.FrameRows {
+		offset, err := op.offset(bound.Offset, rows[i].tuple)
+		if err != nil {
+			return 0, err
+		}
+		if bound.Type == parser.OffsetPreceding {
+			return i - int(offset), nil
+		}
+		return i + int(offset), nil
+	}
+	return op.rangeBound(spec, bound, rows, i, isStart)
+}
+
+// rangeBound returns the position of a RANGE offset bound: the frame holds
+// the rows whose ORDER BY value is within offset of the current row's. Rows
+// with a NULL value have their peers as their frame.
+func (op *WindowOperator) rangeBound(spec *parser.WindowSpec, bound *parser.FrameBound, rows []*windowRow, i int, isStart bool) (int, error) {
+	if len(rows[i].order) != 1 {
+		return 0, fmt.Errorf("RANGE with an offset requires exactly one ORDER BY column")
+	}
+	current := rows[i].order[0]
+	if current == nil {
+		return op.bound(spec, parser.FrameRange, &parser.FrameBound{Type: parser.CurrentRow}, rows, i, isStart)
+	}
+
+	offset, err := op.evaluator.Evaluate(bound.Offset, rows[i].tuple)
+	if err != nil {
+		return 0, err
+	}
+
+	// Preceding rows come before the current row in ORDER BY direction, so
+	// their values are smaller when ascending and larger when descending
+	descending := spec.OrderBy.Orders[0].Direction == parser.Descending
+	operator := parser.Plus
+	if (bound.Type == parser.OffsetPreceding) != descending {
+		operator = parser.Minus
+	}
+	limit, err := arithmetic(operator, current, offset)
+	if err != nil {
+		return 0, err
+	}
+
+	// The first row at or past the limit in ORDER BY direction starts the
+	// frame; the last row not past it ends it
+	beyond := func(j int) bool {
+		cmp := compareValues(rows[j].order[0], limit)
+		if descending {
+			cmp = -cmp
+		}
+		if isStart {
+			return cmp >= 0 && rows[j].order[0] != nil
+		}
+		return cmp > 0 || rows[j].order[0] == nil && descending
+	}
+	pos := sort.Search(len(rows), beyond)
+	if isStart {
+		return pos, nil
+	}
+	return pos - 1, nil
+}
+
+// offset evaluates a ROWS frame or LAG/LEAD offset, which must be a
+// non-negative integer
+func (op *WindowOperator) offset(expr parser.Expression, tuple *Tuple) (int64, error) {
+	value, err := op.evaluator.Evaluate(expr, tuple)
+	if err != nil {
+		return 0, err
+	}
+	n, ok := toInt64(value)
+	if !ok || n < 0 {
+		return 0, fmt.Errorf("%w: %v", ErrInvalidWindowOffset, value)
+	}
+	return n, nil
+}
+
+// outputSchema returns the input schema with a column per window function
+func (op *WindowOperator) outputSchema(input *TupleSchema) *TupleSchema {
+	var columns []ColumnInfo
+	if input != nil {
+		columns = append(columns, input.Columns...)
+	}
+	for _, fn := range op.functions {
+		columns = append(columns, ColumnInfo{
+			Name:     fn.String(),
+			Type:     windowColumnType(fn, input),
+			Nullable: true,
+		})
+	}
+	return NewTupleSchema(columns)
+}
+
+// windowColumnType returns the type of a window function's values
+func windowColumnType(fn *parser.FunctionCall, input *TupleSchema) ColumnType {
+	switch strings.ToUpper(fn.Name.Value) {
+	case "ROW_NUMBER", "RANK", "DENSE_RANK", "COUNT":
+		return TypeBigInt
+	case "AVG":
+		return TypeDouble
+	}
+
+	// Other functions return values of their argument
+	if len(fn.Arguments) > 0 && input != nil {
+		if ident, ok := fn.Arguments[0].(*parser.Identifier); ok {
+			if col, ok := input.GetColumn(ident.Value); ok {
+				return col.Type
+			}
+		}
+	}
+	return TypeNull
+}
+
+// Next returns the next row with its window function values
+func (op *WindowOperator) Next() (*Tuple, error) {
+	if op.closed {
+		return nil, ErrOperatorClosed
+	}
+	if op.pos >= len(op.rows) {
+		return nil, nil
+	}
+	op.pos++
+	return op.rows[op.pos-1], nil
+}
+
+// Close releases resources
+func (op *WindowOperator) Close() error {
+	op.rows = nil
+	op.closed = true
+	return nil
+}
+
+// OperatorType returns the operator type
+func (op *WindowOperator) OperatorType() string {
+	return "Window"
+}
+
+// EstimatedCost returns estimated cost
+func (op *WindowOperator) EstimatedCost() float64 {
+	// One sort per window
+	return op.child.EstimatedCost() * 1.5
+}
+
+// compareOrder compares two rows' ORDER BY keys in the window's directions
+func compareOrder(spec *parser.WindowSpec, a, b []interface{}) int {
+	for i := range a {
+		cmp := compareValues(a[i], b[i])
+		if spec.OrderBy.Orders[i].Direction == parser.Descending {
+			cmp = -cmp
+		}
+		if cmp != 0 {
+			return cmp
+		}
+	}
+	return 0
+}
+
+// windowAggregate accumulates an aggregate over a window frame. NULL values
+// are skipped, and aggregates of no values are NULL, except COUNT.
+type windowAggregate struct {
+	name  string
+	count int64
+	sum   interface{}
+	best  interface{} // for MIN and MAX
+}
+
+// add adds a value to the aggregate
+func (a *windowAggregate) add(value interface{}) error {
+	if value == nil {
+		return nil
+	}
+	a.count++
+
+	switch a.name {
+	case "SUM", "AVG":
+		if a.sum == nil {
+			a.sum = value
+			return nil
+		}
+		sum, err := arithmetic(parser.Plus, a.sum, value)
+		if err != nil {
+			return err
+		}
+		a.sum = sum
+	case "MIN":
+		if a.best == nil || compareValues(value, a.best) < 0 {
+			a.best = value
+		}
+	case "MAX":
+		if a.best == nil || compareValues(value, a.best) > 0 {
+			a.best = value
+		}
+	}
+	return nil
+}
+
+// result returns the aggregate of the values added so far
+func (a *windowAggregate) result() interface{} {
+	switch a.name {
+	case "COUNT":
+		return a.count
+	case "SUM":
+		return a.sum
+	case "AVG":
+		sum, ok := toFloat64(a.sum)
+		if !ok || a.count == 0 {
+			return nil
+		}
+		return sum / float64(a.count)
+	default:
+		return a.best
+	}
+}
@@ -0,0 +1,285 @@
+package formatter
+
+import (
+	"fmt"
+	"strings"
+
+	"relational-db/internal/lexer"
+	"relational-db/internal/parser"
+)
+
+// Precedence of expressions, from the loosest binding to the tightest, as
+// the parser reads them
+const (
+	precOr = iota + 1
+	precAnd
+	precNot
+	precComparison // comparisons, IS NULL, LIKE, IN and BETWEEN
+	precAdditive
+	precMultiplicative
+	precUnary
+	precPrimary
+)
+
+// precedence returns how tightly an expression binds its operands
+func precedence(expr parser.Expression) int {
+	switch e := expr.(type) {
+	case *parser.BinaryExpression:
+		switch e.Operator {
+		case parser.Or:
+			return precOr
+		case parser.And:
+			return precAnd
+		case parser.Plus, parser.Minus:
+			return precAdditive
+		case parser.Multiply, parser.Divide, parser.Modulo:
+			return precMultiplicative
+		default:
+			return precComparison
+		}
+	case *parser.UnaryExpression:
+		if e.Operator != parser.Not {
+			return precUnary
+		}
+		if isInSubquery(e.Operand) {
+			return precComparison
+		}
+		return precNot
+	case *parser.IsNullExpression, *parser.BetweenExpression, *parser.InListExpression, *parser.LikeExpression:
+		return precComparison
+	default:
+		return precPrimary
+	}
+}
+
+// isInSubquery reports whether expr is expr IN (SELECT ...), which negated
+// is written expr NOT IN (SELECT ...)
+func isInSubquery(expr parser.Expression) bool {
+	b, ok := expr.(*parser.BinaryExpression)
+	return ok && b.Operator == parser.In
+}
+
+// expression renders an expression, in parentheses when it binds looser
+// than min
+func (f *formatter) expression(expr parser.Expression, min int, depth int) string {
+	text := f.operand(expr, depth)
+	if precedence(expr) < min {
+		return "(" + text + ")"
+	}
+	return text
+}
+
+// operand renders an expression without parentheses of its own
+func (f *formatter) operand(expr parser.Expression, depth int) string {
+	switch e := expr.(type) {
+	case *parser.Identifier:
+		return f.identifier(e, depth)
+	case *parser.Literal:
+		return f.commentsBefore(e.Pos, depth) + f.literal(e)
+	case *parser.Parameter:
+		return e.String()
+	case *parser.ColumnReference:
+		if e.Table == nil {
+			return f.identifier(e.Column, depth)
+		}
+		return f.identifier(e.Table, depth) + "." + f.identifier(e.Column, depth)
+	case *parser.Wildcard:
+		if e.Table == nil {
+			return "*"
+		}
+		return f.identifier(e.Table, depth) + ".*"
+	case *parser.BinaryExpression:
+		return f.binary(e, depth)
+	case *parser.UnaryExpression:
+		return f.unary(e, depth)
+	case *parser.FunctionCall:
+		return f.functionCall(e, depth)
+	case *parser.IsNullExpression:
+		operator := f.keyword("IS NULL")
+		if e.Not {
+			operator = f.keyword("IS NOT NULL")
+		}
+		return f.expression(e.Expr, precComparison, depth) + " " + operator
+	case *parser.BetweenExpression:
+		return f.expression(e.Expr, precComparison, depth) + " " + f.negated("BETWEEN", e.Not) + " " +
+			f.expression(e.Lower, precAdditive, depth) + " " + f.keyword("AND") + " " +
+			f.expression(e.Upper, precAdditive, depth)
+	case *parser.InListExpression:
+		return f.expression(e.Expr, precComparison, depth) + " " + f.negated("IN", e.Not) +
+			" (" + f.join(e.List, depth) + ")"
+	case *parser.LikeExpression:
+		result := f.expression(e.Expr, precComparison, depth) + " " + f.negated("LIKE", e.Not) + " " +
+			f.expression(e.Pattern, precAdditive, depth)
+		if e.Escape != nil {
+			result += " " + f.keyword("ESCAPE") + " " + f.expression(e.Escape, precAdditive, depth)
+		}
+		return result
+	case *parser.CaseExpression:
+		return f.caseExpression(e, depth)
+	case *parser.CastExpression:
+		return f.keyword("CAST") + "(" + f.expression(e.Expr, 0, depth) + " " + f.keyword("AS") + " " +
+			dataType(e.Type) + ")"
+	case *parser.SubqueryExpression:
+		result := f.nested(e.Query, depth)
+		if e.Alias != nil {
+			result += " " + f.keyword("AS") + " " + f.identifier(e.Alias, depth)
+		}
+		if len(e.Columns) > 0 {
+			result += " (" + f.names(e.Columns, depth) + ")"
+		}
+		return result
+	case *parser.ExistsExpression:
+		return f.keyword("EXISTS") + " " + f.nested(e.Query, depth)
+	default:
+		return expr.String()
+	}
+}
+
+// negated renders a predicate keyword, preceded by NOT when negated
+func (f *formatter) negated(keyword string, not bool) string {
+	if not {
+		return f.keyword("NOT " + keyword)
+	}
+	return f.keyword(keyword)
+}
+
+// literal renders a literal value
+func (f *formatter) literal(l *parser.Literal) string {
+	switch l.Type {
+	case lexer.STRING:
+		return quoteString(fmt.Sprintf("%v", l.Value))
+	case lexer.HEX_STRING:
+		return l.String()
+	case lexer.NULL:
+		return f.keyword("NULL")
+	case lexer.TRUE:
+		return f.keyword("TRUE")
+	case lexer.FALSE:
+		return f.keyword("FALSE")
+	default:
+		return fmt.Sprintf("%v", l.Value)
+	}
+}
+
+// binary renders a binary operation. Operators associate left, so a right
+// operand of the same precedence is parenthesized; comparisons take
+// arithmetic operands on their right.
+func (f *formatter) binary(b *parser.BinaryExpression, depth int) string {
+	prec := precedence(b)
+	leftMin, rightMin := prec, prec+1
+	if prec == precComparison {
+		rightMin = precAdditive
+	}
+
+	operator := b.Operator.String()
+	switch b.Operator {
+	case parser.And, parser.Or, parser.In, parser.Like, parser.Between:
+		operator = f.keyword(operator)
+	}
+
+	right := f.expression(b.Right, rightMin, depth)
+	if b.Operator == parser.Minus && strings.HasPrefix(right, "-") {
+		// Two minus signs in a row would start a comment
+		right = "(" + right + ")"
+	}
+	return f.expression(b.Left, leftMin, depth) + " " + operator + " " + right
+}
+
+// unary renders a unary operation
+func (f *formatter) unary(u *parser.UnaryExpression, depth int) string {
+	switch u.Operator {
+	case parser.Not:
+		if in, ok := u.Operand.(*parser.BinaryExpression); ok && isInSubquery(in) {
+			return f.expression(in.Left, precComparison, depth) + " " + f.keyword("NOT IN") + " " +
+				f.expression(in.Right, precAdditive, depth)
+		}
+		return f.keyword("NOT") + " " + f.expression(u.Operand, precNot, depth)
+	default:
+		operand := f.expression(u.Operand, precUnary, depth)
+		if strings.HasPrefix(operand, "-") {
+			operand = "(" + operand + ")"
+		}
+		return u.Operator.String() + operand
+	}
+}
+
+// functionCall renders a function call and its window
+func (f *formatter) functionCall(call *parser.FunctionCall, depth int) string {
+	var result strings.Builder
+	result.WriteString(f.commentsBefore(call.Name.Pos, depth) + call.Name.Value + "(")
+	if call.Distinct {
+		result.WriteString(f.keyword("DISTINCT") + " ")
+	}
+	result.WriteString(f.join(call.Arguments, depth) + ")")
+
+	if call.Over != nil {
+		result.WriteString(" " + f.keyword("OVER") + " " + f.window(call.Over, depth))
+	}
+	return result.String()
+}
+
+// window renders the window of an OVER or WINDOW clause, or its name
+func (f *formatter) window(w *parser.WindowSpec, depth int) string {
+	if w.Name != nil {
+		return f.identifier(w.Name, depth)
+	}
+
+	var parts []string
+	if len(w.PartitionBy) > 0 {
+		parts = append(parts, f.keyword("PARTITION BY")+" "+f.join(w.PartitionBy, depth))
+	}
+	if w.OrderBy != nil {
+		orders := make([]string, len(w.OrderBy.Orders))
+		for i, order := range w.OrderBy.Orders {
+			orders[i] = f.orderExpression(order, depth)
+		}
+		parts = append(parts, f.keyword("ORDER BY")+" "+strings.Join(orders, ", "))
+	}
+	if w.Frame != nil {
+		parts = append(parts, f.keyword(w.Frame.Unit.String()+" BETWEEN")+" "+f.frameBound(w.Frame.Start, depth)+
+			" "+f.keyword("AND")+" "+f.frameBound(w.Frame.End, depth))
+	}
+	return "(" + strings.Join(parts, " ") + ")"
+}
+
+// frameBound renders one end of a window frame
+func (f *formatter) frameBound(b *parser.FrameBound, depth int) string {
+	switch b.Type {
+	case parser.OffsetPreceding:
+		return f.expression(b.Offset, precAdditive, depth) + " " + f.keyword("PRECEDING")
+	case parser.OffsetFollowing:
+		return f.expression(b.Offset, precAdditive, depth) + " " + f.keyword("FOLLOWING")
+	default:
+		return f.keyword(b.String())
+	}
+}
+
+// caseExpression renders a CASE expression on one line when it fits,
+// otherwise with each branch on a line of its own
+func (f *formatter) caseExpression(c *parser.CaseExpression, depth int) string {
+	mark := f.next
+	if text := f.caseBranches(c, " ", " ", depth); f.fits(text, depth) {
+		return text
+	}
+	f.next = mark
+	return f.caseBranches(c, "\n"+f.indent(depth+1), "\n"+f.indent(depth), depth+1)
+}
+
+// caseBranches renders a CASE expression, starting each branch with
+// separator and its END with last
+func (f *formatter) caseBranches(c *parser.CaseExpression, separator, last string, depth int) string {
+	var result strings.Builder
+	result.WriteString(f.keyword("CASE"))
+	if c.Operand != nil {
+		result.WriteString(" " + f.expression(c.Operand, 0, depth))
+	}
+	for _, when := range c.Whens {
+		result.WriteString(separator + f.keyword("WHEN") + " " + f.expression(when.Condition, 0, depth) + " " +
+			f.keyword("THEN") + " " + f.expression(when.Result, 0, depth))
+	}
+	if c.Else != nil {
+		result.WriteString(separator + f.keyword("ELSE") + " " + f.expression(c.Else, 0, depth))
+	}
+	result.WriteString(last + f.keyword("END"))
+	return result.String()
+}
@@ -0,0 +1,388 @@
+// Package formatter renders parsed SQL statements as canonical, indented SQL
+// for NamyohDB.
+package formatter
+
+import (
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"relational-db/internal/lexer"
+	"relational-db/internal/parser"
+)
+
+// KeywordCase selects how the formatter writes keywords. Names, literals
+// and data types are always written as they were parsed.
+type KeywordCase int
+
+const (
+	UpperCase KeywordCase = iota
+	LowerCase
+)
+
+// Options control the layout of formatted SQL
+type Options struct {
+	KeywordCase KeywordCase
+	Indent      string // one level of indentation
+	LineWidth   int    // lists and conditions wider than this are broken over lines, 0 for no limit
+}
+
+// DefaultOptions returns upper case keywords, four space indentation and
+// 80 column lines
+func DefaultOptions() Options {
+	return Options{
+		KeywordCase: UpperCase,
+		Indent:      "    ",
+		LineWidth:   80,
+	}
+}
+
+// Format renders a statement as SQL, without a terminating semicolon
+func Format(stmt parser.Statement, opts Options) string {
+	f := &formatter{opts: opts}
+	return f.statement(stmt, 0)
+}
+
+// FormatScript formats every statement of a script, each terminated by a
+// semicolon. Comments are kept: those between statements stay on lines of
+// their own or after the statement they follow on a line, and those within
+// a statement are written before the name or literal that follows them. A
+// script with a syntax error is not formatted, and the error is returned as
+// parser.ParseErrors.
+func FormatScript(sql string, opts Options) (string, error) {
+	statements, err := parser.ParseScript(sql)
+	if err != nil {
+		return "", err
+	}
+
+	w := &scriptWriter{source: sql}
+	comments := scanComments(sql)
+	next := 0
+
+	for _, stmt := range statements {
+		for next < len(comments) && comments[next].start < stmt.Start {
+			w.comment(comments[next])
+			next++
+		}
+
+		inside := next
+		for inside < len(comments) && comments[inside].start < stmt.End {
+			inside++
+		}
+
+		f := &formatter{opts: opts, comments: comments[next:inside]}
+		w.separate(stmt.Start)
+		w.out.WriteString(f.statement(stmt.Statement, 0))
+		w.out.WriteString(";")
+		w.last = stmt.End
+		w.lastLine = stmt.Line + strings.Count(stmt.Text, "\n")
+		w.lineComment = false
+
+		// Comments after the last name or literal of the statement
+		for _, c := range f.comments[f.next:] {
+			w.trailing(c)
+		}
+		next = inside
+	}
+	for ; next < len(comments); next++ {
+		w.comment(comments[next])
+	}
+
+	if w.out.Len() == 0 {
+		return "", nil
+	}
+	return w.out.String() + "\n", nil
+}
+
+// scriptWriter assembles a formatted script
+type scriptWriter struct {
+	out         strings.Builder
+	source      string
+	last        int  // source offset just past the last statement or comment written
+	lastLine    int  // source line the last statement ended on, 0 before the first
+	lineComment bool // the output ends with a line comment
+}
+
+// separate starts the line of the next statement or comment, keeping one
+// blank line where the source had at least one
+func (w *scriptWriter) separate(start int) {
+	if w.out.Len() == 0 {
+		return
+	}
+	w.out.WriteString("\n")
+	if strings.Count(w.source[w.last:start], "\n") > 1 {
+		w.out.WriteString("\n")
+	}
+}
+
+// comment writes a comment found outside any statement. One starting on the
+// line a statement ends on stays after it.
+func (w *scriptWriter) comment(c comment) {
+	if w.lastLine > 0 && c.line == w.lastLine {
+		w.trailing(c)
+		return
+	}
+	w.separate(c.start)
+	w.out.WriteString(c.text)
+	w.last = c.end
+	w.lastLine = 0
+	w.lineComment = !c.block
+}
+
+// trailing writes a comment at the end of the current line
+func (w *scriptWriter) trailing(c comment) {
+	if w.lineComment {
+		w.out.WriteString("\n")
+	} else {
+		w.out.WriteString(" ")
+	}
+	w.out.WriteString(c.text)
+	w.last = c.end
+	w.lineComment = !c.block
+}
+
+// comment is a comment of the source, as written
+type comment struct {
+	text  string
+	start int // byte offsets of the comment
+	end   int
+	line  int
+	block bool // a /* */ comment, rather than one running to the end of the line
+}
+
+// scanComments returns the comments of a script in source order
+func scanComments(sql string) []comment {
+	var comments []comment
+	l := lexer.NewLexer(sql)
+	for {
+		token := l.NextToken()
+		if token.Type == lexer.EOF {
+			return comments
+		}
+		if token.Type != lexer.COMMENT {
+			continue
+		}
+
+		c := comment{start: token.Position, line: token.Line}
+		if strings.HasPrefix(sql[c.start:], "/*") {
+			c.block = true
+			c.end = len(sql)
+			if i := strings.Index(sql[c.start+2:], "*/"); i >= 0 {
+				c.end = c.start + 2 + i + 2
+			}
+		} else {
+			c.end = len(sql)
+			if i := strings.IndexByte(sql[c.start:], '\n'); i >= 0 {
+				c.end = c.start + i
+			}
+		}
+		c.text = strings.TrimRight(sql[c.start:c.end], " \t\r")
+		if c.block && !strings.HasSuffix(c.text, "*/") {
+			c.text += " */"
+		}
+		comments = append(comments, c)
+	}
+}
+
+// formatter renders one statement. Comments of the statement are written
+// before the first name or literal found after them.
+type formatter struct {
+	opts     Options
+	comments []comment
+	next     int // index of the first comment not yet written
+}
+
+// keyword returns one or more keywords in the configured case
+func (f *formatter) keyword(words string) string {
+	if f.opts.KeywordCase == LowerCase {
+		return strings.ToLower(words)
+	}
+	return words
+}
+
+// indent returns the indentation of the given depth
+func (f *formatter) indent(depth int) string {
+	return strings.Repeat(f.opts.Indent, depth)
+}
+
+// fits reports whether text fits on a single line at the given depth
+func (f *formatter) fits(text string, depth int) bool {
+	if strings.Contains(text, "\n") {
+		return false
+	}
+	if f.opts.LineWidth <= 0 {
+		return true
+	}
+	width := utf8.RuneCountInString(f.indent(depth)) + utf8.RuneCountInString(text)
+	return width <= f.opts.LineWidth
+}
+
+// commentsBefore returns the comments not yet written that come before pos
+// in the source, each followed by what separates it from the token at pos
+func (f *formatter) commentsBefore(pos parser.Pos, depth int) string {
+	if !pos.IsValid() {
+		return ""
+	}
+
+	var result strings.Builder
+	for f.next < len(f.comments) && f.comments[f.next].start < pos.Offset {
+		c := f.comments[f.next]
+		result.WriteString(c.text)
+		if c.block {
+			result.WriteString(" ")
+		} else {
+			result.WriteString("\n" + f.indent(depth))
+		}
+		f.next++
+	}
+	return result.String()
+}
+
+// leading returns the comments before a clause that starts with keywords,
+// so that comments written between its keywords and its first name or
+// literal come before the clause
+func (f *formatter) leading(pos parser.Pos, depth int) string {
+	return f.commentsBefore(pos, depth)
+}
+
+// firstPosition returns the position of the first expression that has one
+func firstPosition(exprs []parser.Expression) parser.Pos {
+	for _, expr := range exprs {
+		if pos := parser.PositionOf(expr); pos.IsValid() {
+			return pos
+		}
+	}
+	return parser.Pos{}
+}
+
+// list renders a clause that introduces a comma separated list: on one line
+// when it fits, otherwise with each item on a line of its own, indented
+// under the clause
+func (f *formatter) list(clause string, n int, depth int, item func(i, depth int) string) string {
+	mark := f.next
+	items := make([]string, n)
+	for i := range items {
+		items[i] = item(i, depth)
+	}
+	line := clause + " " + strings.Join(items, ", ")
+	if f.fits(line, depth) {
+		return line
+	}
+
+	f.next = mark
+	var result strings.Builder
+	result.WriteString(clause)
+	for i := 0; i < n; i++ {
+		if i > 0 {
+			result.WriteString(",")
+		}
+		result.WriteString("\n" + f.indent(depth+1) + item(i, depth+1))
+	}
+	return result.String()
+}
+
+// expressionList renders a clause that introduces a list of expressions
+func (f *formatter) expressionList(clause string, exprs []parser.Expression, depth int) string {
+	return f.list(clause, len(exprs), depth, func(i, depth int) string {
+		return f.expression(exprs[i], 0, depth)
+	})
+}
+
+// join renders expressions separated by commas on one line
+func (f *formatter) join(exprs []parser.Expression, depth int) string {
+	items := make([]string, len(exprs))
+	for i, expr := range exprs {
+		items[i] = f.expression(expr, 0, depth)
+	}
+	return strings.Join(items, ", ")
+}
+
+// names renders a comma separated list of names
+func (f *formatter) names(names []*parser.Identifier, depth int) string {
+	items := make([]string, len(names))
+	for i, name := range names {
+		items[i] = f.identifier(name, depth)
+	}
+	return strings.Join(items, ", ")
+}
+
+// condition renders a clause that introduces a condition, such as WHERE.
+// A condition too wide for one line that is a chain of ANDs or of ORs is
+// broken before each operator.
+func (f *formatter) condition(clause string, cond parser.Expression, depth int) string {
+	lead := f.leading(parser.PositionOf(cond), depth)
+	mark := f.next
+	line := clause + " " + f.expression(cond, 0, depth)
+	if f.fits(line, depth) {
+		return lead + line
+	}
+
+	chain, ok := cond.(*parser.BinaryExpression)
+	if !ok || (chain.Operator != parser.And && chain.Operator != parser.Or) {
+		return lead + line
+	}
+	f.next = mark
+
+	// Operators associate left, so the chain runs down the left operands
+	var operands []parser.Expression
+	expr := parser.Expression(chain)
+	for {
+		b, ok := expr.(*parser.BinaryExpression)
+		if !ok || b.Operator != chain.Operator {
+			break
+		}
+		operands = append(operands, b.Right)
+		expr = b.Left
+	}
+
+	prec := precedence(chain)
+	operator := f.keyword(chain.Operator.String())
+	var result strings.Builder
+	result.WriteString(lead + clause + " " + f.expression(expr, prec, depth))
+	for i := len(operands) - 1; i >= 0; i-- {
+		result.WriteString("\n" + f.indent(depth+1) + operator + " ")
+		result.WriteString(f.expression(operands[i], prec+1, depth+1))
+	}
+	return result.String()
+}
+
+// identifier renders a name, quoted when it was quoted in the source or
+// would otherwise be read as a keyword or is not a plain word, and its
+// alias
+func (f *formatter) identifier(id *parser.Identifier, depth int) string {
+	name := quoteIdentifier(id.Value)
+	if id.Quoted {
+		name = parser.QuoteIdentifier(id.Value)
+	}
+	result := f.commentsBefore(id.Pos, depth) + name
+	if id.Alias != nil {
+		result += " " + f.keyword("AS") + " " + f.identifier(id.Alias, depth)
+	}
+	return result
+}
+
+// quoteIdentifier quotes a name that is a keyword or not a plain word
+func quoteIdentifier(name string) string {
+	plain := name != ""
+	for i, c := range name {
+		if !(c == '_' || unicode.IsLetter(c) || (i > 0 && unicode.IsDigit(c))) {
+			plain = false
+			break
+		}
+	}
+	if _, keyword := lexer.Keywords[strings.ToUpper(name)]; plain && !keyword {
+		return name
+	}
+
+	return parser.QuoteIdentifier(name)
+}
+
+// quoteString renders text as a string literal, as an escape string when
+// it holds characters such as newlines that would break up the line
+func quoteString(text string) string {
+	if !strings.ContainsAny(text, "\n\r\t\b\f") {
+		return "'" + strings.ReplaceAll(text, "'", "''") + "'"
+	}
+	replacer := strings.NewReplacer(`\`, `\\`, `'`, `''`, "\n", `\n`, "\r", `\r`, "\t", `\t`, "\b", `\b`, "\f", `\f`)
+	return "E'" + replacer.Replace(text) + "'"
+}
@@ -0,0 +1,288 @@
+package formatter
+
+import (
+	"go/ast"
+	goparser "go/parser"
+	"go/token"
+	"reflect"
+	"strconv"
+	"strings"
+	"testing"
+
+	"relational-db/internal/parser"
+)
+
+// corpus returns every statement of the parser tests that parses, so the
+// round trip covers what the parser does as it grows
+func corpus(t *testing.T) []string {
+	file, err := goparser.ParseFile(token.NewFileSet(), "../../tests/unit/parser_test.go", nil, 0)
+	if err != nil {
+		t.Fatalf("failed to read the parser tests: %v", err)
+	}
+
+	var statements []string
+	seen := make(map[string]bool)
+	ast.Inspect(file, func(node ast.Node) bool {
+		lit, ok := node.(*ast.BasicLit)
+		if !ok || lit.Kind != token.STRING {
+			return true
+		}
+		sql, err := strconv.Unquote(lit.Value)
+		if err != nil || seen[sql] {
+			return true
+		}
+		seen[sql] = true
+		if _, err := parser.ParseSQL(sql); err == nil {
+			statements = append(statements, sql)
+		}
+		return true
+	})
+	return statements
+}
+
+// extraCorpus covers statements the parser tests do not
+var extraCorpus = []string{
+	"SELECT a FROM t WHERE NOT (a = 1 OR b = 2) AND c IS NOT NULL",
+	"SELECT a - -b, -(-a), a - (b - c), (a + b) * c, a * (b / c) % d FROM t",
+	"SELECT (a = b) = c, a = (b = c), a = (NOT b), (a < b) IS NULL FROM t",
+	"SELECT a FROM t WHERE (a IN (1, 2)) = TRUE AND b NOT IN (SELECT b FROM u)",
+	"SELECT a FROM t WHERE a NOT BETWEEN 1 + 1 AND 2 * 3 AND b NOT LIKE 'x''y%' ESCAPE '!'",
+	"SELECT CASE WHEN a > 1 THEN 'big' WHEN a > 0 THEN 'small' ELSE 'none' END, CASE a WHEN 1 THEN 2 END FROM t",
+	"SELECT CAST(a AS DECIMAL(10,2)), CAST((SELECT 1) AS INTEGER) FROM t",
+	"SELECT name, ROW_NUMBER() OVER (PARTITION BY dept ORDER BY salary DESC ROWS BETWEEN 2 PRECEDING AND CURRENT ROW) FROM emp",
+	"SELECT RANK() OVER w, SUM(b) OVER \"my window\" FROM t WINDOW w AS (PARTITION BY a ORDER BY b DESC), \"my window\" AS () ORDER BY RANK() OVER w",
+	"SELECT COUNT(DISTINCT a), SUM(b) OVER (ORDER BY c RANGE BETWEEN UNBOUNDED PRECEDING AND UNBOUNDED FOLLOWING) FROM t",
+	"SELECT u.name, o.total FROM users u INNER JOIN orders AS o ON u.id = o.user_id LEFT JOIN items i ON i.order_id = o.id WHERE o.total > $1",
+	"SELECT * FROM (SELECT a, b FROM t) AS d (x, y) WHERE EXISTS (SELECT 1 FROM u WHERE u.x = d.x)",
+	"WITH RECURSIVE r (n) AS (SELECT 1 UNION ALL SELECT n + 1 FROM r WHERE n < 10), s AS (SELECT 2) SELECT n FROM r",
+	"SELECT a FROM t UNION SELECT a FROM u INTERSECT SELECT a FROM v ORDER BY a LIMIT 10 OFFSET 5",
+	"(SELECT a FROM t UNION SELECT a FROM u) INTERSECT (SELECT a FROM v LIMIT 1)",
+	"SELECT `select`, \"two words\", `back``tick`, \"quote\"\"d\", \"MixedCase\" FROM `order`",
+	"SELECT 'it''s', E'tab\\there\\nand \\\\ back', 'back \\ slash', X'CAFE', x'', 1.5e10, 2E-3 FROM t",
+	"SELECT prénom, \"名前\", 'naïve ☃' FROM café WHERE straße = 'größe'",
+	"INSERT INTO t (a, b) VALUES (1, 'x'), (2, NULL) ON CONFLICT (a) DO UPDATE SET b = excluded.b WHERE t.b IS NULL RETURNING a, b",
+	"INSERT INTO t SELECT * FROM u ON CONFLICT DO NOTHING",
+	"UPDATE t SET a = a + 1, b = u.b FROM u WHERE t.id = u.id RETURNING *",
+	"DELETE FROM t USING u JOIN v ON u.id = v.id WHERE t.id = u.id RETURNING t.id",
+	"MERGE INTO t AS x USING (SELECT id, v FROM s) AS src ON x.id = src.id WHEN MATCHED AND src.v IS NULL THEN DELETE WHEN MATCHED THEN UPDATE SET v = src.v WHEN NOT MATCHED THEN INSERT (id, v) VALUES (src.id, src.v) WHEN NOT MATCHED AND src.v = 0 THEN DO NOTHING",
+	"CREATE TABLE t (id INTEGER PRIMARY KEY AUTO_INCREMENT, name VARCHAR(20) NOT NULL UNIQUE, score DECIMAL(5,2) DEFAULT 0, CONSTRAINT fk FOREIGN KEY (name) REFERENCES u (name), CHECK (score >= 0)) PARTITION BY RANGE (id) (PARTITION p0 VALUES LESS THAN (100), PARTITION p1 VALUES LESS THAN MAXVALUE) WITH (clustered = true, compressed)",
+	"CREATE TABLE t (a INTEGER) PARTITION BY LIST (a) (PARTITION p VALUES IN (1, 2), PARTITION q VALUES IN (3))",
+	"CREATE TABLE t (a INTEGER) PARTITION BY HASH (a) PARTITIONS 4",
+	"CREATE OR REPLACE VIEW v (a, b) AS SELECT a, b FROM t",
+	"CREATE MATERIALIZED VIEW IF NOT EXISTS m AS SELECT a FROM t WITH NO DATA",
+	"DROP MATERIALIZED VIEW IF EXISTS m CASCADE",
+	"REFRESH MATERIALIZED VIEW CONCURRENTLY m",
+	"CREATE UNIQUE INDEX IF NOT EXISTS i ON t (a, b) USING btree",
+	"DROP INDEX IF EXISTS i",
+	"DROP TABLE IF EXISTS t CASCADE",
+	"ALTER TABLE t ADD COLUMN c TEXT DEFAULT 'x', DROP COLUMN d, RENAME COLUMN e TO f, RENAME TO u, ADD CONSTRAINT pk PRIMARY KEY (a), DROP CONSTRAINT old, SET (ttl = 10), RESET (ttl)",
+	"BEGIN ISOLATION LEVEL REPEATABLE READ",
+	"COMMIT",
+	"ROLLBACK TO SAVEPOINT s",
+	"SAVEPOINT s",
+	"RELEASE SAVEPOINT s",
+}
+
+// stripPositions clears the source positions of a parsed statement, which
+// formatting moves
+func stripPositions(v reflect.Value) {
+	switch v.Kind() {
+	case reflect.Ptr, reflect.Interface:
+		if !v.IsNil() {
+			stripPositions(v.Elem())
+		}
+	case reflect.Slice:
+		for i := 0; i < v.Len(); i++ {
+			stripPositions(v.Index(i))
+		}
+	case reflect.Struct:
+		if v.Type() == reflect.TypeOf(parser.Pos{}) {
+			v.Set(reflect.Zero(v.Type()))
+			return
+		}
+		for i := 0; i < v.NumField(); i++ {
+			stripPositions(v.Field(i))
+		}
+	}
+}
+
+// parse parses a statement with its positions cleared
+func parse(t *testing.T, sql string) parser.Statement {
+	stmt, err := parser.ParseSQL(sql)
+	if err != nil {
+		t.Fatalf("failed to parse %q: %v", sql, err)
+	}
+	stripPositions(reflect.ValueOf(stmt))
+	return stmt
+}
+
+func TestRoundTrip(t *testing.T) {
+	statements := append(corpus(t), extraCorpus...)
+	if len(statements) < 2*len(extraCorpus) {
+		t.Fatalf("expected the parser tests to provide a corpus, found %d statements", len(statements)-len(extraCorpus))
+	}
+
+	narrow := DefaultOptions()
+	narrow.LineWidth = 20
+	lower := DefaultOptions()
+	lower.KeywordCase = LowerCase
+	lower.Indent = "\t"
+	options := map[string]Options{"default": DefaultOptions(), "narrow": narrow, "lower": lower}
+
+	for _, sql := range statements {
+		want := parse(t, sql)
+		for name, opts := range options {
+			formatted := Format(want, opts)
+			got, err := parser.ParseSQL(formatted)
+			if err != nil {
+				t.Errorf("%s: formatting %q gave SQL that does not parse: %v\n%s", name, sql, err, formatted)
+				continue
+			}
+			stripPositions(reflect.ValueOf(got))
+			if !reflect.DeepEqual(got, want) {
+				t.Errorf("%s: formatting %q changed the statement:\n%s\nwas parsed as %s", name, sql, formatted, got)
+			}
+			if again := Format(got, opts); again != formatted {
+				t.Errorf("%s: formatting %q is not stable:\n%s\nthen\n%s", name, sql, formatted, again)
+			}
+		}
+	}
+}
+
+func TestFormatLayout(t *testing.T) {
+	tests := []struct {
+		name string
+		sql  string
+		opts func(*Options)
+		want string
+	}{
+		{
+			name: "short clauses stay on one line",
+			sql:  "select id, name from users u where u.active = true order by name asc limit 10",
+			want: "SELECT id, name\nFROM users AS u\nWHERE u.active = TRUE\nORDER BY name\nLIMIT 10",
+		},
+		{
+			name: "long lists break one item per line",
+			sql:  "SELECT customer_name, customer_email, customer_phone, customer_address FROM customers",
+			opts: func(o *Options) { o.LineWidth = 40 },
+			want: "SELECT\n    customer_name,\n    customer_email,\n    customer_phone,\n    customer_address\nFROM customers",
+		},
+		{
+			name: "long conditions break before each operator",
+			sql:  "SELECT a FROM t JOIN u ON t.id = u.id AND t.kind = u.kind WHERE t.a > 1 AND (t.b < 2 OR t.c = 3)",
+			opts: func(o *Options) { o.LineWidth = 30 },
+			want: "SELECT a\nFROM t\nINNER JOIN u\n    ON t.id = u.id\n        AND t.kind = u.kind\nWHERE t.a > 1\n    AND (t.b < 2 OR t.c = 3)",
+		},
+		{
+			name: "subqueries are indented",
+			sql:  "SELECT name FROM users WHERE id IN (SELECT user_id FROM orders WHERE total > 100)",
+			want: "SELECT name\nFROM users\nWHERE id IN (\n    SELECT user_id\n    FROM orders\n    WHERE total > 100\n)",
+		},
+		{
+			name: "common table expressions and set operations",
+			sql:  "WITH a AS (SELECT 1), b AS (SELECT 2) SELECT * FROM a UNION ALL SELECT * FROM b",
+			want: "WITH a AS (\n    SELECT 1\n),\nb AS (\n    SELECT 2\n)\nSELECT *\nFROM a\nUNION ALL\nSELECT *\nFROM b",
+		},
+		{
+			name: "lower case keywords and tab indentation",
+			sql:  "CREATE TABLE t (id INTEGER PRIMARY KEY, name TEXT NOT NULL DEFAULT 'x')",
+			opts: func(o *Options) { o.KeywordCase = LowerCase; o.Indent = "\t" },
+			want: "create table t (\n\tid INTEGER primary key,\n\tname TEXT not null default 'x'\n)",
+		},
+		{
+			name: "wide CASE expressions break per branch",
+			sql:  "SELECT CASE WHEN score >= 90 THEN 'excellent' WHEN score >= 50 THEN 'passing' ELSE 'failing' END FROM results",
+			opts: func(o *Options) { o.LineWidth = 40 },
+			want: "SELECT\n    CASE\n        WHEN score >= 90 THEN 'excellent'\n        WHEN score >= 50 THEN 'passing'\n        ELSE 'failing'\n    END\nFROM results",
+		},
+		{
+			name: "names that are keywords are quoted",
+			sql:  "SELECT `order`, \"first name\" FROM `table`",
+			want: "SELECT \"order\", \"first name\"\nFROM \"table\"",
+		},
+		{
+			name: "quoted names keep their quotes",
+			sql:  "SELECT \"MixedCase\", \"id\" AS \"Total\" FROM \"users\"",
+			want: "SELECT \"MixedCase\", \"id\" AS \"Total\"\nFROM \"users\"",
+		},
+		{
+			name: "operators are parenthesized only where needed",
+			sql:  "SELECT ((a + b)) * c, a + (b * c), a - (b - c), NOT (a AND b), - -a FROM t",
+			want: "SELECT (a + b) * c, a + b * c, a - (b - c), NOT (a AND b), -(-a)\nFROM t",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			opts := DefaultOptions()
+			if tt.opts != nil {
+				tt.opts(&opts)
+			}
+			if got := Format(parse(t, tt.sql), opts); got != tt.want {
+				t.Errorf("Format() =\n%s\nwant\n%s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestFormatScriptComments(t *testing.T) {
+	source := strings.Join([]string{
+		"-- Schema for the shop",
+		"",
+		"create table users (id integer primary key, /* display name */ name text); -- people",
+		"",
+		"",
+		"insert into users values (1, 'ann'), -- first",
+		"  (2, 'bob');",
+		"/* done */",
+	}, "\n")
+
+	want := strings.Join([]string{
+		"-- Schema for the shop",
+		"",
+		"CREATE TABLE users (",
+		"    id integer PRIMARY KEY,",
+		"    /* display name */ name text",
+		"); -- people",
+		"",
+		"INSERT INTO users",
+		"VALUES",
+		"    (1, 'ann'),",
+		"    -- first",
+		"    (2, 'bob');",
+		"/* done */",
+		"",
+	}, "\n")
+
+	got, err := FormatScript(source, DefaultOptions())
+	if err != nil {
+		t.Fatalf("FormatScript() failed: %v", err)
+	}
+	if got != want {
+		t.Errorf("FormatScript() =\n%s\nwant\n%s", got, want)
+	}
+
+	// Formatting is idempotent, comments included
+	again, err := FormatScript(got, DefaultOptions())
+	if err != nil {
+		t.Fatalf("FormatScript() of formatted script failed: %v", err)
+	}
+	if again != got {
+		t.Errorf("formatting again gave\n%s\nwant\n%s", again, got)
+	}
+}
+
+func TestFormatScriptErrors(t *testing.T) {
+	_, err := FormatScript("SELECT 1;\nSELECT FROM;", DefaultOptions())
+	errs, ok := err.(parser.ParseErrors)
+	if !ok || len(errs) != 1 {
+		t.Fatalf("expected one parse error, got %v", err)
+	}
+	if errs[0].Line != 2 || errs[0].Statement != 2 {
+		t.Errorf("expected the error in statement 2 on line 2, got %v", errs[0])
+	}
+
+	if got, err := FormatScript("  \n-- nothing here\n", DefaultOptions()); err != nil || got != "-- nothing here\n" {
+		t.Errorf("FormatScript() of a comment = %q, %v", got, err)
+	}
+}
@@ -0,0 +1,615 @@
+package formatter
+
+import (
+	"fmt"
+	"strings"
+
+	"relational-db/internal/parser"
+)
+
+// statement renders a statement at the given depth. Like every rendering
+// of the formatter, the first line is not indented and later lines carry
+// their full indentation.
+func (f *formatter) statement(stmt parser.Statement, depth int) string {
+	switch s := stmt.(type) {
+	case *parser.SelectStatement:
+		return f.query(s, depth)
+	case *parser.InsertStatement:
+		return f.insert(s, depth)
+	case *parser.UpdateStatement:
+		return f.update(s, depth)
+	case *parser.DeleteStatement:
+		return f.delete(s, depth)
+	case *parser.MergeStatement:
+		return f.merge(s, depth)
+	case *parser.CreateTableStatement:
+		return f.createTable(s, depth)
+	case *parser.DropTableStatement:
+		result := f.keyword("DROP TABLE") + " "
+		if s.IfExists {
+			result += f.keyword("IF EXISTS") + " "
+		}
+		return result + f.identifier(s.TableName, depth) + f.dropBehavior(s.Cascade)
+	case *parser.CreateViewStatement:
+		return f.createView(s, depth)
+	case *parser.DropViewStatement:
+		result := f.keyword("DROP") + " "
+		if s.Materialized {
+			result += f.keyword("MATERIALIZED") + " "
+		}
+		result += f.keyword("VIEW") + " "
+		if s.IfExists {
+			result += f.keyword("IF EXISTS") + " "
+		}
+		return result + f.identifier(s.ViewName, depth) + f.dropBehavior(s.Cascade)
+	case *parser.RefreshMaterializedViewStatement:
+		result := f.keyword("REFRESH MATERIALIZED VIEW") + " "
+		if s.Concurrently {
+			result += f.keyword("CONCURRENTLY") + " "
+		}
+		return result + f.identifier(s.ViewName, depth)
+	case *parser.CreateIndexStatement:
+		return f.createIndex(s, depth)
+	case *parser.DropIndexStatement:
+		result := f.keyword("DROP INDEX") + " "
+		if s.IfExists {
+			result += f.keyword("IF EXISTS") + " "
+		}
+		return result + f.identifier(s.IndexName, depth)
+	case *parser.AlterTableStatement:
+		clause := f.keyword("ALTER TABLE") + " " + f.identifier(s.TableName, depth)
+		return f.list(clause, len(s.Actions), depth, func(i, depth int) string {
+			return f.alterTableAction(s.Actions[i], depth)
+		})
+	case *parser.BeginStatement:
+		if s.IsolationLevel == parser.IsolationDefault {
+			return f.keyword("BEGIN")
+		}
+		return f.keyword("BEGIN ISOLATION LEVEL " + s.IsolationLevel.String())
+	case *parser.CommitStatement:
+		return f.keyword("COMMIT")
+	case *parser.RollbackStatement:
+		if s.Savepoint == nil {
+			return f.keyword("ROLLBACK")
+		}
+		return f.keyword("ROLLBACK TO SAVEPOINT") + " " + f.identifier(s.Savepoint, depth)
+	case *parser.SavepointStatement:
+		return f.keyword("SAVEPOINT") + " " + f.identifier(s.Name, depth)
+	case *parser.ReleaseSavepointStatement:
+		return f.keyword("RELEASE SAVEPOINT") + " " + f.identifier(s.Name, depth)
+	default:
+		return stmt.String()
+	}
+}
+
+// lines joins the clauses of a statement, one to a line
+func (f *formatter) lines(clauses []string, depth int) string {
+	return strings.Join(clauses, "\n"+f.indent(depth))
+}
+
+// query renders a query with each of its clauses starting a line
+func (f *formatter) query(s *parser.SelectStatement, depth int) string {
+	var clauses []string
+
+	if s.With != nil {
+		clauses = append(clauses, f.with(s.With, depth))
+	}
+	if s.SetOperation != nil {
+		clauses = append(clauses, f.setOperation(s.SetOperation, depth))
+	}
+	if s.SelectClause != nil {
+		lead := f.leading(firstPosition(s.SelectClause.Columns), depth)
+		clause := f.keyword("SELECT")
+		if s.SelectClause.Distinct {
+			clause += " " + f.keyword("DISTINCT")
+		}
+		clauses = append(clauses, lead+f.expressionList(clause, s.SelectClause.Columns, depth))
+	}
+	if s.FromClause != nil {
+		clauses = append(clauses, f.from(f.keyword("FROM"), s.FromClause, depth))
+	}
+	if s.WhereClause != nil {
+		clauses = append(clauses, f.condition(f.keyword("WHERE"), s.WhereClause.Condition, depth))
+	}
+	if s.GroupBy != nil {
+		lead := f.leading(firstPosition(s.GroupBy.Columns), depth)
+		clauses = append(clauses, lead+f.expressionList(f.keyword("GROUP BY"), s.GroupBy.Columns, depth))
+	}
+	if s.Having != nil {
+		clauses = append(clauses, f.condition(f.keyword("HAVING"), s.Having.Condition, depth))
+	}
+	if len(s.Windows) > 0 {
+		windows := make([]string, len(s.Windows))
+		for i, window := range s.Windows {
+			windows[i] = f.identifier(window.Name, depth) + " " + f.keyword("AS") + " " + f.window(window.Spec, depth)
+		}
+		clauses = append(clauses, f.keyword("WINDOW")+" "+strings.Join(windows, ", "))
+	}
+	if s.OrderBy != nil {
+		clauses = append(clauses, f.orderBy(s.OrderBy, depth))
+	}
+	if s.Limit != nil {
+		clause := f.leading(parser.PositionOf(s.Limit.Count), depth) + f.keyword("LIMIT") + " " +
+			f.expression(s.Limit.Count, 0, depth)
+		if s.Limit.Offset != nil {
+			clause += " " + f.keyword("OFFSET") + " " + f.expression(s.Limit.Offset, 0, depth)
+		}
+		clauses = append(clauses, clause)
+	}
+
+	return f.lines(clauses, depth)
+}
+
+// nested renders a query in parentheses, on lines of its own one level
+// deeper than the parentheses
+func (f *formatter) nested(s *parser.SelectStatement, depth int) string {
+	return "(\n" + f.indent(depth+1) + f.query(s, depth+1) + "\n" + f.indent(depth) + ")"
+}
+
+// with renders a WITH clause, starting each common table expression on a
+// line of its own
+func (f *formatter) with(w *parser.WithClause, depth int) string {
+	var result strings.Builder
+	result.WriteString(f.keyword("WITH") + " ")
+	if w.Recursive {
+		result.WriteString(f.keyword("RECURSIVE") + " ")
+	}
+
+	for i, cte := range w.CTEs {
+		if i > 0 {
+			result.WriteString(",\n" + f.indent(depth))
+		}
+		result.WriteString(f.identifier(cte.Name, depth))
+		if len(cte.Columns) > 0 {
+			result.WriteString(" (" + f.names(cte.Columns, depth) + ")")
+		}
+		result.WriteString(" " + f.keyword("AS") + " ")
+
+		query := cte.Query
+		if cte.RecursiveTerm != nil {
+			query = &parser.SelectStatement{SetOperation: &parser.SetOperation{
+				Operator: parser.Union,
+				All:      cte.UnionAll,
+				Left:     cte.Query,
+				Right:    cte.RecursiveTerm,
+			}}
+		}
+		result.WriteString(f.nested(query, depth))
+	}
+	return result.String()
+}
+
+// setOperation renders the operands of a set operation on either side of
+// its operator. Operands are parenthesized where the parser would otherwise
+// group them differently or apply their clauses to the whole operation.
+func (f *formatter) setOperation(op *parser.SetOperation, depth int) string {
+	operator := op.Operator.String()
+	if op.All {
+		operator += " ALL"
+	}
+
+	left := op.Left
+	leftParens := ownsClauses(left) ||
+		(left.SetOperation != nil && op.Operator == parser.Intersect && left.SetOperation.Operator != parser.Intersect)
+	right := op.Right
+	rightParens := ownsClauses(right) || right.SetOperation != nil
+
+	return f.setOperand(left, leftParens, depth) + "\n" +
+		f.indent(depth) + f.keyword(operator) + "\n" +
+		f.indent(depth) + f.setOperand(right, rightParens, depth)
+}
+
+// setOperand renders an operand of a set operation
+func (f *formatter) setOperand(s *parser.SelectStatement, parens bool, depth int) string {
+	if parens {
+		return f.nested(s, depth)
+	}
+	return f.query(s, depth)
+}
+
+// ownsClauses reports whether an operand of a set operation has clauses
+// that would otherwise apply to the whole operation
+func ownsClauses(s *parser.SelectStatement) bool {
+	return s.With != nil || s.OrderBy != nil || s.Limit != nil
+}
+
+// from renders the tables of a FROM clause, or of the USING clause of a
+// DELETE, followed by its joins one to a line
+func (f *formatter) from(clause string, from *parser.FromClause, depth int) string {
+	lead := f.leading(firstPosition(from.Tables), depth)
+	clauses := []string{lead + f.expressionList(clause, from.Tables, depth)}
+
+	for _, join := range from.Joins {
+		lead := f.leading(parser.PositionOf(join.Table), depth)
+		line := f.keyword(join.JoinType.String()) + " " + f.expression(join.Table, 0, depth)
+		if join.Condition != nil {
+			mark := f.next
+			on := line + " " + f.keyword("ON") + " " + f.expression(join.Condition, 0, depth)
+			if f.fits(on, depth) {
+				line = on
+			} else {
+				f.next = mark
+				line += "\n" + f.indent(depth+1) + f.condition(f.keyword("ON"), join.Condition, depth+1)
+			}
+		}
+		clauses = append(clauses, lead+line)
+	}
+
+	return f.lines(clauses, depth)
+}
+
+// orderBy renders an ORDER BY clause. Ascending order is the default and
+// is left out.
+func (f *formatter) orderBy(order *parser.OrderByClause, depth int) string {
+	lead := f.leading(parser.PositionOf(order.Orders[0].Expression), depth)
+	return lead + f.list(f.keyword("ORDER BY"), len(order.Orders), depth, func(i, depth int) string {
+		return f.orderExpression(order.Orders[i], depth)
+	})
+}
+
+// orderExpression renders one ordering of ORDER BY
+func (f *formatter) orderExpression(order *parser.OrderExpression, depth int) string {
+	result := f.expression(order.Expression, 0, depth)
+	if order.Direction == parser.Descending {
+		result += " " + f.keyword("DESC")
+	}
+	return result
+}
+
+// insert renders an INSERT statement, with a line for each of its rows
+// when they do not fit on one
+func (f *formatter) insert(s *parser.InsertStatement, depth int) string {
+	head := f.keyword("INSERT INTO") + " " + f.identifier(s.TableName, depth)
+	if len(s.Columns) > 0 {
+		head += " (" + f.names(s.Columns, depth) + ")"
+	}
+	clauses := []string{head}
+
+	if s.Query != nil {
+		clauses = append(clauses, f.query(s.Query, depth))
+	} else {
+		clauses = append(clauses, f.list(f.keyword("VALUES"), len(s.Values), depth, func(i, depth int) string {
+			return f.leading(firstPosition(s.Values[i]), depth) + "(" + f.join(s.Values[i], depth) + ")"
+		}))
+	}
+
+	if o := s.OnConflict; o != nil {
+		clause := f.keyword("ON CONFLICT")
+		if len(o.Columns) > 0 {
+			clause += " (" + f.names(o.Columns, depth) + ")"
+		}
+		if !o.DoUpdate {
+			clause += " " + f.keyword("DO NOTHING")
+		} else {
+			clause += " " + f.keyword("DO UPDATE") + "\n" + f.indent(depth+1) + f.setClauses(o.SetClauses, depth+1)
+			if o.WhereClause != nil {
+				clause += "\n" + f.indent(depth+1) + f.condition(f.keyword("WHERE"), o.WhereClause.Condition, depth+1)
+			}
+		}
+		clauses = append(clauses, clause)
+	}
+
+	return f.lines(append(clauses, f.returning(s.Returning, depth)...), depth)
+}
+
+// update renders an UPDATE statement
+func (f *formatter) update(s *parser.UpdateStatement, depth int) string {
+	clauses := []string{
+		f.keyword("UPDATE") + " " + f.identifier(s.TableName, depth),
+		f.setClauses(s.SetClauses, depth),
+	}
+	if s.From != nil {
+		clauses = append(clauses, f.from(f.keyword("FROM"), s.From, depth))
+	}
+	if s.WhereClause != nil {
+		clauses = append(clauses, f.condition(f.keyword("WHERE"), s.WhereClause.Condition, depth))
+	}
+	return f.lines(append(clauses, f.returning(s.Returning, depth)...), depth)
+}
+
+// delete renders a DELETE statement
+func (f *formatter) delete(s *parser.DeleteStatement, depth int) string {
+	clauses := []string{f.keyword("DELETE FROM") + " " + f.identifier(s.TableName, depth)}
+	if s.Using != nil {
+		clauses = append(clauses, f.from(f.keyword("USING"), s.Using, depth))
+	}
+	if s.WhereClause != nil {
+		clauses = append(clauses, f.condition(f.keyword("WHERE"), s.WhereClause.Condition, depth))
+	}
+	return f.lines(append(clauses, f.returning(s.Returning, depth)...), depth)
+}
+
+// setClauses renders the SET list of an UPDATE
+func (f *formatter) setClauses(set []*parser.SetClause, depth int) string {
+	return f.list(f.keyword("SET"), len(set), depth, func(i, depth int) string {
+		return f.identifier(set[i].Column, depth) + " = " + f.expression(set[i].Value, 0, depth)
+	})
+}
+
+// returning renders the RETURNING clause of a data-modifying statement, as
+// no clauses when it has none
+func (f *formatter) returning(returning []parser.Expression, depth int) []string {
+	if len(returning) == 0 {
+		return nil
+	}
+	return []string{f.expressionList(f.keyword("RETURNING"), returning, depth)}
+}
+
+// merge renders a MERGE statement, with the action of each WHEN clause
+// indented under it
+func (f *formatter) merge(s *parser.MergeStatement, depth int) string {
+	clauses := []string{
+		f.keyword("MERGE INTO") + " " + f.identifier(s.Target, depth),
+		f.keyword("USING") + " " + f.expression(s.Source, 0, depth),
+		f.condition(f.keyword("ON"), s.Condition, depth),
+	}
+
+	for _, clause := range s.Clauses {
+		when := f.keyword("WHEN MATCHED")
+		if !clause.Matched {
+			when = f.keyword("WHEN NOT MATCHED")
+		}
+		if clause.Condition != nil {
+			when += " " + f.keyword("AND") + " " + f.expression(clause.Condition, 0, depth)
+		}
+		when += " " + f.keyword("THEN") + "\n" + f.indent(depth+1)
+
+		switch clause.Action {
+		case parser.MergeUpdate:
+			when += f.keyword("UPDATE") + " " + f.setClauses(clause.SetClauses, depth+1)
+		case parser.MergeDelete:
+			when += f.keyword("DELETE")
+		case parser.MergeInsert:
+			when += f.keyword("INSERT")
+			if len(clause.Columns) > 0 {
+				when += " (" + f.names(clause.Columns, depth+1) + ")"
+			}
+			when += " " + f.keyword("VALUES") + " (" + f.join(clause.Values, depth+1) + ")"
+		case parser.MergeDoNothing:
+			when += f.keyword("DO NOTHING")
+		}
+		clauses = append(clauses, when)
+	}
+
+	return f.lines(clauses, depth)
+}
+
+// createTable renders a CREATE TABLE statement with each column and table
+// constraint on a line of its own
+func (f *formatter) createTable(s *parser.CreateTableStatement, depth int) string {
+	var elements []string
+	for _, column := range s.Columns {
+		elements = append(elements, f.columnDefinition(column, depth+1))
+	}
+	for _, constraint := range s.Constraints {
+		elements = append(elements, f.tableConstraint(constraint, depth+1))
+	}
+
+	var result strings.Builder
+	result.WriteString(f.keyword("CREATE TABLE") + " " + f.identifier(s.TableName, depth) + " (")
+	for i, element := range elements {
+		if i > 0 {
+			result.WriteString(",")
+		}
+		result.WriteString("\n" + f.indent(depth+1) + element)
+	}
+	result.WriteString("\n" + f.indent(depth) + ")")
+
+	if spec := s.PartitionBy; spec != nil {
+		result.WriteString("\n" + f.indent(depth) + f.keyword("PARTITION BY "+spec.Method.String()))
+		result.WriteString(" (" + f.identifier(spec.Column, depth) + ")")
+		if len(spec.Partitions) == 0 {
+			result.WriteString(fmt.Sprintf(" %s %d", f.keyword("PARTITIONS"), spec.Count))
+		} else {
+			result.WriteString(" (")
+			for i, partition := range spec.Partitions {
+				if i > 0 {
+					result.WriteString(",")
+				}
+				result.WriteString("\n" + f.indent(depth+1) + f.partition(partition, depth+1))
+			}
+			result.WriteString("\n" + f.indent(depth) + ")")
+		}
+	}
+
+	if len(s.Options) > 0 {
+		result.WriteString("\n" + f.indent(depth) + f.keyword("WITH") + " (" + f.tableOptions(s.Options, depth) + ")")
+	}
+	return result.String()
+}
+
+// columnDefinition renders a column of CREATE TABLE or ALTER TABLE ADD
+func (f *formatter) columnDefinition(column *parser.ColumnDefinition, depth int) string {
+	result := f.identifier(column.Name, depth) + " " + dataType(column.DataType)
+	for _, constraint := range column.Constraints {
+		result += " " + f.columnConstraint(constraint, depth)
+	}
+	return result
+}
+
+// columnConstraint renders a constraint of a column definition
+func (f *formatter) columnConstraint(c *parser.ColumnConstraint, depth int) string {
+	var result string
+	if c.Name != nil {
+		result = f.keyword("CONSTRAINT") + " " + f.identifier(c.Name, depth) + " "
+	}
+
+	switch c.Type {
+	case parser.NotNull:
+		return result + f.keyword("NOT NULL")
+	case parser.PrimaryKey:
+		return result + f.keyword("PRIMARY KEY")
+	case parser.UniqueKey:
+		return result + f.keyword("UNIQUE")
+	case parser.ForeignKey:
+		return result + f.references(c.References, depth)
+	case parser.Default:
+		return result + f.keyword("DEFAULT") + " " + f.expression(c.DefaultValue, 0, depth)
+	case parser.AutoIncrement:
+		return result + f.keyword("AUTO_INCREMENT")
+	default:
+		return result + c.String()
+	}
+}
+
+// tableConstraint renders a table constraint of CREATE TABLE or ALTER
+// TABLE ADD
+func (f *formatter) tableConstraint(c *parser.TableConstraint, depth int) string {
+	result := f.leading(constraintPosition(c), depth)
+	if c.Name != nil {
+		result = f.keyword("CONSTRAINT") + " " + f.identifier(c.Name, depth) + " "
+	}
+
+	switch c.Type {
+	case parser.PrimaryKey:
+		return result + f.keyword("PRIMARY KEY") + " (" + f.names(c.Columns, depth) + ")"
+	case parser.UniqueKey:
+		return result + f.keyword("UNIQUE") + " (" + f.names(c.Columns, depth) + ")"
+	case parser.ForeignKey:
+		result += f.keyword("FOREIGN KEY") + " (" + f.names(c.Columns, depth) + ")"
+		if c.References != nil {
+			result += " " + f.references(c.References, depth)
+		}
+		return result
+	case parser.Check:
+		return result + f.keyword("CHECK") + " (" + f.expression(c.Check, 0, depth) + ")"
+	default:
+		return result + c.String()
+	}
+}
+
+// constraintPosition returns the position of the first name or literal of
+// a table constraint
+func constraintPosition(c *parser.TableConstraint) parser.Pos {
+	switch {
+	case c.Name != nil:
+		return c.Name.Pos
+	case len(c.Columns) > 0:
+		return c.Columns[0].Pos
+	default:
+		return parser.PositionOf(c.Check)
+	}
+}
+
+// references renders the REFERENCES clause of a foreign key
+func (f *formatter) references(ref *parser.ForeignKeyReference, depth int) string {
+	result := f.keyword("REFERENCES") + " " + f.identifier(ref.Table, depth)
+	if len(ref.Columns) > 0 {
+		result += " (" + f.names(ref.Columns, depth) + ")"
+	}
+	return result
+}
+
+// partition renders a PARTITION entry of PARTITION BY
+func (f *formatter) partition(p *parser.PartitionDefinition, depth int) string {
+	result := f.keyword("PARTITION") + " " + f.identifier(p.Name, depth)
+	switch {
+	case len(p.Values) > 0:
+		result += " " + f.keyword("VALUES IN") + " (" + f.join(p.Values, depth) + ")"
+	case p.LessThan != nil:
+		result += " " + f.keyword("VALUES LESS THAN") + " (" + f.expression(p.LessThan, 0, depth) + ")"
+	case p.MaxValue:
+		result += " " + f.keyword("VALUES LESS THAN (MAXVALUE)")
+	}
+	return result
+}
+
+// tableOptions renders the name = value options of WITH or SET
+func (f *formatter) tableOptions(options []*parser.TableOption, depth int) string {
+	items := make([]string, len(options))
+	for i, option := range options {
+		items[i] = f.identifier(option.Name, depth)
+		if option.Value != nil {
+			items[i] += " = " + f.expression(option.Value, 0, depth)
+		}
+	}
+	return strings.Join(items, ", ")
+}
+
+// dataType renders a data type as it was parsed
+func dataType(d *parser.DataType) string {
+	switch {
+	case d.Length > 0:
+		return fmt.Sprintf("%s(%d)", d.Name, d.Length)
+	case d.Precision > 0:
+		return fmt.Sprintf("%s(%d,%d)", d.Name, d.Precision, d.Scale)
+	default:
+		return d.Name
+	}
+}
+
+// dropBehavior renders the CASCADE of a DROP statement
+func (f *formatter) dropBehavior(cascade bool) string {
+	if cascade {
+		return " " + f.keyword("CASCADE")
+	}
+	return ""
+}
+
+// createView renders a CREATE VIEW statement with its query starting on
+// the next line
+func (f *formatter) createView(s *parser.CreateViewStatement, depth int) string {
+	result := f.keyword("CREATE") + " "
+	if s.OrReplace {
+		result += f.keyword("OR REPLACE") + " "
+	}
+	if s.Materialized {
+		result += f.keyword("MATERIALIZED") + " "
+	}
+	result += f.keyword("VIEW") + " "
+	if s.IfNotExists {
+		result += f.keyword("IF NOT EXISTS") + " "
+	}
+	result += f.identifier(s.ViewName, depth)
+	if len(s.Columns) > 0 {
+		result += " (" + f.names(s.Columns, depth) + ")"
+	}
+	result += " " + f.keyword("AS") + "\n" + f.indent(depth) + f.query(s.Query, depth)
+	if s.WithNoData {
+		result += "\n" + f.indent(depth) + f.keyword("WITH NO DATA")
+	}
+	return result
+}
+
+// createIndex renders a CREATE INDEX statement
+func (f *formatter) createIndex(s *parser.CreateIndexStatement, depth int) string {
+	result := f.keyword("CREATE") + " "
+	if s.Unique {
+		result += f.keyword("UNIQUE") + " "
+	}
+	result += f.keyword("INDEX") + " "
+	if s.IfNotExists {
+		result += f.keyword("IF NOT EXISTS") + " "
+	}
+	result += f.identifier(s.IndexName, depth) + " " + f.keyword("ON") + " " + f.identifier(s.TableName, depth)
+	result += " (" + f.names(s.Columns, depth) + ")"
+	if s.Using != nil {
+		result += " " + f.keyword("USING") + " " + f.identifier(s.Using, depth)
+	}
+	return result
+}
+
+// alterTableAction renders a single change made by ALTER TABLE
+func (f *formatter) alterTableAction(action parser.AlterTableAction, depth int) string {
+	switch a := action.(type) {
+	case *parser.SetTableOptionsAction:
+		return f.keyword("SET") + " (" + f.tableOptions(a.Options, depth) + ")"
+	case *parser.ResetTableOptionsAction:
+		return f.keyword("RESET") + " (" + f.names(a.Names, depth) + ")"
+	case *parser.AddColumnAction:
+		return f.keyword("ADD COLUMN") + " " + f.columnDefinition(a.Column, depth)
+	case *parser.DropColumnAction:
+		return f.keyword("DROP COLUMN") + " " + f.identifier(a.Column, depth)
+	case *parser.RenameColumnAction:
+		return f.keyword("RENAME COLUMN") + " " + f.identifier(a.Column, depth) + " " +
+			f.keyword("TO") + " " + f.identifier(a.NewName, depth)
+	case *parser.RenameTableAction:
+		return f.keyword("RENAME TO") + " " + f.identifier(a.NewName, depth)
+	case *parser.AddConstraintAction:
+		return f.keyword("ADD") + " " + f.tableConstraint(a.Constraint, depth)
+	case *parser.DropConstraintAction:
+		return f.keyword("DROP CONSTRAINT") + " " + f.identifier(a.Name, depth)
+	default:
+		return action.String()
+	}
+}
@@ -2,8 +2,11 @@ package lexer
 
 import (
 	"fmt"
+	"encoding/hex"
+	"strconv"
 	"strings"
 	"unicode"
+	"unicode/utf8"
 )
 
 // TokenType represents the type of a SQL token
@@ -20,6 +23,8 @@ const (
 	IDENTIFIER
 	NUMBER
 	STRING
+	HEX_STRING // X'ABCD', the value holds the hex digits
+	PARAMETER // ?, $1
 
 	// Keywords
 	SELECT
@@ -113,6 +118,18 @@ const (
 	ALL
 	IF
 	EXISTS
+	WITH
+	UNION
+	INTERSECT
+	EXCEPT
+	CASE
+	WHEN
+	THEN
+	ELSE
+	END
+	CAST
+	TRUE
+	FALSE
 )
 
 // Token represents a single token in the SQL statement
@@ -122,6 +139,7 @@ type Token struct {
 	Position int
 	Line     int
 	Column   int
+	Quoted   bool // an identifier written in quotes
 }
 
 // String returns a string representation of the token type
@@ -141,6 +159,10 @@ func (t TokenType) String() string {
 		return "NUMBER"
 	case STRING:
 		return "STRING"
+	case HEX_STRING:
+		return "HEX_STRING"
+	case PARAMETER:
+		return "PARAMETER"
 	case SELECT:
 		return "SELECT"
 	case FROM:
@@ -165,16 +187,160 @@ func (t TokenType) String() string {
 		return "TABLE"
 	case DROP:
 		return "DROP"
+	case ALTER:
+		return "ALTER"
+	case INDEX:
+		return "INDEX"
+	case PRIMARY:
+		return "PRIMARY"
+	case KEY:
+		return "KEY"
+	case FOREIGN:
+		return "FOREIGN"
+	case REFERENCES:
+		return "REFERENCES"
+	case NOT:
+		return "NOT"
+	case NULL:
+		return "NULL"
+	case UNIQUE:
+		return "UNIQUE"
+	case DEFAULT:
+		return "DEFAULT"
+	case AUTO_INCREMENT:
+		return "AUTO_INCREMENT"
+	case CONSTRAINT:
+		return "CONSTRAINT"
+	case INTEGER:
+		return "INTEGER"
+	case TEXT:
+		return "TEXT"
+	case REAL:
+		return "REAL"
+	case BLOB:
+		return "BLOB"
+	case BOOLEAN:
+		return "BOOLEAN"
 	case EQUALS:
 		return "EQUALS"
+	case NOT_EQUALS:
+		return "NOT_EQUALS"
+	case LESS_THAN:
+		return "LESS_THAN"
+	case GREATER_THAN:
+		return "GREATER_THAN"
+	case LESS_EQUAL:
+		return "LESS_EQUAL"
+	case GREATER_EQUAL:
+		return "GREATER_EQUAL"
+	case LIKE:
+		return "LIKE"
+	case IN:
+		return "IN"
+	case BETWEEN:
+		return "BETWEEN"
+	case IS:
+		return "IS"
+	case AND:
+		return "AND"
+	case OR:
+		return "OR"
 	case SEMICOLON:
 		return "SEMICOLON"
 	case COMMA:
 		return "COMMA"
+	case DOT:
+		return "DOT"
 	case LPAREN:
 		return "LPAREN"
 	case RPAREN:
 		return "RPAREN"
+	case LBRACKET:
+		return "LBRACKET"
+	case RBRACKET:
+		return "RBRACKET"
+	case PLUS:
+		return "PLUS"
+	case MINUS:
+		return "MINUS"
+	case MULTIPLY:
+		return "MULTIPLY"
+	case DIVIDE:
+		return "DIVIDE"
+	case MODULO:
+		return "MODULO"
+	case COUNT:
+		return "COUNT"
+	case SUM:
+		return "SUM"
+	case AVG:
+		return "AVG"
+	case MIN:
+		return "MIN"
+	case MAX:
+		return "MAX"
+	case ORDER:
+		return "ORDER"
+	case BY:
+		return "BY"
+	case ASC:
+		return "ASC"
+	case DESC:
+		return "DESC"
+	case GROUP:
+		return "GROUP"
+	case HAVING:
+		return "HAVING"
+	case LIMIT:
+		return "LIMIT"
+	case OFFSET:
+		return "OFFSET"
+	case JOIN:
+		return "JOIN"
+	case INNER:
+		return "INNER"
+	case LEFT:
+		return "LEFT"
+	case RIGHT:
+		return "RIGHT"
+	case OUTER:
+		return "OUTER"
+	case ON:
+		return "ON"
+	case AS:
+		return "AS"
+	case DISTINCT:
+		return "DISTINCT"
+	case ALL:
+		return "ALL"
+	case IF:
+		return "IF"
+	case EXISTS:
+		return "EXISTS"
+	case WITH:
+		return "WITH"
+	case UNION:
+		return "UNION"
+	case INTERSECT:
+		return "INTERSECT"
+	case EXCEPT:
+		return "EXCEPT"
+	case CASE:
+		return "CASE"
+	case WHEN:
+		return "WHEN"
+	case THEN:
+		return "THEN"
+	case ELSE:
+		return "ELSE"
+	case END:
+		return "END"
+	case CAST:
+		return "CAST"
+	case TRUE:
+		return "TRUE"
+	case FALSE:
+		return "FALSE"
 	default:
 		return fmt.Sprintf("TokenType(%d)", int(t))
 	}
@@ -241,12 +407,26 @@ var Keywords = map[string]TokenType{
 	"ALL":            ALL,
 	"IF":             IF,
 	"EXISTS":         EXISTS,
+	"WITH":           WITH,
+	"UNION":          UNION,
+	"INTERSECT":      INTERSECT,
+	"EXCEPT":         EXCEPT,
+	"CASE":           CASE,
+	"WHEN":           WHEN,
+	"THEN":           THEN,
+	"ELSE":           ELSE,
+	"END":            END,
+	"CAST":           CAST,
+	"TRUE":           TRUE,
+	"FALSE":          FALSE,
 }
 
-// Lexer represents the lexical analyzer
+// Lexer represents the lexical analyzer. It reads its input as UTF-8, one
+// character at a time; positions are byte offsets.
 type Lexer struct {
 	input    string
-	position int
+	offset   int // byte offset of the current character
+	position int // byte offset of the next character
 	current  rune
 	line     int
 	column   int
@@ -263,14 +443,22 @@ func NewLexer(input string) *Lexer {
 	return l
 }
 
+// Source returns the text being tokenized
+func (l *Lexer) Source() string {
+	return l.input
+}
+
 // readChar reads the next character and advances position
 func (l *Lexer) readChar() {
+	l.offset = l.position
 	if l.position >= len(l.input) {
 		l.current = 0 // ASCII NUL represents EOF
+		l.position++
 	} else {
-		l.current = rune(l.input[l.position])
+		var width int
+		l.current, width = utf8.DecodeRuneInString(l.input[l.position:])
+		l.position += width
 	}
-	l.position++
 	if l.current == '\n' {
 		l.line++
 		l.column = 0
@@ -279,12 +467,25 @@ func (l *Lexer) readChar() {
 	}
 }
 
+// unreadChar backs up one character, so the character just read is read
+// again by the next readChar. A newline is uncounted, as reading it again
+// counts it.
+func (l *Lexer) unreadChar() {
+	l.position = l.offset
+	if l.current == '\n' {
+		l.line--
+	} else {
+		l.column--
+	}
+}
+
 // peekChar returns the next character without advancing position
 func (l *Lexer) peekChar() rune {
 	if l.position >= len(l.input) {
 		return 0
 	}
-	return rune(l.input[l.position])
+	ch, _ := utf8.DecodeRuneInString(l.input[l.position:])
+	return ch
 }
 
 // NextToken returns the next token from the input
@@ -293,7 +494,7 @@ func (l *Lexer) NextToken() Token {
 
 	l.skipWhitespace()
 
-	token.Position = l.position - 1
+	token.Position = l.offset
 	token.Line = l.line
 	token.Column = l.column
 
@@ -358,14 +559,26 @@ func (l *Lexer) NextToken() Token {
 		}
 	case '%':
 		token = Token{Type: MODULO, Value: string(l.current), Position: token.Position, Line: token.Line, Column: token.Column}
-	case '\'', '"':
+	case '\'':
 		token = l.readString()
-	case '`':
+	case '"', '`':
 		token = l.readIdentifier()
+	case '?':
+		token = Token{Type: PARAMETER, Value: string(l.current), Position: token.Position, Line: token.Line, Column: token.Column}
+	case '$':
+		if isDigit(l.peekChar()) {
+			token = l.readParameter()
+		} else {
+			token = Token{Type: ILLEGAL, Value: string(l.current), Position: token.Position, Line: token.Line, Column: token.Column}
+		}
 	case 0:
 		token = Token{Type: EOF, Value: "", Position: token.Position, Line: token.Line, Column: token.Column}
 	default:
-		if isLetter(l.current) {
+		if (l.current == 'e' || l.current == 'E') && l.peekChar() == '\'' {
+			token = l.readEscapeString()
+		} else if (l.current == 'x' || l.current == 'X') && l.peekChar() == '\'' {
+			token = l.readBlob()
+		} else if isLetter(l.current) {
 			token = l.readIdentifierOrKeyword()
 		} else if isDigit(l.current) {
 			token = l.readNumber()
@@ -380,17 +593,16 @@ func (l *Lexer) NextToken() Token {
 
 // readIdentifierOrKeyword reads an identifier or keyword
 func (l *Lexer) readIdentifierOrKeyword() Token {
-	position := l.position - 1
+	position := l.offset
 	line := l.line
 	column := l.column
 
 	var identifier strings.Builder
-	for isLetter(l.current) || isDigit(l.current) || l.current == '_' {
+	for isLetter(l.current) || unicode.IsDigit(l.current) || unicode.IsMark(l.current) {
 		identifier.WriteRune(l.current)
 		l.readChar()
 	}
-	l.position-- // Back up one character
-	l.column--
+	l.unreadChar()
 
 	value := identifier.String()
 	tokenType := lookupIdentifier(value)
@@ -404,69 +616,148 @@ func (l *Lexer) readIdentifierOrKeyword() Token {
 	}
 }
 
-// readIdentifier reads a quoted identifier
+// readIdentifier reads an identifier quoted with double quotes or
+// backticks. A quote is written inside one by doubling it. The name is
+// kept exactly as written, and is never a keyword.
 func (l *Lexer) readIdentifier() Token {
-	position := l.position - 1
+	position := l.offset
 	line := l.line
 	column := l.column
 
-	quote := l.current
-	l.readChar() // Skip opening quote
-
-	var identifier strings.Builder
-	for l.current != quote && l.current != 0 {
-		if l.current == '\\' {
-			l.readChar()
-			if l.current != 0 {
-				identifier.WriteRune(l.current)
-			}
-		} else {
-			identifier.WriteRune(l.current)
-		}
-		l.readChar()
+	value, ok := l.readQuoted()
+	if !ok {
+		return Token{Type: ILLEGAL, Value: "unterminated quoted identifier", Position: position, Line: line, Column: column}
+	}
+	if value == "" {
+		return Token{Type: ILLEGAL, Value: "empty quoted identifier", Position: position, Line: line, Column: column}
 	}
 
 	return Token{
 		Type:     IDENTIFIER,
-		Value:    identifier.String(),
+		Value:    value,
 		Position: position,
 		Line:     line,
 		Column:   column,
+		Quoted:   true,
 	}
 }
 
-// readString reads a string literal
+// readString reads a string literal. A quote is written inside one by
+// doubling it; backslashes have no special meaning.
 func (l *Lexer) readString() Token {
-	position := l.position - 1
+	position := l.offset
 	line := l.line
 	column := l.column
 
+	value, ok := l.readQuoted()
+	if !ok {
+		return Token{Type: ILLEGAL, Value: "unterminated string", Position: position, Line: line, Column: column}
+	}
+
+	return Token{
+		Type:     STRING,
+		Value:    value,
+		Position: position,
+		Line:     line,
+		Column:   column,
+	}
+}
+
+// readQuoted reads the text between the quote at the current character
+// and the matching closing quote, where two quotes in a row stand for one.
+// It reports false if the input ends first.
+func (l *Lexer) readQuoted() (string, bool) {
 	quote := l.current
 	l.readChar() // Skip opening quote
 
+	var text strings.Builder
+	for {
+		if l.current == 0 && l.offset >= len(l.input) {
+			return text.String(), false
+		}
+		if l.current == quote {
+			if l.peekChar() != quote {
+				return text.String(), true
+			}
+			l.readChar() // Skip the first of two quotes
+		}
+		text.WriteRune(l.current)
+		l.readChar()
+	}
+}
+
+// readEscapeString reads an escape string such as E'a\tb', in which
+// backslash escapes stand for special characters: \b \f \n \r \t, \xh or
+// \xhh and \o to \ooo for the byte with that hex or octal value, and
+// \uXXXX or \UXXXXXXXX for a Unicode character. Any other character after
+// a backslash stands for itself.
+func (l *Lexer) readEscapeString() Token {
+	position := l.offset
+	line := l.line
+	column := l.column
+
+	l.readChar() // Skip 'E'
+	l.readChar() // Skip opening quote
+
 	var str strings.Builder
-	for l.current != quote && l.current != 0 {
-		if l.current == '\\' {
+	for {
+		if l.current == 0 && l.offset >= len(l.input) {
+			return Token{Type: ILLEGAL, Value: "unterminated string", Position: position, Line: line, Column: column}
+		}
+		if l.current == '\'' {
+			if l.peekChar() != '\'' {
+				break
+			}
+			l.readChar() // Skip the first of two quotes
+		} else if l.current == '\\' {
 			l.readChar()
+			if l.offset >= len(l.input) {
+				continue // reported as unterminated
+			}
 			switch l.current {
 			case 'n':
-				str.WriteRune('\n')
+				l.current = '\n'
 			case 't':
-				str.WriteRune('\t')
+				l.current = '\t'
 			case 'r':
-				str.WriteRune('\r')
-			case '\\':
-				str.WriteRune('\\')
-			case '\'':
-				str.WriteRune('\'')
-			case '"':
-				str.WriteRune('"')
-			default:
-				str.WriteRune(l.current)
+				l.current = '\r'
+			case 'b':
+				l.current = '\b'
+			case 'f':
+				l.current = '\f'
+			case 'x':
+				// Without hex digits \x is a plain x
+				if digits := l.readEscapeDigits(2, 16); digits != "" {
+					value, _ := strconv.ParseUint(digits, 16, 8)
+					str.WriteByte(byte(value))
+					l.readChar()
+					continue
+				}
+			case '0', '1', '2', '3', '4', '5', '6', '7':
+				first := string(l.current)
+				digits := first + l.readEscapeDigits(2, 8)
+				value, _ := strconv.ParseUint(digits, 8, 16)
+				if value > 0xFF {
+					return Token{Type: ILLEGAL, Value: "invalid octal escape \\" + digits, Position: position, Line: line, Column: column}
+				}
+				str.WriteByte(byte(value))
+				l.readChar()
+				continue
+			case 'u', 'U':
+				size := 4
+				if l.current == 'U' {
+					size = 8
+				}
+				escape := string(l.current)
+				digits := l.readEscapeDigits(size, 16)
+				value, _ := strconv.ParseUint(digits, 16, 32)
+				if len(digits) != size || !utf8.ValidRune(rune(value)) {
+					return Token{Type: ILLEGAL, Value: "invalid unicode escape \\" + escape + digits, Position: position, Line: line, Column: column}
+				}
+				l.current = rune(value)
 			}
-		} else {
-			str.WriteRune(l.current)
 		}
+		str.WriteRune(l.current)
 		l.readChar()
 	}
 
@@ -479,9 +770,53 @@ func (l *Lexer) readString() Token {
 	}
 }
 
+// readEscapeDigits reads up to max digits in base 8 or 16 that follow the
+// current character, leaving the last one read as the current character
+func (l *Lexer) readEscapeDigits(max int, base int) string {
+	var digits strings.Builder
+	for digits.Len() < max {
+		ch := l.peekChar()
+		valid := ch >= '0' && ch <= '7'
+		if base == 16 {
+			valid = isDigit(ch) || (ch >= 'a' && ch <= 'f') || (ch >= 'A' && ch <= 'F')
+		}
+		if !valid {
+			break
+		}
+		l.readChar()
+		digits.WriteRune(l.current)
+	}
+	return digits.String()
+}
+
+// readBlob reads a blob literal such as X'CAFE', written as an even number
+// of hex digits
+func (l *Lexer) readBlob() Token {
+	position := l.offset
+	line := l.line
+	column := l.column
+
+	l.readChar() // Skip 'X'
+	digits, ok := l.readQuoted()
+	if !ok {
+		return Token{Type: ILLEGAL, Value: "unterminated blob", Position: position, Line: line, Column: column}
+	}
+	if _, err := hex.DecodeString(digits); err != nil {
+		return Token{Type: ILLEGAL, Value: "X'" + digits + "'", Position: position, Line: line, Column: column}
+	}
+
+	return Token{
+		Type:     HEX_STRING,
+		Value:    digits,
+		Position: position,
+		Line:     line,
+		Column:   column,
+	}
+}
+
 // readNumber reads a numeric literal
 func (l *Lexer) readNumber() Token {
-	position := l.position - 1
+	position := l.offset
 	line := l.line
 	column := l.column
 
@@ -501,8 +836,9 @@ func (l *Lexer) readNumber() Token {
 		}
 	}
 
-	// Check for scientific notation
-	if l.current == 'e' || l.current == 'E' {
+	// Check for scientific notation: an exponent needs digits, so that
+	// 1e is read as 1 followed by the name e
+	if (l.current == 'e' || l.current == 'E') && l.exponentFollows() {
 		number.WriteRune(l.current)
 		l.readChar()
 		if l.current == '+' || l.current == '-' {
@@ -515,8 +851,7 @@ func (l *Lexer) readNumber() Token {
 		}
 	}
 
-	l.position-- // Back up one character
-	l.column--
+	l.unreadChar()
 
 	return Token{
 		Type:     NUMBER,
@@ -527,9 +862,44 @@ func (l *Lexer) readNumber() Token {
 	}
 }
 
+// exponentFollows reports whether the e at the current character starts
+// the exponent of a number: digits, optionally after a sign
+func (l *Lexer) exponentFollows() bool {
+	next := l.position
+	if next < len(l.input) && (l.input[next] == '+' || l.input[next] == '-') {
+		next++
+	}
+	return next < len(l.input) && isDigit(rune(l.input[next]))
+}
+
+// readParameter reads a numbered parameter placeholder such as $1
+func (l *Lexer) readParameter() Token {
+	position := l.offset
+	line := l.line
+	column := l.column
+
+	var parameter strings.Builder
+	parameter.WriteRune(l.current)
+	l.readChar() // Skip '$'
+	for isDigit(l.current) {
+		parameter.WriteRune(l.current)
+		l.readChar()
+	}
+
+	l.unreadChar()
+
+	return Token{
+		Type:     PARAMETER,
+		Value:    parameter.String(),
+		Position: position,
+		Line:     line,
+		Column:   column,
+	}
+}
+
 // readComment reads a single-line comment
 func (l *Lexer) readComment() Token {
-	position := l.position - 1
+	position := l.offset
 	line := l.line
 	column := l.column
 
@@ -550,7 +920,7 @@ func (l *Lexer) readComment() Token {
 
 // readMultiLineComment reads a multi-line comment
 func (l *Lexer) readMultiLineComment() Token {
-	position := l.position - 1
+	position := l.offset
 	line := l.line
 	column := l.column
 
@@ -591,9 +961,9 @@ func isLetter(ch rune) bool {
 	return unicode.IsLetter(ch) || ch == '_'
 }
 
-// isDigit checks if a character is a digit
+// isDigit checks if a character is a decimal digit
 func isDigit(ch rune) bool {
-	return unicode.IsDigit(ch)
+	return ch >= '0' && ch <= '9'
 }
 
 // lookupIdentifier checks if an identifier is a keyword
@@ -25,6 +25,9 @@ func (cm *CostModel) EstimateCost(plan *PhysicalPlan) float64 {
 	case PhysicalPlanTypeIndexScan:
 		return cm.estimateIndexScanCost(plan)
 
+	case PhysicalPlanTypeClusteredIndexScan:
+		return cm.estimateClusteredIndexScanCost(plan)
+
 	case PhysicalPlanTypeFilter:
 		return cm.estimateFilterCost(plan)
 
@@ -52,6 +55,30 @@ func (cm *CostModel) EstimateCost(plan *PhysicalPlan) float64 {
 	case PhysicalPlanTypeLimit:
 		return cm.estimateLimitCost(plan)
 
+	case PhysicalPlanTypeSubqueryScan:
+		return cm.estimateSubqueryScanCost(plan)
+
+	case PhysicalPlanTypeCTEScan, PhysicalPlanTypeWorkTableScan:
+		return cm.estimateMaterializedScanCost(plan)
+
+	case PhysicalPlanTypeRecursiveUnion:
+		return cm.estimateRecursiveUnionCost(plan)
+
+	case PhysicalPlanTypeAppend:
+		return cm.estimateAppendCost(plan)
+
+	case PhysicalPlanTypeHashSetOp:
+		return cm.estimateHashSetOpCost(plan)
+
+	case PhysicalPlanTypeSortSetOp:
+		return cm.estimateSortSetOpCost(plan)
+
+	case PhysicalPlanTypeWindow:
+		return cm.estimateWindowCost(plan)
+
+	case PhysicalPlanTypeSingleRow:
+		return cm.config.CPUTupleCost
+
 	default:
 		// Unknown plan type - return high cost
 		return 1000000.0
@@ -99,6 +126,28 @@ func (cm *CostModel) estimateIndexScanCost(plan *PhysicalPlan) float64 {
 	return indexCost + dataCost + cpuCost
 }
 
+// estimateClusteredIndexScanCost estimates cost of a clustered index scan
+func (cm *CostModel) estimateClusteredIndexScanCost(plan *PhysicalPlan) float64 {
+	// Rows live in the index leaves, so there is no separate heap fetch:
+	// one descent to the first leaf, then a sequential walk of the leaf chain
+	selectivity := 1.0
+	if plan.KeyRange != nil {
+		selectivity = 0.1 // Default range selectivity (would come from statistics)
+	}
+
+	descentCost := math.Log2(math.Max(float64(plan.Cardinality), 2)) * cm.config.RandomPageCost
+
+	leafPages := float64(plan.Cardinality) * selectivity / 100.0
+	if leafPages < 1.0 {
+		leafPages = 1.0
+	}
+	leafCost := leafPages * cm.config.SeqPageCost
+
+	cpuCost := float64(plan.Cardinality) * selectivity * cm.config.CPUTupleCost
+
+	return descentCost + leafCost + cpuCost
+}
+
 // estimateFilterCost estimates cost of filter operation
 func (cm *CostModel) estimateFilterCost(plan *PhysicalPlan) float64 {
 	if len(plan.Children) == 0 {
@@ -240,6 +289,29 @@ func (cm *CostModel) estimateSortCostForRows(rows int64) float64 {
 	return comparisons * cm.config.CPUTupleCost
 }
 
+// estimateWindowCost estimates cost of computing window functions: one sort
+// of the input per distinct partitioned or ordered window, then a pass over
+// the rows per function
+func (cm *CostModel) estimateWindowCost(plan *PhysicalPlan) float64 {
+	if len(plan.Children) == 0 {
+		return 0
+	}
+
+	childCost := cm.EstimateCost(plan.Children[0])
+	rows := plan.Children[0].Cardinality
+
+	var sortCost float64
+	for _, spec := range WindowSpecs(plan.WindowFuncs) {
+		if len(spec.PartitionBy) > 0 || spec.OrderBy != nil {
+			sortCost += cm.estimateSortCostForRows(rows)
+		}
+	}
+
+	funcCost := float64(rows) * float64(len(plan.WindowFuncs)) * cm.config.CPUTupleCost
+
+	return childCost + sortCost + funcCost
+}
+
 // estimateProjectCost estimates cost of projection
 func (cm *CostModel) estimateProjectCost(plan *PhysicalPlan) float64 {
 	if len(plan.Children) == 0 {
@@ -268,6 +340,79 @@ func (cm *CostModel) estimateLimitCost(plan *PhysicalPlan) float64 {
 	return childCost * 0.1
 }
 
+// estimateSubqueryScanCost estimates cost of reading a derived table
+func (cm *CostModel) estimateSubqueryScanCost(plan *PhysicalPlan) float64 {
+	if len(plan.Children) == 0 {
+		return 0
+	}
+
+	// Rows are passed through with their columns renamed
+	childCost := cm.EstimateCost(plan.Children[0])
+	return childCost + float64(plan.Children[0].Cardinality)*cm.config.CPUTupleCost*0.1
+}
+
+// estimateMaterializedScanCost estimates cost of reading rows already held
+// in memory: a materialized CTE or a recursive CTE's work table. Computing
+// the rows is costed once, in the CTE's own plan.
+func (cm *CostModel) estimateMaterializedScanCost(plan *PhysicalPlan) float64 {
+	return float64(plan.Cardinality) * cm.config.CPUTupleCost
+}
+
+// estimateRecursiveUnionCost estimates cost of a recursive CTE: the anchor
+// once and the recursive term once per iteration
+func (cm *CostModel) estimateRecursiveUnionCost(plan *PhysicalPlan) float64 {
+	if len(plan.Children) != 2 {
+		return 0
+	}
+
+	// The number of iterations depends on the data; assume a shallow hierarchy
+	const iterations = 10.0
+	return cm.EstimateCost(plan.Children[0]) + iterations*cm.EstimateCost(plan.Children[1])
+}
+
+// estimateAppendCost estimates cost of UNION ALL: the rows of both inputs
+// passed through in turn
+func (cm *CostModel) estimateAppendCost(plan *PhysicalPlan) float64 {
+	cost := 0.0
+	for _, child := range plan.Children {
+		cost += cm.EstimateCost(child) + float64(child.Cardinality)*cm.config.CPUTupleCost*0.1
+	}
+	return cost
+}
+
+// estimateHashSetOpCost estimates cost of a hash-based set operation, which
+// counts the rows of both inputs in a hash table
+func (cm *CostModel) estimateHashSetOpCost(plan *PhysicalPlan) float64 {
+	if len(plan.Children) != 2 {
+		return 0
+	}
+
+	leftChild := plan.Children[0]
+	rightChild := plan.Children[1]
+
+	childCost := cm.EstimateCost(leftChild) + cm.EstimateCost(rightChild)
+	hashCost := float64(leftChild.Cardinality+rightChild.Cardinality) * cm.config.CPUTupleCost * 2.0
+
+	return childCost + hashCost
+}
+
+// estimateSortSetOpCost estimates cost of a sort-based set operation, which
+// sorts both inputs on all columns and merges them
+func (cm *CostModel) estimateSortSetOpCost(plan *PhysicalPlan) float64 {
+	if len(plan.Children) != 2 {
+		return 0
+	}
+
+	leftChild := plan.Children[0]
+	rightChild := plan.Children[1]
+
+	childCost := cm.EstimateCost(leftChild) + cm.EstimateCost(rightChild)
+	sortCost := cm.estimateSortCostForRows(leftChild.Cardinality) + cm.estimateSortCostForRows(rightChild.Cardinality)
+	mergeCost := float64(leftChild.Cardinality+rightChild.Cardinality) * cm.config.CPUTupleCost
+
+	return childCost + sortCost + mergeCost
+}
+
 // EstimateSelectivity estimates the selectivity of a predicate
 func (cm *CostModel) EstimateSelectivity(predicate interface{}) float64 {
 	// TODO: Implement selectivity estimation based on predicate type
@@ -5,9 +5,13 @@ package optimizer
 
 import (
 	"fmt"
+	"strconv"
+	"strings"
 	"time"
 
 	"relational-db/internal/compiler"
+	"relational-db/internal/lexer"
+	"relational-db/internal/parser"
 	"relational-db/internal/semantic"
 )
 
@@ -26,6 +30,7 @@ type OptimizerConfig struct {
 	EnableJoinReordering    bool
 	EnableIndexSelection    bool
 	EnableSubqueryUnnesting bool
+	EnableCTEInlining       bool // Plan CTEs referenced once in place instead of materializing them
 
 	// Cost model parameters
 	SeqPageCost    float64
@@ -44,6 +49,7 @@ func DefaultOptimizerConfig() *OptimizerConfig {
 		EnableJoinReordering:    true,
 		EnableIndexSelection:    true,
 		EnableSubqueryUnnesting: true,
+		EnableCTEInlining:       true,
 		SeqPageCost:             1.0,
 		RandomPageCost:          4.0,
 		CPUTupleCost:            0.01,
@@ -110,9 +116,23 @@ func (opt *Optimizer) Optimize(info *semantic.SemanticInfo) (*QueryPlan, error)
 		return nil, fmt.Errorf("plan selection failed: %w", err)
 	}
 
-	// Phase 5: Finalize plan
+	// Phase 5: Plan subqueries evaluated from expressions
+	subqueries, err := opt.planSubqueries(info)
+	if err != nil {
+		return nil, fmt.Errorf("subquery planning failed: %w", err)
+	}
+
+	// Phase 6: Plan common table expressions that are materialized
+	ctes, err := opt.planCTEs(info)
+	if err != nil {
+		return nil, fmt.Errorf("CTE planning failed: %w", err)
+	}
+
+	// Phase 7: Finalize plan
 	finalPlan := &QueryPlan{
 		Root:             bestPlan,
+		Subqueries:       subqueries,
+		CTEs:             ctes,
 		EstimatedCost:    bestPlan.Cost,
 		EstimatedRows:    bestPlan.Cardinality,
 		OptimizationTime: time.Since(startTime),
@@ -136,10 +156,13 @@ func (opt *Optimizer) createLogicalPlan(info *semantic.SemanticInfo) (*LogicalPl
 		return opt.createInsertPlan(compiled)
 
 	case compiler.QueryTypeUpdate:
-		return opt.createUpdatePlan(compiled)
+		return opt.createUpdatePlan(compiled, info)
 
 	case compiler.QueryTypeDelete:
-		return opt.createDeletePlan(compiled)
+		return opt.createDeletePlan(compiled, info)
+
+	case compiler.QueryTypeMerge:
+		return opt.createMergePlan(compiled)
 
 	default:
 		return nil, fmt.Errorf("unsupported query type for optimization: %s", compiled.QueryType)
@@ -148,16 +171,428 @@ func (opt *Optimizer) createLogicalPlan(info *semantic.SemanticInfo) (*LogicalPl
 
 // createSelectPlan creates logical plan for SELECT query
 func (opt *Optimizer) createSelectPlan(compiled *compiler.CompiledQuery, info *semantic.SemanticInfo) (*LogicalPlan, error) {
+	stmt, ok := compiled.Statement.(*parser.SelectStatement)
+	if !ok {
+		return nil, fmt.Errorf("expected SELECT statement")
+	}
+	return opt.selectPlan(stmt, info.HasAggregates, info)
+}
+
+// selectPlan creates the logical plan of a SELECT, which may be the query
+// itself or one of its subqueries
+func (opt *Optimizer) selectPlan(stmt *parser.SelectStatement, hasAggregates bool, info *semantic.SemanticInfo) (*LogicalPlan, error) {
+	if stmt.SetOperation != nil {
+		return opt.setOperationPlan(stmt, info)
+	}
+
+	// A query without FROM computes its select list once, from a row
+	// without columns
+	plan := &LogicalPlan{Type: PlanTypeSingleRow}
+	if stmt.FromClause != nil {
+		var err error
+		if plan, err = opt.fromPlan(stmt.FromClause, info); err != nil {
+			return nil, err
+		}
+	}
+
+	if stmt.WhereClause != nil {
+		plan = &LogicalPlan{
+			Type:       PlanTypeFilter,
+			FilterExpr: stmt.WhereClause.Condition,
+			Children:   []*LogicalPlan{plan},
+		}
+	}
+
+	if stmt.GroupBy != nil || hasAggregates {
+		plan = &LogicalPlan{
+			Type:       PlanTypeAggregate,
+			Aggregates: semantic.QueryAggregates(stmt),
+			Children:   []*LogicalPlan{plan},
+		}
+		if stmt.GroupBy != nil {
+			for _, key := range stmt.GroupBy.Columns {
+				plan.GroupBy = append(plan.GroupBy, selectedExpression(key, stmt.SelectClause.Columns))
+			}
+		}
+		if stmt.Having != nil {
+			plan = &LogicalPlan{
+				Type:       PlanTypeFilter,
+				FilterExpr: stmt.Having.Condition,
+				Children:   []*LogicalPlan{plan},
+			}
+		}
+	}
+
+	// Window functions see the grouped rows and feed the final sort
+	if windows := semantic.QueryWindowFunctions(stmt); len(windows) > 0 {
+		plan = &LogicalPlan{
+			Type:        PlanTypeWindow,
+			WindowFuncs: windows,
+			Children:    []*LogicalPlan{plan},
+		}
+	}
+
+	// The rows are sorted before the select list is computed, so keys
+	// naming a result column are replaced by the expression it selects
+	if stmt.OrderBy != nil {
+		sortKeys := make([]SortKey, 0, len(stmt.OrderBy.Orders))
+		for _, order := range stmt.OrderBy.Orders {
+			expr := selectedExpression(order.Expression, stmt.SelectClause.Columns)
+			sortKeys = append(sortKeys, SortKey{
+				Expr:       expr,
+				Column:     columnNameOf(expr),
+				Descending: order.Direction == parser.Descending,
+			})
+		}
+		plan = &LogicalPlan{
+			Type:     PlanTypeSort,
+			SortKeys: sortKeys,
+			Children: []*LogicalPlan{plan},
+		}
+	}
+
+	plan = &LogicalPlan{
+		Type:        PlanTypeProject,
+		Projections: stmt.SelectClause.Columns,
+		Children:    []*LogicalPlan{plan},
+	}
+
+	if stmt.Limit != nil {
+		plan = &LogicalPlan{
+			Type:     PlanTypeLimit,
+			Limit:    stmt.Limit,
+			Children: []*LogicalPlan{plan},
+		}
+	}
+
+	return plan, nil
+}
+
+// setOperationPlan creates the plan of a UNION, INTERSECT or EXCEPT: the
+// plans of the two queries it combines under a set operation node, then the
+// sort and limit of the compound query, which apply to the combined rows
+func (opt *Optimizer) setOperationPlan(stmt *parser.SelectStatement, info *semantic.SemanticInfo) (*LogicalPlan, error) {
+	op := stmt.SetOperation
+
+	var columns []string
+	if result, found := info.CompiledQuery.ResolvedRefs.QueryResults[stmt]; found {
+		for _, col := range result.Columns {
+			columns = append(columns, col.Name)
+		}
+	}
+
+	children := make([]*LogicalPlan, 0, 2)
+	for _, query := range []*parser.SelectStatement{op.Left, op.Right} {
+		child, err := opt.selectPlan(query, semantic.QueryHasAggregates(query), info)
+		if err != nil {
+			return nil, err
+		}
+		children = append(children, child)
+	}
+
 	plan := &LogicalPlan{
-		Type: PlanTypeSelect,
+		Type:        PlanTypeSetOperation,
+		SetOperator: op.Operator,
+		UnionAll:    op.All,
+		Columns:     columns,
+		Children:    children,
 	}
 
-	// TODO: Build logical plan tree from compiled query
-	// This will be implemented based on the parser AST structure
+	if stmt.OrderBy != nil {
+		sortKeys := make([]SortKey, 0, len(stmt.OrderBy.Orders))
+		for _, order := range stmt.OrderBy.Orders {
+			key := SortKey{
+				Expr:       order.Expression,
+				Column:     columnNameOf(order.Expression),
+				Descending: order.Direction == parser.Descending,
+			}
+
+			// ORDER BY 2 sorts by the second result column
+			if literal, ok := order.Expression.(*parser.Literal); ok {
+				position, err := strconv.Atoi(fmt.Sprintf("%v", literal.Value))
+				if err == nil && position >= 1 && position <= len(columns) {
+					key.Column = columns[position-1]
+					key.Expr = &parser.Identifier{Value: key.Column}
+				}
+			}
+			sortKeys = append(sortKeys, key)
+		}
+		plan = &LogicalPlan{
+			Type:     PlanTypeSort,
+			SortKeys: sortKeys,
+			Children: []*LogicalPlan{plan},
+		}
+	}
+
+	if stmt.Limit != nil {
+		plan = &LogicalPlan{
+			Type:     PlanTypeLimit,
+			Limit:    stmt.Limit,
+			Children: []*LogicalPlan{plan},
+		}
+	}
 
 	return plan, nil
 }
 
+// fromPlan creates the plan reading the data sources of a FROM clause: the
+// scans of its items combined left-deep by joins, in the order the items
+// are written
+func (opt *Optimizer) fromPlan(from *parser.FromClause, info *semantic.SemanticInfo) (*LogicalPlan, error) {
+	var plan *LogicalPlan
+	for _, table := range from.Tables {
+		scan, err := opt.fromItemPlan(table, info)
+		if err != nil {
+			return nil, err
+		}
+		if plan == nil {
+			plan = scan
+		} else {
+			plan = &LogicalPlan{
+				Type:     PlanTypeJoin,
+				JoinType: JoinTypeCross,
+				Children: []*LogicalPlan{plan, scan},
+			}
+		}
+	}
+
+	names := make([]string, 0, len(from.Tables))
+	for _, table := range from.Tables {
+		names = append(names, fromItemName(table))
+	}
+	for _, join := range from.Joins {
+		scan, err := opt.fromItemPlan(join.Table, info)
+		if err != nil {
+			return nil, err
+		}
+		plan = &LogicalPlan{
+			Type:     PlanTypeJoin,
+			JoinType: joinTypeOf(join.JoinType),
+			JoinCond: join.Condition,
+			Children: []*LogicalPlan{plan, scan},
+		}
+
+		// Equalities between the joined item and the items before it can
+		// serve as hash join keys
+		name := fromItemName(join.Table)
+		if join.Condition != nil {
+			plan.JoinKeys = joinKeys(join.Condition, names, []string{name}, info.CompiledQuery.ResolvedRefs)
+		}
+		names = append(names, name)
+	}
+
+	return plan, nil
+}
+
+// fromItemPlan creates the plan reading one FROM item: a table scan, a scan
+// over the rows of a derived table's query, or a scan of a CTE
+func (opt *Optimizer) fromItemPlan(item parser.Expression, info *semantic.SemanticInfo) (*LogicalPlan, error) {
+	if ident, ok := item.(*parser.Identifier); ok {
+		if ref, found := info.CompiledQuery.ResolvedRefs.CTERefs[ident]; found {
+			return opt.cteScanPlan(ident, ref, info)
+		}
+	}
+
+	subquery, ok := item.(*parser.SubqueryExpression)
+	if !ok {
+		scan := &LogicalPlan{
+			Type:      PlanTypeScan,
+			TableName: tableNameOf(item),
+		}
+		if ident, ok := item.(*parser.Identifier); ok && ident.Alias != nil {
+			scan.Alias = ident.Alias.Value
+		}
+		return scan, nil
+	}
+
+	meta := subqueryInfoOf(info, subquery.Query)
+	query, err := opt.selectPlan(subquery.Query, meta != nil && meta.HasAggregates, info)
+	if err != nil {
+		return nil, err
+	}
+
+	alias := ""
+	if subquery.Alias != nil {
+		alias = subquery.Alias.Value
+	}
+	var columns []string
+	for _, col := range subquery.Columns {
+		columns = append(columns, col.Value)
+	}
+	return &LogicalPlan{
+		Type:      PlanTypeSubqueryScan,
+		TableName: alias,
+		Columns:   columns,
+		Children:  []*LogicalPlan{query},
+	}, nil
+}
+
+// cteScanPlan creates the plan reading a CTE referenced in FROM. A CTE
+// that is not materialized is planned in place, like a derived table.
+func (opt *Optimizer) cteScanPlan(ident *parser.Identifier, ref *compiler.CTEReference, info *semantic.SemanticInfo) (*LogicalPlan, error) {
+	name := ident.Value
+	if ident.Alias != nil {
+		name = ident.Alias.Value
+	}
+	columns := cteColumnNames(ref.CTE)
+
+	// A recursive term reads the rows of the previous iteration
+	if ref.SelfReference {
+		return &LogicalPlan{
+			Type:      PlanTypeWorkTableScan,
+			TableName: name,
+			CTE:       ref.CTE,
+			Columns:   columns,
+		}, nil
+	}
+
+	meta := cteInfoOf(info, ref.CTE)
+	if opt.materializeCTE(meta) {
+		return &LogicalPlan{
+			Type:      PlanTypeCTEScan,
+			TableName: name,
+			CTE:       ref.CTE,
+			Columns:   columns,
+		}, nil
+	}
+
+	query, err := opt.selectPlan(ref.CTE.Query, meta != nil && meta.HasAggregates, info)
+	if err != nil {
+		return nil, err
+	}
+	return &LogicalPlan{
+		Type:      PlanTypeSubqueryScan,
+		TableName: name,
+		Columns:   columns,
+		Children:  []*LogicalPlan{query},
+	}, nil
+}
+
+// materializeCTE decides whether a CTE's rows are computed once and kept
+// for all of its references, or its query is planned in place of its only
+// reference, where the optimizations of the enclosing query apply to it.
+// Recursive CTEs are always materialized.
+func (opt *Optimizer) materializeCTE(meta *semantic.CTEMetadata) bool {
+	if meta == nil {
+		return false
+	}
+	return meta.Recursive || meta.References > 1 || !opt.config.EnableCTEInlining
+}
+
+// planCTEs plans the CTEs that are materialized. A recursive CTE's plan is
+// a recursive union of its anchor and recursive terms.
+func (opt *Optimizer) planCTEs(info *semantic.SemanticInfo) (map[*parser.CommonTableExpression]*CTEPlan, error) {
+	ctes := make(map[*parser.CommonTableExpression]*CTEPlan)
+
+	for _, meta := range info.CTEInfo {
+		if meta.References == 0 || !opt.materializeCTE(meta) {
+			continue
+		}
+
+		logical, err := opt.selectPlan(meta.CTE.Query, meta.HasAggregates, info)
+		if err != nil {
+			return nil, err
+		}
+		if meta.Recursive {
+			recursive, err := opt.selectPlan(meta.CTE.RecursiveTerm, false, info)
+			if err != nil {
+				return nil, err
+			}
+			logical = &LogicalPlan{
+				Type:     PlanTypeRecursiveUnion,
+				CTE:      meta.CTE,
+				UnionAll: meta.CTE.UnionAll,
+				Children: []*LogicalPlan{logical, recursive},
+			}
+		}
+
+		best, err := opt.bestPhysicalPlan(logical)
+		if err != nil {
+			return nil, err
+		}
+		ctes[meta.CTE] = &CTEPlan{
+			Root:      best,
+			Recursive: meta.Recursive,
+		}
+	}
+
+	return ctes, nil
+}
+
+// cteInfoOf returns the semantic metadata of a CTE
+func cteInfoOf(info *semantic.SemanticInfo, cte *parser.CommonTableExpression) *semantic.CTEMetadata {
+	for _, meta := range info.CTEInfo {
+		if meta.CTE == cte {
+			return meta
+		}
+	}
+	return nil
+}
+
+// cteColumnNames returns the column names a CTE lists, if any
+func cteColumnNames(cte *parser.CommonTableExpression) []string {
+	if len(cte.Columns) == 0 {
+		return nil
+	}
+	names := make([]string, len(cte.Columns))
+	for i, col := range cte.Columns {
+		names[i] = col.Value
+	}
+	return names
+}
+
+// bestPhysicalPlan optimizes a logical plan planned apart from the main
+// query and returns its cheapest physical plan
+func (opt *Optimizer) bestPhysicalPlan(logical *LogicalPlan) (*PhysicalPlan, error) {
+	logical, err := opt.applyLogicalOptimizations(logical)
+	if err != nil {
+		return nil, err
+	}
+	physicalPlans, err := opt.generatePhysicalPlans(logical)
+	if err != nil {
+		return nil, err
+	}
+	return opt.selectBestPlan(physicalPlans)
+}
+
+// planSubqueries plans the subqueries used in expressions (scalar, IN and
+// EXISTS subqueries). Derived tables are part of the main plan.
+func (opt *Optimizer) planSubqueries(info *semantic.SemanticInfo) (map[*parser.SelectStatement]*SubqueryPlan, error) {
+	subqueries := make(map[*parser.SelectStatement]*SubqueryPlan)
+
+	for _, meta := range info.SubqueryInfo {
+		if meta.Type == semantic.SubqueryDerivedTable {
+			continue
+		}
+
+		logical, err := opt.selectPlan(meta.Query, meta.HasAggregates, info)
+		if err != nil {
+			return nil, err
+		}
+		best, err := opt.bestPhysicalPlan(logical)
+		if err != nil {
+			return nil, err
+		}
+
+		subqueries[meta.Query] = &SubqueryPlan{
+			Root:       best,
+			Correlated: meta.IsCorrelated,
+		}
+	}
+
+	return subqueries, nil
+}
+
+// subqueryInfoOf returns the semantic metadata of a subquery
+func subqueryInfoOf(info *semantic.SemanticInfo, query *parser.SelectStatement) *semantic.SubqueryMetadata {
+	for _, meta := range info.SubqueryInfo {
+		if meta.Query == query {
+			return meta
+		}
+	}
+	return nil
+}
+
 // createInsertPlan creates logical plan for INSERT query
 func (opt *Optimizer) createInsertPlan(compiled *compiler.CompiledQuery) (*LogicalPlan, error) {
 	return &LogicalPlan{
@@ -165,17 +600,70 @@ func (opt *Optimizer) createInsertPlan(compiled *compiler.CompiledQuery) (*Logic
 	}, nil
 }
 
-// createUpdatePlan creates logical plan for UPDATE query
-func (opt *Optimizer) createUpdatePlan(compiled *compiler.CompiledQuery) (*LogicalPlan, error) {
-	return &LogicalPlan{
+// createUpdatePlan creates logical plan for UPDATE query. An UPDATE ... FROM
+// reads the rows it changes from a join of the target and the FROM tables.
+func (opt *Optimizer) createUpdatePlan(compiled *compiler.CompiledQuery, info *semantic.SemanticInfo) (*LogicalPlan, error) {
+	plan := &LogicalPlan{
 		Type: PlanTypeUpdate,
-	}, nil
+	}
+	if stmt, ok := compiled.Statement.(*parser.UpdateStatement); ok && stmt.From != nil {
+		join, err := opt.joinedTablesPlan(stmt.TableName, stmt.From, stmt.WhereClause, info)
+		if err != nil {
+			return nil, err
+		}
+		plan.Children = []*LogicalPlan{join}
+	}
+	return plan, nil
 }
 
-// createDeletePlan creates logical plan for DELETE query
-func (opt *Optimizer) createDeletePlan(compiled *compiler.CompiledQuery) (*LogicalPlan, error) {
-	return &LogicalPlan{
+// createDeletePlan creates logical plan for DELETE query. A DELETE ... USING
+// reads the rows it removes from a join of the target and the USING tables.
+func (opt *Optimizer) createDeletePlan(compiled *compiler.CompiledQuery, info *semantic.SemanticInfo) (*LogicalPlan, error) {
+	plan := &LogicalPlan{
 		Type: PlanTypeDelete,
+	}
+	if stmt, ok := compiled.Statement.(*parser.DeleteStatement); ok && stmt.Using != nil {
+		join, err := opt.joinedTablesPlan(stmt.TableName, stmt.Using, stmt.WhereClause, info)
+		if err != nil {
+			return nil, err
+		}
+		plan.Children = []*LogicalPlan{join}
+	}
+	return plan, nil
+}
+
+// joinedTablesPlan creates the plan joining the target of an UPDATE or
+// DELETE to the tables of its FROM or USING clause. The statement's WHERE
+// condition is the join condition, so its equalities between the target
+// and the other tables can serve as hash join keys.
+func (opt *Optimizer) joinedTablesPlan(target *parser.Identifier, from *parser.FromClause, where *parser.WhereClause, info *semantic.SemanticInfo) (*LogicalPlan, error) {
+	sources, err := opt.fromPlan(from, info)
+	if err != nil {
+		return nil, err
+	}
+
+	join := &LogicalPlan{
+		Type:     PlanTypeJoin,
+		JoinType: JoinTypeCross,
+		Children: []*LogicalPlan{{Type: PlanTypeScan, TableName: target.Value}, sources},
+	}
+	if where != nil && where.Condition != nil {
+		join.JoinType = JoinTypeInner
+		join.JoinCond = where.Condition
+
+		var names []string
+		for _, item := range fromItems(from) {
+			names = append(names, fromItemName(item))
+		}
+		join.JoinKeys = joinKeys(where.Condition, []string{target.Value}, names, info.CompiledQuery.ResolvedRefs)
+	}
+	return join, nil
+}
+
+// createMergePlan creates logical plan for MERGE query
+func (opt *Optimizer) createMergePlan(compiled *compiler.CompiledQuery) (*LogicalPlan, error) {
+	return &LogicalPlan{
+		Type: PlanTypeMerge,
 	}, nil
 }
 
@@ -240,14 +728,146 @@ func (opt *Optimizer) generatePhysicalPlans(logicalPlan *LogicalPlan) ([]*Physic
 
 // logicalToPhysical converts logical plan to physical plan
 func (opt *Optimizer) logicalToPhysical(logical *LogicalPlan) (*PhysicalPlan, error) {
+	children := make([]*PhysicalPlan, 0, len(logical.Children))
+	for _, child := range logical.Children {
+		physicalChild, err := opt.logicalToPhysical(child)
+		if err != nil {
+			return nil, err
+		}
+		children = append(children, physicalChild)
+	}
+
 	physical := &PhysicalPlan{
-		Type: PhysicalPlanType(logical.Type),
+		Type:       PhysicalPlanType(logical.Type),
+		Children:   children,
+		TableName:  logical.TableName,
+		Alias:      logical.Alias,
+		FilterExpr: logical.FilterExpr,
+		JoinType:   logical.JoinType,
+		JoinCond:   logical.JoinCond,
+		CTE:        logical.CTE,
+		UnionAll:   logical.UnionAll,
+		Columns:    logical.Columns,
+
+		SetOperator: logical.SetOperator,
+		GroupBy:     logical.GroupBy,
+		Aggregates:  logical.Aggregates,
+		WindowFuncs: logical.WindowFuncs,
+		Projections: logical.Projections,
+		Limit:       logical.Limit,
 	}
 
 	// TODO: Implement conversion with physical operator selection
 	// - Choose scan method (sequential vs index)
 	// - Choose join algorithm (nested loop, hash join, merge join)
 	// - Choose aggregation method (hash, sort)
+	switch logical.Type {
+	case PlanTypeScan:
+		physical.Type = PhysicalPlanTypeSeqScan
+		if table, err := opt.catalog.GetTable(logical.TableName); err == nil {
+			if table.Clustered {
+				physical.Type = PhysicalPlanTypeClusteredIndexScan
+				physical.Ordering = table.ClusterKey()
+			}
+			if scheme := table.Partitioning; scheme != nil {
+				physical.Partitioned = true
+				physical.Partitions = scheme.PartitionNames()
+
+				// Partitions are read one after another, which keeps key order
+				// only when they are ranges over the leading key column
+				if len(physical.Ordering) > 0 && (scheme.Method != parser.PartitionByRange || scheme.Column != physical.Ordering[0]) {
+					physical.Ordering = nil
+				}
+			}
+		}
+
+	case PlanTypeFilter:
+		physical.Type = PhysicalPlanTypeFilter
+		physical.Ordering = children[0].Ordering
+
+		// Primary key predicates on a clustered scan become the scan's key range
+		if scan := children[0]; scan.Type == PhysicalPlanTypeClusteredIndexScan && len(scan.Ordering) > 0 {
+			scan.KeyRange = extractKeyRange(logical.FilterExpr, scan.Ordering[0])
+			scan.Cost = opt.costModel.EstimateCost(scan)
+		}
+
+		// Predicates on the partition key prune partitions from the scan
+		if scan := children[0]; scan.Partitioned {
+			if table, err := opt.catalog.GetTable(scan.TableName); err == nil && table.Partitioning != nil {
+				scan.Partitions = prunePartitions(table.Partitioning, logical.FilterExpr)
+			}
+		}
+
+	case PlanTypeJoin:
+		physical.Type = PhysicalPlanTypeNestedLoopJoin
+
+		// With equality keys a hash join reads each input once instead of
+		// rescanning the right input for every left row
+		if len(logical.JoinKeys) > 0 && logical.JoinType == JoinTypeInner {
+			physical.JoinKeys = logical.JoinKeys
+			hash := *physical
+			hash.Type = PhysicalPlanTypeHashJoin
+			if opt.costModel.EstimateCost(&hash) < opt.costModel.EstimateCost(physical) {
+				physical.Type = PhysicalPlanTypeHashJoin
+			}
+		}
+
+	case PlanTypeAggregate:
+		physical.Type = PhysicalPlanTypeHashAggregate
+
+	case PlanTypeSort:
+		// Rows already arriving in the requested order need no sort
+		if sortSatisfied(logical.SortKeys, children[0].Ordering) {
+			return children[0], nil
+		}
+
+		// A sort-based set operation returns its rows ordered by all of its
+		// columns, which spares the sort when the keys are a prefix of them
+		if setOp := children[0]; setOp.Type == PhysicalPlanTypeHashSetOp && sortSatisfied(logical.SortKeys, setOp.Columns) {
+			setOp.Type = PhysicalPlanTypeSortSetOp
+			setOp.Ordering = setOp.Columns
+			setOp.Cost = opt.costModel.EstimateCost(setOp)
+			return setOp, nil
+		}
+		physical.Type = PhysicalPlanTypeSort
+		physical.SortKeys = logical.SortKeys
+		physical.Ordering = sortOrdering(logical.SortKeys)
+
+	case PlanTypeProject:
+		physical.Type = PhysicalPlanTypeProject
+		physical.Ordering = children[0].Ordering
+
+	case PlanTypeWindow:
+		// Rows leave the window operator in the order they arrived
+		physical.Type = PhysicalPlanTypeWindow
+		physical.Ordering = children[0].Ordering
+
+	case PlanTypeLimit:
+		physical.Type = PhysicalPlanTypeLimit
+		physical.Ordering = children[0].Ordering
+
+	case PlanTypeSubqueryScan:
+		physical.Type = PhysicalPlanTypeSubqueryScan
+
+	case PlanTypeCTEScan:
+		physical.Type = PhysicalPlanTypeCTEScan
+
+	case PlanTypeWorkTableScan:
+		physical.Type = PhysicalPlanTypeWorkTableScan
+
+	case PlanTypeRecursiveUnion:
+		physical.Type = PhysicalPlanTypeRecursiveUnion
+
+	case PlanTypeSingleRow:
+		physical.Type = PhysicalPlanTypeSingleRow
+
+	case PlanTypeSetOperation:
+		// UNION ALL keeps every row of both inputs, so it needs no hashing
+		physical.Type = PhysicalPlanTypeHashSetOp
+		if logical.SetOperator == parser.Union && logical.UnionAll {
+			physical.Type = PhysicalPlanTypeAppend
+		}
+	}
 
 	// Estimate cost and cardinality
 	physical.Cost = opt.costModel.EstimateCost(physical)
@@ -256,6 +876,277 @@ func (opt *Optimizer) logicalToPhysical(logical *LogicalPlan) (*PhysicalPlan, er
 	return physical, nil
 }
 
+// sortSatisfied reports whether input ordered by ordering already satisfies
+// the sort keys, i.e. the keys are an ascending prefix of the ordering
+func sortSatisfied(keys []SortKey, ordering []string) bool {
+	if len(keys) == 0 || len(keys) > len(ordering) {
+		return false
+	}
+	for i, key := range keys {
+		if key.Descending || key.Column == "" || key.Column != ordering[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// sortOrdering returns the ordering produced by sorting on keys
+func sortOrdering(keys []SortKey) []string {
+	var ordering []string
+	for _, key := range keys {
+		if key.Descending || key.Column == "" {
+			break
+		}
+		ordering = append(ordering, key.Column)
+	}
+	return ordering
+}
+
+// extractKeyRange derives a key range on column from the conjuncts of a
+// filter predicate. Returns nil if no conjunct bounds the column.
+func extractKeyRange(expr interface{}, column string) *KeyRange {
+	var keyRange *KeyRange
+
+	for _, conjunct := range conjuncts(expr) {
+		binary, ok := conjunct.(*parser.BinaryExpression)
+		if !ok {
+			continue
+		}
+
+		op := binary.Operator
+		literal, ok := binary.Right.(*parser.Literal)
+		if !ok || columnNameOf(binary.Left) != column {
+			// Accept the mirrored form: literal op column
+			literal, ok = binary.Left.(*parser.Literal)
+			if !ok || columnNameOf(binary.Right) != column {
+				continue
+			}
+			op = mirrorOperator(op)
+		}
+
+		value := literalValue(literal)
+		if keyRange == nil {
+			keyRange = &KeyRange{}
+		}
+
+		switch op {
+		case parser.Equal:
+			keyRange.Lower, keyRange.LowerInclusive = value, true
+			keyRange.Upper, keyRange.UpperInclusive = value, true
+		case parser.GreaterThan:
+			keyRange.Lower, keyRange.LowerInclusive = value, false
+		case parser.GreaterEqual:
+			keyRange.Lower, keyRange.LowerInclusive = value, true
+		case parser.LessThan:
+			keyRange.Upper, keyRange.UpperInclusive = value, false
+		case parser.LessEqual:
+			keyRange.Upper, keyRange.UpperInclusive = value, true
+		}
+	}
+
+	if keyRange != nil && keyRange.Lower == nil && keyRange.Upper == nil {
+		return nil
+	}
+	return keyRange
+}
+
+// prunePartitions returns the partitions that may hold rows matching a
+// filter predicate, in scan order
+func prunePartitions(scheme *compiler.PartitionScheme, expr interface{}) []string {
+	keyRange := extractKeyRange(expr, scheme.Column)
+	if keyRange == nil {
+		return scheme.PartitionNames()
+	}
+	return scheme.Prune(keyRange.Lower, keyRange.Upper, keyRange.LowerInclusive, keyRange.UpperInclusive)
+}
+
+// conjuncts splits a predicate on AND
+func conjuncts(expr interface{}) []interface{} {
+	if binary, ok := expr.(*parser.BinaryExpression); ok && binary.Operator == parser.And {
+		return append(conjuncts(binary.Left), conjuncts(binary.Right)...)
+	}
+	return []interface{}{expr}
+}
+
+// mirrorOperator returns the operator for swapped operands (a < b == b > a)
+func mirrorOperator(op parser.BinaryOperator) parser.BinaryOperator {
+	switch op {
+	case parser.LessThan:
+		return parser.GreaterThan
+	case parser.GreaterThan:
+		return parser.LessThan
+	case parser.LessEqual:
+		return parser.GreaterEqual
+	case parser.GreaterEqual:
+		return parser.LessEqual
+	default:
+		return op
+	}
+}
+
+// literalValue converts a parser literal to a comparable Go value
+func literalValue(lit *parser.Literal) interface{} {
+	if lit.Type == lexer.NUMBER {
+		text := fmt.Sprintf("%v", lit.Value)
+		if i, err := strconv.ParseInt(text, 10, 64); err == nil {
+			return i
+		}
+		if f, err := strconv.ParseFloat(text, 64); err == nil {
+			return f
+		}
+	}
+	return lit.Value
+}
+
+// selectedExpression returns the select list entry an ORDER BY or GROUP BY
+// key refers to, by its position as in ORDER BY 2 or by its alias, or the
+// key itself
+func selectedExpression(key parser.Expression, columns []parser.Expression) parser.Expression {
+	switch k := key.(type) {
+	case *parser.Literal:
+		position, err := strconv.Atoi(fmt.Sprintf("%v", k.Value))
+		if err == nil && position >= 1 && position <= len(columns) {
+			if _, wildcard := columns[position-1].(*parser.Wildcard); !wildcard {
+				return columns[position-1]
+			}
+		}
+	case *parser.Identifier:
+		for _, col := range columns {
+			if ident, ok := col.(*parser.Identifier); ok && ident.Alias != nil && ident.Alias.Value == k.Value {
+				return col
+			}
+			if ref, ok := col.(*parser.ColumnReference); ok && ref.Column.Alias != nil && ref.Column.Alias.Value == k.Value {
+				return col
+			}
+			if sub, ok := col.(*parser.SubqueryExpression); ok && sub.Alias != nil && sub.Alias.Value == k.Value {
+				return col
+			}
+		}
+	}
+	return key
+}
+
+// tableNameOf returns the table name referenced by a FROM item
+func tableNameOf(expr parser.Expression) string {
+	if ident, ok := expr.(*parser.Identifier); ok {
+		return ident.Value
+	}
+	return expr.String()
+}
+
+// columnNameOf returns the unqualified column name of a column expression,
+// or "" if the expression is not a plain column reference
+func columnNameOf(expr interface{}) string {
+	switch e := expr.(type) {
+	case *parser.Identifier:
+		if idx := strings.LastIndex(e.Value, "."); idx >= 0 {
+			return e.Value[idx+1:]
+		}
+		return e.Value
+	case *parser.ColumnReference:
+		return e.Column.Value
+	default:
+		return ""
+	}
+}
+
+// fromItems returns the items of a FROM clause in the order they are
+// written, the joined ones last
+func fromItems(from *parser.FromClause) []parser.Expression {
+	items := append([]parser.Expression{}, from.Tables...)
+	for _, join := range from.Joins {
+		items = append(items, join.Table)
+	}
+	return items
+}
+
+// fromItemName returns the name a FROM item's columns are qualified with
+func fromItemName(item parser.Expression) string {
+	switch e := item.(type) {
+	case *parser.Identifier:
+		if e.Alias != nil {
+			return e.Alias.Value
+		}
+		return e.Value
+	case *parser.SubqueryExpression:
+		if e.Alias != nil {
+			return e.Alias.Value
+		}
+	}
+	return ""
+}
+
+// joinKeys returns the equalities of a join condition that compare a column
+// of the tables named left with a column of the tables named right, with
+// the left column first
+func joinKeys(cond parser.Expression, left, right []string, refs *compiler.ResolvedReferences) []JoinKey {
+	var keys []JoinKey
+	for _, conjunct := range conjuncts(cond) {
+		binary, ok := conjunct.(*parser.BinaryExpression)
+		if !ok || binary.Operator != parser.Equal {
+			continue
+		}
+
+		switch {
+		case columnOfTables(binary.Left, left, right, refs) && columnOfTables(binary.Right, right, left, refs):
+			keys = append(keys, JoinKey{Left: binary.Left, Right: binary.Right})
+		case columnOfTables(binary.Right, left, right, refs) && columnOfTables(binary.Left, right, left, refs):
+			keys = append(keys, JoinKey{Left: binary.Right, Right: binary.Left})
+		}
+	}
+	return keys
+}
+
+// columnOfTables reports whether expr is a column of one of the tables
+// named in tables. An unqualified column must not also be a column of the
+// tables named in others.
+func columnOfTables(expr parser.Expression, tables, others []string, refs *compiler.ResolvedReferences) bool {
+	var table, column string
+	switch e := expr.(type) {
+	case *parser.ColumnReference:
+		if e.Table != nil {
+			table = e.Table.Value
+		}
+		column = e.Column.Value
+	case *parser.Identifier:
+		column = e.Value
+		if idx := strings.LastIndex(column, "."); idx >= 0 {
+			table, column = column[:idx], column[idx+1:]
+		}
+	default:
+		return false
+	}
+
+	has := func(names []string) bool {
+		for _, name := range names {
+			if table != "" {
+				if name == table {
+					return true
+				}
+				continue
+			}
+			if meta, found := refs.Tables[name]; found && meta.HasColumn(column) {
+				return true
+			}
+		}
+		return false
+	}
+	return has(tables) && (table != "" || !has(others))
+}
+
+func joinTypeOf(joinType parser.JoinType) JoinType {
+	switch joinType {
+	case parser.LeftJoin:
+		return JoinTypeLeft
+	case parser.RightJoin:
+		return JoinTypeRight
+	case parser.FullJoin:
+		return JoinTypeFull
+	default:
+		return JoinTypeInner
+	}
+}
+
 // selectBestPlan selects the plan with lowest estimated cost
 func (opt *Optimizer) selectBestPlan(plans []*PhysicalPlan) (*PhysicalPlan, error) {
 	if len(plans) == 0 {
@@ -285,12 +1176,31 @@ func (opt *Optimizer) estimateCardinality(plan *PhysicalPlan) int64 {
 // QueryPlan represents the final optimized query plan
 type QueryPlan struct {
 	Root             *PhysicalPlan
+	Subqueries       map[*parser.SelectStatement]*SubqueryPlan
+	CTEs             map[*parser.CommonTableExpression]*CTEPlan
 	EstimatedCost    float64
 	EstimatedRows    int64
 	OptimizationTime time.Duration
 	Statistics       map[string]interface{}
 }
 
+// SubqueryPlan is the plan of a subquery that an expression of the query
+// evaluates
+type SubqueryPlan struct {
+	Root *PhysicalPlan
+
+	// Correlated subqueries refer to columns of the enclosing query and
+	// are evaluated again for each of its rows; others are evaluated once
+	Correlated bool
+}
+
+// CTEPlan is the plan of a materialized common table expression, whose
+// rows are computed on first use and shared by all of its references
+type CTEPlan struct {
+	Root      *PhysicalPlan
+	Recursive bool
+}
+
 // String returns a string representation of the query plan
 func (qp *QueryPlan) String() string {
 	return fmt.Sprintf("QueryPlan{Cost: %.2f, Rows: %d, Time: %v}",
@@ -1,10 +1,14 @@
 package optimizer
 
 import (
+	"strings"
 	"testing"
 	"time"
 
 	"relational-db/internal/compiler"
+	"relational-db/internal/lexer"
+	"relational-db/internal/parser"
+	"relational-db/internal/semantic"
 )
 
 // TestNewOptimizer tests creating an optimizer
@@ -458,3 +462,284 @@ func TestQueryPlan(t *testing.T) {
 		t.Error("Expected non-empty string representation")
 	}
 }
+
+// TestClusteredScanSkipsSort tests that ORDER BY on the cluster key needs no sort
+func TestClusteredScanSkipsSort(t *testing.T) {
+	catalog := compiler.NewMockCatalog()
+	table := compiler.NewTableMetadata("events")
+	table.AddColumn(compiler.NewColumnMetadata("id", compiler.DataTypeInteger))
+	table.AddColumn(compiler.NewColumnMetadata("name", compiler.DataTypeText))
+	table.PrimaryKey = []string{"id"}
+	table.Clustered = true
+	catalog.AddTable(table)
+
+	opt := NewOptimizer(catalog, NewMockStatisticsManager())
+
+	plan, err := opt.logicalToPhysical(&LogicalPlan{
+		Type:     PlanTypeSort,
+		SortKeys: []SortKey{{Column: "id"}},
+		Children: []*LogicalPlan{{
+			Type: PlanTypeFilter,
+			FilterExpr: &parser.BinaryExpression{
+				Left:     &parser.Identifier{Value: "id"},
+				Operator: parser.GreaterEqual,
+				Right:    &parser.Literal{Value: "10", Type: lexer.NUMBER},
+			},
+			Children: []*LogicalPlan{{Type: PlanTypeScan, TableName: "events"}},
+		}},
+	})
+	if err != nil {
+		t.Fatalf("logicalToPhysical failed: %v", err)
+	}
+
+	if plan.Type != PhysicalPlanTypeFilter {
+		t.Fatalf("Expected sort to be eliminated, got root %s", plan.Type)
+	}
+
+	scan := plan.Children[0]
+	if scan.Type != PhysicalPlanTypeClusteredIndexScan {
+		t.Fatalf("Expected ClusteredIndexScan, got %s", scan.Type)
+	}
+	if scan.KeyRange == nil || scan.KeyRange.Lower != int64(10) || !scan.KeyRange.LowerInclusive {
+		t.Errorf("Expected key range [10, +inf), got %v", scan.KeyRange)
+	}
+
+	// Ordering on a non-key column still needs a sort
+	plan, err = opt.logicalToPhysical(&LogicalPlan{
+		Type:     PlanTypeSort,
+		SortKeys: []SortKey{{Column: "name"}},
+		Children: []*LogicalPlan{{Type: PlanTypeScan, TableName: "events"}},
+	})
+	if err != nil {
+		t.Fatalf("logicalToPhysical failed: %v", err)
+	}
+	if plan.Type != PhysicalPlanTypeSort {
+		t.Errorf("Expected Sort, got %s", plan.Type)
+	}
+}
+
+func TestPartitionPruning(t *testing.T) {
+	catalog := compiler.NewMockCatalog()
+	table := compiler.NewTableMetadata("events")
+	table.AddColumn(compiler.NewColumnMetadata("id", compiler.DataTypeInteger))
+	table.AddColumn(compiler.NewColumnMetadata("day", compiler.DataTypeText))
+	table.Partitioning = &compiler.PartitionScheme{
+		Method: parser.PartitionByRange,
+		Column: "day",
+		Partitions: []*compiler.PartitionBound{
+			{Name: "events_2023", LessThan: "2024-01-01"},
+			{Name: "events_2024", LessThan: "2025-01-01"},
+			{Name: "events_max"},
+		},
+	}
+	catalog.AddTable(table)
+
+	opt := NewOptimizer(catalog, NewMockStatisticsManager())
+
+	plan, err := opt.logicalToPhysical(&LogicalPlan{
+		Type: PlanTypeFilter,
+		FilterExpr: &parser.BinaryExpression{
+			Left:     &parser.Identifier{Value: "day"},
+			Operator: parser.GreaterEqual,
+			Right:    &parser.Literal{Value: "2024-03-01", Type: lexer.STRING},
+		},
+		Children: []*LogicalPlan{{Type: PlanTypeScan, TableName: "events"}},
+	})
+	if err != nil {
+		t.Fatalf("logicalToPhysical failed: %v", err)
+	}
+
+	scan := plan.Children[0]
+	if !scan.Partitioned || len(scan.Partitions) != 2 || scan.Partitions[0] != "events_2024" || scan.Partitions[1] != "events_max" {
+		t.Errorf("Expected partitions [events_2024 events_max], got %v", scan.Partitions)
+	}
+
+	explain := (&QueryPlan{Root: plan}).Explain()
+	if !strings.Contains(explain, "partitions: events_2024, events_max") {
+		t.Errorf("Expected pruned partitions in EXPLAIN output, got:\n%s", explain)
+	}
+
+	// Without a predicate on the partition key every partition is scanned
+	plan, err = opt.logicalToPhysical(&LogicalPlan{Type: PlanTypeScan, TableName: "events"})
+	if err != nil {
+		t.Fatalf("logicalToPhysical failed: %v", err)
+	}
+	if len(plan.Partitions) != 3 {
+		t.Errorf("Expected all 3 partitions, got %v", plan.Partitions)
+	}
+}
+
+func TestCTEPlanning(t *testing.T) {
+	catalog := compiler.NewMockCatalog()
+	table := compiler.NewTableMetadata("categories")
+	table.AddColumn(compiler.NewColumnMetadata("id", compiler.DataTypeInteger))
+	table.AddColumn(compiler.NewColumnMetadata("parent_id", compiler.DataTypeInteger))
+	catalog.AddTable(table)
+
+	optimize := func(opt *Optimizer, sql string) *QueryPlan {
+		stmt, err := parser.ParseSQL(sql)
+		if err != nil {
+			t.Fatalf("%s: parse failed: %v", sql, err)
+		}
+		compiled, err := compiler.NewQueryCompiler(catalog).Compile(stmt)
+		if err != nil {
+			t.Fatalf("%s: compile failed: %v", sql, err)
+		}
+		info, err := semantic.NewSemanticAnalyzer(catalog).Analyze(compiled)
+		if err != nil {
+			t.Fatalf("%s: analyze failed: %v", sql, err)
+		}
+		plan, err := opt.Optimize(info)
+		if err != nil {
+			t.Fatalf("%s: optimize failed: %v", sql, err)
+		}
+		return plan
+	}
+	opt := NewOptimizer(catalog, NewMockStatisticsManager())
+
+	// A CTE referenced once is planned in place
+	plan := optimize(opt, "WITH roots AS (SELECT id FROM categories WHERE parent_id = 0) SELECT id FROM roots")
+	if len(plan.CTEs) != 0 || !strings.Contains(plan.Explain(), "SubqueryScan") {
+		t.Errorf("Expected inlined CTE, got:\n%s", plan.Explain())
+	}
+
+	// Referenced twice, it is materialized once
+	plan = optimize(opt, "WITH roots AS (SELECT id FROM categories WHERE parent_id = 0) SELECT a.id FROM roots a JOIN roots b ON a.id = b.id")
+	if len(plan.CTEs) != 1 || strings.Count(plan.Explain(), "CTEScan") != 2 {
+		t.Errorf("Expected one materialized CTE scanned twice, got:\n%s", plan.Explain())
+	}
+
+	// Inlining can be turned off
+	config := DefaultOptimizerConfig()
+	config.EnableCTEInlining = false
+	plan = optimize(NewOptimizerWithConfig(catalog, NewMockStatisticsManager(), config),
+		"WITH roots AS (SELECT id FROM categories WHERE parent_id = 0) SELECT id FROM roots")
+	if len(plan.CTEs) != 1 {
+		t.Errorf("Expected materialized CTE with inlining disabled, got %d CTE plans", len(plan.CTEs))
+	}
+
+	// Recursive CTEs are materialized as a recursive union
+	plan = optimize(opt, `WITH RECURSIVE tree AS (
+		SELECT id, parent_id FROM categories WHERE parent_id = 0
+		UNION ALL
+		SELECT c.id, c.parent_id FROM categories c JOIN tree t ON c.parent_id = t.id)
+		SELECT id FROM tree`)
+	if len(plan.CTEs) != 1 {
+		t.Fatalf("Expected one CTE plan, got %d", len(plan.CTEs))
+	}
+	for _, cte := range plan.CTEs {
+		if !cte.Recursive || cte.Root.Type != PhysicalPlanTypeRecursiveUnion || !cte.Root.UnionAll {
+			t.Errorf("Expected recursive union, got:\n%s", cte.Root)
+		}
+		if !strings.Contains(cte.Root.String(), "WorkTableScan") {
+			t.Errorf("Expected recursive term to scan the work table, got:\n%s", cte.Root)
+		}
+	}
+}
+
+func TestSetOperationPlanning(t *testing.T) {
+	catalog := compiler.NewMockCatalog()
+	for _, name := range []string{"customers", "suppliers"} {
+		table := compiler.NewTableMetadata(name)
+		table.AddColumn(compiler.NewColumnMetadata("city", compiler.DataTypeText))
+		table.AddColumn(compiler.NewColumnMetadata("country", compiler.DataTypeText))
+		catalog.AddTable(table)
+	}
+
+	optimize := func(sql string) *QueryPlan {
+		stmt, err := parser.ParseSQL(sql)
+		if err != nil {
+			t.Fatalf("%s: parse failed: %v", sql, err)
+		}
+		compiled, err := compiler.NewQueryCompiler(catalog).Compile(stmt)
+		if err != nil {
+			t.Fatalf("%s: compile failed: %v", sql, err)
+		}
+		info, err := semantic.NewSemanticAnalyzer(catalog).Analyze(compiled)
+		if err != nil {
+			t.Fatalf("%s: analyze failed: %v", sql, err)
+		}
+		plan, err := NewOptimizer(catalog, NewMockStatisticsManager()).Optimize(info)
+		if err != nil {
+			t.Fatalf("%s: optimize failed: %v", sql, err)
+		}
+		return plan
+	}
+
+	// UNION ALL concatenates its inputs
+	plan := optimize("SELECT city FROM customers UNION ALL SELECT city FROM suppliers")
+	if plan.Root.Type != PhysicalPlanTypeAppend || len(plan.Root.Children) != 2 {
+		t.Errorf("Expected Append, got:\n%s", plan.Explain())
+	}
+
+	// Other set operations hash their rows
+	plan = optimize("SELECT city FROM customers INTERSECT SELECT city FROM suppliers")
+	if plan.Root.Type != PhysicalPlanTypeHashSetOp || plan.Root.SetOperator != parser.Intersect || plan.Root.UnionAll {
+		t.Errorf("Expected HashSetOp INTERSECT, got:\n%s", plan.Explain())
+	}
+
+	// Sorting on the leading result columns uses a sort-based set operation
+	// instead of a hash and a sort
+	plan = optimize("SELECT city, country FROM customers EXCEPT SELECT city, country FROM suppliers ORDER BY 1")
+	if plan.Root.Type != PhysicalPlanTypeSortSetOp || !strings.Contains(plan.Explain(), "EXCEPT") {
+		t.Errorf("Expected SortSetOp, got:\n%s", plan.Explain())
+	}
+
+	// Descending order still needs a sort
+	plan = optimize("SELECT city FROM customers UNION SELECT city FROM suppliers ORDER BY city DESC")
+	if plan.Root.Type != PhysicalPlanTypeSort || plan.Root.Children[0].Type != PhysicalPlanTypeHashSetOp {
+		t.Errorf("Expected Sort over HashSetOp, got:\n%s", plan.Explain())
+	}
+}
+
+func TestWindowPlanning(t *testing.T) {
+	catalog := compiler.NewMockCatalog()
+	table := compiler.NewTableMetadata("sales")
+	table.AddColumn(compiler.NewColumnMetadata("region", compiler.DataTypeText))
+	table.AddColumn(compiler.NewColumnMetadata("amount", compiler.DataTypeInteger))
+	catalog.AddTable(table)
+
+	stmt, err := parser.ParseSQL("SELECT region, SUM(amount) OVER (PARTITION BY region), RANK() OVER (ORDER BY amount DESC) FROM sales ORDER BY region")
+	if err != nil {
+		t.Fatalf("parse failed: %v", err)
+	}
+	compiled, err := compiler.NewQueryCompiler(catalog).Compile(stmt)
+	if err != nil {
+		t.Fatalf("compile failed: %v", err)
+	}
+	info, err := semantic.NewSemanticAnalyzer(catalog).Analyze(compiled)
+	if err != nil {
+		t.Fatalf("analyze failed: %v", err)
+	}
+	plan, err := NewOptimizer(catalog, NewMockStatisticsManager()).Optimize(info)
+	if err != nil {
+		t.Fatalf("optimize failed: %v", err)
+	}
+
+	// Window functions are computed below the sort, and SUM with OVER is
+	// not a grouped aggregate
+	var window *PhysicalPlan
+	var walk func(node *PhysicalPlan)
+	walk = func(node *PhysicalPlan) {
+		switch node.Type {
+		case PhysicalPlanTypeWindow:
+			window = node
+		case PhysicalPlanTypeHashAggregate, PhysicalPlanTypeSortAggregate:
+			t.Errorf("Unexpected aggregate in plan:\n%s", plan.Explain())
+		}
+		for _, child := range node.Children {
+			walk(child)
+		}
+	}
+	walk(plan.Root)
+
+	if window == nil {
+		t.Fatalf("Expected Window node, got:\n%s", plan.Explain())
+	}
+	if len(window.WindowFuncs) != 2 || len(WindowSpecs(window.WindowFuncs)) != 2 {
+		t.Errorf("Expected 2 functions over 2 windows, got %v", window.WindowFuncs)
+	}
+	if !strings.Contains(plan.Explain(), "Window") {
+		t.Errorf("Expected Window in explain output, got:\n%s", plan.Explain())
+	}
+}
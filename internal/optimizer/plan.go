@@ -3,6 +3,8 @@ package optimizer
 import (
 	"fmt"
 	"strings"
+
+	"relational-db/internal/parser"
 )
 
 // PlanType represents the type of logical plan node
@@ -21,6 +23,14 @@ const (
 	PlanTypeSort
 	PlanTypeProject
 	PlanTypeLimit
+	PlanTypeSubqueryScan
+	PlanTypeCTEScan
+	PlanTypeWorkTableScan
+	PlanTypeRecursiveUnion
+	PlanTypeSetOperation
+	PlanTypeWindow
+	PlanTypeMerge
+	PlanTypeSingleRow
 )
 
 func (pt PlanType) String() string {
@@ -47,6 +57,22 @@ func (pt PlanType) String() string {
 		return "PROJECT"
 	case PlanTypeLimit:
 		return "LIMIT"
+	case PlanTypeSubqueryScan:
+		return "SUBQUERY SCAN"
+	case PlanTypeCTEScan:
+		return "CTE SCAN"
+	case PlanTypeWorkTableScan:
+		return "WORK TABLE SCAN"
+	case PlanTypeRecursiveUnion:
+		return "RECURSIVE UNION"
+	case PlanTypeSetOperation:
+		return "SET OPERATION"
+	case PlanTypeWindow:
+		return "WINDOW"
+	case PlanTypeMerge:
+		return "MERGE"
+	case PlanTypeSingleRow:
+		return "SINGLE ROW"
 	default:
 		return "UNKNOWN"
 	}
@@ -60,10 +86,32 @@ type LogicalPlan struct {
 	Children []*LogicalPlan
 
 	// Plan-specific data
-	TableName  string      // For scan nodes
+	TableName  string      // For scan nodes; the alias of a subquery scan
+	Alias      string      // For scan nodes: the name the query gives the table, if any
 	FilterExpr interface{} // For filter nodes
 	JoinType   JoinType    // For join nodes
 	JoinCond   interface{} // For join nodes
+	JoinKeys   []JoinKey   // For join nodes: equalities in JoinCond a hash join can use
+	SortKeys   []SortKey   // For sort nodes
+
+	// For CTE, work table and recursive union nodes: the common table
+	// expression read or computed
+	CTE      *parser.CommonTableExpression
+	UnionAll bool     // For recursive union and set operation nodes: keep duplicates
+	Columns  []string // For subquery and CTE scans: column names the CTE lists; for set operations: result column names
+
+	SetOperator parser.SetOperator // For set operation nodes
+
+	// For aggregate nodes: the GROUP BY expressions and the aggregate calls
+	// computed per group
+	GroupBy    []parser.Expression
+	Aggregates []*parser.FunctionCall
+
+	WindowFuncs []*parser.FunctionCall // For window nodes: the window function calls computed
+
+	Projections []parser.Expression // For project nodes: the select list
+
+	Limit *parser.LimitClause // For limit nodes: the row count and offset
 
 	// Estimated properties
 	Cardinality int64
@@ -83,6 +131,10 @@ func (lp *LogicalPlan) toString(indent int) string {
 		result += fmt.Sprintf("(%s)", lp.TableName)
 	}
 
+	if lp.Type == PlanTypeSetOperation {
+		result += fmt.Sprintf("(%s)", setOperationName(lp.SetOperator, lp.UnionAll))
+	}
+
 	if len(lp.Children) > 0 {
 		for _, child := range lp.Children {
 			result += "\n" + child.toString(indent+1)
@@ -92,6 +144,14 @@ func (lp *LogicalPlan) toString(indent int) string {
 	return result
 }
 
+// JoinKey is an equality in a join condition between an expression over
+// the join's left input and one over its right input. A hash join finds
+// the rows that can match by the values of these expressions.
+type JoinKey struct {
+	Left  parser.Expression
+	Right parser.Expression
+}
+
 // JoinType represents the type of join operation
 type JoinType int
 
@@ -136,6 +196,16 @@ const (
 	PhysicalPlanTypeSort
 	PhysicalPlanTypeProject
 	PhysicalPlanTypeLimit
+	PhysicalPlanTypeClusteredIndexScan
+	PhysicalPlanTypeSubqueryScan
+	PhysicalPlanTypeCTEScan
+	PhysicalPlanTypeWorkTableScan
+	PhysicalPlanTypeRecursiveUnion
+	PhysicalPlanTypeAppend
+	PhysicalPlanTypeHashSetOp
+	PhysicalPlanTypeSortSetOp
+	PhysicalPlanTypeWindow
+	PhysicalPlanTypeSingleRow
 )
 
 func (ppt PhysicalPlanType) String() string {
@@ -162,6 +232,26 @@ func (ppt PhysicalPlanType) String() string {
 		return "Project"
 	case PhysicalPlanTypeLimit:
 		return "Limit"
+	case PhysicalPlanTypeClusteredIndexScan:
+		return "ClusteredIndexScan"
+	case PhysicalPlanTypeSubqueryScan:
+		return "SubqueryScan"
+	case PhysicalPlanTypeCTEScan:
+		return "CTEScan"
+	case PhysicalPlanTypeWorkTableScan:
+		return "WorkTableScan"
+	case PhysicalPlanTypeRecursiveUnion:
+		return "RecursiveUnion"
+	case PhysicalPlanTypeAppend:
+		return "Append"
+	case PhysicalPlanTypeHashSetOp:
+		return "HashSetOp"
+	case PhysicalPlanTypeSortSetOp:
+		return "SortSetOp"
+	case PhysicalPlanTypeWindow:
+		return "Window"
+	case PhysicalPlanTypeSingleRow:
+		return "SingleRow"
 	default:
 		return "Unknown"
 	}
@@ -176,10 +266,38 @@ type PhysicalPlan struct {
 
 	// Plan-specific data
 	TableName  string      // For scan nodes
+	Alias      string      // For scan nodes
 	IndexName  string      // For index scan nodes
 	FilterExpr interface{} // For filter nodes
 	JoinType   JoinType    // For join nodes
 	JoinCond   interface{} // For join nodes
+	JoinKeys   []JoinKey   // For join nodes
+	SortKeys   []SortKey   // For sort nodes
+	KeyRange   *KeyRange   // For clustered index scan nodes
+
+	// For scans of partitioned tables: the partitions left after pruning
+	Partitioned bool
+	Partitions  []string
+
+	// For CTE, work table, recursive union and set operation nodes
+	CTE      *parser.CommonTableExpression
+	UnionAll bool
+	Columns  []string
+
+	SetOperator parser.SetOperator // For set operation nodes
+
+	// For aggregate nodes
+	GroupBy    []parser.Expression
+	Aggregates []*parser.FunctionCall
+
+	WindowFuncs []*parser.FunctionCall // For window nodes
+
+	Projections []parser.Expression // For project nodes
+
+	Limit *parser.LimitClause // For limit nodes
+
+	// Physical properties
+	Ordering []string // Columns the output is sorted by (ascending)
 
 	// Cost estimates
 	Cost        float64 // Total estimated cost
@@ -210,6 +328,39 @@ func (pp *PhysicalPlan) toString(indent int) string {
 		result += fmt.Sprintf(" using %s", pp.IndexName)
 	}
 
+	if pp.Type == PhysicalPlanTypeHashSetOp || pp.Type == PhysicalPlanTypeSortSetOp {
+		result += " " + setOperationName(pp.SetOperator, pp.UnionAll)
+	}
+
+	if pp.KeyRange != nil {
+		result += fmt.Sprintf(" range %s", pp.KeyRange)
+	}
+
+	if pp.Partitioned {
+		if len(pp.Partitions) == 0 {
+			result += " partitions: none"
+		} else {
+			result += fmt.Sprintf(" partitions: %s", strings.Join(pp.Partitions, ", "))
+		}
+	}
+
+	if pp.Type == PhysicalPlanTypeWindow {
+		specs := WindowSpecs(pp.WindowFuncs)
+		windows := make([]string, len(specs))
+		for i, spec := range specs {
+			windows[i] = spec.String()
+		}
+		result += fmt.Sprintf(" over %s", strings.Join(windows, ", "))
+	}
+
+	if len(pp.SortKeys) > 0 {
+		keys := make([]string, len(pp.SortKeys))
+		for i, key := range pp.SortKeys {
+			keys[i] = key.String()
+		}
+		result += fmt.Sprintf(" by %s", strings.Join(keys, ", "))
+	}
+
 	if len(pp.Children) > 0 {
 		for _, child := range pp.Children {
 			result += "\n" + child.toString(indent+1)
@@ -219,6 +370,77 @@ func (pp *PhysicalPlan) toString(indent int) string {
 	return result
 }
 
+// setOperationName returns the SQL name of a set operation, e.g. UNION ALL
+func setOperationName(op parser.SetOperator, all bool) string {
+	if all {
+		return op.String() + " ALL"
+	}
+	return op.String()
+}
+
+// WindowSpecs returns the distinct windows of window function calls, in
+// order of first use. Calls over equal windows share one sort of the input.
+func WindowSpecs(calls []*parser.FunctionCall) []*parser.WindowSpec {
+	var specs []*parser.WindowSpec
+	seen := make(map[string]bool)
+	for _, call := range calls {
+		key := call.Over.String()
+		if !seen[key] {
+			seen[key] = true
+			specs = append(specs, call.Over)
+		}
+	}
+	return specs
+}
+
+// SortKey describes one ORDER BY key
+type SortKey struct {
+	Expr       interface{} // Sort expression (parser.Expression)
+	Column     string      // Column name when the key is a plain column reference
+	Descending bool
+}
+
+// String returns a string representation of the sort key
+func (sk SortKey) String() string {
+	name := sk.Column
+	if name == "" {
+		name = fmt.Sprintf("%v", sk.Expr)
+	}
+	if sk.Descending {
+		return name + " DESC"
+	}
+	return name + " ASC"
+}
+
+// KeyRange bounds the primary key values read by a clustered index scan.
+// A nil bound leaves that end of the range open.
+type KeyRange struct {
+	Lower          interface{}
+	Upper          interface{}
+	LowerInclusive bool
+	UpperInclusive bool
+}
+
+// String returns a string representation of the key range
+func (kr *KeyRange) String() string {
+	lower, upper := "(-inf", "+inf)"
+	if kr.Lower != nil {
+		bracket := "("
+		if kr.LowerInclusive {
+			bracket = "["
+		}
+		lower = fmt.Sprintf("%s%v", bracket, kr.Lower)
+	}
+	if kr.Upper != nil {
+		bracket := ")"
+		if kr.UpperInclusive {
+			bracket = "]"
+		}
+		upper = fmt.Sprintf("%v%s", kr.Upper, bracket)
+	}
+	return lower + ", " + upper
+}
+
 // ScanMethod represents the method for scanning a table
 type ScanMethod int
 
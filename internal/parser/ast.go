@@ -27,13 +27,18 @@ type Expression interface {
 	ExpressionNode()
 }
 
-// SelectStatement represents a SELECT query
+// SelectStatement represents a SELECT query. A query combining two others
+// with UNION, INTERSECT or EXCEPT has a SetOperation instead of the clauses
+// from SELECT to HAVING; its ORDER BY and LIMIT apply to the combined rows.
 type SelectStatement struct {
+	With         *WithClause
+	SetOperation *SetOperation
 	SelectClause *SelectClause
 	FromClause   *FromClause
 	WhereClause  *WhereClause
 	GroupBy      *GroupByClause
 	Having       *HavingClause
+	Windows      []*NamedWindow // the WINDOW clause
 	OrderBy      *OrderByClause
 	Limit        *LimitClause
 }
@@ -43,6 +48,12 @@ func (s *SelectStatement) NodeType() string { return "SelectStatement" }
 func (s *SelectStatement) String() string {
 	var parts []string
 	
+	if s.With != nil {
+		parts = append(parts, s.With.String())
+	}
+	if s.SetOperation != nil {
+		parts = append(parts, s.SetOperation.String())
+	}
 	if s.SelectClause != nil {
 		parts = append(parts, s.SelectClause.String())
 	}
@@ -58,6 +69,13 @@ func (s *SelectStatement) String() string {
 	if s.Having != nil {
 		parts = append(parts, s.Having.String())
 	}
+	if len(s.Windows) > 0 {
+		windows := make([]string, len(s.Windows))
+		for i, window := range s.Windows {
+			windows[i] = window.String()
+		}
+		parts = append(parts, "WINDOW "+strings.Join(windows, ", "))
+	}
 	if s.OrderBy != nil {
 		parts = append(parts, s.OrderBy.String())
 	}
@@ -68,11 +86,139 @@ func (s *SelectStatement) String() string {
 	return strings.Join(parts, " ")
 }
 
-// InsertStatement represents an INSERT statement
+// FirstSelect returns the leftmost plain SELECT of a query, whose select
+// list names the columns of a set operation's result
+func (s *SelectStatement) FirstSelect() *SelectStatement {
+	for s.SetOperation != nil {
+		s = s.SetOperation.Left
+	}
+	return s
+}
+
+// SetOperator is the operator of a set operation
+type SetOperator int
+
+const (
+	Union SetOperator = iota
+	Intersect
+	Except
+)
+
+func (o SetOperator) String() string {
+	switch o {
+	case Union:
+		return "UNION"
+	case Intersect:
+		return "INTERSECT"
+	case Except:
+		return "EXCEPT"
+	default:
+		return "UNKNOWN"
+	}
+}
+
+// SetOperation represents left UNION|INTERSECT|EXCEPT [ALL] right
+type SetOperation struct {
+	Operator SetOperator
+	All      bool
+	Left     *SelectStatement
+	Right    *SelectStatement
+}
+
+func (s *SetOperation) NodeType() string { return "SetOperation" }
+func (s *SetOperation) String() string {
+	operator := s.Operator.String()
+	if s.All {
+		operator += " ALL"
+	}
+
+	// Operations associate left, and INTERSECT binds tighter than the others
+	left := s.Left.String()
+	if needsParentheses(s.Left) || (s.Left.SetOperation != nil && s.Operator == Intersect && s.Left.SetOperation.Operator != Intersect) {
+		left = "(" + left + ")"
+	}
+	right := s.Right.String()
+	if needsParentheses(s.Right) || s.Right.SetOperation != nil {
+		right = "(" + right + ")"
+	}
+	return left + " " + operator + " " + right
+}
+
+// needsParentheses reports whether an operand of a set operation has clauses
+// that would otherwise apply to the whole operation
+func needsParentheses(s *SelectStatement) bool {
+	return s.With != nil || s.OrderBy != nil || s.Limit != nil
+}
+
+// WithClause represents the common table expressions of WITH [RECURSIVE]
+type WithClause struct {
+	Recursive bool
+	CTEs      []*CommonTableExpression
+}
+
+func (w *WithClause) NodeType() string { return "WithClause" }
+func (w *WithClause) String() string {
+	var result strings.Builder
+	result.WriteString("WITH ")
+	if w.Recursive {
+		result.WriteString("RECURSIVE ")
+	}
+	for i, cte := range w.CTEs {
+		if i > 0 {
+			result.WriteString(", ")
+		}
+		result.WriteString(cte.String())
+	}
+	return result.String()
+}
+
+// CommonTableExpression represents name [(columns)] AS (query). In a
+// recursive CTE Query is the anchor term and RecursiveTerm, joined to it by
+// UNION [ALL], is the term that refers to the CTE itself.
+type CommonTableExpression struct {
+	Name          *Identifier
+	Columns       []*Identifier
+	Query         *SelectStatement
+	RecursiveTerm *SelectStatement
+	UnionAll      bool
+}
+
+func (c *CommonTableExpression) NodeType() string { return "CommonTableExpression" }
+func (c *CommonTableExpression) String() string {
+	var result strings.Builder
+	result.WriteString(c.Name.String())
+	if len(c.Columns) > 0 {
+		result.WriteString(" (")
+		for i, col := range c.Columns {
+			if i > 0 {
+				result.WriteString(", ")
+			}
+			result.WriteString(col.String())
+		}
+		result.WriteString(")")
+	}
+	result.WriteString(" AS (")
+	result.WriteString(c.Query.String())
+	if c.RecursiveTerm != nil {
+		result.WriteString(" UNION ")
+		if c.UnionAll {
+			result.WriteString("ALL ")
+		}
+		result.WriteString(c.RecursiveTerm.String())
+	}
+	result.WriteString(")")
+	return result.String()
+}
+
+// InsertStatement represents an INSERT statement. The rows come from
+// either Values or Query.
 type InsertStatement struct {
 	TableName *Identifier
 	Columns   []*Identifier
 	Values    [][]Expression
+	Query      *SelectStatement // INSERT ... SELECT
+	OnConflict *OnConflictClause
+	Returning  []Expression
 }
 
 func (i *InsertStatement) StatementNode() {}
@@ -93,21 +239,32 @@ func (i *InsertStatement) String() string {
 		result.WriteString(")")
 	}
 	
-	result.WriteString(" VALUES ")
-	for idx, valueSet := range i.Values {
-		if idx > 0 {
-			result.WriteString(", ")
-		}
-		result.WriteString("(")
-		for vidx, val := range valueSet {
-			if vidx > 0 {
+	if i.Query != nil {
+		result.WriteString(" ")
+		result.WriteString(i.Query.String())
+	} else {
+		result.WriteString(" VALUES ")
+		for idx, valueSet := range i.Values {
+			if idx > 0 {
 				result.WriteString(", ")
 			}
-			result.WriteString(val.String())
+			result.WriteString("(")
+			for vidx, val := range valueSet {
+				if vidx > 0 {
+					result.WriteString(", ")
+				}
+				result.WriteString(val.String())
+			}
+			result.WriteString(")")
 		}
-		result.WriteString(")")
 	}
 	
+	if i.OnConflict != nil {
+		result.WriteString(" ")
+		result.WriteString(i.OnConflict.String())
+	}
+	
+	result.WriteString(returningString(i.Returning))
 	return result.String()
 }
 
@@ -115,7 +272,9 @@ func (i *InsertStatement) String() string {
 type UpdateStatement struct {
 	TableName *Identifier
 	SetClauses []*SetClause
+	From *FromClause // tables joined to the target, or nil
 	WhereClause *WhereClause
+	Returning []Expression
 }
 
 func (u *UpdateStatement) StatementNode() {}
@@ -133,18 +292,26 @@ func (u *UpdateStatement) String() string {
 		result.WriteString(setClause.String())
 	}
 	
+	if u.From != nil {
+		result.WriteString(" ")
+		result.WriteString(u.From.String())
+	}
+	
 	if u.WhereClause != nil {
 		result.WriteString(" ")
 		result.WriteString(u.WhereClause.String())
 	}
 	
+	result.WriteString(returningString(u.Returning))
 	return result.String()
 }
 
 // DeleteStatement represents a DELETE statement
 type DeleteStatement struct {
 	TableName   *Identifier
+	Using       *FromClause // tables joined to the target, or nil
 	WhereClause *WhereClause
+	Returning   []Expression
 }
 
 func (d *DeleteStatement) StatementNode() {}
@@ -154,19 +321,206 @@ func (d *DeleteStatement) String() string {
 	result.WriteString("DELETE FROM ")
 	result.WriteString(d.TableName.String())
 	
+	if d.Using != nil {
+		result.WriteString(" USING ")
+		result.WriteString(d.Using.itemsString())
+	}
+	
 	if d.WhereClause != nil {
 		result.WriteString(" ")
 		result.WriteString(d.WhereClause.String())
 	}
 	
+	result.WriteString(returningString(d.Returning))
+	return result.String()
+}
+
+// returningString renders the RETURNING clause of a data-modifying
+// statement, or nothing when it has none
+func returningString(returning []Expression) string {
+	if len(returning) == 0 {
+		return ""
+	}
+	
+	var result strings.Builder
+	result.WriteString(" RETURNING ")
+	for idx, expr := range returning {
+		if idx > 0 {
+			result.WriteString(", ")
+		}
+		result.WriteString(expr.String())
+	}
+	return result.String()
+}
+
+// OnConflictClause is the ON CONFLICT clause of an INSERT. Columns name the
+// primary key or unique index a conflict is detected on, and may be left
+// out for DO NOTHING. DO UPDATE refers to the row that was not inserted as
+// excluded.
+type OnConflictClause struct {
+	Columns     []*Identifier
+	DoUpdate    bool
+	SetClauses  []*SetClause
+	WhereClause *WhereClause
+}
+
+func (o *OnConflictClause) NodeType() string { return "OnConflictClause" }
+func (o *OnConflictClause) String() string {
+	var result strings.Builder
+	result.WriteString("ON CONFLICT")
+	
+	if len(o.Columns) > 0 {
+		result.WriteString(" (")
+		for idx, col := range o.Columns {
+			if idx > 0 {
+				result.WriteString(", ")
+			}
+			result.WriteString(col.String())
+		}
+		result.WriteString(")")
+	}
+	
+	if !o.DoUpdate {
+		result.WriteString(" DO NOTHING")
+		return result.String()
+	}
+	
+	result.WriteString(" DO UPDATE SET ")
+	for idx, setClause := range o.SetClauses {
+		if idx > 0 {
+			result.WriteString(", ")
+		}
+		result.WriteString(setClause.String())
+	}
+	if o.WhereClause != nil {
+		result.WriteString(" ")
+		result.WriteString(o.WhereClause.String())
+	}
 	return result.String()
 }
 
+// MergeStatement represents a MERGE statement. Every row of Source is
+// matched against Target by Condition, and the first WHEN clause that
+// applies to it decides what happens.
+type MergeStatement struct {
+	Target    *Identifier // table, with an optional alias
+	Source    Expression  // table or derived table, with an optional alias
+	Condition Expression
+	Clauses   []*MergeClause
+}
+
+func (m *MergeStatement) StatementNode() {}
+func (m *MergeStatement) NodeType() string { return "MergeStatement" }
+func (m *MergeStatement) String() string {
+	var result strings.Builder
+	result.WriteString("MERGE INTO ")
+	result.WriteString(m.Target.String())
+	result.WriteString(" USING ")
+	result.WriteString(m.Source.String())
+	result.WriteString(" ON ")
+	result.WriteString(m.Condition.String())
+	
+	for _, clause := range m.Clauses {
+		result.WriteString(" ")
+		result.WriteString(clause.String())
+	}
+	return result.String()
+}
+
+// MergeClause is a WHEN [NOT] MATCHED clause of a MERGE statement
+type MergeClause struct {
+	Matched   bool
+	Condition Expression // AND condition, nil without one
+	Action    MergeAction
+	
+	SetClauses []*SetClause  // MergeUpdate
+	Columns    []*Identifier // MergeInsert, empty for all columns
+	Values     []Expression  // MergeInsert
+}
+
+func (m *MergeClause) NodeType() string { return "MergeClause" }
+func (m *MergeClause) String() string {
+	var result strings.Builder
+	if m.Matched {
+		result.WriteString("WHEN MATCHED")
+	} else {
+		result.WriteString("WHEN NOT MATCHED")
+	}
+	if m.Condition != nil {
+		result.WriteString(" AND ")
+		result.WriteString(m.Condition.String())
+	}
+	result.WriteString(" THEN ")
+	
+	switch m.Action {
+	case MergeUpdate:
+		result.WriteString("UPDATE SET ")
+		for idx, setClause := range m.SetClauses {
+			if idx > 0 {
+				result.WriteString(", ")
+			}
+			result.WriteString(setClause.String())
+		}
+	case MergeDelete:
+		result.WriteString("DELETE")
+	case MergeInsert:
+		result.WriteString("INSERT")
+		if len(m.Columns) > 0 {
+			result.WriteString(" (")
+			for idx, col := range m.Columns {
+				if idx > 0 {
+					result.WriteString(", ")
+				}
+				result.WriteString(col.String())
+			}
+			result.WriteString(")")
+		}
+		result.WriteString(" VALUES (")
+		for idx, val := range m.Values {
+			if idx > 0 {
+				result.WriteString(", ")
+			}
+			result.WriteString(val.String())
+		}
+		result.WriteString(")")
+	case MergeDoNothing:
+		result.WriteString("DO NOTHING")
+	}
+	return result.String()
+}
+
+// MergeAction is what a MERGE clause does to the rows it applies to
+type MergeAction int
+
+const (
+	MergeUpdate MergeAction = iota
+	MergeDelete
+	MergeInsert
+	MergeDoNothing
+)
+
+func (a MergeAction) String() string {
+	switch a {
+	case MergeUpdate:
+		return "UPDATE"
+	case MergeDelete:
+		return "DELETE"
+	case MergeInsert:
+		return "INSERT"
+	case MergeDoNothing:
+		return "DO NOTHING"
+	default:
+		return "UNKNOWN"
+	}
+}
+
 // CreateTableStatement represents a CREATE TABLE statement
 type CreateTableStatement struct {
 	TableName *Identifier
 	Columns   []*ColumnDefinition
 	Constraints []*TableConstraint
+	PartitionBy *PartitionSpec
+	Options   []*TableOption
 }
 
 func (c *CreateTableStatement) StatementNode() {}
@@ -190,13 +544,159 @@ func (c *CreateTableStatement) String() string {
 	}
 	
 	result.WriteString(")")
+	
+	if c.PartitionBy != nil {
+		result.WriteString(" ")
+		result.WriteString(c.PartitionBy.String())
+	}
+	
+	if len(c.Options) > 0 {
+		result.WriteString(" WITH (")
+		for idx, option := range c.Options {
+			if idx > 0 {
+				result.WriteString(", ")
+			}
+			result.WriteString(option.String())
+		}
+		result.WriteString(")")
+	}
 	return result.String()
 }
 
-// DropTableStatement represents a DROP TABLE statement
+// Option returns the table option with the given name, or nil if it is not set.
+// Option names are matched case-insensitively.
+func (c *CreateTableStatement) Option(name string) *TableOption {
+	for _, option := range c.Options {
+		if strings.EqualFold(option.Name.Value, name) {
+			return option
+		}
+	}
+	return nil
+}
+
+// PartitionMethod identifies how rows are assigned to partitions
+type PartitionMethod int
+
+const (
+	PartitionByRange PartitionMethod = iota
+	PartitionByList
+	PartitionByHash
+)
+
+func (m PartitionMethod) String() string {
+	switch m {
+	case PartitionByRange:
+		return "RANGE"
+	case PartitionByList:
+		return "LIST"
+	case PartitionByHash:
+		return "HASH"
+	default:
+		return "UNKNOWN"
+	}
+}
+
+// PartitionSpec represents the PARTITION BY clause of CREATE TABLE
+type PartitionSpec struct {
+	Method     PartitionMethod
+	Column     *Identifier
+	Partitions []*PartitionDefinition
+	Count      int // HASH ... PARTITIONS n, used when Partitions is empty
+}
+
+func (p *PartitionSpec) NodeType() string { return "PartitionSpec" }
+func (p *PartitionSpec) String() string {
+	result := fmt.Sprintf("PARTITION BY %s (%s)", p.Method, p.Column.String())
+	if len(p.Partitions) == 0 {
+		return result + fmt.Sprintf(" PARTITIONS %d", p.Count)
+	}
+	
+	partitions := make([]string, len(p.Partitions))
+	for idx, partition := range p.Partitions {
+		partitions[idx] = partition.String()
+	}
+	return result + " (" + strings.Join(partitions, ", ") + ")"
+}
+
+// PartitionDefinition represents one PARTITION entry of a PARTITION BY clause
+type PartitionDefinition struct {
+	Name     *Identifier
+	LessThan Expression   // RANGE: exclusive upper bound
+	MaxValue bool         // RANGE: VALUES LESS THAN (MAXVALUE)
+	Values   []Expression // LIST: values stored in this partition
+}
+
+func (p *PartitionDefinition) NodeType() string { return "PartitionDefinition" }
+func (p *PartitionDefinition) String() string {
+	result := "PARTITION " + p.Name.String()
+	switch {
+	case len(p.Values) > 0:
+		values := make([]string, len(p.Values))
+		for idx, value := range p.Values {
+			values[idx] = value.String()
+		}
+		result += " VALUES IN (" + strings.Join(values, ", ") + ")"
+	case p.LessThan != nil:
+		result += " VALUES LESS THAN (" + p.LessThan.String() + ")"
+	case p.MaxValue:
+		result += " VALUES LESS THAN (MAXVALUE)"
+	}
+	return result
+}
+
+// TableOption represents a storage option in the WITH clause of CREATE TABLE,
+// e.g. WITH (clustered = true)
+type TableOption struct {
+	Name  *Identifier
+	Value Expression // nil when the option is given without a value
+}
+
+func (t *TableOption) NodeType() string { return "TableOption" }
+func (t *TableOption) String() string {
+	if t.Value == nil {
+		return t.Name.String()
+	}
+	return t.Name.String() + " = " + t.Value.String()
+}
+
+// Text returns the option value as plain text, unquoting string literals.
+// An option given without a value returns the empty string.
+func (t *TableOption) Text() string {
+	switch v := t.Value.(type) {
+	case nil:
+		return ""
+	case *Identifier:
+		return v.Value
+	case *Literal:
+		return fmt.Sprintf("%v", v.Value)
+	default:
+		return t.Value.String()
+	}
+}
+
+// IsEnabled reports whether a boolean option is switched on. An option given
+// without a value counts as enabled.
+func (t *TableOption) IsEnabled() bool {
+	if t.Value == nil {
+		return true
+	}
+	switch v := t.Value.(type) {
+	case *Identifier:
+		return strings.EqualFold(v.Value, "true") || strings.EqualFold(v.Value, "on")
+	case *Literal:
+		s := fmt.Sprintf("%v", v.Value)
+		return s == "1" || strings.EqualFold(s, "true") || strings.EqualFold(s, "on")
+	default:
+		return false
+	}
+}
+
+// DropTableStatement represents a DROP TABLE statement. Cascade also drops
+// the views that depend on the table.
 type DropTableStatement struct {
 	TableName *Identifier
 	IfExists  bool
+	Cascade   bool
 }
 
 func (d *DropTableStatement) StatementNode() {}
@@ -208,9 +708,374 @@ func (d *DropTableStatement) String() string {
 		result.WriteString("IF EXISTS ")
 	}
 	result.WriteString(d.TableName.String())
+	if d.Cascade {
+		result.WriteString(" CASCADE")
+	}
+	return result.String()
+}
+
+// CreateViewStatement represents a CREATE VIEW or CREATE MATERIALIZED VIEW
+// statement
+type CreateViewStatement struct {
+	ViewName     *Identifier
+	Columns      []*Identifier // renames the query's columns, empty to keep them
+	Query        *SelectStatement
+	OrReplace    bool
+	Materialized bool
+	IfNotExists  bool
+	WithNoData   bool // materialized views only: create the view unpopulated
+}
+
+func (c *CreateViewStatement) StatementNode() {}
+func (c *CreateViewStatement) NodeType() string { return "CreateViewStatement" }
+func (c *CreateViewStatement) String() string {
+	var result strings.Builder
+	result.WriteString("CREATE ")
+	if c.OrReplace {
+		result.WriteString("OR REPLACE ")
+	}
+	if c.Materialized {
+		result.WriteString("MATERIALIZED ")
+	}
+	result.WriteString("VIEW ")
+	if c.IfNotExists {
+		result.WriteString("IF NOT EXISTS ")
+	}
+	result.WriteString(c.ViewName.String())
+	if len(c.Columns) > 0 {
+		result.WriteString(" (")
+		for i, col := range c.Columns {
+			if i > 0 {
+				result.WriteString(", ")
+			}
+			result.WriteString(col.String())
+		}
+		result.WriteString(")")
+	}
+	result.WriteString(" AS ")
+	result.WriteString(c.Query.String())
+	if c.WithNoData {
+		result.WriteString(" WITH NO DATA")
+	}
+	return result.String()
+}
+
+// DropViewStatement represents a DROP VIEW or DROP MATERIALIZED VIEW
+// statement. Cascade also drops the views that depend on the view.
+type DropViewStatement struct {
+	ViewName     *Identifier
+	Materialized bool
+	IfExists     bool
+	Cascade      bool
+}
+
+func (d *DropViewStatement) StatementNode() {}
+func (d *DropViewStatement) NodeType() string { return "DropViewStatement" }
+func (d *DropViewStatement) String() string {
+	var result strings.Builder
+	result.WriteString("DROP ")
+	if d.Materialized {
+		result.WriteString("MATERIALIZED ")
+	}
+	result.WriteString("VIEW ")
+	if d.IfExists {
+		result.WriteString("IF EXISTS ")
+	}
+	result.WriteString(d.ViewName.String())
+	if d.Cascade {
+		result.WriteString(" CASCADE")
+	}
+	return result.String()
+}
+
+// RefreshMaterializedViewStatement represents
+// REFRESH MATERIALIZED VIEW [CONCURRENTLY] name
+type RefreshMaterializedViewStatement struct {
+	ViewName     *Identifier
+	Concurrently bool
+}
+
+func (r *RefreshMaterializedViewStatement) StatementNode() {}
+func (r *RefreshMaterializedViewStatement) NodeType() string {
+	return "RefreshMaterializedViewStatement"
+}
+func (r *RefreshMaterializedViewStatement) String() string {
+	if r.Concurrently {
+		return "REFRESH MATERIALIZED VIEW CONCURRENTLY " + r.ViewName.String()
+	}
+	return "REFRESH MATERIALIZED VIEW " + r.ViewName.String()
+}
+
+// CreateIndexStatement represents a CREATE INDEX statement
+type CreateIndexStatement struct {
+	IndexName   *Identifier
+	TableName   *Identifier
+	Columns     []*Identifier
+	Unique      bool
+	IfNotExists bool
+	Using       *Identifier // index method, nil for the default
+}
+
+func (c *CreateIndexStatement) StatementNode() {}
+func (c *CreateIndexStatement) NodeType() string { return "CreateIndexStatement" }
+func (c *CreateIndexStatement) String() string {
+	var result strings.Builder
+	result.WriteString("CREATE ")
+	if c.Unique {
+		result.WriteString("UNIQUE ")
+	}
+	result.WriteString("INDEX ")
+	if c.IfNotExists {
+		result.WriteString("IF NOT EXISTS ")
+	}
+	result.WriteString(c.IndexName.String())
+	result.WriteString(" ON ")
+	result.WriteString(c.TableName.String())
+	result.WriteString(" (")
+	for i, col := range c.Columns {
+		if i > 0 {
+			result.WriteString(", ")
+		}
+		result.WriteString(col.String())
+	}
+	result.WriteString(")")
+	if c.Using != nil {
+		result.WriteString(" USING ")
+		result.WriteString(c.Using.String())
+	}
+	return result.String()
+}
+
+// DropIndexStatement represents a DROP INDEX statement
+type DropIndexStatement struct {
+	IndexName *Identifier
+	IfExists  bool
+}
+
+func (d *DropIndexStatement) StatementNode() {}
+func (d *DropIndexStatement) NodeType() string { return "DropIndexStatement" }
+func (d *DropIndexStatement) String() string {
+	var result strings.Builder
+	result.WriteString("DROP INDEX ")
+	if d.IfExists {
+		result.WriteString("IF EXISTS ")
+	}
+	result.WriteString(d.IndexName.String())
+	return result.String()
+}
+
+// IsolationLevel is the isolation level requested by BEGIN
+type IsolationLevel int
+
+const (
+	IsolationDefault IsolationLevel = iota // the server's default
+	IsolationReadUncommitted
+	IsolationReadCommitted
+	IsolationRepeatableRead
+	IsolationSerializable
+)
+
+func (l IsolationLevel) String() string {
+	switch l {
+	case IsolationReadUncommitted:
+		return "READ UNCOMMITTED"
+	case IsolationReadCommitted:
+		return "READ COMMITTED"
+	case IsolationRepeatableRead:
+		return "REPEATABLE READ"
+	case IsolationSerializable:
+		return "SERIALIZABLE"
+	default:
+		return "DEFAULT"
+	}
+}
+
+// BeginStatement represents BEGIN [TRANSACTION] [ISOLATION LEVEL level]
+// or START TRANSACTION
+type BeginStatement struct {
+	IsolationLevel IsolationLevel
+}
+
+func (b *BeginStatement) StatementNode() {}
+func (b *BeginStatement) NodeType() string { return "BeginStatement" }
+func (b *BeginStatement) String() string {
+	if b.IsolationLevel == IsolationDefault {
+		return "BEGIN"
+	}
+	return "BEGIN ISOLATION LEVEL " + b.IsolationLevel.String()
+}
+
+// CommitStatement represents COMMIT [TRANSACTION]
+type CommitStatement struct{}
+
+func (c *CommitStatement) StatementNode() {}
+func (c *CommitStatement) NodeType() string { return "CommitStatement" }
+func (c *CommitStatement) String() string { return "COMMIT" }
+
+// RollbackStatement represents ROLLBACK [TRANSACTION] [TO [SAVEPOINT] name].
+// Savepoint is nil when the whole transaction is rolled back.
+type RollbackStatement struct {
+	Savepoint *Identifier
+}
+
+func (r *RollbackStatement) StatementNode() {}
+func (r *RollbackStatement) NodeType() string { return "RollbackStatement" }
+func (r *RollbackStatement) String() string {
+	if r.Savepoint == nil {
+		return "ROLLBACK"
+	}
+	return "ROLLBACK TO SAVEPOINT " + r.Savepoint.String()
+}
+
+// SavepointStatement represents SAVEPOINT name
+type SavepointStatement struct {
+	Name *Identifier
+}
+
+func (s *SavepointStatement) StatementNode() {}
+func (s *SavepointStatement) NodeType() string { return "SavepointStatement" }
+func (s *SavepointStatement) String() string {
+	return "SAVEPOINT " + s.Name.String()
+}
+
+// ReleaseSavepointStatement represents RELEASE [SAVEPOINT] name
+type ReleaseSavepointStatement struct {
+	Name *Identifier
+}
+
+func (r *ReleaseSavepointStatement) StatementNode() {}
+func (r *ReleaseSavepointStatement) NodeType() string { return "ReleaseSavepointStatement" }
+func (r *ReleaseSavepointStatement) String() string {
+	return "RELEASE SAVEPOINT " + r.Name.String()
+}
+
+// AlterTableStatement represents an ALTER TABLE statement
+type AlterTableStatement struct {
+	TableName *Identifier
+	Actions   []AlterTableAction
+}
+
+func (a *AlterTableStatement) StatementNode() {}
+func (a *AlterTableStatement) NodeType() string { return "AlterTableStatement" }
+func (a *AlterTableStatement) String() string {
+	var result strings.Builder
+	result.WriteString("ALTER TABLE ")
+	result.WriteString(a.TableName.String())
+	
+	for idx, action := range a.Actions {
+		if idx > 0 {
+			result.WriteString(",")
+		}
+		result.WriteString(" ")
+		result.WriteString(action.String())
+	}
+	
 	return result.String()
 }
 
+// AlterTableAction represents a single change made by ALTER TABLE
+type AlterTableAction interface {
+	Node
+	AlterTableActionNode()
+}
+
+// SetTableOptionsAction represents ALTER TABLE ... SET (name = value, ...)
+type SetTableOptionsAction struct {
+	Options []*TableOption
+}
+
+func (s *SetTableOptionsAction) AlterTableActionNode() {}
+func (s *SetTableOptionsAction) NodeType() string { return "SetTableOptionsAction" }
+func (s *SetTableOptionsAction) String() string {
+	options := make([]string, len(s.Options))
+	for idx, option := range s.Options {
+		options[idx] = option.String()
+	}
+	return "SET (" + strings.Join(options, ", ") + ")"
+}
+
+// ResetTableOptionsAction represents ALTER TABLE ... RESET (name, ...)
+type ResetTableOptionsAction struct {
+	Names []*Identifier
+}
+
+func (r *ResetTableOptionsAction) AlterTableActionNode() {}
+func (r *ResetTableOptionsAction) NodeType() string { return "ResetTableOptionsAction" }
+func (r *ResetTableOptionsAction) String() string {
+	names := make([]string, len(r.Names))
+	for idx, name := range r.Names {
+		names[idx] = name.String()
+	}
+	return "RESET (" + strings.Join(names, ", ") + ")"
+}
+
+// AddColumnAction represents ALTER TABLE ... ADD [COLUMN] definition
+type AddColumnAction struct {
+	Column *ColumnDefinition
+}
+
+func (a *AddColumnAction) AlterTableActionNode() {}
+func (a *AddColumnAction) NodeType() string { return "AddColumnAction" }
+func (a *AddColumnAction) String() string {
+	return "ADD COLUMN " + a.Column.String()
+}
+
+// DropColumnAction represents ALTER TABLE ... DROP [COLUMN] name
+type DropColumnAction struct {
+	Column *Identifier
+}
+
+func (d *DropColumnAction) AlterTableActionNode() {}
+func (d *DropColumnAction) NodeType() string { return "DropColumnAction" }
+func (d *DropColumnAction) String() string {
+	return "DROP COLUMN " + d.Column.String()
+}
+
+// RenameColumnAction represents ALTER TABLE ... RENAME [COLUMN] name TO new_name
+type RenameColumnAction struct {
+	Column  *Identifier
+	NewName *Identifier
+}
+
+func (r *RenameColumnAction) AlterTableActionNode() {}
+func (r *RenameColumnAction) NodeType() string { return "RenameColumnAction" }
+func (r *RenameColumnAction) String() string {
+	return "RENAME COLUMN " + r.Column.String() + " TO " + r.NewName.String()
+}
+
+// RenameTableAction represents ALTER TABLE ... RENAME TO new_name
+type RenameTableAction struct {
+	NewName *Identifier
+}
+
+func (r *RenameTableAction) AlterTableActionNode() {}
+func (r *RenameTableAction) NodeType() string { return "RenameTableAction" }
+func (r *RenameTableAction) String() string {
+	return "RENAME TO " + r.NewName.String()
+}
+
+// AddConstraintAction represents ALTER TABLE ... ADD table_constraint
+type AddConstraintAction struct {
+	Constraint *TableConstraint
+}
+
+func (a *AddConstraintAction) AlterTableActionNode() {}
+func (a *AddConstraintAction) NodeType() string { return "AddConstraintAction" }
+func (a *AddConstraintAction) String() string {
+	return "ADD " + a.Constraint.String()
+}
+
+// DropConstraintAction represents ALTER TABLE ... DROP CONSTRAINT name
+type DropConstraintAction struct {
+	Name *Identifier
+}
+
+func (d *DropConstraintAction) AlterTableActionNode() {}
+func (d *DropConstraintAction) NodeType() string { return "DropConstraintAction" }
+func (d *DropConstraintAction) String() string {
+	return "DROP CONSTRAINT " + d.Name.String()
+}
+
 // SelectClause represents the SELECT part of a query
 type SelectClause struct {
 	Distinct bool
@@ -243,8 +1108,13 @@ type FromClause struct {
 
 func (f *FromClause) NodeType() string { return "FromClause" }
 func (f *FromClause) String() string {
+	return "FROM " + f.itemsString()
+}
+
+// itemsString renders the tables and joins of the clause without the FROM
+// keyword, which DELETE ... USING replaces
+func (f *FromClause) itemsString() string {
 	var result strings.Builder
-	result.WriteString("FROM ")
 	
 	for idx, table := range f.Tables {
 		if idx > 0 {
@@ -469,6 +1339,7 @@ type TableConstraint struct {
 	Name       *Identifier
 	Columns    []*Identifier
 	References *ForeignKeyReference
+	Check      Expression // CHECK constraints only
 }
 
 func (t *TableConstraint) NodeType() string { return "TableConstraint" }
@@ -510,6 +1381,10 @@ func (t *TableConstraint) String() string {
 			result.WriteString(col.String())
 		}
 		result.WriteString(")")
+	case Check:
+		result.WriteString("CHECK (")
+		result.WriteString(t.Check.String())
+		result.WriteString(")")
 	}
 	
 	return result.String()
@@ -524,6 +1399,7 @@ const (
 	ForeignKey
 	Check
 	Default
+	AutoIncrement
 )
 
 func (c *ColumnConstraint) NodeType() string { return "ColumnConstraint" }
@@ -539,6 +1415,8 @@ func (c *ColumnConstraint) String() string {
 		return "REFERENCES " + c.References.String()
 	case Default:
 		return "DEFAULT " + c.DefaultValue.String()
+	case AutoIncrement:
+		return "AUTO_INCREMENT"
 	default:
 		return ""
 	}
@@ -570,24 +1448,37 @@ func (f *ForeignKeyReference) String() string {
 
 // Identifier represents table names, column names, etc.
 type Identifier struct {
-	Value string
-	Alias *Identifier
+	Value  string
+	Alias  *Identifier
+	Quoted bool // written in quotes, so never a keyword and kept as is
+	Pos    Pos  // where the name appears in the source
 }
 
 func (i *Identifier) ExpressionNode() {}
 func (i *Identifier) NodeType() string { return "Identifier" }
 func (i *Identifier) String() string {
 	result := i.Value
+	if i.Quoted {
+		result = QuoteIdentifier(i.Value)
+	}
 	if i.Alias != nil {
-		result += " AS " + i.Alias.Value
+		result += " AS " + i.Alias.String()
 	}
 	return result
 }
 
-// Literal represents literal values (strings, numbers, etc.)
+// QuoteIdentifier returns name as a quoted identifier, with the quotes
+// inside it doubled
+func QuoteIdentifier(name string) string {
+	return `"` + strings.ReplaceAll(name, `"`, `""`) + `"`
+}
+
+// Literal represents literal values (strings, numbers, etc.). A number
+// keeps its source text and a blob holds its bytes as a []byte.
 type Literal struct {
 	Value interface{}
 	Type  lexer.TokenType
+	Pos   Pos // where the literal appears in the source
 }
 
 func (l *Literal) ExpressionNode() {}
@@ -595,9 +1486,17 @@ func (l *Literal) NodeType() string { return "Literal" }
 func (l *Literal) String() string {
 	switch l.Type {
 	case lexer.STRING:
-		return fmt.Sprintf("'%v'", l.Value)
+		return "'" + strings.ReplaceAll(fmt.Sprintf("%v", l.Value), "'", "''") + "'"
+	case lexer.HEX_STRING:
+		return fmt.Sprintf("X'%X'", l.Value)
 	case lexer.NUMBER:
 		return fmt.Sprintf("%v", l.Value)
+	case lexer.NULL:
+		return "NULL"
+	case lexer.TRUE:
+		return "TRUE"
+	case lexer.FALSE:
+		return "FALSE"
 	default:
 		return fmt.Sprintf("%v", l.Value)
 	}
@@ -714,6 +1613,7 @@ type FunctionCall struct {
 	Name      *Identifier
 	Arguments []Expression
 	Distinct  bool
+	Over      *WindowSpec // set for window function calls
 }
 
 func (f *FunctionCall) ExpressionNode() {}
@@ -732,9 +1632,123 @@ func (f *FunctionCall) String() string {
 		result.WriteString(arg.String())
 	}
 	result.WriteString(")")
+	if f.Over != nil {
+		result.WriteString(" OVER ")
+		result.WriteString(f.Over.String())
+	}
 	return result.String()
 }
 
+// IsWindow reports whether the call is a window function call
+func (f *FunctionCall) IsWindow() bool {
+	return f.Over != nil
+}
+
+// WindowSpec represents the window of an OVER clause:
+// (PARTITION BY ... ORDER BY ... frame), or the name of a window of the
+// query's WINDOW clause, whose parts the parser copies in
+type WindowSpec struct {
+	Name        *Identifier // set for OVER name
+	PartitionBy []Expression
+	OrderBy     *OrderByClause
+	Frame       *WindowFrame // nil for the default frame
+}
+
+func (w *WindowSpec) NodeType() string { return "WindowSpec" }
+func (w *WindowSpec) String() string {
+	if w.Name != nil {
+		return w.Name.String()
+	}
+	var parts []string
+	if len(w.PartitionBy) > 0 {
+		exprs := make([]string, len(w.PartitionBy))
+		for i, expr := range w.PartitionBy {
+			exprs[i] = expr.String()
+		}
+		parts = append(parts, "PARTITION BY "+strings.Join(exprs, ", "))
+	}
+	if w.OrderBy != nil {
+		parts = append(parts, w.OrderBy.String())
+	}
+	if w.Frame != nil {
+		parts = append(parts, w.Frame.String())
+	}
+	return "(" + strings.Join(parts, " ") + ")"
+}
+
+// NamedWindow is a window of a WINDOW clause: name AS (...)
+type NamedWindow struct {
+	Name *Identifier
+	Spec *WindowSpec
+}
+
+func (n *NamedWindow) NodeType() string { return "NamedWindow" }
+func (n *NamedWindow) String() string {
+	return n.Name.String() + " AS " + n.Spec.String()
+}
+
+// FrameUnit is the unit a window frame is measured in
+type FrameUnit int
+
+const (
+	FrameRows  FrameUnit = iota // physical rows
+	FrameRange                  // ORDER BY values; peers share a frame
+)
+
+func (u FrameUnit) String() string {
+	if u == FrameRange {
+		return "RANGE"
+	}
+	return "ROWS"
+}
+
+// FrameBoundType is the kind of a window frame bound
+type FrameBoundType int
+
+const (
+	UnboundedPreceding FrameBoundType = iota
+	OffsetPreceding
+	CurrentRow
+	OffsetFollowing
+	UnboundedFollowing
+)
+
+// FrameBound is one end of a window frame. Offset is set for
+// OffsetPreceding and OffsetFollowing.
+type FrameBound struct {
+	Type   FrameBoundType
+	Offset Expression
+}
+
+func (b *FrameBound) NodeType() string { return "FrameBound" }
+func (b *FrameBound) String() string {
+	switch b.Type {
+	case UnboundedPreceding:
+		return "UNBOUNDED PRECEDING"
+	case OffsetPreceding:
+		return b.Offset.String() + " PRECEDING"
+	case OffsetFollowing:
+		return b.Offset.String() + " FOLLOWING"
+	case UnboundedFollowing:
+		return "UNBOUNDED FOLLOWING"
+	default:
+		return "CURRENT ROW"
+	}
+}
+
+// WindowFrame represents ROWS|RANGE BETWEEN start AND end. The short form
+// ROWS start is parsed with End set to CURRENT ROW.
+type WindowFrame struct {
+	Unit  FrameUnit
+	Start *FrameBound
+	End   *FrameBound
+}
+
+func (f *WindowFrame) NodeType() string { return "WindowFrame" }
+func (f *WindowFrame) String() string {
+	return fmt.Sprintf("%s BETWEEN %s AND %s", f.Unit, f.Start, f.End)
+}
+
 // ColumnReference represents qualified column references (table.column)
 type ColumnReference struct {
 	Table  *Identifier
@@ -762,4 +1776,186 @@ func (w *Wildcard) String() string {
 		return w.Table.String() + ".*"
 	}
 	return "*"
-}
\ No newline at end of file
+}
+
+// SubqueryExpression represents a parenthesized SELECT: a scalar subquery
+// used as a value, the right side of IN, or a derived table in FROM
+type SubqueryExpression struct {
+	Query   *SelectStatement
+	Alias   *Identifier   // name of a derived table
+	Columns []*Identifier // optional names for the derived table's columns
+}
+
+func (s *SubqueryExpression) ExpressionNode() {}
+func (s *SubqueryExpression) NodeType() string { return "SubqueryExpression" }
+func (s *SubqueryExpression) String() string {
+	result := "(" + s.Query.String() + ")"
+	if s.Alias != nil {
+		result += " AS " + s.Alias.String()
+	}
+	if len(s.Columns) > 0 {
+		columns := make([]string, len(s.Columns))
+		for i, col := range s.Columns {
+			columns[i] = col.String()
+		}
+		result += " (" + strings.Join(columns, ", ") + ")"
+	}
+	return result
+}
+
+// ExistsExpression represents EXISTS (SELECT ...)
+type ExistsExpression struct {
+	Query *SelectStatement
+}
+
+func (e *ExistsExpression) ExpressionNode() {}
+func (e *ExistsExpression) NodeType() string { return "ExistsExpression" }
+func (e *ExistsExpression) String() string {
+	return "EXISTS (" + e.Query.String() + ")"
+}
+
+// IsNullExpression represents expr IS [NOT] NULL
+type IsNullExpression struct {
+	Expr Expression
+	Not  bool
+}
+
+func (i *IsNullExpression) ExpressionNode() {}
+func (i *IsNullExpression) NodeType() string { return "IsNullExpression" }
+func (i *IsNullExpression) String() string {
+	if i.Not {
+		return fmt.Sprintf("(%s IS NOT NULL)", i.Expr.String())
+	}
+	return fmt.Sprintf("(%s IS NULL)", i.Expr.String())
+}
+
+// BetweenExpression represents expr [NOT] BETWEEN lower AND upper
+type BetweenExpression struct {
+	Expr  Expression
+	Lower Expression
+	Upper Expression
+	Not   bool
+}
+
+func (b *BetweenExpression) ExpressionNode() {}
+func (b *BetweenExpression) NodeType() string { return "BetweenExpression" }
+func (b *BetweenExpression) String() string {
+	op := "BETWEEN"
+	if b.Not {
+		op = "NOT BETWEEN"
+	}
+	return fmt.Sprintf("(%s %s %s AND %s)", b.Expr.String(), op, b.Lower.String(), b.Upper.String())
+}
+
+// InListExpression represents expr [NOT] IN (value, ...). IN with a
+// subquery is a BinaryExpression whose right operand is the subquery.
+type InListExpression struct {
+	Expr Expression
+	List []Expression
+	Not  bool
+}
+
+func (i *InListExpression) ExpressionNode() {}
+func (i *InListExpression) NodeType() string { return "InListExpression" }
+func (i *InListExpression) String() string {
+	values := make([]string, len(i.List))
+	for j, value := range i.List {
+		values[j] = value.String()
+	}
+	op := "IN"
+	if i.Not {
+		op = "NOT IN"
+	}
+	return fmt.Sprintf("(%s %s (%s))", i.Expr.String(), op, strings.Join(values, ", "))
+}
+
+// LikeExpression represents expr [NOT] LIKE pattern [ESCAPE escape]
+type LikeExpression struct {
+	Expr    Expression
+	Pattern Expression
+	Escape  Expression
+	Not     bool
+}
+
+func (l *LikeExpression) ExpressionNode() {}
+func (l *LikeExpression) NodeType() string { return "LikeExpression" }
+func (l *LikeExpression) String() string {
+	op := "LIKE"
+	if l.Not {
+		op = "NOT LIKE"
+	}
+	if l.Escape != nil {
+		return fmt.Sprintf("(%s %s %s ESCAPE %s)", l.Expr.String(), op, l.Pattern.String(), l.Escape.String())
+	}
+	return fmt.Sprintf("(%s %s %s)", l.Expr.String(), op, l.Pattern.String())
+}
+
+// CaseExpression represents CASE [operand] WHEN ... THEN ... [ELSE ...] END.
+// In a simple CASE, Operand is compared with each WHEN value; in a searched
+// CASE, Operand is nil and each WHEN is a condition.
+type CaseExpression struct {
+	Operand Expression
+	Whens   []*WhenClause
+	Else    Expression
+}
+
+func (c *CaseExpression) ExpressionNode() {}
+func (c *CaseExpression) NodeType() string { return "CaseExpression" }
+func (c *CaseExpression) String() string {
+	var result strings.Builder
+	result.WriteString("CASE")
+	if c.Operand != nil {
+		result.WriteString(" ")
+		result.WriteString(c.Operand.String())
+	}
+	for _, when := range c.Whens {
+		result.WriteString(" ")
+		result.WriteString(when.String())
+	}
+	if c.Else != nil {
+		result.WriteString(" ELSE ")
+		result.WriteString(c.Else.String())
+	}
+	result.WriteString(" END")
+	return result.String()
+}
+
+// WhenClause is one WHEN condition THEN result branch of a CASE expression
+type WhenClause struct {
+	Condition Expression
+	Result    Expression
+}
+
+func (w *WhenClause) NodeType() string { return "WhenClause" }
+func (w *WhenClause) String() string {
+	return fmt.Sprintf("WHEN %s THEN %s", w.Condition.String(), w.Result.String())
+}
+
+// CastExpression represents CAST(expr AS type)
+type CastExpression struct {
+	Expr Expression
+	Type *DataType
+}
+
+func (c *CastExpression) ExpressionNode() {}
+func (c *CastExpression) NodeType() string { return "CastExpression" }
+func (c *CastExpression) String() string {
+	return fmt.Sprintf("CAST(%s AS %s)", c.Expr.String(), c.Type.String())
+}
+
+// Parameter represents a bind parameter placeholder. Positional ? markers are
+// numbered left to right, so Index is always the 1-based position of the
+// value bound to it.
+type Parameter struct {
+	Index    int
+	Numbered bool // written as $n rather than ?
+}
+
+func (p *Parameter) ExpressionNode() {}
+func (p *Parameter) NodeType() string { return "Parameter" }
+func (p *Parameter) String() string {
+	if p.Numbered {
+		return fmt.Sprintf("$%d", p.Index)
+	}
+	return "?"
+}
@@ -0,0 +1,165 @@
+package parser
+
+import (
+	"fmt"
+	"strings"
+
+	"relational-db/internal/lexer"
+)
+
+// Pos is a position in the source text of a statement: a line and column
+// counted from 1, and a byte offset from the start of the text
+type Pos struct {
+	Line   int
+	Column int
+	Offset int
+}
+
+// IsValid reports whether the position was recorded by the parser
+func (p Pos) IsValid() bool {
+	return p.Line > 0
+}
+
+// tokenPos returns the position of a token
+func tokenPos(token lexer.Token) Pos {
+	return Pos{Line: token.Line, Column: token.Column, Offset: token.Position}
+}
+
+// ParseError is a syntax error found at a token of the source
+type ParseError struct {
+	Pos
+	Message   string
+	Expected  []string // tokens or words that would have been accepted, if known
+	Found     string   // the offending token
+	Excerpt   string   // the source line holding the token, with a caret under it
+	Statement int      // 1-based index of the script statement holding the error, 0 outside scripts
+}
+
+// Error implements the error interface
+func (e *ParseError) Error() string {
+	if e.Statement > 0 {
+		return fmt.Sprintf("statement %d at line %d, column %d: %s", e.Statement, e.Line, e.Column, e.Message)
+	}
+	return fmt.Sprintf("Parse error at line %d, column %d: %s", e.Line, e.Column, e.Message)
+}
+
+// Detail returns the error followed by the excerpt of the source pointing
+// at it
+func (e *ParseError) Detail() string {
+	if e.Excerpt == "" {
+		return e.Error()
+	}
+	return e.Error() + "\n" + e.Excerpt
+}
+
+// ParseErrors is the list of errors found parsing a statement or script,
+// in source order
+type ParseErrors []*ParseError
+
+// Error implements the error interface
+func (e ParseErrors) Error() string {
+	messages := make([]string, len(e))
+	for i, err := range e {
+		messages[i] = err.Error()
+	}
+	return fmt.Sprintf("parse errors: %v", messages)
+}
+
+// sourceExcerpt returns the line of source holding offset, and under it a
+// caret pointing at the offset. Tabs before the offset are kept so the
+// caret lines up however the tabs are displayed.
+func sourceExcerpt(source string, offset int) string {
+	if source == "" {
+		return ""
+	}
+	if offset > len(source) {
+		offset = len(source)
+	}
+	if offset < 0 {
+		offset = 0
+	}
+
+	start := strings.LastIndexByte(source[:offset], '\n') + 1
+	end := strings.IndexByte(source[offset:], '\n')
+	if end < 0 {
+		end = len(source)
+	} else {
+		end += offset
+	}
+	line := strings.TrimRight(source[start:end], "\r")
+
+	var caret strings.Builder
+	for _, c := range source[start:offset] {
+		if c == '\t' {
+			caret.WriteByte('\t')
+		} else {
+			caret.WriteByte(' ')
+		}
+	}
+	caret.WriteByte('^')
+	return line + "\n" + caret.String()
+}
+
+// describeToken returns how an error names a token: the text of a name,
+// number or string, otherwise its type
+func describeToken(token lexer.Token) string {
+	switch token.Type {
+	case lexer.IDENTIFIER, lexer.NUMBER:
+		return token.Value
+	case lexer.STRING:
+		return "'" + token.Value + "'"
+	case lexer.HEX_STRING:
+		return "X'" + token.Value + "'"
+	default:
+		return token.Type.String()
+	}
+}
+
+// alternatives joins the tokens or words an error expected as in
+// "A, B or C"
+func alternatives(expected []string) string {
+	switch len(expected) {
+	case 0:
+		return ""
+	case 1:
+		return expected[0]
+	default:
+		return strings.Join(expected[:len(expected)-1], ", ") + " or " + expected[len(expected)-1]
+	}
+}
+
+// PositionOf returns the source position of an expression: the position of
+// its first name or literal. It returns the zero Pos when the expression
+// holds none the parser recorded a position for.
+func PositionOf(expr Node) Pos {
+	// An absent name, literal or query has no position
+	switch n := expr.(type) {
+	case *Identifier:
+		if n == nil {
+			return Pos{}
+		}
+	case *Literal:
+		if n == nil {
+			return Pos{}
+		}
+	case *SelectStatement:
+		if n == nil {
+			return Pos{}
+		}
+	}
+
+	var pos Pos
+	Inspect(expr, func(node Node) bool {
+		if pos.IsValid() {
+			return false
+		}
+		switch n := node.(type) {
+		case *Identifier:
+			pos = n.Pos
+		case *Literal:
+			pos = n.Pos
+		}
+		return !pos.IsValid()
+	})
+	return pos
+}
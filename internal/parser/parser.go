@@ -1,8 +1,10 @@
 package parser
 
 import (
+	"encoding/hex"
 	"fmt"
 	"strconv"
+	"strings"
 
 	"relational-db/internal/lexer"
 )
@@ -12,14 +14,29 @@ type Parser struct {
 	lexer        *lexer.Lexer
 	currentToken lexer.Token
 	peekToken    lexer.Token
-	errors       []string
+	errors       []*ParseError
+
+	// Set after an error until the parser resynchronizes at the end of the
+	// statement, so one mistake is not reported again by every rule that
+	// fails because of it
+	panicking bool
+
+	// Bind parameters seen so far: the highest index referenced, and
+	// which placeholder styles (? or $n) the statement uses
+	parameters      int
+	positionalParam bool
+	numberedParam   bool
+
+	// Set while parsing the operand of CAST, where AS introduces the
+	// target type rather than an alias
+	castOperand bool
 }
 
 // NewParser creates a new parser instance
 func NewParser(l *lexer.Lexer) *Parser {
 	p := &Parser{
 		lexer:  l,
-		errors: []string{},
+		errors: []*ParseError{},
 	}
 
 	// Read two tokens, so currentToken and peekToken are both set
@@ -29,33 +46,124 @@ func NewParser(l *lexer.Lexer) *Parser {
 	return p
 }
 
-// nextToken advances both currentToken and peekToken
+// nextToken advances both currentToken and peekToken, skipping comments
 func (p *Parser) nextToken() {
 	p.currentToken = p.peekToken
 	p.peekToken = p.lexer.NextToken()
+	for p.peekToken.Type == lexer.COMMENT {
+		p.peekToken = p.lexer.NextToken()
+	}
 }
 
 // Errors returns any parsing errors
 func (p *Parser) Errors() []string {
-	return p.errors
+	messages := make([]string, len(p.errors))
+	for i, err := range p.errors {
+		messages[i] = err.Error()
+	}
+	return messages
+}
+
+// ParseErrors returns any parsing errors with their positions in the source
+func (p *Parser) ParseErrors() ParseErrors {
+	return ParseErrors(p.errors)
 }
 
-// addError adds an error message
+// ParameterCount returns the number of bind parameters the parsed statement
+// expects
+func (p *Parser) ParameterCount() int {
+	return p.parameters
+}
+
+// addError adds an error at the current token
 func (p *Parser) addError(msg string) {
-	p.errors = append(p.errors, fmt.Sprintf("Parse error at line %d, column %d: %s",
-		p.currentToken.Line, p.currentToken.Column, msg))
+	p.addErrorExpecting(msg, nil)
+}
+
+// addErrorExpecting adds an error at the current token, recording the
+// tokens or words that would have been accepted there. Only the first
+// error of a statement is kept; see panicking.
+func (p *Parser) addErrorExpecting(msg string, expected []string) {
+	if p.panicking {
+		return
+	}
+	p.panicking = true
+
+	p.errors = append(p.errors, &ParseError{
+		Pos:      tokenPos(p.currentToken),
+		Message:  msg,
+		Expected: expected,
+		Found:    describeToken(p.currentToken),
+		Excerpt:  sourceExcerpt(p.lexer.Source(), p.currentToken.Position),
+	})
+}
+
+// addErrorAt adds an error at a name read earlier in the statement
+func (p *Parser) addErrorAt(name *Identifier, msg string) {
+	if p.panicking {
+		return
+	}
+	p.panicking = true
+
+	p.errors = append(p.errors, &ParseError{
+		Pos:     name.Pos,
+		Message: msg,
+		Found:   name.Value,
+		Excerpt: sourceExcerpt(p.lexer.Source(), name.Pos.Offset),
+	})
+}
+
+// expectedError adds an error saying which tokens or words were expected
+// instead of the current token. context, if not empty, follows them in the
+// message, as in "expected VIEW after MATERIALIZED, got IDENTIFIER".
+func (p *Parser) expectedError(context string, expected ...string) {
+	msg := "expected " + alternatives(expected)
+	if context != "" {
+		msg += " " + context
+	}
+	p.addErrorExpecting(fmt.Sprintf("%s, got %s", msg, p.currentToken.Type.String()), expected)
 }
 
 // expectToken checks if current token matches expected type and advances
 func (p *Parser) expectToken(expectedType lexer.TokenType) bool {
 	if p.currentToken.Type != expectedType {
-		p.addError(fmt.Sprintf("expected %s, got %s", expectedType.String(), p.currentToken.Type.String()))
+		p.expectedError("", expectedType.String())
 		return false
 	}
 	p.nextToken()
 	return true
 }
 
+// expectStatementEnd reports an error unless the statement just parsed is
+// followed by a semicolon or the end of the input
+func (p *Parser) expectStatementEnd() {
+	if !p.currentTokenIs(lexer.SEMICOLON) && !p.currentTokenIs(lexer.EOF) {
+		p.addError(fmt.Sprintf("unexpected token %s after statement", p.currentToken.Type.String()))
+	}
+}
+
+// expectEnd reports an error unless the statement just parsed, with an
+// optional semicolon, is all of the input. Scripts of several statements
+// are parsed by ParseScript.
+func (p *Parser) expectEnd() {
+	p.expectStatementEnd()
+	if p.currentTokenIs(lexer.SEMICOLON) {
+		p.nextToken()
+		if !p.currentTokenIs(lexer.EOF) {
+			p.addError(fmt.Sprintf("unexpected token %s after statement", p.currentToken.Type.String()))
+		}
+	}
+}
+
+// synchronize recovers from an error by skipping to the end of the
+// statement, leaving the parser on the terminating semicolon or EOF
+func (p *Parser) synchronize() {
+	for !p.currentTokenIs(lexer.SEMICOLON) && !p.currentTokenIs(lexer.EOF) {
+		p.nextToken()
+	}
+	p.panicking = false
+}
+
 // currentTokenIs checks if current token matches the given type
 func (p *Parser) currentTokenIs(tokenType lexer.TokenType) bool {
 	return p.currentToken.Type == tokenType
@@ -66,11 +174,17 @@ func (p *Parser) peekTokenIs(tokenType lexer.TokenType) bool {
 	return p.peekToken.Type == tokenType
 }
 
+// currentWordIs checks if the current token is an identifier spelling a
+// contextual keyword such as RESET or PARTITION
+func (p *Parser) currentWordIs(word string) bool {
+	return p.currentToken.Type == lexer.IDENTIFIER && strings.EqualFold(p.currentToken.Value, word)
+}
+
 // ParseStatement parses a complete SQL statement
 func (p *Parser) ParseStatement() Statement {
 	switch p.currentToken.Type {
-	case lexer.SELECT:
-		return p.parseSelectStatement()
+	case lexer.SELECT, lexer.WITH, lexer.LPAREN:
+		return p.parseQuery()
 	case lexer.INSERT:
 		return p.parseInsertStatement()
 	case lexer.UPDATE:
@@ -81,13 +195,325 @@ func (p *Parser) ParseStatement() Statement {
 		return p.parseCreateStatement()
 	case lexer.DROP:
 		return p.parseDropStatement()
+	case lexer.ALTER:
+		return p.parseAlterStatement()
+	case lexer.IDENTIFIER:
+		// Transaction control, REFRESH and MERGE keywords are contextual
+		switch {
+		case p.currentWordIs("BEGIN"), p.currentWordIs("START"):
+			return p.parseBeginStatement()
+		case p.currentWordIs("COMMIT"):
+			p.nextToken()
+			p.skipTransactionWord()
+			return &CommitStatement{}
+		case p.currentWordIs("ROLLBACK"):
+			return p.parseRollbackStatement()
+		case p.currentWordIs("SAVEPOINT"):
+			p.nextToken()
+			if name := p.parseIdentifier(); name != nil {
+				return &SavepointStatement{Name: name}
+			}
+			return nil
+		case p.currentWordIs("RELEASE"):
+			return p.parseReleaseSavepointStatement()
+		case p.currentWordIs("REFRESH"):
+			return p.parseRefreshStatement()
+		case p.currentWordIs("MERGE"):
+			return p.parseMergeStatement()
+		}
+		p.addError(fmt.Sprintf("unexpected identifier %s", p.currentToken.Value))
+		return nil
 	default:
 		p.addError(fmt.Sprintf("unexpected token %s", p.currentToken.Type.String()))
 		return nil
 	}
 }
 
-// parseSelectStatement parses SELECT statements
+// parseBeginStatement parses BEGIN [TRANSACTION | WORK] [ISOLATION LEVEL level]
+// and START TRANSACTION [ISOLATION LEVEL level]
+func (p *Parser) parseBeginStatement() *BeginStatement {
+	if p.currentWordIs("START") {
+		p.nextToken()
+		if !p.currentWordIs("TRANSACTION") {
+			p.expectedError("after START", "TRANSACTION")
+			return nil
+		}
+	} else {
+		p.nextToken() // consume BEGIN
+	}
+	p.skipTransactionWord()
+
+	stmt := &BeginStatement{}
+	if !p.currentWordIs("ISOLATION") {
+		return stmt
+	}
+	p.nextToken()
+	if !p.currentWordIs("LEVEL") {
+		p.expectedError("after ISOLATION", "LEVEL")
+		return nil
+	}
+	p.nextToken()
+
+	switch {
+	case p.currentWordIs("SERIALIZABLE"):
+		stmt.IsolationLevel = IsolationSerializable
+	case p.currentWordIs("REPEATABLE"):
+		p.nextToken()
+		if !p.currentWordIs("READ") {
+			p.expectedError("after REPEATABLE", "READ")
+			return nil
+		}
+		stmt.IsolationLevel = IsolationRepeatableRead
+	case p.currentWordIs("READ"):
+		p.nextToken()
+		switch {
+		case p.currentWordIs("COMMITTED"):
+			stmt.IsolationLevel = IsolationReadCommitted
+		case p.currentWordIs("UNCOMMITTED"):
+			stmt.IsolationLevel = IsolationReadUncommitted
+		default:
+			p.expectedError("after READ", "COMMITTED", "UNCOMMITTED")
+			return nil
+		}
+	default:
+		p.addError(fmt.Sprintf("unknown isolation level %s", p.currentToken.Value))
+		return nil
+	}
+	p.nextToken()
+
+	return stmt
+}
+
+// parseRollbackStatement parses ROLLBACK [TRANSACTION | WORK] [TO [SAVEPOINT] name]
+func (p *Parser) parseRollbackStatement() *RollbackStatement {
+	p.nextToken() // consume ROLLBACK
+	p.skipTransactionWord()
+
+	stmt := &RollbackStatement{}
+	if !p.currentWordIs("TO") {
+		return stmt
+	}
+	p.nextToken()
+	if p.currentWordIs("SAVEPOINT") {
+		p.nextToken()
+	}
+
+	if stmt.Savepoint = p.parseIdentifier(); stmt.Savepoint == nil {
+		return nil
+	}
+	return stmt
+}
+
+// parseReleaseSavepointStatement parses RELEASE [SAVEPOINT] name
+func (p *Parser) parseReleaseSavepointStatement() *ReleaseSavepointStatement {
+	p.nextToken() // consume RELEASE
+	if p.currentWordIs("SAVEPOINT") {
+		p.nextToken()
+	}
+
+	name := p.parseIdentifier()
+	if name == nil {
+		return nil
+	}
+	return &ReleaseSavepointStatement{Name: name}
+}
+
+// skipTransactionWord consumes the optional TRANSACTION or WORK noise word
+// of transaction control statements
+func (p *Parser) skipTransactionWord() {
+	if p.currentWordIs("TRANSACTION") || p.currentWordIs("WORK") {
+		p.nextToken()
+	}
+}
+
+// parseQuery parses a query: SELECT statements combined by set operations,
+// optionally preceded by a WITH clause and followed by ORDER BY and LIMIT
+func (p *Parser) parseQuery() *SelectStatement {
+	// A query nested in a CAST operand has aliases of its own
+	castOperand := p.castOperand
+	p.castOperand = false
+	defer func() { p.castOperand = castOperand }()
+
+	var with *WithClause
+	if p.currentTokenIs(lexer.WITH) {
+		with = p.parseWithClause()
+		if with == nil {
+			return nil
+		}
+	}
+
+	stmt := p.parseSetExpression()
+	if stmt == nil {
+		return nil
+	}
+
+	// A parenthesized query may bring its own clauses; each may appear once
+	if with != nil {
+		if stmt.With != nil {
+			p.addError("query has more than one WITH clause")
+			return nil
+		}
+		stmt.With = with
+	}
+	if p.currentTokenIs(lexer.ORDER) {
+		if stmt.OrderBy != nil {
+			p.addError("query has more than one ORDER BY clause")
+			return nil
+		}
+		stmt.OrderBy = p.parseOrderByClause()
+		if stmt.OrderBy != nil && !p.resolveWindows(stmt.Windows, stmt.OrderBy) {
+			return nil
+		}
+	}
+	if p.currentTokenIs(lexer.LIMIT) {
+		if stmt.Limit != nil {
+			p.addError("query has more than one LIMIT clause")
+			return nil
+		}
+		stmt.Limit = p.parseLimitClause()
+	}
+
+	return stmt
+}
+
+// parseSetExpression parses queries combined by UNION and EXCEPT, which
+// associate left
+func (p *Parser) parseSetExpression() *SelectStatement {
+	left := p.parseSetTerm()
+	for left != nil && (p.currentTokenIs(lexer.UNION) || p.currentTokenIs(lexer.EXCEPT)) {
+		operator := Union
+		if p.currentTokenIs(lexer.EXCEPT) {
+			operator = Except
+		}
+		left = p.parseSetOperation(left, operator, p.parseSetTerm)
+	}
+	return left
+}
+
+// parseSetTerm parses queries combined by INTERSECT, which binds tighter than
+// UNION and EXCEPT
+func (p *Parser) parseSetTerm() *SelectStatement {
+	left := p.parseSetOperand()
+	for left != nil && p.currentTokenIs(lexer.INTERSECT) {
+		left = p.parseSetOperation(left, Intersect, p.parseSetOperand)
+	}
+	return left
+}
+
+// parseSetOperation parses the rest of left op [ALL|DISTINCT] right, with
+// the current token on the operator
+func (p *Parser) parseSetOperation(left *SelectStatement, operator SetOperator, parseRight func() *SelectStatement) *SelectStatement {
+	p.nextToken() // consume operator
+
+	op := &SetOperation{Operator: operator, Left: left}
+	if p.currentTokenIs(lexer.ALL) {
+		op.All = true
+		p.nextToken()
+	} else if p.currentTokenIs(lexer.DISTINCT) {
+		p.nextToken()
+	}
+
+	op.Right = parseRight()
+	if op.Right == nil {
+		return nil
+	}
+	return &SelectStatement{SetOperation: op}
+}
+
+// parseSetOperand parses a SELECT without ORDER BY and LIMIT, which would
+// apply to the whole set operation, or a parenthesized query
+func (p *Parser) parseSetOperand() *SelectStatement {
+	switch p.currentToken.Type {
+	case lexer.SELECT:
+		return p.parseSelectStatement()
+
+	case lexer.LPAREN:
+		p.nextToken()
+		query := p.parseQuery()
+		if query == nil {
+			return nil
+		}
+		if !p.expectToken(lexer.RPAREN) {
+			return nil
+		}
+		return query
+
+	default:
+		p.expectedError("", "SELECT")
+		return nil
+	}
+}
+
+// parseWithClause parses WITH [RECURSIVE] cte [, ...]
+func (p *Parser) parseWithClause() *WithClause {
+	p.nextToken() // consume WITH
+
+	clause := &WithClause{}
+	if p.currentWordIs("RECURSIVE") {
+		clause.Recursive = true
+		p.nextToken()
+	}
+
+	for {
+		cte := p.parseCommonTableExpression(clause.Recursive)
+		if cte == nil {
+			return nil
+		}
+		clause.CTEs = append(clause.CTEs, cte)
+
+		if !p.currentTokenIs(lexer.COMMA) {
+			break
+		}
+		p.nextToken() // consume comma
+	}
+
+	return clause
+}
+
+// parseCommonTableExpression parses name [(col, ...)] AS (query). Under WITH
+// RECURSIVE the query may be an anchor and a recursive term joined by
+// UNION [ALL].
+func (p *Parser) parseCommonTableExpression(recursive bool) *CommonTableExpression {
+	if !p.currentTokenIs(lexer.IDENTIFIER) {
+		p.expectedError("", "common table expression name")
+		return nil
+	}
+	cte := &CommonTableExpression{Name: identifierAt(p.currentToken)}
+	p.nextToken()
+
+	if p.currentTokenIs(lexer.LPAREN) {
+		if cte.Columns = p.parseColumnNameList(); cte.Columns == nil {
+			return nil
+		}
+	}
+
+	if !p.expectToken(lexer.AS) {
+		return nil
+	}
+	if !p.expectToken(lexer.LPAREN) {
+		return nil
+	}
+
+	cte.Query = p.parseQuery()
+	if cte.Query == nil {
+		return nil
+	}
+
+	// The last UNION of a recursive CTE separates the anchor from the
+	// recursive term
+	if op := cte.Query.SetOperation; recursive && op != nil && op.Operator == Union && !needsParentheses(cte.Query) {
+		cte.Query, cte.RecursiveTerm, cte.UnionAll = op.Left, op.Right, op.All
+	}
+
+	if !p.expectToken(lexer.RPAREN) {
+		return nil
+	}
+	return cte
+}
+
+// parseSelectStatement parses a SELECT statement up to its WINDOW clause.
+// ORDER BY and LIMIT are left to parseQuery, as after a set operation they
+// apply to all of its rows.
 func (p *Parser) parseSelectStatement() *SelectStatement {
 	stmt := &SelectStatement{}
 
@@ -117,19 +543,82 @@ func (p *Parser) parseSelectStatement() *SelectStatement {
 		stmt.Having = p.parseHavingClause()
 	}
 
-	// Parse ORDER BY clause (optional)
-	if p.currentTokenIs(lexer.ORDER) {
-		stmt.OrderBy = p.parseOrderByClause()
+	// Parse WINDOW clause (optional)
+	if p.currentWordIs("WINDOW") {
+		if stmt.Windows = p.parseWindowClause(); stmt.Windows == nil {
+			return nil
+		}
 	}
-
-	// Parse LIMIT clause (optional)
-	if p.currentTokenIs(lexer.LIMIT) {
-		stmt.Limit = p.parseLimitClause()
+	if !p.resolveWindows(stmt.Windows, stmt) {
+		return nil
 	}
 
 	return stmt
 }
 
+// parseWindowClause parses WINDOW name AS (...) [, ...]
+func (p *Parser) parseWindowClause() []*NamedWindow {
+	p.nextToken() // consume WINDOW
+
+	var windows []*NamedWindow
+	for {
+		if !p.currentTokenIs(lexer.IDENTIFIER) {
+			p.expectedError("in WINDOW clause", "window name")
+			return nil
+		}
+		window := &NamedWindow{Name: identifierAt(p.currentToken)}
+		for _, other := range windows {
+			if other.Name.Value == window.Name.Value {
+				p.addError(fmt.Sprintf("window %s is defined more than once", window.Name.Value))
+				return nil
+			}
+		}
+		p.nextToken()
+
+		if !p.expectToken(lexer.AS) {
+			return nil
+		}
+		if window.Spec = p.parseWindowSpec(); window.Spec == nil {
+			return nil
+		}
+		windows = append(windows, window)
+
+		if !p.currentTokenIs(lexer.COMMA) {
+			break
+		}
+		p.nextToken() // consume comma
+	}
+	return windows
+}
+
+// resolveWindows copies the parts of the windows of a WINDOW clause into
+// the OVER clauses under node that name them. Nested queries are left out,
+// as they resolve their own. It reports whether every name was defined.
+func (p *Parser) resolveWindows(windows []*NamedWindow, node Node) bool {
+	resolved := true
+	Inspect(node, func(n Node) bool {
+		switch n := n.(type) {
+		case *SelectStatement:
+			return n == node
+		case *WindowSpec:
+			if n.Name == nil {
+				break
+			}
+			for _, window := range windows {
+				if window.Name.Value == n.Name.Value {
+					n.PartitionBy, n.OrderBy, n.Frame = window.Spec.PartitionBy, window.Spec.OrderBy, window.Spec.Frame
+					return false
+				}
+			}
+			p.addErrorAt(n.Name, fmt.Sprintf("window %s is not defined", n.Name.Value))
+			resolved = false
+			return false
+		}
+		return resolved
+	})
+	return resolved
+}
+
 // parseSelectClause parses the SELECT part
 func (p *Parser) parseSelectClause() *SelectClause {
 	if !p.expectToken(lexer.SELECT) {
@@ -167,11 +656,17 @@ func (p *Parser) parseFromClause() *FromClause {
 		return nil
 	}
 
+	return p.parseFromItems()
+}
+
+// parseFromItems parses the table list and joins of a FROM clause, or of
+// the USING clause of a DELETE
+func (p *Parser) parseFromItems() *FromClause {
 	clause := &FromClause{}
 
 	// Parse table list
 	for {
-		expr := p.parseExpression()
+		expr := p.parseTableReference()
 		if expr == nil {
 			return nil
 		}
@@ -342,7 +837,7 @@ func (p *Parser) parseJoinClause() *JoinClause {
 		return nil
 	}
 
-	table := p.parseExpression()
+	table := p.parseTableReference()
 	if table == nil {
 		return nil
 	}
@@ -399,45 +894,298 @@ func (p *Parser) parseInsertStatement() *InsertStatement {
 		}
 	}
 
-	// Parse VALUES clause
-	if !p.expectToken(lexer.VALUES) {
-		return nil
-	}
-
-	for {
-		if !p.expectToken(lexer.LPAREN) {
+	// The rows come from a query or a VALUES list of one or more rows
+	if p.currentTokenIs(lexer.SELECT) || p.currentTokenIs(lexer.WITH) {
+		if stmt.Query = p.parseQuery(); stmt.Query == nil {
 			return nil
 		}
-
-		var values []Expression
-		for {
-			expr := p.parseExpression()
-			if expr == nil {
-				return nil
-			}
-			values = append(values, expr)
-
-			if !p.currentTokenIs(lexer.COMMA) {
-				break
-			}
-			p.nextToken() // consume comma
+	} else {
+		if !p.expectToken(lexer.VALUES) {
+			return nil
 		}
-
-		if !p.expectToken(lexer.RPAREN) {
+		if stmt.Values = p.parseValuesRows(); stmt.Values == nil {
 			return nil
 		}
+	}
+
+	if p.currentTokenIs(lexer.ON) {
+		if stmt.OnConflict = p.parseOnConflictClause(); stmt.OnConflict == nil {
+			return nil
+		}
+	}
+
+	var ok bool
+	if stmt.Returning, ok = p.parseReturningClause(); !ok {
+		return nil
+	}
+	return stmt
+}
+
+// parseOnConflictClause parses
+// ON CONFLICT [(columns)] DO NOTHING | DO UPDATE SET ... [WHERE condition]
+func (p *Parser) parseOnConflictClause() *OnConflictClause {
+	p.nextToken() // consume ON
+	if !p.currentWordIs("CONFLICT") {
+		p.expectedError("after ON", "CONFLICT")
+		return nil
+	}
+	p.nextToken()
+
+	clause := &OnConflictClause{}
+	if p.currentTokenIs(lexer.LPAREN) {
+		if clause.Columns = p.parseColumnNameList(); clause.Columns == nil {
+			return nil
+		}
+	}
+
+	if !p.currentWordIs("DO") {
+		p.expectedError("in ON CONFLICT", "DO")
+		return nil
+	}
+	p.nextToken()
+
+	switch {
+	case p.currentWordIs("NOTHING"):
+		p.nextToken()
+	case p.currentTokenIs(lexer.UPDATE):
+		p.nextToken()
+		if !p.expectToken(lexer.SET) {
+			return nil
+		}
+		clause.DoUpdate = true
+		if clause.SetClauses = p.parseSetClauses(); clause.SetClauses == nil {
+			return nil
+		}
+		if p.currentTokenIs(lexer.WHERE) {
+			if clause.WhereClause = p.parseWhereClause(); clause.WhereClause == nil {
+				return nil
+			}
+		}
+	default:
+		p.expectedError("after DO", "NOTHING", "UPDATE")
+		return nil
+	}
+
+	return clause
+}
+
+// parseSetClauses parses the column = value list of an UPDATE, ON CONFLICT
+// DO UPDATE or MERGE UPDATE
+func (p *Parser) parseSetClauses() []*SetClause {
+	var clauses []*SetClause
+	for {
+		col := p.parseIdentifier()
+		if col == nil {
+			return nil
+		}
+
+		if !p.expectToken(lexer.EQUALS) {
+			return nil
+		}
+
+		value := p.parseExpression()
+		if value == nil {
+			return nil
+		}
+
+		clauses = append(clauses, &SetClause{Column: col, Value: value})
 
-		stmt.Values = append(stmt.Values, values)
-
 		if !p.currentTokenIs(lexer.COMMA) {
 			break
 		}
 		p.nextToken() // consume comma
 	}
+	return clauses
+}
+
+// parseMergeStatement parses
+// MERGE INTO target [alias] USING source [alias] ON condition WHEN ...
+func (p *Parser) parseMergeStatement() *MergeStatement {
+	p.nextToken() // consume MERGE
+	if !p.expectToken(lexer.INTO) {
+		return nil
+	}
+
+	target := p.parseIdentifier()
+	if target == nil {
+		return nil
+	}
+	if target.Alias == nil && p.currentTokenIs(lexer.IDENTIFIER) && !p.currentWordIs("USING") {
+		target.Alias = identifierAt(p.currentToken)
+		p.nextToken()
+	}
+
+	if !p.currentWordIs("USING") {
+		p.expectedError("in MERGE", "USING")
+		return nil
+	}
+	p.nextToken()
+
+	stmt := &MergeStatement{Target: target}
+	if stmt.Source = p.parseTableReference(); stmt.Source == nil {
+		return nil
+	}
+	if !p.expectToken(lexer.ON) {
+		return nil
+	}
+	if stmt.Condition = p.parseExpression(); stmt.Condition == nil {
+		return nil
+	}
+
+	for p.currentTokenIs(lexer.WHEN) {
+		clause := p.parseMergeClause()
+		if clause == nil {
+			return nil
+		}
+		stmt.Clauses = append(stmt.Clauses, clause)
+	}
+	if len(stmt.Clauses) == 0 {
+		p.expectedError("in MERGE", "WHEN")
+		return nil
+	}
 
 	return stmt
 }
 
+// parseMergeClause parses WHEN [NOT] MATCHED [AND condition] THEN action
+func (p *Parser) parseMergeClause() *MergeClause {
+	p.nextToken() // consume WHEN
+
+	clause := &MergeClause{Matched: true}
+	if p.currentTokenIs(lexer.NOT) {
+		clause.Matched = false
+		p.nextToken()
+	}
+	if !p.currentWordIs("MATCHED") {
+		p.expectedError("after WHEN", "MATCHED")
+		return nil
+	}
+	p.nextToken()
+
+	if p.currentTokenIs(lexer.AND) {
+		p.nextToken()
+		if clause.Condition = p.parseExpression(); clause.Condition == nil {
+			return nil
+		}
+	}
+	if !p.expectToken(lexer.THEN) {
+		return nil
+	}
+
+	switch {
+	case p.currentTokenIs(lexer.UPDATE) && clause.Matched:
+		p.nextToken()
+		if !p.expectToken(lexer.SET) {
+			return nil
+		}
+		clause.Action = MergeUpdate
+		if clause.SetClauses = p.parseSetClauses(); clause.SetClauses == nil {
+			return nil
+		}
+	case p.currentTokenIs(lexer.DELETE) && clause.Matched:
+		p.nextToken()
+		clause.Action = MergeDelete
+	case p.currentTokenIs(lexer.INSERT) && !clause.Matched:
+		p.nextToken()
+		clause.Action = MergeInsert
+		if p.currentTokenIs(lexer.LPAREN) {
+			if clause.Columns = p.parseColumnNameList(); clause.Columns == nil {
+				return nil
+			}
+		}
+		if !p.expectToken(lexer.VALUES) {
+			return nil
+		}
+		rows := p.parseValuesRows()
+		if rows == nil {
+			return nil
+		}
+		if len(rows) != 1 {
+			p.addError("MERGE INSERT takes a single VALUES row")
+			return nil
+		}
+		clause.Values = rows[0]
+	case p.currentWordIs("DO"):
+		p.nextToken()
+		if !p.currentWordIs("NOTHING") {
+			p.expectedError("after DO", "NOTHING")
+			return nil
+		}
+		p.nextToken()
+		clause.Action = MergeDoNothing
+	case clause.Matched:
+		p.expectedError("after WHEN MATCHED THEN", "UPDATE", "DELETE", "DO NOTHING")
+		return nil
+	default:
+		p.expectedError("after WHEN NOT MATCHED THEN", "INSERT", "DO NOTHING")
+		return nil
+	}
+
+	return clause
+}
+
+// parseValuesRows parses the rows of a VALUES list
+func (p *Parser) parseValuesRows() [][]Expression {
+	var rows [][]Expression
+	for {
+		if !p.expectToken(lexer.LPAREN) {
+			return nil
+		}
+
+		var values []Expression
+		for {
+			expr := p.parseExpression()
+			if expr == nil {
+				return nil
+			}
+			values = append(values, expr)
+
+			if !p.currentTokenIs(lexer.COMMA) {
+				break
+			}
+			p.nextToken() // consume comma
+		}
+
+		if !p.expectToken(lexer.RPAREN) {
+			return nil
+		}
+
+		rows = append(rows, values)
+
+		if !p.currentTokenIs(lexer.COMMA) {
+			break
+		}
+		p.nextToken() // consume comma
+	}
+
+	return rows
+}
+
+// parseReturningClause parses the optional RETURNING list that ends an
+// INSERT, UPDATE or DELETE. It reports false after a syntax error.
+func (p *Parser) parseReturningClause() ([]Expression, bool) {
+	if !p.currentWordIs("RETURNING") {
+		return nil, true
+	}
+	p.nextToken()
+
+	var returning []Expression
+	for {
+		expr := p.parseExpression()
+		if expr == nil {
+			return nil, false
+		}
+		returning = append(returning, expr)
+
+		if !p.currentTokenIs(lexer.COMMA) {
+			break
+		}
+		p.nextToken() // consume comma
+	}
+
+	return returning, true
+}
+
 // parseUpdateStatement parses UPDATE statements
 func (p *Parser) parseUpdateStatement() *UpdateStatement {
 	if !p.expectToken(lexer.UPDATE) {
@@ -449,88 +1197,674 @@ func (p *Parser) parseUpdateStatement() *UpdateStatement {
 		return nil
 	}
 
-	if !p.expectToken(lexer.SET) {
+	if !p.expectToken(lexer.SET) {
+		return nil
+	}
+
+	stmt := &UpdateStatement{TableName: tableName}
+
+	if stmt.SetClauses = p.parseSetClauses(); stmt.SetClauses == nil {
+		return nil
+	}
+
+	// Parse optional FROM clause joining other tables to the target
+	if p.currentTokenIs(lexer.FROM) {
+		if stmt.From = p.parseFromClause(); stmt.From == nil {
+			return nil
+		}
+	}
+
+	// Parse optional WHERE clause
+	if p.currentTokenIs(lexer.WHERE) {
+		stmt.WhereClause = p.parseWhereClause()
+	}
+
+	var ok bool
+	if stmt.Returning, ok = p.parseReturningClause(); !ok {
+		return nil
+	}
+	return stmt
+}
+
+// parseDeleteStatement parses DELETE statements
+func (p *Parser) parseDeleteStatement() *DeleteStatement {
+	if !p.expectToken(lexer.DELETE) {
+		return nil
+	}
+	if !p.expectToken(lexer.FROM) {
+		return nil
+	}
+
+	tableName := p.parseIdentifier()
+	if tableName == nil {
+		return nil
+	}
+
+	stmt := &DeleteStatement{TableName: tableName}
+
+	// Parse optional USING clause joining other tables to the target
+	if p.currentWordIs("USING") {
+		p.nextToken()
+		if stmt.Using = p.parseFromItems(); stmt.Using == nil {
+			return nil
+		}
+	}
+
+	// Parse optional WHERE clause
+	if p.currentTokenIs(lexer.WHERE) {
+		stmt.WhereClause = p.parseWhereClause()
+	}
+
+	var ok bool
+	if stmt.Returning, ok = p.parseReturningClause(); !ok {
+		return nil
+	}
+	return stmt
+}
+
+// parseCreateStatement parses CREATE statements
+func (p *Parser) parseCreateStatement() Statement {
+	if !p.expectToken(lexer.CREATE) {
+		return nil
+	}
+
+	if p.currentTokenIs(lexer.TABLE) {
+		return p.parseCreateTableStatement()
+	}
+
+	if p.currentTokenIs(lexer.INDEX) || p.currentTokenIs(lexer.UNIQUE) {
+		return p.parseCreateIndexStatement()
+	}
+
+	if p.currentTokenIs(lexer.OR) || p.currentWordIs("VIEW") || p.currentWordIs("MATERIALIZED") {
+		return p.parseCreateViewStatement()
+	}
+
+	p.addError("only CREATE TABLE, CREATE INDEX and CREATE VIEW are supported")
+	return nil
+}
+
+// parseCreateViewStatement parses
+// CREATE [OR REPLACE] [MATERIALIZED] VIEW [IF NOT EXISTS] name [(columns)]
+// AS query [WITH [NO] DATA]
+func (p *Parser) parseCreateViewStatement() *CreateViewStatement {
+	stmt := &CreateViewStatement{}
+	if p.currentTokenIs(lexer.OR) {
+		p.nextToken()
+		if !p.currentWordIs("REPLACE") {
+			p.expectedError("after OR", "REPLACE")
+			return nil
+		}
+		p.nextToken()
+		stmt.OrReplace = true
+	}
+
+	if p.currentWordIs("MATERIALIZED") {
+		p.nextToken()
+		stmt.Materialized = true
+	}
+
+	if !p.currentWordIs("VIEW") {
+		p.expectedError("", "VIEW")
+		return nil
+	}
+	p.nextToken()
+
+	if stmt.OrReplace && stmt.Materialized {
+		p.addError("OR REPLACE is not supported for materialized views")
+		return nil
+	}
+
+	// Check for IF NOT EXISTS
+	if p.currentTokenIs(lexer.IF) {
+		p.nextToken()
+		if !p.expectToken(lexer.NOT) || !p.expectToken(lexer.EXISTS) {
+			return nil
+		}
+		stmt.IfNotExists = true
+	}
+
+	// The name is followed by AS, which must not be read as an alias
+	if !p.currentTokenIs(lexer.IDENTIFIER) {
+		p.expectedError("", "view name")
+		return nil
+	}
+	stmt.ViewName = identifierAt(p.currentToken)
+	p.nextToken()
+
+	if p.currentTokenIs(lexer.LPAREN) {
+		if stmt.Columns = p.parseColumnNameList(); stmt.Columns == nil {
+			return nil
+		}
+	}
+
+	if !p.expectToken(lexer.AS) {
+		return nil
+	}
+
+	if stmt.Query = p.parseQuery(); stmt.Query == nil {
+		return nil
+	}
+
+	if p.currentTokenIs(lexer.WITH) {
+		if !stmt.Materialized {
+			p.addError("WITH DATA is only allowed for materialized views")
+			return nil
+		}
+		p.nextToken()
+		if p.currentTokenIs(lexer.NOT) || p.currentWordIs("NO") {
+			p.nextToken()
+			stmt.WithNoData = true
+		}
+		if !p.currentWordIs("DATA") {
+			p.expectedError("", "DATA")
+			return nil
+		}
+		p.nextToken()
+	}
+
+	return stmt
+}
+
+// parseRefreshStatement parses REFRESH MATERIALIZED VIEW [CONCURRENTLY] name
+func (p *Parser) parseRefreshStatement() *RefreshMaterializedViewStatement {
+	p.nextToken() // consume REFRESH
+	if !p.currentWordIs("MATERIALIZED") {
+		p.expectedError("after REFRESH", "MATERIALIZED")
+		return nil
+	}
+	p.nextToken()
+	if !p.currentWordIs("VIEW") {
+		p.expectedError("after MATERIALIZED", "VIEW")
+		return nil
+	}
+	p.nextToken()
+
+	stmt := &RefreshMaterializedViewStatement{}
+	if p.currentWordIs("CONCURRENTLY") {
+		p.nextToken()
+		stmt.Concurrently = true
+	}
+
+	if stmt.ViewName = p.parseIdentifier(); stmt.ViewName == nil {
+		return nil
+	}
+	return stmt
+}
+
+// parseCreateIndexStatement parses
+// CREATE [UNIQUE] INDEX [IF NOT EXISTS] name ON table (columns) [USING method]
+func (p *Parser) parseCreateIndexStatement() *CreateIndexStatement {
+	stmt := &CreateIndexStatement{}
+	if p.currentTokenIs(lexer.UNIQUE) {
+		stmt.Unique = true
+		p.nextToken()
+	}
+
+	if !p.expectToken(lexer.INDEX) {
+		return nil
+	}
+
+	// Check for IF NOT EXISTS
+	if p.currentTokenIs(lexer.IF) {
+		p.nextToken()
+		if !p.expectToken(lexer.NOT) || !p.expectToken(lexer.EXISTS) {
+			return nil
+		}
+		stmt.IfNotExists = true
+	}
+
+	if stmt.IndexName = p.parseIdentifier(); stmt.IndexName == nil {
+		return nil
+	}
+
+	if !p.expectToken(lexer.ON) {
+		return nil
+	}
+
+	if stmt.TableName = p.parseIdentifier(); stmt.TableName == nil {
+		return nil
+	}
+
+	if !p.expectToken(lexer.LPAREN) {
+		return nil
+	}
+
+	for {
+		col := p.parseIdentifier()
+		if col == nil {
+			return nil
+		}
+		stmt.Columns = append(stmt.Columns, col)
+
+		if !p.currentTokenIs(lexer.COMMA) {
+			break
+		}
+		p.nextToken() // consume comma
+	}
+
+	if !p.expectToken(lexer.RPAREN) {
+		return nil
+	}
+
+	if p.currentWordIs("USING") {
+		p.nextToken()
+		if stmt.Using = p.parseIdentifier(); stmt.Using == nil {
+			return nil
+		}
+	}
+
+	return stmt
+}
+
+// parseCreateTableStatement parses CREATE TABLE statements
+func (p *Parser) parseCreateTableStatement() *CreateTableStatement {
+	if !p.expectToken(lexer.TABLE) {
+		return nil
+	}
+
+	tableName := p.parseIdentifier()
+	if tableName == nil {
+		return nil
+	}
+
+	if !p.expectToken(lexer.LPAREN) {
+		return nil
+	}
+
+	stmt := &CreateTableStatement{TableName: tableName}
+
+	for {
+		// Parse column definition or table constraint
+		if p.currentTokenIs(lexer.CONSTRAINT) || p.currentTokenIs(lexer.PRIMARY) ||
+			p.currentTokenIs(lexer.FOREIGN) || p.currentTokenIs(lexer.UNIQUE) ||
+			(p.currentWordIs("CHECK") && p.peekTokenIs(lexer.LPAREN)) {
+			// Table constraint
+			constraint := p.parseTableConstraint()
+			if constraint == nil {
+				return nil
+			}
+			stmt.Constraints = append(stmt.Constraints, constraint)
+		} else {
+			// Column definition
+			colDef := p.parseColumnDefinition()
+			if colDef == nil {
+				return nil
+			}
+			stmt.Columns = append(stmt.Columns, colDef)
+		}
+
+		if !p.currentTokenIs(lexer.COMMA) {
+			break
+		}
+		p.nextToken() // consume comma
+	}
+
+	if !p.expectToken(lexer.RPAREN) {
+		return nil
+	}
+
+	// Parse partitioning (optional)
+	if p.currentWordIs("PARTITION") {
+		spec := p.parsePartitionSpec()
+		if spec == nil {
+			return nil
+		}
+		stmt.PartitionBy = spec
+	}
+
+	// Parse table options (optional)
+	if p.currentTokenIs(lexer.WITH) {
+		options := p.parseTableOptions()
+		if options == nil {
+			return nil
+		}
+		stmt.Options = options
+	}
+
+	return stmt
+}
+
+// parsePartitionSpec parses
+//
+//	PARTITION BY RANGE (col) (PARTITION name VALUES LESS THAN (expr | MAXVALUE), ...)
+//	PARTITION BY LIST (col) (PARTITION name VALUES IN (expr, ...), ...)
+//	PARTITION BY HASH (col) PARTITIONS n | (PARTITION name, ...)
+func (p *Parser) parsePartitionSpec() *PartitionSpec {
+	p.nextToken() // consume PARTITION
+	if !p.expectToken(lexer.BY) {
+		return nil
+	}
+
+	spec := &PartitionSpec{}
+	switch {
+	case p.currentWordIs("RANGE"):
+		spec.Method = PartitionByRange
+	case p.currentWordIs("LIST"):
+		spec.Method = PartitionByList
+	case p.currentWordIs("HASH"):
+		spec.Method = PartitionByHash
+	default:
+		p.addErrorExpecting(fmt.Sprintf("expected RANGE, LIST or HASH, got %s", p.currentToken.Value), []string{"RANGE", "LIST", "HASH"})
+		return nil
+	}
+	p.nextToken()
+
+	if !p.expectToken(lexer.LPAREN) {
+		return nil
+	}
+	spec.Column = p.parseIdentifier()
+	if spec.Column == nil {
+		return nil
+	}
+	if !p.expectToken(lexer.RPAREN) {
+		return nil
+	}
+
+	// HASH partitions may be given as a count instead of a list
+	if spec.Method == PartitionByHash && p.currentWordIs("PARTITIONS") {
+		p.nextToken()
+		if !p.currentTokenIs(lexer.NUMBER) {
+			p.expectedError("", "partition count")
+			return nil
+		}
+		count, err := strconv.Atoi(p.currentToken.Value)
+		if err != nil || count <= 0 {
+			p.addError(fmt.Sprintf("invalid partition count %s", p.currentToken.Value))
+			return nil
+		}
+		spec.Count = count
+		p.nextToken()
+		return spec
+	}
+
+	if !p.expectToken(lexer.LPAREN) {
+		return nil
+	}
+
+	for {
+		partition := p.parsePartitionDefinition(spec.Method)
+		if partition == nil {
+			return nil
+		}
+		spec.Partitions = append(spec.Partitions, partition)
+
+		if !p.currentTokenIs(lexer.COMMA) {
+			break
+		}
+		p.nextToken() // consume comma
+	}
+
+	if !p.expectToken(lexer.RPAREN) {
+		return nil
+	}
+
+	return spec
+}
+
+// parsePartitionDefinition parses a single PARTITION entry
+func (p *Parser) parsePartitionDefinition(method PartitionMethod) *PartitionDefinition {
+	if !p.currentWordIs("PARTITION") {
+		p.addErrorExpecting(fmt.Sprintf("expected PARTITION, got %s", p.currentToken.Value), []string{"PARTITION"})
+		return nil
+	}
+	p.nextToken()
+
+	partition := &PartitionDefinition{Name: p.parseIdentifier()}
+	if partition.Name == nil {
+		return nil
+	}
+
+	switch method {
+	case PartitionByRange:
+		if !p.expectToken(lexer.VALUES) {
+			return nil
+		}
+		if !p.currentWordIs("LESS") {
+			p.expectedError("", "LESS THAN")
+			return nil
+		}
+		p.nextToken()
+		if !p.currentWordIs("THAN") {
+			p.expectedError("", "LESS THAN")
+			return nil
+		}
+		p.nextToken()
+
+		if p.currentWordIs("MAXVALUE") {
+			p.nextToken()
+			partition.MaxValue = true
+			return partition
+		}
+
+		if !p.expectToken(lexer.LPAREN) {
+			return nil
+		}
+		if p.currentWordIs("MAXVALUE") {
+			p.nextToken()
+			partition.MaxValue = true
+		} else {
+			partition.LessThan = p.parseExpression()
+			if partition.LessThan == nil {
+				return nil
+			}
+		}
+		if !p.expectToken(lexer.RPAREN) {
+			return nil
+		}
+
+	case PartitionByList:
+		if !p.expectToken(lexer.VALUES) {
+			return nil
+		}
+		if !p.expectToken(lexer.IN) {
+			return nil
+		}
+		if !p.expectToken(lexer.LPAREN) {
+			return nil
+		}
+		for {
+			value := p.parseExpression()
+			if value == nil {
+				return nil
+			}
+			partition.Values = append(partition.Values, value)
+
+			if !p.currentTokenIs(lexer.COMMA) {
+				break
+			}
+			p.nextToken() // consume comma
+		}
+		if !p.expectToken(lexer.RPAREN) {
+			return nil
+		}
+	}
+
+	return partition
+}
+
+// parseTableOptions parses WITH (name [= value], ...) after CREATE TABLE
+func (p *Parser) parseTableOptions() []*TableOption {
+	if !p.expectToken(lexer.WITH) {
+		return nil
+	}
+
+	return p.parseTableOptionList()
+}
+
+// parseTableOptionList parses a parenthesized list of name [= value] options
+func (p *Parser) parseTableOptionList() []*TableOption {
+	if !p.expectToken(lexer.LPAREN) {
+		return nil
+	}
+
+	var options []*TableOption
+	for {
+		if !p.currentTokenIs(lexer.IDENTIFIER) {
+			p.expectedError("", "table option name")
+			return nil
+		}
+		option := &TableOption{Name: identifierAt(p.currentToken)}
+		p.nextToken()
+
+		if p.currentTokenIs(lexer.EQUALS) {
+			p.nextToken()
+			option.Value = p.parseExpression()
+			if option.Value == nil {
+				return nil
+			}
+		}
+		options = append(options, option)
+
+		if !p.currentTokenIs(lexer.COMMA) {
+			break
+		}
+		p.nextToken() // consume comma
+	}
+
+	if !p.expectToken(lexer.RPAREN) {
+		return nil
+	}
+
+	return options
+}
+
+// parseDropStatement parses DROP statements
+func (p *Parser) parseDropStatement() Statement {
+	if !p.expectToken(lexer.DROP) {
+		return nil
+	}
+
+	if p.currentTokenIs(lexer.TABLE) {
+		return p.parseDropTableStatement()
+	}
+
+	if p.currentTokenIs(lexer.INDEX) {
+		return p.parseDropIndexStatement()
+	}
+
+	if p.currentWordIs("VIEW") || p.currentWordIs("MATERIALIZED") {
+		return p.parseDropViewStatement()
+	}
+
+	p.addError("only DROP TABLE, DROP INDEX and DROP VIEW are supported")
+	return nil
+}
+
+// parseDropViewStatement parses
+// DROP [MATERIALIZED] VIEW [IF EXISTS] name [CASCADE | RESTRICT]
+func (p *Parser) parseDropViewStatement() *DropViewStatement {
+	stmt := &DropViewStatement{}
+	if p.currentWordIs("MATERIALIZED") {
+		p.nextToken()
+		stmt.Materialized = true
+	}
+	if !p.currentWordIs("VIEW") {
+		p.expectedError("", "VIEW")
+		return nil
+	}
+	p.nextToken()
+
+	// Check for IF EXISTS
+	if p.currentTokenIs(lexer.IF) {
+		p.nextToken()
+		if !p.expectToken(lexer.EXISTS) {
+			return nil
+		}
+		stmt.IfExists = true
+	}
+
+	if stmt.ViewName = p.parseIdentifier(); stmt.ViewName == nil {
+		return nil
+	}
+
+	stmt.Cascade = p.parseDropBehavior()
+	return stmt
+}
+
+// parseDropBehavior parses an optional CASCADE or RESTRICT and reports
+// whether dependent objects are dropped too
+func (p *Parser) parseDropBehavior() bool {
+	switch {
+	case p.currentWordIs("CASCADE"):
+		p.nextToken()
+		return true
+	case p.currentWordIs("RESTRICT"):
+		p.nextToken()
+	}
+	return false
+}
+
+// parseDropIndexStatement parses DROP INDEX [IF EXISTS] name
+func (p *Parser) parseDropIndexStatement() *DropIndexStatement {
+	if !p.expectToken(lexer.INDEX) {
 		return nil
 	}
 
-	stmt := &UpdateStatement{TableName: tableName}
-
-	// Parse SET clauses
-	for {
-		col := p.parseIdentifier()
-		if col == nil {
-			return nil
-		}
-
-		if !p.expectToken(lexer.EQUALS) {
-			return nil
-		}
+	stmt := &DropIndexStatement{}
 
-		value := p.parseExpression()
-		if value == nil {
+	// Check for IF EXISTS
+	if p.currentTokenIs(lexer.IF) {
+		p.nextToken()
+		if !p.expectToken(lexer.EXISTS) {
 			return nil
 		}
-
-		setClause := &SetClause{
-			Column: col,
-			Value:  value,
-		}
-		stmt.SetClauses = append(stmt.SetClauses, setClause)
-
-		if !p.currentTokenIs(lexer.COMMA) {
-			break
-		}
-		p.nextToken() // consume comma
+		stmt.IfExists = true
 	}
 
-	// Parse optional WHERE clause
-	if p.currentTokenIs(lexer.WHERE) {
-		stmt.WhereClause = p.parseWhereClause()
+	if stmt.IndexName = p.parseIdentifier(); stmt.IndexName == nil {
+		return nil
 	}
 
 	return stmt
 }
 
-// parseDeleteStatement parses DELETE statements
-func (p *Parser) parseDeleteStatement() *DeleteStatement {
-	if !p.expectToken(lexer.DELETE) {
+// parseDropTableStatement parses DROP TABLE statements
+func (p *Parser) parseDropTableStatement() *DropTableStatement {
+	if !p.expectToken(lexer.TABLE) {
 		return nil
 	}
-	if !p.expectToken(lexer.FROM) {
-		return nil
+
+	stmt := &DropTableStatement{}
+
+	// Check for IF EXISTS
+	if p.currentTokenIs(lexer.IF) {
+		p.nextToken()
+		if p.currentTokenIs(lexer.EXISTS) {
+			stmt.IfExists = true
+			p.nextToken()
+		} else {
+			p.expectedError("after IF", "EXISTS")
+			return nil
+		}
 	}
 
 	tableName := p.parseIdentifier()
 	if tableName == nil {
 		return nil
 	}
-
-	stmt := &DeleteStatement{TableName: tableName}
-
-	// Parse optional WHERE clause
-	if p.currentTokenIs(lexer.WHERE) {
-		stmt.WhereClause = p.parseWhereClause()
-	}
+	stmt.TableName = tableName
+	stmt.Cascade = p.parseDropBehavior()
 
 	return stmt
 }
 
-// parseCreateStatement parses CREATE statements
-func (p *Parser) parseCreateStatement() Statement {
-	if !p.expectToken(lexer.CREATE) {
+// parseAlterStatement parses ALTER statements
+func (p *Parser) parseAlterStatement() Statement {
+	if !p.expectToken(lexer.ALTER) {
 		return nil
 	}
 
 	if p.currentTokenIs(lexer.TABLE) {
-		return p.parseCreateTableStatement()
+		return p.parseAlterTableStatement()
 	}
 
-	p.addError("only CREATE TABLE is supported")
+	p.addError("only ALTER TABLE is supported")
 	return nil
 }
 
-// parseCreateTableStatement parses CREATE TABLE statements
-func (p *Parser) parseCreateTableStatement() *CreateTableStatement {
+// parseAlterTableStatement parses ALTER TABLE statements
+func (p *Parser) parseAlterTableStatement() *AlterTableStatement {
 	if !p.expectToken(lexer.TABLE) {
 		return nil
 	}
@@ -540,30 +1874,14 @@ func (p *Parser) parseCreateTableStatement() *CreateTableStatement {
 		return nil
 	}
 
-	if !p.expectToken(lexer.LPAREN) {
-		return nil
-	}
-
-	stmt := &CreateTableStatement{TableName: tableName}
+	stmt := &AlterTableStatement{TableName: tableName}
 
 	for {
-		// Parse column definition or table constraint
-		if p.currentTokenIs(lexer.CONSTRAINT) || p.currentTokenIs(lexer.PRIMARY) ||
-			p.currentTokenIs(lexer.FOREIGN) || p.currentTokenIs(lexer.UNIQUE) {
-			// Table constraint
-			constraint := p.parseTableConstraint()
-			if constraint == nil {
-				return nil
-			}
-			stmt.Constraints = append(stmt.Constraints, constraint)
-		} else {
-			// Column definition
-			colDef := p.parseColumnDefinition()
-			if colDef == nil {
-				return nil
-			}
-			stmt.Columns = append(stmt.Columns, colDef)
+		action := p.parseAlterTableAction()
+		if action == nil {
+			return nil
 		}
+		stmt.Actions = append(stmt.Actions, action)
 
 		if !p.currentTokenIs(lexer.COMMA) {
 			break
@@ -571,54 +1889,120 @@ func (p *Parser) parseCreateTableStatement() *CreateTableStatement {
 		p.nextToken() // consume comma
 	}
 
-	if !p.expectToken(lexer.RPAREN) {
-		return nil
-	}
-
 	return stmt
 }
 
-// parseDropStatement parses DROP statements
-func (p *Parser) parseDropStatement() Statement {
-	if !p.expectToken(lexer.DROP) {
-		return nil
-	}
+// parseAlterTableAction parses a single ALTER TABLE action
+func (p *Parser) parseAlterTableAction() AlterTableAction {
+	switch {
+	case p.currentWordIs("ADD"):
+		p.nextToken()
+		if p.currentTokenIs(lexer.CONSTRAINT) || p.currentTokenIs(lexer.PRIMARY) ||
+			p.currentTokenIs(lexer.FOREIGN) || p.currentTokenIs(lexer.UNIQUE) ||
+			(p.currentWordIs("CHECK") && p.peekTokenIs(lexer.LPAREN)) {
+			constraint := p.parseTableConstraint()
+			if constraint == nil {
+				return nil
+			}
+			return &AddConstraintAction{Constraint: constraint}
+		}
 
-	if p.currentTokenIs(lexer.TABLE) {
-		return p.parseDropTableStatement()
-	}
+		if p.currentWordIs("COLUMN") {
+			p.nextToken()
+		}
+		column := p.parseColumnDefinition()
+		if column == nil {
+			return nil
+		}
+		return &AddColumnAction{Column: column}
 
-	p.addError("only DROP TABLE is supported")
-	return nil
-}
+	case p.currentTokenIs(lexer.DROP):
+		p.nextToken()
+		if p.currentTokenIs(lexer.CONSTRAINT) {
+			p.nextToken()
+			name := p.parseIdentifier()
+			if name == nil {
+				return nil
+			}
+			return &DropConstraintAction{Name: name}
+		}
 
-// parseDropTableStatement parses DROP TABLE statements
-func (p *Parser) parseDropTableStatement() *DropTableStatement {
-	if !p.expectToken(lexer.TABLE) {
-		return nil
-	}
+		if p.currentWordIs("COLUMN") {
+			p.nextToken()
+		}
+		column := p.parseIdentifier()
+		if column == nil {
+			return nil
+		}
+		return &DropColumnAction{Column: column}
 
-	stmt := &DropTableStatement{}
+	case p.currentWordIs("RENAME"):
+		p.nextToken()
+		if p.currentWordIs("TO") {
+			p.nextToken()
+			name := p.parseIdentifier()
+			if name == nil {
+				return nil
+			}
+			return &RenameTableAction{NewName: name}
+		}
 
-	// Check for IF EXISTS
-	if p.currentTokenIs(lexer.IF) {
+		if p.currentWordIs("COLUMN") {
+			p.nextToken()
+		}
+		column := p.parseIdentifier()
+		if column == nil {
+			return nil
+		}
+		if !p.currentWordIs("TO") {
+			p.expectedError("", "TO")
+			return nil
+		}
 		p.nextToken()
-		if p.currentTokenIs(lexer.EXISTS) {
-			stmt.IfExists = true
+		name := p.parseIdentifier()
+		if name == nil {
+			return nil
+		}
+		return &RenameColumnAction{Column: column, NewName: name}
+
+	case p.currentTokenIs(lexer.SET):
+		p.nextToken()
+		options := p.parseTableOptionList()
+		if options == nil {
+			return nil
+		}
+		return &SetTableOptionsAction{Options: options}
+
+	case p.currentWordIs("RESET"):
+		p.nextToken()
+		if !p.expectToken(lexer.LPAREN) {
+			return nil
+		}
+
+		action := &ResetTableOptionsAction{}
+		for {
+			if !p.currentTokenIs(lexer.IDENTIFIER) {
+				p.expectedError("", "table option name")
+				return nil
+			}
+			action.Names = append(action.Names, identifierAt(p.currentToken))
 			p.nextToken()
-		} else {
-			p.addError("expected EXISTS after IF")
+
+			if !p.currentTokenIs(lexer.COMMA) {
+				break
+			}
+			p.nextToken() // consume comma
+		}
+
+		if !p.expectToken(lexer.RPAREN) {
 			return nil
 		}
-	}
+		return action
 
-	tableName := p.parseIdentifier()
-	if tableName == nil {
+	default:
+		p.addError(fmt.Sprintf("unexpected token %s in ALTER TABLE", p.currentToken.Type.String()))
 		return nil
 	}
-	stmt.TableName = tableName
-
-	return stmt
 }
 
 // parseColumnDefinition parses column definitions
@@ -652,10 +2036,12 @@ func (p *Parser) parseColumnDefinition() *ColumnDefinition {
 
 // parseDataType parses SQL data types
 func (p *Parser) parseDataType() *DataType {
+	// Type names without a keyword of their own, such as VARCHAR or DATE,
+	// are read as identifiers
 	if !p.currentTokenIs(lexer.INTEGER) && !p.currentTokenIs(lexer.TEXT) &&
 		!p.currentTokenIs(lexer.REAL) && !p.currentTokenIs(lexer.BLOB) &&
-		!p.currentTokenIs(lexer.BOOLEAN) {
-		p.addError("expected data type")
+		!p.currentTokenIs(lexer.BOOLEAN) && !p.currentTokenIs(lexer.IDENTIFIER) {
+		p.expectedError("", "data type")
 		return nil
 	}
 
@@ -667,7 +2053,7 @@ func (p *Parser) parseDataType() *DataType {
 		p.nextToken()
 
 		if !p.currentTokenIs(lexer.NUMBER) {
-			p.addError("expected number after (")
+			p.expectedError("after (", "number")
 			return nil
 		}
 
@@ -684,7 +2070,7 @@ func (p *Parser) parseDataType() *DataType {
 		if p.currentTokenIs(lexer.COMMA) {
 			p.nextToken()
 			if !p.currentTokenIs(lexer.NUMBER) {
-				p.addError("expected number after comma")
+				p.expectedError("after comma", "number")
 				return nil
 			}
 
@@ -726,11 +2112,14 @@ func (p *Parser) parseColumnConstraint() *ColumnConstraint {
 	case lexer.UNIQUE:
 		p.nextToken()
 		return &ColumnConstraint{Type: UniqueKey}
+	case lexer.AUTO_INCREMENT:
+		p.nextToken()
+		return &ColumnConstraint{Type: AutoIncrement}
 	case lexer.DEFAULT:
 		p.nextToken()
 		value := p.parseExpression()
 		if value == nil {
-			p.addError("expected expression after DEFAULT")
+			p.expectedError("after DEFAULT", "expression")
 			return nil
 		}
 		return &ColumnConstraint{Type: Default, DefaultValue: value}
@@ -769,8 +2158,23 @@ func (p *Parser) parseTableConstraint() *TableConstraint {
 		p.nextToken()
 		constraint.Type = UniqueKey
 	default:
-		p.addError("expected constraint type")
-		return nil
+		if !p.currentWordIs("CHECK") {
+			p.expectedError("", "constraint type")
+			return nil
+		}
+		p.nextToken()
+		constraint.Type = Check
+
+		if !p.expectToken(lexer.LPAREN) {
+			return nil
+		}
+		if constraint.Check = p.parseExpression(); constraint.Check == nil {
+			return nil
+		}
+		if !p.expectToken(lexer.RPAREN) {
+			return nil
+		}
+		return constraint
 	}
 
 	// Parse column list
@@ -856,14 +2260,14 @@ func (p *Parser) parseLogicalOr() Expression {
 
 // parseLogicalAnd parses AND expressions
 func (p *Parser) parseLogicalAnd() Expression {
-	left := p.parseComparison()
+	left := p.parseLogicalNot()
 	if left == nil {
 		return nil
 	}
 
 	for p.currentTokenIs(lexer.AND) {
 		p.nextToken()
-		right := p.parseComparison()
+		right := p.parseLogicalNot()
 		if right == nil {
 			return nil
 		}
@@ -873,6 +2277,21 @@ func (p *Parser) parseLogicalAnd() Expression {
 	return left
 }
 
+// parseLogicalNot parses NOT, which binds looser than comparisons so that
+// NOT a = b negates the comparison
+func (p *Parser) parseLogicalNot() Expression {
+	if !p.currentTokenIs(lexer.NOT) {
+		return p.parseComparison()
+	}
+
+	p.nextToken()
+	operand := p.parseLogicalNot()
+	if operand == nil {
+		return nil
+	}
+	return &UnaryExpression{Operator: Not, Operand: operand}
+}
+
 // parseComparison parses comparison expressions
 func (p *Parser) parseComparison() Expression {
 	left := p.parseAddition()
@@ -895,12 +2314,18 @@ func (p *Parser) parseComparison() Expression {
 			op = LessEqual
 		case lexer.GREATER_EQUAL:
 			op = GreaterEqual
-		case lexer.LIKE:
-			op = Like
-		case lexer.IN:
-			op = In
-		case lexer.BETWEEN:
-			op = Between
+		case lexer.IS:
+			left = p.parseIsNull(left)
+			if left == nil {
+				return nil
+			}
+			continue
+		case lexer.NOT, lexer.LIKE, lexer.IN, lexer.BETWEEN:
+			left = p.parsePredicate(left)
+			if left == nil {
+				return nil
+			}
+			continue
 		default:
 			return left
 		}
@@ -914,6 +2339,123 @@ func (p *Parser) parseComparison() Expression {
 	}
 }
 
+// parseIsNull parses IS [NOT] NULL after its operand
+func (p *Parser) parseIsNull(operand Expression) Expression {
+	p.nextToken() // consume IS
+
+	expr := &IsNullExpression{Expr: operand}
+	if p.currentTokenIs(lexer.NOT) {
+		expr.Not = true
+		p.nextToken()
+	}
+	if !p.expectToken(lexer.NULL) {
+		return nil
+	}
+	return expr
+}
+
+// parsePredicate parses [NOT] LIKE, [NOT] IN and [NOT] BETWEEN after their
+// left operand
+func (p *Parser) parsePredicate(operand Expression) Expression {
+	not := false
+	if p.currentTokenIs(lexer.NOT) {
+		not = true
+		p.nextToken()
+	}
+
+	switch p.currentToken.Type {
+	case lexer.LIKE:
+		return p.parseLike(operand, not)
+	case lexer.IN:
+		return p.parseIn(operand, not)
+	case lexer.BETWEEN:
+		return p.parseBetween(operand, not)
+	default:
+		p.expectedError("after NOT", "LIKE", "IN", "BETWEEN")
+		return nil
+	}
+}
+
+// parseLike parses LIKE pattern [ESCAPE escape]
+func (p *Parser) parseLike(operand Expression, not bool) Expression {
+	p.nextToken() // consume LIKE
+
+	pattern := p.parseAddition()
+	if pattern == nil {
+		return nil
+	}
+	expr := &LikeExpression{Expr: operand, Pattern: pattern, Not: not}
+
+	if p.currentWordIs("ESCAPE") {
+		p.nextToken()
+		if expr.Escape = p.parseAddition(); expr.Escape == nil {
+			return nil
+		}
+	}
+	return expr
+}
+
+// parseIn parses IN (value, ...) or IN (SELECT ...). NOT IN with a subquery
+// is the negation of the IN predicate.
+func (p *Parser) parseIn(operand Expression, not bool) Expression {
+	p.nextToken() // consume IN
+
+	if !p.expectToken(lexer.LPAREN) {
+		return nil
+	}
+
+	if p.currentTokenIs(lexer.SELECT) || p.currentTokenIs(lexer.WITH) {
+		subquery := p.parseSubquery()
+		if subquery == nil {
+			return nil
+		}
+		var expr Expression = &BinaryExpression{Left: operand, Operator: In, Right: subquery}
+		if not {
+			expr = &UnaryExpression{Operator: Not, Operand: expr}
+		}
+		return expr
+	}
+
+	expr := &InListExpression{Expr: operand, Not: not}
+	for {
+		value := p.parseExpression()
+		if value == nil {
+			return nil
+		}
+		expr.List = append(expr.List, value)
+
+		if !p.currentTokenIs(lexer.COMMA) {
+			break
+		}
+		p.nextToken() // consume comma
+	}
+
+	if !p.expectToken(lexer.RPAREN) {
+		return nil
+	}
+	return expr
+}
+
+// parseBetween parses BETWEEN lower AND upper. The bounds are parsed above
+// AND, so the AND separating them is not taken for a logical AND.
+func (p *Parser) parseBetween(operand Expression, not bool) Expression {
+	p.nextToken() // consume BETWEEN
+
+	lower := p.parseAddition()
+	if lower == nil {
+		return nil
+	}
+	if !p.expectToken(lexer.AND) {
+		return nil
+	}
+	upper := p.parseAddition()
+	if upper == nil {
+		return nil
+	}
+
+	return &BetweenExpression{Expr: operand, Lower: lower, Upper: upper, Not: not}
+}
+
 // parseAddition parses addition and subtraction
 func (p *Parser) parseAddition() Expression {
 	left := p.parseMultiplication()
@@ -1003,17 +2545,34 @@ func (p *Parser) parsePrimary() Expression {
 	switch p.currentToken.Type {
 	case lexer.IDENTIFIER:
 		return p.parseIdentifierExpression()
+	case lexer.COUNT, lexer.SUM, lexer.AVG, lexer.MIN, lexer.MAX:
+		return p.parseAggregateCall()
 	case lexer.NUMBER:
 		return p.parseNumberLiteral()
 	case lexer.STRING:
 		return p.parseStringLiteral()
+	case lexer.HEX_STRING:
+		return p.parseBlobLiteral()
+	case lexer.PARAMETER:
+		return p.parseParameter()
+	case lexer.NULL, lexer.TRUE, lexer.FALSE:
+		return p.parseKeywordLiteral()
+	case lexer.CASE:
+		return p.parseCaseExpression()
+	case lexer.CAST:
+		return p.parseCastExpression()
 	case lexer.MULTIPLY:
 		// Handle * (wildcard)
 		p.nextToken()
 		return &Wildcard{}
+	case lexer.EXISTS:
+		return p.parseExists()
 	case lexer.LPAREN:
-		// Handle grouped expressions
+		// Handle subqueries and grouped expressions
 		p.nextToken()
+		if p.currentTokenIs(lexer.SELECT) || p.currentTokenIs(lexer.WITH) {
+			return p.parseSubquery()
+		}
 		expr := p.parseExpression()
 		if expr == nil {
 			return nil
@@ -1028,46 +2587,406 @@ func (p *Parser) parsePrimary() Expression {
 	}
 }
 
-// parseIdentifierExpression parses identifiers, function calls, and column references
-func (p *Parser) parseIdentifierExpression() Expression {
-	name := p.parseIdentifier()
-	if name == nil {
+// parseKeywordLiteral parses NULL, TRUE and FALSE
+func (p *Parser) parseKeywordLiteral() *Literal {
+	lit := &Literal{Type: p.currentToken.Type, Pos: tokenPos(p.currentToken)}
+	switch p.currentToken.Type {
+	case lexer.TRUE:
+		lit.Value = true
+	case lexer.FALSE:
+		lit.Value = false
+	}
+	p.nextToken()
+	return lit
+}
+
+// parseCaseExpression parses a simple CASE operand WHEN value THEN result
+// ... END or a searched CASE WHEN condition THEN result ... END, each with
+// an optional ELSE
+func (p *Parser) parseCaseExpression() Expression {
+	p.nextToken() // consume CASE
+
+	expr := &CaseExpression{}
+	if !p.currentTokenIs(lexer.WHEN) {
+		expr.Operand = p.parseExpression()
+		if expr.Operand == nil {
+			return nil
+		}
+	}
+
+	for p.currentTokenIs(lexer.WHEN) {
+		p.nextToken()
+		condition := p.parseExpression()
+		if condition == nil {
+			return nil
+		}
+		if !p.expectToken(lexer.THEN) {
+			return nil
+		}
+		result := p.parseExpression()
+		if result == nil {
+			return nil
+		}
+		expr.Whens = append(expr.Whens, &WhenClause{Condition: condition, Result: result})
+	}
+	if len(expr.Whens) == 0 {
+		p.expectedError("in CASE expression", "WHEN")
 		return nil
 	}
 
-	// Check if it's a function call
-	if p.currentTokenIs(lexer.LPAREN) {
+	if p.currentTokenIs(lexer.ELSE) {
+		p.nextToken()
+		expr.Else = p.parseExpression()
+		if expr.Else == nil {
+			return nil
+		}
+	}
+
+	if !p.expectToken(lexer.END) {
+		return nil
+	}
+	return expr
+}
+
+// parseCastExpression parses CAST(expr AS type)
+func (p *Parser) parseCastExpression() Expression {
+	p.nextToken() // consume CAST
+
+	if !p.expectToken(lexer.LPAREN) {
+		return nil
+	}
+	castOperand := p.castOperand
+	p.castOperand = true
+	operand := p.parseExpression()
+	p.castOperand = castOperand
+	if operand == nil {
+		return nil
+	}
+	if !p.expectToken(lexer.AS) {
+		return nil
+	}
+	dataType := p.parseDataType()
+	if dataType == nil {
+		return nil
+	}
+	if !p.expectToken(lexer.RPAREN) {
+		return nil
+	}
+
+	return &CastExpression{Expr: operand, Type: dataType}
+}
+
+// parseSubquery parses a SELECT in parentheses, positioned after the opening
+// parenthesis, and its optional AS alias
+func (p *Parser) parseSubquery() Expression {
+	query := p.parseQuery()
+	if query == nil {
+		return nil
+	}
+	if !p.expectToken(lexer.RPAREN) {
+		return nil
+	}
+
+	subquery := &SubqueryExpression{Query: query}
+	if p.currentTokenIs(lexer.AS) && !p.castOperand {
+		p.nextToken()
+		if !p.currentTokenIs(lexer.IDENTIFIER) {
+			p.expectedError("after AS", "identifier")
+			return nil
+		}
+		subquery.Alias = identifierAt(p.currentToken)
+		p.nextToken()
+
+		if p.currentTokenIs(lexer.LPAREN) {
+			if subquery.Columns = p.parseColumnNameList(); subquery.Columns == nil {
+				return nil
+			}
+		}
+	}
+
+	return subquery
+}
+
+// parseColumnNameList parses a parenthesized list of column names, such as
+// the columns of a CTE, a view or a derived table
+func (p *Parser) parseColumnNameList() []*Identifier {
+	p.nextToken() // consume (
+
+	var columns []*Identifier
+	for {
+		if !p.currentTokenIs(lexer.IDENTIFIER) {
+			p.expectedError("", "column name")
+			return nil
+		}
+		columns = append(columns, identifierAt(p.currentToken))
 		p.nextToken()
 
-		funcCall := &FunctionCall{Name: name}
+		if !p.currentTokenIs(lexer.COMMA) {
+			break
+		}
+		p.nextToken() // consume comma
+	}
+	if !p.expectToken(lexer.RPAREN) {
+		return nil
+	}
+	return columns
+}
+
+// parseExists parses EXISTS (SELECT ...)
+func (p *Parser) parseExists() Expression {
+	p.nextToken() // consume EXISTS
+
+	if !p.expectToken(lexer.LPAREN) {
+		return nil
+	}
+	if !p.currentTokenIs(lexer.SELECT) && !p.currentTokenIs(lexer.WITH) {
+		p.expectedError("after EXISTS (", "SELECT")
+		return nil
+	}
+
+	query := p.parseQuery()
+	if query == nil {
+		return nil
+	}
+	if !p.expectToken(lexer.RPAREN) {
+		return nil
+	}
+
+	return &ExistsExpression{Query: query}
+}
+
+// parseTableReference parses a FROM or JOIN item. Tables and derived tables
+// may be given an alias without AS, as in FROM users u or FROM (SELECT ...) t.
+// RETURNING and WINDOW are never taken for one, since they end an
+// INSERT ... SELECT and start a WINDOW clause.
+func (p *Parser) parseTableReference() Expression {
+	table := p.parseExpression()
+	if table == nil {
+		return nil
+	}
 
-		// Check for DISTINCT
-		if p.currentTokenIs(lexer.DISTINCT) {
-			funcCall.Distinct = true
+	if !p.currentTokenIs(lexer.IDENTIFIER) || p.currentWordIs("RETURNING") || p.currentWordIs("WINDOW") {
+		return table
+	}
+	switch t := table.(type) {
+	case *Identifier:
+		if t.Alias == nil {
+			t.Alias = identifierAt(p.currentToken)
+			p.nextToken()
+		}
+	case *SubqueryExpression:
+		if t.Alias == nil {
+			t.Alias = identifierAt(p.currentToken)
 			p.nextToken()
 		}
+	}
 
-		// Parse arguments
-		if !p.currentTokenIs(lexer.RPAREN) {
-			for {
-				arg := p.parseExpression()
-				if arg == nil {
-					return nil
-				}
-				funcCall.Arguments = append(funcCall.Arguments, arg)
+	return table
+}
 
-				if !p.currentTokenIs(lexer.COMMA) {
-					break
-				}
-				p.nextToken() // consume comma
+// parseAggregateCall parses a call of COUNT, SUM, AVG, MIN or MAX, whose
+// names are keywords
+func (p *Parser) parseAggregateCall() Expression {
+	name := &Identifier{Value: strings.ToUpper(p.currentToken.Value), Pos: tokenPos(p.currentToken)}
+	p.nextToken()
+
+	if !p.currentTokenIs(lexer.LPAREN) {
+		p.expectedError("after "+name.Value, "(")
+		return nil
+	}
+	return p.parseFunctionCall(name)
+}
+
+// parseFunctionCall parses the argument list of a function call, starting
+// at its opening parenthesis, and an optional OVER clause
+func (p *Parser) parseFunctionCall(name *Identifier) Expression {
+	p.nextToken() // consume (
+
+	funcCall := &FunctionCall{Name: name}
+
+	// Check for DISTINCT
+	if p.currentTokenIs(lexer.DISTINCT) {
+		funcCall.Distinct = true
+		p.nextToken()
+	}
+
+	// Parse arguments
+	if !p.currentTokenIs(lexer.RPAREN) {
+		for {
+			arg := p.parseExpression()
+			if arg == nil {
+				return nil
+			}
+			funcCall.Arguments = append(funcCall.Arguments, arg)
+
+			if !p.currentTokenIs(lexer.COMMA) {
+				break
 			}
+			p.nextToken() // consume comma
 		}
+	}
 
-		if !p.expectToken(lexer.RPAREN) {
+	if !p.expectToken(lexer.RPAREN) {
+		return nil
+	}
+
+	if p.currentWordIs("OVER") {
+		p.nextToken()
+		if p.currentTokenIs(lexer.IDENTIFIER) {
+			// A window of the WINDOW clause, filled in once it is read
+			funcCall.Over = &WindowSpec{Name: identifierAt(p.currentToken)}
+			p.nextToken()
+			return funcCall
+		}
+		funcCall.Over = p.parseWindowSpec()
+		if funcCall.Over == nil {
+			return nil
+		}
+	}
+
+	return funcCall
+}
+
+// parseWindowSpec parses ( [PARTITION BY expr, ...] [ORDER BY ...] [frame] )
+func (p *Parser) parseWindowSpec() *WindowSpec {
+	if !p.expectToken(lexer.LPAREN) {
+		return nil
+	}
+
+	spec := &WindowSpec{}
+
+	if p.currentWordIs("PARTITION") {
+		p.nextToken()
+		if !p.expectToken(lexer.BY) {
+			return nil
+		}
+		for {
+			expr := p.parseExpression()
+			if expr == nil {
+				return nil
+			}
+			spec.PartitionBy = append(spec.PartitionBy, expr)
+
+			if !p.currentTokenIs(lexer.COMMA) {
+				break
+			}
+			p.nextToken() // consume comma
+		}
+	}
+
+	if p.currentTokenIs(lexer.ORDER) {
+		spec.OrderBy = p.parseOrderByClause()
+		if spec.OrderBy == nil {
+			return nil
+		}
+	}
+
+	if p.currentWordIs("ROWS") || p.currentWordIs("RANGE") {
+		spec.Frame = p.parseWindowFrame()
+		if spec.Frame == nil {
+			return nil
+		}
+	}
+
+	if !p.expectToken(lexer.RPAREN) {
+		return nil
+	}
+	return spec
+}
+
+// parseWindowFrame parses ROWS|RANGE start or ROWS|RANGE BETWEEN start AND end
+func (p *Parser) parseWindowFrame() *WindowFrame {
+	frame := &WindowFrame{Unit: FrameRows}
+	if p.currentWordIs("RANGE") {
+		frame.Unit = FrameRange
+	}
+	p.nextToken()
+
+	if !p.currentTokenIs(lexer.BETWEEN) {
+		frame.Start = p.parseFrameBound()
+		if frame.Start == nil {
+			return nil
+		}
+		frame.End = &FrameBound{Type: CurrentRow}
+		return frame
+	}
+	p.nextToken() // consume BETWEEN
+
+	frame.Start = p.parseFrameBound()
+	if frame.Start == nil {
+		return nil
+	}
+	if !p.expectToken(lexer.AND) {
+		return nil
+	}
+	frame.End = p.parseFrameBound()
+	if frame.End == nil {
+		return nil
+	}
+	return frame
+}
+
+// parseFrameBound parses UNBOUNDED PRECEDING|FOLLOWING, CURRENT ROW or
+// offset PRECEDING|FOLLOWING
+func (p *Parser) parseFrameBound() *FrameBound {
+	switch {
+	case p.currentWordIs("UNBOUNDED"):
+		p.nextToken()
+		bound := &FrameBound{}
+		switch {
+		case p.currentWordIs("PRECEDING"):
+			bound.Type = UnboundedPreceding
+		case p.currentWordIs("FOLLOWING"):
+			bound.Type = UnboundedFollowing
+		default:
+			p.expectedError("after UNBOUNDED", "PRECEDING", "FOLLOWING")
+			return nil
+		}
+		p.nextToken()
+		return bound
+
+	case p.currentWordIs("CURRENT"):
+		p.nextToken()
+		if !p.currentWordIs("ROW") {
+			p.expectedError("after CURRENT", "ROW")
 			return nil
 		}
+		p.nextToken()
+		return &FrameBound{Type: CurrentRow}
+	}
+
+	// Offsets are arithmetic, so the AND of BETWEEN is not read as a conjunction
+	offset := p.parseAddition()
+	if offset == nil {
+		return nil
+	}
+	bound := &FrameBound{Offset: offset}
+	switch {
+	case p.currentWordIs("PRECEDING"):
+		bound.Type = OffsetPreceding
+	case p.currentWordIs("FOLLOWING"):
+		bound.Type = OffsetFollowing
+	default:
+		p.expectedError("after frame offset", "PRECEDING", "FOLLOWING")
+		return nil
+	}
+	p.nextToken()
+	return bound
+}
+
+// parseIdentifierExpression parses identifiers, function calls, and column references
+func (p *Parser) parseIdentifierExpression() Expression {
+	token := p.currentToken
+	name := p.parseIdentifier()
+	if name == nil {
+		return nil
+	}
 
-		return funcCall
+	// Check if it's a function call; function names name no table or
+	// column, so they keep their spelling
+	if p.currentTokenIs(lexer.LPAREN) {
+		name.Value = token.Value
+		return p.parseFunctionCall(name)
 	}
 
 	// Check if it's a qualified column reference (table.column)
@@ -1089,24 +3008,35 @@ func (p *Parser) parseIdentifierExpression() Expression {
 	return name
 }
 
+// identifierAt returns the identifier a token names. Unquoted names are
+// folded to lower case, so Users, USERS and users name the same table; a
+// quoted name is kept exactly as written.
+func identifierAt(token lexer.Token) *Identifier {
+	value := token.Value
+	if !token.Quoted {
+		value = strings.ToLower(value)
+	}
+	return &Identifier{Value: value, Quoted: token.Quoted, Pos: tokenPos(token)}
+}
+
 // parseIdentifier parses identifiers with optional aliases
 func (p *Parser) parseIdentifier() *Identifier {
 	if !p.currentTokenIs(lexer.IDENTIFIER) {
-		p.addError("expected identifier")
+		p.expectedError("", "identifier")
 		return nil
 	}
 
-	identifier := &Identifier{Value: p.currentToken.Value}
+	identifier := identifierAt(p.currentToken)
 	p.nextToken()
 
 	// Parse optional alias
-	if p.currentTokenIs(lexer.AS) {
+	if p.currentTokenIs(lexer.AS) && !p.castOperand {
 		p.nextToken()
 		if !p.currentTokenIs(lexer.IDENTIFIER) {
-			p.addError("expected identifier after AS")
+			p.expectedError("after AS", "identifier")
 			return nil
 		}
-		identifier.Alias = &Identifier{Value: p.currentToken.Value}
+		identifier.Alias = identifierAt(p.currentToken)
 		p.nextToken()
 	}
 
@@ -1115,27 +3045,69 @@ func (p *Parser) parseIdentifier() *Identifier {
 
 // parseNumberLiteral parses numeric literals
 func (p *Parser) parseNumberLiteral() *Literal {
-	value := p.currentToken.Value
+	lit := &Literal{Value: p.currentToken.Value, Type: lexer.NUMBER, Pos: tokenPos(p.currentToken)}
 	p.nextToken()
-	return &Literal{Value: value, Type: lexer.NUMBER}
+	return lit
 }
 
 // parseStringLiteral parses string literals
 func (p *Parser) parseStringLiteral() *Literal {
+	lit := &Literal{Value: p.currentToken.Value, Type: lexer.STRING, Pos: tokenPos(p.currentToken)}
+	p.nextToken()
+	return lit
+}
+
+// parseBlobLiteral parses X'...' literals into their bytes
+func (p *Parser) parseBlobLiteral() *Literal {
+	value, _ := hex.DecodeString(p.currentToken.Value) // checked by the lexer
+	lit := &Literal{Value: value, Type: lexer.HEX_STRING, Pos: tokenPos(p.currentToken)}
+	p.nextToken()
+	return lit
+}
+
+// parseParameter parses ? and $n bind parameter placeholders
+func (p *Parser) parseParameter() Expression {
 	value := p.currentToken.Value
+
+	if value == "?" {
+		if p.numberedParam {
+			p.addError("cannot mix ? and $n parameters")
+			return nil
+		}
+		p.positionalParam = true
+		p.parameters++
+		p.nextToken()
+		return &Parameter{Index: p.parameters}
+	}
+
+	if p.positionalParam {
+		p.addError("cannot mix ? and $n parameters")
+		return nil
+	}
+	index, err := strconv.Atoi(value[1:])
+	if err != nil || index < 1 {
+		p.addError(fmt.Sprintf("invalid parameter %s", value))
+		return nil
+	}
+	p.numberedParam = true
+	if index > p.parameters {
+		p.parameters = index
+	}
 	p.nextToken()
-	return &Literal{Value: value, Type: lexer.STRING}
+	return &Parameter{Index: index, Numbered: true}
 }
 
-// ParseSQL is a convenience function to parse a complete SQL statement
+// ParseSQL is a convenience function to parse a complete SQL statement.
+// A syntax error is returned as ParseErrors.
 func ParseSQL(sql string) (Statement, error) {
 	lexer := lexer.NewLexer(sql)
 	parser := NewParser(lexer)
 
 	stmt := parser.ParseStatement()
+	parser.expectEnd()
 
 	if len(parser.Errors()) > 0 {
-		return nil, fmt.Errorf("parse errors: %v", parser.Errors())
+		return nil, parser.ParseErrors()
 	}
 
 	return stmt, nil
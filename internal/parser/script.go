@@ -0,0 +1,75 @@
+package parser
+
+import (
+	"strings"
+
+	"relational-db/internal/lexer"
+)
+
+// ScriptStatement is one statement of a script along with where it appears
+// in the script source
+type ScriptStatement struct {
+	Statement Statement
+	Text      string // source text, without the terminating semicolon
+	Start     int    // byte offset of the statement's first token
+	End       int    // byte offset just past the statement's last character
+	Line      int
+	Column    int
+}
+
+// ParseScript parses a sequence of statements separated by semicolons, such
+// as a migration file or seed script. Comments and empty statements are
+// skipped. After an invalid statement parsing resumes at the next
+// semicolon, so every invalid statement is reported: the error is then
+// ParseErrors, naming each statement and position, and the valid
+// statements are returned along with it.
+func ParseScript(sql string) ([]*ScriptStatement, error) {
+	p := NewParser(lexer.NewLexer(sql))
+	var statements []*ScriptStatement
+	var errs ParseErrors
+
+	for !p.currentTokenIs(lexer.EOF) {
+		if p.currentTokenIs(lexer.SEMICOLON) {
+			p.nextToken()
+			continue
+		}
+
+		start := p.currentToken
+		p.errors = p.errors[:0]
+		p.parameters = 0
+		p.positionalParam = false
+		p.numberedParam = false
+
+		stmt := p.ParseStatement()
+		p.expectStatementEnd()
+		if len(p.errors) > 0 {
+			// The parser stops reporting after a statement's first
+			// error, so each invalid statement adds one
+			err := p.errors[0]
+			err.Statement = len(statements) + len(errs) + 1
+			errs = append(errs, err)
+			p.synchronize()
+			continue
+		}
+
+		end := p.currentToken.Position
+		if end > len(sql) || p.currentTokenIs(lexer.EOF) {
+			end = len(sql)
+		}
+		text := strings.TrimRight(sql[start.Position:end], " \t\r\n")
+
+		statements = append(statements, &ScriptStatement{
+			Statement: stmt,
+			Text:      text,
+			Start:     start.Position,
+			End:       start.Position + len(text),
+			Line:      start.Line,
+			Column:    start.Column,
+		})
+	}
+
+	if len(errs) > 0 {
+		return statements, errs
+	}
+	return statements, nil
+}
@@ -0,0 +1,358 @@
+package parser
+
+// A Visitor's Visit method is called for each node met by Walk. If the
+// visitor w it returns is not nil, Walk visits each child of the node with
+// w and then calls w.Visit(nil).
+type Visitor interface {
+	Visit(node Node) (w Visitor)
+}
+
+// Walk traverses the tree rooted at node in depth first order, visiting
+// children in the order they appear in the source. Every name is a node of
+// its own, including the alias of a table or column, which is a child of
+// the Identifier it names.
+func Walk(v Visitor, node Node) {
+	if v = v.Visit(node); v == nil {
+		return
+	}
+	eachChild(node, func(child Node, _ func(Node)) {
+		Walk(v, child)
+	})
+	v.Visit(nil)
+}
+
+type inspector func(Node) bool
+
+func (f inspector) Visit(node Node) Visitor {
+	if f(node) {
+		return f
+	}
+	return nil
+}
+
+// Inspect traverses the tree rooted at node as Walk does, calling f for each
+// node and then f(nil) once its children are done. The children of a node
+// are skipped when f returns false for it.
+func Inspect(node Node, f func(Node) bool) {
+	Walk(inspector(f), node)
+}
+
+// Cursor describes the node being visited by Rewrite, and lets it be
+// replaced
+type Cursor struct {
+	node    Node
+	parent  Node
+	replace func(Node)
+}
+
+// Node returns the current node
+func (c *Cursor) Node() Node { return c.node }
+
+// Parent returns the node holding the current one, nil for the root
+func (c *Cursor) Parent() Node { return c.parent }
+
+// Replace puts n in place of the current node. n must be of a type the
+// parent's field accepts, such as an Expression for a WHERE condition or an
+// *Identifier for a table name; Replace panics otherwise.
+func (c *Cursor) Replace(n Node) {
+	c.replace(n)
+	c.node = n
+}
+
+// ApplyFunc is called by Rewrite for each node. Its result controls the
+// traversal, as described for Rewrite.
+type ApplyFunc func(c *Cursor) bool
+
+// Rewrite traverses the tree rooted at root as Walk does, calling pre before
+// the children of each node and post after them; either may be nil. The
+// current node may be replaced through the cursor: a node replaced by pre
+// has the children of its replacement traversed. When pre returns false
+// the children of the node and its post call are skipped, and when post
+// returns false the traversal stops.
+//
+// Nodes are changed in place. Rewrite returns the root, or what replaced it.
+func Rewrite(root Node, pre, post ApplyFunc) Node {
+	r := &rewriter{pre: pre, post: post}
+	r.apply(nil, root, func(n Node) { root = n })
+	return root
+}
+
+type rewriter struct {
+	pre     ApplyFunc
+	post    ApplyFunc
+	stopped bool
+}
+
+func (r *rewriter) apply(parent, node Node, replace func(Node)) {
+	if r.stopped {
+		return
+	}
+
+	c := &Cursor{node: node, parent: parent, replace: replace}
+	if r.pre != nil && !r.pre(c) {
+		return
+	}
+
+	eachChild(c.node, func(child Node, replace func(Node)) {
+		r.apply(c.node, child, replace)
+	})
+
+	if r.stopped {
+		return
+	}
+	if r.post != nil && !r.post(c) {
+		r.stopped = true
+	}
+}
+
+// nodeField is the type of a field of a node that holds a child node
+type nodeField interface {
+	Node
+	comparable
+}
+
+// child passes fn the node held in field, with a function that replaces it,
+// unless the field is empty
+func child[T nodeField](fn func(Node, func(Node)), field *T) {
+	var empty T
+	if *field == empty {
+		return
+	}
+	fn(*field, func(n Node) { *field = n.(T) })
+}
+
+// children passes fn each node of a list, as child does
+func children[T nodeField](fn func(Node, func(Node)), list []T) {
+	for i := range list {
+		child(fn, &list[i])
+	}
+}
+
+// eachChild passes fn each child of node in source order, with a function
+// that replaces it. Every node type with children must be listed here;
+// Literal, Parameter, DataType, BEGIN and COMMIT have none.
+func eachChild(node Node, fn func(Node, func(Node))) {
+	switch n := node.(type) {
+	// Statements
+	case *SelectStatement:
+		child(fn, &n.With)
+		child(fn, &n.SetOperation)
+		child(fn, &n.SelectClause)
+		child(fn, &n.FromClause)
+		child(fn, &n.WhereClause)
+		child(fn, &n.GroupBy)
+		child(fn, &n.Having)
+		children(fn, n.Windows)
+		child(fn, &n.OrderBy)
+		child(fn, &n.Limit)
+	case *SetOperation:
+		child(fn, &n.Left)
+		child(fn, &n.Right)
+	case *WithClause:
+		children(fn, n.CTEs)
+	case *CommonTableExpression:
+		child(fn, &n.Name)
+		children(fn, n.Columns)
+		child(fn, &n.Query)
+		child(fn, &n.RecursiveTerm)
+	case *InsertStatement:
+		child(fn, &n.TableName)
+		children(fn, n.Columns)
+		for _, row := range n.Values {
+			children(fn, row)
+		}
+		child(fn, &n.Query)
+		child(fn, &n.OnConflict)
+		children(fn, n.Returning)
+	case *OnConflictClause:
+		children(fn, n.Columns)
+		children(fn, n.SetClauses)
+		child(fn, &n.WhereClause)
+	case *UpdateStatement:
+		child(fn, &n.TableName)
+		children(fn, n.SetClauses)
+		child(fn, &n.From)
+		child(fn, &n.WhereClause)
+		children(fn, n.Returning)
+	case *DeleteStatement:
+		child(fn, &n.TableName)
+		child(fn, &n.Using)
+		child(fn, &n.WhereClause)
+		children(fn, n.Returning)
+	case *MergeStatement:
+		child(fn, &n.Target)
+		child(fn, &n.Source)
+		child(fn, &n.Condition)
+		children(fn, n.Clauses)
+	case *MergeClause:
+		child(fn, &n.Condition)
+		children(fn, n.SetClauses)
+		children(fn, n.Columns)
+		children(fn, n.Values)
+	case *CreateTableStatement:
+		child(fn, &n.TableName)
+		children(fn, n.Columns)
+		children(fn, n.Constraints)
+		child(fn, &n.PartitionBy)
+		children(fn, n.Options)
+	case *PartitionSpec:
+		child(fn, &n.Column)
+		children(fn, n.Partitions)
+	case *PartitionDefinition:
+		child(fn, &n.Name)
+		child(fn, &n.LessThan)
+		children(fn, n.Values)
+	case *TableOption:
+		child(fn, &n.Name)
+		child(fn, &n.Value)
+	case *DropTableStatement:
+		child(fn, &n.TableName)
+	case *CreateViewStatement:
+		child(fn, &n.ViewName)
+		children(fn, n.Columns)
+		child(fn, &n.Query)
+	case *DropViewStatement:
+		child(fn, &n.ViewName)
+	case *RefreshMaterializedViewStatement:
+		child(fn, &n.ViewName)
+	case *CreateIndexStatement:
+		child(fn, &n.IndexName)
+		child(fn, &n.TableName)
+		children(fn, n.Columns)
+		child(fn, &n.Using)
+	case *DropIndexStatement:
+		child(fn, &n.IndexName)
+	case *RollbackStatement:
+		child(fn, &n.Savepoint)
+	case *SavepointStatement:
+		child(fn, &n.Name)
+	case *ReleaseSavepointStatement:
+		child(fn, &n.Name)
+	case *AlterTableStatement:
+		child(fn, &n.TableName)
+		children(fn, n.Actions)
+	case *SetTableOptionsAction:
+		children(fn, n.Options)
+	case *ResetTableOptionsAction:
+		children(fn, n.Names)
+	case *AddColumnAction:
+		child(fn, &n.Column)
+	case *DropColumnAction:
+		child(fn, &n.Column)
+	case *RenameColumnAction:
+		child(fn, &n.Column)
+		child(fn, &n.NewName)
+	case *RenameTableAction:
+		child(fn, &n.NewName)
+	case *AddConstraintAction:
+		child(fn, &n.Constraint)
+	case *DropConstraintAction:
+		child(fn, &n.Name)
+
+	// Clauses
+	case *SelectClause:
+		children(fn, n.Columns)
+	case *FromClause:
+		children(fn, n.Tables)
+		children(fn, n.Joins)
+	case *JoinClause:
+		child(fn, &n.Table)
+		child(fn, &n.Condition)
+	case *WhereClause:
+		child(fn, &n.Condition)
+	case *GroupByClause:
+		children(fn, n.Columns)
+	case *HavingClause:
+		child(fn, &n.Condition)
+	case *OrderByClause:
+		children(fn, n.Orders)
+	case *OrderExpression:
+		child(fn, &n.Expression)
+	case *LimitClause:
+		child(fn, &n.Count)
+		child(fn, &n.Offset)
+	case *SetClause:
+		child(fn, &n.Column)
+		child(fn, &n.Value)
+	case *ColumnDefinition:
+		child(fn, &n.Name)
+		child(fn, &n.DataType)
+		children(fn, n.Constraints)
+	case *ColumnConstraint:
+		child(fn, &n.Name)
+		child(fn, &n.References)
+		child(fn, &n.DefaultValue)
+	case *TableConstraint:
+		child(fn, &n.Name)
+		children(fn, n.Columns)
+		child(fn, &n.References)
+		child(fn, &n.Check)
+	case *ForeignKeyReference:
+		child(fn, &n.Table)
+		children(fn, n.Columns)
+
+	// Expressions
+	case *Identifier:
+		child(fn, &n.Alias)
+	case *ColumnReference:
+		child(fn, &n.Table)
+		child(fn, &n.Column)
+	case *Wildcard:
+		child(fn, &n.Table)
+	case *BinaryExpression:
+		child(fn, &n.Left)
+		child(fn, &n.Right)
+	case *UnaryExpression:
+		child(fn, &n.Operand)
+	case *FunctionCall:
+		child(fn, &n.Name)
+		children(fn, n.Arguments)
+		child(fn, &n.Over)
+	case *WindowSpec:
+		// The parts of a named window belong to the WINDOW clause
+		if n.Name != nil {
+			child(fn, &n.Name)
+			break
+		}
+		children(fn, n.PartitionBy)
+		child(fn, &n.OrderBy)
+		child(fn, &n.Frame)
+	case *NamedWindow:
+		child(fn, &n.Name)
+		child(fn, &n.Spec)
+	case *WindowFrame:
+		child(fn, &n.Start)
+		child(fn, &n.End)
+	case *FrameBound:
+		child(fn, &n.Offset)
+	case *SubqueryExpression:
+		child(fn, &n.Query)
+		child(fn, &n.Alias)
+		children(fn, n.Columns)
+	case *ExistsExpression:
+		child(fn, &n.Query)
+	case *IsNullExpression:
+		child(fn, &n.Expr)
+	case *BetweenExpression:
+		child(fn, &n.Expr)
+		child(fn, &n.Lower)
+		child(fn, &n.Upper)
+	case *InListExpression:
+		child(fn, &n.Expr)
+		children(fn, n.List)
+	case *LikeExpression:
+		child(fn, &n.Expr)
+		child(fn, &n.Pattern)
+		child(fn, &n.Escape)
+	case *CaseExpression:
+		child(fn, &n.Operand)
+		children(fn, n.Whens)
+		child(fn, &n.Else)
+	case *WhenClause:
+		child(fn, &n.Condition)
+		child(fn, &n.Result)
+	case *CastExpression:
+		child(fn, &n.Expr)
+		child(fn, &n.Type)
+	}
+}
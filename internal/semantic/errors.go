@@ -1,6 +1,10 @@
 package semantic
 
-import "fmt"
+import (
+	"fmt"
+
+	"relational-db/internal/parser"
+)
 
 // ErrorCode represents a semantic error code
 type ErrorCode int
@@ -26,6 +30,7 @@ const (
 	ErrInSubqueryMultiCol      ErrorCode = 5302
 	ErrDerivedTableNoAlias     ErrorCode = 5303
 	ErrCorrelatedRefNotVisible ErrorCode = 5304
+	ErrRecursiveCTEAggregate   ErrorCode = 5305
 
 	// Schema errors (5400-5499)
 	ErrTableAlreadyExists    ErrorCode = 5401
@@ -33,6 +38,18 @@ const (
 	ErrForeignKeyRefNotFound ErrorCode = 5403
 	ErrCircularDependency    ErrorCode = 5404
 	ErrTableNotFound         ErrorCode = 5405
+	ErrColumnNotFound        ErrorCode = 5406
+	ErrIndexAlreadyExists    ErrorCode = 5407
+	ErrIndexNotFound         ErrorCode = 5408
+	ErrDependentObjects      ErrorCode = 5409
+
+	// Window function errors (5500-5599)
+	ErrWindowNotAllowed    ErrorCode = 5501
+	ErrWindowRequiresOver  ErrorCode = 5502
+	ErrNestedWindow        ErrorCode = 5503
+	ErrInvalidWindowFrame  ErrorCode = 5504
+	ErrWrongWindowArgCount ErrorCode = 5505
+	ErrNotWindowFunction   ErrorCode = 5506
 )
 
 // ErrorCategory represents the category of semantic error
@@ -45,6 +62,7 @@ const (
 	CategorySubquery
 	CategorySchema
 	CategoryConstraint
+	CategoryWindow
 )
 
 func (ec ErrorCategory) String() string {
@@ -59,6 +77,8 @@ func (ec ErrorCategory) String() string {
 		return "Schema"
 	case CategoryConstraint:
 		return "Constraint"
+	case CategoryWindow:
+		return "Window"
 	default:
 		return "General"
 	}
@@ -95,6 +115,15 @@ func (se *SemanticError) WithHint(hint string) *SemanticError {
 	return se
 }
 
+// At locates the error at node, the part of the statement it concerns,
+// recording the node's text and its position in the source
+func (se *SemanticError) At(node parser.Node) *SemanticError {
+	pos := parser.PositionOf(node)
+	se.Location = ErrorLocation{Line: pos.Line, Column: pos.Column, Offset: pos.Offset}
+	se.Expression = node.String()
+	return se
+}
+
 // WithSuggestion adds a suggestion to the error
 func (se *SemanticError) WithSuggestion(suggestion string) *SemanticError {
 	se.Suggestion = suggestion
@@ -150,6 +179,15 @@ func NewSubqueryError(code ErrorCode, message string) *SemanticError {
 	}
 }
 
+// NewWindowError creates a window function related error
+func NewWindowError(code ErrorCode, message string) *SemanticError {
+	return &SemanticError{
+		Code:     code,
+		Category: CategoryWindow,
+		Message:  message,
+	}
+}
+
 // NewSchemaError creates a schema related error
 func NewSchemaError(code ErrorCode, message string) *SemanticError {
 	return &SemanticError{
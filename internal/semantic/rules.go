@@ -2,6 +2,8 @@ package semantic
 
 import (
 	"fmt"
+	"strconv"
+	"strings"
 
 	"relational-db/internal/compiler"
 	"relational-db/internal/parser"
@@ -60,10 +62,12 @@ func (r *AggregateValidationRule) Validate(compiled *compiler.CompiledQuery, ctx
 	}
 
 	// Walk SELECT clause for aggregates
-	for _, col := range selectStmt.SelectClause.Columns {
-		if r.hasAggregate(col) {
-			ctx.HasAggregates = true
-			ctx.AggregateInfo.InSelect = true
+	if selectStmt.SelectClause != nil {
+		for _, col := range selectStmt.SelectClause.Columns {
+			if r.hasAggregate(col) {
+				ctx.HasAggregates = true
+				ctx.AggregateInfo.InSelect = true
+			}
 		}
 	}
 
@@ -74,7 +78,7 @@ func (r *AggregateValidationRule) Validate(compiled *compiler.CompiledQuery, ctx
 			return NewAggregateError(
 				ErrAggregateInWhere,
 				"Aggregate functions are not allowed in WHERE clause",
-			).WithHint("Use HAVING clause instead")
+			).At(selectStmt.WhereClause.Condition).WithHint("Use HAVING clause instead")
 		}
 		ctx.InWhereClause = false
 	}
@@ -100,34 +104,337 @@ func (r *AggregateValidationRule) Validate(compiled *compiler.CompiledQuery, ctx
 	return nil
 }
 
-// hasAggregate checks if an expression contains an aggregate function
+// hasAggregate checks if an expression contains an aggregate function.
+// Subqueries aggregate their own rows, so they are not entered.
 func (r *AggregateValidationRule) hasAggregate(expr parser.Expression) bool {
 	if expr == nil {
 		return false
 	}
 
-	switch e := expr.(type) {
-	case *parser.FunctionCall:
-		// Check if it's an aggregate function
-		name := e.Name.Value
-		if name == "COUNT" || name == "SUM" || name == "AVG" || name == "MAX" || name == "MIN" {
-			return true
+	found := false
+	parser.Inspect(expr, func(node parser.Node) bool {
+		switch e := node.(type) {
+		case *parser.SubqueryExpression, *parser.ExistsExpression:
+			return false
+		case *parser.FunctionCall:
+			found = IsAggregateCall(e)
 		}
-		// Check arguments
-		for _, arg := range e.Arguments {
-			if r.hasAggregate(arg) {
-				return true
+		return !found
+	})
+	return found
+}
+
+// IsAggregateCall reports whether a function call is an aggregate. With
+// OVER it is a window function computed per row, though its arguments may
+// aggregate.
+func IsAggregateCall(call *parser.FunctionCall) bool {
+	if call.Over != nil {
+		return false
+	}
+	switch strings.ToUpper(call.Name.Value) {
+	case "COUNT", "SUM", "AVG", "MAX", "MIN":
+		return true
+	}
+	return false
+}
+
+// WindowValidationRule validates window function usage: where window
+// functions may appear, their arguments and their frames
+type WindowValidationRule struct{}
+
+// Name returns the rule name
+func (r *WindowValidationRule) Name() string {
+	return "Window Function Validation"
+}
+
+// Validate validates window function usage
+func (r *WindowValidationRule) Validate(compiled *compiler.CompiledQuery, ctx *ValidationContext) error {
+	selectStmt, ok := compiled.Statement.(*parser.SelectStatement)
+	if !ok {
+		return nil
+	}
+	return r.validateQuery(selectStmt, ctx)
+}
+
+// validateQuery validates a query and the queries it is built from
+func (r *WindowValidationRule) validateQuery(query *parser.SelectStatement, ctx *ValidationContext) error {
+	if query.With != nil {
+		for _, cte := range query.With.CTEs {
+			if err := r.validateQuery(cte.Query, ctx); err != nil {
+				return err
 			}
 		}
+	}
 
-	case *parser.BinaryExpression:
-		return r.hasAggregate(e.Left) || r.hasAggregate(e.Right)
+	if query.SetOperation != nil {
+		if err := r.validateQuery(query.SetOperation.Left, ctx); err != nil {
+			return err
+		}
+		return r.validateQuery(query.SetOperation.Right, ctx)
+	}
 
-	case *parser.UnaryExpression:
-		return r.hasAggregate(e.Operand)
+	// Window functions are computed after grouping, so they cannot filter
+	// or group rows
+	if query.FromClause != nil {
+		for _, table := range query.FromClause.Tables {
+			if subquery, ok := table.(*parser.SubqueryExpression); ok {
+				if err := r.validateQuery(subquery.Query, ctx); err != nil {
+					return err
+				}
+			}
+		}
+		for _, join := range query.FromClause.Joins {
+			if subquery, ok := join.Table.(*parser.SubqueryExpression); ok {
+				if err := r.validateQuery(subquery.Query, ctx); err != nil {
+					return err
+				}
+			}
+			if err := r.disallow(join.Condition, "JOIN conditions"); err != nil {
+				return err
+			}
+		}
+	}
+	if query.WhereClause != nil {
+		if err := r.disallow(query.WhereClause.Condition, "WHERE"); err != nil {
+			return err
+		}
+	}
+	if query.GroupBy != nil {
+		for _, expr := range query.GroupBy.Columns {
+			if err := r.disallow(expr, "GROUP BY"); err != nil {
+				return err
+			}
+		}
+	}
+	if query.Having != nil {
+		if err := r.disallow(query.Having.Condition, "HAVING"); err != nil {
+			return err
+		}
 	}
 
-	return false
+	var calls []*parser.FunctionCall
+	if query.SelectClause != nil {
+		for _, col := range query.SelectClause.Columns {
+			calls = functionCalls(col, calls)
+		}
+	}
+	if query.OrderBy != nil {
+		for _, order := range query.OrderBy.Orders {
+			calls = functionCalls(order.Expression, calls)
+		}
+	}
+	for _, call := range calls {
+		if err := r.validateCall(call); err != nil {
+			return err
+		}
+		if call.Over != nil {
+			ctx.HasWindowFunctions = true
+		}
+	}
+	return nil
+}
+
+// disallow rejects window functions in a clause that cannot contain them
+func (r *WindowValidationRule) disallow(expr parser.Expression, clause string) error {
+	for _, call := range functionCalls(expr, nil) {
+		if call.Over != nil {
+			return NewWindowError(
+				ErrWindowNotAllowed,
+				fmt.Sprintf("Window functions are not allowed in %s", clause),
+			).At(call).WithHint("Compute the window function in a subquery and filter its result")
+		}
+	}
+	return nil
+}
+
+// validateCall checks one function call of a select list or ORDER BY
+func (r *WindowValidationRule) validateCall(call *parser.FunctionCall) error {
+	name := strings.ToUpper(call.Name.Value)
+	minArgs, maxArgs, windowOnly := windowFunctionArity(name)
+
+	if call.Over == nil {
+		if windowOnly {
+			return NewWindowError(ErrWindowRequiresOver, fmt.Sprintf("Window function %s requires an OVER clause", name)).At(call)
+		}
+		return nil
+	}
+
+	if minArgs < 0 {
+		return NewWindowError(ErrNotWindowFunction, fmt.Sprintf("%s is not a window function", name)).At(call)
+	}
+	if call.Distinct {
+		return NewWindowError(ErrNotWindowFunction, fmt.Sprintf("DISTINCT is not supported in window function %s", name)).At(call)
+	}
+	if len(call.Arguments) < minArgs || len(call.Arguments) > maxArgs {
+		return NewWindowError(
+			ErrWrongWindowArgCount,
+			fmt.Sprintf("Window function %s takes %d to %d arguments, got %d", name, minArgs, maxArgs, len(call.Arguments)),
+		).At(call)
+	}
+
+	// Window functions cannot be nested, neither in the arguments nor in
+	// the window itself
+	nested := append([]parser.Expression{}, call.Arguments...)
+	nested = append(nested, windowSpecExpressions(call.Over)...)
+	for _, expr := range nested {
+		for _, inner := range functionCalls(expr, nil) {
+			if inner.Over != nil {
+				return NewWindowError(ErrNestedWindow, "Window function calls cannot be nested").At(inner)
+			}
+		}
+	}
+
+	return r.validateFrame(call)
+}
+
+// validateFrame checks the bounds of the window frame of a call
+func (r *WindowValidationRule) validateFrame(call *parser.FunctionCall) error {
+	spec := call.Over
+	frame := spec.Frame
+	if frame == nil {
+		return nil
+	}
+
+	if frame.Start.Type == parser.UnboundedFollowing {
+		return NewWindowError(ErrInvalidWindowFrame, "Frame start cannot be UNBOUNDED FOLLOWING").At(call)
+	}
+	if frame.End.Type == parser.UnboundedPreceding {
+		return NewWindowError(ErrInvalidWindowFrame, "Frame end cannot be UNBOUNDED PRECEDING").At(call)
+	}
+	if frame.Start.Type > frame.End.Type {
+		return NewWindowError(ErrInvalidWindowFrame, fmt.Sprintf("Frame starting at %s cannot end at %s", frame.Start, frame.End)).At(call)
+	}
+
+	for _, bound := range []*parser.FrameBound{frame.Start, frame.End} {
+		if bound.Offset == nil {
+			continue
+		}
+		if frame.Unit == parser.FrameRange && (spec.OrderBy == nil || len(spec.OrderBy.Orders) != 1) {
+			return NewWindowError(ErrInvalidWindowFrame, "RANGE with an offset requires exactly one ORDER BY column").At(call)
+		}
+		lit, ok := bound.Offset.(*parser.Literal)
+		if !ok {
+			if _, ok := bound.Offset.(*parser.Parameter); ok {
+				continue
+			}
+			return NewWindowError(ErrInvalidWindowFrame, fmt.Sprintf("Frame offset %s must be a constant", bound.Offset)).At(call)
+		}
+		offset, err := strconv.ParseFloat(fmt.Sprintf("%v", lit.Value), 64)
+		if err != nil || offset < 0 {
+			return NewWindowError(ErrInvalidWindowFrame, fmt.Sprintf("Frame offset %s must be a non-negative number", lit)).At(lit)
+		}
+		if frame.Unit == parser.FrameRows && offset != float64(int64(offset)) {
+			return NewWindowError(ErrInvalidWindowFrame, fmt.Sprintf("ROWS frame offset %s must be an integer", lit)).At(lit)
+		}
+	}
+	return nil
+}
+
+// windowFunctionArity returns the argument counts a function accepts with an
+// OVER clause, and whether it is only valid with one. minArgs is -1 for
+// functions that cannot be used as window functions.
+func windowFunctionArity(name string) (minArgs, maxArgs int, windowOnly bool) {
+	switch name {
+	case "ROW_NUMBER", "RANK", "DENSE_RANK":
+		return 0, 0, true
+	case "LAG", "LEAD":
+		return 1, 3, true
+	case "FIRST_VALUE", "LAST_VALUE":
+		return 1, 1, true
+	case "COUNT", "SUM", "AVG", "MIN", "MAX":
+		return 1, 1, false
+	default:
+		return -1, -1, false
+	}
+}
+
+// windowSpecExpressions returns the expressions of an OVER clause
+func windowSpecExpressions(spec *parser.WindowSpec) []parser.Expression {
+	exprs := append([]parser.Expression{}, spec.PartitionBy...)
+	if spec.OrderBy != nil {
+		for _, order := range spec.OrderBy.Orders {
+			exprs = append(exprs, order.Expression)
+		}
+	}
+	if spec.Frame != nil {
+		if spec.Frame.Start.Offset != nil {
+			exprs = append(exprs, spec.Frame.Start.Offset)
+		}
+		if spec.Frame.End.Offset != nil {
+			exprs = append(exprs, spec.Frame.End.Offset)
+		}
+	}
+	return exprs
+}
+
+// functionCalls appends the function calls in an expression to calls,
+// outermost first. Subqueries are not entered.
+func functionCalls(expr parser.Expression, calls []*parser.FunctionCall) []*parser.FunctionCall {
+	if expr == nil {
+		return calls
+	}
+
+	parser.Inspect(expr, func(node parser.Node) bool {
+		switch e := node.(type) {
+		case *parser.SubqueryExpression, *parser.ExistsExpression:
+			return false
+		case *parser.FunctionCall:
+			calls = append(calls, e)
+		}
+		return true
+	})
+	return calls
+}
+
+// QueryWindowFunctions returns the window function calls a query computes
+// in its select list and ORDER BY, in order of appearance
+func QueryWindowFunctions(query *parser.SelectStatement) []*parser.FunctionCall {
+	var exprs []parser.Expression
+	if query.SelectClause != nil {
+		exprs = append(exprs, query.SelectClause.Columns...)
+	}
+	if query.OrderBy != nil {
+		for _, order := range query.OrderBy.Orders {
+			exprs = append(exprs, order.Expression)
+		}
+	}
+
+	var windows []*parser.FunctionCall
+	for _, expr := range exprs {
+		for _, call := range functionCalls(expr, nil) {
+			if call.Over != nil {
+				windows = append(windows, call)
+			}
+		}
+	}
+	return windows
+}
+
+// QueryAggregates returns the aggregate calls a query computes in its
+// select list, HAVING and ORDER BY, in order of appearance
+func QueryAggregates(query *parser.SelectStatement) []*parser.FunctionCall {
+	var exprs []parser.Expression
+	if query.SelectClause != nil {
+		exprs = append(exprs, query.SelectClause.Columns...)
+	}
+	if query.Having != nil {
+		exprs = append(exprs, query.Having.Condition)
+	}
+	if query.OrderBy != nil {
+		for _, order := range query.OrderBy.Orders {
+			exprs = append(exprs, order.Expression)
+		}
+	}
+
+	var aggregates []*parser.FunctionCall
+	for _, expr := range exprs {
+		for _, call := range functionCalls(expr, nil) {
+			if IsAggregateCall(call) {
+				aggregates = append(aggregates, call)
+			}
+		}
+	}
+	return aggregates
 }
 
 // SubqueryValidationRule validates subquery semantics
@@ -138,12 +445,486 @@ func (r *SubqueryValidationRule) Name() string {
 	return "Subquery Validation"
 }
 
-// Validate validates subquery semantics
+// Validate collects the subqueries of a statement, detects references to
+// enclosing queries and checks that each subquery returns the number of
+// columns its position requires
 func (r *SubqueryValidationRule) Validate(compiled *compiler.CompiledQuery, ctx *ValidationContext) error {
-	// TODO: Implement subquery validation
-	// For now, just initialize the metadata
 	ctx.SubqueryInfo = make([]*SubqueryMetadata, 0)
-	return nil
+	ctx.CTEInfo = make([]*CTEMetadata, 0)
+	walk := &subqueryWalk{ctx: ctx}
+
+	switch stmt := compiled.Statement.(type) {
+	case *parser.SelectStatement:
+		walk.selectStatement(stmt)
+
+	case *parser.InsertStatement:
+		for _, row := range stmt.Values {
+			for _, value := range row {
+				walk.expression(value, LocationUnknown)
+			}
+		}
+		// The query of INSERT ... SELECT does not see the target table
+		if stmt.Query != nil {
+			outer := ctx.Scope
+			ctx.Scope = NewValidationScope(outer)
+			walk.selectStatement(stmt.Query)
+			ctx.Scope = outer
+		}
+		walk.addTable(stmt.TableName)
+		if conflict := stmt.OnConflict; conflict != nil && conflict.DoUpdate {
+			if table, found := ctx.ResolvedRefs.Tables[compiler.ExcludedTable]; found {
+				ctx.Scope.AddTable(compiler.ExcludedTable, table)
+			}
+			for _, set := range conflict.SetClauses {
+				walk.expression(set.Value, LocationUnknown)
+			}
+			if conflict.WhereClause != nil {
+				walk.expression(conflict.WhereClause.Condition, LocationWhere)
+			}
+		}
+		walk.returning(stmt.Returning)
+
+	case *parser.UpdateStatement:
+		walk.fromClause(stmt.From)
+		walk.addTable(stmt.TableName)
+		for _, set := range stmt.SetClauses {
+			walk.expression(set.Value, LocationUnknown)
+		}
+		if stmt.WhereClause != nil {
+			walk.expression(stmt.WhereClause.Condition, LocationWhere)
+		}
+		walk.returning(stmt.Returning)
+
+	case *parser.DeleteStatement:
+		walk.fromClause(stmt.Using)
+		walk.addTable(stmt.TableName)
+		if stmt.WhereClause != nil {
+			walk.expression(stmt.WhereClause.Condition, LocationWhere)
+		}
+		walk.returning(stmt.Returning)
+
+	case *parser.MergeStatement:
+		walk.fromItem(stmt.Source)
+		walk.addTable(stmt.Target)
+		walk.expression(stmt.Condition, LocationFrom)
+		for _, clause := range stmt.Clauses {
+			walk.expression(clause.Condition, LocationWhere)
+			for _, set := range clause.SetClauses {
+				walk.expression(set.Value, LocationUnknown)
+			}
+			for _, value := range clause.Values {
+				walk.expression(value, LocationUnknown)
+			}
+		}
+	}
+
+	ctx.HasSubqueries = len(ctx.SubqueryInfo) > 0
+	return walk.err
+}
+
+// subqueryWalk visits the subqueries of a statement, keeping the stack of
+// subqueries being analyzed so correlated references can be attributed to
+// every subquery they reach out of
+type subqueryWalk struct {
+	ctx   *ValidationContext
+	stack []*activeSubquery
+	err   error
+}
+
+// activeSubquery is a subquery being analyzed and the scope of its FROM items
+type activeSubquery struct {
+	meta  *SubqueryMetadata
+	scope *ValidationScope
+}
+
+// selectStatement makes a query's FROM items visible in the current scope
+// and visits all of its clauses
+func (w *subqueryWalk) selectStatement(stmt *parser.SelectStatement) {
+	if stmt.With != nil {
+		for _, cte := range stmt.With.CTEs {
+			w.cte(cte)
+		}
+	}
+
+	// The queries a set operation combines have scopes of their own
+	if stmt.SetOperation != nil {
+		for _, query := range []*parser.SelectStatement{stmt.SetOperation.Left, stmt.SetOperation.Right} {
+			outer := w.ctx.Scope
+			w.ctx.Scope = NewValidationScope(outer)
+			w.selectStatement(query)
+			w.ctx.Scope = outer
+		}
+		return
+	}
+
+	w.fromClause(stmt.FromClause)
+
+	if stmt.SelectClause != nil {
+		for _, col := range stmt.SelectClause.Columns {
+			w.expression(col, LocationSelect)
+		}
+	}
+	if stmt.WhereClause != nil {
+		w.expression(stmt.WhereClause.Condition, LocationWhere)
+	}
+	if stmt.GroupBy != nil {
+		for _, expr := range stmt.GroupBy.Columns {
+			w.expression(expr, LocationGroupBy)
+		}
+	}
+	if stmt.Having != nil {
+		w.expression(stmt.Having.Condition, LocationHaving)
+	}
+	if stmt.OrderBy != nil {
+		for _, order := range stmt.OrderBy.Orders {
+			w.expression(order.Expression, LocationOrderBy)
+		}
+	}
+}
+
+// fromClause adds the tables and derived tables of a FROM clause to the
+// current scope and visits its join conditions
+func (w *subqueryWalk) fromClause(from *parser.FromClause) {
+	if from == nil {
+		return
+	}
+	for _, table := range from.Tables {
+		w.fromItem(table)
+	}
+	for _, join := range from.Joins {
+		w.fromItem(join.Table)
+		w.expression(join.Condition, LocationFrom)
+	}
+}
+
+// returning visits the RETURNING clause of a data-modifying statement,
+// whose target table is in the current scope
+func (w *subqueryWalk) returning(returning []parser.Expression) {
+	for _, expr := range returning {
+		w.expression(expr, LocationSelect)
+	}
+}
+
+// cte records a common table expression and visits its terms, each in a
+// scope of its own
+func (w *subqueryWalk) cte(cte *parser.CommonTableExpression) {
+	meta := &CTEMetadata{
+		CTE:           cte,
+		Recursive:     cte.RecursiveTerm != nil,
+		HasAggregates: QueryHasAggregates(cte.Query),
+	}
+	for _, ref := range w.ctx.ResolvedRefs.CTERefs {
+		if ref.CTE == cte && !ref.SelfReference {
+			meta.References++
+		}
+	}
+	w.ctx.CTEInfo = append(w.ctx.CTEInfo, meta)
+
+	for _, term := range []*parser.SelectStatement{cte.Query, cte.RecursiveTerm} {
+		if term == nil {
+			continue
+		}
+		outer := w.ctx.Scope
+		w.ctx.Scope = NewValidationScope(outer)
+		w.selectStatement(term)
+		w.ctx.Scope = outer
+	}
+
+	// Each iteration aggregates only the previous iteration's rows, which
+	// is not what an aggregate over the whole result would give
+	if cte.RecursiveTerm != nil && QueryHasAggregates(cte.RecursiveTerm) {
+		w.fail(nil, NewSubqueryError(
+			ErrRecursiveCTEAggregate,
+			fmt.Sprintf("Recursive term of '%s' cannot use aggregate functions", cte.Name.Value),
+		).At(cte.Name))
+	}
+}
+
+// fromItem adds a table or derived table to the current scope
+func (w *subqueryWalk) fromItem(expr parser.Expression) {
+	switch e := expr.(type) {
+	case *parser.Identifier:
+		w.addTable(e)
+
+	case *parser.SubqueryExpression:
+		meta := w.subquery(e.Query, SubqueryDerivedTable, LocationFrom)
+		if e.Alias == nil {
+			w.fail(meta, NewSubqueryError(
+				ErrDerivedTableNoAlias,
+				"Subquery in FROM must have an alias",
+			).At(e).WithHint("Name it with AS, e.g. FROM (SELECT ...) AS t"))
+			return
+		}
+
+		// The compiler built the derived table's columns from its select list
+		if table, found := w.ctx.ResolvedRefs.Tables[e.Alias.Value]; found {
+			w.ctx.Scope.AddTable(e.Alias.Value, table)
+		}
+	}
+}
+
+// addTable adds a base table to the current scope under its alias or name
+func (w *subqueryWalk) addTable(ident *parser.Identifier) {
+	var table *compiler.TableMetadata
+	if ref, found := w.ctx.ResolvedRefs.CTERefs[ident]; found {
+		table = w.ctx.ResolvedRefs.CTEs[ref.CTE]
+	} else if w.ctx.Catalog != nil {
+		table, _ = w.ctx.Catalog.GetTable(ident.Value)
+	} else {
+		table, _ = w.ctx.ResolvedRefs.GetTable(ident.Value)
+	}
+	if table == nil {
+		return
+	}
+
+	name := ident.Value
+	if ident.Alias != nil {
+		name = ident.Alias.Value
+		w.ctx.Scope.AddAlias(name, ident.Value)
+	}
+	w.ctx.Scope.AddTable(name, table)
+}
+
+// expression visits the subqueries and column references in an expression
+func (w *subqueryWalk) expression(expr parser.Expression, loc ExpressionLocation) {
+	switch e := expr.(type) {
+	case *parser.SubqueryExpression:
+		w.subquery(e.Query, SubqueryScalar, loc)
+
+	case *parser.ExistsExpression:
+		w.subquery(e.Query, SubqueryExists, loc)
+
+	case *parser.BinaryExpression:
+		w.expression(e.Left, loc)
+		if subquery, ok := e.Right.(*parser.SubqueryExpression); ok && e.Operator == parser.In {
+			w.subquery(subquery.Query, SubqueryInPredicate, loc)
+			return
+		}
+		w.expression(e.Right, loc)
+
+	case *parser.UnaryExpression:
+		w.expression(e.Operand, loc)
+
+	case *parser.IsNullExpression:
+		w.expression(e.Expr, loc)
+
+	case *parser.BetweenExpression:
+		w.expression(e.Expr, loc)
+		w.expression(e.Lower, loc)
+		w.expression(e.Upper, loc)
+
+	case *parser.InListExpression:
+		w.expression(e.Expr, loc)
+		for _, value := range e.List {
+			w.expression(value, loc)
+		}
+
+	case *parser.LikeExpression:
+		w.expression(e.Expr, loc)
+		w.expression(e.Pattern, loc)
+		w.expression(e.Escape, loc)
+
+	case *parser.CastExpression:
+		w.expression(e.Expr, loc)
+
+	case *parser.CaseExpression:
+		w.expression(e.Operand, loc)
+		for _, when := range e.Whens {
+			w.expression(when.Condition, loc)
+			w.expression(when.Result, loc)
+		}
+		w.expression(e.Else, loc)
+
+	case *parser.FunctionCall:
+		for _, arg := range e.Arguments {
+			w.expression(arg, loc)
+		}
+		if e.Over != nil {
+			for _, expr := range windowSpecExpressions(e.Over) {
+				w.expression(expr, loc)
+			}
+		}
+
+	case *parser.Identifier:
+		w.column(e, "", e.Value)
+
+	case *parser.ColumnReference:
+		table := ""
+		if e.Table != nil {
+			table = e.Table.Value
+		}
+		w.column(e, table, e.Column.Value)
+	}
+}
+
+// column records a reference to a column of an enclosing query as a
+// correlation of each subquery between the reference and that query
+func (w *subqueryWalk) column(ref parser.Expression, table, column string) {
+	if len(w.stack) == 0 {
+		return
+	}
+
+	found, tableName := w.ctx.Scope.findColumn(table, column)
+	if found == nil {
+		// Unqualified names may be select list aliases; only qualified
+		// references are known to be columns
+		if table != "" && !w.ctx.Scope.HasTable(table) {
+			w.fail(w.stack[len(w.stack)-1].meta, NewSubqueryError(
+				ErrCorrelatedRefNotVisible,
+				fmt.Sprintf("Column '%s.%s' is not visible in this subquery", table, column),
+			).At(ref))
+		}
+		return
+	}
+
+	for i := len(w.stack) - 1; i >= 0; i-- {
+		active := w.stack[i]
+
+		// Count the scopes between the subquery and the one the column was
+		// found in; a column of the subquery itself ends the search
+		levels := 0
+		scope := active.scope
+		for scope != nil && scope != found {
+			scope = scope.Parent
+			levels++
+		}
+		if levels == 0 || scope == nil {
+			return
+		}
+
+		active.meta.IsCorrelated = true
+		active.meta.CorrelatedRefs = append(active.meta.CorrelatedRefs, CorrelatedReference{
+			Column:     column,
+			Table:      tableName,
+			OuterScope: levels,
+		})
+	}
+}
+
+// subquery analyzes a subquery in its own scope and checks its shape
+func (w *subqueryWalk) subquery(query *parser.SelectStatement, subqueryType SubqueryType, loc ExpressionLocation) *SubqueryMetadata {
+	meta := &SubqueryMetadata{
+		Query:        query,
+		Type:         subqueryType,
+		Location:     loc,
+		ExpectedRows: RowCountAny,
+		Valid:        true,
+	}
+	w.ctx.SubqueryInfo = append(w.ctx.SubqueryInfo, meta)
+
+	w.ctx.EnterSubquery()
+	if subqueryType == SubqueryDerivedTable {
+		// A derived table cannot see the FROM items next to it
+		w.ctx.Scope.Parent = w.ctx.Scope.Parent.Parent
+	}
+	w.stack = append(w.stack, &activeSubquery{meta: meta, scope: w.ctx.Scope})
+
+	w.selectStatement(query)
+	meta.ColumnCount = w.columnCount(query)
+	meta.HasAggregates = QueryHasAggregates(query)
+
+	w.stack = w.stack[:len(w.stack)-1]
+	w.ctx.ExitSubquery()
+
+	switch subqueryType {
+	case SubqueryScalar:
+		// More than one row is an error when the subquery is evaluated
+		meta.ExpectedRows = RowCountZeroOrOne
+		if meta.ColumnCount != 1 {
+			w.fail(meta, NewSubqueryError(
+				ErrScalarSubqueryMultiCol,
+				fmt.Sprintf("Subquery used as a value must return one column, got %d", meta.ColumnCount),
+			).At(query))
+		}
+		if query.Limit != nil {
+			if count, ok := query.Limit.Count.(*parser.Literal); ok && literalGreaterThanOne(count) {
+				w.ctx.AddWarning(SemanticWarning{
+					Message: "Scalar subquery may return more than one row",
+					Hint:    "Use LIMIT 1 or an aggregate to return a single value",
+				})
+			}
+		}
+
+	case SubqueryInPredicate:
+		if meta.ColumnCount != 1 {
+			w.fail(meta, NewSubqueryError(
+				ErrInSubqueryMultiCol,
+				fmt.Sprintf("Subquery in IN must return one column, got %d", meta.ColumnCount),
+			).At(query))
+		}
+	}
+
+	return meta
+}
+
+// columnCount returns the number of columns a subquery's select list
+// produces, expanding wildcards over the tables of its scope
+func (w *subqueryWalk) columnCount(query *parser.SelectStatement) int {
+	if query.SetOperation != nil {
+		if result, found := w.ctx.ResolvedRefs.QueryResults[query]; found {
+			return len(result.Columns)
+		}
+		return 0
+	}
+	if query.SelectClause == nil {
+		return 0
+	}
+
+	count := 0
+	scope := w.stack[len(w.stack)-1].scope
+	for _, col := range query.SelectClause.Columns {
+		wildcard, ok := col.(*parser.Wildcard)
+		if !ok {
+			count++
+			continue
+		}
+		if wildcard.Table != nil {
+			if table, found := scope.Tables[wildcard.Table.Value]; found {
+				count += len(table.Columns)
+			}
+			continue
+		}
+		for _, table := range scope.Tables {
+			count += len(table.Columns)
+		}
+	}
+	return count
+}
+
+// fail marks a subquery invalid, if the error concerns one, and keeps the
+// first error for the rule
+func (w *subqueryWalk) fail(meta *SubqueryMetadata, err *SemanticError) {
+	if meta != nil {
+		meta.Valid = false
+		meta.Error = err
+	}
+	if w.err == nil {
+		w.err = err
+	}
+}
+
+// literalGreaterThanOne reports whether a numeric literal exceeds one
+func literalGreaterThanOne(lit *parser.Literal) bool {
+	n, err := strconv.ParseInt(fmt.Sprintf("%v", lit.Value), 10, 64)
+	return err == nil && n > 1
+}
+
+// QueryHasAggregates reports whether a query computes aggregates in its
+// select list or HAVING clause. A set operation has aggregates if any query
+// it combines does.
+func QueryHasAggregates(query *parser.SelectStatement) bool {
+	if query.SetOperation != nil {
+		return QueryHasAggregates(query.SetOperation.Left) || QueryHasAggregates(query.SetOperation.Right)
+	}
+	aggregates := &AggregateValidationRule{}
+	if query.SelectClause != nil {
+		for _, col := range query.SelectClause.Columns {
+			if aggregates.hasAggregate(col) {
+				return true
+			}
+		}
+	}
+	return query.Having != nil && aggregates.hasAggregate(query.Having.Condition)
 }
 
 // SchemaValidationRule validates schema-related operations
@@ -165,6 +946,21 @@ func (r *SchemaValidationRule) Validate(compiled *compiler.CompiledQuery, ctx *V
 	case *parser.DropTableStatement:
 		return r.validateDropTable(stmt)
 
+	case *parser.AlterTableStatement:
+		return r.validateAlterTable(stmt)
+
+	case *parser.CreateIndexStatement:
+		return r.validateCreateIndex(stmt)
+
+	case *parser.DropIndexStatement:
+		return r.validateDropIndex(stmt)
+
+	case *parser.DropViewStatement:
+		if stmt.Cascade {
+			return nil
+		}
+		return r.checkDependents(stmt.ViewName.Value)
+
 	default:
 		return nil
 	}
@@ -179,7 +975,7 @@ func (r *SchemaValidationRule) validateCreateTable(stmt *parser.CreateTableState
 		return NewSchemaError(
 			ErrTableAlreadyExists,
 			fmt.Sprintf("Table '%s' already exists", tableName),
-		).WithHint("Use IF NOT EXISTS clause to avoid this error")
+		).At(stmt.TableName).WithHint("Use IF NOT EXISTS clause to avoid this error")
 	}
 
 	// Check for duplicate column names
@@ -190,7 +986,7 @@ func (r *SchemaValidationRule) validateCreateTable(stmt *parser.CreateTableState
 			return NewSchemaError(
 				ErrDuplicateColumn,
 				fmt.Sprintf("Duplicate column name '%s' in table '%s'", colName, tableName),
-			)
+			).At(col.Name)
 		}
 		columnNames[colName] = true
 	}
@@ -204,7 +1000,7 @@ func (r *SchemaValidationRule) validateCreateTable(stmt *parser.CreateTableState
 					return NewSchemaError(
 						ErrForeignKeyRefNotFound,
 						fmt.Sprintf("Foreign key references non-existent table '%s'", refTable),
-					)
+					).At(constraint.References.Table)
 				}
 			}
 		}
@@ -223,7 +1019,173 @@ func (r *SchemaValidationRule) validateDropTable(stmt *parser.DropTableStatement
 			return NewSchemaError(
 				ErrTableNotFound,
 				fmt.Sprintf("Table '%s' does not exist", tableName),
-			).WithHint("Use IF EXISTS clause to avoid this error")
+			).At(stmt.TableName).WithHint("Use IF EXISTS clause to avoid this error")
+		}
+	}
+
+	if !stmt.Cascade {
+		return r.checkDependents(tableName)
+	}
+
+	return nil
+}
+
+// checkDependents refuses to drop a table or view that views depend on
+func (r *SchemaValidationRule) checkDependents(name string) error {
+	if r.catalog == nil {
+		return nil
+	}
+
+	dependents := compiler.DependentViews(r.catalog, name)
+	if len(dependents) == 0 {
+		return nil
+	}
+	return NewSchemaError(
+		ErrDependentObjects,
+		fmt.Sprintf("Cannot drop '%s' because other objects depend on it: %s", name, strings.Join(dependents, ", ")),
+	).WithHint("Use DROP ... CASCADE to drop the dependent objects too")
+}
+
+// validateCreateIndex validates CREATE INDEX statement
+func (r *SchemaValidationRule) validateCreateIndex(stmt *parser.CreateIndexStatement) error {
+	if r.catalog == nil {
+		return nil
+	}
+
+	tableName := stmt.TableName.Value
+	table, err := r.catalog.GetTable(tableName)
+	if err != nil {
+		return NewSchemaError(
+			ErrTableNotFound,
+			fmt.Sprintf("Table '%s' does not exist", tableName),
+		).At(stmt.TableName)
+	}
+
+	indexName := stmt.IndexName.Value
+	if owner, found := compiler.FindIndexTable(r.catalog, indexName); found && !stmt.IfNotExists {
+		return NewSchemaError(
+			ErrIndexAlreadyExists,
+			fmt.Sprintf("Index '%s' already exists on table '%s'", indexName, owner),
+		).At(stmt.IndexName).WithHint("Use IF NOT EXISTS clause to avoid this error")
+	}
+
+	for _, col := range stmt.Columns {
+		if !table.HasColumn(col.Value) {
+			return NewSchemaError(
+				ErrColumnNotFound,
+				fmt.Sprintf("Column '%s' does not exist in table '%s'", col.Value, tableName),
+			).At(col)
+		}
+	}
+
+	return nil
+}
+
+// validateDropIndex validates DROP INDEX statement
+func (r *SchemaValidationRule) validateDropIndex(stmt *parser.DropIndexStatement) error {
+	indexName := stmt.IndexName.Value
+	if r.catalog == nil || stmt.IfExists {
+		return nil
+	}
+
+	if _, found := compiler.FindIndexTable(r.catalog, indexName); !found {
+		return NewSchemaError(
+			ErrIndexNotFound,
+			fmt.Sprintf("Index '%s' does not exist", indexName),
+		).At(stmt.IndexName).WithHint("Use IF EXISTS clause to avoid this error")
+	}
+
+	return nil
+}
+
+// validateAlterTable validates ALTER TABLE statement. Each action sees the
+// columns left by the actions before it.
+func (r *SchemaValidationRule) validateAlterTable(stmt *parser.AlterTableStatement) error {
+	tableName := stmt.TableName.Value
+	if r.catalog == nil {
+		return nil
+	}
+
+	table, err := r.catalog.GetTable(tableName)
+	if err != nil {
+		return NewSchemaError(
+			ErrTableNotFound,
+			fmt.Sprintf("Table '%s' does not exist", tableName),
+		).At(stmt.TableName)
+	}
+
+	columns := make(map[string]bool, len(table.Columns))
+	for _, col := range table.Columns {
+		columns[col.Name] = true
+	}
+	missingColumn := func(col *parser.Identifier) error {
+		return NewSchemaError(
+			ErrColumnNotFound,
+			fmt.Sprintf("Column '%s' does not exist in table '%s'", col.Value, tableName),
+		).At(col)
+	}
+
+	for _, action := range stmt.Actions {
+		switch action := action.(type) {
+		case *parser.AddColumnAction:
+			name := action.Column.Name.Value
+			if columns[name] {
+				return NewSchemaError(
+					ErrDuplicateColumn,
+					fmt.Sprintf("Duplicate column name '%s' in table '%s'", name, tableName),
+				).At(action.Column.Name).WithHint("Use RENAME COLUMN to give one of the columns another name")
+			}
+			columns[name] = true
+
+			for _, constraint := range action.Column.Constraints {
+				if constraint.References != nil && !r.catalog.TableExists(constraint.References.Table.Value) {
+					return NewSchemaError(
+						ErrForeignKeyRefNotFound,
+						fmt.Sprintf("Foreign key references non-existent table '%s'", constraint.References.Table.Value),
+					).At(constraint.References.Table)
+				}
+			}
+
+		case *parser.DropColumnAction:
+			if !columns[action.Column.Value] {
+				return missingColumn(action.Column)
+			}
+			delete(columns, action.Column.Value)
+
+		case *parser.RenameColumnAction:
+			if !columns[action.Column.Value] {
+				return missingColumn(action.Column)
+			}
+			if columns[action.NewName.Value] && action.Column.Value != action.NewName.Value {
+				return NewSchemaError(
+					ErrDuplicateColumn,
+					fmt.Sprintf("Duplicate column name '%s' in table '%s'", action.NewName.Value, tableName),
+				).At(action.NewName)
+			}
+			delete(columns, action.Column.Value)
+			columns[action.NewName.Value] = true
+
+		case *parser.RenameTableAction:
+			newName := action.NewName.Value
+			if newName != tableName && r.catalog.TableExists(newName) {
+				return NewSchemaError(
+					ErrTableAlreadyExists,
+					fmt.Sprintf("Table '%s' already exists", newName),
+				).At(action.NewName)
+			}
+
+		case *parser.AddConstraintAction:
+			for _, col := range action.Constraint.Columns {
+				if !columns[col.Value] {
+					return missingColumn(col)
+				}
+			}
+			if ref := action.Constraint.References; ref != nil && !r.catalog.TableExists(ref.Table.Value) {
+				return NewSchemaError(
+					ErrForeignKeyRefNotFound,
+					fmt.Sprintf("Foreign key references non-existent table '%s'", ref.Table.Value),
+				).At(ref.Table)
+			}
 		}
 	}
 
@@ -38,6 +38,9 @@ func (sa *SemanticAnalyzer) RegisterDefaultRules() {
 	// Add aggregate validation rule
 	sa.AddRule(&AggregateValidationRule{})
 
+	// Add window function validation rule
+	sa.AddRule(&WindowValidationRule{})
+
 	// Add subquery validation rule
 	sa.AddRule(&SubqueryValidationRule{})
 
@@ -96,6 +99,7 @@ func (sa *SemanticAnalyzer) Analyze(compiled *compiler.CompiledQuery) (*Semantic
 
 	// Extract metadata from context
 	info.HasAggregates = ctx.HasAggregates
+	info.HasWindowFunctions = ctx.HasWindowFunctions
 	info.HasSubqueries = ctx.HasSubqueries
 	info.HasGroupBy = ctx.HasGroupBy
 
@@ -107,6 +111,10 @@ func (sa *SemanticAnalyzer) Analyze(compiled *compiler.CompiledQuery) (*Semantic
 		info.SubqueryInfo = ctx.SubqueryInfo
 	}
 
+	if ctx.CTEInfo != nil {
+		info.CTEInfo = ctx.CTEInfo
+	}
+
 	if ctx.GroupByInfo != nil {
 		info.GroupByInfo = ctx.GroupByInfo
 	}
@@ -128,10 +136,16 @@ type SemanticInfo struct {
 	HasAggregates bool
 	AggregateInfo *AggregateMetadata
 
+	// Window function metadata
+	HasWindowFunctions bool
+
 	// Subquery metadata
 	HasSubqueries bool
 	SubqueryInfo  []*SubqueryMetadata
 
+	// Common table expression metadata
+	CTEInfo []*CTEMetadata
+
 	// GROUP BY metadata
 	HasGroupBy  bool
 	GroupByInfo *GroupByMetadata
@@ -185,12 +199,18 @@ type ValidationContext struct {
 	HasAggregates  bool
 	AggregateInfo  *AggregateMetadata
 
+	// Window function tracking
+	HasWindowFunctions bool
+
 	// Subquery tracking
 	SubqueryDepth int
 	HasSubqueries bool
 	SubqueryInfo  []*SubqueryMetadata
 	OuterScopes   []*ValidationScope
 
+	// Common table expression tracking
+	CTEInfo []*CTEMetadata
+
 	// GROUP BY tracking
 	HasGroupBy  bool
 	GroupByInfo *GroupByMetadata
@@ -299,6 +319,26 @@ func (vs *ValidationScope) HasColumn(table, column string) bool {
 	return false
 }
 
+// findColumn finds the scope, starting from this one and moving outwards,
+// that a column reference resolves in. It returns the scope and the name the
+// column's table is known by there, or nil if the column is not visible.
+func (vs *ValidationScope) findColumn(table, column string) (*ValidationScope, string) {
+	for scope := vs; scope != nil; scope = scope.Parent {
+		if table != "" {
+			if t, ok := scope.Tables[table]; ok && t.HasColumn(column) {
+				return scope, table
+			}
+			continue
+		}
+		for name, t := range scope.Tables {
+			if t.HasColumn(column) {
+				return scope, name
+			}
+		}
+	}
+	return nil, ""
+}
+
 // AddTable adds a table to the scope
 func (vs *ValidationScope) AddTable(name string, table *compiler.TableMetadata) {
 	vs.Tables[name] = table
@@ -353,6 +393,7 @@ const (
 	LocationGroupBy
 	LocationHaving
 	LocationOrderBy
+	LocationFrom
 )
 
 func (el ExpressionLocation) String() string {
@@ -367,6 +408,8 @@ func (el ExpressionLocation) String() string {
 		return "HAVING"
 	case LocationOrderBy:
 		return "ORDER BY"
+	case LocationFrom:
+		return "FROM"
 	default:
 		return "UNKNOWN"
 	}
@@ -411,14 +454,30 @@ type SubqueryMetadata struct {
 	CorrelatedRefs []CorrelatedReference
 
 	// Result info
-	ColumnCount  int
-	ExpectedRows RowCountExpectation
+	ColumnCount   int
+	ExpectedRows  RowCountExpectation
+	HasAggregates bool
 
 	// Validation
 	Valid bool
 	Error error
 }
 
+// CTEMetadata describes a common table expression
+type CTEMetadata struct {
+	CTE *parser.CommonTableExpression
+
+	// Recursive CTEs have a term that reads the CTE's own rows
+	Recursive bool
+
+	// Whether the anchor (or only) term aggregates
+	HasAggregates bool
+
+	// Number of references to the CTE, not counting the one in its own
+	// recursive term
+	References int
+}
+
 // SubqueryType indicates the type of subquery
 type SubqueryType int
 
@@ -4,6 +4,7 @@ import (
 	"testing"
 
 	"relational-db/internal/compiler"
+	"relational-db/internal/parser"
 )
 
 // TestNewSemanticAnalyzer tests creating a semantic analyzer
@@ -214,3 +215,250 @@ func TestSubqueryType(t *testing.T) {
 		})
 	}
 }
+
+// TestSubqueryValidation tests subquery classification and correlation
+func TestSubqueryValidation(t *testing.T) {
+	catalog := compiler.NewMockCatalog()
+	users := compiler.NewTableMetadata("users")
+	users.AddColumn(&compiler.ColumnMetadata{Name: "id", TableName: "users", DataType: compiler.DataTypeInteger})
+	users.AddColumn(&compiler.ColumnMetadata{Name: "name", TableName: "users", DataType: compiler.DataTypeText})
+	catalog.AddTable(users)
+	orders := compiler.NewTableMetadata("orders")
+	orders.AddColumn(&compiler.ColumnMetadata{Name: "id", TableName: "orders", DataType: compiler.DataTypeInteger})
+	orders.AddColumn(&compiler.ColumnMetadata{Name: "user_id", TableName: "orders", DataType: compiler.DataTypeInteger})
+	catalog.AddTable(orders)
+
+	analyze := func(sql string) *SemanticInfo {
+		stmt, err := parser.ParseSQL(sql)
+		if err != nil {
+			t.Fatalf("%s: parse failed: %v", sql, err)
+		}
+		compiled, err := compiler.NewQueryCompiler(catalog).Compile(stmt)
+		if err != nil {
+			t.Fatalf("%s: compile failed: %v", sql, err)
+		}
+		info, err := NewSemanticAnalyzer(catalog).Analyze(compiled)
+		if err != nil {
+			t.Fatalf("%s: analyze failed: %v", sql, err)
+		}
+		return info
+	}
+
+	info := analyze("SELECT name FROM users WHERE EXISTS (SELECT id FROM orders WHERE orders.user_id = users.id)")
+	if len(info.SubqueryInfo) != 1 {
+		t.Fatalf("Expected 1 subquery, got %d", len(info.SubqueryInfo))
+	}
+	exists := info.SubqueryInfo[0]
+	if exists.Type != SubqueryExists || !exists.IsCorrelated {
+		t.Errorf("Expected correlated EXISTS subquery, got %s (correlated=%v)", exists.Type, exists.IsCorrelated)
+	}
+	if len(exists.CorrelatedRefs) != 1 || exists.CorrelatedRefs[0].OuterScope != 1 {
+		t.Errorf("Expected one reference to the enclosing query, got %+v", exists.CorrelatedRefs)
+	}
+
+	info = analyze("SELECT name FROM users WHERE id IN (SELECT user_id FROM orders)")
+	if len(info.SubqueryInfo) != 1 || info.SubqueryInfo[0].Type != SubqueryInPredicate || info.SubqueryInfo[0].IsCorrelated {
+		t.Errorf("Expected uncorrelated IN subquery, got %+v", info.SubqueryInfo)
+	}
+
+	failures := []struct {
+		sql  string
+		code ErrorCode
+	}{
+		{"SELECT name, (SELECT id, user_id FROM orders) AS o FROM users", ErrScalarSubqueryMultiCol},
+		{"SELECT name FROM users WHERE id IN (SELECT id, user_id FROM orders)", ErrInSubqueryMultiCol},
+	}
+	for _, tt := range failures {
+		info := analyze(tt.sql)
+		if len(info.Errors) == 0 || info.Errors[0].Code != tt.code {
+			t.Errorf("%s: expected error %d, got %+v", tt.sql, tt.code, info.Errors)
+		}
+	}
+}
+
+// TestCTEMetadata tests that CTEs are recorded with their references
+func TestCTEMetadata(t *testing.T) {
+	catalog := compiler.NewMockCatalog()
+	nodes := compiler.NewTableMetadata("nodes")
+	nodes.AddColumn(&compiler.ColumnMetadata{Name: "id", TableName: "nodes", DataType: compiler.DataTypeInteger})
+	nodes.AddColumn(&compiler.ColumnMetadata{Name: "parent_id", TableName: "nodes", DataType: compiler.DataTypeInteger})
+	catalog.AddTable(nodes)
+
+	stmt, err := parser.ParseSQL(`WITH RECURSIVE tree AS (
+		SELECT id FROM nodes WHERE parent_id = 0
+		UNION ALL
+		SELECT n.id FROM nodes n JOIN tree t ON n.parent_id = t.id)
+		SELECT a.id FROM tree a WHERE a.id IN (SELECT id FROM tree)`)
+	if err != nil {
+		t.Fatalf("parse failed: %v", err)
+	}
+	compiled, err := compiler.NewQueryCompiler(catalog).Compile(stmt)
+	if err != nil {
+		t.Fatalf("compile failed: %v", err)
+	}
+	info, err := NewSemanticAnalyzer(catalog).Analyze(compiled)
+	if err != nil {
+		t.Fatalf("analyze failed: %v", err)
+	}
+
+	if !info.IsValid() {
+		t.Fatalf("Expected valid query, got %+v", info.Errors)
+	}
+	if len(info.CTEInfo) != 1 {
+		t.Fatalf("Expected 1 CTE, got %d", len(info.CTEInfo))
+	}
+	if meta := info.CTEInfo[0]; !meta.Recursive || meta.References != 2 {
+		t.Errorf("Expected recursive CTE with 2 references, got %+v", meta)
+	}
+}
+
+// TestWindowValidation tests where window functions may appear and how
+// their arguments and frames are checked
+func TestWindowValidation(t *testing.T) {
+	catalog := compiler.NewMockCatalog()
+	sales := compiler.NewTableMetadata("sales")
+	sales.AddColumn(&compiler.ColumnMetadata{Name: "region", TableName: "sales", DataType: compiler.DataTypeText})
+	sales.AddColumn(&compiler.ColumnMetadata{Name: "day", TableName: "sales", DataType: compiler.DataTypeInteger})
+	sales.AddColumn(&compiler.ColumnMetadata{Name: "amount", TableName: "sales", DataType: compiler.DataTypeInteger})
+	catalog.AddTable(sales)
+
+	analyze := func(sql string) *SemanticInfo {
+		stmt, err := parser.ParseSQL(sql)
+		if err != nil {
+			t.Fatalf("%s: parse failed: %v", sql, err)
+		}
+		compiled, err := compiler.NewQueryCompiler(catalog).Compile(stmt)
+		if err != nil {
+			t.Fatalf("%s: compile failed: %v", sql, err)
+		}
+		info, err := NewSemanticAnalyzer(catalog).Analyze(compiled)
+		if err != nil {
+			t.Fatalf("%s: analyze failed: %v", sql, err)
+		}
+		return info
+	}
+
+	valid := []string{
+		"SELECT region, ROW_NUMBER() OVER (PARTITION BY region ORDER BY day) FROM sales",
+		"SELECT LAG(amount, 1, 0) OVER (ORDER BY day) FROM sales ORDER BY RANK() OVER (ORDER BY amount)",
+		"SELECT SUM(amount) OVER (ORDER BY day ROWS BETWEEN 2 PRECEDING AND CURRENT ROW) FROM sales",
+		"SELECT AVG(amount) OVER (ORDER BY day RANGE BETWEEN 3 PRECEDING AND 3 FOLLOWING) FROM sales",
+	}
+	for _, sql := range valid {
+		info := analyze(sql)
+		if len(info.Errors) != 0 {
+			t.Errorf("%s: unexpected errors %+v", sql, info.Errors)
+		}
+		if !info.HasWindowFunctions {
+			t.Errorf("%s: expected HasWindowFunctions", sql)
+		}
+	}
+
+	failures := []struct {
+		sql  string
+		code ErrorCode
+	}{
+		{"SELECT region FROM sales WHERE ROW_NUMBER() OVER (ORDER BY day) = 1", ErrWindowNotAllowed},
+		{"SELECT region FROM sales GROUP BY region HAVING SUM(amount) OVER () > 1", ErrWindowNotAllowed},
+		{"SELECT ROW_NUMBER() FROM sales", ErrWindowRequiresOver},
+		{"SELECT SUM(RANK() OVER (ORDER BY day)) OVER () FROM sales", ErrNestedWindow},
+		{"SELECT SUM(amount) OVER (ORDER BY day ROWS BETWEEN CURRENT ROW AND 1 PRECEDING) FROM sales", ErrInvalidWindowFrame},
+		{"SELECT SUM(amount) OVER (RANGE BETWEEN 1 PRECEDING AND CURRENT ROW) FROM sales", ErrInvalidWindowFrame},
+		{"SELECT LAG(amount, 1, 0, 2) OVER (ORDER BY day) FROM sales", ErrWrongWindowArgCount},
+	}
+	for _, tt := range failures {
+		info := analyze(tt.sql)
+		if len(info.Errors) == 0 || info.Errors[0].Code != tt.code {
+			t.Errorf("%s: expected error %d, got %+v", tt.sql, tt.code, info.Errors)
+		}
+	}
+}
+
+// TestViewDependencies tests that tables and views cannot be dropped while
+// views depend on them, unless the drop cascades
+func TestViewDependencies(t *testing.T) {
+	catalog := compiler.NewMockCatalog()
+	users := compiler.NewTableMetadata("users")
+	users.AddColumn(&compiler.ColumnMetadata{Name: "id", TableName: "users", DataType: compiler.DataTypeInteger})
+	catalog.AddTable(users)
+	orders := compiler.NewTableMetadata("orders")
+	orders.AddColumn(&compiler.ColumnMetadata{Name: "id", TableName: "orders", DataType: compiler.DataTypeInteger})
+	catalog.AddTable(orders)
+	catalog.AddView(&compiler.ViewMetadata{Name: "user_ids", Query: "SELECT id FROM users", DependsOn: []string{"users"}})
+	catalog.AddView(&compiler.ViewMetadata{Name: "first_user", Query: "SELECT id FROM user_ids WHERE id = 1", DependsOn: []string{"user_ids"}})
+
+	analyze := func(sql string) *SemanticInfo {
+		stmt, err := parser.ParseSQL(sql)
+		if err != nil {
+			t.Fatalf("%s: parse failed: %v", sql, err)
+		}
+		compiled, err := compiler.NewQueryCompiler(catalog).Compile(stmt)
+		if err != nil {
+			t.Fatalf("%s: compile failed: %v", sql, err)
+		}
+		info, err := NewSemanticAnalyzer(catalog).Analyze(compiled)
+		if err != nil {
+			t.Fatalf("%s: analyze failed: %v", sql, err)
+		}
+		return info
+	}
+
+	valid := []string{
+		"DROP TABLE orders",
+		"DROP TABLE users CASCADE",
+		"DROP VIEW first_user",
+		"DROP VIEW user_ids CASCADE",
+	}
+	for _, sql := range valid {
+		if info := analyze(sql); len(info.Errors) != 0 {
+			t.Errorf("%s: unexpected errors %+v", sql, info.Errors)
+		}
+	}
+
+	for _, sql := range []string{"DROP TABLE users", "DROP VIEW user_ids RESTRICT"} {
+		info := analyze(sql)
+		if len(info.Errors) == 0 || info.Errors[0].Code != ErrDependentObjects {
+			t.Errorf("%s: expected error %d, got %+v", sql, ErrDependentObjects, info.Errors)
+		}
+	}
+}
+
+// TestErrorLocations tests that semantic errors point at the part of the
+// statement they concern
+func TestErrorLocations(t *testing.T) {
+	catalog := compiler.NewMockCatalog()
+	users := compiler.NewTableMetadata("users")
+	users.AddColumn(&compiler.ColumnMetadata{Name: "id", TableName: "users", DataType: compiler.DataTypeInteger})
+	users.AddColumn(&compiler.ColumnMetadata{Name: "name", TableName: "users", DataType: compiler.DataTypeText})
+	catalog.AddTable(users)
+
+	tests := []struct {
+		sql          string
+		line, column int
+		expression   string
+	}{
+		{"SELECT id FROM users\nWHERE ROW_NUMBER() OVER (ORDER BY id) = 1", 2, 7, "ROW_NUMBER() OVER (ORDER BY id ASC)"},
+		{"SELECT name, (SELECT id, name FROM users) AS o FROM users", 1, 22, "SELECT id, name FROM users"},
+	}
+	for _, tt := range tests {
+		stmt, err := parser.ParseSQL(tt.sql)
+		if err != nil {
+			t.Fatalf("%s: parse failed: %v", tt.sql, err)
+		}
+		compiled, err := compiler.NewQueryCompiler(catalog).Compile(stmt)
+		if err != nil {
+			t.Fatalf("%s: compile failed: %v", tt.sql, err)
+		}
+		info, err := NewSemanticAnalyzer(catalog).Analyze(compiled)
+		if err != nil {
+			t.Fatalf("%s: analyze failed: %v", tt.sql, err)
+		}
+		if len(info.Errors) == 0 {
+			t.Errorf("%s: expected an error", tt.sql)
+			continue
+		}
+		if se := info.Errors[0]; se.Location.Line != tt.line || se.Location.Column != tt.column || se.Expression != tt.expression {
+			t.Errorf("%s: expected error at %d:%d in %q, got %d:%d in %q", tt.sql, tt.line, tt.column, tt.expression, se.Location.Line, se.Location.Column, se.Expression)
+		}
+	}
+}
@@ -2,11 +2,16 @@ package database
 
 import (
 	"context"
+	"database/sql"
 	"fmt"
+	"reflect"
+	"strconv"
 	"sync"
 	"time"
 
 	"relational-db/internal/config"
+	"relational-db/internal/executor"
+	"relational-db/internal/parser"
 	"relational-db/internal/storage"
 )
 
@@ -31,11 +36,21 @@ type Connection interface {
 	// Query operations
 	Execute(query string, params ...interface{}) (Result, error)
 	ExecuteContext(ctx context.Context, query string, params ...interface{}) (Result, error)
+	Prepare(query string) (Stmt, error)
+	
+	// Script execution; statements run in order and stop at the first error
+	ExecScript(script string, opts ScriptOptions) ([]Result, error)
+	ExecScriptContext(ctx context.Context, script string, opts ScriptOptions) ([]Result, error)
 	
 	// Transaction management
 	Begin() (Transaction, error)
 	BeginContext(ctx context.Context) (Transaction, error)
 	
+	// Large objects; changes made through a handle are committed when it closes
+	CreateLargeObject() (LargeObjectID, error)
+	OpenLargeObject(id LargeObjectID, mode LargeObjectMode) (LargeObject, error)
+	DeleteLargeObject(id LargeObjectID) error
+	
 	// Connection management
 	Close() error
 	Ping() error
@@ -47,6 +62,11 @@ type Transaction interface {
 	Execute(query string, params ...interface{}) (Result, error)
 	ExecuteContext(ctx context.Context, query string, params ...interface{}) (Result, error)
 	
+	// Large objects; changes become visible when the transaction commits
+	CreateLargeObject() (LargeObjectID, error)
+	OpenLargeObject(id LargeObjectID, mode LargeObjectMode) (LargeObject, error)
+	DeleteLargeObject(id LargeObjectID) error
+	
 	// Transaction control
 	Commit() error
 	Rollback() error
@@ -55,6 +75,18 @@ type Transaction interface {
 	Status() TransactionStatus
 }
 
+// Stmt represents a prepared statement that can be executed repeatedly
+// with different parameter values
+type Stmt interface {
+	Execute(params ...interface{}) (Result, error)
+	ExecuteContext(ctx context.Context, params ...interface{}) (Result, error)
+	
+	// NumParams returns the number of ? or $n parameters to bind
+	NumParams() int
+	
+	Close() error
+}
+
 // Result represents query execution results
 type Result interface {
 	// Row retrieval
@@ -168,6 +200,12 @@ type DatabaseStats struct {
 	TransactionsTotal   int64
 	StorageStats        storage.StorageStats
 	Uptime              time.Duration
+	
+	// Rows removed by TTL expiry, per table
+	ExpiredRows         map[string]uint64
+	
+	// Prepared plan cache activity
+	PlanCache           executor.PlanCacheStats
 }
 
 // HealthStatus represents database health
@@ -185,6 +223,14 @@ type DatabaseImpl struct {
 	storage       storage.StorageEngine
 	connections   map[string]*ConnectionImpl
 	startTime     time.Time
+	ttlWorker     *executor.TTLWorker
+	largeObjects  *largeObjectStore
+	catalog       *executor.SystemCatalog
+	tables        *executor.CatalogManager
+	planner       *executor.QueryPlanner
+	executor      *executor.Executor
+	locks         *executor.LockManager
+	transactions  *executor.TransactionExecutor
 	
 	// Statistics
 	connectionsTotal    int64
@@ -192,13 +238,38 @@ type DatabaseImpl struct {
 	transactionsTotal   int64
 }
 
-// NewDatabase creates a new database instance
+// NewDatabase creates a new database instance over the tables of its system
+// catalog. The catalog of a new database takes its first page, so nothing
+// may allocate pages in the storage engine before.
 func NewDatabase(cfg *config.Config, storageEngine storage.StorageEngine) (*DatabaseImpl, error) {
+	schemaManager := executor.NewSchemaManager()
+	catalogManager := executor.NewCatalogManager(schemaManager)
+	catalog := executor.NewSystemCatalog(storageEngine, schemaManager, catalogManager, cfg.Storage.PageSize)
+	if err := catalog.Bootstrap(); err != nil {
+		return nil, fmt.Errorf("failed to load system catalog: %w", err)
+	}
+	
+	execConfig := executor.DefaultExecutorConfig()
+	execConfig.PageSize = cfg.Storage.PageSize
+	exec := executor.NewExecutorWithConfig(storageEngine, nil, execConfig)
+	if err := exec.RegisterTables(catalogManager); err != nil {
+		return nil, fmt.Errorf("failed to load tables: %w", err)
+	}
+	exec.RegisterMaterializedViews(catalogManager)
+	
+	locks := executor.NewLockManager()
 	return &DatabaseImpl{
-		config:      cfg,
-		storage:     storageEngine,
-		connections: make(map[string]*ConnectionImpl),
-		startTime:   time.Now(),
+		config:       cfg,
+		storage:      storageEngine,
+		connections:  make(map[string]*ConnectionImpl),
+		startTime:    time.Now(),
+		largeObjects: newLargeObjectStore(storageEngine, cfg.Storage.PageSize),
+		catalog:      catalog,
+		tables:       catalogManager,
+		planner:      executor.NewQueryPlanner(catalogManager, cfg.Database.PlanCacheSize),
+		executor:     exec,
+		locks:        locks,
+		transactions: executor.NewTransactionExecutor(exec, locks),
 	}, nil
 }
 
@@ -218,6 +289,7 @@ func (db *DatabaseImpl) Connect(ctx context.Context) (Connection, error) {
 		id:       connID,
 		database: db,
 		storage:  db.storage,
+		session:  db.transactions.NewSession(connID),
 		created:  time.Now(),
 		ctx:      ctx,
 	}
@@ -231,17 +303,205 @@ func (db *DatabaseImpl) Connect(ctx context.Context) (Connection, error) {
 // Close closes the database and all connections
 func (db *DatabaseImpl) Close() error {
 	db.mu.Lock()
-	defer db.mu.Unlock()
+	connections := db.connections
+	db.connections = make(map[string]*ConnectionImpl)
+	worker := db.ttlWorker
+	db.mu.Unlock()
 	
-	// Close all connections
-	for _, conn := range db.connections {
+	// Close all connections; each removes itself from the database, so
+	// db.mu must not be held here
+	for _, conn := range connections {
 		conn.Close()
 	}
-	db.connections = make(map[string]*ConnectionImpl)
+	
+	// Stop background row expiry
+	if worker != nil {
+		worker.Stop()
+	}
 	
 	return nil
 }
 
+// StartTTLWorker starts background row expiry over the tables of the
+// database, replacing a running worker. A zero interval or batch size
+// selects the default.
+func (db *DatabaseImpl) StartTTLWorker(interval time.Duration, batchSize int) {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+	
+	if db.ttlWorker != nil {
+		db.ttlWorker.Stop()
+	}
+	db.ttlWorker = executor.NewTTLWorker(db.tables, db.transactions, interval, batchSize)
+	db.ttlWorker.Start()
+}
+
+// queryPlanner returns the current query planner
+func (db *DatabaseImpl) queryPlanner() *executor.QueryPlanner {
+	db.mu.RLock()
+	defer db.mu.RUnlock()
+	
+	return db.planner
+}
+
+// executeDML runs a prepared INSERT, UPDATE, DELETE or MERGE with bound
+// parameter values, under the lock manager. With a nil txn the statement
+// commits on its own. The rows of its RETURNING clause are the rows of the
+// result.
+func (db *DatabaseImpl) executeDML(ctx context.Context, txn *executor.Transaction, plan *executor.PreparedPlan, params []interface{}) (Result, error) {
+	db.mu.RLock()
+	transactions, planner := db.transactions, db.planner
+	db.mu.RUnlock()
+	
+	var result *executor.DMLResult
+	var err error
+	if txn != nil {
+		result, err = transactions.ExecuteDMLInTransaction(ctx, txn.ID, planner, plan, params)
+	} else {
+		result, err = transactions.ExecuteDML(ctx, planner, plan, params)
+	}
+	if err != nil {
+		return nil, err
+	}
+	
+	dml := &ResultImpl{
+		columns:      []string{},
+		rows:         [][]interface{}{},
+		rowsAffected: result.RowsAffected,
+		lastInsertID: result.LastInsertID,
+	}
+	if result.Returning != nil {
+		for _, col := range result.Returning.Schema.Columns {
+			dml.columns = append(dml.columns, col.Name)
+		}
+		for _, tuple := range result.Returning.Tuples {
+			dml.rows = append(dml.rows, tuple.Values)
+		}
+	}
+	return dml, nil
+}
+
+// executeQuery runs a prepared SELECT with bound parameter values. The rows
+// it selects are the rows of the result.
+func (db *DatabaseImpl) executeQuery(ctx context.Context, plan *executor.PreparedPlan, params []interface{}) (Result, error) {
+	db.mu.RLock()
+	exec := db.executor
+	db.mu.RUnlock()
+	
+	if plan.Plan == nil {
+		return nil, fmt.Errorf("statement is not a planned query")
+	}
+	rows, err := exec.ExecuteWithParameters(ctx, plan.Plan, params)
+	if err != nil {
+		return nil, err
+	}
+	
+	query := &ResultImpl{
+		columns: resultColumns(plan, rows),
+		rows:    make([][]interface{}, 0, len(rows.Tuples)),
+	}
+	for _, tuple := range rows.Tuples {
+		query.rows = append(query.rows, tuple.Values)
+	}
+	return query, nil
+}
+
+// resultColumns returns the names of the columns a query selects, from its
+// rows or, when it selected none, from the compiled query
+func resultColumns(plan *executor.PreparedPlan, rows *executor.ResultSet) []string {
+	columns := []string{}
+	if rows.Schema != nil {
+		for _, col := range rows.Schema.Columns {
+			columns = append(columns, col.Name)
+		}
+		return columns
+	}
+	
+	if stmt, ok := plan.Statement.(*parser.SelectStatement); ok {
+		if result, found := plan.Compiled.ResolvedRefs.QueryResults[stmt]; found {
+			for _, col := range result.Columns {
+				columns = append(columns, col.Name)
+			}
+		}
+	}
+	return columns
+}
+
+// prepareQuery plans a query, or takes its plan from the plan cache, and
+// binds params to its ? and $n placeholders
+func (db *DatabaseImpl) prepareQuery(query string, params []interface{}) (*executor.PreparedPlan, []interface{}, error) {
+	plan, err := db.queryPlanner().Prepare(query)
+	if err != nil {
+		return nil, nil, err
+	}
+	
+	bound, err := plan.Bind(params)
+	if err != nil {
+		return nil, nil, err
+	}
+	
+	return plan, bound, nil
+}
+
+// executeDDL runs a schema statement; the system catalog persists the change
+func (db *DatabaseImpl) executeDDL(ctx context.Context, stmt parser.Statement) (Result, error) {
+	db.mu.RLock()
+	exec, catalog, planner := db.executor, db.catalog, db.planner
+	db.mu.RUnlock()
+	
+	if err := exec.ExecuteDDL(ctx, catalog, planner, stmt); err != nil {
+		return nil, err
+	}
+	
+	return &ResultImpl{
+		columns: []string{},
+		rows:    [][]interface{}{},
+	}, nil
+}
+
+// changesSchema reports whether a statement creates, alters or drops a
+// table, index or view
+func changesSchema(stmt parser.Statement) bool {
+	switch stmt.(type) {
+	case *parser.CreateTableStatement, *parser.DropTableStatement, *parser.AlterTableStatement,
+		*parser.CreateIndexStatement, *parser.DropIndexStatement, *parser.CreateViewStatement,
+		*parser.DropViewStatement, *parser.RefreshMaterializedViewStatement:
+		return true
+	default:
+		return false
+	}
+}
+
+// controlsTransaction reports whether a statement is BEGIN, COMMIT,
+// ROLLBACK or a savepoint statement
+func controlsTransaction(stmt parser.Statement) bool {
+	switch stmt.(type) {
+	case *parser.BeginStatement, *parser.CommitStatement, *parser.RollbackStatement,
+		*parser.SavepointStatement, *parser.ReleaseSavepointStatement:
+		return true
+	default:
+		return false
+	}
+}
+
+// readsRows reports whether a statement is a query, a SELECT
+func readsRows(stmt parser.Statement) bool {
+	_, ok := stmt.(*parser.SelectStatement)
+	return ok
+}
+
+// changesRows reports whether a statement is an INSERT, UPDATE, DELETE or
+// MERGE
+func changesRows(stmt parser.Statement) bool {
+	switch stmt.(type) {
+	case *parser.InsertStatement, *parser.UpdateStatement, *parser.DeleteStatement,
+		*parser.MergeStatement:
+		return true
+	default:
+		return false
+	}
+}
+
 // CreateTable creates a new table
 func (db *DatabaseImpl) CreateTable(name string, schema TableSchema) error {
 	// TODO: Implement table creation
@@ -265,6 +525,11 @@ func (db *DatabaseImpl) Stats() DatabaseStats {
 	db.mu.RLock()
 	defer db.mu.RUnlock()
 	
+	expiredRows := make(map[string]uint64)
+	if db.ttlWorker != nil {
+		expiredRows = db.ttlWorker.Stats()
+	}
+	
 	return DatabaseStats{
 		ConnectionsActive:   len(db.connections),
 		ConnectionsTotal:    db.connectionsTotal,
@@ -273,6 +538,8 @@ func (db *DatabaseImpl) Stats() DatabaseStats {
 		TransactionsTotal:   db.transactionsTotal,
 		StorageStats:        db.storage.Stats(),
 		Uptime:              time.Since(db.startTime),
+		ExpiredRows:         expiredRows,
+		PlanCache:           db.planner.Cache().Stats(),
 	}
 }
 
@@ -320,6 +587,7 @@ type ConnectionImpl struct {
 	id       string
 	database *DatabaseImpl
 	storage  storage.StorageEngine
+	session  *executor.Session // Transaction opened with BEGIN
 	created  time.Time
 	closed   bool
 	ctx      context.Context
@@ -339,13 +607,37 @@ func (c *ConnectionImpl) ExecuteContext(ctx context.Context, query string, param
 		return nil, fmt.Errorf("connection is closed")
 	}
 	
-	// TODO: Implement query parsing and execution
+	// Parameters are bound as values, never spliced into the SQL text
+	plan, bound, err := c.database.prepareQuery(query, params)
+	if err != nil {
+		return nil, err
+	}
+	stmt := plan.Statement
+	
 	c.database.incrementQueryCount()
+	if changesRows(stmt) {
+		return c.database.executeDML(ctx, c.session.Transaction(), plan, bound)
+	}
+	if readsRows(stmt) {
+		return c.database.executeQuery(ctx, plan, bound)
+	}
+	if changesSchema(stmt) {
+		return c.database.executeDDL(ctx, stmt)
+	}
+	if controlsTransaction(stmt) {
+		if err := c.session.Execute(stmt); err != nil {
+			return nil, err
+		}
+		return &ResultImpl{
+			columns: []string{},
+			rows:    [][]interface{}{},
+		}, nil
+	}
 	
 	return &ResultImpl{
 		columns: []string{},
 		rows:    [][]interface{}{},
-	}, fmt.Errorf("query execution not yet implemented")
+	}, fmt.Errorf("%s statements are not supported", plan.Compiled.QueryType)
 }
 
 // Begin starts a new transaction
@@ -362,14 +654,99 @@ func (c *ConnectionImpl) BeginContext(ctx context.Context) (Transaction, error)
 		return nil, fmt.Errorf("connection is closed")
 	}
 	
-	// TODO: Implement transaction management
+	transactions := c.database.transactions
+	txn, err := transactions.BeginTransaction(transactions.GetIsolationLevel())
+	if err != nil {
+		return nil, err
+	}
+	
 	return &TransactionImpl{
+		connection:   c,
+		status:       TxActive,
+		ctx:          ctx,
+		txn:          txn,
+		largeObjects: c.database.largeObjects.begin(),
+	}, nil
+}
+
+// Prepare compiles a statement once so it can be executed repeatedly.
+// Plans are shared between connections through the database's plan cache.
+func (c *ConnectionImpl) Prepare(query string) (Stmt, error) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	
+	if c.closed {
+		return nil, fmt.Errorf("connection is closed")
+	}
+	
+	plan, err := c.database.queryPlanner().Prepare(query)
+	if err != nil {
+		return nil, err
+	}
+	
+	return &StmtImpl{
 		connection: c,
-		status:     TxActive,
-		ctx:        ctx,
+		plan:       plan,
 	}, nil
 }
 
+// CreateLargeObject creates a new, empty large object
+func (c *ConnectionImpl) CreateLargeObject() (LargeObjectID, error) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	
+	if c.closed {
+		return 0, fmt.Errorf("connection is closed")
+	}
+	
+	txn := c.database.largeObjects.begin()
+	id, err := txn.create()
+	if err != nil {
+		txn.rollback()
+		return 0, err
+	}
+	
+	return id, txn.commit()
+}
+
+// OpenLargeObject opens a large object; writes are committed on Close
+func (c *ConnectionImpl) OpenLargeObject(id LargeObjectID, mode LargeObjectMode) (LargeObject, error) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	
+	if c.closed {
+		return nil, fmt.Errorf("connection is closed")
+	}
+	
+	txn := c.database.largeObjects.begin()
+	handle, err := txn.open(id, mode)
+	if err != nil {
+		txn.rollback()
+		return nil, err
+	}
+	
+	handle.autocommit = true
+	return handle, nil
+}
+
+// DeleteLargeObject deletes a large object and releases its pages
+func (c *ConnectionImpl) DeleteLargeObject(id LargeObjectID) error {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	
+	if c.closed {
+		return fmt.Errorf("connection is closed")
+	}
+	
+	txn := c.database.largeObjects.begin()
+	if err := txn.delete(id); err != nil {
+		txn.rollback()
+		return err
+	}
+	
+	return txn.commit()
+}
+
 // Close closes the connection
 func (c *ConnectionImpl) Close() error {
 	c.mu.Lock()
@@ -382,7 +759,8 @@ func (c *ConnectionImpl) Close() error {
 	c.closed = true
 	c.database.removeConnection(c.id)
 	
-	return nil
+	// An open BEGIN ... COMMIT block is rolled back
+	return c.session.Close()
 }
 
 // Ping tests the connection
@@ -399,10 +777,12 @@ func (c *ConnectionImpl) Ping() error {
 
 // TransactionImpl implements the Transaction interface
 type TransactionImpl struct {
-	mu         sync.RWMutex
-	connection *ConnectionImpl
-	status     TransactionStatus
-	ctx        context.Context
+	mu           sync.RWMutex
+	connection   *ConnectionImpl
+	status       TransactionStatus
+	ctx          context.Context
+	txn          *executor.Transaction
+	largeObjects *largeObjectTxn
 }
 
 // Execute executes a query within the transaction
@@ -419,11 +799,57 @@ func (tx *TransactionImpl) ExecuteContext(ctx context.Context, query string, par
 		return nil, fmt.Errorf("transaction is not active")
 	}
 	
-	// TODO: Implement transactional query execution
+	db := tx.connection.database
+	plan, bound, err := db.prepareQuery(query, params)
+	if err != nil {
+		return nil, err
+	}
+	stmt := plan.Statement
+	
+	db.incrementQueryCount()
+	if controlsTransaction(stmt) {
+		if err := tx.executeSavepoint(stmt); err != nil {
+			return nil, err
+		}
+		return &ResultImpl{
+			columns: []string{},
+			rows:    [][]interface{}{},
+		}, nil
+	}
+	
+	if changesRows(stmt) {
+		return db.executeDML(ctx, tx.txn, plan, bound)
+	}
+	if readsRows(stmt) {
+		return db.executeQuery(ctx, plan, bound)
+	}
+	
+	// Schema changes are not transactional; they take effect at once
+	if changesSchema(stmt) {
+		return db.executeDDL(ctx, stmt)
+	}
+	
 	return &ResultImpl{
 		columns: []string{},
 		rows:    [][]interface{}{},
-	}, fmt.Errorf("transactional query execution not yet implemented")
+	}, fmt.Errorf("%s statements are not supported", plan.Compiled.QueryType)
+}
+
+// executeSavepoint runs a savepoint statement in the transaction. The
+// transaction itself ends through Commit and Rollback only.
+func (tx *TransactionImpl) executeSavepoint(stmt parser.Statement) error {
+	transactions := tx.connection.database.transactions
+	switch stmt := stmt.(type) {
+	case *parser.SavepointStatement:
+		return transactions.CreateSavepoint(tx.txn.ID, stmt.Name.Value)
+	case *parser.ReleaseSavepointStatement:
+		return transactions.ReleaseSavepoint(tx.txn.ID, stmt.Name.Value)
+	case *parser.RollbackStatement:
+		if stmt.Savepoint != nil {
+			return transactions.RollbackToSavepoint(tx.txn.ID, stmt.Savepoint.Value)
+		}
+	}
+	return fmt.Errorf("%s cannot run inside a transaction; use Commit or Rollback", stmt.String())
 }
 
 // Commit commits the transaction
@@ -435,9 +861,12 @@ func (tx *TransactionImpl) Commit() error {
 		return fmt.Errorf("transaction is not active")
 	}
 	
-	// TODO: Implement transaction commit
 	tx.status = TxCommitted
-	return nil
+	if err := tx.connection.database.transactions.CommitTransaction(tx.txn.ID); err != nil {
+		tx.largeObjects.rollback()
+		return err
+	}
+	return tx.largeObjects.commit()
 }
 
 // Rollback rolls back the transaction
@@ -449,9 +878,53 @@ func (tx *TransactionImpl) Rollback() error {
 		return fmt.Errorf("transaction is not active")
 	}
 	
-	// TODO: Implement transaction rollback
 	tx.status = TxRolledBack
-	return nil
+	err := tx.connection.database.transactions.RollbackTransaction(tx.txn.ID)
+	if lobErr := tx.largeObjects.rollback(); err == nil {
+		err = lobErr
+	}
+	return err
+}
+
+// CreateLargeObject creates a new large object within the transaction
+func (tx *TransactionImpl) CreateLargeObject() (LargeObjectID, error) {
+	tx.mu.Lock()
+	defer tx.mu.Unlock()
+	
+	if tx.status != TxActive {
+		return 0, fmt.Errorf("transaction is not active")
+	}
+	
+	return tx.largeObjects.create()
+}
+
+// OpenLargeObject opens a large object within the transaction
+func (tx *TransactionImpl) OpenLargeObject(id LargeObjectID, mode LargeObjectMode) (LargeObject, error) {
+	tx.mu.Lock()
+	defer tx.mu.Unlock()
+	
+	if tx.status != TxActive {
+		return nil, fmt.Errorf("transaction is not active")
+	}
+	
+	handle, err := tx.largeObjects.open(id, mode)
+	if err != nil {
+		return nil, err
+	}
+	
+	return handle, nil
+}
+
+// DeleteLargeObject deletes a large object when the transaction commits
+func (tx *TransactionImpl) DeleteLargeObject(id LargeObjectID) error {
+	tx.mu.Lock()
+	defer tx.mu.Unlock()
+	
+	if tx.status != TxActive {
+		return fmt.Errorf("transaction is not active")
+	}
+	
+	return tx.largeObjects.delete(id)
 }
 
 // Status returns the transaction status
@@ -469,6 +942,9 @@ type ResultImpl struct {
 	rows     [][]interface{}
 	position int
 	closed   bool
+	
+	rowsAffected int64
+	lastInsertID int64
 }
 
 // Next advances to the next row
@@ -484,7 +960,11 @@ func (r *ResultImpl) Next() bool {
 	return r.position <= len(r.rows)
 }
 
-// Scan copies the current row's values into the provided destinations
+// Scan copies the current row's values into the provided destinations,
+// which must be pointers. A value is converted to the type pointed to as
+// database/sql converts it: numbers and strings convert to each other,
+// *interface{} receives the value as it is, and NULL can only be stored
+// through a pointer that may be nil, such as **string or *interface{}.
 func (r *ResultImpl) Scan(dest ...interface{}) error {
 	r.mu.RLock()
 	defer r.mu.RUnlock()
@@ -502,14 +982,122 @@ func (r *ResultImpl) Scan(dest ...interface{}) error {
 		return fmt.Errorf("destination count mismatch: expected %d, got %d", len(row), len(dest))
 	}
 	
-	// TODO: Implement proper type conversion
 	for i, val := range row {
-		dest[i] = val
+		if err := convertAssign(dest[i], val); err != nil {
+			return fmt.Errorf("cannot scan column %d: %w", i+1, err)
+		}
 	}
 	
 	return nil
 }
 
+// convertAssign stores src, a value of a result row, through the pointer dest
+func convertAssign(dest, src interface{}) error {
+	switch d := dest.(type) {
+	case *interface{}:
+		if d == nil {
+			return fmt.Errorf("destination is a nil pointer")
+		}
+		*d = src
+		return nil
+	case sql.Scanner:
+		return d.Scan(src)
+	}
+	
+	ptr := reflect.ValueOf(dest)
+	if ptr.Kind() != reflect.Ptr || ptr.IsNil() {
+		return fmt.Errorf("destination %T is not a non-nil pointer", dest)
+	}
+	target := ptr.Elem()
+	
+	if src == nil {
+		switch target.Kind() {
+		case reflect.Ptr, reflect.Interface, reflect.Slice, reflect.Map:
+			target.Set(reflect.Zero(target.Type()))
+			return nil
+		}
+		return fmt.Errorf("cannot store NULL in %s", target.Type())
+	}
+	
+	// A pointer destination receives a new value, which holds src
+	if target.Kind() == reflect.Ptr {
+		value := reflect.New(target.Type().Elem())
+		if err := convertAssign(value.Interface(), src); err != nil {
+			return err
+		}
+		target.Set(value)
+		return nil
+	}
+	
+	source := reflect.ValueOf(src)
+	if source.Type().AssignableTo(target.Type()) {
+		if b, ok := src.([]byte); ok {
+			src = append([]byte(nil), b...)
+			source = reflect.ValueOf(src)
+		}
+		target.Set(source)
+		return nil
+	}
+	
+	text := asString(src)
+	switch target.Kind() {
+	case reflect.String:
+		target.SetString(text)
+		return nil
+	case reflect.Slice:
+		if target.Type().Elem().Kind() == reflect.Uint8 {
+			target.SetBytes([]byte(text))
+			return nil
+		}
+	case reflect.Bool:
+		b, err := strconv.ParseBool(text)
+		if err != nil {
+			return fmt.Errorf("converting %T %q to bool: %w", src, text, err)
+		}
+		target.SetBool(b)
+		return nil
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		n, err := strconv.ParseInt(text, 10, target.Type().Bits())
+		if err != nil {
+			return fmt.Errorf("converting %T %q to %s: %w", src, text, target.Type(), err)
+		}
+		target.SetInt(n)
+		return nil
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		n, err := strconv.ParseUint(text, 10, target.Type().Bits())
+		if err != nil {
+			return fmt.Errorf("converting %T %q to %s: %w", src, text, target.Type(), err)
+		}
+		target.SetUint(n)
+		return nil
+	case reflect.Float32, reflect.Float64:
+		f, err := strconv.ParseFloat(text, target.Type().Bits())
+		if err != nil {
+			return fmt.Errorf("converting %T %q to %s: %w", src, text, target.Type(), err)
+		}
+		target.SetFloat(f)
+		return nil
+	}
+	return fmt.Errorf("unsupported conversion from %T to %s", src, target.Type())
+}
+
+// asString formats a value the way convertAssign parses it
+func asString(src interface{}) string {
+	switch v := src.(type) {
+	case string:
+		return v
+	case []byte:
+		return string(v)
+	case time.Time:
+		return v.Format(time.RFC3339Nano)
+	case float64:
+		return strconv.FormatFloat(v, 'g', -1, 64)
+	case float32:
+		return strconv.FormatFloat(float64(v), 'g', -1, 32)
+	}
+	return fmt.Sprint(src)
+}
+
 // Close closes the result set
 func (r *ResultImpl) Close() error {
 	r.mu.Lock()
@@ -521,12 +1109,13 @@ func (r *ResultImpl) Close() error {
 
 // RowsAffected returns the number of affected rows
 func (r *ResultImpl) RowsAffected() int64 {
-	return int64(len(r.rows))
+	return r.rowsAffected
 }
 
-// LastInsertID returns the last inserted ID
+// LastInsertID returns the last value an INSERT generated for an
+// AUTO_INCREMENT column, 0 if it generated none
 func (r *ResultImpl) LastInsertID() int64 {
-	return 0 // TODO: Implement
+	return r.lastInsertID
 }
 
 // Columns returns the column names
@@ -0,0 +1,371 @@
+package database
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"relational-db/internal/config"
+	"relational-db/internal/storage"
+)
+
+// memoryStorage is an in-memory storage engine
+type memoryStorage struct {
+	pages  map[storage.PageID][]byte
+	nextID storage.PageID
+}
+
+func newMemoryStorage() *memoryStorage {
+	return &memoryStorage{pages: make(map[storage.PageID][]byte), nextID: 1}
+}
+
+func (ms *memoryStorage) AllocatePage() (storage.PageID, error) {
+	id := ms.nextID
+	ms.nextID++
+	ms.pages[id] = make([]byte, 4096)
+	return id, nil
+}
+
+func (ms *memoryStorage) DeallocatePage(id storage.PageID) error {
+	delete(ms.pages, id)
+	return nil
+}
+
+func (ms *memoryStorage) ReadPage(id storage.PageID) (*storage.Page, error) {
+	data, exists := ms.pages[id]
+	if !exists {
+		return nil, storage.ErrInvalidPageID
+	}
+	return &storage.Page{ID: id, Data: append([]byte(nil), data...)}, nil
+}
+
+func (ms *memoryStorage) WritePage(page *storage.Page) error {
+	if _, exists := ms.pages[page.ID]; !exists {
+		return fmt.Errorf("page %d not allocated", page.ID)
+	}
+	ms.pages[page.ID] = append([]byte(nil), page.Data...)
+	return nil
+}
+
+func (ms *memoryStorage) Sync() error  { return nil }
+func (ms *memoryStorage) Close() error { return nil }
+func (ms *memoryStorage) Stats() storage.StorageStats {
+	return storage.StorageStats{TotalPages: uint64(len(ms.pages))}
+}
+
+// openDatabase opens a database over engine and connects to it
+func openDatabase(t *testing.T, engine storage.StorageEngine) (*DatabaseImpl, Connection) {
+	t.Helper()
+
+	db, err := NewDatabase(config.Default(), engine)
+	if err != nil {
+		t.Fatalf("failed to open database: %v", err)
+	}
+	conn, err := db.Connect(context.Background())
+	if err != nil {
+		t.Fatalf("failed to connect: %v", err)
+	}
+	return db, conn
+}
+
+func TestSchemaSurvivesRestart(t *testing.T) {
+	engine := newMemoryStorage()
+
+	db, conn := openDatabase(t, engine)
+	for _, sql := range []string{
+		"CREATE TABLE users (id INTEGER PRIMARY KEY, email VARCHAR(50)) WITH (clustered = true)",
+		"CREATE UNIQUE INDEX idx_users_email ON users (email)",
+		"ALTER TABLE users ADD COLUMN name VARCHAR(20)",
+	} {
+		if _, err := conn.Execute(sql); err != nil {
+			t.Fatalf("%s failed: %v", sql, err)
+		}
+	}
+	if result, err := conn.Execute("INSERT INTO users (id, email, name) VALUES (1, 'a@example.com', 'ann')"); err != nil || result.RowsAffected() != 1 {
+		t.Fatalf("insert failed: %v", err)
+	}
+	db.Close()
+
+	// The tables are loaded from the catalog and their rows from storage
+	db, conn = openDatabase(t, engine)
+	defer db.Close()
+	if _, err := conn.Execute("CREATE TABLE users (id INTEGER PRIMARY KEY) WITH (clustered = true)"); err == nil {
+		t.Error("expected users to exist after restart")
+	}
+	result, err := conn.Execute("UPDATE users SET id = id RETURNING id, email, name")
+	if err != nil {
+		t.Fatalf("failed to read users: %v", err)
+	}
+	if rows := result.(*ResultImpl).rows; len(rows) != 1 || fmt.Sprint(rows[0]) != "[1 a@example.com ann]" {
+		t.Errorf("expected the inserted user to survive the restart, got %v", rows)
+	}
+	if _, err := conn.Execute("INSERT INTO users (id, email, name) VALUES (2, 'a@example.com', 'bob')"); err == nil {
+		t.Error("expected the unique index to be enforced after restart")
+	}
+	if _, err := conn.Execute("INSERT INTO users (id, email, name) VALUES (2, 'b@example.com', 'bob')"); err != nil {
+		t.Errorf("expected the users table to accept rows after restart: %v", err)
+	}
+}
+
+func TestHeapRowsSurviveRestart(t *testing.T) {
+	engine := newMemoryStorage()
+
+	db, conn := openDatabase(t, engine)
+	for _, sql := range []string{
+		"CREATE TABLE notes (body VARCHAR(20))",
+		"INSERT INTO notes VALUES ('x'), ('x'), ('y')",
+		"DELETE FROM notes WHERE body = 'y'",
+	} {
+		if _, err := conn.Execute(sql); err != nil {
+			t.Fatalf("%s failed: %v", sql, err)
+		}
+	}
+
+	// A rolled back insert is not stored
+	tx, err := conn.Begin()
+	if err != nil {
+		t.Fatalf("failed to begin: %v", err)
+	}
+	if _, err := tx.Execute("INSERT INTO notes VALUES ('z')"); err != nil {
+		t.Fatalf("insert failed: %v", err)
+	}
+	if err := tx.Rollback(); err != nil {
+		t.Fatalf("rollback failed: %v", err)
+	}
+	db.Close()
+
+	db, conn = openDatabase(t, engine)
+	defer db.Close()
+	result, err := conn.Execute("UPDATE notes SET body = body RETURNING body")
+	if err != nil {
+		t.Fatalf("failed to read notes: %v", err)
+	}
+	if rows := result.(*ResultImpl).rows; fmt.Sprint(rows) != "[[x] [x]]" {
+		t.Errorf("expected both duplicate notes to survive the restart, got %v", rows)
+	}
+	if result, err := conn.Execute("DELETE FROM notes WHERE body = 'x'"); err != nil || result.RowsAffected() != 2 {
+		t.Errorf("expected both notes to be deleted after restart, got %v", err)
+	}
+}
+
+func TestSelect(t *testing.T) {
+	db, conn := openDatabase(t, newMemoryStorage())
+	defer db.Close()
+
+	for _, sql := range []string{
+		"CREATE TABLE t (id INTEGER PRIMARY KEY, name VARCHAR(10))",
+		"INSERT INTO t VALUES (1, 'ann'), (2, 'bob'), (3, 'cy')",
+	} {
+		if _, err := conn.Execute(sql); err != nil {
+			t.Fatalf("%s failed: %v", sql, err)
+		}
+	}
+
+	result, err := conn.Execute("SELECT id FROM t WHERE id = ?", 2)
+	if err != nil {
+		t.Fatalf("select failed: %v", err)
+	}
+	if rows := result.(*ResultImpl).rows; fmt.Sprint(result.Columns()) != "[id]" || fmt.Sprint(rows) != "[[2]]" {
+		t.Errorf("expected column id holding [[2]], got %v holding %v", result.Columns(), rows)
+	}
+
+	// A query that selects no rows still names its columns
+	result, err = conn.Execute("SELECT id, name FROM t WHERE id = ?", 9)
+	if err != nil {
+		t.Fatalf("select failed: %v", err)
+	}
+	if rows := result.(*ResultImpl).rows; fmt.Sprint(result.Columns()) != "[id name]" || len(rows) != 0 {
+		t.Errorf("expected columns [id name] and no rows, got %v holding %v", result.Columns(), rows)
+	}
+
+	// A transaction reads its own changes
+	tx, err := conn.Begin()
+	if err != nil {
+		t.Fatalf("failed to begin: %v", err)
+	}
+	if _, err := tx.Execute("INSERT INTO t VALUES (4, 'dee')"); err != nil {
+		t.Fatalf("insert failed: %v", err)
+	}
+	result, err = tx.Execute("SELECT x.name AS who FROM t x WHERE x.id > $1 ORDER BY id DESC LIMIT $2", 1, 2)
+	if err != nil {
+		t.Fatalf("select failed: %v", err)
+	}
+	if rows := result.(*ResultImpl).rows; fmt.Sprint(result.Columns()) != "[who]" || fmt.Sprint(rows) != "[[dee] [cy]]" {
+		t.Errorf("expected column who holding [[dee] [cy]], got %v holding %v", result.Columns(), rows)
+	}
+	if err := tx.Rollback(); err != nil {
+		t.Fatalf("rollback failed: %v", err)
+	}
+
+	stmt, err := conn.Prepare("SELECT name FROM t ORDER BY 1 LIMIT 2 OFFSET ?")
+	if err != nil {
+		t.Fatalf("prepare failed: %v", err)
+	}
+	defer stmt.Close()
+	result, err = stmt.Execute(1)
+	if err != nil {
+		t.Fatalf("prepared select failed: %v", err)
+	}
+	if rows := result.(*ResultImpl).rows; fmt.Sprint(rows) != "[[bob] [cy]]" {
+		t.Errorf("expected [[bob] [cy]] after the rollback, got %v", rows)
+	}
+}
+
+func TestScan(t *testing.T) {
+	db, conn := openDatabase(t, newMemoryStorage())
+	defer db.Close()
+
+	if _, err := conn.Execute("CREATE TABLE u (id INTEGER PRIMARY KEY, name VARCHAR(10), score DOUBLE)"); err != nil {
+		t.Fatalf("create failed: %v", err)
+	}
+	result, err := conn.Execute("INSERT INTO u (id, name, score) VALUES (1, 'ann', 2.5), (2, NULL, 3) RETURNING id, name")
+	if err != nil {
+		t.Fatalf("insert failed: %v", err)
+	}
+
+	// Values are converted to the types the destinations point to
+	var id int
+	var name string
+	if !result.Next() {
+		t.Fatal("expected a first returned row")
+	}
+	if err := result.Scan(&id, &name); err != nil || id != 1 || name != "ann" {
+		t.Errorf("expected 1 and ann, got %d and %q (%v)", id, name, err)
+	}
+	var text string
+	var raw interface{}
+	if err := result.Scan(&text, &raw); err != nil || text != "1" || raw != "ann" {
+		t.Errorf("expected \"1\" and ann, got %q and %v (%v)", text, raw, err)
+	}
+
+	// NULL needs a destination that can hold it
+	if !result.Next() {
+		t.Fatal("expected a second returned row")
+	}
+	if err := result.Scan(&id, &name); err == nil {
+		t.Error("expected NULL to be rejected for a string")
+	}
+	named := new(string)
+	var id64 int64
+	if err := result.Scan(&id64, &named); err != nil || id64 != 2 || named != nil {
+		t.Errorf("expected 2 and a nil name, got %d and %v (%v)", id64, named, err)
+	}
+	if err := result.Scan(id, &raw); err == nil {
+		t.Error("expected a destination that is not a pointer to be rejected")
+	}
+	if result.Next() {
+		t.Error("expected two returned rows")
+	}
+
+	result, err = conn.Execute("SELECT score FROM u ORDER BY id")
+	if err != nil {
+		t.Fatalf("select failed: %v", err)
+	}
+	var score float64
+	var truncated int
+	if !result.Next() || result.Scan(&score) != nil || score != 2.5 {
+		t.Errorf("expected score 2.5, got %v", score)
+	}
+	if err := result.Scan(&truncated); err == nil {
+		t.Error("expected 2.5 to be rejected for an int")
+	}
+}
+
+func TestPreparedSelect(t *testing.T) {
+	db, conn := openDatabase(t, newMemoryStorage())
+	defer db.Close()
+
+	for _, sql := range []string{
+		"CREATE TABLE t (id INTEGER PRIMARY KEY, name VARCHAR(10))",
+		"INSERT INTO t VALUES (1, 'ann'), (2, 'bob')",
+	} {
+		if _, err := conn.Execute(sql); err != nil {
+			t.Fatalf("%s failed: %v", sql, err)
+		}
+	}
+
+	stmt, err := conn.Prepare("SELECT name FROM t WHERE id = ?")
+	if err != nil {
+		t.Fatalf("prepare failed: %v", err)
+	}
+	defer stmt.Close()
+	if stmt.NumParams() != 1 {
+		t.Errorf("expected 1 parameter, got %d", stmt.NumParams())
+	}
+
+	// One cached plan runs with each set of values
+	before := db.Stats().PlanCache
+	for id, want := range map[int]string{1: "[[ann]]", 2: "[[bob]]", 3: "[]"} {
+		result, err := stmt.Execute(id)
+		if err != nil {
+			t.Fatalf("execute with %d failed: %v", id, err)
+		}
+		if rows := result.(*ResultImpl).rows; fmt.Sprint(rows) != want {
+			t.Errorf("id %d: expected %s, got %v", id, want, rows)
+		}
+	}
+	if after := db.Stats().PlanCache; after.Misses != before.Misses || after.Size != before.Size {
+		t.Errorf("expected the statement to reuse its plan, cache went from %+v to %+v", before, after)
+	}
+
+	// The same SQL prepared on another connection shares the plan
+	other, err := db.Connect(context.Background())
+	if err != nil {
+		t.Fatalf("failed to connect: %v", err)
+	}
+	shared, err := other.Prepare("select name from t where id = ?")
+	if err != nil {
+		t.Fatalf("prepare failed: %v", err)
+	}
+	if shared.(*StmtImpl).plan != stmt.(*StmtImpl).plan {
+		t.Error("expected connections to share the cached plan")
+	}
+	if _, err := stmt.Execute(); err == nil {
+		t.Error("expected an error for a missing parameter value")
+	}
+}
+
+func TestNewDatabaseKeepsForeignData(t *testing.T) {
+	engine := newMemoryStorage()
+	pageID, _ := engine.AllocatePage()
+	engine.WritePage(&storage.Page{ID: pageID, Data: []byte("not a catalog")})
+
+	if _, err := NewDatabase(config.Default(), engine); err == nil {
+		t.Fatal("expected a database whose first page holds no catalog to be rejected")
+	}
+	if string(engine.pages[pageID]) != "not a catalog" {
+		t.Error("expected the first page to be left as it was")
+	}
+}
+
+func TestExpiredRowsLeaveUniqueIndex(t *testing.T) {
+	db, conn := openDatabase(t, newMemoryStorage())
+	defer db.Close()
+
+	for _, sql := range []string{
+		"CREATE TABLE tokens (id INTEGER PRIMARY KEY, tok VARCHAR(10), issued_at TIMESTAMP) WITH (clustered = true, ttl_column = issued_at, ttl = '1 hour')",
+		"CREATE UNIQUE INDEX tok ON tokens (tok)",
+	} {
+		if _, err := conn.Execute(sql); err != nil {
+			t.Fatalf("%s failed: %v", sql, err)
+		}
+	}
+	insert := "INSERT INTO tokens VALUES (?, ?, ?)"
+	if _, err := conn.Execute(insert, 1, "abc", time.Now().Add(-2*time.Hour)); err != nil {
+		t.Fatalf("insert failed: %v", err)
+	}
+	if _, err := conn.Execute(insert, 2, "abc", time.Now()); err == nil {
+		t.Fatal("expected the unique index to reject a second abc")
+	}
+
+	db.StartTTLWorker(time.Hour, 10)
+	if removed, err := db.ttlWorker.RunOnce(); err != nil || removed != 1 {
+		t.Fatalf("expected 1 expired row, got %d (%v)", removed, err)
+	}
+
+	// The expired row's index entry went with it
+	if _, err := conn.Execute(insert, 2, "abc", time.Now()); err != nil {
+		t.Errorf("expected abc to be free again after expiry: %v", err)
+	}
+}
@@ -0,0 +1,320 @@
+package database
+
+import (
+	"bytes"
+	"io"
+	"testing"
+)
+
+// objectContents reads a whole large object through a connection
+func objectContents(t *testing.T, conn Connection, id LargeObjectID) []byte {
+	t.Helper()
+
+	var buf bytes.Buffer
+	if _, err := ExportLargeObject(conn, id, &buf); err != nil {
+		t.Fatalf("failed to export large object %d: %v", id, err)
+	}
+	return buf.Bytes()
+}
+
+// pattern returns n bytes that differ from page to page
+func pattern(n int) []byte {
+	data := make([]byte, n)
+	for i := range data {
+		data[i] = byte(i*7 + i/4096)
+	}
+	return data
+}
+
+func TestLargeObjectRoundTrip(t *testing.T) {
+	engine := newMemoryStorage()
+	db, conn := openDatabase(t, engine)
+	defer db.Close()
+
+	// Sizes within one page, across pages, and past the page IDs the header
+	// holds, which continue in index pages
+	for _, size := range []int{0, 100, 4096, 10000, 600 * 4096} {
+		data := pattern(size)
+		id, err := ImportLargeObject(conn, bytes.NewReader(data))
+		if err != nil {
+			t.Fatalf("import of %d bytes failed: %v", size, err)
+		}
+		if got := objectContents(t, conn, id); !bytes.Equal(got, data) {
+			t.Errorf("expected %d bytes back, got %d differing bytes", size, len(got))
+		}
+
+		obj, err := conn.OpenLargeObject(id, LargeObjectRead)
+		if err != nil {
+			t.Fatalf("open failed: %v", err)
+		}
+		if obj.Size() != int64(size) {
+			t.Errorf("expected size %d, got %d", size, obj.Size())
+		}
+		if _, err := obj.Write([]byte("x")); err == nil {
+			t.Error("expected a write through a read handle to fail")
+		}
+		obj.Close()
+	}
+
+	if _, err := conn.OpenLargeObject(LargeObjectID(1), LargeObjectRead); err == nil {
+		t.Error("expected the catalog page not to open as a large object")
+	}
+}
+
+func TestLargeObjectSeekAndTruncate(t *testing.T) {
+	engine := newMemoryStorage()
+	db, conn := openDatabase(t, engine)
+	defer db.Close()
+
+	id, err := ImportLargeObject(conn, bytes.NewReader([]byte("hello world")))
+	if err != nil {
+		t.Fatalf("import failed: %v", err)
+	}
+
+	obj, err := conn.OpenLargeObject(id, LargeObjectReadWrite)
+	if err != nil {
+		t.Fatalf("open failed: %v", err)
+	}
+
+	read := func(n int) string {
+		buf := make([]byte, n)
+		count, err := io.ReadFull(obj, buf)
+		if err != nil && err != io.ErrUnexpectedEOF {
+			t.Fatalf("read failed: %v", err)
+		}
+		return string(buf[:count])
+	}
+
+	if pos, err := obj.Seek(6, io.SeekStart); err != nil || pos != 6 {
+		t.Fatalf("seek failed: %d (%v)", pos, err)
+	}
+	if got := read(5); got != "world" {
+		t.Errorf("expected world, got %q", got)
+	}
+	if _, err := obj.Seek(-5, io.SeekEnd); err != nil {
+		t.Fatalf("seek from end failed: %v", err)
+	}
+	if _, err := obj.Seek(-1, io.SeekCurrent); err != nil {
+		t.Fatalf("seek from current failed: %v", err)
+	}
+	if got := read(6); got != " world" {
+		t.Errorf("expected ' world', got %q", got)
+	}
+	if _, err := obj.Seek(-1, io.SeekStart); err == nil {
+		t.Error("expected a negative position to be rejected")
+	}
+
+	// Overwrite in place
+	obj.Seek(0, io.SeekStart)
+	obj.Write([]byte("J"))
+	obj.Seek(0, io.SeekStart)
+	if got := read(11); got != "Jello world" {
+		t.Errorf("expected Jello world, got %q", got)
+	}
+
+	// Shrinking drops the tail, and extending again reads zeros
+	if err := obj.Truncate(5); err != nil {
+		t.Fatalf("truncate failed: %v", err)
+	}
+	if err := obj.Truncate(8); err != nil {
+		t.Fatalf("extend failed: %v", err)
+	}
+	obj.Seek(0, io.SeekStart)
+	if got := read(20); got != "Jello\x00\x00\x00" {
+		t.Errorf("expected Jello and 3 zeros, got %q", got)
+	}
+
+	// Writing past the end leaves a zero-filled gap across pages
+	if _, err := obj.Seek(5000, io.SeekStart); err != nil {
+		t.Fatalf("seek past end failed: %v", err)
+	}
+	if _, err := obj.Write([]byte("end")); err != nil {
+		t.Fatalf("write past end failed: %v", err)
+	}
+	if obj.Size() != 5003 {
+		t.Errorf("expected size 5003, got %d", obj.Size())
+	}
+	if err := obj.Close(); err != nil {
+		t.Fatalf("close failed: %v", err)
+	}
+
+	want := append([]byte("Jello"), make([]byte, 4995)...)
+	want = append(want, "end"...)
+	if got := objectContents(t, conn, id); !bytes.Equal(got, want) {
+		t.Errorf("unexpected contents after close: %q...", got[:16])
+	}
+
+	// Shrinking to a page boundary keeps the whole last page
+	obj, _ = conn.OpenLargeObject(id, LargeObjectWrite)
+	if err := obj.Truncate(4096); err != nil {
+		t.Fatalf("truncate to a page boundary failed: %v", err)
+	}
+	if err := obj.Truncate(-1); err == nil {
+		t.Error("expected a negative size to be rejected")
+	}
+	obj.Close()
+	if got := objectContents(t, conn, id); len(got) != 4096 || !bytes.Equal(got, want[:4096]) {
+		t.Errorf("expected the first page back, got %d bytes", len(got))
+	}
+}
+
+func TestLargeObjectTransactionRollback(t *testing.T) {
+	engine := newMemoryStorage()
+	db, conn := openDatabase(t, engine)
+	defer db.Close()
+
+	id, err := ImportLargeObject(conn, bytes.NewReader([]byte("committed")))
+	if err != nil {
+		t.Fatalf("import failed: %v", err)
+	}
+	pages := len(engine.pages)
+
+	tx, err := conn.Begin()
+	if err != nil {
+		t.Fatalf("begin failed: %v", err)
+	}
+	created, err := tx.CreateLargeObject()
+	if err != nil {
+		t.Fatalf("create failed: %v", err)
+	}
+	obj, err := tx.OpenLargeObject(created, LargeObjectWrite)
+	if err != nil {
+		t.Fatalf("open failed: %v", err)
+	}
+	obj.Write(pattern(10000))
+	obj.Close()
+
+	obj, err = tx.OpenLargeObject(id, LargeObjectWrite)
+	if err != nil {
+		t.Fatalf("open failed: %v", err)
+	}
+	obj.Write([]byte("CHANGED"))
+	obj.Close()
+
+	// Another transaction cannot write the object meanwhile
+	if other, err := conn.OpenLargeObject(id, LargeObjectWrite); err == nil {
+		other.Close()
+		t.Error("expected a concurrent writer to be rejected")
+	}
+
+	if err := tx.Rollback(); err != nil {
+		t.Fatalf("rollback failed: %v", err)
+	}
+
+	if got := objectContents(t, conn, id); string(got) != "committed" {
+		t.Errorf("expected the committed contents after rollback, got %q", got)
+	}
+	if _, err := conn.OpenLargeObject(created, LargeObjectRead); err == nil {
+		t.Error("expected an object created by a rolled back transaction not to exist")
+	}
+	if len(engine.pages) != pages {
+		t.Errorf("expected rollback to release its pages: %d pages before, %d after", pages, len(engine.pages))
+	}
+
+	// The same changes take effect when committed
+	tx, _ = conn.Begin()
+	obj, _ = tx.OpenLargeObject(id, LargeObjectWrite)
+	obj.Write([]byte("CHANGED"))
+	obj.Close()
+	if got := objectContents(t, conn, id); string(got) != "committed" {
+		t.Errorf("expected uncommitted changes to be invisible, got %q", got)
+	}
+	if err := tx.Commit(); err != nil {
+		t.Fatalf("commit failed: %v", err)
+	}
+	if got := objectContents(t, conn, id); string(got) != "CHANGEDed" {
+		t.Errorf("expected the committed changes, got %q", got)
+	}
+	if _, err := tx.CreateLargeObject(); err == nil {
+		t.Error("expected a finished transaction to be rejected")
+	}
+}
+
+func TestLargeObjectReaderSnapshot(t *testing.T) {
+	engine := newMemoryStorage()
+	db, conn := openDatabase(t, engine)
+	defer db.Close()
+
+	before := pattern(3 * 4096)
+	id, err := ImportLargeObject(conn, bytes.NewReader(before))
+	if err != nil {
+		t.Fatalf("import failed: %v", err)
+	}
+	pages := len(engine.pages)
+
+	reader, err := conn.OpenLargeObject(id, LargeObjectRead)
+	if err != nil {
+		t.Fatalf("open failed: %v", err)
+	}
+
+	// Rewrite every page and commit while the reader is open
+	writer, err := conn.OpenLargeObject(id, LargeObjectWrite)
+	if err != nil {
+		t.Fatalf("open for writing failed: %v", err)
+	}
+	after := bytes.Repeat([]byte{0xAB}, len(before))
+	writer.Write(after)
+	if err := writer.Close(); err != nil {
+		t.Fatalf("commit failed: %v", err)
+	}
+
+	// The reader keeps reading the object as it was when opened, so the
+	// replaced pages stay allocated until it closes
+	got, err := io.ReadAll(reader)
+	if err != nil {
+		t.Fatalf("read failed: %v", err)
+	}
+	if !bytes.Equal(got, before) {
+		t.Error("expected the open reader to see the contents it opened")
+	}
+	if len(engine.pages) != pages+3 {
+		t.Errorf("expected the replaced pages to be kept for the reader, got %d pages for %d", len(engine.pages), pages)
+	}
+	reader.Close()
+	if len(engine.pages) != pages {
+		t.Errorf("expected the replaced pages to be released with the reader, got %d pages for %d", len(engine.pages), pages)
+	}
+
+	if got := objectContents(t, conn, id); !bytes.Equal(got, after) {
+		t.Error("expected a new reader to see the committed contents")
+	}
+}
+
+func TestLargeObjectDeleteReleasesPages(t *testing.T) {
+	engine := newMemoryStorage()
+	db, conn := openDatabase(t, engine)
+	defer db.Close()
+
+	pages := len(engine.pages)
+	id, err := ImportLargeObject(conn, bytes.NewReader(pattern(600*4096)))
+	if err != nil {
+		t.Fatalf("import failed: %v", err)
+	}
+	if len(engine.pages) <= pages+600 {
+		t.Fatalf("expected data, header and index pages to be allocated, got %d", len(engine.pages)-pages)
+	}
+
+	// A delete in a transaction only takes effect at commit
+	tx, _ := conn.Begin()
+	if err := tx.DeleteLargeObject(id); err != nil {
+		t.Fatalf("delete failed: %v", err)
+	}
+	if obj, err := conn.OpenLargeObject(id, LargeObjectRead); err != nil {
+		t.Errorf("expected the object to exist until the delete commits: %v", err)
+	} else {
+		obj.Close()
+	}
+	if err := tx.Commit(); err != nil {
+		t.Fatalf("commit failed: %v", err)
+	}
+
+	if _, err := conn.OpenLargeObject(id, LargeObjectRead); err == nil {
+		t.Error("expected a deleted object not to open")
+	}
+	if len(engine.pages) != pages {
+		t.Errorf("expected every page of the object to be released, %d remain", len(engine.pages)-pages)
+	}
+	if err := conn.DeleteLargeObject(id); err == nil {
+		t.Error("expected deleting a deleted object to fail")
+	}
+}
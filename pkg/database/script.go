@@ -0,0 +1,89 @@
+package database
+
+import (
+	"context"
+	"fmt"
+
+	"relational-db/internal/parser"
+)
+
+// ScriptOptions controls how a script is executed
+type ScriptOptions struct {
+	// Transaction runs the whole script in one transaction, so either
+	// every statement changing rows takes effect or none does. Schema
+	// changes take effect as they run.
+	Transaction bool
+}
+
+// ScriptError reports the statement of a script that failed
+type ScriptError struct {
+	Index  int    // 1-based position of the statement in the script
+	Line   int
+	Column int
+	SQL    string
+	Err    error
+}
+
+// Error implements the error interface
+func (e *ScriptError) Error() string {
+	return fmt.Sprintf("statement %d at line %d: %v", e.Index, e.Line, e.Err)
+}
+
+// Unwrap returns the underlying error
+func (e *ScriptError) Unwrap() error {
+	return e.Err
+}
+
+// ExecScript executes a semicolon-separated script
+func (c *ConnectionImpl) ExecScript(script string, opts ScriptOptions) ([]Result, error) {
+	return c.ExecScriptContext(c.ctx, script, opts)
+}
+
+// ExecScriptContext executes a semicolon-separated script with context. The
+// results of the statements that ran are returned, a query's holding the
+// rows it selected; on failure the error is a *ScriptError naming the
+// statement. The script is parsed completely
+// before anything runs, so a syntax error anywhere executes nothing.
+func (c *ConnectionImpl) ExecScriptContext(ctx context.Context, script string, opts ScriptOptions) ([]Result, error) {
+	statements, err := parser.ParseScript(script)
+	if err != nil {
+		return nil, err
+	}
+	
+	execute := c.ExecuteContext
+	
+	var tx Transaction
+	if opts.Transaction {
+		tx, err = c.BeginContext(ctx)
+		if err != nil {
+			return nil, err
+		}
+		execute = tx.ExecuteContext
+	}
+	
+	results := make([]Result, 0, len(statements))
+	for i, stmt := range statements {
+		result, err := execute(ctx, stmt.Text)
+		if err != nil {
+			if tx != nil {
+				tx.Rollback()
+			}
+			return results, &ScriptError{
+				Index:  i + 1,
+				Line:   stmt.Line,
+				Column: stmt.Column,
+				SQL:    stmt.Text,
+				Err:    err,
+			}
+		}
+		results = append(results, result)
+	}
+	
+	if tx != nil {
+		if err := tx.Commit(); err != nil {
+			return results, err
+		}
+	}
+	
+	return results, nil
+}
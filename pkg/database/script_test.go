@@ -0,0 +1,205 @@
+package database
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"testing"
+)
+
+// accountRows returns the rows of the accounts table in id order
+func accountRows(t *testing.T, conn Connection) [][]interface{} {
+	t.Helper()
+
+	result, err := conn.Execute("SELECT id, balance FROM accounts ORDER BY id")
+	if err != nil {
+		t.Fatalf("failed to read accounts: %v", err)
+	}
+	return result.(*ResultImpl).rows
+}
+
+const accountsTable = "CREATE TABLE accounts (id INTEGER PRIMARY KEY, balance INTEGER) WITH (clustered = true)"
+
+func TestExecScriptRunsInOrder(t *testing.T) {
+	for _, transaction := range []bool{false, true} {
+		t.Run(fmt.Sprintf("transaction=%v", transaction), func(t *testing.T) {
+			db, conn := openDatabase(t, newMemoryStorage())
+			defer db.Close()
+
+			// Each statement depends on the ones before it
+			results, err := conn.(*ConnectionImpl).ExecScript(`
+				-- schema
+				`+accountsTable+`;
+				INSERT INTO accounts VALUES (1, 100), (2, 50);
+				UPDATE accounts SET balance = balance + 10 WHERE id = 1;
+				DELETE FROM accounts WHERE balance < 100;
+				INSERT INTO accounts VALUES (3, 0);
+			`, ScriptOptions{Transaction: transaction})
+			if err != nil {
+				t.Fatalf("script failed: %v", err)
+			}
+			if len(results) != 5 {
+				t.Fatalf("expected 5 results, got %d", len(results))
+			}
+			if results[1].RowsAffected() != 2 || results[3].RowsAffected() != 1 {
+				t.Errorf("expected 2 inserted and 1 deleted row, got %d and %d", results[1].RowsAffected(), results[3].RowsAffected())
+			}
+
+			want := [][]interface{}{{int64(1), int64(110)}, {int64(3), int64(0)}}
+			if got := accountRows(t, conn); !reflect.DeepEqual(got, want) {
+				t.Errorf("expected %v, got %v", want, got)
+			}
+		})
+	}
+}
+
+func TestExecScriptSelect(t *testing.T) {
+	for _, transaction := range []bool{false, true} {
+		t.Run(fmt.Sprintf("transaction=%v", transaction), func(t *testing.T) {
+			db, conn := openDatabase(t, newMemoryStorage())
+			defer db.Close()
+
+			// A query sees the changes of the statements before it
+			results, err := conn.(*ConnectionImpl).ExecScript(`
+				`+accountsTable+`;
+				INSERT INTO accounts VALUES (1, 100), (2, 50);
+				SELECT id FROM accounts WHERE balance > 60;
+				UPDATE accounts SET balance = 70 WHERE id = 2;
+				SELECT id FROM accounts WHERE balance > 60 ORDER BY id DESC;
+			`, ScriptOptions{Transaction: transaction})
+			if err != nil {
+				t.Fatalf("script failed: %v", err)
+			}
+			if len(results) != 5 {
+				t.Fatalf("expected 5 results, got %d", len(results))
+			}
+			for i, want := range map[int]string{2: "[[1]]", 4: "[[2] [1]]"} {
+				if rows := results[i].(*ResultImpl).rows; fmt.Sprint(rows) != want {
+					t.Errorf("statement %d: expected %s, got %v", i+1, want, rows)
+				}
+				if columns := results[i].Columns(); fmt.Sprint(columns) != "[id]" {
+					t.Errorf("statement %d: expected column id, got %v", i+1, columns)
+				}
+			}
+		})
+	}
+}
+
+func TestExecScriptRollsBackOnFailure(t *testing.T) {
+	db, conn := openDatabase(t, newMemoryStorage())
+	defer db.Close()
+
+	if _, err := conn.Execute(accountsTable); err != nil {
+		t.Fatalf("create failed: %v", err)
+	}
+	if _, err := conn.Execute("INSERT INTO accounts VALUES (1, 100), (2, 50)"); err != nil {
+		t.Fatalf("insert failed: %v", err)
+	}
+	before := accountRows(t, conn)
+
+	results, err := conn.(*ConnectionImpl).ExecScript(`
+		UPDATE accounts SET balance = balance - 30 WHERE id = 1;
+		UPDATE accounts SET balance = balance + 30 WHERE id = 2;
+		DELETE FROM accounts WHERE id = 2;
+		INSERT INTO accounts VALUES (3, 0),
+			(1, 0);
+		INSERT INTO accounts VALUES (4, 0);
+	`, ScriptOptions{Transaction: true})
+
+	var scriptErr *ScriptError
+	if !errors.As(err, &scriptErr) {
+		t.Fatalf("expected a ScriptError, got %v", err)
+	}
+	if scriptErr.Index != 4 || scriptErr.Line != 5 {
+		t.Errorf("expected statement 4 at line 5 to fail, got statement %d at line %d", scriptErr.Index, scriptErr.Line)
+	}
+	if scriptErr.SQL == "" || scriptErr.Err == nil {
+		t.Errorf("expected the failed statement and its error, got %+v", scriptErr)
+	}
+	if len(results) != 3 {
+		t.Errorf("expected the results of the 3 statements that ran, got %d", len(results))
+	}
+
+	// None of the statements before the failed one took effect
+	if got := accountRows(t, conn); !reflect.DeepEqual(got, before) {
+		t.Errorf("expected rollback to restore %v, got %v", before, got)
+	}
+
+	// Without a transaction the statements before the failed one stay
+	_, err = conn.(*ConnectionImpl).ExecScript(`
+		DELETE FROM accounts WHERE id = 2;
+		INSERT INTO accounts VALUES (1, 0);
+	`, ScriptOptions{})
+	if !errors.As(err, &scriptErr) || scriptErr.Index != 2 {
+		t.Fatalf("expected statement 2 to fail, got %v", err)
+	}
+	if got := accountRows(t, conn); len(got) != 1 {
+		t.Errorf("expected the delete to stay, got %v", got)
+	}
+}
+
+func TestExecScriptParseError(t *testing.T) {
+	db, conn := openDatabase(t, newMemoryStorage())
+	defer db.Close()
+
+	if _, err := conn.(*ConnectionImpl).ExecScript(accountsTable+"; INSERT INTO accounts VALUES (;", ScriptOptions{}); err == nil {
+		t.Fatal("expected a syntax error")
+	}
+	if _, err := conn.Execute(accountsTable); err != nil {
+		t.Errorf("expected a script with a syntax error to run nothing: %v", err)
+	}
+}
+
+func TestTransactionControlStatements(t *testing.T) {
+	db, conn := openDatabase(t, newMemoryStorage())
+	defer db.Close()
+
+	if _, err := conn.Execute(accountsTable); err != nil {
+		t.Fatalf("create failed: %v", err)
+	}
+
+	for _, sql := range []string{
+		"BEGIN",
+		"INSERT INTO accounts VALUES (1, 100)",
+		"SAVEPOINT before_second",
+		"INSERT INTO accounts VALUES (2, 50)",
+		"ROLLBACK TO SAVEPOINT before_second",
+		"COMMIT",
+		"BEGIN",
+		"UPDATE accounts SET balance = 0",
+		"ROLLBACK",
+	} {
+		if _, err := conn.Execute(sql); err != nil {
+			t.Fatalf("%s failed: %v", sql, err)
+		}
+	}
+
+	want := [][]interface{}{{int64(1), int64(100)}}
+	if got := accountRows(t, conn); !reflect.DeepEqual(got, want) {
+		t.Errorf("expected %v, got %v", want, got)
+	}
+	if _, err := conn.Execute("COMMIT"); err == nil {
+		t.Error("expected COMMIT without a transaction to fail")
+	}
+
+	// A transaction from Begin takes savepoints but not BEGIN or COMMIT
+	tx, err := conn.Begin()
+	if err != nil {
+		t.Fatalf("begin failed: %v", err)
+	}
+	if _, err := tx.Execute("COMMIT"); err == nil {
+		t.Error("expected COMMIT inside a transaction to fail")
+	}
+	if _, err := tx.Execute("INSERT INTO accounts VALUES (?, ?)", 2, 50); err != nil {
+		t.Fatalf("insert failed: %v", err)
+	}
+	if _, err := tx.Execute("INSERT INTO accounts VALUES (?, ?)", 3, "none"); err == nil {
+		t.Error("expected a mismatched parameter to fail")
+	}
+	if err := tx.Commit(); err != nil {
+		t.Fatalf("commit failed: %v", err)
+	}
+	if got := accountRows(t, conn); len(got) != 2 {
+		t.Errorf("expected the committed row, got %v", got)
+	}
+}
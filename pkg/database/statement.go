@@ -0,0 +1,77 @@
+package database
+
+import (
+	"context"
+	"fmt"
+	"sync"
+
+	"relational-db/internal/executor"
+)
+
+// StmtImpl implements the Stmt interface
+type StmtImpl struct {
+	mu         sync.Mutex
+	connection *ConnectionImpl
+	plan       *executor.PreparedPlan
+	closed     bool
+}
+
+// Execute executes the statement with the given parameter values
+func (s *StmtImpl) Execute(params ...interface{}) (Result, error) {
+	return s.ExecuteContext(s.connection.ctx, params...)
+}
+
+// ExecuteContext executes the statement with context
+func (s *StmtImpl) ExecuteContext(ctx context.Context, params ...interface{}) (Result, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	
+	if s.closed {
+		return nil, fmt.Errorf("statement is closed")
+	}
+	if err := s.connection.Ping(); err != nil {
+		return nil, err
+	}
+	
+	// Replan if a referenced table changed since the statement was prepared
+	plan, err := s.connection.database.queryPlanner().Refresh(s.plan)
+	if err != nil {
+		return nil, err
+	}
+	s.plan = plan
+	
+	bound, err := plan.Bind(params)
+	if err != nil {
+		return nil, err
+	}
+	
+	s.connection.database.incrementQueryCount()
+	if changesRows(plan.Statement) {
+		return s.connection.database.executeDML(ctx, s.connection.session.Transaction(), plan, bound)
+	}
+	if readsRows(plan.Statement) {
+		return s.connection.database.executeQuery(ctx, plan, bound)
+	}
+	
+	return &ResultImpl{
+		columns: []string{},
+		rows:    [][]interface{}{},
+	}, fmt.Errorf("%s statements cannot be prepared", plan.Compiled.QueryType)
+}
+
+// NumParams returns the number of parameters the statement expects
+func (s *StmtImpl) NumParams() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	
+	return s.plan.ParameterCount
+}
+
+// Close releases the statement. The plan stays in the shared cache.
+func (s *StmtImpl) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	
+	s.closed = true
+	return nil
+}
@@ -1,6 +1,8 @@
 package unit
 
 import (
+	"reflect"
+	"strings"
 	"testing"
 
 	"relational-db/internal/lexer"
@@ -314,7 +316,213 @@ func TestParseCreateTableWithConstraints(t *testing.T) {
 	}
 }
 
+// TestParseCreateTableWithOptions tests CREATE TABLE ... WITH (options)
+func TestParseCreateTableWithOptions(t *testing.T) {
+	sql := `CREATE TABLE events (
+		id INTEGER PRIMARY KEY,
+		payload TEXT
+	) WITH (clustered = true)`
+	l := lexer.NewLexer(sql)
+	p := parser.NewParser(l)
+
+	stmt := p.ParseStatement()
+
+	if stmt == nil {
+		t.Fatalf("Expected statement, got nil. Errors: %v", p.Errors())
+	}
+
+	createStmt, ok := stmt.(*parser.CreateTableStatement)
+	if !ok {
+		t.Fatalf("Expected *CreateTableStatement, got %T", stmt)
+	}
+
+	option := createStmt.Option("CLUSTERED")
+	if option == nil {
+		t.Fatal("Expected clustered option, got nil")
+	}
+
+	if !option.IsEnabled() {
+		t.Errorf("Expected clustered option to be enabled, got %s", option)
+	}
+}
+
+// TestParseAlterTableOptions tests ALTER TABLE SET and RESET of table options
+func TestParseAlterTableOptions(t *testing.T) {
+	sql := "ALTER TABLE sessions SET (ttl_column = created_at, ttl = '30 days'), RESET (clustered)"
+	l := lexer.NewLexer(sql)
+	p := parser.NewParser(l)
+
+	stmt := p.ParseStatement()
+
+	if stmt == nil {
+		t.Fatalf("Expected statement, got nil. Errors: %v", p.Errors())
+	}
+
+	alterStmt, ok := stmt.(*parser.AlterTableStatement)
+	if !ok {
+		t.Fatalf("Expected *AlterTableStatement, got %T", stmt)
+	}
+
+	if len(alterStmt.Actions) != 2 {
+		t.Fatalf("Expected 2 actions, got %d", len(alterStmt.Actions))
+	}
+
+	set, ok := alterStmt.Actions[0].(*parser.SetTableOptionsAction)
+	if !ok {
+		t.Fatalf("Expected *SetTableOptionsAction, got %T", alterStmt.Actions[0])
+	}
+	if len(set.Options) != 2 || set.Options[0].Text() != "created_at" || set.Options[1].Text() != "30 days" {
+		t.Errorf("Unexpected options: %s", set)
+	}
+
+	if _, ok := alterStmt.Actions[1].(*parser.ResetTableOptionsAction); !ok {
+		t.Errorf("Expected *ResetTableOptionsAction, got %T", alterStmt.Actions[1])
+	}
+}
+
+// TestParseAlterTableActions tests the column, rename and constraint actions of ALTER TABLE
+func TestParseAlterTableActions(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"ALTER TABLE users ADD COLUMN email VARCHAR(100) NOT NULL", "ALTER TABLE users ADD COLUMN email VARCHAR(100) NOT NULL"},
+		{"ALTER TABLE users ADD email TEXT DEFAULT 'none'", "ALTER TABLE users ADD COLUMN email TEXT DEFAULT 'none'"},
+		{"ALTER TABLE users DROP email", "ALTER TABLE users DROP COLUMN email"},
+		{"ALTER TABLE users RENAME name TO full_name", "ALTER TABLE users RENAME COLUMN name TO full_name"},
+		{"ALTER TABLE users RENAME TO people", "ALTER TABLE users RENAME TO people"},
+		{"ALTER TABLE users ADD CONSTRAINT pk PRIMARY KEY (id)", "ALTER TABLE users ADD CONSTRAINT pk PRIMARY KEY (id)"},
+		{"ALTER TABLE users ADD UNIQUE (email), DROP CONSTRAINT pk", "ALTER TABLE users ADD UNIQUE (email), DROP CONSTRAINT pk"},
+		{"ALTER TABLE users ADD CHECK (age >= 18)", "ALTER TABLE users ADD CHECK ((age >= 18))"},
+		{"ALTER TABLE orders ADD FOREIGN KEY (user_id) REFERENCES users (id)", "ALTER TABLE orders ADD FOREIGN KEY (user_id) REFERENCES users (id)"},
+	}
+
+	for _, tt := range tests {
+		stmt, err := parser.ParseSQL(tt.input)
+		if err != nil {
+			t.Errorf("%s: parse failed: %v", tt.input, err)
+			continue
+		}
+		if _, ok := stmt.(*parser.AlterTableStatement); !ok {
+			t.Errorf("%s: expected *AlterTableStatement, got %T", tt.input, stmt)
+			continue
+		}
+		if stmt.String() != tt.expected {
+			t.Errorf("%s: expected %q, got %q", tt.input, tt.expected, stmt.String())
+		}
+	}
+
+	invalid := []string{
+		"ALTER TABLE users ADD",
+		"ALTER TABLE users DROP CONSTRAINT",
+		"ALTER TABLE users RENAME name",
+		"ALTER TABLE users RENAME COLUMN name full_name",
+	}
+	for _, sql := range invalid {
+		if _, err := parser.ParseSQL(sql); err == nil {
+			t.Errorf("%s: expected parse error", sql)
+		}
+	}
+}
+
+// TestParsePartitionBy tests CREATE TABLE ... PARTITION BY
+func TestParsePartitionBy(t *testing.T) {
+	tests := []struct {
+		sql        string
+		method     parser.PartitionMethod
+		partitions int
+		count      int
+	}{
+		{"CREATE TABLE m (ts INTEGER) PARTITION BY RANGE (ts) (PARTITION p0 VALUES LESS THAN (100), PARTITION p1 VALUES LESS THAN MAXVALUE)", parser.PartitionByRange, 2, 0},
+		{"CREATE TABLE m (region TEXT) PARTITION BY LIST (region) (PARTITION eu VALUES IN ('de', 'fr'), PARTITION us VALUES IN ('us'))", parser.PartitionByList, 2, 0},
+		{"CREATE TABLE m (id INTEGER) PARTITION BY HASH (id) PARTITIONS 4", parser.PartitionByHash, 0, 4},
+	}
+
+	for _, tt := range tests {
+		l := lexer.NewLexer(tt.sql)
+		p := parser.NewParser(l)
+
+		stmt := p.ParseStatement()
+		if stmt == nil {
+			t.Fatalf("Expected statement for %q, got nil. Errors: %v", tt.sql, p.Errors())
+		}
+
+		createStmt, ok := stmt.(*parser.CreateTableStatement)
+		if !ok || createStmt.PartitionBy == nil {
+			t.Fatalf("Expected partitioned *CreateTableStatement for %q, got %T", tt.sql, stmt)
+		}
+
+		spec := createStmt.PartitionBy
+		if spec.Method != tt.method || len(spec.Partitions) != tt.partitions || spec.Count != tt.count {
+			t.Errorf("Unexpected partition spec for %q: %s", tt.sql, spec)
+		}
+	}
+}
+
 // TestParseDelete tests DELETE statement
+func TestParseParameters(t *testing.T) {
+	sql := "SELECT * FROM users WHERE id = ? AND name = ?"
+	p := parser.NewParser(lexer.NewLexer(sql))
+
+	stmt := p.ParseStatement()
+	if stmt == nil {
+		t.Fatalf("Expected statement, got nil. Errors: %v", p.Errors())
+	}
+	if p.ParameterCount() != 2 {
+		t.Errorf("Expected 2 parameters, got %d", p.ParameterCount())
+	}
+
+	where := stmt.(*parser.SelectStatement).WhereClause.Condition.(*parser.BinaryExpression)
+	second := where.Right.(*parser.BinaryExpression).Right.(*parser.Parameter)
+	if second.Index != 2 || second.Numbered {
+		t.Errorf("Expected positional parameter 2, got %s (index %d)", second, second.Index)
+	}
+
+	// Numbered parameters may repeat and appear out of order
+	p = parser.NewParser(lexer.NewLexer("UPDATE users SET name = $2 WHERE id = $1 OR parent = $1"))
+	if stmt := p.ParseStatement(); stmt == nil {
+		t.Fatalf("Unexpected errors: %v", p.Errors())
+	}
+	if p.ParameterCount() != 2 {
+		t.Errorf("Expected 2 parameters, got %d", p.ParameterCount())
+	}
+
+	if _, err := parser.ParseSQL("SELECT * FROM users WHERE id = ? AND name = $2"); err == nil {
+		t.Error("Expected error when mixing ? and $n parameters")
+	}
+}
+
+func TestParseScript(t *testing.T) {
+	script := `-- seed data
+CREATE TABLE users (id INTEGER PRIMARY KEY, name TEXT);;
+INSERT INTO users (id, name) VALUES (1, 'alice');
+/* second user */
+INSERT INTO users (id, name) VALUES (2, 'bob')`
+
+	statements, err := parser.ParseScript(script)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if len(statements) != 3 {
+		t.Fatalf("Expected 3 statements, got %d", len(statements))
+	}
+
+	if _, ok := statements[0].Statement.(*parser.CreateTableStatement); !ok {
+		t.Errorf("Expected *CreateTableStatement, got %T", statements[0].Statement)
+	}
+	if statements[1].Text != "INSERT INTO users (id, name) VALUES (1, 'alice')" || statements[1].Line != 3 {
+		t.Errorf("Unexpected span for statement 2: %q at line %d", statements[1].Text, statements[1].Line)
+	}
+	if got := script[statements[2].Start:statements[2].End]; got != statements[2].Text || statements[2].Line != 5 {
+		t.Errorf("Unexpected span for statement 3: %q at line %d", got, statements[2].Line)
+	}
+
+	_, err = parser.ParseScript("DELETE FROM users;\nSELECT FROM;\nDROP TABLE users")
+	if err == nil || !strings.Contains(err.Error(), "statement 2 at line 2") {
+		t.Errorf("Expected error for statement 2, got %v", err)
+	}
+}
+
 func TestParseDelete(t *testing.T) {
 	sql := "DELETE FROM users WHERE id = 42"
 	l := lexer.NewLexer(sql)
@@ -453,3 +661,1066 @@ func TestParseSQLConvenienceFunction(t *testing.T) {
 		t.Fatal("Expected FROM clause, got nil")
 	}
 }
+
+func TestParseSQLRejectsTrailingTokens(t *testing.T) {
+	for _, sql := range []string{
+		"SELECT a FROM t;",
+		"SELECT a FROM t ; -- done",
+	} {
+		if _, err := parser.ParseSQL(sql); err != nil {
+			t.Errorf("%s: unexpected error: %v", sql, err)
+		}
+	}
+
+	for _, sql := range []string{
+		"SELECT a FROM t ORDER BY a UNION SELECT b FROM s",
+		"SELECT a FROM t; SELECT b FROM s",
+		"DELETE FROM t WHERE a = 1 b",
+	} {
+		_, err := parser.ParseSQL(sql)
+		if err == nil || !strings.Contains(err.Error(), "after statement") {
+			t.Errorf("%s: expected an error for the trailing tokens, got %v", sql, err)
+		}
+	}
+}
+
+func TestParseSubqueries(t *testing.T) {
+	tests := []struct {
+		sql      string
+		expected string
+	}{
+		{
+			"SELECT name FROM users WHERE id IN (SELECT user_id FROM orders)",
+			"SELECT name FROM users WHERE (id IN (SELECT user_id FROM orders))",
+		},
+		{
+			"SELECT name FROM users u WHERE NOT EXISTS (SELECT id FROM orders WHERE orders.user_id = u.id)",
+			"SELECT name FROM users AS u WHERE (NOT EXISTS (SELECT id FROM orders WHERE (orders.user_id = u.id)))",
+		},
+		{
+			"SELECT name, (SELECT title FROM teams WHERE teams.id = users.team_id) AS team FROM users",
+			"SELECT name, (SELECT title FROM teams WHERE (teams.id = users.team_id)) AS team FROM users",
+		},
+		{
+			"SELECT t.id FROM (SELECT id FROM users) t JOIN (SELECT user_id FROM orders) AS o ON t.id = o.user_id",
+			"SELECT t.id FROM (SELECT id FROM users) AS t INNER JOIN (SELECT user_id FROM orders) AS o ON (t.id = o.user_id)",
+		},
+	}
+
+	for _, tt := range tests {
+		stmt, err := parser.ParseSQL(tt.sql)
+		if err != nil {
+			t.Fatalf("%s: unexpected error: %v", tt.sql, err)
+		}
+		if got := stmt.String(); got != tt.expected {
+			t.Errorf("%s:\nexpected %s\ngot      %s", tt.sql, tt.expected, got)
+		}
+	}
+
+	if _, err := parser.ParseSQL("SELECT id FROM users WHERE EXISTS (id)"); err == nil {
+		t.Error("Expected error for EXISTS without a subquery")
+	}
+}
+
+func TestParseCommonTableExpressions(t *testing.T) {
+	tests := []struct {
+		sql      string
+		expected string
+	}{
+		{
+			"WITH adults AS (SELECT id, name FROM users WHERE age >= 18) SELECT name FROM adults",
+			"WITH adults AS (SELECT id, name FROM users WHERE (age >= 18)) SELECT name FROM adults",
+		},
+		{
+			"WITH a AS (SELECT id FROM users), b (user_id) AS (SELECT id FROM a) SELECT user_id FROM b",
+			"WITH a AS (SELECT id FROM users), b (user_id) AS (SELECT id FROM a) SELECT user_id FROM b",
+		},
+		{
+			"WITH RECURSIVE tree (id, parent_id) AS (SELECT id, parent_id FROM categories WHERE parent_id = 0 UNION ALL SELECT c.id, c.parent_id FROM categories c JOIN tree t ON c.parent_id = t.id) SELECT id FROM tree",
+			"WITH RECURSIVE tree (id, parent_id) AS (SELECT id, parent_id FROM categories WHERE (parent_id = 0) UNION ALL SELECT c.id, c.parent_id FROM categories AS c INNER JOIN tree AS t ON (c.parent_id = t.id)) SELECT id FROM tree",
+		},
+		{
+			"SELECT name FROM users WHERE id IN (WITH big AS (SELECT user_id FROM orders) SELECT user_id FROM big)",
+			"SELECT name FROM users WHERE (id IN (WITH big AS (SELECT user_id FROM orders) SELECT user_id FROM big))",
+		},
+	}
+
+	for _, tt := range tests {
+		stmt, err := parser.ParseSQL(tt.sql)
+		if err != nil {
+			t.Fatalf("%s: unexpected error: %v", tt.sql, err)
+		}
+		if got := stmt.String(); got != tt.expected {
+			t.Errorf("%s:\nexpected %s\ngot      %s", tt.sql, tt.expected, got)
+		}
+	}
+
+	stmt, _ := parser.ParseSQL("WITH RECURSIVE n AS (SELECT id FROM seed UNION SELECT id FROM n) SELECT id FROM n")
+	cte := stmt.(*parser.SelectStatement).With.CTEs[0]
+	if cte.RecursiveTerm == nil || cte.UnionAll {
+		t.Errorf("Expected a UNION recursive term, got %+v", cte)
+	}
+
+	invalid := []string{
+		"WITH a AS SELECT id FROM users SELECT id FROM a",
+		"WITH a AS (SELECT id FROM users) DELETE FROM a",
+	}
+	for _, sql := range invalid {
+		if _, err := parser.ParseSQL(sql); err == nil {
+			t.Errorf("%s: expected error", sql)
+		}
+	}
+}
+
+func TestParseSetOperations(t *testing.T) {
+	tests := []struct {
+		sql      string
+		expected string
+	}{
+		// INTERSECT binds tighter than UNION and EXCEPT
+		{
+			"SELECT a FROM t UNION SELECT a FROM u INTERSECT SELECT a FROM v",
+			"SELECT a FROM t UNION (SELECT a FROM u INTERSECT SELECT a FROM v)",
+		},
+		// UNION and EXCEPT associate to the left
+		{
+			"SELECT a FROM t UNION ALL SELECT a FROM u EXCEPT SELECT a FROM v",
+			"SELECT a FROM t UNION ALL SELECT a FROM u EXCEPT SELECT a FROM v",
+		},
+		{
+			"(SELECT a FROM t UNION SELECT a FROM u) INTERSECT SELECT a FROM v",
+			"(SELECT a FROM t UNION SELECT a FROM u) INTERSECT SELECT a FROM v",
+		},
+		{
+			"SELECT a FROM t EXCEPT (SELECT a FROM u EXCEPT SELECT a FROM v)",
+			"SELECT a FROM t EXCEPT (SELECT a FROM u EXCEPT SELECT a FROM v)",
+		},
+		// ORDER BY and LIMIT after the last query apply to the whole result
+		{
+			"SELECT a FROM t UNION DISTINCT SELECT a FROM u ORDER BY a LIMIT 5",
+			"SELECT a FROM t UNION SELECT a FROM u ORDER BY a ASC LIMIT 5",
+		},
+		{
+			"(SELECT a FROM t ORDER BY a LIMIT 1) UNION ALL (SELECT a FROM u LIMIT 2)",
+			"(SELECT a FROM t ORDER BY a ASC LIMIT 1) UNION ALL (SELECT a FROM u LIMIT 2)",
+		},
+		{
+			"WITH x AS (SELECT a FROM t UNION SELECT a FROM u) SELECT a FROM x INTERSECT ALL SELECT a FROM v",
+			"WITH x AS (SELECT a FROM t UNION SELECT a FROM u) SELECT a FROM x INTERSECT ALL SELECT a FROM v",
+		},
+	}
+
+	for _, tt := range tests {
+		stmt, err := parser.ParseSQL(tt.sql)
+		if err != nil {
+			t.Fatalf("%s: unexpected error: %v", tt.sql, err)
+		}
+		if got := stmt.String(); got != tt.expected {
+			t.Errorf("%s:\nexpected %s\ngot      %s", tt.sql, tt.expected, got)
+		}
+	}
+
+	stmt, _ := parser.ParseSQL("SELECT a FROM t UNION SELECT a FROM u ORDER BY a")
+	query := stmt.(*parser.SelectStatement)
+	if query.SetOperation == nil || query.OrderBy == nil || query.SetOperation.Right.OrderBy != nil {
+		t.Errorf("Expected ORDER BY on the compound query, got %+v", query)
+	}
+
+	invalid := []string{
+		"SELECT a FROM t UNION",
+		"SELECT a FROM t UNION ALL ALL SELECT a FROM u",
+		"SELECT a FROM t INTERSECT DELETE FROM u",
+	}
+	for _, sql := range invalid {
+		if _, err := parser.ParseSQL(sql); err == nil {
+			t.Errorf("%s: expected error", sql)
+		}
+	}
+}
+
+func TestParseConditionalExpressions(t *testing.T) {
+	tests := []struct {
+		sql      string
+		expected string
+	}{
+		{
+			"SELECT CASE WHEN a > 1 THEN 'big' WHEN a IS NULL THEN 'none' ELSE 'small' END FROM t",
+			"SELECT CASE WHEN (a > 1) THEN 'big' WHEN (a IS NULL) THEN 'none' ELSE 'small' END FROM t",
+		},
+		{
+			"SELECT CASE a WHEN 1 THEN TRUE END FROM t",
+			"SELECT CASE a WHEN 1 THEN TRUE END FROM t",
+		},
+		{
+			"SELECT CAST(a AS VARCHAR(10)), CAST(b AS INTEGER) FROM t",
+			"SELECT CAST(a AS VARCHAR(10)), CAST(b AS INTEGER) FROM t",
+		},
+		{
+			"SELECT CAST(CAST(a AS INTEGER) + b AS REAL), CAST((SELECT x AS y FROM u) AS REAL) FROM t",
+			"SELECT CAST((CAST(a AS INTEGER) + b) AS REAL), CAST((SELECT x AS y FROM u) AS REAL) FROM t",
+		},
+		{
+			"SELECT COALESCE(a, b, 0), NULLIF(a, '') FROM t WHERE b IS NOT NULL AND c = FALSE",
+			"SELECT COALESCE(a, b, 0), NULLIF(a, '') FROM t WHERE ((b IS NOT NULL) AND (c = FALSE))",
+		},
+		{
+			"SELECT a FROM t WHERE a + 1 IS NULL OR b = NULL",
+			"SELECT a FROM t WHERE (((a + 1) IS NULL) OR (b = NULL))",
+		},
+	}
+
+	for _, tt := range tests {
+		stmt, err := parser.ParseSQL(tt.sql)
+		if err != nil {
+			t.Fatalf("%s: unexpected error: %v", tt.sql, err)
+		}
+		if got := stmt.String(); got != tt.expected {
+			t.Errorf("%s:\nexpected %s\ngot      %s", tt.sql, tt.expected, got)
+		}
+	}
+
+	invalid := []string{
+		"SELECT CASE ELSE 1 END FROM t",
+		"SELECT CASE WHEN a THEN 1 FROM t",
+		"SELECT CASE WHEN a 1 END FROM t",
+		"SELECT CAST(a INTEGER) FROM t",
+		"SELECT CAST(a AS) FROM t",
+		"SELECT a FROM t WHERE a IS 1",
+	}
+	for _, sql := range invalid {
+		if _, err := parser.ParseSQL(sql); err == nil {
+			t.Errorf("%s: expected error", sql)
+		}
+	}
+}
+
+func TestParsePredicates(t *testing.T) {
+	tests := []struct {
+		sql      string
+		expected string
+	}{
+		// BETWEEN takes its own AND; the next AND is a conjunction
+		{
+			"SELECT a FROM t WHERE a BETWEEN 1 AND 5 AND b NOT BETWEEN c - 1 AND c + 1",
+			"SELECT a FROM t WHERE ((a BETWEEN 1 AND 5) AND (b NOT BETWEEN (c - 1) AND (c + 1)))",
+		},
+		{
+			"SELECT a FROM t WHERE a IN (1, 2, 3) OR b NOT IN ('x')",
+			"SELECT a FROM t WHERE ((a IN (1, 2, 3)) OR (b NOT IN ('x')))",
+		},
+		{
+			"SELECT a FROM t WHERE a NOT IN (SELECT b FROM u)",
+			"SELECT a FROM t WHERE (NOT (a IN (SELECT b FROM u)))",
+		},
+		{
+			"SELECT a FROM t WHERE a LIKE 'x%' AND b NOT LIKE '10!%%' ESCAPE '!'",
+			"SELECT a FROM t WHERE ((a LIKE 'x%') AND (b NOT LIKE '10!%%' ESCAPE '!'))",
+		},
+		{
+			"SELECT a FROM t WHERE NOT a IN (1 + 1, ?)",
+			"SELECT a FROM t WHERE (NOT (a IN ((1 + 1), ?)))",
+		},
+	}
+
+	for _, tt := range tests {
+		stmt, err := parser.ParseSQL(tt.sql)
+		if err != nil {
+			t.Fatalf("%s: unexpected error: %v", tt.sql, err)
+		}
+		if got := stmt.String(); got != tt.expected {
+			t.Errorf("%s:\nexpected %s\ngot      %s", tt.sql, tt.expected, got)
+		}
+	}
+
+	invalid := []string{
+		"SELECT a FROM t WHERE a BETWEEN 1",
+		"SELECT a FROM t WHERE a BETWEEN 1 OR 5",
+		"SELECT a FROM t WHERE a IN ()",
+		"SELECT a FROM t WHERE a IN (1, 2",
+		"SELECT a FROM t WHERE a IN 1",
+		"SELECT a FROM t WHERE a NOT = 1",
+		"SELECT a FROM t WHERE a LIKE 'x' ESCAPE",
+	}
+	for _, sql := range invalid {
+		if _, err := parser.ParseSQL(sql); err == nil {
+			t.Errorf("%s: expected error", sql)
+		}
+	}
+}
+
+// TestParseIndexStatements tests CREATE INDEX and DROP INDEX
+func TestParseIndexStatements(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"CREATE INDEX idx_name ON users (name)", "CREATE INDEX idx_name ON users (name)"},
+		{"create unique index if not exists idx_email on users (tenant, email) using hash", "CREATE UNIQUE INDEX IF NOT EXISTS idx_email ON users (tenant, email) USING hash"},
+		{"DROP INDEX idx_name", "DROP INDEX idx_name"},
+		{"DROP INDEX IF EXISTS idx_name", "DROP INDEX IF EXISTS idx_name"},
+	}
+
+	for _, tt := range tests {
+		stmt, err := parser.ParseSQL(tt.input)
+		if err != nil {
+			t.Errorf("%s: parse failed: %v", tt.input, err)
+			continue
+		}
+		if stmt.String() != tt.expected {
+			t.Errorf("%s: expected %q, got %q", tt.input, tt.expected, stmt.String())
+		}
+	}
+
+	stmt, _ := parser.ParseSQL("CREATE UNIQUE INDEX idx ON users (a, b)")
+	create, ok := stmt.(*parser.CreateIndexStatement)
+	if !ok {
+		t.Fatalf("Expected *CreateIndexStatement, got %T", stmt)
+	}
+	if !create.Unique || create.IfNotExists || len(create.Columns) != 2 || create.Using != nil {
+		t.Errorf("Unexpected statement: %+v", create)
+	}
+
+	invalid := []string{
+		"CREATE INDEX ON users (name)",
+		"CREATE INDEX idx users (name)",
+		"CREATE INDEX idx ON users ()",
+		"CREATE INDEX IF EXISTS idx ON users (name)",
+		"CREATE UNIQUE TABLE t (id INTEGER)",
+		"DROP INDEX",
+	}
+	for _, sql := range invalid {
+		if _, err := parser.ParseSQL(sql); err == nil {
+			t.Errorf("%s: expected parse error", sql)
+		}
+	}
+}
+
+func TestParseTransactionStatements(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"BEGIN", "BEGIN"},
+		{"begin transaction", "BEGIN"},
+		{"START TRANSACTION", "BEGIN"},
+		{"BEGIN ISOLATION LEVEL SERIALIZABLE", "BEGIN ISOLATION LEVEL SERIALIZABLE"},
+		{"BEGIN WORK ISOLATION LEVEL read uncommitted", "BEGIN ISOLATION LEVEL READ UNCOMMITTED"},
+		{"START TRANSACTION ISOLATION LEVEL REPEATABLE READ", "BEGIN ISOLATION LEVEL REPEATABLE READ"},
+		{"COMMIT", "COMMIT"},
+		{"COMMIT WORK", "COMMIT"},
+		{"ROLLBACK TRANSACTION", "ROLLBACK"},
+		{"ROLLBACK TO SAVEPOINT sp1", "ROLLBACK TO SAVEPOINT sp1"},
+		{"ROLLBACK TO sp1", "ROLLBACK TO SAVEPOINT sp1"},
+		{"SAVEPOINT sp1", "SAVEPOINT sp1"},
+		{"RELEASE SAVEPOINT sp1", "RELEASE SAVEPOINT sp1"},
+		{"RELEASE sp1", "RELEASE SAVEPOINT sp1"},
+	}
+
+	for _, tt := range tests {
+		stmt, err := parser.ParseSQL(tt.input)
+		if err != nil {
+			t.Errorf("%s: parse failed: %v", tt.input, err)
+			continue
+		}
+		if stmt.String() != tt.expected {
+			t.Errorf("%s: expected %q, got %q", tt.input, tt.expected, stmt.String())
+		}
+	}
+
+	stmt, _ := parser.ParseSQL("BEGIN ISOLATION LEVEL READ COMMITTED")
+	begin, ok := stmt.(*parser.BeginStatement)
+	if !ok {
+		t.Fatalf("Expected *BeginStatement, got %T", stmt)
+	}
+	if begin.IsolationLevel != parser.IsolationReadCommitted {
+		t.Errorf("Expected READ COMMITTED, got %s", begin.IsolationLevel)
+	}
+
+	invalid := []string{
+		"START",
+		"BEGIN ISOLATION SERIALIZABLE",
+		"BEGIN ISOLATION LEVEL READ",
+		"BEGIN ISOLATION LEVEL SNAPSHOT",
+		"ROLLBACK TO",
+		"SAVEPOINT",
+		"RELEASE SAVEPOINT",
+		"VACUUM",
+	}
+	for _, sql := range invalid {
+		if _, err := parser.ParseSQL(sql); err == nil {
+			t.Errorf("%s: expected parse error", sql)
+		}
+	}
+}
+
+func TestParseWindowFunctions(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"SELECT ROW_NUMBER() OVER () FROM t", "SELECT ROW_NUMBER() OVER () FROM t"},
+		{"SELECT rank() OVER (PARTITION BY a, b ORDER BY c DESC) FROM t", "SELECT rank() OVER (PARTITION BY a, b ORDER BY c DESC) FROM t"},
+		{"SELECT lag(x, 2, 0) OVER (ORDER BY d) FROM t", "SELECT lag(x, 2, 0) OVER (ORDER BY d ASC) FROM t"},
+		{"SELECT sum(x) OVER (ORDER BY d ROWS 2 PRECEDING) FROM t", "SELECT SUM(x) OVER (ORDER BY d ASC ROWS BETWEEN 2 PRECEDING AND CURRENT ROW) FROM t"},
+		{"SELECT avg(x) OVER (ORDER BY d RANGE BETWEEN UNBOUNDED PRECEDING AND UNBOUNDED FOLLOWING) FROM t", "SELECT AVG(x) OVER (ORDER BY d ASC RANGE BETWEEN UNBOUNDED PRECEDING AND UNBOUNDED FOLLOWING) FROM t"},
+		{"SELECT count(*) OVER (PARTITION BY a ROWS BETWEEN CURRENT ROW AND 1 FOLLOWING) FROM t", "SELECT COUNT(*) OVER (PARTITION BY a ROWS BETWEEN CURRENT ROW AND 1 FOLLOWING) FROM t"},
+		{"SELECT max(x) FROM t", "SELECT MAX(x) FROM t"},
+		{"SELECT rank() OVER w, sum(x) OVER v FROM t WINDOW w AS (PARTITION BY a ORDER BY d), v AS (ORDER BY d ROWS 1 PRECEDING) ORDER BY rank() OVER w", "SELECT rank() OVER w, SUM(x) OVER v FROM t WINDOW w AS (PARTITION BY a ORDER BY d ASC), v AS (ORDER BY d ASC ROWS BETWEEN 1 PRECEDING AND CURRENT ROW) ORDER BY rank() OVER w ASC"},
+	}
+
+	for _, tt := range tests {
+		stmt, err := parser.ParseSQL(tt.input)
+		if err != nil {
+			t.Errorf("%s: parse failed: %v", tt.input, err)
+			continue
+		}
+		if stmt.String() != tt.expected {
+			t.Errorf("%s: expected %q, got %q", tt.input, tt.expected, stmt.String())
+		}
+	}
+
+	stmt, _ := parser.ParseSQL("SELECT first_value(x) OVER (PARTITION BY a ORDER BY d ROWS BETWEEN 1 PRECEDING AND UNBOUNDED FOLLOWING) FROM t")
+	call, ok := stmt.(*parser.SelectStatement).SelectClause.Columns[0].(*parser.FunctionCall)
+	if !ok || !call.IsWindow() {
+		t.Fatalf("Expected window function call, got %T", stmt.(*parser.SelectStatement).SelectClause.Columns[0])
+	}
+	frame := call.Over.Frame
+	if frame == nil || frame.Unit != parser.FrameRows || frame.Start.Type != parser.OffsetPreceding || frame.End.Type != parser.UnboundedFollowing {
+		t.Errorf("Unexpected frame %v", frame)
+	}
+
+	// A named window takes the parts of its definition
+	stmt, _ = parser.ParseSQL("SELECT sum(x) OVER w FROM t WINDOW w AS (PARTITION BY a ORDER BY d)")
+	over := stmt.(*parser.SelectStatement).SelectClause.Columns[0].(*parser.FunctionCall).Over
+	if over.Name == nil || over.Name.Value != "w" || len(over.PartitionBy) != 1 || over.OrderBy == nil {
+		t.Errorf("Expected the parts of window w, got %v", over)
+	}
+
+	invalid := []string{
+		"SELECT sum(x) OVER x FROM t",
+		"SELECT sum(x) OVER x FROM t WINDOW w AS (ORDER BY d)",
+		"SELECT sum(x) OVER w FROM t WINDOW w AS (ORDER BY d), w AS ()",
+		"SELECT (SELECT sum(x) OVER w FROM u) FROM t WINDOW w AS (ORDER BY d)",
+		"SELECT a FROM t UNION SELECT a FROM u ORDER BY rank() OVER w",
+		"SELECT a FROM t WINDOW w",
+		"SELECT sum(x) OVER (ROWS 3) FROM t",
+		"SELECT sum(x) OVER (ORDER BY d ROWS BETWEEN 1 PRECEDING) FROM t",
+		"SELECT sum(x) OVER (PARTITION a) FROM t",
+		"SELECT sum FROM t",
+	}
+	for _, sql := range invalid {
+		if _, err := parser.ParseSQL(sql); err == nil {
+			t.Errorf("%s: expected parse error", sql)
+		}
+	}
+}
+
+func TestParseViewStatements(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"CREATE VIEW v AS SELECT a FROM t", "CREATE VIEW v AS SELECT a FROM t"},
+		{"create or replace view v (x, y) as select a, b from t where a > 1", "CREATE OR REPLACE VIEW v (x, y) AS SELECT a, b FROM t WHERE (a > 1)"},
+		{"CREATE VIEW v AS WITH c AS (SELECT 1) SELECT * FROM c", "CREATE VIEW v AS WITH c AS (SELECT 1) SELECT * FROM c"},
+		{"CREATE MATERIALIZED VIEW IF NOT EXISTS mv AS SELECT a FROM t WITH NO DATA", "CREATE MATERIALIZED VIEW IF NOT EXISTS mv AS SELECT a FROM t WITH NO DATA"},
+		{"CREATE MATERIALIZED VIEW mv AS SELECT a FROM t WITH DATA", "CREATE MATERIALIZED VIEW mv AS SELECT a FROM t"},
+		{"DROP VIEW IF EXISTS v CASCADE", "DROP VIEW IF EXISTS v CASCADE"},
+		{"DROP MATERIALIZED VIEW mv RESTRICT", "DROP MATERIALIZED VIEW mv"},
+		{"DROP TABLE t CASCADE", "DROP TABLE t CASCADE"},
+		{"REFRESH MATERIALIZED VIEW mv", "REFRESH MATERIALIZED VIEW mv"},
+		{"refresh materialized view concurrently mv", "REFRESH MATERIALIZED VIEW CONCURRENTLY mv"},
+		{"SELECT * FROM (SELECT a, b FROM t) AS d (x, y)", "SELECT * FROM (SELECT a, b FROM t) AS d (x, y)"},
+	}
+
+	for _, tt := range tests {
+		stmt, err := parser.ParseSQL(tt.input)
+		if err != nil {
+			t.Errorf("%s: parse failed: %v", tt.input, err)
+			continue
+		}
+		if stmt.String() != tt.expected {
+			t.Errorf("%s: expected %q, got %q", tt.input, tt.expected, stmt.String())
+		}
+	}
+
+	stmt, _ := parser.ParseSQL("CREATE MATERIALIZED VIEW mv (x) AS SELECT a FROM t WITH NO DATA")
+	create, ok := stmt.(*parser.CreateViewStatement)
+	if !ok {
+		t.Fatalf("Expected *CreateViewStatement, got %T", stmt)
+	}
+	if !create.Materialized || !create.WithNoData || create.OrReplace || len(create.Columns) != 1 {
+		t.Errorf("Unexpected statement: %+v", create)
+	}
+
+	invalid := []string{
+		"CREATE VIEW AS SELECT a FROM t",
+		"CREATE VIEW v SELECT a FROM t",
+		"CREATE VIEW v () AS SELECT a FROM t",
+		"CREATE OR REPLACE MATERIALIZED VIEW mv AS SELECT a FROM t",
+		"CREATE VIEW v AS SELECT a FROM t WITH NO DATA",
+		"REFRESH VIEW mv",
+		"DROP VIEW",
+		"SELECT * FROM (SELECT a FROM t) AS d ()",
+	}
+	for _, sql := range invalid {
+		if _, err := parser.ParseSQL(sql); err == nil {
+			t.Errorf("%s: expected parse error", sql)
+		}
+	}
+}
+
+func TestParseDataModification(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"INSERT INTO t (a, b) VALUES (1, 'x'), (2, 'y')", "INSERT INTO t (a, b) VALUES (1, 'x'), (2, 'y')"},
+		{"INSERT INTO t SELECT a, b FROM s WHERE a > 1", "INSERT INTO t SELECT a, b FROM s WHERE (a > 1)"},
+		{"insert into t (a) with c as (select 1) select * from c", "INSERT INTO t (a) WITH c AS (SELECT 1) SELECT * FROM c"},
+		{"INSERT INTO t (b) VALUES ('x') RETURNING id", "INSERT INTO t (b) VALUES ('x') RETURNING id"},
+		{"INSERT INTO t SELECT * FROM s returning *", "INSERT INTO t SELECT * FROM s RETURNING *"},
+		{"UPDATE t SET a = a + 1 WHERE b = 'x' RETURNING a, b AS c", "UPDATE t SET a = (a + 1) WHERE (b = 'x') RETURNING a, b AS c"},
+		{"DELETE FROM t RETURNING t.a", "DELETE FROM t RETURNING t.a"},
+		{"CREATE TABLE t (id INT PRIMARY KEY AUTO_INCREMENT, b TEXT)", "CREATE TABLE t (id INT PRIMARY KEY AUTO_INCREMENT, b TEXT)"},
+	}
+
+	for _, tt := range tests {
+		stmt, err := parser.ParseSQL(tt.input)
+		if err != nil {
+			t.Errorf("%s: parse failed: %v", tt.input, err)
+			continue
+		}
+		if stmt.String() != tt.expected {
+			t.Errorf("%s: expected %q, got %q", tt.input, tt.expected, stmt.String())
+		}
+	}
+
+	stmt, _ := parser.ParseSQL("INSERT INTO t SELECT a FROM s RETURNING a, b")
+	insert, ok := stmt.(*parser.InsertStatement)
+	if !ok {
+		t.Fatalf("Expected *InsertStatement, got %T", stmt)
+	}
+	if insert.Query == nil || insert.Values != nil || len(insert.Returning) != 2 {
+		t.Errorf("Unexpected statement: %+v", insert)
+	}
+
+	invalid := []string{
+		"INSERT INTO t (a)",
+		"INSERT INTO t VALUES (1) RETURNING",
+		"UPDATE t SET a = 1 RETURNING a,",
+		"DELETE FROM t RETURNING",
+	}
+	for _, sql := range invalid {
+		if _, err := parser.ParseSQL(sql); err == nil {
+			t.Errorf("%s: expected parse error", sql)
+		}
+	}
+}
+
+func TestParseUpsertAndMerge(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"INSERT INTO t (a, b) VALUES (1, 'x') ON CONFLICT (a) DO NOTHING", "INSERT INTO t (a, b) VALUES (1, 'x') ON CONFLICT (a) DO NOTHING"},
+		{"INSERT INTO t VALUES (1) on conflict do nothing", "INSERT INTO t VALUES (1) ON CONFLICT DO NOTHING"},
+		{"INSERT INTO t (a, b) VALUES (1, 'x') ON CONFLICT (a) DO UPDATE SET b = excluded.b WHERE t.b <> excluded.b RETURNING a",
+			"INSERT INTO t (a, b) VALUES (1, 'x') ON CONFLICT (a) DO UPDATE SET b = excluded.b WHERE (t.b != excluded.b) RETURNING a"},
+		{"INSERT INTO t SELECT * FROM s ON CONFLICT (a, b) DO UPDATE SET c = c + 1", "INSERT INTO t SELECT * FROM s ON CONFLICT (a, b) DO UPDATE SET c = (c + 1)"},
+		{"MERGE INTO t USING s ON t.id = s.id WHEN MATCHED THEN UPDATE SET v = s.v WHEN NOT MATCHED THEN INSERT (id, v) VALUES (s.id, s.v)",
+			"MERGE INTO t USING s ON (t.id = s.id) WHEN MATCHED THEN UPDATE SET v = s.v WHEN NOT MATCHED THEN INSERT (id, v) VALUES (s.id, s.v)"},
+		{"merge into t tgt using (select id from s) src on tgt.id = src.id when matched and src.id > 1 then delete when matched then do nothing when not matched then insert values (src.id)",
+			"MERGE INTO t AS tgt USING (SELECT id FROM s) AS src ON (tgt.id = src.id) WHEN MATCHED AND (src.id > 1) THEN DELETE WHEN MATCHED THEN DO NOTHING WHEN NOT MATCHED THEN INSERT VALUES (src.id)"},
+	}
+
+	for _, tt := range tests {
+		stmt, err := parser.ParseSQL(tt.input)
+		if err != nil {
+			t.Errorf("%s: parse failed: %v", tt.input, err)
+			continue
+		}
+		if stmt.String() != tt.expected {
+			t.Errorf("%s: expected %q, got %q", tt.input, tt.expected, stmt.String())
+		}
+	}
+
+	stmt, _ := parser.ParseSQL("MERGE INTO t AS x USING s AS y ON x.a = y.a WHEN NOT MATCHED AND y.b IS NULL THEN DO NOTHING")
+	merge, ok := stmt.(*parser.MergeStatement)
+	if !ok {
+		t.Fatalf("Expected *MergeStatement, got %T", stmt)
+	}
+	if len(merge.Clauses) != 1 || merge.Clauses[0].Matched || merge.Clauses[0].Action != parser.MergeDoNothing ||
+		merge.Clauses[0].Condition == nil {
+		t.Errorf("Unexpected statement: %+v", merge)
+	}
+
+	invalid := []string{
+		"INSERT INTO t VALUES (1) ON CONFLICT (a)",
+		"INSERT INTO t VALUES (1) ON CONFLICT DO UPDATE",
+		"INSERT INTO t VALUES (1) ON DUPLICATE DO NOTHING",
+		"MERGE INTO t USING s ON t.a = s.a",
+		"MERGE INTO t ON t.a = s.a WHEN MATCHED THEN DELETE",
+		"MERGE INTO t USING s ON t.a = s.a WHEN MATCHED THEN INSERT VALUES (1)",
+		"MERGE INTO t USING s ON t.a = s.a WHEN NOT MATCHED THEN DELETE",
+		"MERGE INTO t USING s ON t.a = s.a WHEN NOT MATCHED THEN INSERT VALUES (1), (2)",
+	}
+	for _, sql := range invalid {
+		if _, err := parser.ParseSQL(sql); err == nil {
+			t.Errorf("%s: expected parse error", sql)
+		}
+	}
+}
+
+func TestParseJoinedUpdateAndDelete(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"UPDATE t SET v = s.v FROM s WHERE t.id = s.id", "UPDATE t SET v = s.v FROM s WHERE (t.id = s.id)"},
+		{"update t set v = x.v, w = 1 from s as x, u where t.id = x.id and x.k = u.k returning t.id",
+			"UPDATE t SET v = x.v, w = 1 FROM s AS x, u WHERE ((t.id = x.id) AND (x.k = u.k)) RETURNING t.id"},
+		{"UPDATE t SET v = 1 FROM s JOIN u ON s.k = u.k WHERE t.id = s.id", "UPDATE t SET v = 1 FROM s INNER JOIN u ON (s.k = u.k) WHERE (t.id = s.id)"},
+		{"DELETE FROM t USING s WHERE t.id = s.id", "DELETE FROM t USING s WHERE (t.id = s.id)"},
+		{"delete from t using (select id from s) as x, u returning *", "DELETE FROM t USING (SELECT id FROM s) AS x, u RETURNING *"},
+	}
+
+	for _, tt := range tests {
+		stmt, err := parser.ParseSQL(tt.input)
+		if err != nil {
+			t.Errorf("%s: parse failed: %v", tt.input, err)
+			continue
+		}
+		if stmt.String() != tt.expected {
+			t.Errorf("%s: expected %q, got %q", tt.input, tt.expected, stmt.String())
+		}
+	}
+
+	stmt, _ := parser.ParseSQL("DELETE FROM t USING s, u WHERE t.id = s.id")
+	del, ok := stmt.(*parser.DeleteStatement)
+	if !ok {
+		t.Fatalf("Expected *DeleteStatement, got %T", stmt)
+	}
+	if del.Using == nil || len(del.Using.Tables) != 2 || del.WhereClause == nil {
+		t.Errorf("Unexpected statement: %+v", del)
+	}
+
+	invalid := []string{
+		"UPDATE t SET v = 1 FROM WHERE t.id = 1",
+		"UPDATE t FROM s SET v = 1",
+		"DELETE FROM t USING WHERE t.id = 1",
+		"DELETE FROM t USING s,",
+	}
+	for _, sql := range invalid {
+		if _, err := parser.ParseSQL(sql); err == nil {
+			t.Errorf("%s: expected parse error", sql)
+		}
+	}
+}
+
+// TestParseErrorPositions tests that syntax errors carry their position, the
+// tokens expected there and an excerpt of the source pointing at them
+func TestParseErrorPositions(t *testing.T) {
+	_, err := parser.ParseSQL("INSERT INTO users (id, name\nVALUES (1, 'alice')")
+	errs, ok := err.(parser.ParseErrors)
+	if !ok || len(errs) != 1 {
+		t.Fatalf("Expected one ParseError, got %v", err)
+	}
+	e := errs[0]
+	if e.Line != 2 || e.Column != 1 || e.Offset != 28 {
+		t.Errorf("Expected error at 2:1 (offset 28), got %d:%d (offset %d)", e.Line, e.Column, e.Offset)
+	}
+	if len(e.Expected) != 1 || e.Expected[0] != "RPAREN" || e.Found != "VALUES" {
+		t.Errorf("Expected RPAREN instead of VALUES, got %v instead of %s", e.Expected, e.Found)
+	}
+	if e.Excerpt != "VALUES (1, 'alice')\n^" {
+		t.Errorf("Unexpected excerpt %q", e.Excerpt)
+	}
+
+	_, err = parser.ParseSQL("SELECT id\n\tFROM users WHERE")
+	errs, ok = err.(parser.ParseErrors)
+	if !ok || len(errs) != 1 {
+		t.Fatalf("Expected one ParseError, got %v", err)
+	}
+	if e := errs[0]; e.Line != 2 || e.Column != 18 || e.Found != "EOF" {
+		t.Errorf("Expected error at EOF at 2:18, got %s at %d:%d", e.Found, e.Line, e.Column)
+	}
+	if detail := errs[0].Detail(); !strings.HasSuffix(detail, "\n\tFROM users WHERE\n\t                ^") {
+		t.Errorf("Unexpected detail %q", detail)
+	}
+
+	// Names and literals record where they appear
+	stmt, err := parser.ParseSQL("SELECT id\nFROM users WHERE name = 'bob'")
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	where := stmt.(*parser.SelectStatement).WhereClause.Condition.(*parser.BinaryExpression)
+	if pos := parser.PositionOf(where); pos.Line != 2 || pos.Column != 18 {
+		t.Errorf("Expected condition at 2:18, got %d:%d", pos.Line, pos.Column)
+	}
+	if pos := parser.PositionOf(where.Right); pos.Line != 2 || pos.Column != 25 {
+		t.Errorf("Expected literal at 2:25, got %d:%d", pos.Line, pos.Column)
+	}
+}
+
+// TestParseScriptRecovery tests that a script reports every invalid
+// statement and still returns the valid ones
+func TestParseScriptRecovery(t *testing.T) {
+	script := "SELECT FROM;\nDELETE FROM users;\nINSERT INTO t VALUES (1;\nDROP TABLE users WHERE;\nDROP TABLE t"
+
+	statements, err := parser.ParseScript(script)
+	errs, ok := err.(parser.ParseErrors)
+	if !ok || len(errs) != 3 {
+		t.Fatalf("Expected 3 parse errors, got %v", err)
+	}
+	if len(statements) != 2 || statements[0].Line != 2 || statements[1].Line != 5 {
+		t.Fatalf("Expected the statements at lines 2 and 5, got %d statements", len(statements))
+	}
+
+	expected := []struct{ statement, line, column int }{{1, 1, 8}, {3, 3, 24}, {4, 4, 18}}
+	for i, want := range expected {
+		if e := errs[i]; e.Statement != want.statement || e.Line != want.line || e.Column != want.column {
+			t.Errorf("Error %d: expected statement %d at %d:%d, got %v", i, want.statement, want.line, want.column, e)
+		}
+	}
+	if !strings.Contains(err.Error(), "statement 3 at line 3, column 24: expected RPAREN, got SEMICOLON") {
+		t.Errorf("Unexpected error message %v", err)
+	}
+}
+
+// walkCorpus covers every kind of node the parser builds
+var walkCorpus = []string{
+	"WITH RECURSIVE t (n) AS (SELECT 1 UNION ALL SELECT n + 1 FROM t WHERE n < 5) SELECT DISTINCT n AS m FROM t",
+	"SELECT a FROM x UNION SELECT b FROM y ORDER BY 1 LIMIT 3 OFFSET 1",
+	"SELECT u.name, COUNT(*) FROM users u LEFT JOIN orders o ON u.id = o.user_id WHERE u.age BETWEEN 18 AND 65 AND u.name LIKE 'a%' ESCAPE '!' AND o.total IS NOT NULL GROUP BY u.name HAVING COUNT(*) > 1 ORDER BY u.name DESC",
+	"SELECT rank() OVER w FROM t WINDOW w AS (PARTITION BY g ORDER BY y ROWS BETWEEN 2 PRECEDING AND CURRENT ROW) ORDER BY SUM(x) OVER w",
+	"SELECT SUM(x) OVER (PARTITION BY g ORDER BY y ROWS BETWEEN 2 PRECEDING AND CURRENT ROW), CASE WHEN a IN (1, 2) THEN -b ELSE CAST(c AS VARCHAR(10)) END FROM (SELECT * FROM t) AS d (x, y) WHERE EXISTS (SELECT 1 FROM s WHERE s.id = d.x) AND id NOT IN (SELECT id FROM r) AND z = ?",
+	"INSERT INTO t (a, b) VALUES (1, $1), (2, 'x') ON CONFLICT (a) DO UPDATE SET b = 'y' WHERE t.a > 0 RETURNING a",
+	"INSERT INTO t SELECT * FROM s RETURNING *",
+	"UPDATE t SET a = s.a FROM s WHERE t.id = s.id RETURNING t.a",
+	"DELETE FROM t USING s WHERE t.id = s.id RETURNING t.id",
+	"MERGE INTO t USING s AS src ON t.id = src.id WHEN MATCHED AND src.gone THEN DELETE WHEN MATCHED THEN UPDATE SET a = src.a WHEN NOT MATCHED THEN INSERT (id, a) VALUES (src.id, src.a)",
+	"CREATE TABLE t (id INT PRIMARY KEY, name VARCHAR(10) NOT NULL DEFAULT 'x', p INT, CONSTRAINT ck CHECK (id > 0), FOREIGN KEY (p) REFERENCES parent (id)) PARTITION BY RANGE (id) (PARTITION p0 VALUES LESS THAN (10), PARTITION p1 VALUES LESS THAN (MAXVALUE)) WITH (ttl = '30 days', ttl_column = name)",
+	"CREATE TABLE l (region VARCHAR(10)) PARTITION BY LIST (region) (PARTITION eu VALUES IN ('de', 'fr'))",
+	"ALTER TABLE t ADD COLUMN c INT, DROP COLUMN d, RENAME COLUMN e TO f, ADD CONSTRAINT u UNIQUE (c), DROP CONSTRAINT v, SET (fillfactor = 70), RESET (fillfactor)",
+	"ALTER TABLE t RENAME TO s",
+	"CREATE MATERIALIZED VIEW v (a) AS SELECT a FROM t",
+	"REFRESH MATERIALIZED VIEW v",
+	"DROP VIEW IF EXISTS v",
+	"CREATE UNIQUE INDEX i ON t (a, b) USING hash",
+	"DROP INDEX i",
+	"DROP TABLE t",
+	"ROLLBACK TO SAVEPOINT s",
+	"SAVEPOINT s",
+	"RELEASE SAVEPOINT s",
+}
+
+// reachableNodes returns every node held in the fields of a tree,
+// found by reflection rather than by the walk under test
+func reachableNodes(node parser.Node) map[parser.Node]bool {
+	nodes := map[parser.Node]bool{}
+	nodeType := reflect.TypeOf((*parser.Node)(nil)).Elem()
+
+	var visit func(v reflect.Value)
+	visit = func(v reflect.Value) {
+		switch v.Kind() {
+		case reflect.Interface:
+			if !v.IsNil() {
+				visit(v.Elem())
+			}
+		case reflect.Ptr:
+			if v.IsNil() {
+				return
+			}
+			if v.Type().Implements(nodeType) {
+				nodes[v.Interface().(parser.Node)] = true
+			}
+			visit(v.Elem())
+		case reflect.Slice:
+			for i := 0; i < v.Len(); i++ {
+				visit(v.Index(i))
+			}
+		case reflect.Struct:
+			for i := 0; i < v.NumField(); i++ {
+				if v.Type().Field(i).IsExported() {
+					visit(v.Field(i))
+				}
+			}
+		}
+	}
+	visit(reflect.ValueOf(node))
+	return nodes
+}
+
+// TestWalkVisitsEveryNode tests that the walk reaches every node of a tree
+// exactly once, and reports each node's parent
+func TestWalkVisitsEveryNode(t *testing.T) {
+	for _, sql := range walkCorpus {
+		stmt, err := parser.ParseSQL(sql)
+		if err != nil {
+			t.Fatalf("Failed to parse %q: %v", sql, err)
+		}
+
+		visited := map[parser.Node]int{}
+		parser.Inspect(stmt, func(node parser.Node) bool {
+			if node != nil {
+				visited[node]++
+			}
+			return true
+		})
+
+		for node := range reachableNodes(stmt) {
+			if visited[node] != 1 {
+				t.Errorf("%q: %s %q visited %d times", sql, node.NodeType(), node.String(), visited[node])
+			}
+		}
+
+		// Each node's parent is the node visited around it
+		var stack []parser.Node
+		parser.Rewrite(stmt, func(c *parser.Cursor) bool {
+			var parent parser.Node
+			if len(stack) > 0 {
+				parent = stack[len(stack)-1]
+			}
+			if c.Parent() != parent {
+				t.Errorf("%q: wrong parent of %s", sql, c.Node().NodeType())
+			}
+			stack = append(stack, c.Node())
+			return true
+		}, func(c *parser.Cursor) bool {
+			stack = stack[:len(stack)-1]
+			return true
+		})
+	}
+}
+
+// TestWalkOrder tests that children are visited in source order and that
+// returning false skips a node's children
+func TestWalkOrder(t *testing.T) {
+	stmt, err := parser.ParseSQL("SELECT a, f(b) FROM t WHERE c > (SELECT d FROM u) ORDER BY e")
+	if err != nil {
+		t.Fatalf("Failed to parse: %v", err)
+	}
+
+	var names []string
+	parser.Inspect(stmt, func(node parser.Node) bool {
+		switch n := node.(type) {
+		case *parser.SubqueryExpression:
+			return false
+		case *parser.Identifier:
+			names = append(names, n.Value)
+		}
+		return true
+	})
+	if got := strings.Join(names, " "); got != "a f b t c e" {
+		t.Errorf("Expected names a f b t c e, got %s", got)
+	}
+}
+
+// TestRewrite tests replacing nodes while walking a tree
+func TestRewrite(t *testing.T) {
+	stmt, err := parser.ParseSQL("SELECT a + ? FROM t WHERE b = ? AND c IN (SELECT d FROM u WHERE e = ?)")
+	if err != nil {
+		t.Fatalf("Failed to parse: %v", err)
+	}
+
+	// Bind each parameter to a literal
+	values := []int{10, 20, 30}
+	result := parser.Rewrite(stmt, nil, func(c *parser.Cursor) bool {
+		if p, ok := c.Node().(*parser.Parameter); ok {
+			c.Replace(&parser.Literal{Value: values[p.Index-1], Type: lexer.NUMBER})
+		}
+		return true
+	})
+	expected := "SELECT (a + 10) FROM t WHERE ((b = 20) AND (c IN (SELECT d FROM u WHERE (e = 30))))"
+	if result != stmt || stmt.String() != expected {
+		t.Errorf("Expected %s, got %s", expected, stmt.String())
+	}
+
+	// A replacement made before the children are visited has its own
+	// children visited
+	where := stmt.(*parser.SelectStatement).WhereClause
+	var seen []string
+	parser.Rewrite(where, func(c *parser.Cursor) bool {
+		if b, ok := c.Node().(*parser.BinaryExpression); ok && b.Operator == parser.And {
+			c.Replace(&parser.UnaryExpression{Operator: parser.Not, Operand: b.Left})
+		}
+		if id, ok := c.Node().(*parser.Identifier); ok {
+			seen = append(seen, id.Value)
+		}
+		return true
+	}, nil)
+	if where.String() != "WHERE (NOT (b = 20))" || strings.Join(seen, " ") != "b" {
+		t.Errorf("Unexpected rewrite %s visiting %v", where.String(), seen)
+	}
+
+	// Replacing the root returns the replacement
+	root := parser.Rewrite(where.Condition, func(c *parser.Cursor) bool {
+		if c.Parent() == nil {
+			c.Replace(&parser.Literal{Value: true, Type: lexer.TRUE})
+		}
+		return true
+	}, nil)
+	if root.String() != "TRUE" {
+		t.Errorf("Expected the root to be replaced, got %s", root.String())
+	}
+
+	// A false result after the children stops the walk
+	visits := 0
+	parser.Rewrite(stmt, nil, func(c *parser.Cursor) bool {
+		visits++
+		return false
+	})
+	if visits != 1 {
+		t.Errorf("Expected the walk to stop after one node, got %d visits", visits)
+	}
+}
+
+// TestLexQuotingAndLiterals tests quoted identifiers, string escapes and
+// the blob and number literal forms
+func TestLexQuotingAndLiterals(t *testing.T) {
+	tests := []struct {
+		sql       string
+		tokenType lexer.TokenType
+		value     string
+	}{
+		{`"Order Total"`, lexer.IDENTIFIER, "Order Total"},
+		{`"select"`, lexer.IDENTIFIER, "select"},
+		{`"say ""hi"""`, lexer.IDENTIFIER, `say "hi"`},
+		{"`MixedCase`", lexer.IDENTIFIER, "MixedCase"},
+		{"`back``tick`", lexer.IDENTIFIER, "back`tick"},
+		{"größe_2", lexer.IDENTIFIER, "größe_2"},
+		{"名前", lexer.IDENTIFIER, "名前"},
+		{`'it''s'`, lexer.STRING, "it's"},
+		{`'C:\temp'`, lexer.STRING, `C:\temp`},
+		{`'naïve ☃'`, lexer.STRING, "naïve ☃"},
+		{`E'a\tb\nc\\d\'e''f'`, lexer.STRING, "a\tb\nc\\d'e'f"},
+		{`e'\x'`, lexer.STRING, "x"},
+		{`E'\x41\x4a\x4B\x7'`, lexer.STRING, "AJK\x07"},
+		{`E'\xC3\xA9'`, lexer.STRING, "é"},
+		{`E'\101\60\0a\377'`, lexer.STRING, "A0\x00a\xff"},
+		{`E'\u00e9\U0001F600'`, lexer.STRING, "é😀"},
+		{`E'\q\"'`, lexer.STRING, `q"`},
+		{`E'\u12'`, lexer.ILLEGAL, `invalid unicode escape \u12`},
+		{`E'\uD800'`, lexer.ILLEGAL, `invalid unicode escape \uD800`},
+		{`E'\U00110000'`, lexer.ILLEGAL, `invalid unicode escape \U00110000`},
+		{`E'\400'`, lexer.ILLEGAL, `invalid octal escape \400`},
+		{`X'CAFE01'`, lexer.HEX_STRING, "CAFE01"},
+		{`x''`, lexer.HEX_STRING, ""},
+		{"1.5e10", lexer.NUMBER, "1.5e10"},
+		{"2E-3", lexer.NUMBER, "2E-3"},
+		{"7e+2", lexer.NUMBER, "7e+2"},
+		{"12", lexer.NUMBER, "12"},
+		{`X'ABC'`, lexer.ILLEGAL, "X'ABC'"},
+		{`X'GG'`, lexer.ILLEGAL, "X'GG'"},
+		{`'open`, lexer.ILLEGAL, "unterminated string"},
+		{`E'open\'`, lexer.ILLEGAL, "unterminated string"},
+		{`"open`, lexer.ILLEGAL, "unterminated quoted identifier"},
+		{`""`, lexer.ILLEGAL, "empty quoted identifier"},
+	}
+
+	for _, tt := range tests {
+		l := lexer.NewLexer(tt.sql)
+		token := l.NextToken()
+		if token.Type != tt.tokenType || token.Value != tt.value {
+			t.Errorf("%s: expected %s %q, got %s %q", tt.sql, tt.tokenType, tt.value, token.Type, token.Value)
+		}
+		if token.Type != lexer.ILLEGAL {
+			if next := l.NextToken(); next.Type != lexer.EOF {
+				t.Errorf("%s: expected one token, then got %s %q", tt.sql, next.Type, next.Value)
+			}
+		}
+	}
+
+	// An exponent needs digits, and positions count bytes while columns
+	// count characters
+	tokens, err := lexer.TokenizeSQL("SELECT 1e, é FROM t")
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if tokens[1].Value != "1" || tokens[2].Value != "e" {
+		t.Errorf("Expected 1 followed by e, got %q and %q", tokens[1].Value, tokens[2].Value)
+	}
+	if from := tokens[5]; from.Type != lexer.FROM || from.Position != 14 || from.Column != 14 {
+		t.Errorf("Expected FROM at byte 14, column 14, got %s at byte %d, column %d", from.Type, from.Position, from.Column)
+	}
+}
+
+// TestParseQuotedNamesAndLiterals tests that quoted names are never
+// keywords and that literals keep their values
+func TestParseQuotedNamesAndLiterals(t *testing.T) {
+	stmt, err := parser.ParseSQL(`SELECT "select", "First Name", X'00FF', 'it''s', 2.5e3 FROM "order" WHERE "from" = E'a\nb'`)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	selectStmt := stmt.(*parser.SelectStatement)
+
+	columns := selectStmt.SelectClause.Columns
+	if name := columns[0].(*parser.Identifier).Value; name != "select" {
+		t.Errorf("Expected name select, got %s", name)
+	}
+	if name := columns[1].(*parser.Identifier).Value; name != "First Name" {
+		t.Errorf("Expected name First Name, got %s", name)
+	}
+	blob := columns[2].(*parser.Literal)
+	if value, ok := blob.Value.([]byte); !ok || blob.Type != lexer.HEX_STRING || len(value) != 2 || value[1] != 0xFF {
+		t.Errorf("Expected the bytes 00 FF, got %v", blob.Value)
+	}
+	if s := columns[3].(*parser.Literal).String(); s != "'it''s'" || blob.String() != "X'00FF'" {
+		t.Errorf("Expected literals to print as written, got %s and %s", s, blob.String())
+	}
+	if number := columns[4].(*parser.Literal); number.Type != lexer.NUMBER || number.Value != "2.5e3" {
+		t.Errorf("Expected the number 2.5e3, got %v", number.Value)
+	}
+
+	if table := selectStmt.FromClause.Tables[0].(*parser.Identifier).Value; table != "order" {
+		t.Errorf("Expected table order, got %s", table)
+	}
+	where := selectStmt.WhereClause.Condition.(*parser.BinaryExpression)
+	if where.Right.(*parser.Literal).Value != "a\nb" {
+		t.Errorf("Expected an escaped newline, got %q", where.Right.(*parser.Literal).Value)
+	}
+}
+
+// TestKeywordTokenNames tests that every keyword's token type has a name
+// for error messages
+func TestKeywordTokenNames(t *testing.T) {
+	for keyword, tokenType := range lexer.Keywords {
+		if name := tokenType.String(); name != keyword {
+			t.Errorf("Expected token type of %s to be named %s, got %s", keyword, keyword, name)
+		}
+	}
+	if name := lexer.TokenType(-1).String(); name != "TokenType(-1)" {
+		t.Errorf("Expected an unknown token type to print its number, got %s", name)
+	}
+}
+
+// TestQuotedIdentifierRoundTrip tests that quoted names keep their quotes
+// when a statement is printed and parsed again
+func TestQuotedIdentifierRoundTrip(t *testing.T) {
+	sql := `WITH "my cte" ("a b") AS (SELECT 1) SELECT "order", "my table".id, "say ""hi""" AS "x y", (SELECT 1) AS "sub q" FROM "my table" WHERE "select" = 1`
+	stmt, err := parser.ParseSQL(sql)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	printed := stmt.String()
+	for _, name := range []string{`"my cte"`, `"a b"`, `"order"`, `"my table"`, `"say ""hi"""`, `"x y"`, `"sub q"`, `"select"`} {
+		if !strings.Contains(printed, name) {
+			t.Errorf("Expected %s in %s", name, printed)
+		}
+	}
+
+	reparsed, err := parser.ParseSQL(printed)
+	if err != nil {
+		t.Fatalf("Failed to parse %s: %v", printed, err)
+	}
+	if again := reparsed.String(); again != printed {
+		t.Errorf("Expected the printed statement to survive a round trip:\n%s\n%s", printed, again)
+	}
+
+	// Unquoted names print as written
+	if stmt, _ := parser.ParseSQL("SELECT id FROM users"); stmt.String() != "SELECT id FROM users" {
+		t.Errorf("Expected unquoted names to stay unquoted, got %s", stmt.String())
+	}
+}